@@ -4,6 +4,7 @@ package flags
 
 import (
 	"flag"
+	"time"
 )
 
 var (
@@ -16,5 +17,41 @@ var (
 	MasterIP    = flag.String("master-ip", "", "IP Address for Master Components")
 	ClusterCIDR = flag.String("cluster-cidr", "", "The CIDR range of pods in the cluster. It is used to bridge traffic coming from outside of the cluster. If not provided, no off-cluster bridging will be performed.")
 	Kubeconfig  = flag.String("kubeconfig", "/var/lib/kube-proxy/kubeconfig", "Path to kubeconfig file with authorization information (the master location is set by the master flag")
-	IPBase      = flag.String("base-ip", "", "First 3 octets of the IP address")
+	IPBase      = flag.String("base-ip", "", "First 3 octets of the pods' IPv4 addresses")
+	LogMaxSize  = flag.Int64("container-log-max-size", 10*1024*1024, "Size in bytes a container log is rotated at, 0 disables rotation")
+	LogMaxFiles = flag.Int("container-log-max-files", 5, "Number of container log files, including the active one, kept when rotating")
+	LogStateDir = flag.String("log-state-dir", "/var/lib/infranetes/logs", "Directory container log cursors are kept in, so log streaming can resume after a reconnect or restart")
+
+	ProxyMode                 = flag.String("proxy-mode", "iptables", "Mode of the kube-proxy run in each VM: iptables, userspace or ipvs")
+	ProxySyncPeriod           = flag.Duration("proxy-sync-period", 0, "How often kube-proxy refreshes its rules, 0 for kube-proxy's default")
+	ProxyMinSyncPeriod        = flag.Duration("proxy-min-sync-period", 0, "Minimum interval between kube-proxy's rule refreshes, 0 for kube-proxy's default")
+	ProxyMasqueradeAll        = flag.Bool("proxy-masquerade-all", false, "Have kube-proxy SNAT all traffic sent to service ips")
+	ProxyConntrackMaxPerCore  = flag.Int("proxy-conntrack-max-per-core", 0, "kube-proxy's conntrack entries per cpu, 0 for kube-proxy's default")
+	ProxyConntrackMin         = flag.Int("proxy-conntrack-min", 0, "kube-proxy's minimum conntrack entries, 0 for kube-proxy's default")
+	ProxyConntrackEstablished = flag.Duration("proxy-conntrack-tcp-timeout-established", 0, "kube-proxy's idle timeout for established tcp connections, 0 for kube-proxy's default")
+	ProxyConntrackCloseWait   = flag.Duration("proxy-conntrack-tcp-timeout-close-wait", 0, "kube-proxy's timeout for tcp connections in CLOSE_WAIT, 0 for kube-proxy's default")
+	KubeconfigSyncPeriod      = flag.Duration("kubeconfig-sync-period", time.Minute, "How often -kubeconfig is checked for rotated credentials to push to the VMs' kube-proxies, 0 disables it")
+	ProxyStatusPeriod         = flag.Duration("proxy-status-period", 30*time.Second, "How often the health of the VMs' kube-proxies is checked, 0 disables it")
+	ProxyHealthzBindAddress   = flag.String("proxy-healthz-bind-address", "0.0.0.0:10256", "Address and port kube-proxy serves its healthz on in each VM")
+	NetworkPolicy             = flag.Bool("network-policy", false, "Enforce NetworkPolicies inside each VM, with the credentials in -kubeconfig")
+	NetworkPolicySyncPeriod   = flag.Duration("network-policy-sync-period", 10*time.Second, "How often the VMs resync the NetworkPolicies that select their pods")
+	NetworkPolicyStatusPeriod = flag.Duration("network-policy-status-period", 30*time.Second, "How often the VMs are asked whether they enforce their pods' NetworkPolicies, 0 disables it")
+	Overlay                   = flag.String("overlay", "", "Tunnel the VMs' pod ips to this node over vxlan or wireguard, instead of relying on the cloud's routing")
+	OverlayCIDR               = flag.String("overlay-cidr", "", "The pod ips given out on the overlay, which the cluster has to route to this node, such as its pod cidr")
+	OverlayEndpoint           = flag.String("overlay-endpoint", "", "The address of this node the VMs send their tunnels to")
+	OverlayPort               = flag.Int("overlay-port", 0, "The overlay's udp port, 4789 for vxlan and 51820 for wireguard if 0")
+	OverlayVNI                = flag.Int("overlay-vni", 0, "The overlay's vxlan network identifier, 4242 if 0")
+	OverlayKeyFile            = flag.String("overlay-key-file", "/var/lib/infranetes/overlay.key", "This node's wireguard private key, created if it doesn't exist")
+	CNIConfDir                = flag.String("cni-conf-dir", "", "Join the VMs to the cluster's pod network with the first CNI config in this directory, such as /etc/cni/net.d")
+	CNIBinDir                 = flag.String("cni-bin-dir", "/opt/cni/bin", "Where the CNI plugins are installed in the VMs")
+	PodIPPolicy               = flag.String("pod-ip-policy", "private", "Which of a VM's addresses is its pod's ip: private, public, interface:<name> or cidr:<cidr>")
+	AgentIPPolicy             = flag.String("agent-ip-policy", "private", "Which of a VM's addresses infranetes reaches its vmserver on: private, public or cidr:<cidr>")
+	DualStack                 = flag.Bool("dual-stack", false, "Give pods an ipv6 address besides their ipv4 one")
+	IPv6CIDR                  = flag.String("ipv6-cidr", "", "The range the fake provider gives pods' ipv6 addresses out from with -dual-stack, aws pods get theirs from their subnet")
+	HostPortMode              = flag.String("hostport-mode", "firewall", "How pods' hostPorts are reached: firewall opens them on the pod's VM, node forwards them from this node's addresses to the VM")
+	FirewallPolicy            = flag.String("firewall-policy", "", "JSON file of firewall rules selected by pod namespace and labels, kept in a security group (aws) or firewall rules (gcp) of each pod's own")
+	FirewallPolicySyncPeriod  = flag.Duration("firewall-policy-sync-period", time.Minute, "How often -firewall-policy is reread and the pods' firewalls updated to it")
+	LBDrainTimeout            = flag.Duration("lb-drain-timeout", time.Minute, "How long stopping a pod waits for its load balancer target to drain before its containers are stopped")
+	LBHealthPeriod            = flag.Duration("lb-health-period", 10*time.Second, "How often the load balancer health of pods with the infranetes.lb.group annotation is checked, 0 disables it")
+	DNSTTL                    = flag.Int64("dns-ttl", 60, "TTL in seconds of the records pods get in the provider's DNS zone with the infranetes.dns.name annotation")
 )
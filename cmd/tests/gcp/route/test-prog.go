@@ -4,14 +4,14 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/apporbit/infranetes/pkg/infranetes/provider/gcp"
+	"github.com/apporbit/infranetes/pkg/common/gcp"
 )
 
 func main() {
 	args := os.Args
 
 	if len(args) != 4 {
-		fmt.Println("Usage:\n\ttest [1|0] dest (ip)")
+		fmt.Println("Usage:\n\ttest [1|0] instance ip")
 		return
 	}
 
@@ -23,7 +23,7 @@ func main() {
 
 	switch args[1] {
 	case "0":
-		err := svc.AddRoute(args[2], args[3])
+		err := svc.AddRoute(gcp.RouteName(args[3]), "default", args[2], args[3])
 		if err != nil {
 			fmt.Printf("Failed to add route: %v\n", err)
 		} else {
@@ -31,7 +31,7 @@ func main() {
 		}
 		return
 	case "1":
-		err := svc.DelRoute(args[2])
+		err := svc.DelRoute(gcp.RouteName(args[3]))
 		if err != nil {
 			fmt.Printf("Failed to del route: %v\n", err)
 		} else {
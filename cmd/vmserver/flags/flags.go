@@ -1,6 +1,9 @@
 package flags
 
-import "flag"
+import (
+	"flag"
+	"time"
+)
 
 var (
 	Version      = flag.Bool("version", false, "Print version and exit")
@@ -8,4 +11,24 @@ var (
 	Cert         = flag.String("cert", "/root/cert.pem", "Location of certificate file")
 	Key          = flag.String("key", "/root/key.pem", "Location of key file")
 	ContProvider = flag.String("contprovider", "docker", "Container Provider to use")
+	LogMaxSize   = flag.Int64("container-log-max-size", 10*1024*1024, "Size in bytes the docker json log of a container is rotated at, 0 disables rotation")
+	LogMaxFiles  = flag.Int("container-log-max-files", 3, "Number of docker json log files, including the active one, kept when rotating")
+	StateDir     = flag.String("state-dir", "/var/lib/infranetes/vmserver", "Where the sandbox's configuration and container table are kept so they survive a restart of vmserver")
+
+	KubeProxyPath = flag.String("kube-proxy-path", "", "kube-proxy binary to run instead of the one built into vmserver, e.g. for ipvs mode")
+
+	SeccompProfileRoot = flag.String("seccomp-profile-root", "/var/lib/kubelet/seccomp", "Directory localhost/ seccomp profiles of docker containers are loaded from")
+
+	ArtifactCacheDir    = flag.String("artifact-cache-dir", "/var/lib/infranetes/artifacts", "Where the systemd provider caches fetched binaries and bundles")
+	ArtifactCredentials = flag.String("artifact-credentials", "", "Json file mapping artifact hosts to {username, password} or {token}")
+	ArtifactAllowHTTP   = flag.Bool("artifact-allow-http", false, "Allow the systemd provider to fetch artifacts, and the nspawn and containerd providers images from registries on localhost, over plain http")
+	ArtifactCacheTTL    = flag.Duration("artifact-cache-ttl", time.Hour, "How long unused artifacts are kept in the cache")
+
+	NspawnRoot = flag.String("nspawn-root", "/var/lib/infranetes/nspawn", "Where the nspawn provider keeps unpacked images and container root filesystems")
+
+	ContainerdAddress     = flag.String("containerd-address", "/run/containerd/containerd.sock", "Socket of the containerd the containerd provider uses")
+	ContainerdNamespace   = flag.String("containerd-namespace", "infranetes", "Containerd namespace the containerd provider keeps its images and containers in")
+	ContainerdRoot        = flag.String("containerd-root", "/var/lib/infranetes/containerd", "Where the containerd provider keeps container state, stdio fifos and logs")
+	ContainerdRuntime     = flag.String("containerd-runtime", "io.containerd.runc.v2", "Runtime containerd runs the containerd provider's containers with")
+	ContainerdSnapshotter = flag.String("containerd-snapshotter", "overlayfs", "Snapshotter containerd unpacks the containerd provider's images with")
 )
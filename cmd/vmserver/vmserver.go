@@ -11,8 +11,10 @@ import (
 	"github.com/apporbit/infranetes/pkg/vmserver"
 
 	// Registered providers
+	_ "github.com/apporbit/infranetes/pkg/vmserver/containerd"
 	_ "github.com/apporbit/infranetes/pkg/vmserver/docker"
 	_ "github.com/apporbit/infranetes/pkg/vmserver/fake"
+	_ "github.com/apporbit/infranetes/pkg/vmserver/nspawn"
 	_ "github.com/apporbit/infranetes/pkg/vmserver/systemd"
 )
 
@@ -21,6 +23,15 @@ const (
 )
 
 func main() {
+	// vmserver runs itself as kube-proxy, so kube-proxy can be stopped without stopping vmserver
+	if len(os.Args) > 1 && os.Args[1] == vmserver.KubeProxyCommand {
+		if err := vmserver.RunKubeProxy(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "kube-proxy: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	flag.Parse()
 
 	if *flags.Version {
@@ -36,7 +47,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	server, err := vmserver.NewVMServer(flags.Cert, flags.Key, contProvider)
+	server, err := vmserver.NewVMServer(flags.Cert, flags.Key, *flags.ContProvider, contProvider, *flags.StateDir, *flags.KubeProxyPath)
 	if err != nil {
 		fmt.Println("Initialize infranetes vm server failed: ", err)
 		os.Exit(1)
@@ -0,0 +1,110 @@
+/* Capability handshake shared by infranetes and vmserver */
+
+package common
+
+import (
+	"fmt"
+
+	"github.com/blang/semver"
+)
+
+const (
+	// ProtocolVersion is the version of the infranetes <-> vmserver protocol spoken by this build
+	ProtocolVersion = "0.2.0"
+	// MinProtocolVersion is the oldest vmserver protocol infranetes will manage
+	MinProtocolVersion = "0.1.0"
+	// LegacyProtocolVersion is assumed for agents that predate the Capabilities rpc
+	LegacyProtocolVersion = "0.1.0"
+
+	FeatureLogs        = "logs"
+	FeatureExec        = "exec"
+	FeatureAttach      = "attach"
+	FeaturePortForward = "portforward"
+	FeatureExecSync    = "execsync"
+	FeatureProxy       = "proxy"
+	FeatureMetrics     = "metrics"
+	FeatureEvents      = "events"
+	// FeatureRegistryAuth is the CreateContainerWithAuth rpc, for images in private registries
+	FeatureRegistryAuth = "registryauth"
+	// FeatureProxyControl is kube-proxy's settings in StartProxy and the StopProxy and ProxyStatus rpcs
+	FeatureProxyControl = "proxycontrol"
+	// FeatureProxyKubeconfig is the UpdateProxyKubeconfig rpc, for rotating kube-proxy's credentials
+	FeatureProxyKubeconfig = "proxykubeconfig"
+	// FeatureNetworkPolicy is the StartNetworkPolicy and NetworkPolicyStatus rpcs, for enforcing NetworkPolicies
+	FeatureNetworkPolicy = "networkpolicy"
+	// FeatureOverlay is the SetupOverlay rpc, for putting the pod's ip on a tunnel to the infranetes node
+	FeatureOverlay = "overlay"
+	// FeatureCNI is the SetupCNI and TeardownCNI rpcs, for joining the cluster's pod network with CNI plugins
+	FeatureCNI = "cni"
+	// FeatureAddresses is the ListAddresses rpc, for choosing the pod's ip by the VM's interfaces
+	FeatureAddresses = "addresses"
+	// FeatureHostPorts is the SetPortMappings rpc, for sending the pod's host ports to its container ports
+	FeatureHostPorts = "hostports"
+
+	SubsystemContainerRuntime = "containerruntime"
+	SubsystemStreaming        = "streaming"
+	SubsystemProxy            = "proxy"
+	SubsystemMetrics          = "metrics"
+	SubsystemNetworkPolicy    = "networkpolicy"
+)
+
+// LegacyCapabilities describes an agent that doesn't implement the Capabilities rpc.  Those agents only ever
+// shipped with the docker container provider.
+func LegacyCapabilities() *CapabilitiesResponse {
+	return &CapabilitiesResponse{
+		ProtocolVersion:   LegacyProtocolVersion,
+		ContainerProvider: "docker",
+		Features:          []string{FeatureLogs, FeatureExec, FeatureAttach, FeaturePortForward, FeatureExecSync, FeatureProxy, FeatureMetrics},
+	}
+}
+
+// CheckCompatible returns an error if an agent speaking version can't be managed by this build
+func CheckCompatible(version string) error {
+	agent, err := semver.Parse(version)
+	if err != nil {
+		return fmt.Errorf("CheckCompatible: couldn't parse agent protocol version %q: %v", version, err)
+	}
+
+	local := semver.MustParse(ProtocolVersion)
+	min := semver.MustParse(MinProtocolVersion)
+
+	if agent.LT(min) {
+		return fmt.Errorf("CheckCompatible: agent protocol %v is older than the minimum supported %v", agent, min)
+	}
+
+	if agent.Major != local.Major {
+		return fmt.Errorf("CheckCompatible: agent protocol %v doesn't match major version of %v", agent, local)
+	}
+
+	return nil
+}
+
+func (c *CapabilitiesResponse) HasFeature(feature string) bool {
+	if c == nil {
+		return false
+	}
+
+	for _, f := range c.Features {
+		if f == feature {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SubsystemReady returns false if the named subsystem was reported and isn't ready.  Subsystems the agent
+// didn't report are considered ready, as older agents don't know about them.
+func (c *CapabilitiesResponse) SubsystemReady(name string) bool {
+	if c == nil {
+		return true
+	}
+
+	for _, s := range c.Subsystems {
+		if s.Name == name {
+			return s.Ready
+		}
+	}
+
+	return true
+}
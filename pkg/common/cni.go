@@ -0,0 +1,32 @@
+/* CNI network configs shared by infranetes and vmserver */
+
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/containernetworking/cni/libcni"
+)
+
+// ParseCNIConfig parses a CNI config list, or a single plugin's config as a list of one
+func ParseCNIConfig(data []byte) (*libcni.NetworkConfigList, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid CNI config: %v", err)
+	}
+
+	if _, ok := raw["plugins"]; ok {
+		return libcni.ConfListFromBytes(data)
+	}
+
+	conf, err := libcni.ConfFromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	if conf.Network.Type == "" {
+		return nil, fmt.Errorf("invalid CNI config: no plugin type")
+	}
+
+	return libcni.ConfListFromConf(conf)
+}
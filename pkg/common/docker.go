@@ -3,14 +3,22 @@
 package common
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/pkg/jsonmessage"
+	dockerclient "github.com/docker/engine-api/client"
 	dockertypes "github.com/docker/engine-api/types"
 	dockerfilters "github.com/docker/engine-api/types/filters"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
 
 	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
 )
@@ -221,3 +229,122 @@ func ToRuntimeAPIImage(image *dockertypes.Image) (*kubeapi.Image, error) {
 		Size_:       size,
 	}, nil
 }
+
+// EncodeRegistryAuth turns CRI credentials into the X-Registry-Auth docker expects on pulls
+func EncodeRegistryAuth(auth *kubeapi.AuthConfig) (string, error) {
+	if auth == nil {
+		return "", nil
+	}
+
+	data, err := json.Marshal(dockertypes.AuthConfig{
+		Username:      auth.Username,
+		Password:      auth.Password,
+		Auth:          auth.Auth,
+		ServerAddress: auth.ServerAddress,
+		IdentityToken: auth.IdentityToken,
+		RegistryToken: auth.RegistryToken,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.URLEncoding.EncodeToString(data), nil
+}
+
+// NeedsPull tells if image has to be pulled given whether docker already has it.  Images pinned to a digest never
+// change, so they are only pulled when missing.  Otherwise always is the caller's policy, and without it only
+// untagged and :latest images are pulled again, as kubelet does by default.
+func NeedsPull(image string, present bool, always bool) bool {
+	if !present {
+		return true
+	}
+
+	named, err := reference.ParseNamed(image)
+	if err != nil {
+		return true
+	}
+
+	if _, ok := named.(reference.Canonical); ok {
+		return false
+	}
+
+	if always {
+		return true
+	}
+
+	tagged, ok := named.(reference.NamedTagged)
+	return !ok || tagged.Tag() == "latest"
+}
+
+// ImageRef returns how to refer to an inspected image, the digest it was pulled by when docker knows it and its id
+// otherwise
+func ImageRef(image string, inspect *dockertypes.ImageInspect) string {
+	repo := image
+	if named, err := reference.ParseNamed(image); err == nil {
+		repo = named.Name()
+	}
+
+	for _, digest := range inspect.RepoDigests {
+		if strings.HasPrefix(digest, repo+"@") {
+			return digest
+		}
+	}
+
+	if len(inspect.RepoDigests) > 0 {
+		return inspect.RepoDigests[0]
+	}
+
+	return inspect.ID
+}
+
+// PullDockerImage pulls image with auth following NeedsPull, returning the image's ImageRef
+func PullDockerImage(client *dockerclient.Client, image string, auth *kubeapi.AuthConfig, always bool) (string, error) {
+	inspect, _, err := client.ImageInspectWithRaw(context.Background(), image, false)
+	present := err == nil
+	if err != nil && !dockerclient.IsErrImageNotFound(err) {
+		return "", fmt.Errorf("couldn't inspect %v: %v", image, err)
+	}
+
+	if !NeedsPull(image, present, always) {
+		glog.Infof("PullDockerImage: %v is already present", image)
+		return ImageRef(image, &inspect), nil
+	}
+
+	registryAuth, err := EncodeRegistryAuth(auth)
+	if err != nil {
+		return "", err
+	}
+
+	pullresp, err := client.ImagePull(context.Background(), image, dockertypes.ImagePullOptions{RegistryAuth: registryAuth})
+	if err != nil {
+		return "", fmt.Errorf("ImagePull Failed (%v)", err)
+	}
+	defer pullresp.Close()
+
+	// failures after the pull started, such as denied access, only show up in the progress stream
+	decoder := json.NewDecoder(pullresp)
+	for {
+		var msg jsonmessage.JSONMessage
+		err := decoder.Decode(&msg)
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("Pull Image failed: %v", err)
+		}
+		if msg.Error != nil {
+			return "", fmt.Errorf("Pull Image failed: %v", msg.Error.Message)
+		}
+		if msg.ErrorMessage != "" {
+			return "", fmt.Errorf("Pull Image failed: %v", msg.ErrorMessage)
+		}
+	}
+
+	inspect, _, err = client.ImageInspectWithRaw(context.Background(), image, false)
+	if err != nil {
+		return "", fmt.Errorf("couldn't inspect %v after pulling it: %v", image, err)
+	}
+
+	return ImageRef(image, &inspect), nil
+}
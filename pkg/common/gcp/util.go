@@ -13,13 +13,25 @@ import (
 	"golang.org/x/oauth2/google"
 	"golang.org/x/oauth2/jwt"
 
-	"github.com/apcera/libretto/virtualmachine/gcp"
 	googlecloud "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
 )
 
 const (
 	infranetesLabelKey   = "infranetes"
 	infranetesLabelValue = "true"
+
+	// RoutePrefix starts the names of the routes infranetes makes for pod ips
+	RoutePrefix = "infranetes-route-"
+	// HostPortPrefix starts the names of the firewall rules infranetes makes for pods' host ports
+	HostPortPrefix = "infranetes-hostport-"
+	// FirewallPrefix starts the names of the firewall rules infranetes makes for -firewall-policy
+	FirewallPrefix = "infranetes-fw-"
+	// StaticIPPrefix starts the names of the static ips infranetes reserves for pods
+	StaticIPPrefix = "infranetes-ip-"
+
+	// natName is the access config libretto gives VMs their public ips with
+	natName = "External NAT for Libretto"
 )
 
 var (
@@ -37,6 +49,9 @@ type GceConfig struct {
 	AuthFile    string
 	Network     string
 	Subnet      string
+	// DNSZone is the Cloud DNS managed zone pods' infranetes.dns.name records are kept in, if any.  Scope has to
+	// cover Cloud DNS for it.
+	DNSZone string
 }
 
 type account struct {
@@ -74,6 +89,8 @@ type GcpSvcWrapper struct {
 	Project string
 	Zone    string
 	Service *googlecloud.Service
+	// Client is the authorized client Service is made with, for the apis the vendored google api has no client for
+	Client *http.Client
 }
 
 func GetService(accountFile string, project string, zone string, scopes []string) (*GcpSvcWrapper, error) {
@@ -113,16 +130,73 @@ func GetService(accountFile string, project string, zone string, scopes []string
 		Project: project,
 		Zone:    zone,
 		Service: svc,
+		Client:  client,
 	}, nil
 }
 
-func (s *GcpSvcWrapper) AddRoute(name string, ip string) error {
+// RouteName is the name of the route infranetes makes for a pod ip
+func RouteName(ip string) string {
+	return RoutePrefix + strings.Replace(ip, ".", "-", -1)
+}
+
+// HostPortFirewallName is the name of the firewall rule opening the host ports of the pod on instance.  The
+// instance's own "infranetes-" is left out to stay within GCE's 63 characters.
+func HostPortFirewallName(instance string) string {
+	return HostPortPrefix + strings.TrimPrefix(instance, "infranetes-")
+}
+
+// PodFirewallPrefix starts the names of the -firewall-policy rules of the pod on instance
+func PodFirewallPrefix(instance string) string {
+	return FirewallPrefix + strings.TrimPrefix(instance, "infranetes-") + "-"
+}
+
+// StaticIPName is the name of the static ip infranetes reserves for the pod on instance
+func StaticIPName(instance string) string {
+	return StaticIPPrefix + strings.TrimPrefix(instance, "infranetes-")
+}
+
+// region is the region of the wrapper's zone, where the static ips its instances can have are
+func (s *GcpSvcWrapper) region() string {
+	return s.Zone[:strings.LastIndex(s.Zone, "-")]
+}
+
+func (s *GcpSvcWrapper) networkURL(network string) string {
+	if strings.Contains(network, "/") {
+		return network
+	}
+	return "projects/" + s.Project + "/global/networks/" + network
+}
+
+func (s *GcpSvcWrapper) instanceURL(instance string) string {
+	return "projects/" + s.Project + "/zones/" + s.Zone + "/instances/" + instance
+}
+
+// AddRoute routes destRange, a single ip if it has no prefix length, to instance.  A route of the same name going
+// elsewhere is replaced, as routes can't be changed in place.
+func (s *GcpSvcWrapper) AddRoute(name string, network string, instance string, destRange string) error {
+	if !strings.Contains(destRange, "/") {
+		destRange += "/32"
+	}
+
 	a := &googlecloud.Route{
 		Kind:            "compute#route",
 		Name:            name,
-		Network:         "projects/engineering-lab/global/networks/default",
-		DestRange:       ip,
-		NextHopInstance: "projects/engineering-lab/zones/us-central1-b/instances/" + name,
+		Network:         s.networkURL(network),
+		DestRange:       destRange,
+		NextHopInstance: s.instanceURL(instance),
+	}
+
+	existing, err := s.Service.Routes.Get(s.Project, name).Do()
+	switch {
+	case err == nil:
+		if existing.DestRange == destRange && strings.HasSuffix(existing.NextHopInstance, a.NextHopInstance) {
+			return nil
+		}
+		if err := s.DelRoute(name); err != nil {
+			return err
+		}
+	case !isNotFound(err):
+		return err
 	}
 
 	op, err := s.Service.Routes.Insert(s.Project, a).Do()
@@ -138,9 +212,13 @@ func (s *GcpSvcWrapper) AddRoute(name string, ip string) error {
 	return nil
 }
 
+// DelRoute removes a route, it isn't an error if it's gone already
 func (s *GcpSvcWrapper) DelRoute(name string) error {
 	op, err := s.Service.Routes.Delete(s.Project, name).Do()
 	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
 		return err
 	}
 
@@ -152,6 +230,349 @@ func (s *GcpSvcWrapper) DelRoute(name string) error {
 	return nil
 }
 
+// ListRoutes returns the project's routes whose names start with prefix
+func (s *GcpSvcWrapper) ListRoutes(prefix string) ([]*googlecloud.Route, error) {
+	routes := []*googlecloud.Route{}
+
+	nextPageToken := ""
+
+	for {
+		list, err := s.Service.Routes.List(s.Project).PageToken(nextPageToken).Do()
+		if err != nil {
+			return nil, fmt.Errorf("ListRoutes failed: %v", err)
+		}
+
+		for _, r := range list.Items {
+			if strings.HasPrefix(r.Name, prefix) {
+				routes = append(routes, r)
+			}
+		}
+
+		nextPageToken = list.NextPageToken
+
+		if nextPageToken == "" {
+			break
+		}
+	}
+
+	return routes, nil
+}
+
+// AddFirewall lets allowed in to the instances with targetTag from sourceRanges.  A rule of the same name is updated
+// in place, so what it already lets in isn't cut off while it changes.
+func (s *GcpSvcWrapper) AddFirewall(name string, network string, targetTag string, sourceRanges []string, allowed []*googlecloud.FirewallAllowed) error {
+	f := &googlecloud.Firewall{
+		Kind:         "compute#firewall",
+		Name:         name,
+		Description:  s.firewallDescription(),
+		Network:      s.networkURL(network),
+		Allowed:      allowed,
+		SourceRanges: sourceRanges,
+		TargetTags:   []string{targetTag},
+	}
+
+	var op *googlecloud.Operation
+	_, err := s.Service.Firewalls.Get(s.Project, name).Do()
+	switch {
+	case err == nil:
+		op, err = s.Service.Firewalls.Update(s.Project, name, f).Do()
+	case isNotFound(err):
+		op, err = s.Service.Firewalls.Insert(s.Project, f).Do()
+	}
+	if err != nil {
+		return err
+	}
+
+	err = s.waitForGlobalOperationReady(op.Name)
+	if err != nil {
+		return fmt.Errorf("AddFirewall failed: %v", err)
+	}
+
+	return nil
+}
+
+// DelFirewall removes a firewall rule, it isn't an error if it's gone already
+func (s *GcpSvcWrapper) DelFirewall(name string) error {
+	op, err := s.Service.Firewalls.Delete(s.Project, name).Do()
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	err = s.waitForGlobalOperationReady(op.Name)
+	if err != nil {
+		return fmt.Errorf("DelFirewall failed: %v", err)
+	}
+
+	return nil
+}
+
+// firewallDescription marks the rules of the zone's instances, as the rules are global but the instances they target
+// by tag are only unique within their zone
+func (s *GcpSvcWrapper) firewallDescription() string {
+	return "infranetes zone " + s.Zone
+}
+
+// FirewallInZone reports if a rule may target one of the zone's instances, which rules made before they were marked
+// with their zone might
+func (s *GcpSvcWrapper) FirewallInZone(f *googlecloud.Firewall) bool {
+	return f.Description == "" || f.Description == s.firewallDescription()
+}
+
+// ListFirewalls returns the project's firewall rules whose names start with prefix
+func (s *GcpSvcWrapper) ListFirewalls(prefix string) ([]*googlecloud.Firewall, error) {
+	firewalls := []*googlecloud.Firewall{}
+
+	nextPageToken := ""
+
+	for {
+		list, err := s.Service.Firewalls.List(s.Project).PageToken(nextPageToken).Do()
+		if err != nil {
+			return nil, fmt.Errorf("ListFirewalls failed: %v", err)
+		}
+
+		for _, f := range list.Items {
+			if strings.HasPrefix(f.Name, prefix) {
+				firewalls = append(firewalls, f)
+			}
+		}
+
+		nextPageToken = list.NextPageToken
+
+		if nextPageToken == "" {
+			break
+		}
+	}
+
+	return firewalls, nil
+}
+
+// AddToInstanceGroup adds instance to the zone's unmanaged instance group group
+func (s *GcpSvcWrapper) AddToInstanceGroup(group string, instance string) error {
+	req := &googlecloud.InstanceGroupsAddInstancesRequest{
+		Instances: []*googlecloud.InstanceReference{{Instance: s.instanceURL(instance)}},
+	}
+
+	op, err := s.Service.InstanceGroups.AddInstances(s.Project, s.Zone, group, req).Do()
+	if err != nil {
+		return err
+	}
+
+	err = s.waitForZoneOperationReady(op.Name)
+	if err != nil {
+		return fmt.Errorf("AddToInstanceGroup failed: %v", err)
+	}
+
+	return nil
+}
+
+// RemoveFromInstanceGroup removes instance from group, waiting up to timeout for the backend services it's in to
+// drain its connections
+func (s *GcpSvcWrapper) RemoveFromInstanceGroup(group string, instance string, timeout time.Duration) error {
+	req := &googlecloud.InstanceGroupsRemoveInstancesRequest{
+		Instances: []*googlecloud.InstanceReference{{Instance: s.instanceURL(instance)}},
+	}
+
+	op, err := s.Service.InstanceGroups.RemoveInstances(s.Project, s.Zone, group, req).Do()
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	if timeout < time.Second {
+		return nil
+	}
+
+	err = waitForOperation(int(timeout.Seconds()), func() (*googlecloud.Operation, error) {
+		return s.Service.ZoneOperations.Get(s.Project, s.Zone, op.Name).Do()
+	})
+	if err != nil {
+		return fmt.Errorf("RemoveFromInstanceGroup failed: %v", err)
+	}
+
+	return nil
+}
+
+// BackendServices returns the names of the backend services the zone's instance group group is a backend of
+func (s *GcpSvcWrapper) BackendServices(group string) ([]string, error) {
+	suffix := "/zones/" + s.Zone + "/instanceGroups/" + group
+
+	names := []string{}
+
+	nextPageToken := ""
+
+	for {
+		list, err := s.Service.BackendServices.List(s.Project).PageToken(nextPageToken).Do()
+		if err != nil {
+			return nil, fmt.Errorf("BackendServices failed: %v", err)
+		}
+
+		for _, service := range list.Items {
+			for _, backend := range service.Backends {
+				if strings.HasSuffix(backend.Group, suffix) {
+					names = append(names, service.Name)
+					break
+				}
+			}
+		}
+
+		nextPageToken = list.NextPageToken
+
+		if nextPageToken == "" {
+			break
+		}
+	}
+
+	return names, nil
+}
+
+// InstanceHealth returns the health backendService's checks give instance in group, "" if it isn't checked
+func (s *GcpSvcWrapper) InstanceHealth(backendService string, group string, instance string) (string, error) {
+	ref := &googlecloud.ResourceGroupReference{
+		Group: "projects/" + s.Project + "/zones/" + s.Zone + "/instanceGroups/" + group,
+	}
+
+	health, err := s.Service.BackendServices.GetHealth(s.Project, backendService, ref).Do()
+	if err != nil {
+		return "", fmt.Errorf("InstanceHealth failed: %v", err)
+	}
+
+	suffix := "/" + s.instanceURL(instance)
+	for _, status := range health.HealthStatus {
+		if strings.HasSuffix(status.Instance, suffix) {
+			return status.HealthState, nil
+		}
+	}
+
+	return "", nil
+}
+
+// ReserveAddress reserves a static ip named name in the zone's region
+func (s *GcpSvcWrapper) ReserveAddress(name string, description string) error {
+	address := &googlecloud.Address{
+		Name:        name,
+		Description: description,
+	}
+
+	op, err := s.Service.Addresses.Insert(s.Project, s.region(), address).Do()
+	if err != nil {
+		return err
+	}
+
+	err = s.waitForRegionOperationReady(op.Name)
+	if err != nil {
+		return fmt.Errorf("ReserveAddress failed: %v", err)
+	}
+
+	return nil
+}
+
+// GetAddress returns the static ip named name in the zone's region
+func (s *GcpSvcWrapper) GetAddress(name string) (*googlecloud.Address, error) {
+	address, err := s.Service.Addresses.Get(s.Project, s.region(), name).Do()
+	if err != nil {
+		return nil, fmt.Errorf("GetAddress failed: %v", err)
+	}
+
+	return address, nil
+}
+
+// ReleaseAddress releases a static ip, it isn't an error if it's gone already
+func (s *GcpSvcWrapper) ReleaseAddress(name string) error {
+	op, err := s.Service.Addresses.Delete(s.Project, s.region(), name).Do()
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	err = s.waitForRegionOperationReady(op.Name)
+	if err != nil {
+		return fmt.Errorf("ReleaseAddress failed: %v", err)
+	}
+
+	return nil
+}
+
+// ListAddresses returns the static ips of the zone's region whose names start with prefix
+func (s *GcpSvcWrapper) ListAddresses(prefix string) ([]*googlecloud.Address, error) {
+	addresses := []*googlecloud.Address{}
+
+	nextPageToken := ""
+
+	for {
+		list, err := s.Service.Addresses.List(s.Project, s.region()).PageToken(nextPageToken).Do()
+		if err != nil {
+			return nil, fmt.Errorf("ListAddresses failed: %v", err)
+		}
+
+		for _, a := range list.Items {
+			if strings.HasPrefix(a.Name, prefix) {
+				addresses = append(addresses, a)
+			}
+		}
+
+		nextPageToken = list.NextPageToken
+
+		if nextPageToken == "" {
+			break
+		}
+	}
+
+	return addresses, nil
+}
+
+// SetNatIP replaces the public ip of instance with natIP, or with an ephemeral one if natIP is empty.  An instance
+// has one access config, so its old one is removed first.
+func (s *GcpSvcWrapper) SetNatIP(instance string, natIP string) error {
+	i, err := s.Service.Instances.Get(s.Project, s.Zone, instance).Do()
+	if err != nil {
+		return fmt.Errorf("SetNatIP: couldn't get instance %v: %v", instance, err)
+	}
+	if len(i.NetworkInterfaces) == 0 {
+		return fmt.Errorf("SetNatIP: %v has no network interface", instance)
+	}
+	nic := i.NetworkInterfaces[0]
+
+	for _, config := range nic.AccessConfigs {
+		op, err := s.Service.Instances.DeleteAccessConfig(s.Project, s.Zone, instance, config.Name, nic.Name).Do()
+		if err != nil {
+			return fmt.Errorf("SetNatIP: couldn't remove %v's public ip: %v", instance, err)
+		}
+		if err := s.waitForZoneOperationReady(op.Name); err != nil {
+			return fmt.Errorf("SetNatIP: couldn't remove %v's public ip: %v", instance, err)
+		}
+	}
+
+	config := &googlecloud.AccessConfig{
+		Name:  natName,
+		Type:  "ONE_TO_ONE_NAT",
+		NatIP: natIP,
+	}
+
+	op, err := s.Service.Instances.AddAccessConfig(s.Project, s.Zone, instance, nic.Name, config).Do()
+	if err != nil {
+		return fmt.Errorf("SetNatIP: couldn't give %v a public ip: %v", instance, err)
+	}
+
+	err = s.waitForZoneOperationReady(op.Name)
+	if err != nil {
+		return fmt.Errorf("SetNatIP: couldn't give %v a public ip: %v", instance, err)
+	}
+
+	return nil
+}
+
+func isNotFound(err error) bool {
+	apiErr, ok := err.(*googleapi.Error)
+	return ok && apiErr.Code == http.StatusNotFound
+}
+
 // waitForOperationReady waits for the regional operation to finish.
 func (s *GcpSvcWrapper) waitForZoneOperationReady(operation string) error {
 	return waitForOperation(OperationTimeout, func() (*googlecloud.Operation, error) {
@@ -159,6 +580,13 @@ func (s *GcpSvcWrapper) waitForZoneOperationReady(operation string) error {
 	})
 }
 
+// waitForRegionOperationReady waits for the operation in the zone's region to finish.
+func (s *GcpSvcWrapper) waitForRegionOperationReady(operation string) error {
+	return waitForOperation(OperationTimeout, func() (*googlecloud.Operation, error) {
+		return s.Service.RegionOperations.Get(s.Project, s.region(), operation).Do()
+	})
+}
+
 // waitForOperationReady waits for the global operation to finish.
 func (s *GcpSvcWrapper) waitForGlobalOperationReady(operation string) error {
 	return waitForOperation(OperationTimeout, func() (*googlecloud.Operation, error) {
@@ -189,45 +617,64 @@ func waitForOperation(timeout int, funcOperation func() (*googlecloud.Operation,
 	return fmt.Errorf("operation timeout, operations status: %v", op.Status)
 }
 
-func addRoute(vm *gcp.VM, podIp string) error {
-	s, err := GetService(vm.AccountFile, vm.Project, vm.Zone, vm.Scopes)
+func (s *GcpSvcWrapper) TagNewInstance(name string) error {
+	i, err := s.Service.Instances.Get(s.Project, s.Zone, name).Do()
 	if err != nil {
-		return fmt.Errorf("Couldn't make service to access google cloud")
+		return fmt.Errorf("TagNewInstance: Couldn't get instance: %v: %v", name, err)
 	}
 
-	return s.AddRoute(vm.Name, podIp)
-
-}
+	req := &googlecloud.InstancesSetLabelsRequest{
+		LabelFingerprint: i.LabelFingerprint,
+		Labels:           map[string]string{infranetesLabelKey: infranetesLabelValue},
+	}
 
-func delRoute(vm *gcp.VM) error {
-	s, err := GetService(vm.AccountFile, vm.Project, vm.Zone, vm.Scopes)
+	op, err := s.Service.Instances.SetLabels(s.Project, s.Zone, name, req).Do()
+	err = s.waitForGlobalOperationReady(op.Name)
 	if err != nil {
-		return fmt.Errorf("Couldn't make service to access google cloud")
+		return fmt.Errorf("TagNewInstance failed: %v", err)
 	}
 
-	return s.DelRoute(vm.Name)
+	return nil
 }
 
-func (s *GcpSvcWrapper) TagNewInstance(name string) error {
+// LabelInstance sets an instance's label key to value, keeping its other labels
+func (s *GcpSvcWrapper) LabelInstance(name string, key string, value string) error {
 	i, err := s.Service.Instances.Get(s.Project, s.Zone, name).Do()
 	if err != nil {
-		return fmt.Errorf("TagNewInstance: Couldn't get instance: %v: %v", name, err)
+		return fmt.Errorf("LabelInstance: couldn't get instance %v: %v", name, err)
 	}
 
-	req := &googlecloud.InstancesSetLabelsRequest{
-		LabelFingerprint: i.LabelFingerprint,
-		Labels:           map[string]string{infranetesLabelKey: infranetesLabelValue},
+	labels := map[string]string{key: value}
+	for k, v := range i.Labels {
+		if k != key {
+			labels[k] = v
+		}
 	}
 
-	op, err := s.Service.Instances.SetLabels(s.Project, s.Zone, name, req).Do()
-	err = s.waitForGlobalOperationReady(op.Name)
+	op, err := s.Service.Instances.SetLabels(s.Project, s.Zone, name, &googlecloud.InstancesSetLabelsRequest{
+		LabelFingerprint: i.LabelFingerprint,
+		Labels:           labels,
+	}).Do()
 	if err != nil {
-		return fmt.Errorf("TagNewInstance failed: %v", err)
+		return fmt.Errorf("LabelInstance: couldn't label %v: %v", name, err)
+	}
+	if err := s.waitForZoneOperationReady(op.Name); err != nil {
+		return fmt.Errorf("LabelInstance: couldn't label %v: %v", name, err)
 	}
 
 	return nil
 }
 
+// AddressLabel is ip as a label value, which can't have dots or colons
+func AddressLabel(ip string) string {
+	return strings.NewReplacer(".", "-", ":", "_").Replace(ip)
+}
+
+// LabelAddress is the ip AddressLabel made label into
+func LabelAddress(label string) string {
+	return strings.NewReplacer("-", ".", "_", ":").Replace(label)
+}
+
 func (s *GcpSvcWrapper) ListInstances() ([]*googlecloud.Instance, error) {
 	images := []*googlecloud.Instance{}
 
@@ -255,6 +702,24 @@ func (s *GcpSvcWrapper) ListInstances() ([]*googlecloud.Instance, error) {
 	return images, nil
 }
 
+// InstanceGone reports if the zone's instance is known not to exist, rather than couldn't be looked up
+func (s *GcpSvcWrapper) InstanceGone(instance string) (bool, error) {
+	_, err := s.Service.Instances.Get(s.Project, s.Zone, instance).Do()
+	if err != nil {
+		if isNotFound(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf("InstanceGone: couldn't get instance %v: %v", instance, err)
+	}
+
+	return false, nil
+}
+
+// InZone reports if an instance url is of one of the zone's instances
+func (s *GcpSvcWrapper) InZone(instanceURL string) bool {
+	return strings.Contains(instanceURL, "/zones/"+s.Zone+"/instances/")
+}
+
 func (s *GcpSvcWrapper) CreateDisk(vol string, size int64) error {
 	d := &googlecloud.Disk{
 		Name:   vol,
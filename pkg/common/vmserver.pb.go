@@ -12,10 +12,34 @@ It has these top-level messages:
 	GetMetricsResponse
 	LogsRequest
 	LogLine
+	ReopenContainerLogRequest
+	ReopenContainerLogResponse
 	File
 	UploadResponse
 	StartProxyRequest
 	StartProxyResponse
+	UpdateProxyKubeconfigRequest
+	UpdateProxyKubeconfigResponse
+	StopProxyRequest
+	StopProxyResponse
+	ProxyStatusRequest
+	ProxyStatusResponse
+	StartNetworkPolicyRequest
+	StartNetworkPolicyResponse
+	NetworkPolicyStatusRequest
+	NetworkPolicyStatusResponse
+	SetupOverlayRequest
+	SetupOverlayResponse
+	SetupCNIRequest
+	SetupCNIResponse
+	TeardownCNIRequest
+	TeardownCNIResponse
+	ListAddressesRequest
+	InterfaceAddress
+	ListAddressesResponse
+	PortMapping
+	SetPortMappingsRequest
+	SetPortMappingsResponse
 	RunCmdRequest
 	RunCmdResponse
 	SetIPRequest
@@ -36,10 +60,17 @@ It has these top-level messages:
 	SetHostnameResponse
 	AddRouteRequest
 	AddRouteResponse
+	CapabilitiesRequest
+	SubsystemStatus
+	CapabilitiesResponse
+	WatchContainerEventsRequest
+	ContainerEvent
 	AddMountRequest
 	AddMountResponse
 	DelMountRequest
 	DelMountResponse
+	CreateContainerWithAuthRequest
+	CreateContainerWithAuthResponse
 */
 package common
 
@@ -63,6 +94,36 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+type ContainerEventType int32
+
+const (
+	ContainerEventType_CREATED ContainerEventType = 0
+	ContainerEventType_STARTED ContainerEventType = 1
+	ContainerEventType_STOPPED ContainerEventType = 2
+	ContainerEventType_REMOVED ContainerEventType = 3
+	ContainerEventType_SYNCED  ContainerEventType = 4
+)
+
+var ContainerEventType_name = map[int32]string{
+	0: "CREATED",
+	1: "STARTED",
+	2: "STOPPED",
+	3: "REMOVED",
+	4: "SYNCED",
+}
+var ContainerEventType_value = map[string]int32{
+	"CREATED": 0,
+	"STARTED": 1,
+	"STOPPED": 2,
+	"REMOVED": 3,
+	"SYNCED":  4,
+}
+
+func (x ContainerEventType) String() string {
+	return proto.EnumName(ContainerEventType_name, int32(x))
+}
+func (ContainerEventType) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
 type GetMetricsRequest struct {
 	Count int32 `protobuf:"varint,1,opt,name=count" json:"count,omitempty"`
 }
@@ -97,6 +158,7 @@ func (m *GetMetricsResponse) GetJsonMetricResponses() [][]byte {
 
 type LogsRequest struct {
 	ContainerID string `protobuf:"bytes,1,opt,name=containerID" json:"containerID,omitempty"`
+	Offset      int64  `protobuf:"varint,2,opt,name=offset" json:"offset,omitempty"`
 }
 
 func (m *LogsRequest) Reset()                    { *m = LogsRequest{} }
@@ -111,8 +173,19 @@ func (m *LogsRequest) GetContainerID() string {
 	return ""
 }
 
+func (m *LogsRequest) GetOffset() int64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
 type LogLine struct {
-	LogLine string `protobuf:"bytes,1,opt,name=logLine" json:"logLine,omitempty"`
+	LogLine   string `protobuf:"bytes,1,opt,name=logLine" json:"logLine,omitempty"`
+	Timestamp int64  `protobuf:"varint,2,opt,name=timestamp" json:"timestamp,omitempty"`
+	Stream    string `protobuf:"bytes,3,opt,name=stream" json:"stream,omitempty"`
+	Partial   bool   `protobuf:"varint,4,opt,name=partial" json:"partial,omitempty"`
+	Offset    int64  `protobuf:"varint,5,opt,name=offset" json:"offset,omitempty"`
 }
 
 func (m *LogLine) Reset()                    { *m = LogLine{} }
@@ -127,77 +200,665 @@ func (m *LogLine) GetLogLine() string {
 	return ""
 }
 
-type File struct {
-	Size int64  `protobuf:"varint,1,opt,name=size" json:"size,omitempty"`
-	Data []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+func (m *LogLine) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func (m *LogLine) GetStream() string {
+	if m != nil {
+		return m.Stream
+	}
+	return ""
+}
+
+func (m *LogLine) GetPartial() bool {
+	if m != nil {
+		return m.Partial
+	}
+	return false
+}
+
+func (m *LogLine) GetOffset() int64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+type ReopenContainerLogRequest struct {
+	ContainerID string `protobuf:"bytes,1,opt,name=containerID" json:"containerID,omitempty"`
+}
+
+func (m *ReopenContainerLogRequest) Reset()                    { *m = ReopenContainerLogRequest{} }
+func (m *ReopenContainerLogRequest) String() string            { return proto.CompactTextString(m) }
+func (*ReopenContainerLogRequest) ProtoMessage()               {}
+func (*ReopenContainerLogRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *ReopenContainerLogRequest) GetContainerID() string {
+	if m != nil {
+		return m.ContainerID
+	}
+	return ""
+}
+
+type ReopenContainerLogResponse struct {
+}
+
+func (m *ReopenContainerLogResponse) Reset()                    { *m = ReopenContainerLogResponse{} }
+func (m *ReopenContainerLogResponse) String() string            { return proto.CompactTextString(m) }
+func (*ReopenContainerLogResponse) ProtoMessage()               {}
+func (*ReopenContainerLogResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+type File struct {
+	Size int64  `protobuf:"varint,1,opt,name=size" json:"size,omitempty"`
+	Data []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (m *File) Reset()                    { *m = File{} }
+func (m *File) String() string            { return proto.CompactTextString(m) }
+func (*File) ProtoMessage()               {}
+func (*File) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *File) GetSize() int64 {
+	if m != nil {
+		return m.Size
+	}
+	return 0
+}
+
+func (m *File) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+type UploadResponse struct {
+}
+
+func (m *UploadResponse) Reset()                    { *m = UploadResponse{} }
+func (m *UploadResponse) String() string            { return proto.CompactTextString(m) }
+func (*UploadResponse) ProtoMessage()               {}
+func (*UploadResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+type StartProxyRequest struct {
+	Ip                             string `protobuf:"bytes,1,opt,name=ip" json:"ip,omitempty"`
+	ClusterCidr                    string `protobuf:"bytes,2,opt,name=clusterCidr" json:"clusterCidr,omitempty"`
+	Kubeconfig                     []byte `protobuf:"bytes,3,opt,name=kubeconfig,proto3" json:"kubeconfig,omitempty"`
+	Mode                           string `protobuf:"bytes,4,opt,name=mode" json:"mode,omitempty"`
+	SyncPeriod                     string `protobuf:"bytes,5,opt,name=syncPeriod" json:"syncPeriod,omitempty"`
+	MinSyncPeriod                  string `protobuf:"bytes,6,opt,name=minSyncPeriod" json:"minSyncPeriod,omitempty"`
+	MasqueradeAll                  bool   `protobuf:"varint,7,opt,name=masqueradeAll" json:"masqueradeAll,omitempty"`
+	ConntrackMaxPerCore            int32  `protobuf:"varint,8,opt,name=conntrackMaxPerCore" json:"conntrackMaxPerCore,omitempty"`
+	ConntrackMin                   int32  `protobuf:"varint,9,opt,name=conntrackMin" json:"conntrackMin,omitempty"`
+	ConntrackTcpEstablishedTimeout string `protobuf:"bytes,10,opt,name=conntrackTcpEstablishedTimeout" json:"conntrackTcpEstablishedTimeout,omitempty"`
+	ConntrackTcpCloseWaitTimeout   string `protobuf:"bytes,11,opt,name=conntrackTcpCloseWaitTimeout" json:"conntrackTcpCloseWaitTimeout,omitempty"`
+	HealthzBindAddress             string `protobuf:"bytes,12,opt,name=healthzBindAddress" json:"healthzBindAddress,omitempty"`
+}
+
+func (m *StartProxyRequest) Reset()                    { *m = StartProxyRequest{} }
+func (m *StartProxyRequest) String() string            { return proto.CompactTextString(m) }
+func (*StartProxyRequest) ProtoMessage()               {}
+func (*StartProxyRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *StartProxyRequest) GetIp() string {
+	if m != nil {
+		return m.Ip
+	}
+	return ""
+}
+
+func (m *StartProxyRequest) GetClusterCidr() string {
+	if m != nil {
+		return m.ClusterCidr
+	}
+	return ""
+}
+
+func (m *StartProxyRequest) GetKubeconfig() []byte {
+	if m != nil {
+		return m.Kubeconfig
+	}
+	return nil
+}
+
+func (m *StartProxyRequest) GetMode() string {
+	if m != nil {
+		return m.Mode
+	}
+	return ""
+}
+
+func (m *StartProxyRequest) GetSyncPeriod() string {
+	if m != nil {
+		return m.SyncPeriod
+	}
+	return ""
+}
+
+func (m *StartProxyRequest) GetMinSyncPeriod() string {
+	if m != nil {
+		return m.MinSyncPeriod
+	}
+	return ""
+}
+
+func (m *StartProxyRequest) GetMasqueradeAll() bool {
+	if m != nil {
+		return m.MasqueradeAll
+	}
+	return false
+}
+
+func (m *StartProxyRequest) GetConntrackMaxPerCore() int32 {
+	if m != nil {
+		return m.ConntrackMaxPerCore
+	}
+	return 0
+}
+
+func (m *StartProxyRequest) GetConntrackMin() int32 {
+	if m != nil {
+		return m.ConntrackMin
+	}
+	return 0
+}
+
+func (m *StartProxyRequest) GetConntrackTcpEstablishedTimeout() string {
+	if m != nil {
+		return m.ConntrackTcpEstablishedTimeout
+	}
+	return ""
+}
+
+func (m *StartProxyRequest) GetConntrackTcpCloseWaitTimeout() string {
+	if m != nil {
+		return m.ConntrackTcpCloseWaitTimeout
+	}
+	return ""
+}
+
+func (m *StartProxyRequest) GetHealthzBindAddress() string {
+	if m != nil {
+		return m.HealthzBindAddress
+	}
+	return ""
+}
+
+type StartProxyResponse struct {
+}
+
+func (m *StartProxyResponse) Reset()                    { *m = StartProxyResponse{} }
+func (m *StartProxyResponse) String() string            { return proto.CompactTextString(m) }
+func (*StartProxyResponse) ProtoMessage()               {}
+func (*StartProxyResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+type UpdateProxyKubeconfigRequest struct {
+	Kubeconfig []byte `protobuf:"bytes,1,opt,name=kubeconfig,proto3" json:"kubeconfig,omitempty"`
+}
+
+func (m *UpdateProxyKubeconfigRequest) Reset()                    { *m = UpdateProxyKubeconfigRequest{} }
+func (m *UpdateProxyKubeconfigRequest) String() string            { return proto.CompactTextString(m) }
+func (*UpdateProxyKubeconfigRequest) ProtoMessage()               {}
+func (*UpdateProxyKubeconfigRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *UpdateProxyKubeconfigRequest) GetKubeconfig() []byte {
+	if m != nil {
+		return m.Kubeconfig
+	}
+	return nil
+}
+
+type UpdateProxyKubeconfigResponse struct {
+}
+
+func (m *UpdateProxyKubeconfigResponse) Reset()                    { *m = UpdateProxyKubeconfigResponse{} }
+func (m *UpdateProxyKubeconfigResponse) String() string            { return proto.CompactTextString(m) }
+func (*UpdateProxyKubeconfigResponse) ProtoMessage()               {}
+func (*UpdateProxyKubeconfigResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+type StopProxyRequest struct {
+}
+
+func (m *StopProxyRequest) Reset()                    { *m = StopProxyRequest{} }
+func (m *StopProxyRequest) String() string            { return proto.CompactTextString(m) }
+func (*StopProxyRequest) ProtoMessage()               {}
+func (*StopProxyRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+type StopProxyResponse struct {
+}
+
+func (m *StopProxyResponse) Reset()                    { *m = StopProxyResponse{} }
+func (m *StopProxyResponse) String() string            { return proto.CompactTextString(m) }
+func (*StopProxyResponse) ProtoMessage()               {}
+func (*StopProxyResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+type ProxyStatusRequest struct {
+}
+
+func (m *ProxyStatusRequest) Reset()                    { *m = ProxyStatusRequest{} }
+func (m *ProxyStatusRequest) String() string            { return proto.CompactTextString(m) }
+func (*ProxyStatusRequest) ProtoMessage()               {}
+func (*ProxyStatusRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+type ProxyStatusResponse struct {
+	Running  bool   `protobuf:"varint,1,opt,name=running" json:"running,omitempty"`
+	Healthy  bool   `protobuf:"varint,2,opt,name=healthy" json:"healthy,omitempty"`
+	Mode     string `protobuf:"bytes,3,opt,name=mode" json:"mode,omitempty"`
+	Message  string `protobuf:"bytes,4,opt,name=message" json:"message,omitempty"`
+	Restarts int32  `protobuf:"varint,5,opt,name=restarts" json:"restarts,omitempty"`
+}
+
+func (m *ProxyStatusResponse) Reset()                    { *m = ProxyStatusResponse{} }
+func (m *ProxyStatusResponse) String() string            { return proto.CompactTextString(m) }
+func (*ProxyStatusResponse) ProtoMessage()               {}
+func (*ProxyStatusResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *ProxyStatusResponse) GetRunning() bool {
+	if m != nil {
+		return m.Running
+	}
+	return false
+}
+
+func (m *ProxyStatusResponse) GetHealthy() bool {
+	if m != nil {
+		return m.Healthy
+	}
+	return false
+}
+
+func (m *ProxyStatusResponse) GetMode() string {
+	if m != nil {
+		return m.Mode
+	}
+	return ""
+}
+
+func (m *ProxyStatusResponse) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *ProxyStatusResponse) GetRestarts() int32 {
+	if m != nil {
+		return m.Restarts
+	}
+	return 0
+}
+
+type StartNetworkPolicyRequest struct {
+	Kubeconfig []byte `protobuf:"bytes,1,opt,name=kubeconfig,proto3" json:"kubeconfig,omitempty"`
+	SyncPeriod string `protobuf:"bytes,2,opt,name=syncPeriod" json:"syncPeriod,omitempty"`
+}
+
+func (m *StartNetworkPolicyRequest) Reset()                    { *m = StartNetworkPolicyRequest{} }
+func (m *StartNetworkPolicyRequest) String() string            { return proto.CompactTextString(m) }
+func (*StartNetworkPolicyRequest) ProtoMessage()               {}
+func (*StartNetworkPolicyRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+func (m *StartNetworkPolicyRequest) GetKubeconfig() []byte {
+	if m != nil {
+		return m.Kubeconfig
+	}
+	return nil
+}
+
+func (m *StartNetworkPolicyRequest) GetSyncPeriod() string {
+	if m != nil {
+		return m.SyncPeriod
+	}
+	return ""
+}
+
+type StartNetworkPolicyResponse struct {
+}
+
+func (m *StartNetworkPolicyResponse) Reset()                    { *m = StartNetworkPolicyResponse{} }
+func (m *StartNetworkPolicyResponse) String() string            { return proto.CompactTextString(m) }
+func (*StartNetworkPolicyResponse) ProtoMessage()               {}
+func (*StartNetworkPolicyResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+type NetworkPolicyStatusRequest struct {
+}
+
+func (m *NetworkPolicyStatusRequest) Reset()                    { *m = NetworkPolicyStatusRequest{} }
+func (m *NetworkPolicyStatusRequest) String() string            { return proto.CompactTextString(m) }
+func (*NetworkPolicyStatusRequest) ProtoMessage()               {}
+func (*NetworkPolicyStatusRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+type NetworkPolicyStatusResponse struct {
+	Running         bool     `protobuf:"varint,1,opt,name=running" json:"running,omitempty"`
+	IngressIsolated bool     `protobuf:"varint,2,opt,name=ingressIsolated" json:"ingressIsolated,omitempty"`
+	EgressIsolated  bool     `protobuf:"varint,3,opt,name=egressIsolated" json:"egressIsolated,omitempty"`
+	Policies        []string `protobuf:"bytes,4,rep,name=policies" json:"policies,omitempty"`
+	Message         string   `protobuf:"bytes,5,opt,name=message" json:"message,omitempty"`
+	LastSync        int64    `protobuf:"varint,6,opt,name=lastSync" json:"lastSync,omitempty"`
+}
+
+func (m *NetworkPolicyStatusResponse) Reset()                    { *m = NetworkPolicyStatusResponse{} }
+func (m *NetworkPolicyStatusResponse) String() string            { return proto.CompactTextString(m) }
+func (*NetworkPolicyStatusResponse) ProtoMessage()               {}
+func (*NetworkPolicyStatusResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+
+func (m *NetworkPolicyStatusResponse) GetRunning() bool {
+	if m != nil {
+		return m.Running
+	}
+	return false
+}
+
+func (m *NetworkPolicyStatusResponse) GetIngressIsolated() bool {
+	if m != nil {
+		return m.IngressIsolated
+	}
+	return false
+}
+
+func (m *NetworkPolicyStatusResponse) GetEgressIsolated() bool {
+	if m != nil {
+		return m.EgressIsolated
+	}
+	return false
+}
+
+func (m *NetworkPolicyStatusResponse) GetPolicies() []string {
+	if m != nil {
+		return m.Policies
+	}
+	return nil
+}
+
+func (m *NetworkPolicyStatusResponse) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *NetworkPolicyStatusResponse) GetLastSync() int64 {
+	if m != nil {
+		return m.LastSync
+	}
+	return 0
+}
+
+type SetupOverlayRequest struct {
+	Mode          string   `protobuf:"bytes,1,opt,name=mode" json:"mode,omitempty"`
+	PodIp         string   `protobuf:"bytes,2,opt,name=podIp" json:"podIp,omitempty"`
+	Gateway       string   `protobuf:"bytes,3,opt,name=gateway" json:"gateway,omitempty"`
+	NodeEndpoint  string   `protobuf:"bytes,4,opt,name=nodeEndpoint" json:"nodeEndpoint,omitempty"`
+	NodePublicKey string   `protobuf:"bytes,5,opt,name=nodePublicKey" json:"nodePublicKey,omitempty"`
+	Port          int32    `protobuf:"varint,6,opt,name=port" json:"port,omitempty"`
+	Vni           int32    `protobuf:"varint,7,opt,name=vni" json:"vni,omitempty"`
+	Mtu           int32    `protobuf:"varint,8,opt,name=mtu" json:"mtu,omitempty"`
+	Routes        []string `protobuf:"bytes,9,rep,name=routes" json:"routes,omitempty"`
+}
+
+func (m *SetupOverlayRequest) Reset()                    { *m = SetupOverlayRequest{} }
+func (m *SetupOverlayRequest) String() string            { return proto.CompactTextString(m) }
+func (*SetupOverlayRequest) ProtoMessage()               {}
+func (*SetupOverlayRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+
+func (m *SetupOverlayRequest) GetMode() string {
+	if m != nil {
+		return m.Mode
+	}
+	return ""
+}
+
+func (m *SetupOverlayRequest) GetPodIp() string {
+	if m != nil {
+		return m.PodIp
+	}
+	return ""
+}
+
+func (m *SetupOverlayRequest) GetGateway() string {
+	if m != nil {
+		return m.Gateway
+	}
+	return ""
+}
+
+func (m *SetupOverlayRequest) GetNodeEndpoint() string {
+	if m != nil {
+		return m.NodeEndpoint
+	}
+	return ""
+}
+
+func (m *SetupOverlayRequest) GetNodePublicKey() string {
+	if m != nil {
+		return m.NodePublicKey
+	}
+	return ""
+}
+
+func (m *SetupOverlayRequest) GetPort() int32 {
+	if m != nil {
+		return m.Port
+	}
+	return 0
+}
+
+func (m *SetupOverlayRequest) GetVni() int32 {
+	if m != nil {
+		return m.Vni
+	}
+	return 0
+}
+
+func (m *SetupOverlayRequest) GetMtu() int32 {
+	if m != nil {
+		return m.Mtu
+	}
+	return 0
+}
+
+func (m *SetupOverlayRequest) GetRoutes() []string {
+	if m != nil {
+		return m.Routes
+	}
+	return nil
+}
+
+type SetupOverlayResponse struct {
+	PublicKey string `protobuf:"bytes,1,opt,name=publicKey" json:"publicKey,omitempty"`
+}
+
+func (m *SetupOverlayResponse) Reset()                    { *m = SetupOverlayResponse{} }
+func (m *SetupOverlayResponse) String() string            { return proto.CompactTextString(m) }
+func (*SetupOverlayResponse) ProtoMessage()               {}
+func (*SetupOverlayResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+
+func (m *SetupOverlayResponse) GetPublicKey() string {
+	if m != nil {
+		return m.PublicKey
+	}
+	return ""
+}
+
+type SetupCNIRequest struct {
+	Config    []byte `protobuf:"bytes,1,opt,name=config,proto3" json:"config,omitempty"`
+	BinDir    string `protobuf:"bytes,2,opt,name=binDir" json:"binDir,omitempty"`
+	SandboxId string `protobuf:"bytes,3,opt,name=sandboxId" json:"sandboxId,omitempty"`
+}
+
+func (m *SetupCNIRequest) Reset()                    { *m = SetupCNIRequest{} }
+func (m *SetupCNIRequest) String() string            { return proto.CompactTextString(m) }
+func (*SetupCNIRequest) ProtoMessage()               {}
+func (*SetupCNIRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+func (m *SetupCNIRequest) GetConfig() []byte {
+	if m != nil {
+		return m.Config
+	}
+	return nil
+}
+
+func (m *SetupCNIRequest) GetBinDir() string {
+	if m != nil {
+		return m.BinDir
+	}
+	return ""
+}
+
+func (m *SetupCNIRequest) GetSandboxId() string {
+	if m != nil {
+		return m.SandboxId
+	}
+	return ""
+}
+
+type SetupCNIResponse struct {
+	Ip   string `protobuf:"bytes,1,opt,name=ip" json:"ip,omitempty"`
+	Ipv6 string `protobuf:"bytes,2,opt,name=ipv6" json:"ipv6,omitempty"`
+}
+
+func (m *SetupCNIResponse) Reset()                    { *m = SetupCNIResponse{} }
+func (m *SetupCNIResponse) String() string            { return proto.CompactTextString(m) }
+func (*SetupCNIResponse) ProtoMessage()               {}
+func (*SetupCNIResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+func (m *SetupCNIResponse) GetIp() string {
+	if m != nil {
+		return m.Ip
+	}
+	return ""
+}
+
+func (m *SetupCNIResponse) GetIpv6() string {
+	if m != nil {
+		return m.Ipv6
+	}
+	return ""
+}
+
+type TeardownCNIRequest struct {
+}
+
+func (m *TeardownCNIRequest) Reset()                    { *m = TeardownCNIRequest{} }
+func (m *TeardownCNIRequest) String() string            { return proto.CompactTextString(m) }
+func (*TeardownCNIRequest) ProtoMessage()               {}
+func (*TeardownCNIRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+
+type TeardownCNIResponse struct {
+}
+
+func (m *TeardownCNIResponse) Reset()                    { *m = TeardownCNIResponse{} }
+func (m *TeardownCNIResponse) String() string            { return proto.CompactTextString(m) }
+func (*TeardownCNIResponse) ProtoMessage()               {}
+func (*TeardownCNIResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
+type ListAddressesRequest struct {
 }
 
-func (m *File) Reset()                    { *m = File{} }
-func (m *File) String() string            { return proto.CompactTextString(m) }
-func (*File) ProtoMessage()               {}
-func (*File) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+func (m *ListAddressesRequest) Reset()                    { *m = ListAddressesRequest{} }
+func (m *ListAddressesRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListAddressesRequest) ProtoMessage()               {}
+func (*ListAddressesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
 
-func (m *File) GetSize() int64 {
+type InterfaceAddress struct {
+	Interface string `protobuf:"bytes,1,opt,name=interface" json:"interface,omitempty"`
+	Ip        string `protobuf:"bytes,2,opt,name=ip" json:"ip,omitempty"`
+}
+
+func (m *InterfaceAddress) Reset()                    { *m = InterfaceAddress{} }
+func (m *InterfaceAddress) String() string            { return proto.CompactTextString(m) }
+func (*InterfaceAddress) ProtoMessage()               {}
+func (*InterfaceAddress) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
+
+func (m *InterfaceAddress) GetInterface() string {
 	if m != nil {
-		return m.Size
+		return m.Interface
 	}
-	return 0
+	return ""
 }
 
-func (m *File) GetData() []byte {
+func (m *InterfaceAddress) GetIp() string {
 	if m != nil {
-		return m.Data
+		return m.Ip
 	}
-	return nil
+	return ""
 }
 
-type UploadResponse struct {
+type ListAddressesResponse struct {
+	Addresses []*InterfaceAddress `protobuf:"bytes,1,rep,name=addresses" json:"addresses,omitempty"`
 }
 
-func (m *UploadResponse) Reset()                    { *m = UploadResponse{} }
-func (m *UploadResponse) String() string            { return proto.CompactTextString(m) }
-func (*UploadResponse) ProtoMessage()               {}
-func (*UploadResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+func (m *ListAddressesResponse) Reset()                    { *m = ListAddressesResponse{} }
+func (m *ListAddressesResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListAddressesResponse) ProtoMessage()               {}
+func (*ListAddressesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
 
-type StartProxyRequest struct {
-	Ip          string `protobuf:"bytes,1,opt,name=ip" json:"ip,omitempty"`
-	ClusterCidr string `protobuf:"bytes,2,opt,name=clusterCidr" json:"clusterCidr,omitempty"`
-	Kubeconfig  []byte `protobuf:"bytes,3,opt,name=kubeconfig,proto3" json:"kubeconfig,omitempty"`
+func (m *ListAddressesResponse) GetAddresses() []*InterfaceAddress {
+	if m != nil {
+		return m.Addresses
+	}
+	return nil
 }
 
-func (m *StartProxyRequest) Reset()                    { *m = StartProxyRequest{} }
-func (m *StartProxyRequest) String() string            { return proto.CompactTextString(m) }
-func (*StartProxyRequest) ProtoMessage()               {}
-func (*StartProxyRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+type PortMapping struct {
+	Protocol      string `protobuf:"bytes,1,opt,name=protocol" json:"protocol,omitempty"`
+	ContainerPort int32  `protobuf:"varint,2,opt,name=containerPort" json:"containerPort,omitempty"`
+	HostPort      int32  `protobuf:"varint,3,opt,name=hostPort" json:"hostPort,omitempty"`
+}
 
-func (m *StartProxyRequest) GetIp() string {
+func (m *PortMapping) Reset()                    { *m = PortMapping{} }
+func (m *PortMapping) String() string            { return proto.CompactTextString(m) }
+func (*PortMapping) ProtoMessage()               {}
+func (*PortMapping) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
+
+func (m *PortMapping) GetProtocol() string {
 	if m != nil {
-		return m.Ip
+		return m.Protocol
 	}
 	return ""
 }
 
-func (m *StartProxyRequest) GetClusterCidr() string {
+func (m *PortMapping) GetContainerPort() int32 {
 	if m != nil {
-		return m.ClusterCidr
+		return m.ContainerPort
 	}
-	return ""
+	return 0
 }
 
-func (m *StartProxyRequest) GetKubeconfig() []byte {
+func (m *PortMapping) GetHostPort() int32 {
 	if m != nil {
-		return m.Kubeconfig
+		return m.HostPort
+	}
+	return 0
+}
+
+type SetPortMappingsRequest struct {
+	Mappings []*PortMapping `protobuf:"bytes,1,rep,name=mappings" json:"mappings,omitempty"`
+}
+
+func (m *SetPortMappingsRequest) Reset()                    { *m = SetPortMappingsRequest{} }
+func (m *SetPortMappingsRequest) String() string            { return proto.CompactTextString(m) }
+func (*SetPortMappingsRequest) ProtoMessage()               {}
+func (*SetPortMappingsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
+
+func (m *SetPortMappingsRequest) GetMappings() []*PortMapping {
+	if m != nil {
+		return m.Mappings
 	}
 	return nil
 }
 
-type StartProxyResponse struct {
+type SetPortMappingsResponse struct {
 }
 
-func (m *StartProxyResponse) Reset()                    { *m = StartProxyResponse{} }
-func (m *StartProxyResponse) String() string            { return proto.CompactTextString(m) }
-func (*StartProxyResponse) ProtoMessage()               {}
-func (*StartProxyResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+func (m *SetPortMappingsResponse) Reset()                    { *m = SetPortMappingsResponse{} }
+func (m *SetPortMappingsResponse) String() string            { return proto.CompactTextString(m) }
+func (*SetPortMappingsResponse) ProtoMessage()               {}
+func (*SetPortMappingsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{31} }
 
 type RunCmdRequest struct {
 	Cmd  string   `protobuf:"bytes,1,opt,name=cmd" json:"cmd,omitempty"`
@@ -207,7 +868,7 @@ type RunCmdRequest struct {
 func (m *RunCmdRequest) Reset()                    { *m = RunCmdRequest{} }
 func (m *RunCmdRequest) String() string            { return proto.CompactTextString(m) }
 func (*RunCmdRequest) ProtoMessage()               {}
-func (*RunCmdRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+func (*RunCmdRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{32} }
 
 func (m *RunCmdRequest) GetCmd() string {
 	if m != nil {
@@ -229,16 +890,17 @@ type RunCmdResponse struct {
 func (m *RunCmdResponse) Reset()                    { *m = RunCmdResponse{} }
 func (m *RunCmdResponse) String() string            { return proto.CompactTextString(m) }
 func (*RunCmdResponse) ProtoMessage()               {}
-func (*RunCmdResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+func (*RunCmdResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{33} }
 
 type SetIPRequest struct {
-	Ip string `protobuf:"bytes,1,opt,name=ip" json:"ip,omitempty"`
+	Ip   string `protobuf:"bytes,1,opt,name=ip" json:"ip,omitempty"`
+	Ipv6 string `protobuf:"bytes,2,opt,name=ipv6" json:"ipv6,omitempty"`
 }
 
 func (m *SetIPRequest) Reset()                    { *m = SetIPRequest{} }
 func (m *SetIPRequest) String() string            { return proto.CompactTextString(m) }
 func (*SetIPRequest) ProtoMessage()               {}
-func (*SetIPRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+func (*SetIPRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{34} }
 
 func (m *SetIPRequest) GetIp() string {
 	if m != nil {
@@ -247,13 +909,20 @@ func (m *SetIPRequest) GetIp() string {
 	return ""
 }
 
+func (m *SetIPRequest) GetIpv6() string {
+	if m != nil {
+		return m.Ipv6
+	}
+	return ""
+}
+
 type SetIPResponse struct {
 }
 
 func (m *SetIPResponse) Reset()                    { *m = SetIPResponse{} }
 func (m *SetIPResponse) String() string            { return proto.CompactTextString(m) }
 func (*SetIPResponse) ProtoMessage()               {}
-func (*SetIPResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+func (*SetIPResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{35} }
 
 type GetIPRequest struct {
 }
@@ -261,16 +930,17 @@ type GetIPRequest struct {
 func (m *GetIPRequest) Reset()                    { *m = GetIPRequest{} }
 func (m *GetIPRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetIPRequest) ProtoMessage()               {}
-func (*GetIPRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+func (*GetIPRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{36} }
 
 type GetIPResponse struct {
-	Ip string `protobuf:"bytes,1,opt,name=ip" json:"ip,omitempty"`
+	Ip   string `protobuf:"bytes,1,opt,name=ip" json:"ip,omitempty"`
+	Ipv6 string `protobuf:"bytes,2,opt,name=ipv6" json:"ipv6,omitempty"`
 }
 
 func (m *GetIPResponse) Reset()                    { *m = GetIPResponse{} }
 func (m *GetIPResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetIPResponse) ProtoMessage()               {}
-func (*GetIPResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+func (*GetIPResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{37} }
 
 func (m *GetIPResponse) GetIp() string {
 	if m != nil {
@@ -279,6 +949,13 @@ func (m *GetIPResponse) GetIp() string {
 	return ""
 }
 
+func (m *GetIPResponse) GetIpv6() string {
+	if m != nil {
+		return m.Ipv6
+	}
+	return ""
+}
+
 type SetSandboxConfigRequest struct {
 	Config []byte `protobuf:"bytes,1,opt,name=config,proto3" json:"config,omitempty"`
 }
@@ -286,7 +963,7 @@ type SetSandboxConfigRequest struct {
 func (m *SetSandboxConfigRequest) Reset()                    { *m = SetSandboxConfigRequest{} }
 func (m *SetSandboxConfigRequest) String() string            { return proto.CompactTextString(m) }
 func (*SetSandboxConfigRequest) ProtoMessage()               {}
-func (*SetSandboxConfigRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+func (*SetSandboxConfigRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{38} }
 
 func (m *SetSandboxConfigRequest) GetConfig() []byte {
 	if m != nil {
@@ -301,7 +978,7 @@ type SetSandboxConfigResponse struct {
 func (m *SetSandboxConfigResponse) Reset()                    { *m = SetSandboxConfigResponse{} }
 func (m *SetSandboxConfigResponse) String() string            { return proto.CompactTextString(m) }
 func (*SetSandboxConfigResponse) ProtoMessage()               {}
-func (*SetSandboxConfigResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+func (*SetSandboxConfigResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{39} }
 
 type GetSandboxConfigRequest struct {
 }
@@ -309,7 +986,7 @@ type GetSandboxConfigRequest struct {
 func (m *GetSandboxConfigRequest) Reset()                    { *m = GetSandboxConfigRequest{} }
 func (m *GetSandboxConfigRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetSandboxConfigRequest) ProtoMessage()               {}
-func (*GetSandboxConfigRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+func (*GetSandboxConfigRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{40} }
 
 type GetSandboxConfigResponse struct {
 	Config []byte `protobuf:"bytes,1,opt,name=config,proto3" json:"config,omitempty"`
@@ -318,7 +995,7 @@ type GetSandboxConfigResponse struct {
 func (m *GetSandboxConfigResponse) Reset()                    { *m = GetSandboxConfigResponse{} }
 func (m *GetSandboxConfigResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetSandboxConfigResponse) ProtoMessage()               {}
-func (*GetSandboxConfigResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+func (*GetSandboxConfigResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{41} }
 
 func (m *GetSandboxConfigResponse) GetConfig() []byte {
 	if m != nil {
@@ -335,7 +1012,7 @@ type CopyFileRequest struct {
 func (m *CopyFileRequest) Reset()                    { *m = CopyFileRequest{} }
 func (m *CopyFileRequest) String() string            { return proto.CompactTextString(m) }
 func (*CopyFileRequest) ProtoMessage()               {}
-func (*CopyFileRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+func (*CopyFileRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{42} }
 
 func (m *CopyFileRequest) GetFile() string {
 	if m != nil {
@@ -357,7 +1034,7 @@ type CopyFileResponse struct {
 func (m *CopyFileResponse) Reset()                    { *m = CopyFileResponse{} }
 func (m *CopyFileResponse) String() string            { return proto.CompactTextString(m) }
 func (*CopyFileResponse) ProtoMessage()               {}
-func (*CopyFileResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+func (*CopyFileResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{43} }
 
 type MountFsRequest struct {
 	Source   string `protobuf:"bytes,1,opt,name=source" json:"source,omitempty"`
@@ -369,7 +1046,7 @@ type MountFsRequest struct {
 func (m *MountFsRequest) Reset()                    { *m = MountFsRequest{} }
 func (m *MountFsRequest) String() string            { return proto.CompactTextString(m) }
 func (*MountFsRequest) ProtoMessage()               {}
-func (*MountFsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+func (*MountFsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{44} }
 
 func (m *MountFsRequest) GetSource() string {
 	if m != nil {
@@ -405,7 +1082,7 @@ type MountFsResponse struct {
 func (m *MountFsResponse) Reset()                    { *m = MountFsResponse{} }
 func (m *MountFsResponse) String() string            { return proto.CompactTextString(m) }
 func (*MountFsResponse) ProtoMessage()               {}
-func (*MountFsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+func (*MountFsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{45} }
 
 type UnmountFsRequest struct {
 	Target string `protobuf:"bytes,1,opt,name=target" json:"target,omitempty"`
@@ -414,7 +1091,7 @@ type UnmountFsRequest struct {
 func (m *UnmountFsRequest) Reset()                    { *m = UnmountFsRequest{} }
 func (m *UnmountFsRequest) String() string            { return proto.CompactTextString(m) }
 func (*UnmountFsRequest) ProtoMessage()               {}
-func (*UnmountFsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+func (*UnmountFsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{46} }
 
 func (m *UnmountFsRequest) GetTarget() string {
 	if m != nil {
@@ -429,7 +1106,7 @@ type UnmountFsResponse struct {
 func (m *UnmountFsResponse) Reset()                    { *m = UnmountFsResponse{} }
 func (m *UnmountFsResponse) String() string            { return proto.CompactTextString(m) }
 func (*UnmountFsResponse) ProtoMessage()               {}
-func (*UnmountFsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+func (*UnmountFsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{47} }
 
 type SetHostnameRequest struct {
 	Hostname string `protobuf:"bytes,1,opt,name=hostname" json:"hostname,omitempty"`
@@ -438,7 +1115,7 @@ type SetHostnameRequest struct {
 func (m *SetHostnameRequest) Reset()                    { *m = SetHostnameRequest{} }
 func (m *SetHostnameRequest) String() string            { return proto.CompactTextString(m) }
 func (*SetHostnameRequest) ProtoMessage()               {}
-func (*SetHostnameRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+func (*SetHostnameRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{48} }
 
 func (m *SetHostnameRequest) GetHostname() string {
 	if m != nil {
@@ -453,7 +1130,7 @@ type SetHostnameResponse struct {
 func (m *SetHostnameResponse) Reset()                    { *m = SetHostnameResponse{} }
 func (m *SetHostnameResponse) String() string            { return proto.CompactTextString(m) }
 func (*SetHostnameResponse) ProtoMessage()               {}
-func (*SetHostnameResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+func (*SetHostnameResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{49} }
 
 type AddRouteRequest struct {
 	Target  string `protobuf:"bytes,1,opt,name=target" json:"target,omitempty"`
@@ -463,7 +1140,7 @@ type AddRouteRequest struct {
 func (m *AddRouteRequest) Reset()                    { *m = AddRouteRequest{} }
 func (m *AddRouteRequest) String() string            { return proto.CompactTextString(m) }
 func (*AddRouteRequest) ProtoMessage()               {}
-func (*AddRouteRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+func (*AddRouteRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{50} }
 
 func (m *AddRouteRequest) GetTarget() string {
 	if m != nil {
@@ -485,7 +1162,143 @@ type AddRouteResponse struct {
 func (m *AddRouteResponse) Reset()                    { *m = AddRouteResponse{} }
 func (m *AddRouteResponse) String() string            { return proto.CompactTextString(m) }
 func (*AddRouteResponse) ProtoMessage()               {}
-func (*AddRouteResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
+func (*AddRouteResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{51} }
+
+type CapabilitiesRequest struct {
+}
+
+func (m *CapabilitiesRequest) Reset()                    { *m = CapabilitiesRequest{} }
+func (m *CapabilitiesRequest) String() string            { return proto.CompactTextString(m) }
+func (*CapabilitiesRequest) ProtoMessage()               {}
+func (*CapabilitiesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{52} }
+
+type SubsystemStatus struct {
+	Name    string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Ready   bool   `protobuf:"varint,2,opt,name=ready" json:"ready,omitempty"`
+	Message string `protobuf:"bytes,3,opt,name=message" json:"message,omitempty"`
+}
+
+func (m *SubsystemStatus) Reset()                    { *m = SubsystemStatus{} }
+func (m *SubsystemStatus) String() string            { return proto.CompactTextString(m) }
+func (*SubsystemStatus) ProtoMessage()               {}
+func (*SubsystemStatus) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{53} }
+
+func (m *SubsystemStatus) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *SubsystemStatus) GetReady() bool {
+	if m != nil {
+		return m.Ready
+	}
+	return false
+}
+
+func (m *SubsystemStatus) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+type CapabilitiesResponse struct {
+	ProtocolVersion   string             `protobuf:"bytes,1,opt,name=protocolVersion" json:"protocolVersion,omitempty"`
+	Features          []string           `protobuf:"bytes,2,rep,name=features" json:"features,omitempty"`
+	ContainerProvider string             `protobuf:"bytes,3,opt,name=containerProvider" json:"containerProvider,omitempty"`
+	StreamingEndpoint string             `protobuf:"bytes,4,opt,name=streamingEndpoint" json:"streamingEndpoint,omitempty"`
+	Subsystems        []*SubsystemStatus `protobuf:"bytes,5,rep,name=subsystems" json:"subsystems,omitempty"`
+}
+
+func (m *CapabilitiesResponse) Reset()                    { *m = CapabilitiesResponse{} }
+func (m *CapabilitiesResponse) String() string            { return proto.CompactTextString(m) }
+func (*CapabilitiesResponse) ProtoMessage()               {}
+func (*CapabilitiesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{54} }
+
+func (m *CapabilitiesResponse) GetProtocolVersion() string {
+	if m != nil {
+		return m.ProtocolVersion
+	}
+	return ""
+}
+
+func (m *CapabilitiesResponse) GetFeatures() []string {
+	if m != nil {
+		return m.Features
+	}
+	return nil
+}
+
+func (m *CapabilitiesResponse) GetContainerProvider() string {
+	if m != nil {
+		return m.ContainerProvider
+	}
+	return ""
+}
+
+func (m *CapabilitiesResponse) GetStreamingEndpoint() string {
+	if m != nil {
+		return m.StreamingEndpoint
+	}
+	return ""
+}
+
+func (m *CapabilitiesResponse) GetSubsystems() []*SubsystemStatus {
+	if m != nil {
+		return m.Subsystems
+	}
+	return nil
+}
+
+type WatchContainerEventsRequest struct {
+}
+
+func (m *WatchContainerEventsRequest) Reset()                    { *m = WatchContainerEventsRequest{} }
+func (m *WatchContainerEventsRequest) String() string            { return proto.CompactTextString(m) }
+func (*WatchContainerEventsRequest) ProtoMessage()               {}
+func (*WatchContainerEventsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{55} }
+
+type ContainerEvent struct {
+	ContainerID string             `protobuf:"bytes,1,opt,name=containerID" json:"containerID,omitempty"`
+	Type        ContainerEventType `protobuf:"varint,2,opt,name=type,enum=common.ContainerEventType" json:"type,omitempty"`
+	Timestamp   int64              `protobuf:"varint,3,opt,name=timestamp" json:"timestamp,omitempty"`
+	Status      []byte             `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
+}
+
+func (m *ContainerEvent) Reset()                    { *m = ContainerEvent{} }
+func (m *ContainerEvent) String() string            { return proto.CompactTextString(m) }
+func (*ContainerEvent) ProtoMessage()               {}
+func (*ContainerEvent) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{56} }
+
+func (m *ContainerEvent) GetContainerID() string {
+	if m != nil {
+		return m.ContainerID
+	}
+	return ""
+}
+
+func (m *ContainerEvent) GetType() ContainerEventType {
+	if m != nil {
+		return m.Type
+	}
+	return ContainerEventType_CREATED
+}
+
+func (m *ContainerEvent) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func (m *ContainerEvent) GetStatus() []byte {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
 
 type AddMountRequest struct {
 	Volume     string `protobuf:"bytes,1,opt,name=volume" json:"volume,omitempty"`
@@ -499,7 +1312,7 @@ type AddMountRequest struct {
 func (m *AddMountRequest) Reset()                    { *m = AddMountRequest{} }
 func (m *AddMountRequest) String() string            { return proto.CompactTextString(m) }
 func (*AddMountRequest) ProtoMessage()               {}
-func (*AddMountRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
+func (*AddMountRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{57} }
 
 func (m *AddMountRequest) GetVolume() string {
 	if m != nil {
@@ -549,7 +1362,7 @@ type AddMountResponse struct {
 func (m *AddMountResponse) Reset()                    { *m = AddMountResponse{} }
 func (m *AddMountResponse) String() string            { return proto.CompactTextString(m) }
 func (*AddMountResponse) ProtoMessage()               {}
-func (*AddMountResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
+func (*AddMountResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{58} }
 
 type DelMountRequest struct {
 	MountPoint string `protobuf:"bytes,1,opt,name=mountPoint" json:"mountPoint,omitempty"`
@@ -558,7 +1371,7 @@ type DelMountRequest struct {
 func (m *DelMountRequest) Reset()                    { *m = DelMountRequest{} }
 func (m *DelMountRequest) String() string            { return proto.CompactTextString(m) }
 func (*DelMountRequest) ProtoMessage()               {}
-func (*DelMountRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
+func (*DelMountRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{59} }
 
 func (m *DelMountRequest) GetMountPoint() string {
 	if m != nil {
@@ -573,17 +1386,93 @@ type DelMountResponse struct {
 func (m *DelMountResponse) Reset()                    { *m = DelMountResponse{} }
 func (m *DelMountResponse) String() string            { return proto.CompactTextString(m) }
 func (*DelMountResponse) ProtoMessage()               {}
-func (*DelMountResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{31} }
+func (*DelMountResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{60} }
+
+type CreateContainerWithAuthRequest struct {
+	Request []byte `protobuf:"bytes,1,opt,name=request,proto3" json:"request,omitempty"`
+	Auth    []byte `protobuf:"bytes,2,opt,name=auth,proto3" json:"auth,omitempty"`
+}
+
+func (m *CreateContainerWithAuthRequest) Reset()         { *m = CreateContainerWithAuthRequest{} }
+func (m *CreateContainerWithAuthRequest) String() string { return proto.CompactTextString(m) }
+func (*CreateContainerWithAuthRequest) ProtoMessage()    {}
+func (*CreateContainerWithAuthRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{61}
+}
+
+func (m *CreateContainerWithAuthRequest) GetRequest() []byte {
+	if m != nil {
+		return m.Request
+	}
+	return nil
+}
+
+func (m *CreateContainerWithAuthRequest) GetAuth() []byte {
+	if m != nil {
+		return m.Auth
+	}
+	return nil
+}
+
+type CreateContainerWithAuthResponse struct {
+	ContainerId string `protobuf:"bytes,1,opt,name=containerId" json:"containerId,omitempty"`
+	ImageRef    string `protobuf:"bytes,2,opt,name=imageRef" json:"imageRef,omitempty"`
+}
+
+func (m *CreateContainerWithAuthResponse) Reset()         { *m = CreateContainerWithAuthResponse{} }
+func (m *CreateContainerWithAuthResponse) String() string { return proto.CompactTextString(m) }
+func (*CreateContainerWithAuthResponse) ProtoMessage()    {}
+func (*CreateContainerWithAuthResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{62}
+}
+
+func (m *CreateContainerWithAuthResponse) GetContainerId() string {
+	if m != nil {
+		return m.ContainerId
+	}
+	return ""
+}
+
+func (m *CreateContainerWithAuthResponse) GetImageRef() string {
+	if m != nil {
+		return m.ImageRef
+	}
+	return ""
+}
 
 func init() {
 	proto.RegisterType((*GetMetricsRequest)(nil), "common.GetMetricsRequest")
 	proto.RegisterType((*GetMetricsResponse)(nil), "common.GetMetricsResponse")
 	proto.RegisterType((*LogsRequest)(nil), "common.LogsRequest")
 	proto.RegisterType((*LogLine)(nil), "common.LogLine")
+	proto.RegisterType((*ReopenContainerLogRequest)(nil), "common.ReopenContainerLogRequest")
+	proto.RegisterType((*ReopenContainerLogResponse)(nil), "common.ReopenContainerLogResponse")
 	proto.RegisterType((*File)(nil), "common.File")
 	proto.RegisterType((*UploadResponse)(nil), "common.UploadResponse")
 	proto.RegisterType((*StartProxyRequest)(nil), "common.StartProxyRequest")
 	proto.RegisterType((*StartProxyResponse)(nil), "common.StartProxyResponse")
+	proto.RegisterType((*UpdateProxyKubeconfigRequest)(nil), "common.UpdateProxyKubeconfigRequest")
+	proto.RegisterType((*UpdateProxyKubeconfigResponse)(nil), "common.UpdateProxyKubeconfigResponse")
+	proto.RegisterType((*StopProxyRequest)(nil), "common.StopProxyRequest")
+	proto.RegisterType((*StopProxyResponse)(nil), "common.StopProxyResponse")
+	proto.RegisterType((*ProxyStatusRequest)(nil), "common.ProxyStatusRequest")
+	proto.RegisterType((*ProxyStatusResponse)(nil), "common.ProxyStatusResponse")
+	proto.RegisterType((*StartNetworkPolicyRequest)(nil), "common.StartNetworkPolicyRequest")
+	proto.RegisterType((*StartNetworkPolicyResponse)(nil), "common.StartNetworkPolicyResponse")
+	proto.RegisterType((*NetworkPolicyStatusRequest)(nil), "common.NetworkPolicyStatusRequest")
+	proto.RegisterType((*NetworkPolicyStatusResponse)(nil), "common.NetworkPolicyStatusResponse")
+	proto.RegisterType((*SetupOverlayRequest)(nil), "common.SetupOverlayRequest")
+	proto.RegisterType((*SetupOverlayResponse)(nil), "common.SetupOverlayResponse")
+	proto.RegisterType((*SetupCNIRequest)(nil), "common.SetupCNIRequest")
+	proto.RegisterType((*SetupCNIResponse)(nil), "common.SetupCNIResponse")
+	proto.RegisterType((*TeardownCNIRequest)(nil), "common.TeardownCNIRequest")
+	proto.RegisterType((*TeardownCNIResponse)(nil), "common.TeardownCNIResponse")
+	proto.RegisterType((*ListAddressesRequest)(nil), "common.ListAddressesRequest")
+	proto.RegisterType((*InterfaceAddress)(nil), "common.InterfaceAddress")
+	proto.RegisterType((*ListAddressesResponse)(nil), "common.ListAddressesResponse")
+	proto.RegisterType((*PortMapping)(nil), "common.PortMapping")
+	proto.RegisterType((*SetPortMappingsRequest)(nil), "common.SetPortMappingsRequest")
+	proto.RegisterType((*SetPortMappingsResponse)(nil), "common.SetPortMappingsResponse")
 	proto.RegisterType((*RunCmdRequest)(nil), "common.RunCmdRequest")
 	proto.RegisterType((*RunCmdResponse)(nil), "common.RunCmdResponse")
 	proto.RegisterType((*SetIPRequest)(nil), "common.SetIPRequest")
@@ -604,10 +1493,18 @@ func init() {
 	proto.RegisterType((*SetHostnameResponse)(nil), "common.SetHostnameResponse")
 	proto.RegisterType((*AddRouteRequest)(nil), "common.AddRouteRequest")
 	proto.RegisterType((*AddRouteResponse)(nil), "common.AddRouteResponse")
+	proto.RegisterType((*CapabilitiesRequest)(nil), "common.CapabilitiesRequest")
+	proto.RegisterType((*SubsystemStatus)(nil), "common.SubsystemStatus")
+	proto.RegisterType((*CapabilitiesResponse)(nil), "common.CapabilitiesResponse")
+	proto.RegisterType((*WatchContainerEventsRequest)(nil), "common.WatchContainerEventsRequest")
+	proto.RegisterType((*ContainerEvent)(nil), "common.ContainerEvent")
 	proto.RegisterType((*AddMountRequest)(nil), "common.AddMountRequest")
 	proto.RegisterType((*AddMountResponse)(nil), "common.AddMountResponse")
 	proto.RegisterType((*DelMountRequest)(nil), "common.DelMountRequest")
 	proto.RegisterType((*DelMountResponse)(nil), "common.DelMountResponse")
+	proto.RegisterType((*CreateContainerWithAuthRequest)(nil), "common.CreateContainerWithAuthRequest")
+	proto.RegisterType((*CreateContainerWithAuthResponse)(nil), "common.CreateContainerWithAuthResponse")
+	proto.RegisterEnum("common.ContainerEventType", ContainerEventType_name, ContainerEventType_value)
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -779,11 +1676,85 @@ var _Mounts_serviceDesc = grpc.ServiceDesc{
 	Metadata: "vmserver.proto",
 }
 
+// Client API for ContainerLogs service
+
+type ContainerLogsClient interface {
+	ReopenContainerLog(ctx context.Context, in *ReopenContainerLogRequest, opts ...grpc.CallOption) (*ReopenContainerLogResponse, error)
+}
+
+type containerLogsClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewContainerLogsClient(cc *grpc.ClientConn) ContainerLogsClient {
+	return &containerLogsClient{cc}
+}
+
+func (c *containerLogsClient) ReopenContainerLog(ctx context.Context, in *ReopenContainerLogRequest, opts ...grpc.CallOption) (*ReopenContainerLogResponse, error) {
+	out := new(ReopenContainerLogResponse)
+	err := grpc.Invoke(ctx, "/common.ContainerLogs/ReopenContainerLog", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for ContainerLogs service
+
+type ContainerLogsServer interface {
+	ReopenContainerLog(context.Context, *ReopenContainerLogRequest) (*ReopenContainerLogResponse, error)
+}
+
+func RegisterContainerLogsServer(s *grpc.Server, srv ContainerLogsServer) {
+	s.RegisterService(&_ContainerLogs_serviceDesc, srv)
+}
+
+func _ContainerLogs_ReopenContainerLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReopenContainerLogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContainerLogsServer).ReopenContainerLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/common.ContainerLogs/ReopenContainerLog",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContainerLogsServer).ReopenContainerLog(ctx, req.(*ReopenContainerLogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _ContainerLogs_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "common.ContainerLogs",
+	HandlerType: (*ContainerLogsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ReopenContainerLog",
+			Handler:    _ContainerLogs_ReopenContainerLog_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "vmserver.proto",
+}
+
 // Client API for VMServer service
 
 type VMServerClient interface {
 	// rpc UploadFiles(File) returns (UploadResponse) {}
 	StartProxy(ctx context.Context, in *StartProxyRequest, opts ...grpc.CallOption) (*StartProxyResponse, error)
+	StopProxy(ctx context.Context, in *StopProxyRequest, opts ...grpc.CallOption) (*StopProxyResponse, error)
+	ProxyStatus(ctx context.Context, in *ProxyStatusRequest, opts ...grpc.CallOption) (*ProxyStatusResponse, error)
+	UpdateProxyKubeconfig(ctx context.Context, in *UpdateProxyKubeconfigRequest, opts ...grpc.CallOption) (*UpdateProxyKubeconfigResponse, error)
+	StartNetworkPolicy(ctx context.Context, in *StartNetworkPolicyRequest, opts ...grpc.CallOption) (*StartNetworkPolicyResponse, error)
+	NetworkPolicyStatus(ctx context.Context, in *NetworkPolicyStatusRequest, opts ...grpc.CallOption) (*NetworkPolicyStatusResponse, error)
+	SetupOverlay(ctx context.Context, in *SetupOverlayRequest, opts ...grpc.CallOption) (*SetupOverlayResponse, error)
+	SetupCNI(ctx context.Context, in *SetupCNIRequest, opts ...grpc.CallOption) (*SetupCNIResponse, error)
+	TeardownCNI(ctx context.Context, in *TeardownCNIRequest, opts ...grpc.CallOption) (*TeardownCNIResponse, error)
+	ListAddresses(ctx context.Context, in *ListAddressesRequest, opts ...grpc.CallOption) (*ListAddressesResponse, error)
+	SetPortMappings(ctx context.Context, in *SetPortMappingsRequest, opts ...grpc.CallOption) (*SetPortMappingsResponse, error)
 	RunCmd(ctx context.Context, in *RunCmdRequest, opts ...grpc.CallOption) (*RunCmdResponse, error)
 	SetPodIP(ctx context.Context, in *SetIPRequest, opts ...grpc.CallOption) (*SetIPResponse, error)
 	GetPodIP(ctx context.Context, in *GetIPRequest, opts ...grpc.CallOption) (*GetIPResponse, error)
@@ -796,19 +1767,112 @@ type VMServerClient interface {
 	Logs(ctx context.Context, in *LogsRequest, opts ...grpc.CallOption) (VMServer_LogsClient, error)
 	GetMetrics(ctx context.Context, in *GetMetricsRequest, opts ...grpc.CallOption) (*GetMetricsResponse, error)
 	AddRoute(ctx context.Context, in *AddRouteRequest, opts ...grpc.CallOption) (*AddRouteResponse, error)
+	Capabilities(ctx context.Context, in *CapabilitiesRequest, opts ...grpc.CallOption) (*CapabilitiesResponse, error)
+	WatchContainerEvents(ctx context.Context, in *WatchContainerEventsRequest, opts ...grpc.CallOption) (VMServer_WatchContainerEventsClient, error)
+	CreateContainerWithAuth(ctx context.Context, in *CreateContainerWithAuthRequest, opts ...grpc.CallOption) (*CreateContainerWithAuthResponse, error)
+}
+
+type vMServerClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewVMServerClient(cc *grpc.ClientConn) VMServerClient {
+	return &vMServerClient{cc}
+}
+
+func (c *vMServerClient) StartProxy(ctx context.Context, in *StartProxyRequest, opts ...grpc.CallOption) (*StartProxyResponse, error) {
+	out := new(StartProxyResponse)
+	err := grpc.Invoke(ctx, "/common.VMServer/StartProxy", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vMServerClient) StopProxy(ctx context.Context, in *StopProxyRequest, opts ...grpc.CallOption) (*StopProxyResponse, error) {
+	out := new(StopProxyResponse)
+	err := grpc.Invoke(ctx, "/common.VMServer/StopProxy", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vMServerClient) ProxyStatus(ctx context.Context, in *ProxyStatusRequest, opts ...grpc.CallOption) (*ProxyStatusResponse, error) {
+	out := new(ProxyStatusResponse)
+	err := grpc.Invoke(ctx, "/common.VMServer/ProxyStatus", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vMServerClient) UpdateProxyKubeconfig(ctx context.Context, in *UpdateProxyKubeconfigRequest, opts ...grpc.CallOption) (*UpdateProxyKubeconfigResponse, error) {
+	out := new(UpdateProxyKubeconfigResponse)
+	err := grpc.Invoke(ctx, "/common.VMServer/UpdateProxyKubeconfig", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vMServerClient) StartNetworkPolicy(ctx context.Context, in *StartNetworkPolicyRequest, opts ...grpc.CallOption) (*StartNetworkPolicyResponse, error) {
+	out := new(StartNetworkPolicyResponse)
+	err := grpc.Invoke(ctx, "/common.VMServer/StartNetworkPolicy", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vMServerClient) NetworkPolicyStatus(ctx context.Context, in *NetworkPolicyStatusRequest, opts ...grpc.CallOption) (*NetworkPolicyStatusResponse, error) {
+	out := new(NetworkPolicyStatusResponse)
+	err := grpc.Invoke(ctx, "/common.VMServer/NetworkPolicyStatus", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vMServerClient) SetupOverlay(ctx context.Context, in *SetupOverlayRequest, opts ...grpc.CallOption) (*SetupOverlayResponse, error) {
+	out := new(SetupOverlayResponse)
+	err := grpc.Invoke(ctx, "/common.VMServer/SetupOverlay", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-type vMServerClient struct {
-	cc *grpc.ClientConn
+func (c *vMServerClient) SetupCNI(ctx context.Context, in *SetupCNIRequest, opts ...grpc.CallOption) (*SetupCNIResponse, error) {
+	out := new(SetupCNIResponse)
+	err := grpc.Invoke(ctx, "/common.VMServer/SetupCNI", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-func NewVMServerClient(cc *grpc.ClientConn) VMServerClient {
-	return &vMServerClient{cc}
+func (c *vMServerClient) TeardownCNI(ctx context.Context, in *TeardownCNIRequest, opts ...grpc.CallOption) (*TeardownCNIResponse, error) {
+	out := new(TeardownCNIResponse)
+	err := grpc.Invoke(ctx, "/common.VMServer/TeardownCNI", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-func (c *vMServerClient) StartProxy(ctx context.Context, in *StartProxyRequest, opts ...grpc.CallOption) (*StartProxyResponse, error) {
-	out := new(StartProxyResponse)
-	err := grpc.Invoke(ctx, "/common.VMServer/StartProxy", in, out, c.cc, opts...)
+func (c *vMServerClient) ListAddresses(ctx context.Context, in *ListAddressesRequest, opts ...grpc.CallOption) (*ListAddressesResponse, error) {
+	out := new(ListAddressesResponse)
+	err := grpc.Invoke(ctx, "/common.VMServer/ListAddresses", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vMServerClient) SetPortMappings(ctx context.Context, in *SetPortMappingsRequest, opts ...grpc.CallOption) (*SetPortMappingsResponse, error) {
+	out := new(SetPortMappingsResponse)
+	err := grpc.Invoke(ctx, "/common.VMServer/SetPortMappings", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -946,11 +2010,71 @@ func (c *vMServerClient) AddRoute(ctx context.Context, in *AddRouteRequest, opts
 	return out, nil
 }
 
+func (c *vMServerClient) Capabilities(ctx context.Context, in *CapabilitiesRequest, opts ...grpc.CallOption) (*CapabilitiesResponse, error) {
+	out := new(CapabilitiesResponse)
+	err := grpc.Invoke(ctx, "/common.VMServer/Capabilities", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vMServerClient) WatchContainerEvents(ctx context.Context, in *WatchContainerEventsRequest, opts ...grpc.CallOption) (VMServer_WatchContainerEventsClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_VMServer_serviceDesc.Streams[1], c.cc, "/common.VMServer/WatchContainerEvents", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &vMServerWatchContainerEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type VMServer_WatchContainerEventsClient interface {
+	Recv() (*ContainerEvent, error)
+	grpc.ClientStream
+}
+
+type vMServerWatchContainerEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *vMServerWatchContainerEventsClient) Recv() (*ContainerEvent, error) {
+	m := new(ContainerEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *vMServerClient) CreateContainerWithAuth(ctx context.Context, in *CreateContainerWithAuthRequest, opts ...grpc.CallOption) (*CreateContainerWithAuthResponse, error) {
+	out := new(CreateContainerWithAuthResponse)
+	err := grpc.Invoke(ctx, "/common.VMServer/CreateContainerWithAuth", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for VMServer service
 
 type VMServerServer interface {
 	// rpc UploadFiles(File) returns (UploadResponse) {}
 	StartProxy(context.Context, *StartProxyRequest) (*StartProxyResponse, error)
+	StopProxy(context.Context, *StopProxyRequest) (*StopProxyResponse, error)
+	ProxyStatus(context.Context, *ProxyStatusRequest) (*ProxyStatusResponse, error)
+	UpdateProxyKubeconfig(context.Context, *UpdateProxyKubeconfigRequest) (*UpdateProxyKubeconfigResponse, error)
+	StartNetworkPolicy(context.Context, *StartNetworkPolicyRequest) (*StartNetworkPolicyResponse, error)
+	NetworkPolicyStatus(context.Context, *NetworkPolicyStatusRequest) (*NetworkPolicyStatusResponse, error)
+	SetupOverlay(context.Context, *SetupOverlayRequest) (*SetupOverlayResponse, error)
+	SetupCNI(context.Context, *SetupCNIRequest) (*SetupCNIResponse, error)
+	TeardownCNI(context.Context, *TeardownCNIRequest) (*TeardownCNIResponse, error)
+	ListAddresses(context.Context, *ListAddressesRequest) (*ListAddressesResponse, error)
+	SetPortMappings(context.Context, *SetPortMappingsRequest) (*SetPortMappingsResponse, error)
 	RunCmd(context.Context, *RunCmdRequest) (*RunCmdResponse, error)
 	SetPodIP(context.Context, *SetIPRequest) (*SetIPResponse, error)
 	GetPodIP(context.Context, *GetIPRequest) (*GetIPResponse, error)
@@ -963,6 +2087,9 @@ type VMServerServer interface {
 	Logs(*LogsRequest, VMServer_LogsServer) error
 	GetMetrics(context.Context, *GetMetricsRequest) (*GetMetricsResponse, error)
 	AddRoute(context.Context, *AddRouteRequest) (*AddRouteResponse, error)
+	Capabilities(context.Context, *CapabilitiesRequest) (*CapabilitiesResponse, error)
+	WatchContainerEvents(*WatchContainerEventsRequest, VMServer_WatchContainerEventsServer) error
+	CreateContainerWithAuth(context.Context, *CreateContainerWithAuthRequest) (*CreateContainerWithAuthResponse, error)
 }
 
 func RegisterVMServerServer(s *grpc.Server, srv VMServerServer) {
@@ -987,6 +2114,186 @@ func _VMServer_StartProxy_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _VMServer_StopProxy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopProxyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VMServerServer).StopProxy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/common.VMServer/StopProxy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VMServerServer).StopProxy(ctx, req.(*StopProxyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VMServer_ProxyStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProxyStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VMServerServer).ProxyStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/common.VMServer/ProxyStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VMServerServer).ProxyStatus(ctx, req.(*ProxyStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VMServer_UpdateProxyKubeconfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateProxyKubeconfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VMServerServer).UpdateProxyKubeconfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/common.VMServer/UpdateProxyKubeconfig",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VMServerServer).UpdateProxyKubeconfig(ctx, req.(*UpdateProxyKubeconfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VMServer_StartNetworkPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartNetworkPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VMServerServer).StartNetworkPolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/common.VMServer/StartNetworkPolicy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VMServerServer).StartNetworkPolicy(ctx, req.(*StartNetworkPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VMServer_NetworkPolicyStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NetworkPolicyStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VMServerServer).NetworkPolicyStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/common.VMServer/NetworkPolicyStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VMServerServer).NetworkPolicyStatus(ctx, req.(*NetworkPolicyStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VMServer_SetupOverlay_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetupOverlayRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VMServerServer).SetupOverlay(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/common.VMServer/SetupOverlay",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VMServerServer).SetupOverlay(ctx, req.(*SetupOverlayRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VMServer_SetupCNI_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetupCNIRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VMServerServer).SetupCNI(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/common.VMServer/SetupCNI",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VMServerServer).SetupCNI(ctx, req.(*SetupCNIRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VMServer_TeardownCNI_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TeardownCNIRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VMServerServer).TeardownCNI(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/common.VMServer/TeardownCNI",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VMServerServer).TeardownCNI(ctx, req.(*TeardownCNIRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VMServer_ListAddresses_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAddressesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VMServerServer).ListAddresses(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/common.VMServer/ListAddresses",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VMServerServer).ListAddresses(ctx, req.(*ListAddressesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VMServer_SetPortMappings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetPortMappingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VMServerServer).SetPortMappings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/common.VMServer/SetPortMappings",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VMServerServer).SetPortMappings(ctx, req.(*SetPortMappingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _VMServer_RunCmd_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(RunCmdRequest)
 	if err := dec(in); err != nil {
@@ -1206,6 +2513,63 @@ func _VMServer_AddRoute_Handler(srv interface{}, ctx context.Context, dec func(i
 	return interceptor(ctx, in, info, handler)
 }
 
+func _VMServer_Capabilities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CapabilitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VMServerServer).Capabilities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/common.VMServer/Capabilities",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VMServerServer).Capabilities(ctx, req.(*CapabilitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VMServer_WatchContainerEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchContainerEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VMServerServer).WatchContainerEvents(m, &vMServerWatchContainerEventsServer{stream})
+}
+
+type VMServer_WatchContainerEventsServer interface {
+	Send(*ContainerEvent) error
+	grpc.ServerStream
+}
+
+type vMServerWatchContainerEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *vMServerWatchContainerEventsServer) Send(m *ContainerEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _VMServer_CreateContainerWithAuth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateContainerWithAuthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VMServerServer).CreateContainerWithAuth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/common.VMServer/CreateContainerWithAuth",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VMServerServer).CreateContainerWithAuth(ctx, req.(*CreateContainerWithAuthRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _VMServer_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "common.VMServer",
 	HandlerType: (*VMServerServer)(nil),
@@ -1214,6 +2578,46 @@ var _VMServer_serviceDesc = grpc.ServiceDesc{
 			MethodName: "StartProxy",
 			Handler:    _VMServer_StartProxy_Handler,
 		},
+		{
+			MethodName: "StopProxy",
+			Handler:    _VMServer_StopProxy_Handler,
+		},
+		{
+			MethodName: "ProxyStatus",
+			Handler:    _VMServer_ProxyStatus_Handler,
+		},
+		{
+			MethodName: "UpdateProxyKubeconfig",
+			Handler:    _VMServer_UpdateProxyKubeconfig_Handler,
+		},
+		{
+			MethodName: "StartNetworkPolicy",
+			Handler:    _VMServer_StartNetworkPolicy_Handler,
+		},
+		{
+			MethodName: "NetworkPolicyStatus",
+			Handler:    _VMServer_NetworkPolicyStatus_Handler,
+		},
+		{
+			MethodName: "SetupOverlay",
+			Handler:    _VMServer_SetupOverlay_Handler,
+		},
+		{
+			MethodName: "SetupCNI",
+			Handler:    _VMServer_SetupCNI_Handler,
+		},
+		{
+			MethodName: "TeardownCNI",
+			Handler:    _VMServer_TeardownCNI_Handler,
+		},
+		{
+			MethodName: "ListAddresses",
+			Handler:    _VMServer_ListAddresses_Handler,
+		},
+		{
+			MethodName: "SetPortMappings",
+			Handler:    _VMServer_SetPortMappings_Handler,
+		},
 		{
 			MethodName: "RunCmd",
 			Handler:    _VMServer_RunCmd_Handler,
@@ -1258,6 +2662,14 @@ var _VMServer_serviceDesc = grpc.ServiceDesc{
 			MethodName: "AddRoute",
 			Handler:    _VMServer_AddRoute_Handler,
 		},
+		{
+			MethodName: "Capabilities",
+			Handler:    _VMServer_Capabilities_Handler,
+		},
+		{
+			MethodName: "CreateContainerWithAuth",
+			Handler:    _VMServer_CreateContainerWithAuth_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -1265,6 +2677,11 @@ var _VMServer_serviceDesc = grpc.ServiceDesc{
 			Handler:       _VMServer_Logs_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "WatchContainerEvents",
+			Handler:       _VMServer_WatchContainerEvents_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "vmserver.proto",
 }
@@ -1272,63 +2689,131 @@ var _VMServer_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("vmserver.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 919 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x56, 0x5d, 0x6f, 0xe3, 0x44,
-	0x14, 0x8d, 0x9b, 0x34, 0x4d, 0x6e, 0xdb, 0x24, 0x9d, 0xb6, 0x5b, 0xd7, 0x48, 0xbb, 0xd1, 0xf0,
-	0x12, 0x78, 0x28, 0xdd, 0x22, 0x1e, 0x90, 0x90, 0x56, 0x4b, 0x4a, 0xbd, 0x95, 0x5a, 0x11, 0x39,
-	0x14, 0x9e, 0xdd, 0x78, 0x1a, 0x0c, 0x89, 0x27, 0xd8, 0x93, 0xb0, 0xe1, 0x27, 0xf0, 0x67, 0xf8,
-	0x81, 0xbc, 0xa0, 0x19, 0xdf, 0xb1, 0xc7, 0x1f, 0xd1, 0xbe, 0xf0, 0x94, 0xb9, 0x77, 0xe6, 0x9e,
-	0x73, 0xcf, 0x7c, 0x9c, 0x18, 0x7a, 0x9b, 0x65, 0xc2, 0xe2, 0x0d, 0x8b, 0xaf, 0x56, 0x31, 0x17,
-	0x9c, 0xb4, 0x67, 0x7c, 0xb9, 0xe4, 0x11, 0xfd, 0x02, 0x4e, 0x5c, 0x26, 0x1e, 0x99, 0x88, 0xc3,
-	0x59, 0xe2, 0xb1, 0x3f, 0xd6, 0x2c, 0x11, 0xe4, 0x0c, 0xf6, 0x67, 0x7c, 0x1d, 0x09, 0xdb, 0x1a,
-	0x5a, 0xa3, 0x7d, 0x2f, 0x0d, 0xe8, 0x1d, 0x10, 0x73, 0x69, 0xb2, 0xe2, 0x51, 0xc2, 0xc8, 0x35,
-	0x9c, 0xfe, 0x96, 0xf0, 0x28, 0x4d, 0xeb, 0x6c, 0x62, 0x5b, 0xc3, 0xe6, 0xe8, 0xc8, 0xab, 0x9b,
-	0xa2, 0x5f, 0xc1, 0xe1, 0x03, 0x9f, 0x67, 0x64, 0x43, 0x38, 0x9c, 0xf1, 0x48, 0xf8, 0x61, 0xc4,
-	0xe2, 0xfb, 0x5b, 0x45, 0xd9, 0xf5, 0xcc, 0x14, 0xfd, 0x1c, 0x0e, 0x1e, 0xf8, 0xfc, 0x21, 0x8c,
-	0x18, 0xb1, 0xe1, 0x60, 0x91, 0x0e, 0x71, 0xa1, 0x0e, 0xe9, 0x15, 0xb4, 0xee, 0xc2, 0x05, 0x23,
-	0x04, 0x5a, 0x49, 0xf8, 0x57, 0x3a, 0xdd, 0xf4, 0xd4, 0x58, 0xe6, 0x02, 0x5f, 0xf8, 0xf6, 0xde,
-	0xd0, 0x1a, 0x1d, 0x79, 0x6a, 0x4c, 0x07, 0xd0, 0x7b, 0x5a, 0x2d, 0xb8, 0x1f, 0xe8, 0xc6, 0x28,
-	0x83, 0x93, 0xa9, 0xf0, 0x63, 0x31, 0x89, 0xf9, 0xc7, 0xad, 0xee, 0xae, 0x07, 0x7b, 0xe1, 0x0a,
-	0xb9, 0xf6, 0xc2, 0x95, 0xea, 0x76, 0xb1, 0x4e, 0x04, 0x8b, 0xc7, 0x61, 0x10, 0x2b, 0x44, 0xd9,
-	0x6d, 0x9e, 0x22, 0xaf, 0x01, 0x7e, 0x5f, 0x3f, 0xb3, 0x19, 0x8f, 0x5e, 0xc2, 0xb9, 0xdd, 0x54,
-	0x94, 0x46, 0x86, 0x9e, 0x01, 0x31, 0x69, 0x90, 0xfc, 0x1b, 0x38, 0xf6, 0xd6, 0xd1, 0x78, 0x19,
-	0x68, 0xe2, 0x01, 0x34, 0x67, 0xcb, 0x00, 0x99, 0xe5, 0x50, 0xaa, 0xf0, 0xe3, 0x79, 0x62, 0xef,
-	0x0d, 0x9b, 0xa3, 0xae, 0xa7, 0xc6, 0x52, 0x85, 0x2e, 0x43, 0xa0, 0xd7, 0x70, 0x34, 0x65, 0xe2,
-	0x7e, 0xb2, 0x43, 0x00, 0xed, 0xc3, 0x31, 0xce, 0x63, 0x41, 0x0f, 0x8e, 0x5c, 0xa3, 0x80, 0xbe,
-	0x81, 0x63, 0xd7, 0x5c, 0x50, 0x41, 0x78, 0x0b, 0x17, 0x53, 0x26, 0xa6, 0x7e, 0x14, 0x3c, 0xf3,
-	0x8f, 0x63, 0x25, 0x4a, 0x93, 0xbd, 0x82, 0x36, 0xea, 0xb6, 0x94, 0x6e, 0x8c, 0xa8, 0x03, 0x76,
-	0xb5, 0x04, 0xf9, 0x2f, 0xe1, 0xc2, 0xad, 0x87, 0xa3, 0x37, 0x60, 0xbb, 0x3b, 0xca, 0x76, 0x52,
-	0xbd, 0x87, 0xfe, 0x98, 0xaf, 0xb6, 0xf2, 0x2e, 0xe8, 0xae, 0x08, 0xb4, 0x5e, 0xc2, 0x85, 0xbe,
-	0x31, 0x6a, 0x4c, 0x1c, 0xe8, 0xc8, 0xdf, 0xdb, 0xfc, 0x5a, 0x64, 0x31, 0x25, 0x30, 0xc8, 0x21,
-	0xb0, 0x4b, 0x01, 0xbd, 0x47, 0xf9, 0x0a, 0xee, 0x12, 0x43, 0x6b, 0xc2, 0xd7, 0xf1, 0x4c, 0xe3,
-	0x62, 0x24, 0xf3, 0xc2, 0x8f, 0xe7, 0x4c, 0xe0, 0xe5, 0xc0, 0x48, 0xe6, 0x5f, 0x12, 0xb1, 0x5d,
-	0x31, 0x75, 0x27, 0xba, 0x1e, 0x46, 0xb2, 0x93, 0x98, 0xf9, 0xc1, 0x8f, 0xd1, 0x62, 0x6b, 0xb7,
-	0x86, 0xd6, 0xa8, 0xe3, 0x65, 0x31, 0x3d, 0x81, 0x7e, 0xc6, 0x8a, 0x8d, 0x7c, 0x09, 0x83, 0xa7,
-	0x68, 0x59, 0x69, 0x05, 0x29, 0x2d, 0x93, 0x92, 0x9e, 0xc2, 0x89, 0xb1, 0x16, 0x01, 0xae, 0x81,
-	0x4c, 0x99, 0xf8, 0xc0, 0x13, 0x11, 0xf9, 0xcb, 0x6c, 0x8f, 0x1c, 0xe8, 0xfc, 0x8a, 0x29, 0x04,
-	0xc9, 0x62, 0x7a, 0x0e, 0xa7, 0x85, 0x0a, 0x04, 0x1a, 0x43, 0xff, 0x7d, 0x10, 0x78, 0x7c, 0x2d,
-	0xd8, 0x27, 0x1a, 0x91, 0xcf, 0x76, 0xee, 0x0b, 0xf6, 0xa7, 0xbf, 0xc5, 0x4d, 0xd1, 0xa1, 0xdc,
-	0xeb, 0x1c, 0x04, 0x81, 0xff, 0xb1, 0x14, 0xb2, 0x52, 0x6e, 0x20, 0x6f, 0xf8, 0x62, 0x9d, 0x75,
-	0x87, 0x91, 0x7c, 0x6d, 0x4a, 0xe0, 0x84, 0x87, 0x91, 0xde, 0x71, 0x23, 0x93, 0xee, 0xfa, 0x4f,
-	0x85, 0x5d, 0x97, 0x91, 0xcc, 0x07, 0x6c, 0x13, 0xce, 0x98, 0xda, 0xf3, 0xae, 0x87, 0x51, 0xe1,
-	0x34, 0xf6, 0x8b, 0xa7, 0x21, 0x55, 0xac, 0x78, 0xf0, 0xf4, 0x74, 0x7f, 0x6b, 0xb7, 0x53, 0x15,
-	0x18, 0xa2, 0x0a, 0x6c, 0x18, 0x55, 0xbc, 0x85, 0xfe, 0x2d, 0x5b, 0x14, 0x44, 0x14, 0x9b, 0xb5,
-	0xca, 0xcd, 0x4a, 0x98, 0xbc, 0x24, 0x85, 0xb9, 0x99, 0xc0, 0x01, 0x5a, 0x2e, 0xf9, 0x01, 0x20,
-	0x37, 0x60, 0x72, 0x79, 0x95, 0x5a, 0xf8, 0x55, 0xc5, 0xbf, 0x1d, 0xa7, 0x6e, 0x0a, 0xdb, 0x6a,
-	0xdc, 0xfc, 0x6d, 0x41, 0x5b, 0x71, 0x24, 0xe4, 0x1d, 0x74, 0x74, 0xdf, 0xe4, 0x42, 0x17, 0x95,
-	0xb6, 0xde, 0xb1, 0xab, 0x13, 0x1a, 0x4b, 0x02, 0xe8, 0x8e, 0x73, 0x80, 0x92, 0xec, 0x1c, 0xa0,
-	0x2c, 0x8e, 0x36, 0x6e, 0xfe, 0x6d, 0x43, 0xe7, 0xe7, 0xc7, 0xa9, 0xfa, 0x6b, 0x92, 0x02, 0x73,
-	0x6b, 0xcc, 0x05, 0x56, 0x5c, 0x39, 0x17, 0x58, 0xe3, 0xa4, 0x0d, 0xf2, 0x2d, 0xb4, 0x53, 0x53,
-	0x24, 0xe7, 0x7a, 0x5d, 0xc1, 0x5b, 0x9d, 0x57, 0xe5, 0xb4, 0x51, 0xda, 0x99, 0x32, 0x31, 0xe1,
-	0xc1, 0xfd, 0x84, 0x9c, 0x65, 0x24, 0x86, 0x3d, 0x3a, 0xe7, 0xa5, 0xac, 0x59, 0xea, 0x56, 0x4a,
-	0xdd, 0xda, 0x52, 0xb7, 0x54, 0xfa, 0x0b, 0x0c, 0xca, 0xf6, 0x48, 0xde, 0x18, 0x3c, 0x75, 0xe6,
-	0xe8, 0x0c, 0x77, 0x2f, 0x30, 0x81, 0xdd, 0x9d, 0xc0, 0xee, 0xa7, 0x80, 0xdd, 0xdd, 0xc0, 0xef,
-	0xa0, 0xa3, 0x2d, 0x32, 0x3f, 0xf7, 0x92, 0xef, 0xe6, 0xe7, 0x5e, 0x71, 0xd3, 0x06, 0xf9, 0x0e,
-	0x0e, 0xd0, 0xd9, 0x48, 0x76, 0x1a, 0x45, 0x83, 0x75, 0x2e, 0x2a, 0xf9, 0xac, 0xfa, 0x7b, 0xe8,
-	0x66, 0xc6, 0x46, 0x32, 0x9a, 0xb2, 0x2f, 0x3a, 0x97, 0x35, 0x33, 0x19, 0xc6, 0x07, 0x38, 0x34,
-	0x5c, 0x8d, 0x38, 0xc6, 0x76, 0x96, 0xcc, 0xd1, 0xf9, 0xac, 0x76, 0x2e, 0x43, 0xba, 0x86, 0x96,
-	0xfc, 0xa0, 0x21, 0xa7, 0x7a, 0x99, 0xf1, 0x79, 0xe3, 0xf4, 0x8d, 0xa4, 0xfa, 0x50, 0x69, 0x5c,
-	0x5b, 0xff, 0xd3, 0x4b, 0xc6, 0xe7, 0xab, 0xcc, 0xb3, 0xf0, 0x7c, 0x4d, 0x4f, 0x2e, 0x3c, 0xdf,
-	0xa2, 0xcf, 0x36, 0x9e, 0xdb, 0xea, 0x63, 0xf0, 0xeb, 0xff, 0x02, 0x00, 0x00, 0xff, 0xff, 0x5d,
-	0x3a, 0x56, 0xa4, 0x1e, 0x0a, 0x00, 0x00,
+	// 2006 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x58, 0xdb, 0x6e, 0xdb, 0xc8,
+	0x19, 0x5e, 0x59, 0xb2, 0x0e, 0xbf, 0x75, 0x1c, 0x49, 0x31, 0x4d, 0x3b, 0x89, 0xcb, 0x6e, 0x52,
+	0x27, 0x5b, 0x04, 0x89, 0x0b, 0x14, 0x28, 0x50, 0xc0, 0xf0, 0x4a, 0x5a, 0xc5, 0x88, 0x9d, 0x08,
+	0x96, 0x9d, 0xa0, 0x17, 0x5b, 0x94, 0x16, 0xc7, 0xf2, 0x34, 0x14, 0x87, 0xcb, 0x19, 0x3a, 0xd1,
+	0x3e, 0x42, 0xaf, 0xfb, 0x08, 0xbd, 0xe8, 0x0b, 0xf4, 0x0d, 0xfa, 0x60, 0x05, 0x87, 0xc3, 0xe1,
+	0x50, 0xa2, 0x9c, 0x9b, 0xde, 0x89, 0xff, 0x69, 0xfe, 0xe3, 0xcc, 0xf7, 0x0b, 0x9a, 0xf7, 0x0b,
+	0x86, 0x83, 0x7b, 0x1c, 0xbc, 0xf2, 0x03, 0xca, 0x29, 0x2a, 0xcf, 0xe8, 0x62, 0x41, 0x3d, 0xcb,
+	0x82, 0xce, 0x18, 0xf3, 0x0b, 0xcc, 0x03, 0x32, 0x63, 0x97, 0xf8, 0x97, 0x10, 0x33, 0x8e, 0x1a,
+	0xb0, 0x3d, 0xa3, 0xa1, 0xc7, 0x8d, 0xc2, 0x61, 0xe1, 0x68, 0xdb, 0x7a, 0x03, 0x48, 0x97, 0x61,
+	0x3e, 0xf5, 0x18, 0x46, 0xfb, 0xd0, 0xfd, 0x3b, 0xa3, 0x5e, 0x4c, 0x4e, 0xa8, 0xcc, 0x28, 0x1c,
+	0x16, 0x8f, 0xea, 0xd6, 0x31, 0xec, 0x9c, 0xd3, 0xb9, 0x32, 0xd8, 0x85, 0x9d, 0x19, 0xf5, 0xb8,
+	0x4d, 0x3c, 0x1c, 0x9c, 0x0d, 0x85, 0xd9, 0x1a, 0x6a, 0x42, 0x99, 0xde, 0xde, 0x32, 0xcc, 0x8d,
+	0xad, 0xc3, 0xc2, 0x51, 0xd1, 0xfa, 0x2b, 0x54, 0xce, 0xe9, 0xfc, 0x9c, 0x78, 0x18, 0xb5, 0xa0,
+	0xe2, 0xc6, 0x3f, 0xa5, 0x6c, 0x07, 0x6a, 0x9c, 0x2c, 0x30, 0xe3, 0xf6, 0xc2, 0x8f, 0xc5, 0x23,
+	0x75, 0xc6, 0x03, 0x6c, 0x2f, 0x8c, 0xa2, 0x10, 0x69, 0x41, 0xc5, 0xb7, 0x03, 0x4e, 0x6c, 0xd7,
+	0x28, 0x1d, 0x16, 0x8e, 0xaa, 0x9a, 0xfd, 0x6d, 0x61, 0xff, 0x35, 0xec, 0x5d, 0x62, 0xea, 0x63,
+	0x6f, 0x90, 0xb8, 0x72, 0x4e, 0xe7, 0x0f, 0x79, 0x68, 0x1d, 0x80, 0x99, 0xa7, 0x11, 0x87, 0x6a,
+	0x59, 0x50, 0xfa, 0x89, 0xb8, 0x18, 0xd5, 0xa1, 0xc4, 0xc8, 0xaf, 0xb1, 0xa7, 0xc5, 0xe8, 0xcb,
+	0xb1, 0xb9, 0x2d, 0x9c, 0xac, 0x5b, 0x6d, 0x68, 0x5e, 0xfb, 0x2e, 0xb5, 0x1d, 0xa5, 0xf5, 0x9f,
+	0x2d, 0xe8, 0x4c, 0xb9, 0x1d, 0xf0, 0x49, 0x40, 0xbf, 0x2e, 0x93, 0xe3, 0x01, 0xb6, 0x88, 0x2f,
+	0x63, 0x8d, 0x5c, 0x71, 0x43, 0xc6, 0x71, 0x30, 0x20, 0x4e, 0x20, 0x0c, 0xd5, 0x10, 0x02, 0xf8,
+	0x1c, 0xde, 0xe0, 0x19, 0xf5, 0x6e, 0xc9, 0x5c, 0x44, 0x5c, 0x8f, 0x8e, 0x5a, 0x50, 0x07, 0x1b,
+	0xa5, 0x44, 0x82, 0x2d, 0xbd, 0xd9, 0x04, 0x07, 0x84, 0x3a, 0x22, 0xe4, 0x1a, 0xea, 0x43, 0x63,
+	0x41, 0xbc, 0x69, 0x4a, 0x2e, 0x2b, 0xb2, 0xcd, 0x7e, 0x09, 0x71, 0x60, 0x3b, 0xf8, 0xd4, 0x75,
+	0x8d, 0x8a, 0x48, 0xd8, 0x3e, 0x74, 0x67, 0xd4, 0xf3, 0x78, 0x60, 0xcf, 0x3e, 0x5f, 0xd8, 0x5f,
+	0x27, 0x38, 0x18, 0xd0, 0x00, 0x1b, 0xd5, 0xa8, 0x09, 0x50, 0x0f, 0xea, 0x29, 0x93, 0x78, 0x46,
+	0x4d, 0x50, 0x9f, 0xc3, 0x13, 0x45, 0xbd, 0x9a, 0xf9, 0x23, 0xc6, 0xed, 0x1b, 0x97, 0xb0, 0x3b,
+	0xec, 0x5c, 0x91, 0x05, 0xa6, 0x21, 0x37, 0x40, 0x9c, 0xf8, 0x3d, 0x1c, 0xe8, 0x72, 0x03, 0x97,
+	0x32, 0xfc, 0xc9, 0x26, 0x3c, 0x91, 0xda, 0x11, 0x52, 0x26, 0xa0, 0x3b, 0x6c, 0xbb, 0xfc, 0xee,
+	0xd7, 0x1f, 0x89, 0xe7, 0x9c, 0x3a, 0x4e, 0x80, 0x19, 0x33, 0xea, 0xa2, 0x16, 0x3d, 0x40, 0x7a,
+	0xda, 0x64, 0x36, 0x8f, 0xe1, 0xe0, 0xda, 0x77, 0x6c, 0x8e, 0x05, 0xf9, 0x9d, 0xca, 0x50, 0x92,
+	0xd7, 0x6c, 0xda, 0x0a, 0xa2, 0x26, 0x4f, 0xe1, 0xf1, 0x06, 0x1d, 0x69, 0x14, 0x41, 0x7b, 0xca,
+	0xa9, 0xaf, 0x17, 0xc8, 0xea, 0x42, 0x47, 0xa3, 0x49, 0xc1, 0x1e, 0x20, 0x41, 0x98, 0x72, 0x9b,
+	0x87, 0x49, 0xb3, 0x5b, 0x77, 0xd0, 0xcd, 0x50, 0xe5, 0xbc, 0xb4, 0xa0, 0x12, 0x84, 0x9e, 0x47,
+	0xbc, 0xd8, 0x8f, 0x6a, 0x44, 0x88, 0xa3, 0x5d, 0x8a, 0x1a, 0x57, 0x55, 0x3d, 0x55, 0x3f, 0x2f,
+	0x30, 0x63, 0xf6, 0x3c, 0x29, 0x70, 0x1b, 0xaa, 0x41, 0x34, 0x01, 0x01, 0x67, 0xa2, 0xbc, 0xdb,
+	0xd6, 0x00, 0xf6, 0x44, 0x4e, 0xde, 0x63, 0xfe, 0x85, 0x06, 0x9f, 0x27, 0xd4, 0x25, 0xb3, 0xe5,
+	0x03, 0xa1, 0xaf, 0xf4, 0xc8, 0x56, 0xd2, 0xe4, 0x79, 0x46, 0x64, 0x88, 0x07, 0x60, 0x66, 0x18,
+	0xd9, 0x50, 0xff, 0x59, 0x80, 0xfd, 0x5c, 0xf6, 0xa6, 0x98, 0x77, 0xa1, 0x45, 0xbc, 0x79, 0x54,
+	0xd6, 0x33, 0x46, 0x5d, 0x9b, 0x63, 0x47, 0xc6, 0xfe, 0x08, 0x9a, 0x38, 0x4b, 0x2f, 0x0a, 0x7a,
+	0x1b, 0xaa, 0x7e, 0x64, 0x99, 0x60, 0x66, 0x94, 0x0e, 0x8b, 0xd9, 0xbc, 0x6c, 0x27, 0x79, 0x71,
+	0x6d, 0xc6, 0xa3, 0x2e, 0x17, 0xfd, 0x5d, 0xb4, 0xfe, 0x5d, 0x80, 0xee, 0x14, 0xf3, 0xd0, 0xff,
+	0x70, 0x8f, 0x03, 0xd7, 0x56, 0x29, 0x49, 0x12, 0x1c, 0xcf, 0x59, 0x03, 0xb6, 0x7d, 0xea, 0x9c,
+	0xf9, 0x72, 0xc2, 0x5a, 0x50, 0x99, 0xdb, 0x1c, 0x7f, 0xb1, 0x97, 0xb2, 0x00, 0x3d, 0xa8, 0x7b,
+	0xd4, 0xc1, 0x23, 0xcf, 0xf1, 0x29, 0xf1, 0xb8, 0xac, 0x42, 0x1f, 0x1a, 0x11, 0x75, 0x12, 0xde,
+	0xb8, 0x64, 0xf6, 0x0e, 0x2f, 0xa5, 0x13, 0x75, 0x28, 0xf9, 0x34, 0xe0, 0xc2, 0x81, 0x6d, 0xb4,
+	0x03, 0xc5, 0x7b, 0x8f, 0x18, 0x95, 0xe4, 0x63, 0xc1, 0x43, 0x39, 0x46, 0x4d, 0x28, 0x07, 0x34,
+	0xe4, 0x98, 0x19, 0xb5, 0x28, 0x1a, 0xeb, 0x05, 0xf4, 0xb2, 0x9e, 0xca, 0xcc, 0x75, 0xa0, 0xe6,
+	0xab, 0x23, 0xe2, 0xdb, 0x68, 0x08, 0x2d, 0x21, 0x3a, 0x78, 0x7f, 0x96, 0x04, 0xd4, 0x84, 0x72,
+	0xa6, 0xbe, 0x4d, 0x28, 0xdf, 0x10, 0x6f, 0x48, 0x92, 0x5b, 0xa3, 0x03, 0x35, 0x66, 0x7b, 0xce,
+	0x0d, 0xfd, 0x7a, 0x16, 0x27, 0xb4, 0x66, 0xfd, 0x1e, 0xda, 0xa9, 0x15, 0x79, 0x98, 0x7e, 0xfb,
+	0xd4, 0xa1, 0x44, 0xfc, 0xfb, 0x3f, 0xca, 0xe6, 0xe8, 0x01, 0xba, 0xc2, 0x76, 0xe0, 0xd0, 0x2f,
+	0x5e, 0x7a, 0xac, 0xd5, 0x87, 0x6e, 0x86, 0x2a, 0x7b, 0xe5, 0x11, 0xf4, 0xce, 0x09, 0xe3, 0x72,
+	0x6e, 0xb1, 0xea, 0x92, 0x37, 0xd0, 0x3e, 0xf3, 0x38, 0x0e, 0x6e, 0xed, 0x19, 0x96, 0xcc, 0xc8,
+	0x33, 0x92, 0xd0, 0xe4, 0xc9, 0xb1, 0x17, 0x5b, 0x32, 0xd6, 0xfe, 0x8a, 0x29, 0xe9, 0xea, 0x0f,
+	0x50, 0xb3, 0x13, 0xa2, 0x78, 0x6b, 0x76, 0x8e, 0x8d, 0x57, 0xf1, 0x5b, 0xf6, 0x6a, 0xf5, 0x10,
+	0xeb, 0x2d, 0xec, 0x4c, 0x68, 0xc0, 0x2f, 0x6c, 0xdf, 0x27, 0xde, 0x5c, 0xf4, 0x52, 0xf4, 0xf8,
+	0xcd, 0xa8, 0x2b, 0x8f, 0xec, 0x43, 0x43, 0xdd, 0xfa, 0x91, 0xa4, 0x38, 0x7d, 0x3b, 0x12, 0xbc,
+	0xa3, 0x8c, 0x0b, 0x4a, 0x51, 0x4c, 0xda, 0x09, 0x3c, 0x9a, 0x62, 0xae, 0x19, 0x53, 0x4f, 0xdb,
+	0x33, 0xa8, 0x2e, 0x24, 0x49, 0xfa, 0xd3, 0x4d, 0xfc, 0xd1, 0xc4, 0xad, 0x3d, 0xd8, 0x5d, 0x33,
+	0x20, 0xd3, 0xf6, 0x12, 0x1a, 0x97, 0xa1, 0x37, 0x58, 0x38, 0x89, 0xc9, 0x1d, 0x28, 0xce, 0x16,
+	0x4e, 0x5a, 0x0f, 0x3b, 0x98, 0x33, 0x63, 0x4b, 0xb4, 0x4b, 0x1b, 0x9a, 0x89, 0xac, 0xd4, 0x3e,
+	0x82, 0xfa, 0x14, 0xf3, 0xb3, 0x49, 0xde, 0x4b, 0x92, 0xad, 0x65, 0x0b, 0x1a, 0x52, 0x52, 0xaa,
+	0x36, 0xa1, 0x3e, 0xd6, 0x54, 0xad, 0x17, 0xd0, 0x18, 0xeb, 0x02, 0x0f, 0xd8, 0x7a, 0x21, 0xc2,
+	0x99, 0xc6, 0xbd, 0x35, 0xc8, 0x5c, 0xb9, 0x2b, 0x3d, 0x69, 0x99, 0x60, 0xac, 0x8b, 0x4a, 0x0f,
+	0xf6, 0x60, 0x77, 0x9c, 0x6f, 0xc6, 0x7a, 0x09, 0xc6, 0x78, 0x83, 0xda, 0xda, 0x11, 0x6f, 0xa0,
+	0x35, 0xa0, 0xfe, 0x32, 0x7a, 0x8d, 0xb5, 0x51, 0xbf, 0x25, 0x6e, 0xd2, 0x5a, 0x6d, 0xa8, 0x46,
+	0x5f, 0xc3, 0xf4, 0x61, 0x46, 0xd0, 0x4e, 0x55, 0xa4, 0x37, 0x97, 0xd0, 0xbc, 0xa0, 0xa1, 0xc7,
+	0x7f, 0x62, 0x5a, 0x2c, 0x8c, 0x86, 0x81, 0x6a, 0xd1, 0x26, 0x94, 0xb9, 0x1d, 0xcc, 0x25, 0x64,
+	0x11, 0xdf, 0xb7, 0x8c, 0x2f, 0xfd, 0xe4, 0xce, 0x16, 0x57, 0xb4, 0xed, 0x7c, 0xf0, 0xdc, 0x65,
+	0x0c, 0x42, 0xac, 0x0e, 0xb4, 0x94, 0x4d, 0x85, 0x1b, 0xda, 0xd7, 0xde, 0x62, 0xed, 0x20, 0x69,
+	0x38, 0x9e, 0xf5, 0x2e, 0x74, 0x34, 0x19, 0xa9, 0xf8, 0x1c, 0xd0, 0x14, 0xf3, 0xb7, 0x94, 0x71,
+	0xcf, 0x5e, 0xa8, 0x48, 0x65, 0xb3, 0x46, 0x24, 0xa9, 0xdc, 0x87, 0x6e, 0x46, 0x4e, 0xbd, 0x95,
+	0xad, 0x53, 0xc7, 0xb9, 0x8c, 0x6e, 0x9f, 0x0d, 0xc7, 0xea, 0x77, 0x60, 0x5c, 0x67, 0x04, 0xed,
+	0x54, 0x47, 0xda, 0xe9, 0x43, 0x77, 0x60, 0xfb, 0xf6, 0x0d, 0x71, 0x09, 0x27, 0xe9, 0x94, 0x9f,
+	0x40, 0x6b, 0x1a, 0xde, 0xb0, 0x25, 0xe3, 0x78, 0x11, 0x3f, 0x03, 0x51, 0x11, 0x52, 0xb7, 0xa2,
+	0xfb, 0x36, 0x4a, 0x4e, 0xf2, 0xda, 0x69, 0xf7, 0x78, 0x7c, 0x33, 0xfd, 0xab, 0x00, 0xbd, 0xac,
+	0x61, 0x59, 0xee, 0x5d, 0x68, 0x25, 0x73, 0xfb, 0x11, 0x07, 0x8c, 0x50, 0x4f, 0x2b, 0x2b, 0xb6,
+	0x79, 0x18, 0x60, 0x39, 0x1f, 0x68, 0x0f, 0x3a, 0xe9, 0x40, 0x07, 0xf4, 0x9e, 0x38, 0x38, 0x90,
+	0xb5, 0xd9, 0x83, 0x4e, 0x8c, 0x17, 0x89, 0x37, 0x5f, 0xb9, 0xd3, 0x7f, 0x00, 0x60, 0x89, 0xeb,
+	0xd1, 0xdb, 0x1a, 0x4d, 0xf1, 0x6e, 0x32, 0xc5, 0x2b, 0x41, 0x59, 0x8f, 0x61, 0xff, 0x93, 0xcd,
+	0x67, 0x77, 0x0a, 0x13, 0x8e, 0xee, 0xb1, 0xc7, 0x55, 0x1a, 0x02, 0x68, 0x66, 0x39, 0xf9, 0xe0,
+	0xf7, 0x08, 0x4a, 0xa2, 0x6f, 0xa2, 0x5c, 0x34, 0x8f, 0xcd, 0xe4, 0xb0, 0xac, 0xea, 0xd5, 0xd2,
+	0xc7, 0x59, 0xe8, 0x5b, 0x4c, 0xa1, 0x6f, 0xe4, 0x8c, 0xf0, 0xbf, 0x6e, 0x7d, 0x15, 0x95, 0x15,
+	0x7d, 0xa6, 0x55, 0xf6, 0x9e, 0xba, 0xa1, 0x4a, 0x3e, 0x02, 0x10, 0xed, 0x34, 0x11, 0x61, 0x6b,
+	0xdd, 0x7b, 0x95, 0x76, 0x6f, 0x13, 0xca, 0x0e, 0xbe, 0x27, 0xb3, 0x0c, 0xe0, 0x90, 0xdd, 0xbc,
+	0x9d, 0xd4, 0xcc, 0xa7, 0xce, 0xf5, 0xf5, 0xd9, 0xd0, 0x28, 0x6b, 0xfd, 0x21, 0x4f, 0x96, 0xfd,
+	0xf1, 0x0c, 0x5a, 0x43, 0xec, 0x66, 0xbc, 0xc9, 0x9e, 0x5e, 0x48, 0x54, 0x53, 0x31, 0xa9, 0x7a,
+	0x02, 0x4f, 0x06, 0x01, 0xb6, 0x39, 0x56, 0x79, 0xf8, 0x44, 0xf8, 0xdd, 0x69, 0xc8, 0xef, 0x12,
+	0x4b, 0x11, 0xa2, 0x88, 0x7f, 0xca, 0x27, 0x2f, 0xba, 0x1f, 0x43, 0x7e, 0x27, 0xc7, 0xfa, 0x2d,
+	0x3c, 0xdd, 0x68, 0x40, 0x76, 0x53, 0xa6, 0x1c, 0x4e, 0xda, 0x49, 0x64, 0x61, 0xcf, 0xf1, 0x25,
+	0xbe, 0x8d, 0x93, 0xf3, 0xf2, 0x0a, 0x50, 0x4e, 0x31, 0x76, 0xa0, 0x32, 0xb8, 0x1c, 0x9d, 0x5e,
+	0x8d, 0x86, 0xed, 0xef, 0xa2, 0x8f, 0xe9, 0xd5, 0xe9, 0x65, 0xf4, 0x51, 0x88, 0x3f, 0x3e, 0x4c,
+	0x26, 0xa3, 0x61, 0x7b, 0x2b, 0xfa, 0xb8, 0x1c, 0x5d, 0x7c, 0xf8, 0x38, 0x1a, 0xb6, 0x8b, 0x08,
+	0xa0, 0x3c, 0xfd, 0xcb, 0xfb, 0xc1, 0x68, 0xd8, 0x2e, 0x1d, 0x4f, 0xa0, 0x22, 0xf7, 0x28, 0x34,
+	0x02, 0x48, 0xb7, 0x2a, 0xb4, 0x97, 0x74, 0xc0, 0xda, 0x36, 0x66, 0x9a, 0x79, 0x2c, 0x99, 0xb0,
+	0xef, 0x8e, 0xff, 0x51, 0x80, 0xb2, 0x48, 0x22, 0x43, 0x27, 0x50, 0x4d, 0x8a, 0x81, 0x54, 0xfb,
+	0xae, 0x34, 0x86, 0x69, 0xac, 0x33, 0x12, 0x5b, 0x91, 0x81, 0xa4, 0x24, 0xa9, 0x81, 0x95, 0x5a,
+	0x9a, 0xc6, 0x3a, 0x43, 0x39, 0xe3, 0x41, 0x43, 0x5f, 0x95, 0x18, 0xfa, 0x19, 0xd0, 0xfa, 0x06,
+	0x85, 0x7e, 0x93, 0x98, 0xd8, 0xb8, 0x8f, 0x99, 0xd6, 0x43, 0x22, 0xea, 0xbc, 0xff, 0xb6, 0xa0,
+	0xfa, 0xf1, 0x62, 0x2a, 0x16, 0xdb, 0x28, 0xa1, 0xe9, 0x86, 0x90, 0x26, 0x74, 0x6d, 0xd9, 0x32,
+	0xcd, 0x3c, 0x96, 0x4a, 0xc2, 0x8f, 0x50, 0x53, 0x48, 0x1f, 0x19, 0xa9, 0x68, 0x76, 0x21, 0x30,
+	0xf7, 0x72, 0x38, 0xca, 0x46, 0x04, 0x3c, 0xd2, 0x15, 0x00, 0xa9, 0x03, 0xd7, 0xb7, 0x05, 0x73,
+	0x3f, 0x97, 0xa7, 0x2c, 0xdd, 0x42, 0x3f, 0x77, 0x59, 0x41, 0xdf, 0x27, 0x7a, 0x0f, 0xed, 0x3f,
+	0xe6, 0xb3, 0x6f, 0x48, 0xa9, 0x73, 0x7e, 0x96, 0xeb, 0x55, 0x06, 0xcd, 0xa7, 0x85, 0xda, 0xb8,
+	0x66, 0x98, 0xd6, 0x43, 0x22, 0xca, 0xfc, 0xdf, 0xa0, 0x9b, 0xb3, 0x27, 0x20, 0xa5, 0xbc, 0x79,
+	0xc7, 0x30, 0x7f, 0xfb, 0xa0, 0x8c, 0x3a, 0xe1, 0x1d, 0xd4, 0x75, 0x20, 0x8d, 0x54, 0x5e, 0x73,
+	0x16, 0x01, 0xf3, 0x20, 0x9f, 0xa9, 0x0f, 0x42, 0x02, 0x92, 0xd3, 0x41, 0x58, 0x01, 0xdf, 0xa6,
+	0xb1, 0xce, 0xd0, 0x1b, 0x40, 0x43, 0xc8, 0x69, 0x03, 0xac, 0x83, 0x69, 0x73, 0x3f, 0x97, 0xa7,
+	0x2c, 0xbd, 0x87, 0x46, 0x06, 0x09, 0x23, 0xe5, 0x7b, 0x1e, 0xd6, 0x36, 0x1f, 0x6f, 0xe0, 0x2a,
+	0x7b, 0x57, 0xd0, 0x5a, 0x01, 0xa2, 0xe8, 0x89, 0x16, 0x48, 0x0e, 0xc4, 0x35, 0x9f, 0x6e, 0xe4,
+	0x2b, 0xab, 0x7f, 0x82, 0x72, 0x8c, 0x4b, 0x51, 0x5f, 0x0d, 0xae, 0x8e, 0x69, 0xcd, 0x47, 0xab,
+	0x64, 0x4d, 0xb5, 0x2a, 0xec, 0x3a, 0x67, 0x13, 0xd4, 0xd3, 0x4e, 0x52, 0xb8, 0xd4, 0xec, 0xaf,
+	0x50, 0x75, 0xd5, 0xf1, 0x9a, 0xea, 0x38, 0x57, 0x75, 0xbc, 0xa2, 0xfa, 0x49, 0xac, 0x41, 0x19,
+	0x78, 0x89, 0xf4, 0x38, 0xf3, 0x30, 0xa9, 0x79, 0xb8, 0x59, 0x40, 0x37, 0x3c, 0xde, 0x68, 0x78,
+	0xfc, 0x2d, 0xc3, 0xe3, 0xcd, 0x86, 0x4f, 0xa0, 0x9a, 0x20, 0xd6, 0xb4, 0x27, 0x57, 0x60, 0xaf,
+	0x69, 0xac, 0x33, 0x94, 0x81, 0x3f, 0x43, 0x45, 0x42, 0x51, 0xa4, 0xaa, 0x91, 0xc5, 0xbb, 0xe6,
+	0xee, 0x1a, 0x5d, 0xbf, 0x16, 0x15, 0x22, 0x4d, 0xaf, 0xc5, 0x55, 0x20, 0x6b, 0xee, 0xe5, 0x70,
+	0xf4, 0xa9, 0xd0, 0x80, 0x69, 0x3a, 0x15, 0xeb, 0xa8, 0xd6, 0xdc, 0xcf, 0xe5, 0x29, 0x4b, 0xaf,
+	0xa1, 0x24, 0xde, 0x17, 0xb5, 0x6b, 0x69, 0xff, 0x36, 0x9a, 0x2d, 0x8d, 0x18, 0xfd, 0x87, 0x68,
+	0x7d, 0xf7, 0xba, 0xf0, 0x7f, 0x7a, 0x6e, 0xe5, 0x1b, 0x2b, 0x00, 0x71, 0xe6, 0x8d, 0xd5, 0x61,
+	0xb5, 0x69, 0xac, 0x33, 0xf4, 0x7b, 0x4a, 0x07, 0xb9, 0xe9, 0x3d, 0x95, 0x83, 0xa9, 0xcd, 0x83,
+	0x7c, 0xa6, 0xd6, 0x6c, 0xbd, 0x3c, 0x2c, 0x8a, 0xd4, 0x9d, 0xf9, 0x00, 0x52, 0x4d, 0x47, 0x32,
+	0xcb, 0x17, 0xd9, 0x72, 0x61, 0x77, 0x03, 0x8e, 0x42, 0xcf, 0x95, 0xda, 0x83, 0x48, 0xcd, 0xfc,
+	0xdd, 0x37, 0xe5, 0x92, 0x30, 0x6e, 0xca, 0x02, 0xde, 0xff, 0xe1, 0x7f, 0x03, 0x00, 0xe9, 0xae,
+	0x5f, 0xe0, 0xa5, 0x16, 0x00, 0x00,
 }
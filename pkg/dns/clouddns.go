@@ -0,0 +1,145 @@
+package dns
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// the vendored google api has no cloud dns client, so its few calls are made here
+const cloudDNSEndpoint = "https://www.googleapis.com/dns/v1"
+
+type cloudDNS struct {
+	endpoint string
+	project  string
+	zoneName string
+	zone     string
+	client   *http.Client
+}
+
+type cloudDNSRecordSet struct {
+	Kind    string   `json:"kind,omitempty"`
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Ttl     int64    `json:"ttl"`
+	Rrdatas []string `json:"rrdatas"`
+}
+
+type cloudDNSChange struct {
+	Kind      string              `json:"kind"`
+	Additions []cloudDNSRecordSet `json:"additions,omitempty"`
+	Deletions []cloudDNSRecordSet `json:"deletions,omitempty"`
+}
+
+type cloudDNSList struct {
+	Rrsets []cloudDNSRecordSet `json:"rrsets"`
+}
+
+type cloudDNSZone struct {
+	DnsName string `json:"dnsName"`
+}
+
+// NewCloudDNS returns the managed zone zoneName of project, reached with client, which has to be authorized for
+// cloud dns
+func NewCloudDNS(client *http.Client, project string, zoneName string) (Backend, error) {
+	return newCloudDNS(cloudDNSEndpoint, client, project, zoneName)
+}
+
+func newCloudDNS(endpoint string, client *http.Client, project string, zoneName string) (*cloudDNS, error) {
+	c := &cloudDNS{
+		endpoint: endpoint,
+		project:  project,
+		zoneName: zoneName,
+		client:   client,
+	}
+
+	zone := &cloudDNSZone{}
+	if err := c.do("GET", "", nil, zone); err != nil {
+		return nil, fmt.Errorf("couldn't get managed zone %v: %v", zoneName, err)
+	}
+	c.zone = strings.ToLower(zone.DnsName)
+
+	return c, nil
+}
+
+func (c *cloudDNS) Zone() string {
+	return c.zone
+}
+
+func (c *cloudDNS) Records(name string) ([]Record, error) {
+	list := &cloudDNSList{}
+	if err := c.do("GET", "/rrsets?name="+url.QueryEscape(name), nil, list); err != nil {
+		return nil, err
+	}
+
+	records := []Record{}
+	for _, set := range list.Rrsets {
+		if set.Type != "A" && set.Type != "AAAA" {
+			continue
+		}
+		records = append(records, Record{Type: set.Type, TTL: set.Ttl, Values: set.Rrdatas})
+	}
+
+	return records, nil
+}
+
+func (c *cloudDNS) Change(name string, del []Record, add []Record) error {
+	change := &cloudDNSChange{Kind: "dns#change"}
+	for _, record := range del {
+		change.Deletions = append(change.Deletions, cloudDNSSet(name, record))
+	}
+	for _, record := range add {
+		change.Additions = append(change.Additions, cloudDNSSet(name, record))
+	}
+	if len(change.Deletions) == 0 && len(change.Additions) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+
+	return c.do("POST", "/changes", body, nil)
+}
+
+func cloudDNSSet(name string, record Record) cloudDNSRecordSet {
+	return cloudDNSRecordSet{Kind: "dns#resourceRecordSet", Name: name, Type: record.Type, Ttl: record.TTL, Rrdatas: record.Values}
+}
+
+// do makes a request of the managed zone's api at path, decoding the response into out if it isn't nil
+func (c *cloudDNS) do(method string, path string, body []byte, out interface{}) error {
+	u := fmt.Sprintf("%v/projects/%v/managedZones/%v%v", c.endpoint, c.project, c.zoneName, path)
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%v %v: %v: %s", method, u, resp.Status, bytes.TrimSpace(data))
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,169 @@
+/* A and AAAA records for VM pods in the cloud's DNS, for clients that can't use the cluster's */
+
+package dns
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Record is one of a name's record sets
+type Record struct {
+	Type   string
+	TTL    int64
+	Values []string
+}
+
+// Backend is a cloud DNS zone
+type Backend interface {
+	// Zone is the domain the backend's names are in, with a trailing dot
+	Zone() string
+	// Records returns the A and AAAA record sets of name
+	Records(name string) ([]Record, error)
+	// Change deletes the record sets in del and adds those in add, at once
+	Change(name string, del []Record, add []Record) error
+}
+
+// Registry keeps the records of pods' names in a Backend
+type Registry struct {
+	backend Backend
+	ttl     int64
+}
+
+func NewRegistry(backend Backend, ttl int64) *Registry {
+	return &Registry{backend: backend, ttl: ttl}
+}
+
+// Name returns the fully qualified name in the registry's zone that name is.  Names without a trailing dot are
+// relative to the zone unless they already end with it.
+func (r *Registry) Name(name string) (string, error) {
+	zone := r.backend.Zone()
+
+	name = strings.ToLower(name)
+	if !strings.HasSuffix(name, ".") {
+		if name != strings.TrimSuffix(zone, ".") && !strings.HasSuffix(name+".", "."+zone) {
+			name = name + "." + zone
+		} else {
+			name = name + "."
+		}
+	}
+
+	if name != zone && !strings.HasSuffix(name, "."+zone) {
+		return "", fmt.Errorf("%v isn't in %v", name, zone)
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if !validLabel(label) {
+			return "", fmt.Errorf("invalid dns name %v", name)
+		}
+	}
+
+	return name, nil
+}
+
+// Register points name at ips with an A record for the ipv4 ones and an AAAA record for the ipv6 ones, replacing the
+// records it had.  It returns the fully qualified name.
+func (r *Registry) Register(name string, ips []string) (string, error) {
+	fqdn, err := r.Name(name)
+	if err != nil {
+		return "", err
+	}
+
+	add := []Record{}
+	v4, v6 := splitIPs(ips)
+	if len(v4) > 0 {
+		add = append(add, Record{Type: "A", TTL: r.ttl, Values: v4})
+	}
+	if len(v6) > 0 {
+		add = append(add, Record{Type: "AAAA", TTL: r.ttl, Values: v6})
+	}
+	if len(add) == 0 {
+		return "", fmt.Errorf("no addresses for %v", fqdn)
+	}
+
+	old, err := r.backend.Records(fqdn)
+	if err != nil {
+		return "", fmt.Errorf("couldn't get the records of %v: %v", fqdn, err)
+	}
+
+	if err := r.backend.Change(fqdn, old, add); err != nil {
+		return "", fmt.Errorf("couldn't register %v: %v", fqdn, err)
+	}
+
+	return fqdn, nil
+}
+
+// Unregister removes the A and AAAA records of fqdn that only point at ips, the addresses of the pod it was registered
+// for.  Records of another pod that took the name over since are kept.  It isn't an error if there are none.
+func (r *Registry) Unregister(fqdn string, ips []string) error {
+	old, err := r.backend.Records(fqdn)
+	if err != nil {
+		return fmt.Errorf("couldn't get the records of %v: %v", fqdn, err)
+	}
+
+	owned := make(map[string]bool)
+	v4, v6 := splitIPs(ips)
+	for _, ip := range append(v4, v6...) {
+		owned[ip] = true
+	}
+
+	del := []Record{}
+	for _, record := range old {
+		if pointsAt(record, owned) {
+			del = append(del, record)
+		}
+	}
+	if len(del) == 0 {
+		return nil
+	}
+
+	if err := r.backend.Change(fqdn, del, nil); err != nil {
+		return fmt.Errorf("couldn't unregister %v: %v", fqdn, err)
+	}
+
+	return nil
+}
+
+// pointsAt reports if all of record's values are among ips
+func pointsAt(record Record, ips map[string]bool) bool {
+	for _, value := range record.Values {
+		parsed := net.ParseIP(value)
+		if parsed == nil || !ips[parsed.String()] {
+			return false
+		}
+	}
+	return len(record.Values) > 0
+}
+
+// splitIPs returns the ipv4 and ipv6 addresses among ips, sorted
+func splitIPs(ips []string) ([]string, []string) {
+	v4 := []string{}
+	v6 := []string{}
+	for _, ip := range ips {
+		parsed := net.ParseIP(ip)
+		switch {
+		case parsed == nil:
+			continue
+		case parsed.To4() != nil:
+			v4 = append(v4, parsed.String())
+		default:
+			v6 = append(v6, parsed.String())
+		}
+	}
+	sort.Strings(v4)
+	sort.Strings(v6)
+	return v4, v6
+}
+
+func validLabel(label string) bool {
+	if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for _, c := range label {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,168 @@
+package dns
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+func TestRegistryName(t *testing.T) {
+	r := NewRegistry(NewFake("pods.example.com."), 60)
+
+	for name, want := range map[string]string{
+		"web":                    "web.pods.example.com.",
+		"Web.Pods.Example.com":   "web.pods.example.com.",
+		"db.pods.example.com.":   "db.pods.example.com.",
+		"a.b":                    "a.b.pods.example.com.",
+		"pods.example.com":       "pods.example.com.",
+		"web.other.example.com.": "",
+		"-web":                   "",
+		"web_1":                  "",
+	} {
+		got, err := r.Name(name)
+		if want == "" {
+			if err == nil {
+				t.Errorf("Name(%q) = %v, want an error", name, got)
+			}
+			continue
+		}
+		if err != nil || got != want {
+			t.Errorf("Name(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	fake := NewFake("example.com.")
+	r := NewRegistry(fake, 60)
+
+	fqdn, err := r.Register("web", []string{"10.0.0.5", "2600:1f14::5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fqdn != "web.example.com." {
+		t.Errorf("Register() = %v, want web.example.com.", fqdn)
+	}
+
+	want := []Record{
+		{Type: "A", TTL: 60, Values: []string{"10.0.0.5"}},
+		{Type: "AAAA", TTL: 60, Values: []string{"2600:1f14::5"}},
+	}
+	if records, _ := fake.Records(fqdn); !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %+v, want %+v", records, want)
+	}
+
+	// a pod registering the name again replaces the records, dropping the AAAA it no longer has
+	if _, err := r.Register("web", []string{"52.1.2.3"}); err != nil {
+		t.Fatal(err)
+	}
+	want = []Record{{Type: "A", TTL: 60, Values: []string{"52.1.2.3"}}}
+	if records, _ := fake.Records(fqdn); !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %+v, want %+v", records, want)
+	}
+
+	if err := r.Unregister(fqdn, []string{"52.1.2.3", "10.0.0.5"}); err != nil {
+		t.Fatal(err)
+	}
+	if records, _ := fake.Records(fqdn); len(records) != 0 {
+		t.Errorf("records after Unregister = %+v", records)
+	}
+	if err := r.Unregister(fqdn, []string{"52.1.2.3"}); err != nil {
+		t.Errorf("Unregister of a name without records: %v", err)
+	}
+
+	if _, err := r.Register("web", nil); err == nil {
+		t.Errorf("Register without addresses succeeded")
+	}
+}
+
+func TestRegistryTakeover(t *testing.T) {
+	fake := NewFake("example.com.")
+	r := NewRegistry(fake, 60)
+
+	old := []string{"10.0.0.5", "2600:1f14::5"}
+	fqdn, err := r.Register("web", old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a new pod takes the name over before the old one is removed
+	if _, err := r.Register("web", []string{"10.0.0.6"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Unregister(fqdn, old); err != nil {
+		t.Fatal(err)
+	}
+	want := []Record{{Type: "A", TTL: 60, Values: []string{"10.0.0.6"}}}
+	if records, _ := fake.Records(fqdn); !reflect.DeepEqual(records, want) {
+		t.Errorf("records after the old pod unregistered = %+v, want %+v", records, want)
+	}
+
+	// only the sets that point at the pod alone are its
+	fake.Change(fqdn, want, []Record{{Type: "A", TTL: 60, Values: []string{"10.0.0.6", "10.0.0.7"}}, {Type: "AAAA", TTL: 60, Values: []string{"2600:1f14::6"}}})
+	if err := r.Unregister(fqdn, []string{"10.0.0.6", "2600:1f14:0:0::6"}); err != nil {
+		t.Fatal(err)
+	}
+	want = []Record{{Type: "A", TTL: 60, Values: []string{"10.0.0.6", "10.0.0.7"}}}
+	if records, _ := fake.Records(fqdn); !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %+v, want %+v", records, want)
+	}
+}
+
+func TestRoute53Change(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+			t.Errorf("%v %v isn't signed", req.Method, req.URL)
+		}
+
+		switch {
+		case req.Method == "GET" && req.URL.Path == "/2013-04-01/hostedzone/Z123":
+			w.Write([]byte(`<GetHostedZoneResponse><HostedZone><Id>/hostedzone/Z123</Id><Name>example.com.</Name></HostedZone></GetHostedZoneResponse>`))
+		case req.Method == "GET" && req.URL.Path == "/2013-04-01/hostedzone/Z123/rrset":
+			w.Write([]byte(`<ListResourceRecordSetsResponse><ResourceRecordSets>
+<ResourceRecordSet><Name>web.example.com.</Name><Type>A</Type><TTL>300</TTL><ResourceRecords><ResourceRecord><Value>10.0.0.4</Value></ResourceRecord></ResourceRecords></ResourceRecordSet>
+<ResourceRecordSet><Name>web.example.com.</Name><Type>TXT</Type><TTL>300</TTL><ResourceRecords><ResourceRecord><Value>"owner"</Value></ResourceRecord></ResourceRecords></ResourceRecordSet>
+<ResourceRecordSet><Name>www.example.com.</Name><Type>A</Type><TTL>300</TTL><ResourceRecords><ResourceRecord><Value>10.0.0.9</Value></ResourceRecord></ResourceRecords></ResourceRecordSet>
+</ResourceRecordSets></ListResourceRecordSetsResponse>`))
+		case req.Method == "POST" && req.URL.Path == "/2013-04-01/hostedzone/Z123/rrset":
+			data, _ := ioutil.ReadAll(req.Body)
+			body = string(data)
+			w.Write([]byte(`<ChangeResourceRecordSetsResponse><ChangeInfo><Status>PENDING</Status></ChangeInfo></ChangeResourceRecordSetsResponse>`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`<ErrorResponse><Error><Code>InvalidInput</Code><Message>unexpected request</Message></Error></ErrorResponse>`))
+		}
+	}))
+	defer server.Close()
+
+	backend, err := newRoute53(server.URL, credentials.NewStaticCredentials("id", "secret", ""), "/hostedzone/Z123", server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backend.Zone() != "example.com." {
+		t.Errorf("Zone() = %v, want example.com.", backend.Zone())
+	}
+
+	if _, err := NewRegistry(backend, 60).Register("web", []string{"10.0.0.5"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`<ChangeResourceRecordSetsRequest xmlns="https://route53.amazonaws.com/doc/2013-04-01/">`,
+		`<Change><Action>DELETE</Action><ResourceRecordSet><Name>web.example.com.</Name><Type>A</Type><TTL>300</TTL><ResourceRecords><ResourceRecord><Value>10.0.0.4</Value></ResourceRecord></ResourceRecords></ResourceRecordSet></Change>`,
+		`<Change><Action>CREATE</Action><ResourceRecordSet><Name>web.example.com.</Name><Type>A</Type><TTL>60</TTL><ResourceRecords><ResourceRecord><Value>10.0.0.5</Value></ResourceRecord></ResourceRecords></ResourceRecordSet></Change>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %v in:\n%v", want, body)
+		}
+	}
+	if strings.Contains(body, "TXT") || strings.Contains(body, "www") {
+		t.Errorf("change of other records in:\n%v", body)
+	}
+}
@@ -0,0 +1,70 @@
+package dns
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Fake is a Backend that keeps its zone in memory
+type Fake struct {
+	zone    string
+	lock    sync.Mutex
+	records map[string][]Record
+}
+
+func NewFake(zone string) *Fake {
+	return &Fake{zone: zone, records: make(map[string][]Record)}
+}
+
+func (f *Fake) Zone() string {
+	return f.zone
+}
+
+func (f *Fake) Records(name string) ([]Record, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return append([]Record{}, f.records[name]...), nil
+}
+
+// Change fails without changing anything when a record set in del isn't in the zone as it is, like the clouds do
+func (f *Fake) Change(name string, del []Record, add []Record) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	kept := []Record{}
+	for _, record := range f.records[name] {
+		if !containsRecord(del, record) {
+			kept = append(kept, record)
+		}
+	}
+	if len(kept)+len(del) != len(f.records[name]) {
+		return fmt.Errorf("%v doesn't have the records to delete", name)
+	}
+
+	for _, record := range add {
+		for _, k := range kept {
+			if k.Type == record.Type {
+				return fmt.Errorf("%v already has a %v record", name, record.Type)
+			}
+		}
+		kept = append(kept, record)
+	}
+
+	if len(kept) == 0 {
+		delete(f.records, name)
+	} else {
+		f.records[name] = kept
+	}
+
+	return nil
+}
+
+func containsRecord(records []Record, record Record) bool {
+	for _, r := range records {
+		if r.Type == record.Type && r.TTL == record.TTL && fmt.Sprint(r.Values) == fmt.Sprint(record.Values) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+package dns
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+)
+
+// the vendored aws sdk has no route53 client, so its few calls are made here
+const (
+	route53Endpoint  = "https://route53.amazonaws.com"
+	route53Version   = "2013-04-01"
+	route53Namespace = "https://route53.amazonaws.com/doc/2013-04-01/"
+	// route53 is global, its requests are signed for us-east-1
+	route53Region = "us-east-1"
+)
+
+type route53 struct {
+	endpoint string
+	zoneId   string
+	zone     string
+	client   *http.Client
+	signer   *v4.Signer
+}
+
+type route53RecordSet struct {
+	Name            string              `xml:"Name"`
+	Type            string              `xml:"Type"`
+	TTL             int64               `xml:"TTL"`
+	ResourceRecords []route53RecordData `xml:"ResourceRecords>ResourceRecord"`
+}
+
+type route53RecordData struct {
+	Value string `xml:"Value"`
+}
+
+type route53Change struct {
+	Action            string           `xml:"Action"`
+	ResourceRecordSet route53RecordSet `xml:"ResourceRecordSet"`
+}
+
+type route53ChangeRequest struct {
+	XMLName xml.Name        `xml:"ChangeResourceRecordSetsRequest"`
+	Xmlns   string          `xml:"xmlns,attr"`
+	Changes []route53Change `xml:"ChangeBatch>Changes>Change"`
+}
+
+type route53ListResponse struct {
+	RecordSets []route53RecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+}
+
+type route53ZoneResponse struct {
+	Name string `xml:"HostedZone>Name"`
+}
+
+type route53Error struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// NewRoute53 returns the Route53 hosted zone hostedZone, reached with sess' credentials
+func NewRoute53(sess *session.Session, hostedZone string) (Backend, error) {
+	client := sess.Config.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	return newRoute53(route53Endpoint, sess.Config.Credentials, hostedZone, client)
+}
+
+func newRoute53(endpoint string, creds *credentials.Credentials, hostedZone string, client *http.Client) (*route53, error) {
+	r := &route53{
+		endpoint: endpoint,
+		zoneId:   strings.TrimPrefix(hostedZone, "/hostedzone/"),
+		client:   client,
+		signer:   v4.NewSigner(creds),
+	}
+
+	resp := &route53ZoneResponse{}
+	if err := r.do("GET", "hostedzone/"+r.zoneId, nil, resp); err != nil {
+		return nil, fmt.Errorf("couldn't get hosted zone %v: %v", hostedZone, err)
+	}
+	r.zone = strings.ToLower(resp.Name)
+	if !strings.HasSuffix(r.zone, ".") {
+		r.zone += "."
+	}
+
+	return r, nil
+}
+
+func (r *route53) Zone() string {
+	return r.zone
+}
+
+func (r *route53) Records(name string) ([]Record, error) {
+	query := url.Values{}
+	query.Set("name", name)
+	query.Set("maxitems", "10")
+
+	resp := &route53ListResponse{}
+	if err := r.do("GET", "hostedzone/"+r.zoneId+"/rrset?"+query.Encode(), nil, resp); err != nil {
+		return nil, err
+	}
+
+	// the list starts at name, and goes on to the names after it
+	records := []Record{}
+	for _, set := range resp.RecordSets {
+		if strings.ToLower(set.Name) != name || (set.Type != "A" && set.Type != "AAAA") || len(set.ResourceRecords) == 0 {
+			continue
+		}
+		record := Record{Type: set.Type, TTL: set.TTL}
+		for _, data := range set.ResourceRecords {
+			record.Values = append(record.Values, data.Value)
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func (r *route53) Change(name string, del []Record, add []Record) error {
+	req := &route53ChangeRequest{Xmlns: route53Namespace}
+	for _, record := range del {
+		req.Changes = append(req.Changes, route53Change{Action: "DELETE", ResourceRecordSet: route53Set(name, record)})
+	}
+	for _, record := range add {
+		req.Changes = append(req.Changes, route53Change{Action: "CREATE", ResourceRecordSet: route53Set(name, record)})
+	}
+	if len(req.Changes) == 0 {
+		return nil
+	}
+
+	body, err := xml.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	return r.do("POST", "hostedzone/"+r.zoneId+"/rrset", append([]byte(xml.Header), body...), nil)
+}
+
+func route53Set(name string, record Record) route53RecordSet {
+	set := route53RecordSet{Name: name, Type: record.Type, TTL: record.TTL}
+	for _, value := range record.Values {
+		set.ResourceRecords = append(set.ResourceRecords, route53RecordData{Value: value})
+	}
+	return set
+}
+
+// do makes a signed request of the route53 api at path, decoding the response into out if it isn't nil
+func (r *route53) do(method string, path string, body []byte, out interface{}) error {
+	req, err := http.NewRequest(method, r.endpoint+"/"+route53Version+"/"+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/xml")
+	}
+
+	if _, err := r.signer.Sign(req, bytes.NewReader(body), "route53", route53Region, time.Now()); err != nil {
+		return fmt.Errorf("couldn't sign request: %v", err)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		apiErr := &route53Error{}
+		if xml.Unmarshal(data, apiErr) == nil && apiErr.Code != "" {
+			return fmt.Errorf("%v: %v", apiErr.Code, apiErr.Message)
+		}
+		return fmt.Errorf("%v %v: %v", method, path, resp.Status)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return xml.Unmarshal(data, out)
+}
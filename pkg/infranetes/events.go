@@ -0,0 +1,123 @@
+package infranetes
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+
+	icommon "github.com/apporbit/infranetes/pkg/common"
+	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+const watchRetryInterval = 5 * time.Second
+
+// watchContainers keeps podData's container cache current from its vmserver's event stream.  It is safe to call
+// multiple times, only the first call for a booted pod starts a watch.
+func (m *Manager) watchContainers(podData *common.PodData) {
+	podData.RLock()
+	booted := podData.Booted && podData.Client != nil
+	hasEvents := podData.HasFeature(icommon.FeatureEvents)
+	podData.RUnlock()
+
+	if !booted || !hasEvents {
+		return
+	}
+
+	ctx, ok := podData.Containers.StartWatch()
+	if !ok {
+		return
+	}
+
+	glog.Infof("watchContainers: starting container watch for %v", podData.Id)
+
+	go func() {
+		for {
+			err := m.syncContainers(ctx, podData)
+			podData.Containers.Invalidate()
+
+			if ctx.Err() != nil {
+				glog.Infof("watchContainers: container watch for %v stopped", podData.Id)
+				return
+			}
+
+			glog.Warningf("watchContainers: container watch for %v failed: %v, retrying in %v", podData.Id, err, watchRetryInterval)
+			time.Sleep(watchRetryInterval)
+		}
+	}()
+}
+
+// syncContainers seeds the cache from the vmserver and then applies events until the stream fails
+func (m *Manager) syncContainers(ctx context.Context, podData *common.PodData) error {
+	podData.RLock()
+	client := podData.Client
+	podData.RUnlock()
+
+	if client == nil {
+		return nil
+	}
+
+	// start watching before listing, so nothing that changes while we seed is missed.  That's only so once the
+	// vmserver has subscribed to its events, which it says with a SYNCED event.
+	stream, err := client.WatchContainerEvents(ctx)
+	if err != nil {
+		return err
+	}
+
+	event, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if event.Type != icommon.ContainerEventType_SYNCED {
+		return fmt.Errorf("watch started with a %v event for %v", event.Type, event.ContainerID)
+	}
+
+	listResp, err := client.ListContainers(&kubeapi.ListContainersRequest{})
+	if err != nil {
+		return err
+	}
+
+	statuses := []*kubeapi.ContainerStatus{}
+	for _, cont := range listResp.Containers {
+		statusResp, err := client.ContainerStatus(&kubeapi.ContainerStatusRequest{ContainerId: cont.Id})
+		if err != nil {
+			return err
+		}
+		statuses = append(statuses, statusResp.Status)
+	}
+
+	podData.Containers.Reset(statuses)
+	glog.Infof("syncContainers: cached %d containers for %v", len(statuses), podData.Id)
+
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		glog.V(2).Infof("syncContainers: %v: %v event for %v", podData.Id, event.Type, event.ContainerID)
+		podData.Containers.HandleEvent(event)
+	}
+}
+
+// refreshContainer updates a single cache entry after the manager changed the container's state itself, unless an
+// event changed it again while its status was fetched
+func refreshContainer(podData *common.PodData, client common.Client, id string) {
+	if !podData.Containers.Synced() {
+		return
+	}
+
+	version := podData.Containers.Version()
+	resp, err := client.ContainerStatus(&kubeapi.ContainerStatusRequest{ContainerId: id})
+	if err != nil {
+		glog.Warningf("refreshContainer: couldn't get status for %v: %v", id, err)
+		return
+	}
+
+	if !podData.Containers.Refresh(resp.Status, version) {
+		glog.V(2).Infof("refreshContainer: %v changed again while its status was fetched", id)
+	}
+}
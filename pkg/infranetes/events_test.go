@@ -0,0 +1,74 @@
+package infranetes
+
+import (
+	"encoding/json"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	icommon "github.com/apporbit/infranetes/pkg/common"
+	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+// eventsClient is a vmserver whose container stops while its status is being fetched, and whose event stream sends
+// events
+type eventsClient struct {
+	common.Client
+
+	podData *common.PodData
+	events  []*icommon.ContainerEvent
+}
+
+func (c *eventsClient) ContainerStatus(req *kubeapi.ContainerStatusRequest) (*kubeapi.ContainerStatusResponse, error) {
+	status := &kubeapi.ContainerStatus{Id: req.ContainerId, State: kubeapi.ContainerState_CONTAINER_EXITED}
+	data, _ := json.Marshal(status)
+	c.podData.Containers.HandleEvent(&icommon.ContainerEvent{ContainerID: req.ContainerId, Type: icommon.ContainerEventType_STOPPED, Status: data})
+
+	return &kubeapi.ContainerStatusResponse{Status: &kubeapi.ContainerStatus{Id: req.ContainerId, State: kubeapi.ContainerState_CONTAINER_RUNNING}}, nil
+}
+
+func (c *eventsClient) WatchContainerEvents(ctx context.Context) (common.ContainerEventStream, error) {
+	return c, nil
+}
+
+func (c *eventsClient) Recv() (*icommon.ContainerEvent, error) {
+	event := c.events[0]
+	c.events = c.events[1:]
+	return event, nil
+}
+
+func TestRefreshContainerKeepsNewerEvents(t *testing.T) {
+	fakeClient, _ := common.CreateFakeClient()
+	client := &eventsClient{Client: fakeClient}
+
+	linux := &kubeapi.LinuxPodSandboxConfig{SecurityContext: &kubeapi.LinuxSandboxSecurityContext{}}
+	podData := common.NewPodData(nil, "pod", &kubeapi.PodSandboxMetadata{Name: "pod"}, nil, nil, "10.0.0.1", linux, client, true, nil)
+	client.podData = podData
+
+	podData.Containers.Reset([]*kubeapi.ContainerStatus{{Id: "app", State: kubeapi.ContainerState_CONTAINER_CREATED}})
+
+	// the status fetched after starting it is older than the event saying it stopped
+	refreshContainer(podData, client, "app")
+	if status, ok := podData.Containers.Status("app"); !ok || status.State != kubeapi.ContainerState_CONTAINER_EXITED {
+		t.Errorf("cached %v after it stopped", status)
+	}
+}
+
+func TestSyncContainersWaitsForSync(t *testing.T) {
+	fakeClient, _ := common.CreateFakeClient()
+	client := &eventsClient{Client: fakeClient, events: []*icommon.ContainerEvent{{ContainerID: "app", Type: icommon.ContainerEventType_STARTED}}}
+
+	linux := &kubeapi.LinuxPodSandboxConfig{SecurityContext: &kubeapi.LinuxSandboxSecurityContext{}}
+	podData := common.NewPodData(nil, "pod", &kubeapi.PodSandboxMetadata{Name: "pod"}, nil, nil, "10.0.0.1", linux, client, true, nil)
+
+	// a watch the vmserver didn't say it subscribed is of no use for seeding the cache
+	m := &Manager{}
+	if err := m.syncContainers(context.Background(), podData); err == nil {
+		t.Errorf("synced from a watch without a SYNCED event")
+	}
+	if podData.Containers.Synced() {
+		t.Errorf("cache was seeded")
+	}
+}
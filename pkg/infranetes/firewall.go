@@ -0,0 +1,58 @@
+package infranetes
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/apporbit/infranetes/cmd/infranetes/flags"
+	"github.com/apporbit/infranetes/pkg/infranetes/provider"
+	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
+)
+
+// watchFirewallPolicy rereads -firewall-policy and brings the pods' firewalls up to date with it.  The pods found at
+// startup are brought up to date right away, as the policy may have changed while infranetes wasn't running.
+func (m *Manager) watchFirewallPolicy(period time.Duration) {
+	m.syncFirewalls()
+
+	for range time.Tick(period) {
+		policy, err := common.LoadFirewallPolicy(*flags.FirewallPolicy)
+		if err != nil {
+			glog.Warningf("watchFirewallPolicy: keeping the current policy: %v", err)
+		} else {
+			common.SetFirewallPolicy(policy)
+		}
+
+		m.syncFirewalls()
+	}
+}
+
+// syncFirewalls updates the firewalls of the booted pods that don't let in what the policy says.  Pods that fail keep
+// their old rules, so they're retried next time.
+func (m *Manager) syncFirewalls() {
+	fp := m.podProvider.(provider.FirewallProvider)
+
+	for id, podData := range m.copyVMMap() {
+		podData.RLock()
+		booted := podData.Booted
+		want := common.PodFirewall(podData.Metadata.GetNamespace(), podData.Labels)
+		have := podData.Firewall
+		podData.RUnlock()
+
+		if !booted || (have != nil && reflect.DeepEqual(want, have)) {
+			continue
+		}
+
+		if err := fp.UpdateFirewall(podData, want); err != nil {
+			glog.Warningf("syncFirewalls: couldn't update %v's firewall: %v", id, err)
+			continue
+		}
+
+		glog.Infof("syncFirewalls: %v's firewall lets in %v", id, want)
+
+		podData.Lock()
+		podData.Firewall = want
+		podData.Unlock()
+	}
+}
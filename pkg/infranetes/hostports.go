@@ -0,0 +1,58 @@
+package infranetes
+
+import (
+	"github.com/golang/glog"
+
+	"github.com/apporbit/infranetes/cmd/infranetes/flags"
+	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
+)
+
+// setupHostPorts makes the pod's host ports reachable the way its annotations or -hostport-mode ask.  In firewall
+// mode the pod provider opens them on the VM when it boots it, here the VM is only told to send them to the container
+// ports.
+func (m *Manager) setupHostPorts(podData *common.PodData) {
+	if len(podData.PortMappings) == 0 {
+		return
+	}
+
+	mode, err := common.HostPortMode(podData.Annotations)
+	if err != nil {
+		glog.Warningf("setupHostPorts: %v: %v", podData.Id, err)
+		return
+	}
+
+	switch mode {
+	case common.HostPortNode:
+		if err := common.ForwardHostPorts(podData.Id, podData.Ip, podData.PortMappings); err != nil {
+			glog.Warningf("setupHostPorts: couldn't forward %v's host ports: %v", podData.Id, err)
+		}
+	case common.HostPortFirewall:
+		if !podData.Booted || podData.Client == nil {
+			return
+		}
+		if err := podData.Client.SetPortMappings(podData.PortMappings); err != nil {
+			glog.Warningf("setupHostPorts: couldn't map %v's host ports: %v", podData.Id, err)
+		}
+	}
+}
+
+// resumeHostPorts forwards the host ports of the pods found at startup again, and drops the forwards of pods that
+// went away while infranetes wasn't running
+func (m *Manager) resumeHostPorts() {
+	forwarded := false
+
+	for _, podData := range m.copyVMMap() {
+		podData.RLock()
+		if mode, err := common.HostPortMode(podData.Annotations); err == nil && mode == common.HostPortNode && len(podData.PortMappings) > 0 {
+			m.setupHostPorts(podData)
+			forwarded = true
+		}
+		podData.RUnlock()
+	}
+
+	if !forwarded && *flags.HostPortMode == common.HostPortNode {
+		if err := common.SyncHostPorts(); err != nil {
+			glog.Warningf("resumeHostPorts: %v", err)
+		}
+	}
+}
@@ -9,6 +9,8 @@ import (
 
 	"github.com/docker/docker/pkg/mount"
 
+	"github.com/apporbit/infranetes/cmd/infranetes/flags"
+	icommon "github.com/apporbit/infranetes/pkg/common"
 	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
 
 	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
@@ -30,6 +32,47 @@ func (m *Manager) importSandboxes() {
 
 	for _, podData := range podDatas {
 		m.vmMap[podData.Id] = podData
+		go m.watchContainers(podData)
+	}
+
+	if common.OverlayEnabled() {
+		common.ReconcileOverlay()
+	}
+}
+
+// resumeLogs restarts log streaming for containers that were being logged when infranetes last exited
+func (m *Manager) resumeLogs() {
+	cursors, err := common.ListLogCursors(*flags.LogStateDir)
+	if err != nil {
+		glog.Warningf("resumeLogs: couldn't list log cursors: %v", err)
+		return
+	}
+
+	for _, cursor := range cursors {
+		podId, _, err := icommon.ParseContainer(cursor.ContainerID)
+		if err != nil {
+			glog.Warningf("resumeLogs: bad container id in cursor: %v", err)
+			continue
+		}
+
+		podData, err := m.getPodData(podId)
+		if err != nil {
+			glog.Infof("resumeLogs: pod for %v is gone, dropping its cursor", cursor.ContainerID)
+			common.RemoveLogCursor(*flags.LogStateDir, cursor.ContainerID)
+			continue
+		}
+
+		podData.RLock()
+		client := podData.Client
+		podData.RUnlock()
+
+		if client == nil || !podData.HasFeature(icommon.FeatureLogs) {
+			continue
+		}
+
+		glog.Infof("resumeLogs: resuming logs for %v at offset %v", cursor.ContainerID, cursor.Offset)
+		podData.AddContLogPath(cursor.ContainerID, cursor.Path)
+		go client.SaveLogs(cursor.ContainerID, cursor.Path)
 	}
 }
 
@@ -46,6 +89,11 @@ func (m *Manager) createSandbox(req *kubeapi.RunPodSandboxRequest) (*kubeapi.Run
 		m.vmMap[podData.Id] = podData
 
 		resp.PodSandboxId = podData.Id
+
+		podData.PortMappings = common.HostPorts(req.Config)
+		m.setupHostPorts(podData)
+
+		go m.watchContainers(podData)
 	}
 
 	return resp, err
@@ -61,6 +109,8 @@ func (m *Manager) stopSandbox(req *kubeapi.StopPodSandboxRequest) (*kubeapi.Stop
 		return nil, fmt.Errorf(msg)
 	}
 
+	m.drainLoadBalancer(podData)
+
 	podData.Lock()
 	defer podData.Unlock()
 
@@ -72,6 +122,9 @@ func (m *Manager) stopSandbox(req *kubeapi.StopPodSandboxRequest) (*kubeapi.Stop
 		return nil, fmt.Errorf(msg)
 	}
 
+	// it's out of its load balancer now
+	podData.LBHealth = ""
+
 	contResp, err := client.ListContainers(&kubeapi.ListContainersRequest{})
 	if err != nil {
 		msg := fmt.Sprintf("stopSandbox: ListContainers failed for %s: %v", podId, err)
@@ -91,6 +144,21 @@ func (m *Manager) stopSandbox(req *kubeapi.StopPodSandboxRequest) (*kubeapi.Stop
 		}
 	}
 
+	// nothing in the VM needs services anymore
+	if podData.Booted && common.ParseCommonAnnotations(podData.Annotations).StartProxy && podData.HasFeature(icommon.FeatureProxyControl) {
+		if err := client.StopProxy(); err != nil {
+			glog.Warningf("stopSandbox: couldn't stop kube-proxy for %s: %v", podId, err)
+		}
+	}
+	podData.ProxyState = ""
+
+	// the VM is going away, so its pod ip goes back to the pod network
+	if common.CNIEnabled() && podData.Booted {
+		if err := client.TeardownCNI(); err != nil {
+			glog.Warningf("stopSandbox: couldn't leave the pod network for %s: %v", podId, err)
+		}
+	}
+
 	podData.StopPod()
 	m.podProvider.StopPodSandbox(podData)
 
@@ -119,6 +187,7 @@ func (m *Manager) removePodSandbox(req *kubeapi.RemovePodSandboxRequest) error {
 
 	podData.RemovePod()
 	m.podProvider.RemovePodSandbox(podData)
+	common.StopForwardingHostPorts(sandboxId)
 
 	m.vmMapLock.Lock()
 	defer m.vmMapLock.Unlock()
@@ -188,7 +257,17 @@ func (m *Manager) preCreateContainer(data *common.PodData, req *kubeapi.CreateCo
 	data.RLock()
 	defer data.RUnlock()
 
-	return m.podProvider.PreCreateContainer(data, req, m.contProvider.ImageStatus)
+	booted := data.Booted
+	if err := m.podProvider.PreCreateContainer(data, req, m.contProvider.ImageStatus); err != nil {
+		return err
+	}
+
+	// pods whose VMs boot with their first container only get their host ports now
+	if !booted && data.Booted {
+		m.setupHostPorts(data)
+	}
+
+	return nil
 }
 
 func isReadOnly(opts string) bool {
@@ -209,6 +288,9 @@ func (m *Manager) createContainer(podData *common.PodData, req *kubeapi.CreateCo
 		return nil, fmt.Errorf("CreateContainer: %v", err)
 	}
 
+	// VMs that boot lazily only get a client in preCreateContainer
+	m.watchContainers(podData)
+
 	podData.RLock()
 	defer podData.RUnlock()
 
@@ -260,7 +342,27 @@ func (m *Manager) createContainer(podData *common.PodData, req *kubeapi.CreateCo
 		}
 	}
 
-	return client.CreateContainer(req)
+	var resp *kubeapi.CreateContainerResponse
+	if auth := m.getImageAuth(req.GetConfig().GetImage().GetImage()); auth != nil && podData.HasFeature(icommon.FeatureRegistryAuth) {
+		resp, err = client.CreateContainerWithAuth(req, auth)
+	} else {
+		if auth != nil {
+			glog.Warningf("CreateContainer: agent can't pull with registry auth, %v may fail to pull", req.GetConfig().GetImage().GetImage())
+		}
+		resp, err = client.CreateContainer(req)
+	}
+	if err == nil {
+		refreshContainer(podData, client, resp.ContainerId)
+	}
+
+	return resp, err
+}
+
+func (m *Manager) getImageAuth(image string) *kubeapi.AuthConfig {
+	m.imageAuthLock.Lock()
+	defer m.imageAuthLock.Unlock()
+
+	return m.imageAuth[image]
 }
 
 func isFlexVolMnt(mount string, mounts map[string]string) (string, bool) {
@@ -312,6 +414,10 @@ func listSandbox(req *kubeapi.ListContainersRequest, podData *common.PodData) ([
 		return nil, false
 	}
 
+	if podData.Containers.Synced() {
+		return podData.Containers.List(podData.Id, req.Filter), true
+	}
+
 	resp, err := client.ListContainers(req)
 	if err != nil {
 		glog.Warningf("listContainers: grpc ListContainers failed: %v", err)
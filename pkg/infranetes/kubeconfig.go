@@ -0,0 +1,73 @@
+package infranetes
+
+import (
+	"crypto/sha256"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/apporbit/infranetes/cmd/infranetes/flags"
+	icommon "github.com/apporbit/infranetes/pkg/common"
+	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
+)
+
+// watchKubeconfig pushes the kubeconfig to the kube-proxy and network policies of every VM whenever its credentials
+// rotate
+func (m *Manager) watchKubeconfig(period time.Duration) {
+	// the version of the kubeconfig each pod's kube-proxy was last given
+	pushed := make(map[string][sha256.Size]byte)
+
+	for range time.Tick(period) {
+		data, err := common.LoadProxyKubeconfig(*flags.Kubeconfig)
+		if err != nil {
+			glog.Warningf("watchKubeconfig: %v", err)
+			continue
+		}
+
+		m.pushKubeconfig(data, pushed)
+	}
+}
+
+// pushKubeconfig gives data to the kube-proxies that don't have it yet.  Pods that fail keep their old version in
+// pushed, so they're retried next time.
+func (m *Manager) pushKubeconfig(data []byte, pushed map[string][sha256.Size]byte) {
+	sum := sha256.Sum256(data)
+	pods := m.copyVMMap()
+
+	for id := range pushed {
+		if _, ok := pods[id]; !ok {
+			delete(pushed, id)
+		}
+	}
+
+	for id, podData := range pods {
+		if pushed[id] == sum {
+			continue
+		}
+
+		podData.RLock()
+		client := podData.Client
+		booted := podData.Booted
+		hasFeature := podData.HasFeature(icommon.FeatureProxyKubeconfig)
+		startProxy := common.ParseCommonAnnotations(podData.Annotations).StartProxy
+		podData.RUnlock()
+
+		// network policies are enforced with the same kubeconfig as kube-proxy's
+		if client == nil || !booted || (!startProxy && !*flags.NetworkPolicy) {
+			continue
+		}
+
+		if !hasFeature {
+			glog.V(1).Infof("pushKubeconfig: %v's vmserver can't update kube-proxy's kubeconfig", id)
+			pushed[id] = sum
+			continue
+		}
+
+		if err := client.UpdateProxyKubeconfig(data); err != nil {
+			glog.Warningf("pushKubeconfig: couldn't update kube-proxy's kubeconfig on %v: %v", id, err)
+			continue
+		}
+
+		pushed[id] = sum
+	}
+}
@@ -0,0 +1,63 @@
+package infranetes
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/apporbit/infranetes/pkg/infranetes/provider"
+	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+// watchLoadBalancers keeps the load balancer health of the pods registered in one up to date for their status
+func (m *Manager) watchLoadBalancers(lbp provider.LoadBalancerProvider, period time.Duration) {
+	for range time.Tick(period) {
+		for id, podData := range m.copyVMMap() {
+			podData.RLock()
+			booted := podData.Booted
+			state := podData.PodState
+			group := common.ParseCommonAnnotations(podData.Annotations).LBGroup
+			podData.RUnlock()
+
+			if !booted || group == "" || state != kubeapi.PodSandboxState_SANDBOX_READY {
+				continue
+			}
+
+			health, err := lbp.LoadBalancerHealth(podData)
+			if err != nil {
+				glog.Warningf("watchLoadBalancers: couldn't get %v's health in %v: %v", id, group, err)
+				health = "unknown"
+			}
+
+			// a pod stopped meanwhile is out of its load balancer
+			podData.Lock()
+			if podData.PodState == kubeapi.PodSandboxState_SANDBOX_READY {
+				if podData.LBHealth != health {
+					glog.Infof("watchLoadBalancers: %v is %v in %v", id, health, group)
+				}
+				podData.LBHealth = health
+			}
+			podData.Unlock()
+		}
+	}
+}
+
+// drainLoadBalancer takes a pod being stopped out of its load balancer before its containers are, so it can finish
+// the connections it has.  It's called without the pod's lock, which its status needs meanwhile, as draining can take
+// up to -lb-drain-timeout.
+func (m *Manager) drainLoadBalancer(podData *common.PodData) {
+	lbp, ok := m.podProvider.(provider.LoadBalancerProvider)
+	if !ok {
+		return
+	}
+
+	podData.RLock()
+	registered := podData.Booted && podData.Client != nil && common.ParseCommonAnnotations(podData.Annotations).LBGroup != ""
+	podData.RUnlock()
+
+	if registered {
+		lbp.DeregisterLoadBalancer(podData)
+	}
+}
@@ -0,0 +1,82 @@
+package infranetes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apporbit/infranetes/pkg/infranetes/provider"
+	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
+	"github.com/apporbit/infranetes/pkg/infranetes/provider/fake"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+// drainingProvider is a pod provider whose load balancer drains pods until released
+type drainingProvider struct {
+	provider.PodProvider
+
+	draining chan struct{}
+	release  chan struct{}
+}
+
+func (p *drainingProvider) DeregisterLoadBalancer(podData *common.PodData) {
+	close(p.draining)
+	<-p.release
+}
+
+func (p *drainingProvider) LoadBalancerHealth(podData *common.PodData) (string, error) {
+	return "healthy", nil
+}
+
+func TestStopSandboxDrainsUnlocked(t *testing.T) {
+	pods, err := fake.NewFakePodProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &drainingProvider{PodProvider: pods, draining: make(chan struct{}), release: make(chan struct{})}
+
+	client, _ := common.CreateFakeClient()
+	annotations := map[string]string{"infranetes.lb.group": "web"}
+	linux := &kubeapi.LinuxPodSandboxConfig{SecurityContext: &kubeapi.LinuxSandboxSecurityContext{}}
+	podData := common.NewPodData(nil, "pod", &kubeapi.PodSandboxMetadata{Name: "pod"}, annotations, nil, "10.0.0.1", linux, client, true, nil)
+	podData.LBHealth = "healthy"
+
+	m := &Manager{podProvider: p, vmMap: map[string]*common.PodData{"pod": podData}}
+
+	stopped := make(chan error)
+	go func() {
+		_, err := m.stopSandbox(&kubeapi.StopPodSandboxRequest{PodSandboxId: "pod"})
+		stopped <- err
+	}()
+
+	select {
+	case <-p.draining:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stopSandbox didn't drain the pod")
+	}
+
+	// the pod's status is still available while it drains
+	status := make(chan *kubeapi.PodSandboxStatus)
+	go func() {
+		podData.RLock()
+		defer podData.RUnlock()
+		status <- podData.PodStatus()
+	}()
+	select {
+	case s := <-status:
+		if s.State != kubeapi.PodSandboxState_SANDBOX_READY {
+			t.Errorf("draining pod is %v, want ready", s.State)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the pod's lock is held while it drains")
+	}
+
+	close(p.release)
+	if err := <-stopped; err != nil {
+		t.Fatal(err)
+	}
+
+	if podData.LBHealth != "" || podData.PodState != kubeapi.PodSandboxState_SANDBOX_NOTREADY {
+		t.Errorf("stopped pod is %v with load balancer health %q", podData.PodState, podData.LBHealth)
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 
+	"github.com/apporbit/infranetes/cmd/infranetes/flags"
 	icommon "github.com/apporbit/infranetes/pkg/common"
 	"github.com/apporbit/infranetes/pkg/infranetes/provider"
 	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
@@ -38,6 +39,10 @@ type Manager struct {
 	mountMap     map[string]string
 	mountMapLock sync.Mutex
 	volumeMap    map[string][]*types.Volume
+
+	// imageAuth keeps the credentials kubelet pulled each image with, so the VM can pull it with them too
+	imageAuth     map[string]*kubeapi.AuthConfig
+	imageAuthLock sync.Mutex
 }
 
 func NewInfranetesManager(podProvider provider.PodProvider, contProvider provider.ImageProvider) (*Manager, error) {
@@ -48,9 +53,66 @@ func NewInfranetesManager(podProvider provider.PodProvider, contProvider provide
 		vmMap:        make(map[string]*common.PodData),
 		volumeMap:    make(map[string][]*types.Volume),
 		mountMap:     make(map[string]string),
+		imageAuth:    make(map[string]*kubeapi.AuthConfig),
+	}
+
+	if *flags.Overlay != "" {
+		if err := common.SetupNodeOverlay(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, _, err := common.IPPolicies(nil); err != nil {
+		return nil, fmt.Errorf("invalid -pod-ip-policy or -agent-ip-policy: %v", err)
+	}
+
+	if _, err := common.HostPortMode(nil); err != nil {
+		return nil, fmt.Errorf("invalid -hostport-mode: %v", err)
+	}
+
+	if *flags.CNIConfDir != "" {
+		if *flags.Overlay != "" {
+			return nil, fmt.Errorf("-overlay and -cni-conf-dir both give pods their ips, only one can be set")
+		}
+		if err := common.LoadCNIConfig(); err != nil {
+			return nil, err
+		}
+	}
+
+	if common.FirewallPolicyEnabled() {
+		if _, ok := podProvider.(provider.FirewallProvider); !ok {
+			return nil, fmt.Errorf("-firewall-policy needs a pod provider that can give pods firewalls of their own")
+		}
+		policy, err := common.LoadFirewallPolicy(*flags.FirewallPolicy)
+		if err != nil {
+			return nil, err
+		}
+		common.SetFirewallPolicy(policy)
 	}
 
 	manager.importSandboxes()
+	manager.resumeLogs()
+	manager.resumeHostPorts()
+
+	if *flags.KubeconfigSyncPeriod > 0 {
+		go manager.watchKubeconfig(*flags.KubeconfigSyncPeriod)
+	}
+
+	if common.FirewallPolicyEnabled() {
+		go manager.watchFirewallPolicy(*flags.FirewallPolicySyncPeriod)
+	}
+
+	if *flags.ProxyStatusPeriod > 0 {
+		go manager.watchProxies(*flags.ProxyStatusPeriod)
+	}
+
+	if *flags.NetworkPolicy && *flags.NetworkPolicyStatusPeriod > 0 {
+		go manager.watchNetworkPolicies(*flags.NetworkPolicyStatusPeriod)
+	}
+
+	if lbp, ok := podProvider.(provider.LoadBalancerProvider); ok && *flags.LBHealthPeriod > 0 {
+		go manager.watchLoadBalancers(lbp, *flags.LBHealthPeriod)
+	}
 
 	manager.registerServer()
 
@@ -80,6 +142,7 @@ func (s *Manager) registerServer() {
 	kubeapi.RegisterImageServiceServer(s.server, s)
 	icommon.RegisterMetricsServer(s.server, s)
 	icommon.RegisterMountsServer(s.server, s)
+	icommon.RegisterContainerLogsServer(s.server, s)
 
 }
 
@@ -199,7 +262,7 @@ func (m *Manager) StartContainer(ctx context.Context, req *kubeapi.StartContaine
 	cookie := rand.Int()
 	glog.Infof("%d: StartContainer: req = %+v", cookie, req)
 
-	podId, contId, err := icommon.ParseContainer(req.GetContainerId())
+	podId, _, err := icommon.ParseContainer(req.GetContainerId())
 	if err != nil {
 		return nil, fmt.Errorf("StartContainer: failed: %v", err)
 	}
@@ -219,7 +282,12 @@ func (m *Manager) StartContainer(ctx context.Context, req *kubeapi.StartContaine
 	}
 
 	resp, err := client.StartContainer(req)
-	if err == nil { // start worked, start logging
+	if err == nil {
+		refreshContainer(podData, client, req.ContainerId)
+	}
+	if err == nil && !podData.HasFeature(icommon.FeatureLogs) {
+		glog.Infof("%d: StartContainer: %v agent can't stream logs, not saving them", cookie, podData.Capabilities.GetContainerProvider())
+	} else if err == nil { // start worked, start logging
 		go func() {
 			path, ok := podData.GetContLogPath(req.GetContainerId())
 			if !ok {
@@ -227,7 +295,7 @@ func (m *Manager) StartContainer(ctx context.Context, req *kubeapi.StartContaine
 				return
 			}
 
-			client.SaveLogs(contId, path)
+			client.SaveLogs(req.GetContainerId(), path)
 		}()
 	}
 
@@ -260,6 +328,9 @@ func (m *Manager) StopContainer(ctx context.Context, req *kubeapi.StopContainerR
 	}
 
 	resp, err := client.StopContainer(req)
+	if err == nil {
+		refreshContainer(podData, client, req.ContainerId)
+	}
 
 	glog.Infof("%d: StopContainer: resp = %+v, err = %v", cookie, resp, err)
 
@@ -290,6 +361,9 @@ func (m *Manager) RemoveContainer(ctx context.Context, req *kubeapi.RemoveContai
 	}
 
 	resp, err := client.RemoveContainer(req)
+	if err == nil {
+		podData.Containers.Delete(req.ContainerId)
+	}
 
 	glog.Infof("%d: RemoveContainer: resp = %+v, err = %v", cookie, resp, err)
 
@@ -330,6 +404,12 @@ func (m *Manager) ContainerStatus(ctx context.Context, req *kubeapi.ContainerSta
 		return nil, errors.New("CreateContainer: nil client, must be a removed pod sandbox?")
 	}
 
+	if status, ok := podData.Containers.Status(req.ContainerId); ok && podData.Containers.Synced() {
+		resp := &kubeapi.ContainerStatusResponse{Status: status}
+		glog.Infof("%d: ContainerStatus: resp = %+v (cached)", cookie, resp)
+		return resp, nil
+	}
+
 	resp, err := client.ContainerStatus(req)
 
 	glog.Infof("%d: ContainerStatus: resp = %+v, err = %v", cookie, resp, err)
@@ -387,6 +467,10 @@ func (m *Manager) Exec(ctx context.Context, req *kubeapi.ExecRequest) (*kubeapi.
 		return nil, errors.New("Exec: nil client, must be a removed pod sandbox?")
 	}
 
+	if !podData.HasFeature(icommon.FeatureExec) {
+		return nil, fmt.Errorf("Exec: %v agent doesn't support exec", podData.Capabilities.GetContainerProvider())
+	}
+
 	resp, err := client.Exec(req)
 
 	glog.Infof("Exec: resp = %+v, err = %v", resp, err)
@@ -416,6 +500,10 @@ func (m *Manager) Attach(ctx context.Context, req *kubeapi.AttachRequest) (*kube
 		return nil, errors.New("Attach: nil client, must be a removed pod sandbox?")
 	}
 
+	if !podData.HasFeature(icommon.FeatureAttach) {
+		return nil, fmt.Errorf("Attach: %v agent doesn't support attach", podData.Capabilities.GetContainerProvider())
+	}
+
 	resp, err := client.Attach(req)
 
 	glog.Infof("Attach: resp = %+v, err = %v", resp, err)
@@ -442,6 +530,10 @@ func (m *Manager) PortForward(ctx context.Context, req *kubeapi.PortForwardReque
 		return nil, errors.New("PortForward: nil client, must be a removed pod sandbox?")
 	}
 
+	if !podData.HasFeature(icommon.FeaturePortForward) {
+		return nil, fmt.Errorf("PortForward: %v agent doesn't support port forwarding", podData.Capabilities.GetContainerProvider())
+	}
+
 	resp, err := client.PortForward(req)
 
 	glog.Infof("Attach: resp = %+v, err = %v", resp, err)
@@ -498,10 +590,17 @@ func (m *Manager) ImageStatus(ctx context.Context, req *kubeapi.ImageStatusReque
 }
 
 func (m *Manager) PullImage(ctx context.Context, req *kubeapi.PullImageRequest) (*kubeapi.PullImageResponse, error) {
-	glog.Infof("PullImage: req = %+v", req)
+	// the request's auth must not end up in the log
+	glog.Infof("PullImage: image = %v, sandbox = %v, auth = %v", req.GetImage().GetImage(), req.GetSandboxConfig().GetMetadata().GetName(), req.Auth != nil)
 
 	resp, err := m.contProvider.PullImage(req)
 
+	if err == nil && req.Auth != nil {
+		m.imageAuthLock.Lock()
+		m.imageAuth[req.GetImage().GetImage()] = req.Auth
+		m.imageAuthLock.Unlock()
+	}
+
 	glog.Infof("PullImage: resp = %+v, err = %v", resp, err)
 
 	return resp, err
@@ -582,6 +681,17 @@ func (m *Manager) DelMount(ctx context.Context, req *icommon.DelMountRequest) (*
 	return &icommon.DelMountResponse{}, nil
 }
 
+// ReopenContainerLog is called after something outside of infranetes rotated a container's log
+func (m *Manager) ReopenContainerLog(ctx context.Context, req *icommon.ReopenContainerLogRequest) (*icommon.ReopenContainerLogResponse, error) {
+	glog.Infof("ReopenContainerLog: req = %+v", req)
+
+	if err := common.ReopenLogWriter(req.ContainerID); err != nil {
+		return nil, err
+	}
+
+	return &icommon.ReopenContainerLogResponse{}, nil
+}
+
 // TODO
 func (m *Manager) ContainerStats(ctx context.Context, req *kubeapi.ContainerStatsRequest) (*kubeapi.ContainerStatsResponse, error) {
 	return nil, fmt.Errorf("Not implemented")
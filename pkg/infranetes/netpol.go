@@ -0,0 +1,86 @@
+package infranetes
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+
+	icommon "github.com/apporbit/infranetes/pkg/common"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+// watchNetworkPolicies keeps whether each VM enforces its pod's NetworkPolicies up to date for the pod's status
+func (m *Manager) watchNetworkPolicies(period time.Duration) {
+	for range time.Tick(period) {
+		m.pollNetworkPolicies()
+	}
+}
+
+// pollNetworkPolicies asks the VMs of ready pods how they enforce their policies, and has those that don't enforce
+// them at all start again
+func (m *Manager) pollNetworkPolicies() {
+	for id, podData := range m.copyVMMap() {
+		podData.RLock()
+		client := podData.Client
+		booted := podData.Booted
+		state := podData.PodState
+		hasFeature := podData.HasFeature(icommon.FeatureNetworkPolicy)
+		podData.RUnlock()
+
+		if client == nil || !booted || !hasFeature || state != kubeapi.PodSandboxState_SANDBOX_READY {
+			continue
+		}
+
+		var summary string
+		status, err := client.NetworkPolicyStatus()
+		if err != nil {
+			glog.Warningf("pollNetworkPolicies: couldn't get %v's network policy status: %v", id, err)
+			summary = "unknown"
+		} else {
+			summary = networkPolicySummary(status)
+			if !status.Running {
+				glog.Infof("pollNetworkPolicies: %v doesn't enforce its network policies, starting them", id)
+				if err := client.StartNetworkPolicy(); err != nil {
+					glog.Warningf("pollNetworkPolicies: couldn't start %v's network policies: %v", id, err)
+				}
+			}
+		}
+
+		podData.Lock()
+		if podData.PodState == kubeapi.PodSandboxState_SANDBOX_READY {
+			if podData.NetPolicy != summary {
+				glog.Infof("pollNetworkPolicies: %v is %v", id, summary)
+			}
+			podData.NetPolicy = summary
+		}
+		podData.Unlock()
+	}
+}
+
+// networkPolicySummary is status in a word, followed by what the pod is isolated by or what went wrong
+func networkPolicySummary(status *icommon.NetworkPolicyStatusResponse) string {
+	switch {
+	case !status.Running:
+		return "stopped"
+	case status.Message != "":
+		return fmt.Sprintf("failing: %v", status.Message)
+	case status.LastSync == 0:
+		return "syncing"
+	}
+
+	isolated := []string{}
+	if status.IngressIsolated {
+		isolated = append(isolated, "ingress")
+	}
+	if status.EgressIsolated {
+		isolated = append(isolated, "egress")
+	}
+	if len(isolated) == 0 {
+		return "enforced: not isolated"
+	}
+
+	return fmt.Sprintf("enforced: %v isolated by %v", strings.Join(isolated, ","), strings.Join(status.Policies, ","))
+}
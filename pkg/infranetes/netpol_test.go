@@ -0,0 +1,71 @@
+package infranetes
+
+import (
+	"testing"
+
+	icommon "github.com/apporbit/infranetes/pkg/common"
+	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+// policyClient is a vmserver that reports status, and starts enforcing its policies when asked
+type policyClient struct {
+	common.Client
+
+	status  *icommon.NetworkPolicyStatusResponse
+	started int
+}
+
+func (c *policyClient) NetworkPolicyStatus() (*icommon.NetworkPolicyStatusResponse, error) {
+	return c.status, nil
+}
+
+func (c *policyClient) StartNetworkPolicy() error {
+	c.started++
+	c.status = &icommon.NetworkPolicyStatusResponse{Running: true}
+	return nil
+}
+
+func TestPollNetworkPolicies(t *testing.T) {
+	fake, _ := common.CreateFakeClient()
+	client := &policyClient{Client: fake, status: &icommon.NetworkPolicyStatusResponse{Message: "network policies aren't enforced"}}
+
+	linux := &kubeapi.LinuxPodSandboxConfig{SecurityContext: &kubeapi.LinuxSandboxSecurityContext{}}
+	podData := common.NewPodData(nil, "pod", &kubeapi.PodSandboxMetadata{Name: "pod"}, nil, nil, "10.0.0.1", linux, client, true, nil)
+	podData.Capabilities = &icommon.CapabilitiesResponse{Features: []string{icommon.FeatureNetworkPolicy}}
+
+	m := &Manager{vmMap: map[string]*common.PodData{"pod": podData}}
+
+	// a vmserver that doesn't enforce them is told to
+	m.pollNetworkPolicies()
+	if client.started != 1 || podData.NetPolicy != "stopped" {
+		t.Errorf("started %v times, status %q", client.started, podData.NetPolicy)
+	}
+
+	client.status = &icommon.NetworkPolicyStatusResponse{Running: true, IngressIsolated: true, Policies: []string{"default/web"}, LastSync: 1}
+	m.pollNetworkPolicies()
+	if client.started != 1 {
+		t.Errorf("restarted running network policies")
+	}
+	if got := podData.PodStatus().Annotations[common.NetworkPolicyAnnotation]; got != "enforced: ingress isolated by default/web" {
+		t.Errorf("status annotation = %q", got)
+	}
+}
+
+func TestNetworkPolicySummary(t *testing.T) {
+	for _, test := range []struct {
+		status *icommon.NetworkPolicyStatusResponse
+		want   string
+	}{
+		{&icommon.NetworkPolicyStatusResponse{}, "stopped"},
+		{&icommon.NetworkPolicyStatusResponse{Running: true}, "syncing"},
+		{&icommon.NetworkPolicyStatusResponse{Running: true, LastSync: 1, Message: "forbidden"}, "failing: forbidden"},
+		{&icommon.NetworkPolicyStatusResponse{Running: true, LastSync: 1}, "enforced: not isolated"},
+		{&icommon.NetworkPolicyStatusResponse{Running: true, LastSync: 1, IngressIsolated: true, EgressIsolated: true, Policies: []string{"a/x", "a/y"}}, "enforced: ingress,egress isolated by a/x,a/y"},
+	} {
+		if got := networkPolicySummary(test.status); got != test.want {
+			t.Errorf("networkPolicySummary(%+v) = %q, want %q", test.status, got, test.want)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -16,10 +17,10 @@ import (
 	"github.com/apcera/libretto/ssh"
 	awsvm "github.com/apcera/libretto/virtualmachine/aws"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
 
 	"github.com/apporbit/infranetes/cmd/infranetes/flags"
+	"github.com/apporbit/infranetes/pkg/dns"
 	"github.com/apporbit/infranetes/pkg/infranetes/provider"
 	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
 	"github.com/apporbit/infranetes/pkg/infranetes/types"
@@ -34,11 +35,25 @@ type podData struct {
 	attached    map[string]string
 	lock        sync.Mutex
 	volumes     []*types.Volume
+	// routedIp is the pod ip routed to the instance, when it isn't the instance's own
+	routedIp string
+	// hostPortGroup is the security group opening the pod's host ports on the instance
+	hostPortGroup string
+	// firewallGroup is the security group letting in what -firewall-policy selects the pod for
+	firewallGroup string
+	// dnsName is the name registered for the pod in the hosted zone
+	dnsName string
+	// lbTarget is the pod's registration in its infranetes.lb.group target group
+	lbTarget *lbTarget
+	// staticIP is the pod's infranetes.staticip elastic ip
+	staticIP *staticIP
 }
 
 type awsPodProvider struct {
 	config   *awsConfig
 	ipList   *utils.Deque
+	ipv6     bool          // set if pods get their ipv6 addresses from ec2 rather than CNI
+	dns      *dns.Registry // set if pods can have names in a hosted zone
 	imagePod bool
 	key      string
 }
@@ -108,9 +123,34 @@ func NewAWSPodProvider() (provider.PodProvider, error) {
 		ipList.Append(fmt.Sprint(*flags.IPBase + "." + strconv.Itoa(i)))
 	}
 
+	ipv6 := *flags.DualStack && *flags.CNIConfDir == ""
+	if ipv6 {
+		// ec2 picks the addresses, as only it knows which of the subnet's are free
+		if *flags.IPv6CIDR != "" {
+			return nil, fmt.Errorf("-ipv6-cidr: aws pods get their ipv6 addresses from their subnet's range")
+		}
+		cidr, err := findIPv6CIDR(&conf.Subnet)
+		if err != nil {
+			return nil, fmt.Errorf("-dual-stack: %v", err)
+		}
+		glog.Infof("NewAWSPodProvider: pod ipv6 addresses come from %v", cidr)
+	}
+
+	var registry *dns.Registry
+	if conf.HostedZone != "" {
+		backend, err := dns.NewRoute53(awsSession, conf.HostedZone)
+		if err != nil {
+			return nil, err
+		}
+		registry = dns.NewRegistry(backend, *flags.DNSTTL)
+		glog.Infof("NewAWSPodProvider: pods' dns names are in %v", backend.Zone())
+	}
+
 	return &awsPodProvider{
 		config: &conf,
 		ipList: ipList,
+		ipv6:   ipv6,
+		dns:    registry,
 		key:    string(rawKey),
 	}, nil
 }
@@ -121,18 +161,57 @@ func (*awsPodProvider) UpdatePodState(data *common.PodData) {
 	}
 }
 
-// FIXME: if steps fail, probably want to teardown VM
-func (p *awsPodProvider) bootSandbox(vm *awsvm.VM, config *kubeapi.PodSandboxConfig, name string, volumes []*types.Volume) (*common.PodData, error) {
+func (p *awsPodProvider) bootSandbox(vm *awsvm.VM, config *kubeapi.PodSandboxConfig, name string, volumes []*types.Volume) (_ *common.PodData, err error) {
 	// 1. Parse Annotations from PodSandboxConfig
 	cAnno := common.ParseCommonAnnotations(config.Annotations)
 
+	podPolicy, agentPolicy, err := common.IPPolicies(config.Annotations)
+	if err != nil {
+		return nil, fmt.Errorf("bootSandbox: %v", err)
+	}
+
+	hostPortMode, err := common.HostPortMode(config.Annotations)
+	if err != nil {
+		return nil, fmt.Errorf("bootSandbox: %v", err)
+	}
+
 	// 2. Boot VM
 	if err := vm.Provision(); err != nil {
 		return nil, fmt.Errorf("failed to provision vm: %v\n", err)
 	}
 
+	// A step that fails tears down what the ones before it set up, last first, ending with the VM
+	var rollback []func()
+	defer func() {
+		if err == nil {
+			return
+		}
+		glog.Warningf("bootSandbox: tearing down %v: %v", vm.InstanceID, err)
+		for i := len(rollback) - 1; i >= 0; i-- {
+			rollback[i]()
+		}
+	}()
+	rollback = append(rollback, func() {
+		if err := vm.Destroy(); err != nil {
+			glog.Warningf("bootSandbox: couldn't terminate %v: %v", vm.InstanceID, err)
+		}
+	})
+
 	vm.SetTag("infranetes", "true")
 
+	// The static ip goes on before the VM's addresses are read, so it's the public one among them
+	static, staticState := setStaticIP(vm.InstanceID, config.Annotations)
+	if static != nil {
+		rollback = append(rollback, func() {
+			if err := disassociateStaticIP(static); err != nil {
+				glog.Warningf("bootSandbox: %v", err)
+			}
+			if err := releaseStaticIP(static); err != nil {
+				glog.Warningf("bootSandbox: %v", err)
+			}
+		})
+	}
+
 	// 3. Extract IP Info
 	ips, err := vm.GetIPs()
 	if err != nil {
@@ -141,23 +220,48 @@ func (p *awsPodProvider) bootSandbox(vm *awsvm.VM, config *kubeapi.PodSandboxCon
 
 	glog.Infof("bootSandbox: ips = %v", ips)
 
-	// FIXME: Perhaps better way to choose public vs private ip
-	index := 1
-	podIp := ips[index].String()
+	addrs := common.CloudAddresses(ips[awsvm.PublicIP], ips[awsvm.PrivateIP])
 
-	glog.Infof("bootSandbox: podIp = %v", podIp)
+	agentIp, err := agentPolicy.Select(addrs)
+	if err != nil {
+		return nil, fmt.Errorf("bootSandbox: %v", err)
+	}
+	if err := vm.SetTag(common.AgentIPTag, agentIp); err != nil {
+		glog.Warningf("bootSandbox: couldn't tag %v with its agent's address: %v", vm.InstanceID, err)
+	}
 
 	// 4. Connect to VMServer in VM
-	client, err := common.CreateRealClient(podIp)
+	client, err := common.CreateRealClient(agentIp)
 	if err != nil {
 		return nil, fmt.Errorf("bootSandbox: error in createClient(): %v", err)
 	}
+	rollback = append(rollback, func() { client.Close() })
+
+	addrs = common.AddInterfaceAddresses(client, addrs)
+
+	podIp, err := podPolicy.Select(addrs)
+	if err != nil {
+		return nil, fmt.Errorf("bootSandbox: %v", err)
+	}
+
+	glog.Infof("bootSandbox: podIp = %v, agentIp = %v", podIp, agentIp)
+
+	// The VM keeps its own ip for reaching it, the pod gets one tunneled through this node
+	if common.OverlayEnabled() {
+		podIp, err = common.JoinOverlay(client, agentIp, "")
+		if err != nil {
+			return nil, fmt.Errorf("bootSandbox: couldn't join the overlay: %v", err)
+		}
+		overlayIp := podIp
+		rollback = append(rollback, func() { common.LeaveOverlay(overlayIp) })
+	}
 
 	providerData := &podData{
 		instanceId:  &vm.InstanceID,
 		usedDevices: make(map[string]bool),
 		attached:    make(map[string]string),
 		volumes:     volumes,
+		staticIP:    static,
 	}
 
 	// 5. Attach/Mount EBS Volumes
@@ -166,6 +270,12 @@ func (p *awsPodProvider) bootSandbox(vm *awsvm.VM, config *kubeapi.PodSandboxCon
 		if err != nil {
 			glog.Warningf("bootSandbox: failed to attach %v to %v in %v", vol.Volume, device, vm.InstanceID)
 		} else {
+			volume := vol.Volume
+			rollback = append(rollback, func() {
+				if err := providerData.detach(volume, true); err != nil {
+					glog.Warningf("bootSandbox: couldn't detach %v from %v: %v", volume, vm.InstanceID, err)
+				}
+			})
 			if vol.MountPoint != "" {
 				err := client.MountFs(device, vol.MountPoint, vol.FsType, vol.ReadOnly)
 				if err != nil {
@@ -182,7 +292,64 @@ func (p *awsPodProvider) bootSandbox(vm *awsvm.VM, config *kubeapi.PodSandboxCon
 		glog.Warningf("CreatePodSandbox: Failed to save sandbox config: %v", err)
 	}
 
-	err = client.SetPodIP(podIp)
+	// The VM keeps its own ip for reaching it, the pod gets one from the cluster's pod network
+	podIpv6 := ""
+	if common.CNIEnabled() {
+		podIp, podIpv6, err = common.SetupCNI(client, name)
+		if err != nil {
+			return nil, fmt.Errorf("CreatePodSandbox: couldn't join the pod network: %v", err)
+		}
+		rollback = append(rollback, func() {
+			if err := client.TeardownCNI(); err != nil {
+				glog.Warningf("bootSandbox: couldn't leave the pod network: %v", err)
+			}
+		})
+	}
+
+	if p.ipv6 {
+		podIpv6, err = assignIPv6(vm.InstanceID)
+		if err != nil {
+			return nil, fmt.Errorf("CreatePodSandbox: %v", err)
+		}
+	} else if *flags.DualStack && podIpv6 == "" {
+		glog.Warningf("CreatePodSandbox: the pod network gave %v no ipv6 address", name)
+	}
+
+	// A pod ip that isn't one of the instance's own needs a route to the instance, unless it's the overlay's, which
+	// has to go through the tunnel
+	if !common.HasAddress(addrs, podIp) && !common.OverlayEnabled() {
+		if err := routePod(p.config.RouteTable, vm.InstanceID, podIp); err != nil {
+			return nil, fmt.Errorf("CreatePodSandbox: %v", err)
+		}
+		providerData.routedIp = podIp
+		rollback = append(rollback, func() {
+			if err := unroutePod(p.config.RouteTable, vm.InstanceID, providerData.routedIp); err != nil {
+				glog.Warningf("bootSandbox: %v", err)
+			}
+		})
+	}
+
+	if mappings := common.HostPorts(config); hostPortMode == common.HostPortFirewall && len(mappings) > 0 {
+		group, err := openHostPorts(vm.InstanceID, mappings)
+		if err != nil {
+			return nil, fmt.Errorf("CreatePodSandbox: %v", err)
+		}
+		providerData.hostPortGroup = group
+		rollback = append(rollback, func() { deleteGroup(group) })
+	}
+
+	var firewall []common.FirewallIngress
+	if common.FirewallPolicyEnabled() {
+		firewall = common.PodFirewall(config.GetMetadata().GetNamespace(), config.Labels)
+		group, err := openFirewall(vm.InstanceID, firewall)
+		if err != nil {
+			return nil, fmt.Errorf("CreatePodSandbox: %v", err)
+		}
+		providerData.firewallGroup = group
+		rollback = append(rollback, func() { deleteGroup(group) })
+	}
+
+	err = client.SetPodIP(podIp, podIpv6)
 	if err != nil {
 		glog.Warningf("CreatePodSandbox: Failed to configure inteface: %v", err)
 	}
@@ -198,6 +365,13 @@ func (p *awsPodProvider) bootSandbox(vm *awsvm.VM, config *kubeapi.PodSandboxCon
 		glog.Infof("CreatePodSandbox: Skipping Proxy")
 	}
 
+	if *flags.NetworkPolicy {
+		err = client.StartNetworkPolicy()
+		if err != nil {
+			glog.Warningf("CreatePodSandbox: Couldn't enforce network policies: %v", err)
+		}
+	}
+
 	// Do we set the hostname to the pod's name
 	if cAnno.SetHostname {
 		err = client.SetHostname(config.GetHostname())
@@ -211,6 +385,13 @@ func (p *awsPodProvider) bootSandbox(vm *awsvm.VM, config *kubeapi.PodSandboxCon
 	booted := true
 
 	podData := common.NewPodData(vm, name, config.Metadata, config.Annotations, config.Labels, podIp, config.Linux, client, booted, providerData)
+	podData.Ips = common.AddressIPs(addrs)
+	podData.Ipv6 = podIpv6
+	podData.Firewall = firewall
+	podData.StaticIPState = staticState
+	if static != nil {
+		podData.StaticIP = static.address
+	}
 
 	return podData, nil
 }
@@ -224,7 +405,8 @@ func (v *awsPodProvider) RunPodSandbox(req *kubeapi.RunPodSandboxRequest, volume
 		ret, err := v.bootSandbox(vm, req.Config, podIp, volumes)
 
 		if err == nil { //i.e. boot succeeded
-			handleElasticIP(req.Config, vm.GetName())
+			v.registerDNS(ret, req.Config)
+			v.registerTarget(ret, req.Config)
 		}
 
 		return ret, err
@@ -292,12 +474,19 @@ func (v *awsPodProvider) PreCreateContainer(data *common.PodData, req *kubeapi.C
 		return fmt.Errorf("PreCreateContainer: couldn't boot VM: %v", err)
 	}
 
-	handleElasticIP(req.GetSandboxConfig(), vm.GetName())
-
 	data.Booted = true
 
 	data.Client = newPodData.Client
+	data.Ip = newPodData.Ip
+	data.Ips = newPodData.Ips
+	data.Ipv6 = newPodData.Ipv6
 	data.ProviderData = newPodData.ProviderData
+	data.Capabilities = newPodData.Capabilities
+	data.StaticIP = newPodData.StaticIP
+	data.StaticIPState = newPodData.StaticIPState
+
+	v.registerDNS(data, req.GetSandboxConfig())
+	v.registerTarget(data, req.GetSandboxConfig())
 
 	return nil
 }
@@ -326,12 +515,57 @@ func (v *awsPodProvider) StopPodSandbox(pdata *common.PodData) {
 	}
 
 	providerData.volumes = nil
+
+	// the elastic ip is free for another pod once this one stops
+	if providerData.staticIP != nil && providerData.staticIP.associationId != "" {
+		if err := disassociateStaticIP(providerData.staticIP); err != nil {
+			glog.Warningf("StopPodSandbox: %v", err)
+			pdata.StaticIPState = common.StaticIPFailed(err)
+		} else {
+			pdata.StaticIPState = common.StaticIPDisassociated
+		}
+	}
 }
 
 func (v *awsPodProvider) RemovePodSandbox(data *common.PodData) {
-	glog.Infof("RemovePodSandbox: release IP: %v", data.Ip)
+	if providerData, ok := data.ProviderData.(*podData); ok && providerData.routedIp != "" {
+		if err := unroutePod(v.config.RouteTable, *providerData.instanceId, providerData.routedIp); err != nil {
+			glog.Warningf("RemovePodSandbox: %v", err)
+		}
+	}
+
+	v.unregisterDNS(data)
+
+	// pods removed without being stopped are still in their target group
+	if providerData, ok := data.ProviderData.(*podData); ok {
+		deregisterTarget(providerData, false)
+	}
+
+	if providerData, ok := data.ProviderData.(*podData); ok && providerData.staticIP != nil {
+		if err := disassociateStaticIP(providerData.staticIP); err != nil {
+			glog.Warningf("RemovePodSandbox: %v", err)
+		}
+		if err := releaseStaticIP(providerData.staticIP); err != nil {
+			glog.Warningf("RemovePodSandbox: %v", err)
+		}
+	}
+
+	if providerData, ok := data.ProviderData.(*podData); ok {
+		for _, group := range []string{providerData.hostPortGroup, providerData.firewallGroup} {
+			if group != "" {
+				deleteGroup(group)
+			}
+		}
+	}
+
+	if common.OverlayEnabled() && data.Booted {
+		common.LeaveOverlay(data.Ip)
+	}
 
-	v.ipList.Append(data.Ip)
+	// the sandbox is named after the instance's private ip, which needn't be its pod ip
+	glog.Infof("RemovePodSandbox: release IP: %v", data.Id)
+
+	v.ipList.Append(data.Id)
 }
 
 func (v *awsPodProvider) PodSandboxStatus(podData *common.PodData) {}
@@ -372,15 +606,47 @@ func (v *awsPodProvider) ListInstances() ([]*common.PodData, error) {
 		return nil, err
 	}
 
+	allocated, err := allocatedStaticIPs()
+	if err != nil {
+		glog.Warningf("ListInstances: %v", err)
+	}
+	pooled, err := pooledStaticIPs("")
+	if err != nil {
+		glog.Warningf("ListInstances: %v", err)
+	}
+
 	podDatas := []*common.PodData{}
+	// the routes the adopted pods need, and the instances they're on
+	wanted := make(map[string]string)
+	adopted := make(map[string]bool)
 	for _, instance := range instances {
-		podIp := *instance.PrivateIpAddress
-		client, err := common.CreateRealClient(podIp)
+		vmIp := *instance.PrivateIpAddress
+
+		var publicIp net.IP
+		if instance.PublicIpAddress != nil {
+			publicIp = net.ParseIP(*instance.PublicIpAddress)
+		}
+		addrs := common.CloudAddresses(publicIp, net.ParseIP(vmIp))
+
+		// the sandbox's config isn't known yet, so vmserver is reached where it was when the VM booted
+		saved := ""
+		for _, tag := range instance.Tags {
+			if aws.StringValue(tag.Key) == common.AgentIPTag {
+				saved = aws.StringValue(tag.Value)
+			}
+		}
+		agentIp, err := common.AgentIP(saved, addrs)
+		if err != nil {
+			glog.Warningf("ListInstances: skipping %v: %v", *instance.InstanceId, err)
+			continue
+		}
+
+		client, err := common.CreateRealClient(agentIp)
 		if err != nil {
 			return nil, fmt.Errorf("CreatePodSandbox: error in createClient(): %v", err)
 		}
 
-		podIp, err = client.GetPodIP()
+		podIp, podIpv6, err := client.GetPodIP()
 		if err != nil {
 			continue
 		}
@@ -390,27 +656,107 @@ func (v *awsPodProvider) ListInstances() ([]*common.PodData, error) {
 			continue
 		}
 
-		name := podIp
+		addrs = common.AddInterfaceAddresses(client, addrs)
+
+		// the sandbox is named after the instance's private ip
+		name := vmIp
+		if common.OverlayEnabled() {
+			// its pod ip is tunneled to it again
+			if _, err := common.JoinOverlay(client, agentIp, podIp); err != nil {
+				glog.Warningf("ListInstances: %v", err)
+			}
+		}
 
 		vm := &awsvm.VM{
 			InstanceID: *instance.InstanceId,
 			Region:     v.config.Region,
 		}
 
-		providerData := &podData{}
+		providerData := &podData{
+			instanceId:    instance.InstanceId,
+			hostPortGroup: instanceGroup(instance, hostPortGroupPrefix),
+			firewallGroup: instanceGroup(instance, podGroupPrefix),
+		}
+		if name := common.ParseCommonAnnotations(config.Annotations).DNSName; name != "" && v.dns != nil {
+			providerData.dnsName, _ = v.dns.Name(name)
+		}
+		if common.ParseCommonAnnotations(config.Annotations).LBGroup != "" {
+			target, err := findTarget(config, *instance.InstanceId, podIp)
+			if err != nil {
+				glog.Warningf("ListInstances: %v", err)
+			}
+			providerData.lbTarget = target
+		}
+		if wantedStaticIP(config.Annotations) != "" {
+			static, err := instanceStaticIP(*instance.InstanceId, allocated, pooled)
+			if err != nil {
+				glog.Warningf("ListInstances: %v", err)
+			}
+			providerData.staticIP = static
+		}
+		adopted[*instance.InstanceId] = true
+		if !common.HasAddress(addrs, podIp) && !common.OverlayEnabled() {
+			providerData.routedIp = podIp
+			wanted[podIp] = *instance.InstanceId
+		}
 
-		v.ipList.FindAndRemove(podIp)
+		v.ipList.FindAndRemove(name)
 
 		glog.Infof("ListInstances: creating a podData for %v", name)
 		booted := true
 		podData := common.NewPodData(vm, name, config.Metadata, config.Annotations, config.Labels, podIp, config.Linux, client, booted, providerData)
+		podData.Ips = common.AddressIPs(addrs)
+		podData.Ipv6 = podIpv6
+		podData.PortMappings = common.HostPorts(config)
+		if static := providerData.staticIP; static != nil {
+			podData.StaticIP = static.address
+			podData.StaticIPState = common.StaticIPDisassociated
+			if static.associationId != "" {
+				podData.StaticIPState = common.StaticIPAssociated
+			}
+		}
 
 		podDatas = append(podDatas, podData)
 	}
 
+	v.reconcileRoutes(wanted, adopted)
+	reconcileGroups(hostPortGroupPrefix, adopted)
+	reconcileGroups(podGroupPrefix, adopted)
+	// not knowing which are pooled would release those
+	if allocated != nil && pooled != nil {
+		reconcileStaticIPs(allocated, pooled, adopted)
+	}
+
 	return podDatas, nil
 }
 
+// reconcileRoutes makes the route table match the pods found at startup.  Their routes are put back if they were
+// lost, and routes to their instances for ips they no longer have are removed.
+func (v *awsPodProvider) reconcileRoutes(wanted map[string]string, adopted map[string]bool) {
+	routes, err := instanceRoutes(v.config.RouteTable)
+	if err != nil {
+		glog.Warningf("reconcileRoutes: couldn't list routes of %v: %v", v.config.RouteTable, err)
+		return
+	}
+
+	for ip, instance := range routes {
+		if adopted[instance] && wanted[ip] != instance {
+			glog.Infof("reconcileRoutes: removing stale route of %v to %v", ip, instance)
+			if err := delRoute(v.config.RouteTable, ip); err != nil {
+				glog.Warningf("reconcileRoutes: %v", err)
+			}
+		}
+	}
+
+	for ip, instance := range wanted {
+		if routes[ip] != instance {
+			if err := routePod(v.config.RouteTable, instance, ip); err != nil {
+				glog.Warningf("reconcileRoutes: %v", err)
+			}
+		}
+	}
+}
+
 func (v *awsPodProvider) createVM(config *kubeapi.PodSandboxConfig, podIp string) *awsvm.VM {
 	aAnno := parseAWSAnnotations(config.Annotations)
 
@@ -440,19 +786,6 @@ func (v *awsPodProvider) createVM(config *kubeapi.PodSandboxConfig, podIp string
 	return vm
 }
 
-func handleElasticIP(config *kubeapi.PodSandboxConfig, name string) {
-	aAnno := parseAWSAnnotations(config.Annotations)
-
-	// Does this VM get an associatable elastic IP?
-	if aAnno.elasticIP != "" {
-		err := attachElasticIP(&name, &aAnno.elasticIP)
-		if err != nil {
-			awsErr := err.(awserr.Error)
-			glog.Warningf("CreatePodSandbox: attaching elastic ip failed: %v, code = %v, msg = %v", err.Error(), awsErr.Code(), awsErr.Message())
-		}
-	}
-}
-
 func (p *podData) detach(vol string, force bool) error {
 	glog.Infof("detach: enter: vol = %v", vol)
 
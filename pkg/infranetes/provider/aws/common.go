@@ -13,6 +13,9 @@ import (
 
 var (
 	client *ec2.EC2
+	// awsSession is the session client was made with, which other services share
+	awsSession *session.Session
+	lbClient   *elbv2
 )
 
 func initEC2(region string) {
@@ -30,12 +33,14 @@ func initEC2(region string) {
 		}
 	}
 
-	client = ec2.New(session.New(&aws.Config{
+	awsSession = session.New(&aws.Config{
 		Credentials: creds,
 		Region:      &region,
 		//CredentialsChainVerboseErrors: aws.Bool(true),
 		HTTPClient: &http.Client{Timeout: 30 * time.Second},
-	}))
+	})
+	client = ec2.New(awsSession)
+	lbClient = newELBv2(awsSession)
 
 }
 
@@ -47,4 +52,6 @@ type awsConfig struct {
 	Vpc           string
 	Subnet        string
 	SshKey        string
+	// HostedZone is the Route53 hosted zone pods' infranetes.dns.name records are kept in, if any
+	HostedZone string
 }
@@ -0,0 +1,45 @@
+package aws
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// testEC2 answers client's calls with respond, which is given each call's action and parameters and returns the body
+// of its response, or the code of the error it fails with
+func testEC2(t *testing.T, respond func(action string, form url.Values) (string, error)) func() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		action := r.Form.Get("Action")
+		body, err := respond(action, r.Form)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "<Response><Errors><Error><Code>%v</Code><Message>%v failed</Message></Error></Errors><RequestID>test</RequestID></Response>", err, action)
+			return
+		}
+
+		fmt.Fprintf(w, "<%vResponse>%v</%vResponse>", action, body, action)
+	}))
+
+	saved := client
+	client = ec2.New(session.New(&aws.Config{
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(server.URL),
+		MaxRetries:  aws.Int(0),
+	}))
+
+	return func() {
+		client = saved
+		server.Close()
+	}
+}
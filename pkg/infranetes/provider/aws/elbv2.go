@@ -0,0 +1,131 @@
+package aws
+
+import (
+	awsclient "github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/query"
+)
+
+// The vendored aws sdk has no elbv2 client, so the few calls pods' target groups need are made the way its generated
+// clients make theirs
+
+type elbv2 struct {
+	*awsclient.Client
+}
+
+func newELBv2(p awsclient.ConfigProvider) *elbv2 {
+	c := p.ClientConfig("elasticloadbalancing")
+
+	svc := &elbv2{
+		Client: awsclient.New(
+			*c.Config,
+			metadata.ClientInfo{
+				ServiceName:   "elasticloadbalancing",
+				SigningName:   c.SigningName,
+				SigningRegion: c.SigningRegion,
+				Endpoint:      c.Endpoint,
+				APIVersion:    "2015-12-01",
+			},
+			c.Handlers,
+		),
+	}
+
+	svc.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	svc.Handlers.Build.PushBackNamed(query.BuildHandler)
+	svc.Handlers.Unmarshal.PushBackNamed(query.UnmarshalHandler)
+	svc.Handlers.UnmarshalMeta.PushBackNamed(query.UnmarshalMetaHandler)
+	svc.Handlers.UnmarshalError.PushBackNamed(query.UnmarshalErrorHandler)
+
+	return svc
+}
+
+func (c *elbv2) call(name string, input interface{}, output interface{}) error {
+	op := &request.Operation{
+		Name:       name,
+		HTTPMethod: "POST",
+		HTTPPath:   "/",
+	}
+
+	return c.NewRequest(op, input, output).Send()
+}
+
+type targetDescription struct {
+	_ struct{} `type:"structure"`
+
+	Id   *string `type:"string"`
+	Port *int64  `type:"integer"`
+}
+
+type targetHealth struct {
+	_ struct{} `type:"structure"`
+
+	Description *string `type:"string"`
+	Reason      *string `type:"string"`
+	State       *string `type:"string"`
+}
+
+type targetHealthDescription struct {
+	_ struct{} `type:"structure"`
+
+	Target       *targetDescription `type:"structure"`
+	TargetHealth *targetHealth      `type:"structure"`
+}
+
+type targetGroup struct {
+	_ struct{} `type:"structure"`
+
+	Port            *int64  `type:"integer"`
+	TargetGroupArn  *string `type:"string"`
+	TargetGroupName *string `type:"string"`
+	TargetType      *string `type:"string"`
+}
+
+type describeTargetGroupsInput struct {
+	_ struct{} `type:"structure"`
+
+	Names           []*string `type:"list"`
+	TargetGroupArns []*string `type:"list"`
+}
+
+type describeTargetGroupsOutput struct {
+	_ struct{} `type:"structure"`
+
+	TargetGroups []*targetGroup `type:"list"`
+}
+
+type targetsInput struct {
+	_ struct{} `type:"structure"`
+
+	TargetGroupArn *string              `type:"string"`
+	Targets        []*targetDescription `type:"list"`
+}
+
+type targetsOutput struct {
+	_ struct{} `type:"structure"`
+}
+
+type describeTargetHealthOutput struct {
+	_ struct{} `type:"structure"`
+
+	TargetHealthDescriptions []*targetHealthDescription `type:"list"`
+}
+
+func (c *elbv2) DescribeTargetGroups(input *describeTargetGroupsInput) (*describeTargetGroupsOutput, error) {
+	output := &describeTargetGroupsOutput{}
+	return output, c.call("DescribeTargetGroups", input, output)
+}
+
+func (c *elbv2) RegisterTargets(input *targetsInput) error {
+	return c.call("RegisterTargets", input, &targetsOutput{})
+}
+
+func (c *elbv2) DeregisterTargets(input *targetsInput) error {
+	return c.call("DeregisterTargets", input, &targetsOutput{})
+}
+
+func (c *elbv2) DescribeTargetHealth(input *targetsInput) (*describeTargetHealthOutput, error) {
+	output := &describeTargetHealthOutput{}
+	return output, c.call("DescribeTargetHealth", input, output)
+}
@@ -0,0 +1,71 @@
+package aws
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
+)
+
+// podGroupPrefix names the security groups that are pods' own firewalls, the rest of the name is the instance's
+const podGroupPrefix = "infranetes-pod-"
+
+func ingressPermissions(ingress []common.FirewallIngress) []*ec2.IpPermission {
+	permissions := []*ec2.IpPermission{}
+	for _, i := range ingress {
+		permission := &ec2.IpPermission{IpProtocol: aws.String(i.Protocol)}
+		switch {
+		case i.Protocol == "all":
+			permission.IpProtocol = aws.String("-1")
+		case i.Protocol == "icmp":
+			permission.FromPort = aws.Int64(-1)
+			permission.ToPort = aws.Int64(-1)
+		case i.Port == 0:
+			permission.FromPort = aws.Int64(0)
+			permission.ToPort = aws.Int64(65535)
+		default:
+			permission.FromPort = aws.Int64(int64(i.Port))
+			permission.ToPort = aws.Int64(int64(i.EndPort))
+		}
+		for _, source := range i.Sources {
+			permission.IpRanges = append(permission.IpRanges, &ec2.IpRange{CidrIp: aws.String(source)})
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions
+}
+
+// openFirewall gives the pod's instance a security group of its own that lets ingress in
+func openFirewall(instance string, ingress []common.FirewallIngress) (string, error) {
+	group, err := addInstanceGroup(instance, podGroupPrefix, fmt.Sprintf("firewall of the infranetes pod on %v", instance), ingressPermissions(ingress))
+	if err != nil {
+		return "", fmt.Errorf("couldn't create the pod's firewall: %v", err)
+	}
+
+	glog.Infof("openFirewall: %v lets %v in to %v", group, ingress, instance)
+
+	return group, nil
+}
+
+func (v *awsPodProvider) UpdateFirewall(data *common.PodData, ingress []common.FirewallIngress) error {
+	providerData, ok := data.ProviderData.(*podData)
+	if !ok {
+		return fmt.Errorf("UpdateFirewall: %v has no aws data", data.Id)
+	}
+
+	// pods from before -firewall-policy was set don't have one yet
+	if providerData.firewallGroup == "" {
+		group, err := openFirewall(*providerData.instanceId, ingress)
+		if err != nil {
+			return err
+		}
+		providerData.firewallGroup = group
+		return nil
+	}
+
+	return setGroupPermissions(providerData.firewallGroup, ingressPermissions(ingress))
+}
@@ -0,0 +1,72 @@
+package aws
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
+)
+
+func permission(protocol string, from int64, to int64, ranges ...string) *ec2.IpPermission {
+	p := &ec2.IpPermission{IpProtocol: aws.String(protocol)}
+	if protocol != "-1" {
+		p.FromPort = aws.Int64(from)
+		p.ToPort = aws.Int64(to)
+	}
+	for _, r := range ranges {
+		p.IpRanges = append(p.IpRanges, &ec2.IpRange{CidrIp: aws.String(r)})
+	}
+	return p
+}
+
+func TestIngressPermissions(t *testing.T) {
+	for _, test := range []struct {
+		ingress common.FirewallIngress
+		want    *ec2.IpPermission
+	}{
+		{common.FirewallIngress{Protocol: "tcp", Port: 80, EndPort: 80, Sources: []string{"0.0.0.0/0"}}, permission("tcp", 80, 80, "0.0.0.0/0")},
+		{common.FirewallIngress{Protocol: "udp", Port: 1000, EndPort: 2000, Sources: []string{"10.0.0.0/8", "192.168.0.0/16"}},
+			permission("udp", 1000, 2000, "10.0.0.0/8", "192.168.0.0/16")},
+		{common.FirewallIngress{Protocol: "tcp", Sources: []string{"0.0.0.0/0"}}, permission("tcp", 0, 65535, "0.0.0.0/0")},
+		{common.FirewallIngress{Protocol: "icmp", Sources: []string{"0.0.0.0/0"}}, permission("icmp", -1, -1, "0.0.0.0/0")},
+		{common.FirewallIngress{Protocol: "all", Sources: []string{"10.0.0.0/8"}}, permission("-1", 0, 0, "10.0.0.0/8")},
+	} {
+		got := ingressPermissions([]common.FirewallIngress{test.ingress})
+		if len(got) != 1 || !reflect.DeepEqual(got[0], test.want) {
+			t.Errorf("ingressPermissions(%v) = %v, want %v", test.ingress, got, test.want)
+		}
+	}
+}
+
+func TestPermissionChanges(t *testing.T) {
+	old := []*ec2.IpPermission{
+		permission("tcp", 80, 80, "0.0.0.0/0"),
+		permission("tcp", 22, 22, "10.0.0.0/8", "192.168.0.0/16"),
+		{IpProtocol: aws.String("-1"), Ipv6Ranges: []*ec2.Ipv6Range{{CidrIpv6: aws.String("::/0")}}},
+	}
+	permissions := []*ec2.IpPermission{
+		permission("tcp", 80, 80, "0.0.0.0/0"),
+		permission("tcp", 22, 22, "10.0.0.0/8", "172.16.0.0/12"),
+		permission("-1", 0, 0, "10.0.0.0/8"),
+	}
+
+	authorize, revoke := permissionChanges(old, permissions)
+
+	if want := []*ec2.IpPermission{permission("-1", 0, 0, "10.0.0.0/8"), permission("tcp", 22, 22, "172.16.0.0/12")}; !reflect.DeepEqual(authorize, want) {
+		t.Errorf("authorize = %v, want %v", authorize, want)
+	}
+	if want := []*ec2.IpPermission{
+		{IpProtocol: aws.String("-1"), Ipv6Ranges: []*ec2.Ipv6Range{{CidrIpv6: aws.String("::/0")}}},
+		permission("tcp", 22, 22, "192.168.0.0/16"),
+	}; !reflect.DeepEqual(revoke, want) {
+		t.Errorf("revoke = %v, want %v", revoke, want)
+	}
+
+	authorize, revoke = permissionChanges(permissions, permissions)
+	if len(authorize) != 0 || len(revoke) != 0 {
+		t.Errorf("unchanged permissions authorize %v and revoke %v", authorize, revoke)
+	}
+}
@@ -0,0 +1,38 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+// hostPortGroupPrefix names the security groups that open pods' host ports, the rest of the name is the instance's
+const hostPortGroupPrefix = "infranetes-hostports-"
+
+// openHostPorts lets the pod's host ports in to its instance with a security group of its own
+func openHostPorts(instance string, mappings []*kubeapi.PortMapping) (string, error) {
+	permissions := []*ec2.IpPermission{}
+	for _, mapping := range mappings {
+		permissions = append(permissions, &ec2.IpPermission{
+			IpProtocol: aws.String(strings.ToLower(mapping.Protocol.String())),
+			FromPort:   aws.Int64(int64(mapping.HostPort)),
+			ToPort:     aws.Int64(int64(mapping.HostPort)),
+			IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
+		})
+	}
+
+	group, err := addInstanceGroup(instance, hostPortGroupPrefix, fmt.Sprintf("host ports of the infranetes pod on %v", instance), permissions)
+	if err != nil {
+		return "", fmt.Errorf("couldn't open host ports: %v", err)
+	}
+
+	glog.Infof("openHostPorts: opened %+v on %v with %v", mappings, instance, group)
+
+	return group, nil
+}
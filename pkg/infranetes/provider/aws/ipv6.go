@@ -0,0 +1,66 @@
+package aws
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func describeInstance(instance string) (*ec2.Instance, error) {
+	resp, err := client.DescribeInstances(&ec2.DescribeInstancesInput{InstanceIds: []*string{aws.String(instance)}})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, resv := range resp.Reservations {
+		for _, inst := range resv.Instances {
+			return inst, nil
+		}
+	}
+
+	return nil, fmt.Errorf("instance %v not found", instance)
+}
+
+// primaryInterface returns the id of the instance's eth0
+func primaryInterface(instance string) (string, error) {
+	inst, err := describeInstance(instance)
+	if err != nil {
+		return "", err
+	}
+
+	for _, iface := range inst.NetworkInterfaces {
+		if iface.Attachment != nil && aws.Int64Value(iface.Attachment.DeviceIndex) == 0 {
+			return *iface.NetworkInterfaceId, nil
+		}
+	}
+
+	return "", fmt.Errorf("couldn't find the primary network interface of %v", instance)
+}
+
+// assignIPv6 has ec2 give the instance a pod ipv6 address from its subnet, one no other interface has.  It goes away
+// with the instance.
+func assignIPv6(instance string) (string, error) {
+	iface, err := primaryInterface(instance)
+	if err != nil {
+		return "", fmt.Errorf("couldn't assign an ipv6 address to %v: %v", instance, err)
+	}
+
+	resp, err := client.AssignIpv6Addresses(&ec2.AssignIpv6AddressesInput{
+		NetworkInterfaceId: aws.String(iface),
+		Ipv6AddressCount:   aws.Int64(1),
+	})
+	if err != nil {
+		return "", fmt.Errorf("couldn't assign an ipv6 address to %v: %v", instance, err)
+	}
+	if len(resp.AssignedIpv6Addresses) != 1 {
+		return "", fmt.Errorf("ec2 assigned %v ipv6 addresses to %v, not one", len(resp.AssignedIpv6Addresses), instance)
+	}
+
+	ip := *resp.AssignedIpv6Addresses[0]
+	glog.Infof("assignIPv6: assigned %v to %v", ip, instance)
+
+	return ip, nil
+}
@@ -0,0 +1,64 @@
+package aws
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+)
+
+const testInstance = `<reservationSet><item><instancesSet><item>
+	<instanceId>i-1</instanceId>
+	<networkInterfaceSet>
+		<item><networkInterfaceId>eni-2</networkInterfaceId><attachment><deviceIndex>1</deviceIndex></attachment></item>
+		<item><networkInterfaceId>eni-1</networkInterfaceId><attachment><deviceIndex>0</deviceIndex></attachment></item>
+	</networkInterfaceSet>
+</item></instancesSet></item></reservationSet>`
+
+func TestAssignIPv6(t *testing.T) {
+	assigned := []string{"2600:1f18::a"}
+
+	defer testEC2(t, func(action string, form url.Values) (string, error) {
+		switch action {
+		case "DescribeInstances":
+			return testInstance, nil
+		case "AssignIpv6Addresses":
+			// ec2 picks the address
+			if form.Get("NetworkInterfaceId") != "eni-1" || form.Get("Ipv6AddressCount") != "1" || form.Get("Ipv6Addresses.1") != "" {
+				t.Errorf("assigned %v", form)
+			}
+			body := "<networkInterfaceId>eni-1</networkInterfaceId><assignedIpv6Addresses>"
+			for _, ip := range assigned {
+				body += fmt.Sprintf("<item>%v</item>", ip)
+			}
+			return body + "</assignedIpv6Addresses>", nil
+		}
+		t.Errorf("unexpected %v", action)
+		return "", fmt.Errorf("InvalidAction")
+	})()
+
+	ip, err := assignIPv6("i-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip != "2600:1f18::a" {
+		t.Errorf("assigned %v", ip)
+	}
+
+	assigned = nil
+	if ip, err := assignIPv6("i-1"); err == nil {
+		t.Errorf("assigned %v without ec2 assigning one", ip)
+	}
+}
+
+func TestAssignIPv6Fails(t *testing.T) {
+	defer testEC2(t, func(action string, form url.Values) (string, error) {
+		if action == "DescribeInstances" {
+			return testInstance, nil
+		}
+		return "", fmt.Errorf("InsufficientFreeAddressesInSubnet")
+	})()
+
+	if ip, err := assignIPv6("i-1"); err == nil {
+		t.Errorf("assigned %v from a full subnet", ip)
+	}
+}
@@ -0,0 +1,168 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/aws/aws-sdk-go/aws"
+
+	"github.com/apporbit/infranetes/cmd/infranetes/flags"
+	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+// lbTarget is a pod's registration in an ALB or NLB target group
+type lbTarget struct {
+	groupArn string
+	target   *targetDescription
+}
+
+func (t *lbTarget) input() *targetsInput {
+	return &targetsInput{TargetGroupArn: aws.String(t.groupArn), Targets: []*targetDescription{t.target}}
+}
+
+// findTarget returns the target the pod is in the target group named or with the arn in its infranetes.lb.group
+// annotation.  Groups of ip targets get its pod ip, the others its instance.
+func findTarget(config *kubeapi.PodSandboxConfig, instance string, podIp string) (*lbTarget, error) {
+	cAnno := common.ParseCommonAnnotations(config.Annotations)
+
+	input := &describeTargetGroupsInput{}
+	if strings.HasPrefix(cAnno.LBGroup, "arn:") {
+		input.TargetGroupArns = []*string{aws.String(cAnno.LBGroup)}
+	} else {
+		input.Names = []*string{aws.String(cAnno.LBGroup)}
+	}
+	resp, err := lbClient.DescribeTargetGroups(input)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find target group %v: %v", cAnno.LBGroup, err)
+	}
+	if len(resp.TargetGroups) != 1 {
+		return nil, fmt.Errorf("target group %v not found", cAnno.LBGroup)
+	}
+	group := resp.TargetGroups[0]
+
+	target := &targetDescription{Id: aws.String(instance), Port: group.Port}
+	if aws.StringValue(group.TargetType) == "ip" {
+		target.Id = aws.String(podIp)
+	}
+	if cAnno.LBPort != 0 {
+		target.Port = aws.Int64(cAnno.LBPort)
+	}
+
+	return &lbTarget{groupArn: *group.TargetGroupArn, target: target}, nil
+}
+
+// registerTarget puts a booted pod in the target group its infranetes.lb.group annotation names.  A pod that isn't
+// in its group still runs, its status shows it isn't.
+func (v *awsPodProvider) registerTarget(data *common.PodData, config *kubeapi.PodSandboxConfig) {
+	if common.ParseCommonAnnotations(config.Annotations).LBGroup == "" {
+		return
+	}
+
+	providerData, ok := data.ProviderData.(*podData)
+	if !ok {
+		return
+	}
+
+	target, err := findTarget(config, *providerData.instanceId, data.Ip)
+	if err == nil {
+		err = lbClient.RegisterTargets(target.input())
+	}
+	if err != nil {
+		glog.Warningf("registerTarget: couldn't register %v: %v", data.Id, err)
+		data.LBHealth = "unregistered"
+		return
+	}
+	providerData.lbTarget = target
+
+	glog.Infof("registerTarget: registered %v:%v in %v", *target.target.Id, aws.Int64Value(target.target.Port), target.groupArn)
+}
+
+// deregisterTarget takes the pod out of its target group, waiting up to -lb-drain-timeout for it to drain if drain is
+// set.  The pod's lock isn't held meanwhile, so its target is only taken under its provider data's.
+func deregisterTarget(providerData *podData, drain bool) {
+	providerData.lock.Lock()
+	target := providerData.lbTarget
+	providerData.lbTarget = nil
+	providerData.lock.Unlock()
+
+	if target == nil {
+		return
+	}
+
+	if err := lbClient.DeregisterTargets(target.input()); err != nil {
+		glog.Warningf("deregisterTarget: couldn't deregister %v from %v: %v", *target.target.Id, target.groupArn, err)
+		return
+	}
+
+	if !drain {
+		return
+	}
+
+	for deadline := time.Now().Add(*flags.LBDrainTimeout); time.Now().Before(deadline); time.Sleep(5 * time.Second) {
+		health, err := healthOf(target)
+		if err != nil {
+			glog.Warningf("deregisterTarget: %v", err)
+			return
+		}
+		if aws.StringValue(health.State) != "draining" {
+			glog.Infof("deregisterTarget: %v drained from %v", *target.target.Id, target.groupArn)
+			return
+		}
+	}
+
+	glog.Warningf("deregisterTarget: %v still draining from %v after %v", *target.target.Id, target.groupArn, *flags.LBDrainTimeout)
+}
+
+// healthOf returns the target's health in its group
+func healthOf(target *lbTarget) (*targetHealth, error) {
+	resp, err := lbClient.DescribeTargetHealth(target.input())
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get health of %v in %v: %v", *target.target.Id, target.groupArn, err)
+	}
+
+	for _, desc := range resp.TargetHealthDescriptions {
+		if desc.TargetHealth != nil && desc.Target != nil && aws.StringValue(desc.Target.Id) == *target.target.Id {
+			return desc.TargetHealth, nil
+		}
+	}
+
+	return &targetHealth{State: aws.String("unused")}, nil
+}
+
+func (v *awsPodProvider) DeregisterLoadBalancer(data *common.PodData) {
+	if providerData, ok := data.ProviderData.(*podData); ok {
+		deregisterTarget(providerData, true)
+	}
+}
+
+func (v *awsPodProvider) LoadBalancerHealth(data *common.PodData) (string, error) {
+	providerData, ok := data.ProviderData.(*podData)
+	if !ok {
+		return "unregistered", nil
+	}
+
+	providerData.lock.Lock()
+	target := providerData.lbTarget
+	providerData.lock.Unlock()
+	if target == nil {
+		return "unregistered", nil
+	}
+
+	health, err := healthOf(target)
+	if err != nil {
+		return "", err
+	}
+
+	// the state with the reason it's in it, such as "unhealthy: Target.Timeout"
+	state := aws.StringValue(health.State)
+	if reason := aws.StringValue(health.Reason); reason != "" {
+		state += ": " + reason
+	}
+
+	return state, nil
+}
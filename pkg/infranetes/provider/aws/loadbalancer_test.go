@@ -0,0 +1,107 @@
+package aws
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+// testELBv2 answers lbClient's calls with groups, of instance targets on port 80 unless named "ips", and the health
+// of targets in them
+func testELBv2(t *testing.T, health map[string]string) func() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		var result string
+		switch action := r.Form.Get("Action"); action {
+		case "DescribeTargetGroups":
+			name := r.Form.Get("Names.member.1")
+			arn := r.Form.Get("TargetGroupArns.member.1")
+			if name == "" {
+				name = arn[len("arn:group/"):]
+			}
+			targetType := "instance"
+			if name == "ips" {
+				targetType = "ip"
+			}
+			if name == "missing" {
+				result = "<TargetGroups></TargetGroups>"
+				break
+			}
+			result = fmt.Sprintf("<TargetGroups><member><TargetGroupArn>arn:group/%v</TargetGroupArn><Port>80</Port><TargetType>%v</TargetType></member></TargetGroups>", name, targetType)
+		case "DescribeTargetHealth":
+			result = "<TargetHealthDescriptions>"
+			for id, state := range health {
+				result += fmt.Sprintf("<member><Target><Id>%v</Id></Target><TargetHealth><State>%v</State></TargetHealth></member>", id, state)
+			}
+			result += "</TargetHealthDescriptions>"
+		default:
+			t.Errorf("unexpected %v", action)
+		}
+
+		action := r.Form.Get("Action")
+		fmt.Fprintf(w, "<%vResponse><%vResult>%v</%vResult></%vResponse>", action, action, result, action, action)
+	}))
+
+	saved := lbClient
+	lbClient = newELBv2(session.New(&aws.Config{
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(server.URL),
+	}))
+
+	return func() {
+		lbClient = saved
+		server.Close()
+	}
+}
+
+func TestFindTarget(t *testing.T) {
+	defer testELBv2(t, nil)()
+
+	for _, test := range []struct {
+		annotations map[string]string
+		group       string
+		id          string
+		port        int64
+	}{
+		{map[string]string{"infranetes.lb.group": "web"}, "arn:group/web", "i-1234", 80},
+		{map[string]string{"infranetes.lb.group": "arn:group/web"}, "arn:group/web", "i-1234", 80},
+		{map[string]string{"infranetes.lb.group": "ips"}, "arn:group/ips", "10.0.0.1", 80},
+		{map[string]string{"infranetes.lb.group": "web", "infranetes.lb.port": "8080"}, "arn:group/web", "i-1234", 8080},
+	} {
+		target, err := findTarget(&kubeapi.PodSandboxConfig{Annotations: test.annotations}, "i-1234", "10.0.0.1")
+		if err != nil {
+			t.Errorf("%v: %v", test.annotations, err)
+			continue
+		}
+		if target.groupArn != test.group || aws.StringValue(target.target.Id) != test.id || aws.Int64Value(target.target.Port) != test.port {
+			t.Errorf("%v: target = %v %v:%v, want %v %v:%v", test.annotations, target.groupArn, aws.StringValue(target.target.Id),
+				aws.Int64Value(target.target.Port), test.group, test.id, test.port)
+		}
+	}
+
+	if _, err := findTarget(&kubeapi.PodSandboxConfig{Annotations: map[string]string{"infranetes.lb.group": "missing"}}, "i-1234", "10.0.0.1"); err == nil {
+		t.Errorf("found a target in a missing group")
+	}
+}
+
+func TestHealthOf(t *testing.T) {
+	defer testELBv2(t, map[string]string{"i-1234": "healthy", "i-5678": "draining"})()
+
+	for id, want := range map[string]string{"i-1234": "healthy", "i-5678": "draining", "i-9999": "unused"} {
+		health, err := healthOf(&lbTarget{groupArn: "arn:group/web", target: &targetDescription{Id: aws.String(id), Port: aws.Int64(80)}})
+		if err != nil {
+			t.Errorf("%v: %v", id, err)
+		} else if aws.StringValue(health.State) != want {
+			t.Errorf("health of %v = %v, want %v", id, aws.StringValue(health.State), want)
+		}
+	}
+}
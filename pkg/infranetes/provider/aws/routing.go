@@ -2,11 +2,16 @@ package aws
 
 import (
 	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
 )
 
+// addRoute points ip's /32 at instance, replacing a route to another instance left by a pod that had the ip before
 func addRoute(routeTable string, instance string, ip string) error {
 	cidr := fmt.Sprintf("%v/32", ip)
 
@@ -17,10 +22,18 @@ func addRoute(routeTable string, instance string, ip string) error {
 	}
 
 	_, err := client.CreateRoute(req)
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "RouteAlreadyExists" {
+		_, err = client.ReplaceRoute(&ec2.ReplaceRouteInput{
+			DestinationCidrBlock: aws.String(cidr),
+			InstanceId:           aws.String(instance),
+			RouteTableId:         aws.String(routeTable),
+		})
+	}
 
 	return err
 }
 
+// delRoute removes ip's /32, it isn't an error if it's gone already
 func delRoute(routeTable string, ip string) error {
 	cidr := fmt.Sprintf("%v/32", ip)
 
@@ -30,15 +43,52 @@ func delRoute(routeTable string, ip string) error {
 	}
 
 	_, err := client.DeleteRoute(req)
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "InvalidRoute.NotFound" {
+		return nil
+	}
 
 	return err
 }
 
+// instanceRoutes returns the /32 routes in routeTable that point at an instance, by their ip
+func instanceRoutes(routeTable string) (map[string]string, error) {
+	resp, err := client.DescribeRouteTables(&ec2.DescribeRouteTablesInput{RouteTableIds: []*string{aws.String(routeTable)}})
+	if err != nil {
+		return nil, err
+	}
+
+	routes := make(map[string]string)
+	for _, table := range resp.RouteTables {
+		for _, route := range table.Routes {
+			if route.DestinationCidrBlock == nil || route.InstanceId == nil || !strings.HasSuffix(*route.DestinationCidrBlock, "/32") {
+				continue
+			}
+			routes[strings.TrimSuffix(*route.DestinationCidrBlock, "/32")] = *route.InstanceId
+		}
+	}
+
+	return routes, nil
+}
+
 func destSourceReset(instance string) error {
+	return setSourceDestCheck(instance, false)
+}
+
+// destSourceRestore turns the instance's source/dest check back on.  Terminated instances have nothing to restore.
+func destSourceRestore(instance string) error {
+	err := setSourceDestCheck(instance, true)
+	if awsErr, ok := err.(awserr.Error); ok && (awsErr.Code() == "IncorrectInstanceState" || awsErr.Code() == "InvalidInstanceID.NotFound") {
+		return nil
+	}
+
+	return err
+}
+
+func setSourceDestCheck(instance string, check bool) error {
 	params := &ec2.ModifyInstanceAttributeInput{
 		InstanceId: aws.String(instance),
 		SourceDestCheck: &ec2.AttributeBooleanValue{
-			Value: aws.Bool(false),
+			Value: aws.Bool(check),
 		},
 	}
 
@@ -47,16 +97,36 @@ func destSourceReset(instance string) error {
 	return err
 }
 
-func attachElasticIP(instanceID *string, elasticID *string) error {
-	req := &ec2.AssociateAddressInput{
-		AllocationId: elasticID,
-		InstanceId:   instanceID,
+// routePod sends traffic for a pod ip that isn't its instance's primary ip to the instance, which has to stop
+// checking that the packets it gets are addressed to it.  Nothing is left behind if it fails.
+func routePod(routeTable string, instance string, ip string) error {
+	if err := destSourceReset(instance); err != nil {
+		return fmt.Errorf("couldn't disable source/dest check of %v: %v", instance, err)
 	}
 
-	_, err := client.AssociateAddress(req)
-	if err != nil {
-		return fmt.Errorf("AssociateAddress failed: %v", err)
+	if err := addRoute(routeTable, instance, ip); err != nil {
+		if err := destSourceRestore(instance); err != nil {
+			glog.Warningf("routePod: couldn't restore source/dest check of %v: %v", instance, err)
+		}
+		return fmt.Errorf("couldn't route %v to %v: %v", ip, instance, err)
 	}
 
+	glog.Infof("routePod: routed %v to %v", ip, instance)
+
+	return nil
+}
+
+// unroutePod undoes routePod
+func unroutePod(routeTable string, instance string, ip string) error {
+	if err := delRoute(routeTable, ip); err != nil {
+		return fmt.Errorf("couldn't remove route for %v: %v", ip, err)
+	}
+
+	if err := destSourceRestore(instance); err != nil {
+		return fmt.Errorf("couldn't restore source/dest check of %v: %v", instance, err)
+	}
+
+	glog.Infof("unroutePod: removed route of %v to %v", ip, instance)
+
 	return nil
 }
@@ -0,0 +1,200 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// addInstanceGroup creates a security group, named prefix and the instance's id, that lets permissions in, and adds it
+// to the groups the instance already has
+func addInstanceGroup(instance string, prefix string, description string, permissions []*ec2.IpPermission) (string, error) {
+	inst, err := describeInstance(instance)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.CreateSecurityGroup(&ec2.CreateSecurityGroupInput{
+		GroupName:   aws.String(prefix + instance),
+		Description: aws.String(description),
+		VpcId:       inst.VpcId,
+	})
+	if err != nil {
+		return "", fmt.Errorf("couldn't create security group: %v", err)
+	}
+	group := *resp.GroupId
+
+	groups := []*string{aws.String(group)}
+	for _, g := range inst.SecurityGroups {
+		groups = append(groups, g.GroupId)
+	}
+
+	if len(permissions) > 0 {
+		_, err = client.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressInput{
+			GroupId:       aws.String(group),
+			IpPermissions: permissions,
+		})
+	}
+	if err == nil {
+		_, err = client.ModifyInstanceAttribute(&ec2.ModifyInstanceAttributeInput{
+			InstanceId: aws.String(instance),
+			Groups:     groups,
+		})
+	}
+	if err != nil {
+		if _, delErr := client.DeleteSecurityGroup(&ec2.DeleteSecurityGroupInput{GroupId: aws.String(group)}); delErr != nil {
+			glog.Warningf("addInstanceGroup: couldn't remove %v: %v", group, delErr)
+		}
+		return "", fmt.Errorf("couldn't add security group to %v: %v", instance, err)
+	}
+
+	return group, nil
+}
+
+// setGroupPermissions replaces what group lets in with permissions.  What's new is let in before what's no longer
+// wanted is revoked, so ingress that stays is never cut off.
+func setGroupPermissions(group string, permissions []*ec2.IpPermission) error {
+	resp, err := client.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{GroupIds: []*string{aws.String(group)}})
+	if err != nil {
+		return err
+	}
+	if len(resp.SecurityGroups) != 1 {
+		return fmt.Errorf("security group %v not found", group)
+	}
+
+	authorize, revoke := permissionChanges(resp.SecurityGroups[0].IpPermissions, permissions)
+
+	if len(authorize) > 0 {
+		if _, err := client.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressInput{
+			GroupId:       aws.String(group),
+			IpPermissions: authorize,
+		}); err != nil {
+			return fmt.Errorf("couldn't authorize the rules of %v: %v", group, err)
+		}
+	}
+
+	if len(revoke) > 0 {
+		if _, err := client.RevokeSecurityGroupIngress(&ec2.RevokeSecurityGroupIngressInput{
+			GroupId:       aws.String(group),
+			IpPermissions: revoke,
+		}); err != nil {
+			return fmt.Errorf("couldn't revoke the rules of %v: %v", group, err)
+		}
+	}
+
+	return nil
+}
+
+// permissionChanges returns the permissions, one per source range, that are in permissions but not old and those
+// that are in old but not permissions
+func permissionChanges(old []*ec2.IpPermission, permissions []*ec2.IpPermission) ([]*ec2.IpPermission, []*ec2.IpPermission) {
+	have := splitPermissions(old)
+	want := splitPermissions(permissions)
+
+	return missingPermissions(want, have), missingPermissions(have, want)
+}
+
+// missingPermissions returns the permissions in from that aren't in other, in a stable order
+func missingPermissions(from map[string]*ec2.IpPermission, other map[string]*ec2.IpPermission) []*ec2.IpPermission {
+	keys := []string{}
+	for key := range from {
+		if _, ok := other[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	ret := []*ec2.IpPermission{}
+	for _, key := range keys {
+		ret = append(ret, from[key])
+	}
+	return ret
+}
+
+// splitPermissions splits permissions into ones of a single ipv4 or ipv6 range each, keyed by what they let in
+func splitPermissions(permissions []*ec2.IpPermission) map[string]*ec2.IpPermission {
+	ret := make(map[string]*ec2.IpPermission)
+	for _, p := range permissions {
+		ports := fmt.Sprintf("%v:%v-%v", aws.StringValue(p.IpProtocol), aws.Int64Value(p.FromPort), aws.Int64Value(p.ToPort))
+		for _, r := range p.IpRanges {
+			ret[ports+" from "+aws.StringValue(r.CidrIp)] = &ec2.IpPermission{
+				IpProtocol: p.IpProtocol,
+				FromPort:   p.FromPort,
+				ToPort:     p.ToPort,
+				IpRanges:   []*ec2.IpRange{{CidrIp: r.CidrIp}},
+			}
+		}
+		for _, r := range p.Ipv6Ranges {
+			ret[ports+" from "+aws.StringValue(r.CidrIpv6)] = &ec2.IpPermission{
+				IpProtocol: p.IpProtocol,
+				FromPort:   p.FromPort,
+				ToPort:     p.ToPort,
+				Ipv6Ranges: []*ec2.Ipv6Range{{CidrIpv6: r.CidrIpv6}},
+			}
+		}
+	}
+	return ret
+}
+
+// deleteGroup removes a pod's security group.  Its instance is being terminated, and the group can't be deleted until
+// it is, so it's retried in the background.
+func deleteGroup(group string) {
+	go func() {
+		for i := 0; i < 30; i++ {
+			_, err := client.DeleteSecurityGroup(&ec2.DeleteSecurityGroupInput{GroupId: aws.String(group)})
+			if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "DependencyViolation" {
+				time.Sleep(10 * time.Second)
+				continue
+			}
+			if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "InvalidGroup.NotFound" {
+				return
+			}
+			if err != nil {
+				glog.Warningf("deleteGroup: couldn't remove %v: %v", group, err)
+			}
+			return
+		}
+		glog.Warningf("deleteGroup: gave up removing %v, it's still in use", group)
+	}()
+}
+
+// reconcileGroups removes the security groups named with prefix whose instances are gone
+func reconcileGroups(prefix string, adopted map[string]bool) {
+	resp, err := client.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("group-name"),
+				Values: []*string{aws.String(prefix + "*")},
+			},
+		},
+	})
+	if err != nil {
+		glog.Warningf("reconcileGroups: %v", err)
+		return
+	}
+
+	for _, group := range resp.SecurityGroups {
+		if adopted[strings.TrimPrefix(*group.GroupName, prefix)] {
+			continue
+		}
+		glog.Infof("reconcileGroups: removing stale %v", *group.GroupName)
+		deleteGroup(*group.GroupId)
+	}
+}
+
+// instanceGroup returns the instance's security group named with prefix, if it has one
+func instanceGroup(instance *ec2.Instance, prefix string) string {
+	for _, group := range instance.SecurityGroups {
+		if group.GroupName != nil && *group.GroupName == prefix+*instance.InstanceId {
+			return *group.GroupId
+		}
+	}
+	return ""
+}
@@ -0,0 +1,391 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/glog"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
+)
+
+// allocatedForTag marks the elastic ips infranetes allocated, with the instance they were allocated for
+const allocatedForTag = "infranetes-allocated-for"
+
+// poolTag puts an elastic ip in the pool of its value, for pods with infranetes.staticip pool:<name> to draw from.  One
+// drawn is tagged allocatedForTag too while its pod is around.
+const poolTag = "infranetes-pool"
+
+// staticIP is the elastic ip of a pod with infranetes.staticip
+type staticIP struct {
+	allocationId  string
+	associationId string
+	address       string
+	// allocated is set when infranetes allocated it for the pod, and releases it with the pod
+	allocated bool
+	// pooled is set when it was drawn from a pool, and goes back to it with the pod
+	pooled bool
+}
+
+// wantedStaticIP returns the elastic ip the pod's infranetes.staticip, or its older infranetes.aws.elasticip, asks for
+func wantedStaticIP(annotations map[string]string) string {
+	if want := common.ParseCommonAnnotations(annotations).StaticIP; want != "" {
+		return want
+	}
+	return parseAWSAnnotations(annotations).elasticIP
+}
+
+// associateStaticIP associates the elastic ip want names, an allocation id or address, with instance, allocating one
+// if want is common.StaticIPAllocate or drawing one from the pool it names with common.StaticIPPoolPrefix
+func associateStaticIP(instance string, want string) (*staticIP, error) {
+	if strings.HasPrefix(want, common.StaticIPPoolPrefix) {
+		return drawStaticIP(instance, strings.TrimPrefix(want, common.StaticIPPoolPrefix))
+	}
+
+	ip := &staticIP{}
+
+	if want == common.StaticIPAllocate {
+		resp, err := client.AllocateAddress(&ec2.AllocateAddressInput{Domain: aws.String(ec2.DomainTypeVpc)})
+		if err != nil {
+			return nil, fmt.Errorf("couldn't allocate an elastic ip: %v", err)
+		}
+		ip.allocationId = *resp.AllocationId
+		ip.address = *resp.PublicIp
+		ip.allocated = true
+
+		// the tag lets a restarted infranetes release it if its pod went away
+		_, err = client.CreateTags(&ec2.CreateTagsInput{
+			Resources: []*string{resp.AllocationId},
+			Tags:      []*ec2.Tag{{Key: aws.String(allocatedForTag), Value: aws.String(instance)}},
+		})
+		if err != nil {
+			glog.Warningf("associateStaticIP: couldn't tag %v: %v", ip.address, err)
+		}
+	} else {
+		address, err := describeAddress(want)
+		if err != nil {
+			return nil, err
+		}
+		// taking it from another instance could take it from a running pod
+		if address.InstanceId != nil && *address.InstanceId != instance {
+			return nil, fmt.Errorf("elastic ip %v is associated with %v", want, *address.InstanceId)
+		}
+		ip.allocationId = aws.StringValue(address.AllocationId)
+		ip.address = aws.StringValue(address.PublicIp)
+	}
+
+	resp, err := client.AssociateAddress(&ec2.AssociateAddressInput{
+		AllocationId: aws.String(ip.allocationId),
+		InstanceId:   aws.String(instance),
+	})
+	if err != nil {
+		if ip.allocated {
+			if err := releaseStaticIP(ip); err != nil {
+				glog.Warningf("associateStaticIP: %v", err)
+			}
+		}
+		return nil, fmt.Errorf("couldn't associate elastic ip %v with %v: %v", ip.address, instance, err)
+	}
+	ip.associationId = aws.StringValue(resp.AssociationId)
+
+	glog.Infof("associateStaticIP: associated %v with %v", ip.address, instance)
+
+	return ip, nil
+}
+
+// drawStaticIP associates a free elastic ip of pool with instance, one that isn't associated or drawn for another
+// instance
+func drawStaticIP(instance string, pool string) (*staticIP, error) {
+	if pool == "" {
+		return nil, fmt.Errorf("no elastic ip pool named")
+	}
+
+	members, err := pooledStaticIPs(pool)
+	if err != nil {
+		return nil, err
+	}
+	drawn, err := allocatedStaticIPs()
+	if err != nil {
+		return nil, err
+	}
+
+	free := []string{}
+	for allocation := range members {
+		if _, ok := drawn[allocation]; !ok {
+			free = append(free, allocation)
+		}
+	}
+	if len(free) == 0 {
+		return nil, fmt.Errorf("no free elastic ip in pool %v", pool)
+	}
+	sort.Strings(free)
+
+	resp, err := client.DescribeAddresses(&ec2.DescribeAddressesInput{AllocationIds: aws.StringSlice(free)})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get the elastic ips of pool %v: %v", pool, err)
+	}
+
+	for _, address := range resp.Addresses {
+		if address.AllocationId == nil || address.AssociationId != nil {
+			continue
+		}
+
+		// another infranetes drawing the same ip can't take it away once it's associated, so it's only tagged after
+		assoc, err := client.AssociateAddress(&ec2.AssociateAddressInput{
+			AllocationId:       address.AllocationId,
+			InstanceId:         aws.String(instance),
+			AllowReassociation: aws.Bool(false),
+		})
+		if err != nil {
+			if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "Resource.AlreadyAssociated" {
+				continue
+			}
+			return nil, fmt.Errorf("couldn't associate elastic ip %v with %v: %v", aws.StringValue(address.PublicIp), instance, err)
+		}
+
+		ip := &staticIP{
+			allocationId:  *address.AllocationId,
+			associationId: aws.StringValue(assoc.AssociationId),
+			address:       aws.StringValue(address.PublicIp),
+			pooled:        true,
+		}
+
+		_, err = client.CreateTags(&ec2.CreateTagsInput{
+			Resources: []*string{address.AllocationId},
+			Tags:      []*ec2.Tag{{Key: aws.String(allocatedForTag), Value: aws.String(instance)}},
+		})
+		if err != nil {
+			glog.Warningf("drawStaticIP: couldn't tag %v: %v", ip.address, err)
+		}
+
+		glog.Infof("drawStaticIP: associated %v of pool %v with %v", ip.address, pool, instance)
+
+		return ip, nil
+	}
+
+	return nil, fmt.Errorf("no free elastic ip in pool %v", pool)
+}
+
+// describeAddress returns the elastic ip with the allocation id or address want
+func describeAddress(want string) (*ec2.Address, error) {
+	input := &ec2.DescribeAddressesInput{}
+	if strings.HasPrefix(want, "eipalloc-") {
+		input.AllocationIds = []*string{aws.String(want)}
+	} else {
+		input.PublicIps = []*string{aws.String(want)}
+	}
+
+	resp, err := client.DescribeAddresses(input)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get elastic ip %v: %v", want, err)
+	}
+	if len(resp.Addresses) != 1 || resp.Addresses[0].AllocationId == nil {
+		return nil, fmt.Errorf("elastic ip %v not found in the vpc", want)
+	}
+
+	return resp.Addresses[0], nil
+}
+
+// disassociateStaticIP takes the elastic ip off its instance, which is given a public ip of its own again if its
+// subnet gives them
+func disassociateStaticIP(ip *staticIP) error {
+	if ip.associationId == "" {
+		return nil
+	}
+
+	_, err := client.DisassociateAddress(&ec2.DisassociateAddressInput{AssociationId: aws.String(ip.associationId)})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); !ok || awsErr.Code() != "InvalidAssociationID.NotFound" {
+			return fmt.Errorf("couldn't disassociate elastic ip %v: %v", ip.address, err)
+		}
+	}
+	ip.associationId = ""
+
+	glog.Infof("disassociateStaticIP: disassociated %v", ip.address)
+
+	return nil
+}
+
+// releaseStaticIP releases an elastic ip infranetes allocated, or returns one drawn from a pool to it
+func releaseStaticIP(ip *staticIP) error {
+	switch {
+	case ip.pooled:
+		return returnAddress(ip.allocationId)
+	case ip.allocated:
+		return releaseAddress(ip.allocationId)
+	}
+
+	return nil
+}
+
+func releaseAddress(allocation string) error {
+	_, err := client.ReleaseAddress(&ec2.ReleaseAddressInput{AllocationId: aws.String(allocation)})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); !ok || awsErr.Code() != "InvalidAllocationID.NotFound" {
+			return fmt.Errorf("couldn't release elastic ip %v: %v", allocation, err)
+		}
+	}
+
+	glog.Infof("releaseAddress: released %v", allocation)
+
+	return nil
+}
+
+// returnAddress returns a disassociated elastic ip drawn from a pool to it
+func returnAddress(allocation string) error {
+	_, err := client.DeleteTags(&ec2.DeleteTagsInput{
+		Resources: []*string{aws.String(allocation)},
+		Tags:      []*ec2.Tag{{Key: aws.String(allocatedForTag)}},
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't return elastic ip %v to its pool: %v", allocation, err)
+	}
+
+	glog.Infof("returnAddress: returned %v to its pool", allocation)
+
+	return nil
+}
+
+// pooledStaticIPs returns the elastic ips in pool, or in any pool if it's ""
+func pooledStaticIPs(pool string) (map[string]bool, error) {
+	filters := []*ec2.Filter{
+		{
+			Name:   aws.String("key"),
+			Values: []*string{aws.String(poolTag)},
+		},
+	}
+	if pool != "" {
+		filters = append(filters, &ec2.Filter{Name: aws.String("value"), Values: []*string{aws.String(pool)}})
+	}
+
+	resp, err := client.DescribeTags(&ec2.DescribeTagsInput{Filters: filters})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list pooled elastic ips: %v", err)
+	}
+
+	pooled := make(map[string]bool)
+	for _, tag := range resp.Tags {
+		pooled[aws.StringValue(tag.ResourceId)] = true
+	}
+
+	return pooled, nil
+}
+
+// allocatedStaticIPs returns the elastic ips infranetes allocated or drew from a pool, and the instances they were
+// allocated for
+func allocatedStaticIPs() (map[string]string, error) {
+	resp, err := client.DescribeTags(&ec2.DescribeTagsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("key"),
+				Values: []*string{aws.String(allocatedForTag)},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list allocated elastic ips: %v", err)
+	}
+
+	allocated := make(map[string]string)
+	for _, tag := range resp.Tags {
+		allocated[aws.StringValue(tag.ResourceId)] = aws.StringValue(tag.Value)
+	}
+
+	return allocated, nil
+}
+
+// instanceStaticIP returns the elastic ip associated with instance, or allocated or drawn for it and disassociated when
+// its pod was stopped, nil if it has none
+func instanceStaticIP(instance string, allocated map[string]string, pooled map[string]bool) (*staticIP, error) {
+	resp, err := client.DescribeAddresses(&ec2.DescribeAddressesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("instance-id"),
+				Values: []*string{aws.String(instance)},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get elastic ip of %v: %v", instance, err)
+	}
+
+	for _, address := range resp.Addresses {
+		if address.AllocationId == nil {
+			continue
+		}
+		_, ok := allocated[*address.AllocationId]
+		return &staticIP{
+			allocationId:  *address.AllocationId,
+			associationId: aws.StringValue(address.AssociationId),
+			address:       aws.StringValue(address.PublicIp),
+			allocated:     ok && !pooled[*address.AllocationId],
+			pooled:        ok && pooled[*address.AllocationId],
+		}, nil
+	}
+
+	for allocation, allocatedFor := range allocated {
+		if allocatedFor == instance {
+			return &staticIP{allocationId: allocation, allocated: !pooled[allocation], pooled: pooled[allocation]}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// reconcileStaticIPs releases the elastic ips infranetes allocated for instances it didn't find at startup, and
+// returns those it drew for them to their pools
+func reconcileStaticIPs(allocated map[string]string, pooled map[string]bool, adopted map[string]bool) {
+	for allocation, instance := range allocated {
+		if adopted[instance] {
+			continue
+		}
+
+		if !pooled[allocation] {
+			glog.Infof("reconcileStaticIPs: releasing stale %v of %v", allocation, instance)
+			if err := releaseAddress(allocation); err != nil {
+				glog.Warningf("reconcileStaticIPs: %v", err)
+			}
+			continue
+		}
+
+		glog.Infof("reconcileStaticIPs: returning stale %v of %v to its pool", allocation, instance)
+		// an instance that's still around has to give it up first, or it isn't free
+		address, err := describeAddress(allocation)
+		if err != nil {
+			glog.Warningf("reconcileStaticIPs: %v", err)
+			continue
+		}
+		if aws.StringValue(address.InstanceId) == instance {
+			ip := &staticIP{allocationId: allocation, associationId: aws.StringValue(address.AssociationId), address: aws.StringValue(address.PublicIp)}
+			if err := disassociateStaticIP(ip); err != nil {
+				glog.Warningf("reconcileStaticIPs: %v", err)
+				continue
+			}
+		}
+		if err := returnAddress(allocation); err != nil {
+			glog.Warningf("reconcileStaticIPs: %v", err)
+		}
+	}
+}
+
+// setStaticIP associates the elastic ip the pod's annotations ask for with instance, returning it and the state of its
+// association.  A pod whose static ip couldn't be associated still runs, with the public ip it booted with.
+func setStaticIP(instance string, annotations map[string]string) (*staticIP, string) {
+	want := wantedStaticIP(annotations)
+	if want == "" {
+		return nil, ""
+	}
+
+	ip, err := associateStaticIP(instance, want)
+	if err != nil {
+		glog.Warningf("setStaticIP: %v", err)
+		return nil, common.StaticIPFailed(err)
+	}
+
+	return ip, common.StaticIPAssociated
+}
@@ -0,0 +1,324 @@
+package aws
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"testing"
+)
+
+// testAddress is an elastic ip of testAddresses
+type testAddress struct {
+	ip          string
+	instance    string
+	association string
+	tags        map[string]string
+}
+
+// testAddresses answers the elastic ip calls of client from addresses, by allocation id, calling before, unless it's
+// nil, before each
+func testAddresses(t *testing.T, addresses map[string]*testAddress, before func(action string)) func() {
+	associations := 0
+
+	return testEC2(t, func(action string, form url.Values) (string, error) {
+		if before != nil {
+			before(action)
+		}
+
+		switch action {
+		case "DescribeTags":
+			filters := make(map[string]string)
+			for i := 1; form.Get(fmt.Sprintf("Filter.%d.Name", i)) != ""; i++ {
+				filters[form.Get(fmt.Sprintf("Filter.%d.Name", i))] = form.Get(fmt.Sprintf("Filter.%d.Value.1", i))
+			}
+			body := "<tagSet>"
+			for allocation, address := range addresses {
+				for key, value := range address.tags {
+					if key != filters["key"] || (filters["value"] != "" && value != filters["value"]) {
+						continue
+					}
+					body += fmt.Sprintf("<item><resourceId>%v</resourceId><resourceType>elastic-ip</resourceType><key>%v</key><value>%v</value></item>", allocation, key, value)
+				}
+			}
+			return body + "</tagSet>", nil
+		case "DescribeAddresses":
+			body := "<addressesSet>"
+			for allocation, address := range addresses {
+				match := true
+				if want := form["AllocationId.1"]; len(want) > 0 {
+					match = false
+					for i := 1; form.Get(fmt.Sprintf("AllocationId.%d", i)) != ""; i++ {
+						match = match || form.Get(fmt.Sprintf("AllocationId.%d", i)) == allocation
+					}
+				}
+				if want := form.Get("PublicIp.1"); want != "" && want != address.ip {
+					match = false
+				}
+				if form.Get("Filter.1.Name") == "instance-id" && form.Get("Filter.1.Value.1") != address.instance {
+					match = false
+				}
+				if !match {
+					continue
+				}
+				body += fmt.Sprintf("<item><publicIp>%v</publicIp><allocationId>%v</allocationId><domain>vpc</domain>", address.ip, allocation)
+				if address.instance != "" {
+					body += fmt.Sprintf("<instanceId>%v</instanceId><associationId>%v</associationId>", address.instance, address.association)
+				}
+				body += "</item>"
+			}
+			return body + "</addressesSet>", nil
+		case "AllocateAddress":
+			allocation := fmt.Sprintf("eipalloc-new%d", len(addresses))
+			addresses[allocation] = &testAddress{ip: fmt.Sprintf("198.51.100.%d", len(addresses)), tags: map[string]string{}}
+			return fmt.Sprintf("<publicIp>%v</publicIp><allocationId>%v</allocationId><domain>vpc</domain>", addresses[allocation].ip, allocation), nil
+		case "AssociateAddress":
+			address, ok := addresses[form.Get("AllocationId")]
+			if !ok {
+				return "", fmt.Errorf("InvalidAllocationID.NotFound")
+			}
+			if address.instance != "" && form.Get("AllowReassociation") != "true" {
+				return "", fmt.Errorf("Resource.AlreadyAssociated")
+			}
+			associations++
+			address.instance = form.Get("InstanceId")
+			address.association = fmt.Sprintf("eipassoc-%d", associations)
+			return fmt.Sprintf("<associationId>%v</associationId>", address.association), nil
+		case "DisassociateAddress":
+			for _, address := range addresses {
+				if address.association == form.Get("AssociationId") {
+					address.instance = ""
+					address.association = ""
+					return "", nil
+				}
+			}
+			return "", fmt.Errorf("InvalidAssociationID.NotFound")
+		case "ReleaseAddress":
+			if _, ok := addresses[form.Get("AllocationId")]; !ok {
+				return "", fmt.Errorf("InvalidAllocationID.NotFound")
+			}
+			delete(addresses, form.Get("AllocationId"))
+			return "", nil
+		case "CreateTags", "DeleteTags":
+			address, ok := addresses[form.Get("ResourceId.1")]
+			if !ok {
+				return "", fmt.Errorf("InvalidID")
+			}
+			if action == "CreateTags" {
+				address.tags[form.Get("Tag.1.Key")] = form.Get("Tag.1.Value")
+			} else {
+				delete(address.tags, form.Get("Tag.1.Key"))
+			}
+			return "", nil
+		}
+		t.Errorf("unexpected %v", action)
+		return "", fmt.Errorf("InvalidAction")
+	})
+}
+
+func testPool() map[string]*testAddress {
+	return map[string]*testAddress{
+		"eipalloc-1": {ip: "203.0.113.1", tags: map[string]string{poolTag: "web"}},
+		"eipalloc-2": {ip: "203.0.113.2", tags: map[string]string{poolTag: "web"}},
+		"eipalloc-3": {ip: "203.0.113.3", tags: map[string]string{poolTag: "db"}},
+		"eipalloc-4": {ip: "203.0.113.4", tags: map[string]string{}},
+	}
+}
+
+func TestDrawStaticIP(t *testing.T) {
+	addresses := testPool()
+	// one of the pool is another infranetes', associated or not
+	addresses["eipalloc-1"].instance = "i-other"
+	addresses["eipalloc-1"].association = "eipassoc-other"
+	addresses["eipalloc-1"].tags[allocatedForTag] = "i-other"
+	defer testAddresses(t, addresses, nil)()
+
+	ip, err := associateStaticIP("i-1", "pool:web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip.allocationId != "eipalloc-2" || ip.address != "203.0.113.2" || !ip.pooled || ip.allocated || ip.associationId == "" {
+		t.Errorf("drew %+v", ip)
+	}
+	if addresses["eipalloc-2"].instance != "i-1" || addresses["eipalloc-2"].tags[allocatedForTag] != "i-1" {
+		t.Errorf("drawn ip is %+v", addresses["eipalloc-2"])
+	}
+
+	// a stopped pod's ip is disassociated but still its own
+	if err := disassociateStaticIP(ip); err != nil {
+		t.Fatal(err)
+	}
+	if ip, err := associateStaticIP("i-2", "pool:web"); err == nil {
+		t.Errorf("drew %+v from an empty pool", ip)
+	}
+
+	if err := releaseStaticIP(ip); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := addresses["eipalloc-2"]; !ok {
+		t.Fatalf("pooled ip was released")
+	}
+	if _, ok := addresses["eipalloc-2"].tags[allocatedForTag]; ok || addresses["eipalloc-2"].tags[poolTag] != "web" {
+		t.Errorf("returned ip has tags %v", addresses["eipalloc-2"].tags)
+	}
+
+	ip, err = associateStaticIP("i-2", "pool:web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip.allocationId != "eipalloc-2" {
+		t.Errorf("drew %+v instead of the returned ip", ip)
+	}
+}
+
+func TestDrawStaticIPRace(t *testing.T) {
+	addresses := testPool()
+	// another infranetes associates the first free ip after it was described, and hasn't tagged it yet
+	defer testAddresses(t, addresses, func(action string) {
+		if action == "AssociateAddress" && addresses["eipalloc-1"].instance == "" {
+			addresses["eipalloc-1"].instance = "i-other"
+			addresses["eipalloc-1"].association = "eipassoc-other"
+		}
+	})()
+
+	ip, err := drawStaticIP("i-1", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip.allocationId != "eipalloc-2" {
+		t.Errorf("drew %+v", ip)
+	}
+	if addresses["eipalloc-1"].instance != "i-other" {
+		t.Errorf("took %v from another instance", addresses["eipalloc-1"].ip)
+	}
+	if _, ok := addresses["eipalloc-1"].tags[allocatedForTag]; ok {
+		t.Errorf("tagged another instance's ip")
+	}
+}
+
+func TestAllocateStaticIP(t *testing.T) {
+	addresses := testPool()
+	defer testAddresses(t, addresses, nil)()
+
+	ip, err := associateStaticIP("i-1", "allocate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ip.allocated || ip.pooled || addresses[ip.allocationId].tags[allocatedForTag] != "i-1" || addresses[ip.allocationId].instance != "i-1" {
+		t.Errorf("allocated %+v", ip)
+	}
+
+	if err := disassociateStaticIP(ip); err != nil {
+		t.Fatal(err)
+	}
+	if err := releaseStaticIP(ip); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := addresses[ip.allocationId]; ok {
+		t.Errorf("allocated ip wasn't released")
+	}
+
+	// one named by the pod is neither
+	ip, err = associateStaticIP("i-1", "203.0.113.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip.allocationId != "eipalloc-4" || ip.allocated || ip.pooled {
+		t.Errorf("associated %+v", ip)
+	}
+	if _, err := associateStaticIP("i-2", "eipalloc-4"); err == nil {
+		t.Errorf("took an ip from another instance")
+	}
+	if err := releaseStaticIP(ip); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := addresses["eipalloc-4"]; !ok {
+		t.Errorf("named ip was released")
+	}
+}
+
+func TestInstanceStaticIP(t *testing.T) {
+	addresses := testPool()
+	addresses["eipalloc-1"].instance = "i-1"
+	addresses["eipalloc-1"].association = "eipassoc-1"
+	addresses["eipalloc-1"].tags[allocatedForTag] = "i-1"
+	// stopped, so disassociated
+	addresses["eipalloc-2"].tags[allocatedForTag] = "i-2"
+	addresses["eipalloc-5"] = &testAddress{ip: "203.0.113.5", tags: map[string]string{allocatedForTag: "i-3"}}
+	addresses["eipalloc-4"].instance = "i-4"
+	addresses["eipalloc-4"].association = "eipassoc-4"
+	defer testAddresses(t, addresses, nil)()
+
+	allocated, err := allocatedStaticIPs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pooled, err := pooledStaticIPs("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for instance, want := range map[string]staticIP{
+		"i-1": {allocationId: "eipalloc-1", associationId: "eipassoc-1", address: "203.0.113.1", pooled: true},
+		"i-2": {allocationId: "eipalloc-2", pooled: true},
+		"i-3": {allocationId: "eipalloc-5", allocated: true},
+		"i-4": {allocationId: "eipalloc-4", associationId: "eipassoc-4", address: "203.0.113.4"},
+	} {
+		ip, err := instanceStaticIP(instance, allocated, pooled)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ip == nil || *ip != want {
+			t.Errorf("%v has %+v, expected %+v", instance, ip, want)
+		}
+	}
+
+	if ip, err := instanceStaticIP("i-5", allocated, pooled); err != nil || ip != nil {
+		t.Errorf("i-5 has %+v: %v", ip, err)
+	}
+}
+
+func TestReconcileStaticIPs(t *testing.T) {
+	addresses := testPool()
+	// adopted
+	addresses["eipalloc-1"].instance = "i-1"
+	addresses["eipalloc-1"].association = "eipassoc-1"
+	addresses["eipalloc-1"].tags[allocatedForTag] = "i-1"
+	// drawn for an instance that's still around but not a pod's
+	addresses["eipalloc-2"].instance = "i-2"
+	addresses["eipalloc-2"].association = "eipassoc-2"
+	addresses["eipalloc-2"].tags[allocatedForTag] = "i-2"
+	// drawn for an instance that's gone
+	addresses["eipalloc-3"].tags[allocatedForTag] = "i-3"
+	// allocated for an instance that's gone
+	addresses["eipalloc-5"] = &testAddress{ip: "203.0.113.5", tags: map[string]string{allocatedForTag: "i-5"}}
+	defer testAddresses(t, addresses, nil)()
+
+	allocated, err := allocatedStaticIPs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pooled, err := pooledStaticIPs("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reconcileStaticIPs(allocated, pooled, map[string]bool{"i-1": true})
+
+	left := []string{}
+	for allocation := range addresses {
+		left = append(left, allocation)
+	}
+	sort.Strings(left)
+	if fmt.Sprint(left) != "[eipalloc-1 eipalloc-2 eipalloc-3 eipalloc-4]" {
+		t.Errorf("left %v", left)
+	}
+
+	if addresses["eipalloc-1"].instance != "i-1" || addresses["eipalloc-1"].tags[allocatedForTag] != "i-1" {
+		t.Errorf("adopted ip is %+v", addresses["eipalloc-1"])
+	}
+	for _, allocation := range []string{"eipalloc-2", "eipalloc-3"} {
+		address := addresses[allocation]
+		if _, ok := address.tags[allocatedForTag]; ok || address.instance != "" || address.tags[poolTag] == "" {
+			t.Errorf("%v wasn't returned to its pool: %+v", allocation, address)
+		}
+	}
+}
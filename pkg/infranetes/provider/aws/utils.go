@@ -91,7 +91,7 @@ func overrideVMDefault(vm *awsvm.VM, anno *awsAnnotations) {
 	}
 }
 
-func findBase(subnetId *string) (*string, error) {
+func describeSubnet(subnetId *string) (*ec2.Subnet, error) {
 	req := &ec2.DescribeSubnetsInput{SubnetIds: []*string{subnetId}}
 	resp, err := client.DescribeSubnets(req)
 	if err != nil {
@@ -106,7 +106,14 @@ func findBase(subnetId *string) (*string, error) {
 		return nil, errors.New(msg)
 	}
 
-	subnet := resp.Subnets[0]
+	return resp.Subnets[0], nil
+}
+
+func findBase(subnetId *string) (*string, error) {
+	subnet, err := describeSubnet(subnetId)
+	if err != nil {
+		return nil, err
+	}
 
 	if *subnet.MapPublicIpOnLaunch != true {
 		msg := fmt.Sprintf("Subnet %v isn't configured correctly, doesn't provide public ip on launch", *subnet.SubnetId)
@@ -124,11 +131,30 @@ func findBase(subnetId *string) (*string, error) {
 	return base, err
 }
 
+// findIPv6CIDR returns the ipv6 range associated with the subnet, which pods' ipv6 addresses come from
+func findIPv6CIDR(subnetId *string) (string, error) {
+	subnet, err := describeSubnet(subnetId)
+	if err != nil {
+		return "", err
+	}
+
+	for _, assoc := range subnet.Ipv6CidrBlockAssociationSet {
+		if assoc.Ipv6CidrBlock != nil && assoc.Ipv6CidrBlockState != nil && aws.StringValue(assoc.Ipv6CidrBlockState.State) == ec2.SubnetCidrBlockStateCodeAssociated {
+			return *assoc.Ipv6CidrBlock, nil
+		}
+	}
+
+	return "", fmt.Errorf("subnet %v has no ipv6 cidr", *subnetId)
+}
+
 func baseFromCidr(cidr *string) (*string, error) {
 	ip, _, err := net.ParseCIDR(*cidr)
 	if err != nil {
 		return nil, err
 	}
+	if ip.To4() == nil {
+		return nil, fmt.Errorf("%v isn't an ipv4 cidr", *cidr)
+	}
 
 	splits := strings.Split(ip.String(), ".")
 	if len(splits) != 4 {
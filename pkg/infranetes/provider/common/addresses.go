@@ -0,0 +1,244 @@
+package common
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/golang/glog"
+
+	"github.com/apporbit/infranetes/cmd/infranetes/flags"
+)
+
+const (
+	IPPolicyPrivate   = "private"
+	IPPolicyPublic    = "public"
+	IPPolicyInterface = "interface"
+	IPPolicyCIDR      = "cidr"
+
+	// IPsAnnotation lists all of a sandbox's addresses in its status, as the CRI only has room for one
+	IPsAnnotation = "infranetes.ips"
+	// IPv6Annotation is a dual-stack sandbox's ipv6 address in its status
+	IPv6Annotation = "infranetes.ipv6"
+	// LBHealthAnnotation is the health the load balancer of a sandbox with infranetes.lb.group last reported
+	LBHealthAnnotation = "infranetes.lb.health"
+	// ProxyAnnotation is the health of a sandbox's kube-proxy, as its VM last reported
+	ProxyAnnotation = "infranetes.proxy"
+	// NetworkPolicyAnnotation is whether the VM of a sandbox enforces its NetworkPolicies, as it last reported
+	NetworkPolicyAnnotation = "infranetes.networkpolicy"
+	// StaticIPAnnotation and StaticIPStateAnnotation are the static public ip of a sandbox with infranetes.staticip in
+	// its status, and whether it's associated with the sandbox's VM
+	StaticIPAnnotation      = "infranetes.staticip.address"
+	StaticIPStateAnnotation = "infranetes.staticip.state"
+
+	// StaticIPAllocate as infranetes.staticip has a static ip allocated for the pod
+	StaticIPAllocate = "allocate"
+	// StaticIPPoolPrefix followed by a pool's name as infranetes.staticip has a free static ip of that pool, one tagged
+	// infranetes-pool with its name, associated with the pod and returned to the pool with it (aws)
+	StaticIPPoolPrefix = "pool:"
+
+	StaticIPAssociated    = "associated"
+	StaticIPDisassociated = "disassociated"
+)
+
+// StaticIPFailed is the state of a static ip that couldn't be associated
+func StaticIPFailed(err error) string {
+	return fmt.Sprintf("failed: %v", err)
+}
+
+// Address is one of a VM's addresses
+type Address struct {
+	IP string
+	// Public is set for addresses from outside the VM's network, such as a cloud's NAT address
+	Public bool
+	// Interface is the VM's interface with the address, empty if it isn't known
+	Interface string
+}
+
+// CloudAddresses returns the addresses a cloud gave a VM.  Either can be nil.
+func CloudAddresses(public net.IP, private ...net.IP) []Address {
+	addrs := []Address{}
+
+	for _, ip := range private {
+		if ip != nil {
+			addrs = append(addrs, Address{IP: ip.String()})
+		}
+	}
+	if public != nil {
+		addrs = append(addrs, Address{IP: public.String(), Public: true})
+	}
+
+	return addrs
+}
+
+// AddInterfaceAddresses adds what vmserver knows about the VM's interfaces to addrs.  vmservers that can't list
+// them leave addrs as they are.
+func AddInterfaceAddresses(client Client, addrs []Address) []Address {
+	resp, err := client.ListAddresses()
+	if err != nil {
+		glog.V(1).Infof("AddInterfaceAddresses: %v", err)
+		return addrs
+	}
+
+	for _, ifaddr := range resp.Addresses {
+		found := false
+		for i := range addrs {
+			if addrs[i].IP == ifaddr.Ip {
+				addrs[i].Interface = ifaddr.Interface
+				found = true
+			}
+		}
+		if !found {
+			addrs = append(addrs, Address{IP: ifaddr.Ip, Interface: ifaddr.Interface})
+		}
+	}
+
+	return addrs
+}
+
+// AddressIPs returns the ips of addrs
+func AddressIPs(addrs []Address) []string {
+	ips := []string{}
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP)
+	}
+	return ips
+}
+
+// HasAddress reports whether ip is one of addrs
+func HasAddress(addrs []Address, ip string) bool {
+	for _, addr := range addrs {
+		if addr.IP == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// IPPolicy picks one of a VM's addresses.  It's written as private, public, interface:<name> or cidr:<cidr>.
+type IPPolicy struct {
+	kind  string
+	iface string
+	cidr  *net.IPNet
+}
+
+func ParseIPPolicy(policy string) (*IPPolicy, error) {
+	parts := strings.SplitN(policy, ":", 2)
+
+	p := &IPPolicy{kind: parts[0]}
+
+	switch {
+	case (p.kind == IPPolicyPrivate || p.kind == IPPolicyPublic) && len(parts) == 1:
+	case p.kind == IPPolicyInterface && len(parts) == 2 && parts[1] != "":
+		p.iface = parts[1]
+	case p.kind == IPPolicyCIDR && len(parts) == 2:
+		_, cidr, err := net.ParseCIDR(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid ip policy %q: %v", policy, err)
+		}
+		p.cidr = cidr
+	default:
+		return nil, fmt.Errorf("invalid ip policy %q, want private, public, interface:<name> or cidr:<cidr>", policy)
+	}
+
+	return p, nil
+}
+
+// Select returns the first of addrs the policy matches.  The pod's ip is its ipv4 one, so private and public only
+// match ipv4 addresses.
+func (p *IPPolicy) Select(addrs []Address) (string, error) {
+	for _, addr := range addrs {
+		var match bool
+
+		switch p.kind {
+		case IPPolicyPrivate:
+			match = !addr.Public && isIPv4(addr.IP)
+		case IPPolicyPublic:
+			match = addr.Public && isIPv4(addr.IP)
+		case IPPolicyInterface:
+			match = addr.Interface == p.iface
+		case IPPolicyCIDR:
+			match = p.cidr.Contains(net.ParseIP(addr.IP))
+		}
+
+		if match {
+			return addr.IP, nil
+		}
+	}
+
+	return "", fmt.Errorf("no address matches ip policy %v in %v", p, AddressIPs(addrs))
+}
+
+// SelectIPv6 returns the first of addrs that's a global ipv6 address, or "" if there's none
+func SelectIPv6(addrs []Address) string {
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr.IP)
+		if ip != nil && ip.To4() == nil && ip.IsGlobalUnicast() {
+			return addr.IP
+		}
+	}
+
+	return ""
+}
+
+func isIPv4(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && parsed.To4() != nil
+}
+
+func (p *IPPolicy) String() string {
+	switch p.kind {
+	case IPPolicyInterface:
+		return p.kind + ":" + p.iface
+	case IPPolicyCIDR:
+		return p.kind + ":" + p.cidr.String()
+	}
+	return p.kind
+}
+
+// AgentIPTag is the tag, or label on gcp, that keeps the address a VM's vmserver was reached on when it booted, so a
+// restarted infranetes, which doesn't know the pod's infranetes.agentip until it's reached it, reaches it there again
+const AgentIPTag = "infranetes-agent-ip"
+
+// AgentIP returns the address a restarted infranetes reaches a VM's vmserver on: saved, the one it was reached on
+// when it booted, while it's still one of addrs, or else the one -agent-ip-policy picks
+func AgentIP(saved string, addrs []Address) (string, error) {
+	if saved != "" && HasAddress(addrs, saved) {
+		return saved, nil
+	}
+
+	_, agentPolicy, err := IPPolicies(nil)
+	if err != nil {
+		return "", err
+	}
+
+	return agentPolicy.Select(addrs)
+}
+
+// IPPolicies returns the policies choosing a sandbox's pod ip and the address its vmserver is reached on, from its
+// annotations or else infranetes' flags.  The VM's interfaces aren't known before vmserver is reached, so the agent's
+// address can't be chosen by interface.
+func IPPolicies(annotations map[string]string) (pod *IPPolicy, agent *IPPolicy, err error) {
+	cAnno := ParseCommonAnnotations(annotations)
+
+	podPolicy := *flags.PodIPPolicy
+	if cAnno.PodIPPolicy != "" {
+		podPolicy = cAnno.PodIPPolicy
+	}
+	agentPolicy := *flags.AgentIPPolicy
+	if cAnno.AgentIPPolicy != "" {
+		agentPolicy = cAnno.AgentIPPolicy
+	}
+
+	if pod, err = ParseIPPolicy(podPolicy); err != nil {
+		return nil, nil, err
+	}
+	if agent, err = ParseIPPolicy(agentPolicy); err != nil {
+		return nil, nil, err
+	}
+	if agent.kind == IPPolicyInterface {
+		return nil, nil, fmt.Errorf("the agent's address can't be chosen by interface")
+	}
+
+	return pod, agent, nil
+}
@@ -0,0 +1,58 @@
+package common
+
+import (
+	"net"
+	"testing"
+
+	"github.com/apporbit/infranetes/cmd/infranetes/flags"
+	"github.com/apporbit/infranetes/pkg/common/gcp"
+)
+
+func TestAgentIP(t *testing.T) {
+	saved := *flags.AgentIPPolicy
+	*flags.AgentIPPolicy = "private"
+	defer func() {
+		*flags.AgentIPPolicy = saved
+	}()
+
+	addrs := CloudAddresses(net.ParseIP("203.0.113.5"), net.ParseIP("10.0.0.5"))
+
+	for _, test := range []struct {
+		saved string
+		want  string
+	}{
+		// a pod with infranetes.agentip public was reached on its public address
+		{"203.0.113.5", "203.0.113.5"},
+		{"10.0.0.5", "10.0.0.5"},
+		// VMs booted before the address was kept, or whose address changed since, are reached by the flag
+		{"", "10.0.0.5"},
+		{"203.0.113.9", "10.0.0.5"},
+	} {
+		ip, err := AgentIP(test.saved, addrs)
+		if err != nil {
+			t.Errorf("%q: %v", test.saved, err)
+		} else if ip != test.want {
+			t.Errorf("%q: reached on %v, want %v", test.saved, ip, test.want)
+		}
+	}
+}
+
+func TestAgentIPLabel(t *testing.T) {
+	for _, ip := range []string{"10.0.0.5", "2600:1f18::a"} {
+		label := gcp.AddressLabel(ip)
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				t.Errorf("%v is labeled %q", ip, label)
+				break
+			}
+		}
+		if addr := gcp.LabelAddress(label); addr != ip {
+			t.Errorf("%v is labeled %q, which is %v", ip, label, addr)
+		}
+	}
+
+	addrs := CloudAddresses(net.ParseIP("203.0.113.5"), net.ParseIP("10.0.0.5"))
+	if ip, err := AgentIP(gcp.LabelAddress(""), addrs); err != nil || ip != "10.0.0.5" {
+		t.Errorf("an unlabeled VM is reached on %v: %v", ip, err)
+	}
+}
@@ -12,11 +12,14 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 
 	"github.com/apporbit/infranetes/cmd/infranetes/flags"
@@ -25,8 +28,16 @@ import (
 	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
 )
 
+const (
+	maxLogRetries    = 10
+	logRetryInterval = 5 * time.Second
+)
+
 type Client interface {
 	CreateContainer(req *kubeapi.CreateContainerRequest) (*kubeapi.CreateContainerResponse, error)
+	// CreateContainerWithAuth has the vmserver pull the container's image with auth, the agent must have
+	// common.FeatureRegistryAuth
+	CreateContainerWithAuth(req *kubeapi.CreateContainerRequest, auth *kubeapi.AuthConfig) (*kubeapi.CreateContainerResponse, error)
 	StartContainer(req *kubeapi.StartContainerRequest) (*kubeapi.StartContainerResponse, error)
 	StopContainer(req *kubeapi.StopContainerRequest) (*kubeapi.StopContainerResponse, error)
 	RemoveContainer(req *kubeapi.RemoveContainerRequest) (*kubeapi.RemoveContainerResponse, error)
@@ -38,9 +49,20 @@ type Client interface {
 	PortForward(req *kubeapi.PortForwardRequest) (*kubeapi.PortForwardResponse, error)
 
 	StartProxy() error
+	StopProxy() error
+	UpdateProxyKubeconfig(kubeconfig []byte) error
+	ProxyStatus() (*common.ProxyStatusResponse, error)
+	StartNetworkPolicy() error
+	NetworkPolicyStatus() (*common.NetworkPolicyStatusResponse, error)
+	SetupOverlay(req *common.SetupOverlayRequest) (*common.SetupOverlayResponse, error)
+	SetupCNI(req *common.SetupCNIRequest) (*common.SetupCNIResponse, error)
+	TeardownCNI() error
+	ListAddresses() (*common.ListAddressesResponse, error)
+	SetPortMappings(mappings []*kubeapi.PortMapping) error
 	RunCmd(req *common.RunCmdRequest) error
-	SetPodIP(ip string) error
-	GetPodIP() (string, error)
+	// SetPodIP tells vmserver the pod's ip, and its ipv6 address if it's dual-stack
+	SetPodIP(ip string, ipv6 string) error
+	GetPodIP() (string, string, error)
 	SetSandboxConfig(config *kubeapi.PodSandboxConfig) error
 	GetSandboxConfig() (*kubeapi.PodSandboxConfig, error)
 	CopyFile(file string) error
@@ -53,20 +75,57 @@ type Client interface {
 	SaveLogs(container string, path string) error
 	GetMetric(req *common.GetMetricsRequest) (*common.GetMetricsResponse, error)
 	AddRoute(req *common.AddRouteRequest) (*common.AddRouteResponse, error)
+	Capabilities() (*common.CapabilitiesResponse, error)
+	WatchContainerEvents(ctx context.Context) (ContainerEventStream, error)
+}
+
+// ContainerEventStream delivers container lifecycle events until its context is canceled or the vmserver goes away
+type ContainerEventStream interface {
+	Recv() (*common.ContainerEvent, error)
 }
 
 type RealClient struct {
 	kubeclient kubeapi.RuntimeServiceClient
 	vmclient   common.VMServerClient
 	conn       *grpc.ClientConn
+
+	// caps is what the vmserver last reported, at capsTime.  Its features don't change while it runs, but its
+	// subsystems' readiness does, so it's fetched again once it's capabilitiesTTL old.
+	capsLock sync.Mutex
+	caps     *common.CapabilitiesResponse
+	capsTime time.Time
 }
 
+// capabilitiesTTL is how long a vmserver's reported subsystem readiness is trusted
+const capabilitiesTTL = 30 * time.Second
+
 func (c *RealClient) CreateContainer(req *kubeapi.CreateContainerRequest) (*kubeapi.CreateContainerResponse, error) {
 	resp, err := c.kubeclient.CreateContainer(context.Background(), req)
 
 	return resp, err
 }
 
+func (c *RealClient) CreateContainerWithAuth(req *kubeapi.CreateContainerRequest, auth *kubeapi.AuthConfig) (*kubeapi.CreateContainerResponse, error) {
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	authBytes, err := json.Marshal(auth)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.vmclient.CreateContainerWithAuth(context.Background(), &common.CreateContainerWithAuthRequest{Request: reqBytes, Auth: authBytes})
+	if err != nil {
+		return nil, err
+	}
+
+	glog.Infof("CreateContainerWithAuth: %v was created from %v", resp.ContainerId, resp.ImageRef)
+
+	return &kubeapi.CreateContainerResponse{ContainerId: resp.ContainerId}, nil
+}
+
 func (c *RealClient) StartContainer(req *kubeapi.StartContainerRequest) (*kubeapi.StartContainerResponse, error) {
 	resp, err := c.kubeclient.StartContainer(context.Background(), req)
 
@@ -134,12 +193,36 @@ func (c *RealClient) Ready() error {
 }
 
 func (c *RealClient) StartProxy() error {
-	data, err := ioutil.ReadFile(*flags.Kubeconfig)
+	data, err := LoadProxyKubeconfig(*flags.Kubeconfig)
+	if err != nil {
+		return fmt.Errorf("StartProxy: %v", err)
+	}
 
 	req := &common.StartProxyRequest{
-		ClusterCidr: *flags.ClusterCIDR,
-		Ip:          *flags.MasterIP,
-		Kubeconfig:  data,
+		ClusterCidr:         *flags.ClusterCIDR,
+		Ip:                  *flags.MasterIP,
+		Kubeconfig:          data,
+		Mode:                *flags.ProxyMode,
+		MasqueradeAll:       *flags.ProxyMasqueradeAll,
+		ConntrackMaxPerCore: int32(*flags.ProxyConntrackMaxPerCore),
+		ConntrackMin:        int32(*flags.ProxyConntrackMin),
+		HealthzBindAddress:  *flags.ProxyHealthzBindAddress,
+	}
+	if *flags.ProxySyncPeriod > 0 {
+		req.SyncPeriod = flags.ProxySyncPeriod.String()
+	}
+	if *flags.ProxyMinSyncPeriod > 0 {
+		req.MinSyncPeriod = flags.ProxyMinSyncPeriod.String()
+	}
+	if *flags.ProxyConntrackEstablished > 0 {
+		req.ConntrackTcpEstablishedTimeout = flags.ProxyConntrackEstablished.String()
+	}
+	if *flags.ProxyConntrackCloseWait > 0 {
+		req.ConntrackTcpCloseWaitTimeout = flags.ProxyConntrackCloseWait.String()
+	}
+
+	if caps, err := c.Capabilities(); err == nil && !caps.HasFeature(common.FeatureProxyControl) {
+		glog.Warningf("StartProxy: vmserver predates kube-proxy settings, it runs kube-proxy with its own")
 	}
 
 	_, err = c.vmclient.StartProxy(context.Background(), req)
@@ -147,25 +230,142 @@ func (c *RealClient) StartProxy() error {
 	return err
 }
 
+func (c *RealClient) StopProxy() error {
+	if caps, err := c.Capabilities(); err == nil && !caps.HasFeature(common.FeatureProxyControl) {
+		return fmt.Errorf("StopProxy: vmserver can't stop kube-proxy")
+	}
+
+	_, err := c.vmclient.StopProxy(context.Background(), &common.StopProxyRequest{})
+
+	return err
+}
+
+func (c *RealClient) UpdateProxyKubeconfig(kubeconfig []byte) error {
+	if caps, err := c.Capabilities(); err == nil && !caps.HasFeature(common.FeatureProxyKubeconfig) {
+		return fmt.Errorf("UpdateProxyKubeconfig: vmserver can't update kube-proxy's kubeconfig")
+	}
+
+	_, err := c.vmclient.UpdateProxyKubeconfig(context.Background(), &common.UpdateProxyKubeconfigRequest{Kubeconfig: kubeconfig})
+
+	return err
+}
+
+// ProxyStatus returns the state and health of the VM's kube-proxy
+func (c *RealClient) ProxyStatus() (*common.ProxyStatusResponse, error) {
+	if caps, err := c.Capabilities(); err == nil && !caps.HasFeature(common.FeatureProxyControl) {
+		// older agents only tell us if kube-proxy's goroutine still runs
+		caps, err := c.fetchCapabilities()
+		if err != nil {
+			return nil, err
+		}
+		ready := caps.SubsystemReady(common.SubsystemProxy)
+		return &common.ProxyStatusResponse{Running: ready, Healthy: ready}, nil
+	}
+
+	return c.vmclient.ProxyStatus(context.Background(), &common.ProxyStatusRequest{})
+}
+
+// StartNetworkPolicy has the vmserver enforce the NetworkPolicies that select its pod
+func (c *RealClient) StartNetworkPolicy() error {
+	if caps, err := c.Capabilities(); err == nil && !caps.HasFeature(common.FeatureNetworkPolicy) {
+		return fmt.Errorf("StartNetworkPolicy: vmserver can't enforce network policies")
+	}
+
+	data, err := LoadProxyKubeconfig(*flags.Kubeconfig)
+	if err != nil {
+		return fmt.Errorf("StartNetworkPolicy: %v", err)
+	}
+
+	req := &common.StartNetworkPolicyRequest{Kubeconfig: data}
+	if *flags.NetworkPolicySyncPeriod > 0 {
+		req.SyncPeriod = flags.NetworkPolicySyncPeriod.String()
+	}
+
+	_, err = c.vmclient.StartNetworkPolicy(context.Background(), req)
+
+	return err
+}
+
+func (c *RealClient) NetworkPolicyStatus() (*common.NetworkPolicyStatusResponse, error) {
+	if caps, err := c.Capabilities(); err == nil && !caps.HasFeature(common.FeatureNetworkPolicy) {
+		return nil, fmt.Errorf("NetworkPolicyStatus: vmserver can't enforce network policies")
+	}
+
+	return c.vmclient.NetworkPolicyStatus(context.Background(), &common.NetworkPolicyStatusRequest{})
+}
+
+func (c *RealClient) SetupOverlay(req *common.SetupOverlayRequest) (*common.SetupOverlayResponse, error) {
+	if caps, err := c.Capabilities(); err == nil && !caps.HasFeature(common.FeatureOverlay) {
+		return nil, fmt.Errorf("SetupOverlay: vmserver can't join the overlay")
+	}
+
+	return c.vmclient.SetupOverlay(context.Background(), req)
+}
+
+func (c *RealClient) SetupCNI(req *common.SetupCNIRequest) (*common.SetupCNIResponse, error) {
+	if caps, err := c.Capabilities(); err == nil && !caps.HasFeature(common.FeatureCNI) {
+		return nil, fmt.Errorf("SetupCNI: vmserver can't run CNI plugins")
+	}
+
+	return c.vmclient.SetupCNI(context.Background(), req)
+}
+
+func (c *RealClient) TeardownCNI() error {
+	if caps, err := c.Capabilities(); err == nil && !caps.HasFeature(common.FeatureCNI) {
+		return nil
+	}
+
+	_, err := c.vmclient.TeardownCNI(context.Background(), &common.TeardownCNIRequest{})
+
+	return err
+}
+
+func (c *RealClient) ListAddresses() (*common.ListAddressesResponse, error) {
+	if caps, err := c.Capabilities(); err == nil && !caps.HasFeature(common.FeatureAddresses) {
+		return nil, fmt.Errorf("ListAddresses: vmserver can't list its addresses")
+	}
+
+	return c.vmclient.ListAddresses(context.Background(), &common.ListAddressesRequest{})
+}
+
+func (c *RealClient) SetPortMappings(mappings []*kubeapi.PortMapping) error {
+	if caps, err := c.Capabilities(); err == nil && !caps.HasFeature(common.FeatureHostPorts) {
+		return fmt.Errorf("SetPortMappings: vmserver can't map host ports")
+	}
+
+	req := &common.SetPortMappingsRequest{}
+	for _, mapping := range mappings {
+		req.Mappings = append(req.Mappings, &common.PortMapping{
+			Protocol:      strings.ToLower(mapping.Protocol.String()),
+			ContainerPort: mapping.ContainerPort,
+			HostPort:      mapping.HostPort,
+		})
+	}
+
+	_, err := c.vmclient.SetPortMappings(context.Background(), req)
+
+	return err
+}
+
 func (c *RealClient) RunCmd(req *common.RunCmdRequest) error {
 	_, err := c.vmclient.RunCmd(context.Background(), req)
 
 	return err
 }
 
-func (c *RealClient) SetPodIP(ip string) error {
-	_, err := c.vmclient.SetPodIP(context.Background(), &common.SetIPRequest{Ip: ip})
+func (c *RealClient) SetPodIP(ip string, ipv6 string) error {
+	_, err := c.vmclient.SetPodIP(context.Background(), &common.SetIPRequest{Ip: ip, Ipv6: ipv6})
 
 	return err
 }
 
-func (c *RealClient) GetPodIP() (string, error) {
+func (c *RealClient) GetPodIP() (string, string, error) {
 	resp, err := c.vmclient.GetPodIP(context.Background(), &common.GetIPRequest{})
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	return resp.Ip, err
+	return resp.Ip, resp.Ipv6, err
 }
 
 func (c *RealClient) SetSandboxConfig(config *kubeapi.PodSandboxConfig) error {
@@ -267,35 +467,79 @@ func (c *RealClient) SetHostname(hostname string) error {
 	return err
 }
 
+// SaveLogs copies container's log to path until the container's log ends, reconnecting and resuming from the
+// last saved cursor if the stream drops
 func (c *RealClient) SaveLogs(container string, path string) error {
-	f, err := os.Create(path)
+	_, contId, err := common.ParseContainer(container)
+	if err != nil {
+		return fmt.Errorf("SaveLogs: %v", err)
+	}
+
+	rotation := LogRotation{MaxSize: *flags.LogMaxSize, MaxFiles: *flags.LogMaxFiles}
+	writer, err := NewLogWriter(*flags.LogStateDir, container, path, rotation)
 	if err != nil {
-		msg := fmt.Sprintf("SaveLogs: failed to create path %v: %v", path, err)
-		glog.Warningf(msg)
+		msg := fmt.Sprintf("SaveLogs: failed to create log writer for %v: %v", path, err)
+		glog.Warning(msg)
 		return errors.New(msg)
 	}
 
-	stream, err := c.vmclient.Logs(context.Background(), &common.LogsRequest{ContainerID: container})
+	failures := 0
+	for {
+		progress, err := c.streamLogs(contId, writer)
+		if err == nil {
+			glog.Infof("SaveLogs: log for %v ended", container)
+			return writer.Finish()
+		}
+
+		if grpc.Code(err) == codes.Canceled {
+			glog.Infof("SaveLogs: connection for %v closed, keeping cursor at %v", container, writer.Offset())
+			writer.Close()
+			return err
+		}
+
+		if progress {
+			failures = 0
+		}
+		failures++
+		if failures > maxLogRetries {
+			msg := fmt.Sprintf("SaveLogs: giving up on %v after %d attempts: %v", container, failures, err)
+			glog.Warning(msg)
+			writer.Close()
+			return errors.New(msg)
+		}
+
+		glog.Warningf("SaveLogs: streaming %v failed, resuming from %v in %v: %v", container, writer.Offset(), logRetryInterval, err)
+		time.Sleep(logRetryInterval)
+	}
+}
+
+// streamLogs returns nil when the vmserver ends the stream and if any lines were written
+func (c *RealClient) streamLogs(contId string, writer *LogWriter) (bool, error) {
+	req := &common.LogsRequest{
+		ContainerID: contId,
+		Offset:      writer.Offset(),
+	}
+
+	stream, err := c.vmclient.Logs(context.Background(), req)
 	if err != nil {
-		return fmt.Errorf("SaveLogs: failed: %v", err)
+		return false, err
 	}
 
+	progress := false
 	for {
 		line, err := stream.Recv()
 		if err == io.EOF {
-			break
+			return progress, nil
 		}
 		if err != nil {
-			msg := fmt.Sprintf("SaveLogs: streaming failed: %v", err)
-			glog.Warningf(msg)
-			return fmt.Errorf(msg)
+			return progress, err
 		}
 
-		f.WriteString(line.LogLine)
-		f.WriteString("\n")
+		if err := writer.Write(line); err != nil {
+			return progress, fmt.Errorf("write failed: %v", err)
+		}
+		progress = true
 	}
-
-	return nil
 }
 
 func (c *RealClient) GetMetric(req *common.GetMetricsRequest) (*common.GetMetricsResponse, error) {
@@ -310,6 +554,70 @@ func (c *RealClient) AddRoute(req *common.AddRouteRequest) (*common.AddRouteResp
 	return resp, err
 }
 
+// Capabilities returns what the vmserver last reported, fetching it again when it's older than capabilitiesTTL.  If
+// that fails, the last report is still returned, as its features remain right.
+func (c *RealClient) Capabilities() (*common.CapabilitiesResponse, error) {
+	c.capsLock.Lock()
+	caps, fetched := c.caps, c.capsTime
+	c.capsLock.Unlock()
+
+	if caps != nil && time.Since(fetched) < capabilitiesTTL {
+		return caps, nil
+	}
+
+	fresh, err := c.fetchCapabilities()
+	if err != nil && caps != nil {
+		glog.V(1).Infof("Capabilities: keeping the last report: %v", err)
+		return caps, nil
+	}
+
+	return fresh, err
+}
+
+func (c *RealClient) fetchCapabilities() (*common.CapabilitiesResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	caps, err := c.vmclient.Capabilities(ctx, &common.CapabilitiesRequest{})
+	if grpc.Code(err) == codes.Unimplemented {
+		glog.Infof("fetchCapabilities: agent predates capabilities, assuming protocol %v", common.LegacyProtocolVersion)
+		caps, err = common.LegacyCapabilities(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.capsLock.Lock()
+	c.caps = caps
+	c.capsTime = time.Now()
+	c.capsLock.Unlock()
+
+	return caps, nil
+}
+
+func (c *RealClient) WatchContainerEvents(ctx context.Context) (ContainerEventStream, error) {
+	return c.vmclient.WatchContainerEvents(ctx, &common.WatchContainerEventsRequest{})
+}
+
+// runtimeReady tells us if the vmserver's container provider can be used yet
+func (c *RealClient) runtimeReady() error {
+	caps, err := c.fetchCapabilities()
+	if err != nil {
+		return err
+	}
+
+	if caps.ProtocolVersion == common.LegacyProtocolVersion {
+		_, err := c.ListContainers(&kubeapi.ListContainersRequest{})
+		return err
+	}
+
+	if !caps.SubsystemReady(common.SubsystemContainerRuntime) {
+		return fmt.Errorf("%v container runtime isn't ready", caps.ContainerProvider)
+	}
+
+	return nil
+}
+
 func (c *RealClient) Close() {
 	c.conn.Close()
 }
@@ -328,14 +636,29 @@ func CreateRealClient(ip string) (Client, error) {
 			if err1 == nil {
 				glog.Infof("CreateClient: version = %+v", version)
 
-				glog.Infof("Waiting on Docker")
+				caps, err := client.Capabilities()
+				if err != nil {
+					glog.Infof("CreateClient: capabilities failed: %v", err)
+					client.Close()
+					return nil, err
+				}
+
+				if err := common.CheckCompatible(caps.ProtocolVersion); err != nil {
+					glog.Warningf("CreateClient: refusing agent at %v: %v", ip, err)
+					client.Close()
+					return nil, err
+				}
+
+				glog.Infof("CreateClient: capabilities = %+v", caps)
+
+				glog.Infof("Waiting on %v", caps.ContainerProvider)
 				for j := 0; j < 5; j++ {
-					_, err := client.ListContainers(&kubeapi.ListContainersRequest{})
+					err := client.runtimeReady()
 					if err != nil {
-						glog.Infof("CreateClient: docker isn't ready (%d): %v", j, err)
+						glog.Infof("CreateClient: %v isn't ready (%d): %v", caps.ContainerProvider, j, err)
 						time.Sleep(5 * time.Second)
 					} else {
-						glog.Infof("CreateClient: docker is ready")
+						glog.Infof("CreateClient: %v is ready", caps.ContainerProvider)
 						break
 					}
 				}
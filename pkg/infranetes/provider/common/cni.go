@@ -0,0 +1,102 @@
+package common
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/golang/glog"
+
+	"github.com/apporbit/infranetes/cmd/infranetes/flags"
+	"github.com/apporbit/infranetes/pkg/common"
+)
+
+var (
+	cniLock sync.Mutex
+	// cniConfig is the CNI config last read from -cni-conf-dir, nil unless it's set
+	cniConfig []byte
+)
+
+// LoadCNIConfig reads the CNI config the VMs join the pod network with.  As the kubelet does, it's the first valid
+// config in -cni-conf-dir by name, which is read again for every VM that joins, so a network installed or changed
+// after infranetes started applies to the pods created since.
+func LoadCNIConfig() error {
+	data, err := readCNIConfig(*flags.CNIConfDir)
+	if err != nil {
+		return fmt.Errorf("LoadCNIConfig: %v", err)
+	}
+
+	cniLock.Lock()
+	cniConfig = data
+	cniLock.Unlock()
+
+	return nil
+}
+
+// currentCNIConfig reads the CNI config again, keeping the last one read while the directory has none that's valid
+func currentCNIConfig() []byte {
+	data, err := readCNIConfig(*flags.CNIConfDir)
+
+	cniLock.Lock()
+	defer cniLock.Unlock()
+
+	if err != nil {
+		glog.Warningf("currentCNIConfig: keeping the last CNI config read: %v", err)
+		return cniConfig
+	}
+	cniConfig = data
+
+	return data
+}
+
+func readCNIConfig(dir string) ([]byte, error) {
+	files, err := libcni.ConfFiles(dir, []string{".conf", ".conflist", ".json"})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			glog.Warningf("readCNIConfig: %v", err)
+			continue
+		}
+
+		list, err := common.ParseCNIConfig(data)
+		if err != nil {
+			glog.Warningf("readCNIConfig: skipping %v: %v", file, err)
+			continue
+		}
+
+		glog.V(1).Infof("readCNIConfig: VMs join %v from %v", list.Name, file)
+
+		return data, nil
+	}
+
+	return nil, fmt.Errorf("no valid CNI config in %v", dir)
+}
+
+func CNIEnabled() bool {
+	cniLock.Lock()
+	defer cniLock.Unlock()
+
+	return cniConfig != nil
+}
+
+// SetupCNI joins the VM to the pod network and returns the pod ip its CNI plugins gave it, and its ipv6 address if
+// they gave it one
+func SetupCNI(client Client, sandboxId string) (string, string, error) {
+	resp, err := client.SetupCNI(&common.SetupCNIRequest{
+		Config:    currentCNIConfig(),
+		BinDir:    *flags.CNIBinDir,
+		SandboxId: sandboxId,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return resp.Ip, resp.Ipv6, nil
+}
@@ -0,0 +1,60 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apporbit/infranetes/cmd/infranetes/flags"
+)
+
+func TestCNIConfigReloads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cni")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	saved := *flags.CNIConfDir
+	*flags.CNIConfDir = dir
+	defer func() {
+		*flags.CNIConfDir = saved
+		cniConfig = nil
+	}()
+
+	if err := LoadCNIConfig(); err == nil {
+		t.Fatalf("loaded a config from an empty directory")
+	}
+
+	write := func(name string, config string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bridge := `{"cniVersion": "0.3.1", "name": "bridge", "type": "bridge"}`
+	calico := `{"cniVersion": "0.3.1", "name": "calico", "plugins": [{"type": "calico"}, {"type": "portmap"}]}`
+
+	// invalid configs are skipped, the first valid one by name is used
+	write("00-broken.conf", `{"cniVersion": "0.3.1", "name": "broken"}`)
+	write("20-bridge.conf", bridge)
+	if err := LoadCNIConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if !CNIEnabled() || string(cniConfig) != bridge {
+		t.Fatalf("loaded %s", cniConfig)
+	}
+
+	// a network installed since is what the next VM joins
+	write("10-calico.conflist", calico)
+	if config := currentCNIConfig(); string(config) != calico {
+		t.Errorf("joins %s after calico was installed", config)
+	}
+
+	// and one that's gone leaves the last config in place
+	os.Remove(filepath.Join(dir, "10-calico.conflist"))
+	os.Remove(filepath.Join(dir, "20-bridge.conf"))
+	if config := currentCNIConfig(); string(config) != calico {
+		t.Errorf("joins %s after the configs were removed", config)
+	}
+}
@@ -0,0 +1,213 @@
+/* Manager side copy of a sandbox's containers, kept current by the vmserver's container event stream */
+
+package common
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+
+	"github.com/apporbit/infranetes/pkg/common"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+type ContainerCache struct {
+	lock       sync.RWMutex
+	containers map[string]*kubeapi.ContainerStatus
+	synced     bool
+	cancel     context.CancelFunc
+
+	// version counts the changes made to the cache, changed is the version each container last changed at and
+	// resetAt the version of the last Reset
+	version uint64
+	changed map[string]uint64
+	resetAt uint64
+}
+
+func NewContainerCache() *ContainerCache {
+	return &ContainerCache{
+		containers: make(map[string]*kubeapi.ContainerStatus),
+		changed:    make(map[string]uint64),
+	}
+}
+
+// StartWatch marks the cache as being watched, returning a context for the watch and false if a watch is already running
+func (c *ContainerCache) StartWatch() (context.Context, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.cancel != nil {
+		return nil, false
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+
+	return ctx, true
+}
+
+// StopWatch ends any running watch and stops the cache from answering queries
+func (c *ContainerCache) StopWatch() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.cancel != nil {
+		c.cancel()
+		c.cancel = nil
+	}
+	c.synced = false
+}
+
+// Synced is true while the cache holds an authoritative view of the sandbox's containers
+func (c *ContainerCache) Synced() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.synced
+}
+
+// Reset replaces the cache contents with statuses and marks it synced
+func (c *ContainerCache) Reset(statuses []*kubeapi.ContainerStatus) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.containers = make(map[string]*kubeapi.ContainerStatus, len(statuses))
+	for _, status := range statuses {
+		c.containers[status.Id] = status
+	}
+	c.version++
+	c.changed = make(map[string]uint64)
+	c.resetAt = c.version
+	c.synced = true
+}
+
+// Invalidate makes queries fall back to the vmserver until the next Reset
+func (c *ContainerCache) Invalidate() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.synced = false
+}
+
+func (c *ContainerCache) Update(status *kubeapi.ContainerStatus) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.containers[status.Id] = status
+	c.version++
+	c.changed[status.Id] = c.version
+}
+
+func (c *ContainerCache) Delete(id string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.containers, id)
+	c.version++
+	c.changed[id] = c.version
+}
+
+// Version is the cache's version, for a later Refresh
+func (c *ContainerCache) Version() uint64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.version
+}
+
+// Refresh updates the cache with a status fetched since version, unless the container changed after version, making
+// its status in the cache the newer one
+func (c *ContainerCache) Refresh(status *kubeapi.ContainerStatus, version uint64) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.resetAt > version || c.changed[status.Id] > version {
+		return false
+	}
+
+	c.containers[status.Id] = status
+	c.version++
+	c.changed[status.Id] = c.version
+
+	return true
+}
+
+// HandleEvent applies a vmserver container event to the cache
+func (c *ContainerCache) HandleEvent(event *common.ContainerEvent) {
+	switch event.Type {
+	case common.ContainerEventType_SYNCED:
+		return
+	case common.ContainerEventType_REMOVED:
+		c.Delete(event.ContainerID)
+		return
+	}
+
+	var status kubeapi.ContainerStatus
+	if err := json.Unmarshal(event.Status, &status); err != nil {
+		glog.Warningf("HandleEvent: couldn't decode status for %v: %v", event.ContainerID, err)
+		return
+	}
+
+	c.Update(&status)
+}
+
+func (c *ContainerCache) Status(id string) (*kubeapi.ContainerStatus, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	status, ok := c.containers[id]
+
+	return status, ok
+}
+
+func (c *ContainerCache) List(podId string, filter *kubeapi.ContainerFilter) []*kubeapi.Container {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	ret := []*kubeapi.Container{}
+
+	for _, status := range c.containers {
+		if filterStatus(filter, status) {
+			continue
+		}
+
+		ret = append(ret, &kubeapi.Container{
+			Id:           status.Id,
+			PodSandboxId: podId,
+			Metadata:     status.Metadata,
+			Image:        status.Image,
+			ImageRef:     status.ImageRef,
+			State:        status.State,
+			CreatedAt:    status.CreatedAt,
+			Labels:       status.Labels,
+			Annotations:  status.Annotations,
+		})
+	}
+
+	return ret
+}
+
+func filterStatus(filter *kubeapi.ContainerFilter, status *kubeapi.ContainerStatus) bool {
+	if filter == nil {
+		return false
+	}
+
+	if filter.Id != "" && filter.Id != status.Id {
+		return true
+	}
+
+	if filter.State != nil && filter.State.State != status.State {
+		return true
+	}
+
+	for k, v := range filter.LabelSelector {
+		if val, ok := status.Labels[k]; !ok || val != v {
+			return true
+		}
+	}
+
+	return false
+}
@@ -2,6 +2,9 @@ package common
 
 import (
 	"errors"
+	"io"
+
+	"golang.org/x/net/context"
 
 	"github.com/apporbit/infranetes/pkg/common"
 	"github.com/apporbit/infranetes/pkg/vmserver"
@@ -27,6 +30,10 @@ func (c *fakeClient) CreateContainer(req *kubeapi.CreateContainerRequest) (*kube
 	return c.fakeProvider.CreateContainer(req)
 }
 
+func (c *fakeClient) CreateContainerWithAuth(req *kubeapi.CreateContainerRequest, auth *kubeapi.AuthConfig) (*kubeapi.CreateContainerResponse, error) {
+	return c.fakeProvider.CreateContainer(req)
+}
+
 func (c *fakeClient) StartContainer(req *kubeapi.StartContainerRequest) (*kubeapi.StartContainerResponse, error) {
 	return c.fakeProvider.StartContainer(req)
 }
@@ -75,16 +82,56 @@ func (c *fakeClient) StartProxy() error {
 	return errors.New("Fake doesn't support StartProxy")
 }
 
+func (c *fakeClient) StopProxy() error {
+	return errors.New("Fake doesn't support StopProxy")
+}
+
+func (c *fakeClient) UpdateProxyKubeconfig(kubeconfig []byte) error {
+	return errors.New("Fake doesn't support UpdateProxyKubeconfig")
+}
+
+func (c *fakeClient) ProxyStatus() (*common.ProxyStatusResponse, error) {
+	return nil, errors.New("Fake doesn't support ProxyStatus")
+}
+
+func (c *fakeClient) StartNetworkPolicy() error {
+	return errors.New("Fake doesn't support StartNetworkPolicy")
+}
+
+func (c *fakeClient) NetworkPolicyStatus() (*common.NetworkPolicyStatusResponse, error) {
+	return nil, errors.New("Fake doesn't support NetworkPolicyStatus")
+}
+
+func (c *fakeClient) SetupOverlay(req *common.SetupOverlayRequest) (*common.SetupOverlayResponse, error) {
+	return nil, errors.New("Fake doesn't support SetupOverlay")
+}
+
+func (c *fakeClient) SetupCNI(req *common.SetupCNIRequest) (*common.SetupCNIResponse, error) {
+	return nil, errors.New("Fake doesn't support SetupCNI")
+}
+
+func (c *fakeClient) TeardownCNI() error {
+	return errors.New("Fake doesn't support TeardownCNI")
+}
+
+func (c *fakeClient) ListAddresses() (*common.ListAddressesResponse, error) {
+	return nil, errors.New("Fake doesn't support ListAddresses")
+}
+
+func (c *fakeClient) SetPortMappings(mappings []*kubeapi.PortMapping) error {
+	return errors.New("Fake doesn't support SetPortMappings")
+}
+
 func (c *fakeClient) RunCmd(req *common.RunCmdRequest) error {
 	return errors.New("Fake doesn't support RunCmd")
 }
 
-func (c *fakeClient) SetPodIP(ip string) error {
+func (c *fakeClient) SetPodIP(ip string, ipv6 string) error {
 	return errors.New("Fake doesn't support SetPodIP")
 }
 
-func (c *fakeClient) GetPodIP() (string, error) {
-	return "", errors.New("Fake doesn't support GetPodIP")
+func (c *fakeClient) GetPodIP() (string, string, error) {
+	return "", "", errors.New("Fake doesn't support GetPodIP")
 }
 
 func (c *fakeClient) SetSandboxConfig(config *kubeapi.PodSandboxConfig) error {
@@ -125,3 +172,46 @@ func (c *fakeClient) GetMetric(req *common.GetMetricsRequest) (*common.GetMetric
 func (c *fakeClient) AddRoute(req *common.AddRouteRequest) (*common.AddRouteResponse, error) {
 	return &common.AddRouteResponse{}, nil
 }
+
+func (c *fakeClient) Capabilities() (*common.CapabilitiesResponse, error) {
+	resp := &common.CapabilitiesResponse{
+		ProtocolVersion:   common.ProtocolVersion,
+		Features:          append(c.fakeProvider.Features(), common.FeatureEvents),
+		ContainerProvider: "fake",
+	}
+
+	return resp, nil
+}
+
+type fakeEventStream struct {
+	ctx    context.Context
+	events <-chan *common.ContainerEvent
+	synced bool
+}
+
+func (s *fakeEventStream) Recv() (*common.ContainerEvent, error) {
+	if !s.synced {
+		s.synced = true
+		return &common.ContainerEvent{Type: common.ContainerEventType_SYNCED}, nil
+	}
+
+	select {
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	case event, ok := <-s.events:
+		if !ok {
+			return nil, io.EOF
+		}
+		return event, nil
+	}
+}
+
+func (c *fakeClient) WatchContainerEvents(ctx context.Context) (ContainerEventStream, error) {
+	events, cancel := c.fakeProvider.Events().Subscribe()
+	go func() {
+		<-ctx.Done()
+		cancel()
+	}()
+
+	return &fakeEventStream{ctx: ctx, events: events}, nil
+}
@@ -0,0 +1,178 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/apporbit/infranetes/cmd/infranetes/flags"
+)
+
+// FirewallPolicy is the -firewall-policy file.  A pod's firewall lets in the ingress of every rule that selects it,
+// and nothing else beyond what the VMs' shared firewall does.
+type FirewallPolicy struct {
+	Rules []FirewallRule `json:"rules"`
+}
+
+// FirewallRule selects the pods in any of Namespaces, or all namespaces if it's empty, that have all of the Selector's
+// labels
+type FirewallRule struct {
+	Namespaces []string          `json:"namespaces,omitempty"`
+	Selector   map[string]string `json:"selector,omitempty"`
+	Ingress    []FirewallIngress `json:"ingress"`
+}
+
+// FirewallIngress lets Protocol in on ports Port through EndPort from Sources.  Protocol is tcp, udp, icmp or all,
+// a Port of 0 is all ports, an EndPort of 0 is only Port, and no Sources is anywhere.
+type FirewallIngress struct {
+	Protocol string   `json:"protocol"`
+	Port     int32    `json:"port,omitempty"`
+	EndPort  int32    `json:"endPort,omitempty"`
+	Sources  []string `json:"sources,omitempty"`
+}
+
+var (
+	firewallLock   sync.RWMutex
+	firewallPolicy *FirewallPolicy
+)
+
+// FirewallPolicyEnabled is whether pods get firewalls of their own
+func FirewallPolicyEnabled() bool {
+	return *flags.FirewallPolicy != ""
+}
+
+// LoadFirewallPolicy reads and checks the policy in file
+func LoadFirewallPolicy(file string) (*FirewallPolicy, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read firewall policy: %v", err)
+	}
+
+	policy := &FirewallPolicy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("couldn't parse firewall policy %v: %v", file, err)
+	}
+
+	for i, rule := range policy.Rules {
+		for j := range rule.Ingress {
+			ingress := &policy.Rules[i].Ingress[j]
+			if err := ingress.normalize(); err != nil {
+				return nil, fmt.Errorf("invalid ingress %d of rule %d in %v: %v", j, i, file, err)
+			}
+		}
+	}
+
+	return policy, nil
+}
+
+// SetFirewallPolicy makes policy the one pods' firewalls are computed from
+func SetFirewallPolicy(policy *FirewallPolicy) {
+	firewallLock.Lock()
+	defer firewallLock.Unlock()
+
+	firewallPolicy = policy
+}
+
+// PodFirewall returns what the current policy lets in to a pod in namespace with labels, in a stable order so it can
+// be compared with what was applied before
+func PodFirewall(namespace string, labels map[string]string) []FirewallIngress {
+	firewallLock.RLock()
+	defer firewallLock.RUnlock()
+
+	ret := []FirewallIngress{}
+	if firewallPolicy == nil {
+		return ret
+	}
+
+	seen := make(map[string]bool)
+	for _, rule := range firewallPolicy.Rules {
+		if !rule.selects(namespace, labels) {
+			continue
+		}
+		for _, ingress := range rule.Ingress {
+			if key := ingress.String(); !seen[key] {
+				seen[key] = true
+				ret = append(ret, ingress)
+			}
+		}
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].String() < ret[j].String() })
+
+	return ret
+}
+
+func (r *FirewallRule) selects(namespace string, labels map[string]string) bool {
+	if len(r.Namespaces) > 0 {
+		found := false
+		for _, ns := range r.Namespaces {
+			if ns == namespace {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for k, v := range r.Selector {
+		if value, ok := labels[k]; !ok || value != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// normalize checks the ingress and fills in its defaults
+func (i *FirewallIngress) normalize() error {
+	i.Protocol = strings.ToLower(i.Protocol)
+	switch i.Protocol {
+	case "tcp", "udp":
+	case "icmp", "all":
+		if i.Port != 0 || i.EndPort != 0 {
+			return fmt.Errorf("%v has no ports", i.Protocol)
+		}
+	default:
+		return fmt.Errorf("invalid protocol %q, want tcp, udp, icmp or all", i.Protocol)
+	}
+
+	if i.Port < 0 || i.Port > 65535 || i.EndPort < 0 || i.EndPort > 65535 || (i.EndPort != 0 && i.EndPort < i.Port) {
+		return fmt.Errorf("invalid ports %v-%v", i.Port, i.EndPort)
+	}
+	if i.EndPort == 0 {
+		i.EndPort = i.Port
+	}
+
+	if len(i.Sources) == 0 {
+		i.Sources = []string{"0.0.0.0/0"}
+	}
+	for _, source := range i.Sources {
+		if _, _, err := net.ParseCIDR(source); err != nil {
+			return fmt.Errorf("invalid source %q: %v", source, err)
+		}
+	}
+	sort.Strings(i.Sources)
+
+	return nil
+}
+
+// PortRange returns the ingress' ports as a firewall takes them, "" if it's all of them
+func (i FirewallIngress) PortRange() string {
+	if i.Port == 0 {
+		return ""
+	}
+	if i.EndPort == i.Port {
+		return fmt.Sprintf("%d", i.Port)
+	}
+	return fmt.Sprintf("%d-%d", i.Port, i.EndPort)
+}
+
+func (i FirewallIngress) String() string {
+	return fmt.Sprintf("%v:%v from %v", i.Protocol, i.PortRange(), strings.Join(i.Sources, ","))
+}
@@ -0,0 +1,147 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestLoadFirewallPolicy(t *testing.T) {
+	for _, test := range []struct {
+		policy string
+		valid  bool
+	}{
+		{`{"rules": [{"selector": {"app": "web"}, "ingress": [{"protocol": "TCP", "port": 80}]}]}`, true},
+		{`{"rules": [{"namespaces": ["prod"], "ingress": [{"protocol": "all", "sources": ["10.0.0.0/8"]}]}]}`, true},
+		{`{"rules": [{"ingress": [{"protocol": "sctp", "port": 80}]}]}`, false},
+		{`{"rules": [{"ingress": [{"protocol": "tcp", "sources": ["10.0.0.1"]}]}]}`, false},
+		{`{"rules": [`, false},
+	} {
+		file, err := ioutil.TempFile("", "firewall-policy")
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.WriteString(test.policy)
+		file.Close()
+
+		policy, err := LoadFirewallPolicy(file.Name())
+		os.Remove(file.Name())
+
+		if test.valid && err != nil {
+			t.Errorf("%v: %v", test.policy, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("%v: loaded an invalid policy", test.policy)
+		} else if test.valid {
+			// the ingress comes out normalized
+			for _, rule := range policy.Rules {
+				for _, ingress := range rule.Ingress {
+					if ingress.Protocol != "tcp" && ingress.Protocol != "all" || len(ingress.Sources) == 0 {
+						t.Errorf("%v: ingress %+v wasn't normalized", test.policy, ingress)
+					}
+				}
+			}
+		}
+	}
+
+	if _, err := LoadFirewallPolicy("/nonexistent/firewall-policy"); err == nil {
+		t.Errorf("loaded a missing policy")
+	}
+}
+
+func TestFirewallIngressNormalize(t *testing.T) {
+	for _, test := range []struct {
+		ingress FirewallIngress
+		want    *FirewallIngress
+	}{
+		{FirewallIngress{Protocol: "TCP", Port: 80}, &FirewallIngress{Protocol: "tcp", Port: 80, EndPort: 80, Sources: []string{"0.0.0.0/0"}}},
+		{FirewallIngress{Protocol: "udp", Port: 1000, EndPort: 2000, Sources: []string{"192.168.0.0/16", "10.0.0.0/8"}},
+			&FirewallIngress{Protocol: "udp", Port: 1000, EndPort: 2000, Sources: []string{"10.0.0.0/8", "192.168.0.0/16"}}},
+		{FirewallIngress{Protocol: "tcp"}, &FirewallIngress{Protocol: "tcp", Sources: []string{"0.0.0.0/0"}}},
+		{FirewallIngress{Protocol: "icmp"}, &FirewallIngress{Protocol: "icmp", Sources: []string{"0.0.0.0/0"}}},
+		{FirewallIngress{Protocol: "icmp", Port: 8}, nil},
+		{FirewallIngress{Protocol: "all", EndPort: 80}, nil},
+		{FirewallIngress{Protocol: "sctp", Port: 80}, nil},
+		{FirewallIngress{Protocol: "tcp", Port: 70000}, nil},
+		{FirewallIngress{Protocol: "tcp", Port: -1}, nil},
+		{FirewallIngress{Protocol: "tcp", Port: 2000, EndPort: 1000}, nil},
+		{FirewallIngress{Protocol: "tcp", Port: 80, Sources: []string{"example.com"}}, nil},
+	} {
+		ingress := test.ingress
+		err := ingress.normalize()
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("normalize(%+v) accepted it", test.ingress)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("normalize(%+v): %v", test.ingress, err)
+		} else if !reflect.DeepEqual(ingress, *test.want) {
+			t.Errorf("normalize(%+v) = %+v, want %+v", test.ingress, ingress, *test.want)
+		}
+	}
+}
+
+func TestFirewallRuleSelects(t *testing.T) {
+	labels := map[string]string{"app": "web", "tier": "front"}
+
+	for _, test := range []struct {
+		rule      FirewallRule
+		namespace string
+		want      bool
+	}{
+		{FirewallRule{}, "default", true},
+		{FirewallRule{Selector: map[string]string{"app": "web"}}, "default", true},
+		{FirewallRule{Selector: map[string]string{"app": "web", "tier": "front"}}, "default", true},
+		{FirewallRule{Selector: map[string]string{"app": "web", "tier": "back"}}, "default", false},
+		{FirewallRule{Selector: map[string]string{"role": "web"}}, "default", false},
+		{FirewallRule{Namespaces: []string{"prod", "default"}}, "default", true},
+		{FirewallRule{Namespaces: []string{"prod"}}, "default", false},
+		{FirewallRule{Namespaces: []string{"prod"}, Selector: map[string]string{"app": "web"}}, "prod", true},
+	} {
+		if got := test.rule.selects(test.namespace, labels); got != test.want {
+			t.Errorf("%+v selects a pod in %v = %v, want %v", test.rule, test.namespace, got, test.want)
+		}
+	}
+}
+
+func TestPodFirewall(t *testing.T) {
+	defer SetFirewallPolicy(nil)
+
+	web := FirewallIngress{Protocol: "tcp", Port: 80, EndPort: 80, Sources: []string{"0.0.0.0/0"}}
+	ssh := FirewallIngress{Protocol: "tcp", Port: 22, EndPort: 22, Sources: []string{"10.0.0.0/8"}}
+	ping := FirewallIngress{Protocol: "icmp", Sources: []string{"0.0.0.0/0"}}
+
+	SetFirewallPolicy(&FirewallPolicy{Rules: []FirewallRule{
+		{Selector: map[string]string{"app": "web"}, Ingress: []FirewallIngress{web, ssh}},
+		{Ingress: []FirewallIngress{ssh, ping}},
+		{Namespaces: []string{"prod"}, Ingress: []FirewallIngress{web}},
+	}})
+
+	// every selecting rule's ingress, once each, sorted
+	got := PodFirewall("default", map[string]string{"app": "web"})
+	if want := []FirewallIngress{ping, ssh, web}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PodFirewall = %v, want %v", got, want)
+	}
+
+	got = PodFirewall("default", nil)
+	if want := []FirewallIngress{ping, ssh}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PodFirewall = %v, want %v", got, want)
+	}
+
+	// the order of the rules doesn't matter
+	SetFirewallPolicy(&FirewallPolicy{Rules: []FirewallRule{
+		{Ingress: []FirewallIngress{ping, ssh}},
+		{Selector: map[string]string{"app": "web"}, Ingress: []FirewallIngress{ssh, web}},
+	}})
+	got = PodFirewall("default", map[string]string{"app": "web"})
+	if want := []FirewallIngress{ping, ssh, web}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PodFirewall = %v, want %v", got, want)
+	}
+
+	SetFirewallPolicy(nil)
+	if got := PodFirewall("default", nil); got == nil || len(got) != 0 {
+		t.Errorf("PodFirewall without a policy = %#v, want an empty list", got)
+	}
+}
@@ -0,0 +1,167 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+
+	"github.com/apporbit/infranetes/cmd/infranetes/flags"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+	utildbus "k8s.io/kubernetes/pkg/util/dbus"
+	"k8s.io/kubernetes/pkg/util/exec"
+	utiliptables "k8s.io/kubernetes/pkg/util/iptables"
+)
+
+const (
+	// HostPortFirewall opens a pod's host ports on its VM with the cloud's firewall
+	HostPortFirewall = "firewall"
+	// HostPortNode forwards a pod's host ports on the infranetes node to its VM
+	HostPortNode = "node"
+
+	// the node's chains that DNAT host ports to pods, and masquerade what they DNAT so replies come back through it
+	nodeHostPortChain     utiliptables.Chain = "INFRANETES-HOSTPORTS"
+	nodeHostPortMasqChain utiliptables.Chain = "INFRANETES-HOSTPORTS-MASQ"
+)
+
+// HostPorts returns the port mappings of config that ask for a host port
+func HostPorts(config *kubeapi.PodSandboxConfig) []*kubeapi.PortMapping {
+	ret := []*kubeapi.PortMapping{}
+	for _, mapping := range config.GetPortMappings() {
+		if mapping.HostPort != 0 {
+			ret = append(ret, mapping)
+		}
+	}
+	return ret
+}
+
+// HostPortMode returns how a pod's host ports are reached, from its annotations or else -hostport-mode
+func HostPortMode(annotations map[string]string) (string, error) {
+	mode := *flags.HostPortMode
+	if a := ParseCommonAnnotations(annotations).HostPortMode; a != "" {
+		mode = a
+	}
+
+	if mode != HostPortFirewall && mode != HostPortNode {
+		return "", fmt.Errorf("invalid host port mode %q, want %v or %v", mode, HostPortFirewall, HostPortNode)
+	}
+
+	return mode, nil
+}
+
+// hostPortTarget is a pod whose host ports the node forwards
+type hostPortTarget struct {
+	ip       string
+	mappings []*kubeapi.PortMapping
+}
+
+type nodeHostPorts struct {
+	lock      sync.Mutex
+	iptables  utiliptables.Interface
+	sandboxes map[string]*hostPortTarget
+}
+
+var nodePorts = &nodeHostPorts{sandboxes: make(map[string]*hostPortTarget)}
+
+// ForwardHostPorts has the node forward the mappings' host ports to the sandbox's pod ip
+func ForwardHostPorts(sandboxId string, podIp string, mappings []*kubeapi.PortMapping) error {
+	nodePorts.lock.Lock()
+	defer nodePorts.lock.Unlock()
+
+	nodePorts.sandboxes[sandboxId] = &hostPortTarget{ip: podIp, mappings: mappings}
+
+	return nodePorts.sync()
+}
+
+// StopForwardingHostPorts removes the sandbox's forwards, if it had any
+func StopForwardingHostPorts(sandboxId string) {
+	nodePorts.lock.Lock()
+	defer nodePorts.lock.Unlock()
+
+	if _, ok := nodePorts.sandboxes[sandboxId]; !ok {
+		return
+	}
+	delete(nodePorts.sandboxes, sandboxId)
+
+	if err := nodePorts.sync(); err != nil {
+		glog.Warningf("StopForwardingHostPorts: %v", err)
+	}
+}
+
+// SyncHostPorts replaces the node's forwards with those of the sandboxes infranetes knows, dropping what a previous
+// infranetes left for pods that are gone
+func SyncHostPorts() error {
+	nodePorts.lock.Lock()
+	defer nodePorts.lock.Unlock()
+
+	return nodePorts.sync()
+}
+
+func (n *nodeHostPorts) sync() error {
+	if n.iptables == nil {
+		n.iptables = utiliptables.New(exec.New(), utildbus.New(), utiliptables.ProtocolIpv4)
+	}
+
+	data := renderNodeHostPortRules(n.sandboxes)
+	if err := n.iptables.Restore(utiliptables.TableNAT, data, utiliptables.NoFlushTables, utiliptables.NoRestoreCounters); err != nil {
+		return fmt.Errorf("couldn't restore host port chains: %v", err)
+	}
+
+	// host ports are only the node's own addresses', whether the traffic comes from outside or the node itself
+	for _, chain := range []utiliptables.Chain{utiliptables.ChainPrerouting, utiliptables.ChainOutput} {
+		if _, err := n.iptables.EnsureRule(utiliptables.Prepend, utiliptables.TableNAT, chain, "-m", "addrtype", "--dst-type", "LOCAL", "-j", string(nodeHostPortChain)); err != nil {
+			return fmt.Errorf("couldn't jump to %v from %v: %v", nodeHostPortChain, chain, err)
+		}
+	}
+	if _, err := n.iptables.EnsureRule(utiliptables.Prepend, utiliptables.TableNAT, utiliptables.ChainPostrouting, "-j", string(nodeHostPortMasqChain)); err != nil {
+		return fmt.Errorf("couldn't jump to %v: %v", nodeHostPortMasqChain, err)
+	}
+
+	return nil
+}
+
+// renderNodeHostPortRules writes the iptables-restore input for the nat table that replaces the node's host port
+// chains with forwards for sandboxes
+func renderNodeHostPortRules(sandboxes map[string]*hostPortTarget) []byte {
+	ids := []string{}
+	for id := range sandboxes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	lines := bytes.NewBuffer(nil)
+	for _, id := range ids {
+		target := sandboxes[id]
+		for _, mapping := range target.mappings {
+			protocol := strings.ToLower(mapping.Protocol.String())
+			containerPort := mapping.ContainerPort
+			if containerPort == 0 {
+				containerPort = mapping.HostPort
+			}
+
+			match := fmt.Sprintf("-p %s -m %s --dport %d", protocol, protocol, mapping.HostPort)
+			if ip := net.ParseIP(mapping.HostIp); ip != nil && !ip.IsUnspecified() {
+				match = fmt.Sprintf("-d %s/32 %s", mapping.HostIp, match)
+			}
+			dest := net.JoinHostPort(target.ip, strconv.Itoa(int(containerPort)))
+
+			fmt.Fprintf(lines, "-A %s %s -m comment --comment %q -j DNAT --to-destination %s\n", nodeHostPortChain, match, id, dest)
+			fmt.Fprintf(lines, "-A %s -d %s/32 -p %s -m %s --dport %d -m conntrack --ctstate DNAT -j MASQUERADE\n", nodeHostPortMasqChain, target.ip, protocol, protocol, containerPort)
+		}
+	}
+
+	data := bytes.NewBuffer(nil)
+	data.WriteString("*nat\n")
+	fmt.Fprintf(data, ":%s - [0:0]\n", nodeHostPortChain)
+	fmt.Fprintf(data, ":%s - [0:0]\n", nodeHostPortMasqChain)
+	data.Write(lines.Bytes())
+	data.WriteString("COMMIT\n")
+
+	return data.Bytes()
+}
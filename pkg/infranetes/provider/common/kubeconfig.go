@@ -0,0 +1,23 @@
+package common
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// LoadProxyKubeconfig returns the kubeconfig at path for the VMs' kube-proxies.  The certificates and keys it refers
+// to by path aren't on the VMs, so they're inlined, which also means a rotated certificate changes the result.
+func LoadProxyKubeconfig(path string) ([]byte, error) {
+	config, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load kubeconfig %v: %v", path, err)
+	}
+
+	if err := clientcmdapi.FlattenConfig(config); err != nil {
+		return nil, fmt.Errorf("couldn't inline files of kubeconfig %v: %v", path, err)
+	}
+
+	return clientcmd.Write(*config)
+}
@@ -0,0 +1,318 @@
+/* Writes container logs to kubelet's log path in the CRI log format */
+
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/apporbit/infranetes/pkg/common"
+)
+
+const (
+	criTimestampFormat = time.RFC3339Nano
+	criStreamStdout    = "stdout"
+	criTagPartial      = "P"
+	criTagFull         = "F"
+
+	cursorSuffix = ".cursor"
+	// how often the cursor is persisted, a crash can replay at most this much log
+	cursorSaveInterval = time.Second
+)
+
+// LogCursor records how far into a container's log in the VM we've copied to Path
+type LogCursor struct {
+	ContainerID string
+	Path        string
+	Offset      int64
+}
+
+// LogRotation configures when a LogWriter rotates, MaxSize of 0 disables rotation
+type LogRotation struct {
+	MaxSize  int64
+	MaxFiles int
+}
+
+type LogWriter struct {
+	lock       sync.Mutex
+	cursor     LogCursor
+	cursorPath string
+	rotation   LogRotation
+	file       *os.File
+	size       int64
+	lastSave   time.Time
+}
+
+// active writers by container id, so ReopenContainerLog can find them
+var (
+	logWriters     = make(map[string]*LogWriter)
+	logWritersLock sync.Mutex
+)
+
+// NewLogWriter opens path for appending, picking up from any cursor saved in stateDir for the same container and path
+func NewLogWriter(stateDir string, containerID string, path string, rotation LogRotation) (*LogWriter, error) {
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return nil, fmt.Errorf("NewLogWriter: couldn't create state dir %v: %v", stateDir, err)
+	}
+
+	w := &LogWriter{
+		cursor:     LogCursor{ContainerID: containerID, Path: path},
+		cursorPath: cursorPath(stateDir, containerID),
+		rotation:   rotation,
+	}
+
+	if saved, err := readLogCursor(w.cursorPath); err == nil && saved.Path == path {
+		glog.Infof("NewLogWriter: resuming %v at offset %v", containerID, saved.Offset)
+		w.cursor.Offset = saved.Offset
+	}
+
+	if err := w.open(); err != nil {
+		return nil, fmt.Errorf("NewLogWriter: %v", err)
+	}
+
+	if err := w.saveCursor(); err != nil {
+		w.file.Close()
+		return nil, err
+	}
+
+	logWritersLock.Lock()
+	defer logWritersLock.Unlock()
+
+	logWriters[containerID] = w
+
+	return w, nil
+}
+
+// ReopenLogWriter closes and reopens the log file of containerID, for use after something external rotated it
+func ReopenLogWriter(containerID string) error {
+	logWritersLock.Lock()
+	w, ok := logWriters[containerID]
+	logWritersLock.Unlock()
+
+	if !ok {
+		return fmt.Errorf("ReopenLogWriter: no log is being written for %v", containerID)
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.file.Close()
+
+	return w.open()
+}
+
+func (w *LogWriter) open() error {
+	f, err := os.OpenFile(w.cursor.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open %v: %v", w.cursor.Path, err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat %v: %v", w.cursor.Path, err)
+	}
+
+	w.file = f
+	w.size = info.Size()
+
+	return nil
+}
+
+// rotate shifts path.N-1 to path.N ... path to path.1, dropping the oldest, and starts a new path
+func (w *LogWriter) rotate() error {
+	path := w.cursor.Path
+
+	w.file.Close()
+
+	if w.rotation.MaxFiles <= 1 {
+		if err := os.Truncate(path, 0); err != nil {
+			return fmt.Errorf("rotate: truncate of %v failed: %v", path, err)
+		}
+		return w.open()
+	}
+
+	oldest := fmt.Sprintf("%s.%d", path, w.rotation.MaxFiles-1)
+	if err := os.Remove(oldest); err != nil && !os.IsNotExist(err) {
+		glog.Warningf("rotate: couldn't remove %v: %v", oldest, err)
+	}
+
+	for i := w.rotation.MaxFiles - 2; i >= 1; i-- {
+		from := fmt.Sprintf("%s.%d", path, i)
+		to := fmt.Sprintf("%s.%d", path, i+1)
+		if err := os.Rename(from, to); err != nil && !os.IsNotExist(err) {
+			glog.Warningf("rotate: couldn't rename %v to %v: %v", from, to, err)
+		}
+	}
+
+	if err := os.Rename(path, path+".1"); err != nil {
+		return fmt.Errorf("rotate: rename of %v failed: %v", path, err)
+	}
+
+	return w.open()
+}
+
+// Offset is where the next LogsRequest should resume from
+func (w *LogWriter) Offset() int64 {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.cursor.Offset
+}
+
+func (w *LogWriter) Write(line *common.LogLine) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	formatted := FormatCRILogLine(line)
+
+	if w.rotation.MaxSize > 0 && w.size > 0 && w.size+int64(len(formatted)) > w.rotation.MaxSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := w.file.WriteString(formatted)
+	w.size += int64(n)
+	if err != nil {
+		return err
+	}
+
+	// agents that don't report offsets can't be resumed and will replay from the start
+	if line.Offset > 0 {
+		w.cursor.Offset = line.Offset
+	}
+
+	if time.Since(w.lastSave) > cursorSaveInterval {
+		return w.saveCursor()
+	}
+
+	return nil
+}
+
+// Close saves the cursor so a later LogWriter can resume
+func (w *LogWriter) Close() error {
+	w.unregister()
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	err := w.saveCursor()
+	w.file.Close()
+
+	return err
+}
+
+// Finish closes the writer and forgets the cursor, used once the container's log has ended
+func (w *LogWriter) Finish() error {
+	w.unregister()
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.file.Close()
+
+	if err := os.Remove(w.cursorPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (w *LogWriter) unregister() {
+	logWritersLock.Lock()
+	defer logWritersLock.Unlock()
+
+	if logWriters[w.cursor.ContainerID] == w {
+		delete(logWriters, w.cursor.ContainerID)
+	}
+}
+
+func (w *LogWriter) saveCursor() error {
+	data, err := json.Marshal(&w.cursor)
+	if err != nil {
+		return err
+	}
+
+	tmp := w.cursorPath + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("saveCursor: %v", err)
+	}
+	if err := os.Rename(tmp, w.cursorPath); err != nil {
+		return fmt.Errorf("saveCursor: %v", err)
+	}
+
+	w.lastSave = time.Now()
+
+	return nil
+}
+
+// FormatCRILogLine renders line as "timestamp stream tag message\n", the format kubelet parses
+func FormatCRILogLine(line *common.LogLine) string {
+	ts := time.Now()
+	if line.Timestamp != 0 {
+		ts = time.Unix(0, line.Timestamp)
+	}
+
+	stream := line.Stream
+	if stream == "" {
+		stream = criStreamStdout
+	}
+
+	tag := criTagFull
+	if line.Partial {
+		tag = criTagPartial
+	}
+
+	return fmt.Sprintf("%s %s %s %s\n", ts.UTC().Format(criTimestampFormat), stream, tag, line.LogLine)
+}
+
+func cursorPath(stateDir string, containerID string) string {
+	return filepath.Join(stateDir, strings.Replace(containerID, ":", "_", -1)+cursorSuffix)
+}
+
+func readLogCursor(path string) (*LogCursor, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor LogCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+
+	return &cursor, nil
+}
+
+// ListLogCursors returns every cursor saved in stateDir, i.e. logs that were still streaming when we last ran
+func ListLogCursors(stateDir string) ([]*LogCursor, error) {
+	files, err := filepath.Glob(filepath.Join(stateDir, "*"+cursorSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []*LogCursor{}
+	for _, file := range files {
+		cursor, err := readLogCursor(file)
+		if err != nil {
+			glog.Warningf("ListLogCursors: skipping %v: %v", file, err)
+			continue
+		}
+		ret = append(ret, cursor)
+	}
+
+	return ret, nil
+}
+
+func RemoveLogCursor(stateDir string, containerID string) error {
+	return os.Remove(cursorPath(stateDir, containerID))
+}
@@ -0,0 +1,153 @@
+package common
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golang/glog"
+
+	"github.com/apporbit/infranetes/cmd/infranetes/flags"
+	"github.com/apporbit/infranetes/pkg/common"
+	"github.com/apporbit/infranetes/pkg/overlay"
+)
+
+// nodeOverlay is the node's end of the overlay, nil unless -overlay is set
+var nodeOverlay *NodeOverlay
+
+// NodeOverlay tunnels the pod ips of the VMs to this node, which routes them to and from the cluster
+type NodeOverlay struct {
+	mode     string
+	endpoint string
+	link     *overlay.Link
+	pool     *overlay.Pool
+	// cidrs the VMs route through the node
+	routes []string
+
+	lock sync.Mutex
+	// the VM each pod ip is tunneled to
+	peers map[string]overlay.Peer
+}
+
+// SetupNodeOverlay brings up the node's end of the overlay described by the -overlay flags
+func SetupNodeOverlay() error {
+	if *flags.OverlayEndpoint == "" {
+		return fmt.Errorf("SetupNodeOverlay: -overlay-endpoint has to be set for the VMs to reach this node")
+	}
+
+	pool, err := overlay.NewPool(*flags.OverlayCIDR)
+	if err != nil {
+		return fmt.Errorf("SetupNodeOverlay: invalid -overlay-cidr: %v", err)
+	}
+
+	link, err := overlay.Setup(overlay.Config{
+		Mode:     *flags.Overlay,
+		Address:  pool.CIDR(pool.Gateway()),
+		Underlay: *flags.OverlayEndpoint,
+		Port:     *flags.OverlayPort,
+		VNI:      *flags.OverlayVNI,
+		KeyFile:  *flags.OverlayKeyFile,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("SetupNodeOverlay: %v", err)
+	}
+
+	routes := []string{pool.Network()}
+	if *flags.ClusterCIDR != "" && *flags.ClusterCIDR != pool.Network() {
+		routes = append(routes, *flags.ClusterCIDR)
+	}
+
+	nodeOverlay = &NodeOverlay{
+		mode:     *flags.Overlay,
+		endpoint: *flags.OverlayEndpoint,
+		link:     link,
+		pool:     pool,
+		routes:   routes,
+		peers:    make(map[string]overlay.Peer),
+	}
+
+	return nil
+}
+
+func OverlayEnabled() bool {
+	return nodeOverlay != nil
+}
+
+// JoinOverlay tunnels a pod ip to the VM at vmIp and returns it.  podIp is the ip the pod got before infranetes
+// restarted, a new pod passes "" to be given one.
+func JoinOverlay(client Client, vmIp string, podIp string) (string, error) {
+	o := nodeOverlay
+
+	if podIp == "" {
+		ip, err := o.pool.Allocate()
+		if err != nil {
+			return "", fmt.Errorf("JoinOverlay: %v", err)
+		}
+		podIp = ip
+	} else if err := o.pool.Reserve(podIp); err != nil {
+		return "", fmt.Errorf("JoinOverlay: %v", err)
+	}
+
+	resp, err := client.SetupOverlay(&common.SetupOverlayRequest{
+		Mode:          o.mode,
+		PodIp:         podIp,
+		Gateway:       o.pool.Gateway(),
+		NodeEndpoint:  o.endpoint,
+		NodePublicKey: o.link.PublicKey(),
+		Port:          int32(o.link.Port()),
+		Vni:           int32(*flags.OverlayVNI),
+		Routes:        o.routes,
+	})
+	if err != nil {
+		o.pool.Release(podIp)
+		return "", fmt.Errorf("JoinOverlay: %v", err)
+	}
+
+	peer := overlay.Peer{Endpoint: vmIp, PublicKey: resp.PublicKey, AllowedIPs: []string{podIp + "/32"}}
+	if err := o.link.AddPeer(peer); err != nil {
+		o.pool.Release(podIp)
+		return "", fmt.Errorf("JoinOverlay: %v", err)
+	}
+
+	o.lock.Lock()
+	o.peers[podIp] = peer
+	o.lock.Unlock()
+
+	glog.Infof("JoinOverlay: %v is tunneled to %v", podIp, vmIp)
+
+	return podIp, nil
+}
+
+// LeaveOverlay stops tunneling podIp and frees it
+func LeaveOverlay(podIp string) {
+	o := nodeOverlay
+
+	o.lock.Lock()
+	peer, ok := o.peers[podIp]
+	delete(o.peers, podIp)
+	o.lock.Unlock()
+
+	if ok {
+		if err := o.link.RemovePeer(peer); err != nil {
+			glog.Warningf("LeaveOverlay: %v", err)
+		}
+	}
+
+	o.pool.Release(podIp)
+}
+
+// ReconcileOverlay stops tunneling to the VMs left on the node's device by pods that are gone.  It's called once the
+// pods found when infranetes started have joined the overlay again.
+func ReconcileOverlay() {
+	o := nodeOverlay
+
+	o.lock.Lock()
+	peers := []overlay.Peer{}
+	for _, peer := range o.peers {
+		peers = append(peers, peer)
+	}
+	o.lock.Unlock()
+
+	if err := o.link.SyncPeers(peers); err != nil {
+		glog.Warningf("ReconcileOverlay: %v", err)
+	}
+}
@@ -4,12 +4,15 @@ import (
 	"errors"
 	"fmt"
 	//	"runtime"
+	"strings"
 	"sync"
 	"time"
 
 	lvm "github.com/apcera/libretto/virtualmachine"
 	"github.com/golang/glog"
 
+	"github.com/apporbit/infranetes/pkg/common"
+
 	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
 )
 
@@ -26,6 +29,13 @@ type PodData struct {
 	Labels       map[string]string
 	CreatedAt    int64
 	Ip           string
+	Ips          []string               // all of the VM's addresses, which the pod's ip isn't among when it's from an overlay or CNI
+	Ipv6         string                 // the pod's ipv6 address in a dual-stack sandbox
+	PortMappings []*kubeapi.PortMapping // the pod's port mappings with a host port
+	Firewall     []FirewallIngress      // what -firewall-policy last let in to the pod, nil if that isn't known
+	LBHealth     string                 // the pod's health in its infranetes.lb.group, as its load balancer last reported it
+	ProxyState   string                 // the health of the pod's kube-proxy, as its vmserver last reported it
+	NetPolicy    string                 // the enforcement of the pod's NetworkPolicies, as its vmserver last reported it
 	Linux        *kubeapi.LinuxPodSandboxConfig
 	stateLock    sync.RWMutex
 	Client       Client
@@ -34,11 +44,27 @@ type PodData struct {
 	BootLock     sync.Mutex
 	ProviderData ProviderData
 	ContLogs     map[string]string
+	Capabilities *common.CapabilitiesResponse
+	Containers   *ContainerCache
+
+	// StaticIP is the pod's infranetes.staticip address, empty if it has none or it isn't known, and StaticIPState
+	// whether it's associated with the pod's VM
+	StaticIP      string
+	StaticIPState string
 }
 
 func NewPodData(vm lvm.VirtualMachine, id string, meta *kubeapi.PodSandboxMetadata, anno map[string]string,
 	labels map[string]string, ip string, linux *kubeapi.LinuxPodSandboxConfig, client Client, booted bool,
 	providerData ProviderData) *PodData {
+	var caps *common.CapabilitiesResponse
+	if client != nil {
+		var err error
+		caps, err = client.Capabilities()
+		if err != nil {
+			glog.Warningf("NewPodData: couldn't get capabilities for %v: %v", id, err)
+		}
+	}
+
 	return &PodData{
 		VM:           vm,
 		Id:           id,
@@ -53,6 +79,8 @@ func NewPodData(vm lvm.VirtualMachine, id string, meta *kubeapi.PodSandboxMetada
 		Booted:       booted,
 		ProviderData: providerData,
 		ContLogs:     make(map[string]string),
+		Capabilities: caps,
+		Containers:   NewContainerCache(),
 	}
 }
 
@@ -134,6 +162,7 @@ func (p *PodData) StopPod() error {
 }
 
 func (p *PodData) RemovePod() error {
+	p.Containers.StopWatch()
 	p.Client.Close()
 	p.Client = nil
 
@@ -145,6 +174,36 @@ func (p *PodData) PodStatus() *kubeapi.PodSandboxStatus {
 		Ip: p.Ip,
 	}
 
+	// the CRI only has room for one ip, the others are reported in annotations
+	annotations := p.Annotations
+	if len(p.Ips) > 0 || p.Ipv6 != "" || p.LBHealth != "" || p.ProxyState != "" || p.NetPolicy != "" || p.StaticIPState != "" {
+		annotations = make(map[string]string)
+		for k, v := range p.Annotations {
+			annotations[k] = v
+		}
+		if len(p.Ips) > 0 {
+			annotations[IPsAnnotation] = strings.Join(p.Ips, ",")
+		}
+		if p.Ipv6 != "" {
+			annotations[IPv6Annotation] = p.Ipv6
+		}
+		if p.LBHealth != "" {
+			annotations[LBHealthAnnotation] = p.LBHealth
+		}
+		if p.ProxyState != "" {
+			annotations[ProxyAnnotation] = p.ProxyState
+		}
+		if p.NetPolicy != "" {
+			annotations[NetworkPolicyAnnotation] = p.NetPolicy
+		}
+		if p.StaticIP != "" {
+			annotations[StaticIPAnnotation] = p.StaticIP
+		}
+		if p.StaticIPState != "" {
+			annotations[StaticIPStateAnnotation] = p.StaticIPState
+		}
+	}
+
 	linux := &kubeapi.LinuxPodSandboxStatus{
 		Namespaces: &kubeapi.Namespace{
 			Options: p.Linux.SecurityContext.NamespaceOptions,
@@ -158,7 +217,7 @@ func (p *PodData) PodStatus() *kubeapi.PodSandboxStatus {
 		Network:     network,
 		Linux:       linux,
 		Labels:      p.Labels,
-		Annotations: p.Annotations,
+		Annotations: annotations,
 		State:       p.GetPodState(),
 	}
 
@@ -245,6 +304,16 @@ func (p *PodData) GetContLogPath(cont string) (string, bool) {
 	return ret, ok
 }
 
+// HasFeature reports if the pod's agent supports feature.  Agents we couldn't query are treated as legacy agents.
+func (p *PodData) HasFeature(feature string) bool {
+	caps := p.Capabilities
+	if caps == nil {
+		caps = common.LegacyCapabilities()
+	}
+
+	return caps.HasFeature(feature)
+}
+
 func (p *PodData) AttachVol(vol string) (string, error) {
 	if p.ProviderData == nil {
 		return "", errors.New("Attach: No Provider Data")
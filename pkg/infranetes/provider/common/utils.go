@@ -85,6 +85,21 @@ type annotationConfig struct {
 	StartProxy     bool
 	CreateInteface bool
 	SetHostname    bool
+	// PodIPPolicy and AgentIPPolicy override -pod-ip-policy and -agent-ip-policy when they aren't empty
+	PodIPPolicy   string
+	AgentIPPolicy string
+	// HostPortMode overrides -hostport-mode when it isn't empty
+	HostPortMode string
+	// DNSName is the name the pod is given in the provider's DNS zone, if any
+	DNSName string
+	// LBGroup is the load balancer target group (aws) or instance group (gcp) the pod is registered in, if any, and
+	// LBPort the port it's registered with when it isn't the group's
+	LBGroup string
+	LBPort  int64
+	// StaticIP is the static public ip the pod's VM is given, an elastic ip allocation or address (aws) or address name
+	// (gcp), StaticIPAllocate for one allocated with the pod and released with it, or StaticIPPoolPrefix and the
+	// name of a pool of elastic ips (aws) to draw one from
+	StaticIP string
 }
 
 func ParseCommonAnnotations(annotations map[string]string) *annotationConfig {
@@ -121,5 +136,20 @@ func ParseCommonAnnotations(annotations map[string]string) *annotationConfig {
 		}
 	}
 
+	ret.PodIPPolicy = annotations["infranetes.podip"]
+	ret.AgentIPPolicy = annotations["infranetes.agentip"]
+	ret.HostPortMode = annotations["infranetes.hostport"]
+	ret.DNSName = annotations["infranetes.dns.name"]
+	ret.LBGroup = annotations["infranetes.lb.group"]
+	ret.StaticIP = annotations["infranetes.staticip"]
+	if a, ok := annotations["infranetes.lb.port"]; ok {
+		port, err := strconv.ParseInt(a, 10, 32)
+		if err != nil || port <= 0 || port > 65535 {
+			glog.Infof("Couldn't parse port %v for infranetes.lb.port", a)
+		} else {
+			ret.LBPort = port
+		}
+	}
+
 	return ret
 }
@@ -1,10 +1,8 @@
 package docker
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 
 	"github.com/golang/glog"
 	"golang.org/x/net/context"
@@ -100,30 +98,19 @@ func (d *dockerImageProvider) ImageStatus(req *kubeapi.ImageStatusRequest) (*kub
 	return resp, nil
 }
 
+// PullImage pulls with the credentials kubelet resolved for the image.  Kubelet only asks when its pull policy says
+// to, so only images pinned to a digest that are already present are skipped.
 func (d *dockerImageProvider) PullImage(req *kubeapi.PullImageRequest) (*kubeapi.PullImageResponse, error) {
-	pullresp, err := d.client.ImagePull(context.Background(), req.Image.GetImage(), dockertypes.ImagePullOptions{})
+	ref, err := common.PullDockerImage(d.client, req.Image.GetImage(), req.Auth, true)
 	if err != nil {
-		return nil, fmt.Errorf("ImagePull Failed (%v)\n", err)
+		return nil, err
 	}
 
-	decoder := json.NewDecoder(pullresp)
-	for {
-		var msg interface{}
-		err := decoder.Decode(&msg)
-
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("Pull Image failed: %v", err)
-		}
+	resp := &kubeapi.PullImageResponse{
+		ImageRef: ref,
 	}
 
-	pullresp.Close()
-
-	resp := &kubeapi.PullImageResponse{}
-
-	return resp, err
+	return resp, nil
 }
 
 func (d *dockerImageProvider) RemoveImage(req *kubeapi.RemoveImageRequest) (*kubeapi.RemoveImageResponse, error) {
@@ -16,6 +16,7 @@ import (
 type fakePodProvider struct {
 	instances map[string]*common.PodData
 	ipList    *utils.Deque
+	ipv6Pool  *utils.IPPool
 }
 
 func init() {
@@ -34,6 +35,14 @@ func NewFakePodProvider() (provider.PodProvider, error) {
 		ipList:    ipList,
 	}
 
+	if *flags.DualStack && *flags.IPv6CIDR != "" {
+		pool, err := utils.NewIPPool(*flags.IPv6CIDR, 1)
+		if err != nil {
+			return nil, err
+		}
+		provider.ipv6Pool = pool
+	}
+
 	return provider, nil
 }
 
@@ -49,6 +58,9 @@ func (p *fakePodProvider) RunPodSandbox(req *kubeapi.RunPodSandboxRequest, volue
 	podIp := p.ipList.Shift().(string)
 	booted := true
 	podData := common.NewPodData(vm, vm.name, req.Config.Metadata, req.Config.Annotations, req.Config.Labels, podIp, req.Config.Linux, client, booted, nil)
+	if p.ipv6Pool != nil {
+		podData.Ipv6, _ = p.ipv6Pool.Allocate()
+	}
 
 	p.instances[name] = podData
 
@@ -66,6 +78,9 @@ func (*fakePodProvider) StopPodSandbox(podData *common.PodData) {}
 func (v *fakePodProvider) RemovePodSandbox(data *common.PodData) {
 	// putting ip back into queue
 	v.ipList.Append(data.Ip)
+	if v.ipv6Pool != nil && data.Ipv6 != "" {
+		v.ipv6Pool.Release(data.Ipv6)
+	}
 }
 
 func (v *fakePodProvider) PodSandboxStatus(podData *common.PodData) {}
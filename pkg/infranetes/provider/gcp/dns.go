@@ -0,0 +1,62 @@
+package gcp
+
+import (
+	"github.com/golang/glog"
+
+	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+// registerDNS gives a booted pod the name its infranetes.dns.name annotation asks for in the managed zone, pointing at
+// its static ip if it has one associated and its pod ips otherwise.  A pod without its name still runs.
+func (p *gcpPodProvider) registerDNS(data *common.PodData, config *kubeapi.PodSandboxConfig) {
+	name := common.ParseCommonAnnotations(config.Annotations).DNSName
+	if name == "" {
+		return
+	}
+	if p.dns == nil {
+		glog.Warningf("registerDNS: %v wants the name %v, but gce.json has no DNSZone", data.Id, name)
+		return
+	}
+
+	providerData, ok := data.ProviderData.(*podData)
+	if !ok {
+		return
+	}
+
+	ips := []string{data.Ip}
+	if data.Ipv6 != "" {
+		ips = append(ips, data.Ipv6)
+	}
+	if providerData.staticIP != nil && providerData.staticIP.associated {
+		ips = []string{providerData.staticIP.address}
+	}
+
+	fqdn, err := p.dns.Register(name, ips)
+	if err != nil {
+		glog.Warningf("registerDNS: %v", err)
+		return
+	}
+	providerData.dnsName = fqdn
+
+	glog.Infof("registerDNS: %v is %v", fqdn, ips)
+}
+
+// unregisterDNS removes the records of the pod's name that point at it, keeping those of any pod that took the name
+// over since
+func (p *gcpPodProvider) unregisterDNS(data *common.PodData) {
+	providerData, ok := data.ProviderData.(*podData)
+	if !ok || providerData.dnsName == "" || p.dns == nil {
+		return
+	}
+
+	ips := []string{data.Ip, data.Ipv6}
+	if providerData.staticIP != nil {
+		ips = append(ips, providerData.staticIP.address)
+	}
+
+	if err := p.dns.Unregister(providerData.dnsName, ips); err != nil {
+		glog.Warningf("unregisterDNS: %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package gcp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/glog"
+
+	googlecloud "google.golang.org/api/compute/v1"
+
+	"github.com/apporbit/infranetes/pkg/common/gcp"
+	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
+)
+
+// firewallRules groups ingress by where it comes from, as a GCE rule lets everything it allows in from the same ranges
+func firewallRules(ingress []common.FirewallIngress) ([][]string, [][]*googlecloud.FirewallAllowed) {
+	bySources := make(map[string][]*googlecloud.FirewallAllowed)
+	sources := make(map[string][]string)
+	for _, i := range ingress {
+		allowed := &googlecloud.FirewallAllowed{IPProtocol: i.Protocol}
+		if ports := i.PortRange(); ports != "" {
+			allowed.Ports = []string{ports}
+		}
+
+		key := strings.Join(i.Sources, ",")
+		bySources[key] = append(bySources[key], allowed)
+		sources[key] = i.Sources
+	}
+
+	keys := []string{}
+	for key := range bySources {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	ranges := [][]string{}
+	alloweds := [][]*googlecloud.FirewallAllowed{}
+	for _, key := range keys {
+		ranges = append(ranges, sources[key])
+		alloweds = append(alloweds, bySources[key])
+	}
+
+	return ranges, alloweds
+}
+
+// setFirewall makes the pod's -firewall-policy rules, which target its instance's tag, let in ingress and no more
+func setFirewall(s *gcp.GcpSvcWrapper, network string, instance string, ingress []common.FirewallIngress) error {
+	prefix := gcp.PodFirewallPrefix(instance)
+
+	existing, err := s.ListFirewalls(prefix)
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[string]bool)
+	ranges, alloweds := firewallRules(ingress)
+	for n := range ranges {
+		name := fmt.Sprintf("%v%d", prefix, n)
+		if err := s.AddFirewall(name, network, instance, ranges[n], alloweds[n]); err != nil {
+			return fmt.Errorf("couldn't add %v: %v", name, err)
+		}
+		wanted[name] = true
+	}
+
+	for _, firewall := range existing {
+		if wanted[firewall.Name] {
+			continue
+		}
+		if err := s.DelFirewall(firewall.Name); err != nil {
+			return fmt.Errorf("couldn't remove %v: %v", firewall.Name, err)
+		}
+	}
+
+	glog.Infof("setFirewall: %v lets %v in", instance, ingress)
+
+	return nil
+}
+
+// closeFirewall removes the pod's -firewall-policy rules
+func closeFirewall(s *gcp.GcpSvcWrapper, instance string) error {
+	firewalls, err := s.ListFirewalls(gcp.PodFirewallPrefix(instance))
+	if err != nil {
+		return err
+	}
+
+	for _, firewall := range firewalls {
+		if err := s.DelFirewall(firewall.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reconcileFirewalls removes the rules named with prefix whose instances are gone.  The rules of any of the zone's
+// infranetes instances are kept, whether or not their pods could be adopted, as are those of other zones' instances and
+// of instances that couldn't be looked up.
+func reconcileFirewalls(s *gcp.GcpSvcWrapper, prefix string, instances []*googlecloud.Instance) {
+	firewalls, err := s.ListFirewalls(prefix)
+	if err != nil {
+		glog.Warningf("reconcileFirewalls: %v", err)
+		return
+	}
+
+	existing := make(map[string]bool)
+	for _, instance := range instances {
+		existing[instance.Name] = true
+	}
+
+	for _, firewall := range firewalls {
+		if !s.FirewallInZone(firewall) {
+			continue
+		}
+		if len(firewall.TargetTags) == 1 {
+			instance := firewall.TargetTags[0]
+			if existing[instance] {
+				continue
+			}
+			gone, err := s.InstanceGone(instance)
+			if err != nil {
+				glog.Warningf("reconcileFirewalls: %v", err)
+			}
+			if !gone {
+				continue
+			}
+		}
+
+		glog.Infof("reconcileFirewalls: removing stale %v", firewall.Name)
+		if err := s.DelFirewall(firewall.Name); err != nil {
+			glog.Warningf("reconcileFirewalls: couldn't remove stale %v: %v", firewall.Name, err)
+		}
+	}
+}
+
+func (v *gcpPodProvider) UpdateFirewall(data *common.PodData, ingress []common.FirewallIngress) error {
+	providerData, ok := data.ProviderData.(*podData)
+	if !ok {
+		return fmt.Errorf("UpdateFirewall: %v has no gcp data", data.Id)
+	}
+
+	return setFirewall(providerData.service, v.config.Network, *providerData.instanceId, ingress)
+}
@@ -0,0 +1,39 @@
+package gcp
+
+import (
+	"reflect"
+	"testing"
+
+	googlecloud "google.golang.org/api/compute/v1"
+
+	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
+)
+
+func TestFirewallRules(t *testing.T) {
+	anywhere := []string{"0.0.0.0/0"}
+	internal := []string{"10.0.0.0/8", "192.168.0.0/16"}
+
+	ranges, alloweds := firewallRules([]common.FirewallIngress{
+		{Protocol: "tcp", Port: 80, EndPort: 80, Sources: anywhere},
+		{Protocol: "tcp", Port: 8000, EndPort: 8080, Sources: internal},
+		{Protocol: "icmp", Sources: anywhere},
+		{Protocol: "udp", Sources: internal},
+	})
+
+	// one rule per set of sources, in a stable order
+	if want := [][]string{anywhere, internal}; !reflect.DeepEqual(ranges, want) {
+		t.Errorf("ranges = %v, want %v", ranges, want)
+	}
+	want := [][]*googlecloud.FirewallAllowed{
+		{{IPProtocol: "tcp", Ports: []string{"80"}}, {IPProtocol: "icmp"}},
+		{{IPProtocol: "tcp", Ports: []string{"8000-8080"}}, {IPProtocol: "udp"}},
+	}
+	if !reflect.DeepEqual(alloweds, want) {
+		t.Errorf("alloweds = %v, want %v", alloweds, want)
+	}
+
+	ranges, alloweds = firewallRules(nil)
+	if len(ranges) != 0 || len(alloweds) != 0 {
+		t.Errorf("no ingress made rules %v %v", ranges, alloweds)
+	}
+}
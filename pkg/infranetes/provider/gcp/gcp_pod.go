@@ -5,15 +5,19 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/golang/glog"
 
 	gcpvm "github.com/apcera/libretto/virtualmachine/gcp"
+	googlecloud "google.golang.org/api/compute/v1"
 
 	"github.com/apporbit/infranetes/cmd/infranetes/flags"
 	"github.com/apporbit/infranetes/pkg/common/gcp"
+	"github.com/apporbit/infranetes/pkg/dns"
 	"github.com/apporbit/infranetes/pkg/infranetes/provider"
 	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
 	"github.com/apporbit/infranetes/pkg/infranetes/types"
@@ -34,6 +38,7 @@ type gcpPodProvider struct {
 	config   *gcp.GceConfig
 	ipList   *utils.Deque
 	imagePod bool
+	dns      *dns.Registry // set if pods can have names in a managed zone
 }
 
 type podData struct {
@@ -42,6 +47,16 @@ type podData struct {
 	volumes    []*types.Volume
 	attached   map[string]string
 	service    *gcp.GcpSvcWrapper
+	// routedIp is the pod ip routed to the instance, when it isn't the instance's own
+	routedIp string
+	// hostPortFirewall is the rule opening the pod's host ports on the instance
+	hostPortFirewall string
+	// dnsName is the name registered for the pod in the managed zone
+	dnsName string
+	// lbGroup is the pod's infranetes.lb.group instance group, while its instance is in it
+	lbGroup string
+	// staticIP is the pod's infranetes.staticip static ip
+	staticIP *staticIP
 }
 
 func NewGCPPodProvider() (provider.PodProvider, error) {
@@ -65,14 +80,34 @@ func NewGCPPodProvider() (provider.PodProvider, error) {
 		return nil, fmt.Errorf("GCP doesn't have autodetection yet: MasterIP = %v, IPBase = %v", *flags.MasterIP, *flags.IPBase)
 	}
 
+	// The vendored compute api predates GCE's ipv6 support, so only the pod network can give pods ipv6 addresses
+	if *flags.DualStack && *flags.CNIConfDir == "" {
+		return nil, fmt.Errorf("-dual-stack: GCP can only give pods ipv6 addresses through -cni-conf-dir")
+	}
+
 	ipList := utils.NewDeque()
 	for i := 2; i <= 254; i++ {
 		ipList.Append(fmt.Sprint(*flags.IPBase + "." + strconv.Itoa(i)))
 	}
 
+	var registry *dns.Registry
+	if conf.DNSZone != "" {
+		s, err := gcp.GetService(conf.AuthFile, conf.Project, conf.Zone, []string{conf.Scope})
+		if err != nil {
+			return nil, err
+		}
+		backend, err := dns.NewCloudDNS(s.Client, conf.Project, conf.DNSZone)
+		if err != nil {
+			return nil, err
+		}
+		registry = dns.NewRegistry(backend, *flags.DNSTTL)
+		glog.Infof("NewGCPPodProvider: pods' dns names are in %v", backend.Zone())
+	}
+
 	return &gcpPodProvider{
 		config: &conf,
 		ipList: ipList,
+		dns:    registry,
 	}, nil
 }
 
@@ -94,9 +129,19 @@ func (p *gcpPodProvider) tagImage(name string) {
 	}
 }
 
-func (p *gcpPodProvider) bootSandbox(vm *gcpvm.VM, config *kubeapi.PodSandboxConfig, name string, volumes []*types.Volume) (*common.PodData, error) {
+func (p *gcpPodProvider) bootSandbox(vm *gcpvm.VM, config *kubeapi.PodSandboxConfig, name string, volumes []*types.Volume) (_ *common.PodData, err error) {
 	cAnno := common.ParseCommonAnnotations(config.Annotations)
 
+	podPolicy, agentPolicy, err := common.IPPolicies(config.Annotations)
+	if err != nil {
+		return nil, fmt.Errorf("CreatePodSandbox: %v", err)
+	}
+
+	hostPortMode, err := common.HostPortMode(config.Annotations)
+	if err != nil {
+		return nil, fmt.Errorf("CreatePodSandbox: %v", err)
+	}
+
 	s, err := gcp.GetService(p.config.AuthFile, p.config.Project, p.config.Zone, []string{p.config.Scope})
 	if err != nil {
 		return nil, fmt.Errorf("CreatePodSandbox: failed to get gcp service")
@@ -113,6 +158,36 @@ func (p *gcpPodProvider) bootSandbox(vm *gcpvm.VM, config *kubeapi.PodSandboxCon
 		return nil, fmt.Errorf("CreatePodSandbox: failed to provision vm: %v\n", err)
 	}
 
+	// A step that fails tears down what the ones before it set up, last first, ending with the VM
+	var rollback []func()
+	defer func() {
+		if err == nil {
+			return
+		}
+		glog.Warningf("CreatePodSandbox: tearing down %v: %v", vm.Name, err)
+		for i := len(rollback) - 1; i >= 0; i-- {
+			rollback[i]()
+		}
+	}()
+	rollback = append(rollback, func() {
+		if err := vm.Destroy(); err != nil {
+			glog.Warningf("CreatePodSandbox: couldn't delete %v: %v", vm.Name, err)
+		}
+	})
+
+	// The static ip goes on before the VM's addresses are read, so it's the public one among them
+	static, staticState := setStaticIP(s, vm.Name, config.Annotations)
+	if static != nil {
+		rollback = append(rollback, func() {
+			if err := disassociateStaticIP(s, vm.Name, static); err != nil {
+				glog.Warningf("CreatePodSandbox: %v", err)
+			}
+			if err := releaseStaticIP(s, static); err != nil {
+				glog.Warningf("CreatePodSandbox: %v", err)
+			}
+		})
+	}
+
 	ips, err := vm.GetIPs()
 	if err != nil {
 		return nil, fmt.Errorf("CreatePodSandbox: error in GetIPs(): %v", err)
@@ -122,20 +197,47 @@ func (p *gcpPodProvider) bootSandbox(vm *gcpvm.VM, config *kubeapi.PodSandboxCon
 
 	glog.Infof("CreatePodSandbox: ips = %v", ips)
 
-	// FIXME: Perhaps better way to choose public vs private ip
-	index := 1
-	podIp := ips[index].String()
+	addrs := common.CloudAddresses(ips[gcpvm.PublicIP], ips[gcpvm.PrivateIP])
+
+	agentIp, err := agentPolicy.Select(addrs)
+	if err != nil {
+		return nil, fmt.Errorf("CreatePodSandbox: %v", err)
+	}
+	if err := s.LabelInstance(vm.Name, common.AgentIPTag, gcp.AddressLabel(agentIp)); err != nil {
+		glog.Warningf("CreatePodSandbox: couldn't label %v with its agent's address: %v", vm.Name, err)
+	}
 
-	client, err := common.CreateRealClient(podIp)
+	client, err := common.CreateRealClient(agentIp)
 	if err != nil {
 		return nil, fmt.Errorf("CreatePodSandbox: error in createClient(): %v", err)
 	}
+	rollback = append(rollback, func() { client.Close() })
+
+	addrs = common.AddInterfaceAddresses(client, addrs)
+
+	podIp, err := podPolicy.Select(addrs)
+	if err != nil {
+		return nil, fmt.Errorf("CreatePodSandbox: %v", err)
+	}
+
+	glog.Infof("CreatePodSandbox: podIp = %v, agentIp = %v", podIp, agentIp)
+
+	// The VM keeps its own ip for reaching it, the pod gets one tunneled through this node
+	if common.OverlayEnabled() {
+		podIp, err = common.JoinOverlay(client, agentIp, "")
+		if err != nil {
+			return nil, fmt.Errorf("CreatePodSandbox: couldn't join the overlay: %v", err)
+		}
+		overlayIp := podIp
+		rollback = append(rollback, func() { common.LeaveOverlay(overlayIp) })
+	}
 
 	providerData := &podData{
 		instanceId: &vm.Name,
 		volumes:    volumes,
 		attached:   attached, // attached:   make(map[string]string),
 		service:    s,
+		staticIP:   static,
 	}
 
 	err = client.SetSandboxConfig(config)
@@ -154,7 +256,67 @@ func (p *gcpPodProvider) bootSandbox(vm *gcpvm.VM, config *kubeapi.PodSandboxCon
 		}
 	}
 
-	err = client.SetPodIP(podIp)
+	// The VM keeps its own ip for reaching it, the pod gets one from the cluster's pod network
+	podIpv6 := ""
+	if common.CNIEnabled() {
+		podIp, podIpv6, err = common.SetupCNI(client, name)
+		if err != nil {
+			return nil, fmt.Errorf("CreatePodSandbox: couldn't join the pod network: %v", err)
+		}
+		rollback = append(rollback, func() {
+			if err := client.TeardownCNI(); err != nil {
+				glog.Warningf("CreatePodSandbox: couldn't leave the pod network: %v", err)
+			}
+		})
+	}
+
+	// A pod ip that isn't one of the instance's own needs a route to the instance, unless it's the overlay's, which
+	// has to go through the tunnel
+	if !common.HasAddress(addrs, podIp) && !common.OverlayEnabled() {
+		if err := s.AddRoute(gcp.RouteName(podIp), p.config.Network, vm.Name, podIp); err != nil {
+			return nil, fmt.Errorf("CreatePodSandbox: couldn't route %v to %v: %v", podIp, vm.Name, err)
+		}
+		providerData.routedIp = podIp
+		rollback = append(rollback, func() {
+			if err := s.DelRoute(gcp.RouteName(providerData.routedIp)); err != nil {
+				glog.Warningf("CreatePodSandbox: couldn't remove route for %v: %v", providerData.routedIp, err)
+			}
+		})
+	}
+
+	if mappings := common.HostPorts(config); hostPortMode == common.HostPortFirewall && len(mappings) > 0 {
+		firewall, err := openHostPorts(s, p.config.Network, vm.Name, mappings)
+		if err != nil {
+			return nil, fmt.Errorf("CreatePodSandbox: couldn't open host ports on %v: %v", vm.Name, err)
+		}
+		providerData.hostPortFirewall = firewall
+		rollback = append(rollback, func() {
+			if err := s.DelFirewall(firewall); err != nil {
+				glog.Warningf("CreatePodSandbox: couldn't close host ports: %v", err)
+			}
+		})
+	}
+
+	var firewall []common.FirewallIngress
+	if common.FirewallPolicyEnabled() {
+		firewall = common.PodFirewall(config.GetMetadata().GetNamespace(), config.Labels)
+		// the rules made before one that couldn't be are removed too
+		rollback = append(rollback, func() {
+			if err := closeFirewall(s, vm.Name); err != nil {
+				glog.Warningf("CreatePodSandbox: couldn't remove the pod's firewall: %v", err)
+			}
+		})
+		if err := setFirewall(s, p.config.Network, vm.Name, firewall); err != nil {
+			return nil, fmt.Errorf("CreatePodSandbox: couldn't create the pod's firewall: %v", err)
+		}
+	}
+
+	// nothing else gives it one, a pod without is no dual-stack pod
+	if *flags.DualStack && podIpv6 == "" {
+		return nil, fmt.Errorf("CreatePodSandbox: -dual-stack, but the pod network gave %v no ipv6 address", name)
+	}
+
+	err = client.SetPodIP(podIp, podIpv6)
 	if err != nil {
 		glog.Warningf("CreatePodSandbox: Failed to configure inteface: %v", err)
 	}
@@ -167,6 +329,13 @@ func (p *gcpPodProvider) bootSandbox(vm *gcpvm.VM, config *kubeapi.PodSandboxCon
 		}
 	}
 
+	if *flags.NetworkPolicy {
+		err = client.StartNetworkPolicy()
+		if err != nil {
+			glog.Warningf("CreatePodSandbox: Couldn't enforce network policies: %v", err)
+		}
+	}
+
 	if cAnno.SetHostname {
 		err = client.SetHostname(config.GetHostname())
 		if err != nil {
@@ -177,6 +346,16 @@ func (p *gcpPodProvider) bootSandbox(vm *gcpvm.VM, config *kubeapi.PodSandboxCon
 	booted := true
 
 	podData := common.NewPodData(vm, name, config.Metadata, config.Annotations, config.Labels, podIp, config.Linux, client, booted, providerData)
+	podData.Ips = common.AddressIPs(addrs)
+	podData.Ipv6 = podIpv6
+	podData.Firewall = firewall
+	podData.StaticIPState = staticState
+	if static != nil {
+		podData.StaticIP = static.address
+	}
+
+	p.registerDNS(podData, config)
+	p.registerTarget(podData, config)
 
 	return podData, nil
 }
@@ -201,17 +380,12 @@ func (v *gcpPodProvider) RunPodSandbox(req *kubeapi.RunPodSandboxRequest, volume
 		Project:          v.config.Project,
 		Scopes:           []string{v.config.Scope},
 		AccountFile:      v.config.AuthFile,
-		Tags:             []string{"infranetes"},
+		Tags:             []string{"infranetes", name},
 		PrivateIPAddress: podIp,
 	}
 
 	if !v.imagePod { // Traditional Pod, but within a VM
-		ret, err := v.bootSandbox(vm, req.Config, podIp, volumes)
-		if err == nil {
-			// FIXME: Google's version of elastic IP handling goes here
-		}
-
-		return ret, err
+		return v.bootSandbox(vm, req.Config, podIp, volumes)
 	} else { // Booting a VM immage to appear as a Pod to K8s.  Can't boot it until container time
 		//FIXME: make generic later
 		providerData := &podData{volumes: volumes}
@@ -272,13 +446,16 @@ func (v *gcpPodProvider) PreCreateContainer(data *common.PodData, req *kubeapi.C
 		return fmt.Errorf("PreCreateContainer: couldn't boot VM: %v", err)
 	}
 
-	// FIXME: Google's version of elastic IP handling goes here
-	//handleElasticIP(req.GetSandboxConfig(), vm.GetName())
-
 	data.Booted = true
 
 	data.Client = newPodData.Client
+	data.Ip = newPodData.Ip
+	data.Ips = newPodData.Ips
+	data.Ipv6 = newPodData.Ipv6
 	data.ProviderData = newPodData.ProviderData
+	data.Capabilities = newPodData.Capabilities
+	data.StaticIP = newPodData.StaticIP
+	data.StaticIPState = newPodData.StaticIPState
 
 	return nil
 }
@@ -306,12 +483,56 @@ func (v *gcpPodProvider) StopPodSandbox(pdata *common.PodData) {
 	}
 
 	providerData.volumes = nil
+
+	// the static ip is free for another pod once this one stops
+	if providerData.staticIP != nil && providerData.staticIP.associated {
+		if err := disassociateStaticIP(providerData.service, *providerData.instanceId, providerData.staticIP); err != nil {
+			glog.Warningf("StopPodSandbox: %v", err)
+			pdata.StaticIPState = common.StaticIPFailed(err)
+		} else {
+			pdata.StaticIPState = common.StaticIPDisassociated
+		}
+	}
 }
 
 func (v *gcpPodProvider) RemovePodSandbox(data *common.PodData) {
-	glog.Infof("RemovePodSandbox: release IP: %v", data.Ip)
+	if providerData, ok := data.ProviderData.(*podData); ok && providerData.routedIp != "" {
+		if err := providerData.service.DelRoute(gcp.RouteName(providerData.routedIp)); err != nil {
+			glog.Warningf("RemovePodSandbox: couldn't remove route for %v: %v", providerData.routedIp, err)
+		}
+	}
 
-	v.ipList.Append(data.Ip)
+	if providerData, ok := data.ProviderData.(*podData); ok && providerData.hostPortFirewall != "" {
+		if err := providerData.service.DelFirewall(providerData.hostPortFirewall); err != nil {
+			glog.Warningf("RemovePodSandbox: couldn't close host ports: %v", err)
+		}
+	}
+
+	v.unregisterDNS(data)
+
+	if providerData, ok := data.ProviderData.(*podData); ok && common.FirewallPolicyEnabled() && providerData.service != nil {
+		if err := closeFirewall(providerData.service, *providerData.instanceId); err != nil {
+			glog.Warningf("RemovePodSandbox: couldn't remove the pod's firewall: %v", err)
+		}
+	}
+
+	if providerData, ok := data.ProviderData.(*podData); ok && providerData.staticIP != nil {
+		if err := disassociateStaticIP(providerData.service, *providerData.instanceId, providerData.staticIP); err != nil {
+			glog.Warningf("RemovePodSandbox: %v", err)
+		}
+		if err := releaseStaticIP(providerData.service, providerData.staticIP); err != nil {
+			glog.Warningf("RemovePodSandbox: %v", err)
+		}
+	}
+
+	if common.OverlayEnabled() && data.Booted {
+		common.LeaveOverlay(data.Ip)
+	}
+
+	// the sandbox is named after the instance's private ip, which needn't be its pod ip
+	glog.Infof("RemovePodSandbox: release IP: %v", data.Id)
+
+	v.ipList.Append(data.Id)
 }
 
 func (v *gcpPodProvider) PodSandboxStatus(podData *common.PodData) {}
@@ -329,15 +550,32 @@ func (v *gcpPodProvider) ListInstances() ([]*common.PodData, error) {
 	}
 
 	podDatas := []*common.PodData{}
+	// the routes the adopted pods need, and the instances they're on
+	wanted := make(map[string]string)
+	adopted := make(map[string]bool)
 	for _, instance := range instances {
-		podIp := instance.NetworkInterfaces[0].NetworkIP
+		nic := instance.NetworkInterfaces[0]
+		vmIp := nic.NetworkIP
+
+		var publicIp net.IP
+		if len(nic.AccessConfigs) > 0 && nic.AccessConfigs[0].NatIP != "" {
+			publicIp = net.ParseIP(nic.AccessConfigs[0].NatIP)
+		}
+		addrs := common.CloudAddresses(publicIp, net.ParseIP(vmIp))
+
+		// the sandbox's config isn't known yet, so vmserver is reached where it was when the VM booted
+		agentIp, err := common.AgentIP(gcp.LabelAddress(instance.Labels[common.AgentIPTag]), addrs)
+		if err != nil {
+			glog.Warningf("ListInstances: skipping %v: %v", instance.Name, err)
+			continue
+		}
 
-		client, err := common.CreateRealClient(podIp)
+		client, err := common.CreateRealClient(agentIp)
 		if err != nil {
 			return nil, fmt.Errorf("CreatePodSandbox: error in createClient(): %v", err)
 		}
 
-		podIp, err = client.GetPodIP()
+		podIp, podIpv6, err := client.GetPodIP()
 		if err != nil {
 			continue
 		}
@@ -347,7 +585,16 @@ func (v *gcpPodProvider) ListInstances() ([]*common.PodData, error) {
 			continue
 		}
 
-		name := podIp
+		addrs = common.AddInterfaceAddresses(client, addrs)
+
+		// the sandbox is named after the instance's private ip
+		name := vmIp
+		if common.OverlayEnabled() {
+			// its pod ip is tunneled to it again
+			if _, err := common.JoinOverlay(client, agentIp, podIp); err != nil {
+				glog.Warningf("ListInstances: %v", err)
+			}
+		}
 
 		vm := &gcpvm.VM{
 			Name:        instance.Name,
@@ -357,20 +604,110 @@ func (v *gcpPodProvider) ListInstances() ([]*common.PodData, error) {
 			AccountFile: v.config.AuthFile,
 		}
 
-		providerData := &podData{}
+		providerData := &podData{instanceId: &instance.Name, service: s}
+		adopted[instance.Name] = true
+		if !common.HasAddress(addrs, podIp) && !common.OverlayEnabled() {
+			providerData.routedIp = podIp
+			wanted[podIp] = instance.Name
+		}
+		if mode, err := common.HostPortMode(config.Annotations); err == nil && mode == common.HostPortFirewall && len(common.HostPorts(config)) > 0 {
+			providerData.hostPortFirewall = gcp.HostPortFirewallName(instance.Name)
+		}
+		if name := common.ParseCommonAnnotations(config.Annotations).DNSName; name != "" && v.dns != nil {
+			providerData.dnsName, _ = v.dns.Name(name)
+		}
+		providerData.lbGroup = common.ParseCommonAnnotations(config.Annotations).LBGroup
+		if want := common.ParseCommonAnnotations(config.Annotations).StaticIP; want != "" {
+			static, err := instanceStaticIP(s, instance.Name, publicIp, want)
+			if err != nil {
+				glog.Warningf("ListInstances: %v", err)
+			}
+			providerData.staticIP = static
+		}
 
-		v.ipList.FindAndRemove(podIp)
+		v.ipList.FindAndRemove(name)
 
 		glog.Infof("ListInstances: creating a podData for %v", name)
 		booted := true
 		podData := common.NewPodData(vm, name, config.Metadata, config.Annotations, config.Labels, podIp, config.Linux, client, booted, providerData)
+		podData.Ips = common.AddressIPs(addrs)
+		podData.Ipv6 = podIpv6
+		podData.PortMappings = common.HostPorts(config)
+		if static := providerData.staticIP; static != nil {
+			podData.StaticIP = static.address
+			podData.StaticIPState = common.StaticIPDisassociated
+			if static.associated {
+				podData.StaticIPState = common.StaticIPAssociated
+			}
+		}
 
 		podDatas = append(podDatas, podData)
 	}
 
+	v.reconcileRoutes(s, instances, wanted, adopted)
+	reconcileFirewalls(s, gcp.HostPortPrefix, instances)
+	reconcileFirewalls(s, gcp.FirewallPrefix, instances)
+	reconcileStaticIPs(s, adopted)
+
 	return podDatas, nil
 }
 
+// reconcileRoutes makes infranetes' routes match the pods found at startup.  Their routes are put back if they were
+// lost, and the routes of ips they no longer have or of this zone's instances that are gone are removed.  Routes to
+// other zones' instances, or to instances that couldn't be looked up, are left to whoever made them.
+func (v *gcpPodProvider) reconcileRoutes(s *gcp.GcpSvcWrapper, instances []*googlecloud.Instance, wanted map[string]string, adopted map[string]bool) {
+	routes, err := s.ListRoutes(gcp.RoutePrefix)
+	if err != nil {
+		glog.Warningf("reconcileRoutes: %v", err)
+		return
+	}
+
+	existing := make(map[string]bool)
+	for _, instance := range instances {
+		existing[instance.Name] = true
+	}
+
+	current := make(map[string]string)
+	for _, route := range routes {
+		if !s.InZone(route.NextHopInstance) {
+			continue
+		}
+
+		ip := strings.TrimSuffix(route.DestRange, "/32")
+		instance := route.NextHopInstance[strings.LastIndex(route.NextHopInstance, "/")+1:]
+		current[ip] = instance
+
+		if wanted[ip] == instance {
+			continue
+		}
+		if !adopted[instance] {
+			if existing[instance] {
+				continue
+			}
+			gone, err := s.InstanceGone(instance)
+			if err != nil {
+				glog.Warningf("reconcileRoutes: %v", err)
+			}
+			if !gone {
+				continue
+			}
+		}
+
+		glog.Infof("reconcileRoutes: removing stale route of %v to %v", ip, instance)
+		if err := s.DelRoute(route.Name); err != nil {
+			glog.Warningf("reconcileRoutes: %v", err)
+		}
+	}
+
+	for ip, instance := range wanted {
+		if current[ip] != instance {
+			if err := s.AddRoute(gcp.RouteName(ip), v.config.Network, instance, ip); err != nil {
+				glog.Warningf("reconcileRoutes: couldn't route %v to %v: %v", ip, instance, err)
+			}
+		}
+	}
+}
+
 func (p *podData) Attach(vol, device string) (string, error) {
 	glog.Infof("Attach: enter: vol = %v, device = %v", vol, device)
 	p.lock.Lock()
@@ -0,0 +1,39 @@
+package gcp
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+
+	googlecloud "google.golang.org/api/compute/v1"
+
+	"github.com/apporbit/infranetes/pkg/common/gcp"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+// hostPortsAllowed is what a firewall rule has to let in for mappings' host ports
+func hostPortsAllowed(mappings []*kubeapi.PortMapping) []*googlecloud.FirewallAllowed {
+	allowed := []*googlecloud.FirewallAllowed{}
+	for _, mapping := range mappings {
+		allowed = append(allowed, &googlecloud.FirewallAllowed{
+			IPProtocol: strings.ToLower(mapping.Protocol.String()),
+			Ports:      []string{strconv.Itoa(int(mapping.HostPort))},
+		})
+	}
+	return allowed
+}
+
+// openHostPorts lets the pod's host ports in to its instance, which is tagged with its own name
+func openHostPorts(s *gcp.GcpSvcWrapper, network string, instance string, mappings []*kubeapi.PortMapping) (string, error) {
+	name := gcp.HostPortFirewallName(instance)
+
+	if err := s.AddFirewall(name, network, instance, []string{"0.0.0.0/0"}, hostPortsAllowed(mappings)); err != nil {
+		return "", err
+	}
+
+	glog.Infof("openHostPorts: opened %+v on %v", mappings, instance)
+
+	return name, nil
+}
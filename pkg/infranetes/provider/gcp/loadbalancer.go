@@ -0,0 +1,96 @@
+package gcp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+
+	"github.com/apporbit/infranetes/cmd/infranetes/flags"
+	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+// registerTarget puts a booted pod's instance in the instance group its infranetes.lb.group annotation names, which
+// backend services balance to.  A pod that isn't in its group still runs, its status shows it isn't.
+func (p *gcpPodProvider) registerTarget(data *common.PodData, config *kubeapi.PodSandboxConfig) {
+	group := common.ParseCommonAnnotations(config.Annotations).LBGroup
+	if group == "" {
+		return
+	}
+
+	providerData, ok := data.ProviderData.(*podData)
+	if !ok {
+		return
+	}
+
+	if err := providerData.service.AddToInstanceGroup(group, *providerData.instanceId); err != nil {
+		glog.Warningf("registerTarget: couldn't add %v to %v: %v", *providerData.instanceId, group, err)
+		data.LBHealth = "unregistered"
+		return
+	}
+	providerData.lbGroup = group
+
+	glog.Infof("registerTarget: added %v to %v", *providerData.instanceId, group)
+}
+
+// DeregisterLoadBalancer takes the pod's instance out of its instance group, which its backend services drain it of.
+// Pods removed without being stopped needn't be, deleting an instance takes it out of its groups.
+func (v *gcpPodProvider) DeregisterLoadBalancer(data *common.PodData) {
+	providerData, ok := data.ProviderData.(*podData)
+	if !ok {
+		return
+	}
+
+	// the pod's lock isn't held while it drains, so its group is only taken under its provider data's
+	providerData.lock.Lock()
+	group := providerData.lbGroup
+	providerData.lbGroup = ""
+	providerData.lock.Unlock()
+	if group == "" {
+		return
+	}
+
+	if err := providerData.service.RemoveFromInstanceGroup(group, *providerData.instanceId, *flags.LBDrainTimeout); err != nil {
+		glog.Warningf("DeregisterLoadBalancer: couldn't remove %v from %v: %v", *providerData.instanceId, group, err)
+	}
+}
+
+// LoadBalancerHealth returns the instance's health in each of the backend services its group is in, such as
+// "web-backend=healthy"
+func (v *gcpPodProvider) LoadBalancerHealth(data *common.PodData) (string, error) {
+	providerData, ok := data.ProviderData.(*podData)
+	if !ok {
+		return "unregistered", nil
+	}
+
+	providerData.lock.Lock()
+	group := providerData.lbGroup
+	providerData.lock.Unlock()
+	if group == "" {
+		return "unregistered", nil
+	}
+
+	services, err := providerData.service.BackendServices(group)
+	if err != nil {
+		return "", err
+	}
+	if len(services) == 0 {
+		return "unused", nil
+	}
+
+	states := []string{}
+	for _, service := range services {
+		state, err := providerData.service.InstanceHealth(service, group, *providerData.instanceId)
+		if err != nil {
+			return "", fmt.Errorf("%v: %v", service, err)
+		}
+		if state == "" {
+			state = "initial"
+		}
+		states = append(states, service+"="+strings.ToLower(state))
+	}
+
+	return strings.Join(states, ","), nil
+}
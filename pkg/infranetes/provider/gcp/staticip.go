@@ -0,0 +1,157 @@
+package gcp
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/golang/glog"
+
+	"github.com/apporbit/infranetes/pkg/common/gcp"
+	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
+)
+
+// staticIP is the static ip of a pod with infranetes.staticip
+type staticIP struct {
+	name    string
+	address string
+	// allocated is set when infranetes reserved it for the pod, and releases it with the pod
+	allocated  bool
+	associated bool
+}
+
+// associateStaticIP makes the static ip named want the public ip of instance, reserving one if want is
+// common.StaticIPAllocate
+func associateStaticIP(s *gcp.GcpSvcWrapper, instance string, want string) (*staticIP, error) {
+	if strings.HasPrefix(want, common.StaticIPPoolPrefix) {
+		return nil, fmt.Errorf("static ip pools aren't supported on gcp")
+	}
+
+	ip := &staticIP{name: want}
+
+	if want == common.StaticIPAllocate {
+		ip.name = gcp.StaticIPName(instance)
+		if err := s.ReserveAddress(ip.name, "infranetes static ip of "+instance); err != nil {
+			return nil, fmt.Errorf("couldn't reserve a static ip: %v", err)
+		}
+		ip.allocated = true
+	}
+
+	err := func() error {
+		address, err := s.GetAddress(ip.name)
+		if err != nil {
+			return err
+		}
+		// taking it from another instance could take it from a running pod
+		if address.Status == "IN_USE" {
+			return fmt.Errorf("static ip %v is in use by %v", ip.name, strings.Join(address.Users, ", "))
+		}
+		ip.address = address.Address
+
+		if err := s.SetNatIP(instance, ip.address); err != nil {
+			// an instance without a public ip can't be reached by it
+			if err := s.SetNatIP(instance, ""); err != nil {
+				glog.Warningf("associateStaticIP: %v", err)
+			}
+			return err
+		}
+
+		return nil
+	}()
+	if err != nil {
+		if err := releaseStaticIP(s, ip); err != nil {
+			glog.Warningf("associateStaticIP: %v", err)
+		}
+		return nil, err
+	}
+	ip.associated = true
+
+	glog.Infof("associateStaticIP: associated %v (%v) with %v", ip.name, ip.address, instance)
+
+	return ip, nil
+}
+
+// disassociateStaticIP gives instance an ephemeral public ip in place of its static one
+func disassociateStaticIP(s *gcp.GcpSvcWrapper, instance string, ip *staticIP) error {
+	if !ip.associated {
+		return nil
+	}
+
+	if err := s.SetNatIP(instance, ""); err != nil {
+		return err
+	}
+	ip.associated = false
+
+	glog.Infof("disassociateStaticIP: disassociated %v from %v", ip.name, instance)
+
+	return nil
+}
+
+// releaseStaticIP releases a static ip infranetes reserved
+func releaseStaticIP(s *gcp.GcpSvcWrapper, ip *staticIP) error {
+	if !ip.allocated {
+		return nil
+	}
+
+	if err := s.ReleaseAddress(ip.name); err != nil {
+		return fmt.Errorf("couldn't release static ip %v: %v", ip.name, err)
+	}
+
+	glog.Infof("releaseStaticIP: released %v", ip.name)
+
+	return nil
+}
+
+// instanceStaticIP returns the static ip the pod on instance asked for, and whether it's still instance's public ip
+func instanceStaticIP(s *gcp.GcpSvcWrapper, instance string, natIP net.IP, want string) (*staticIP, error) {
+	ip := &staticIP{name: want}
+	if want == common.StaticIPAllocate {
+		ip.name = gcp.StaticIPName(instance)
+		ip.allocated = true
+	}
+
+	address, err := s.GetAddress(ip.name)
+	if err != nil {
+		return nil, err
+	}
+	ip.address = address.Address
+	ip.associated = natIP != nil && natIP.String() == address.Address
+
+	return ip, nil
+}
+
+// reconcileStaticIPs releases the static ips infranetes reserved for instances it didn't find at startup
+func reconcileStaticIPs(s *gcp.GcpSvcWrapper, adopted map[string]bool) {
+	addresses, err := s.ListAddresses(gcp.StaticIPPrefix)
+	if err != nil {
+		glog.Warningf("reconcileStaticIPs: %v", err)
+		return
+	}
+
+	for _, address := range addresses {
+		if adopted["infranetes-"+strings.TrimPrefix(address.Name, gcp.StaticIPPrefix)] {
+			continue
+		}
+		glog.Infof("reconcileStaticIPs: releasing stale %v", address.Name)
+		if err := s.ReleaseAddress(address.Name); err != nil {
+			glog.Warningf("reconcileStaticIPs: %v", err)
+		}
+	}
+}
+
+// setStaticIP makes the static ip the pod's annotations ask for the public ip of instance, returning it and the state
+// of its association.  A pod whose static ip couldn't be associated still runs, with the public ip it booted with.
+func setStaticIP(s *gcp.GcpSvcWrapper, instance string, annotations map[string]string) (*staticIP, string) {
+	want := common.ParseCommonAnnotations(annotations).StaticIP
+	if want == "" {
+		return nil, ""
+	}
+
+	ip, err := associateStaticIP(s, instance, want)
+	if err != nil {
+		glog.Warningf("setStaticIP: %v", err)
+		return nil, common.StaticIPFailed(err)
+	}
+
+	return ip, common.StaticIPAssociated
+}
@@ -18,6 +18,22 @@ type PodProvider interface {
 	ListInstances() ([]*common.PodData, error)
 }
 
+// FirewallProvider is a PodProvider that can give each pod a firewall of its own for -firewall-policy.  Providers
+// apply the policy when they boot a pod, UpdateFirewall replaces what a booted pod's firewall lets in.
+type FirewallProvider interface {
+	UpdateFirewall(podData *common.PodData, ingress []common.FirewallIngress) error
+}
+
+// LoadBalancerProvider is a PodProvider that registers pods with the infranetes.lb.group annotation in its cloud's
+// load balancers when it boots them
+type LoadBalancerProvider interface {
+	// DeregisterLoadBalancer takes the pod out of its group, waiting up to -lb-drain-timeout for its connections to
+	// drain
+	DeregisterLoadBalancer(podData *common.PodData)
+	// LoadBalancerHealth returns the pod's health as its load balancer sees it
+	LoadBalancerHealth(podData *common.PodData) (string, error)
+}
+
 type ImageProvider interface {
 	ListImages(req *kubeapi.ListImagesRequest) (*kubeapi.ListImagesResponse, error)
 	ImageStatus(req *kubeapi.ImageStatusRequest) (*kubeapi.ImageStatusResponse, error)
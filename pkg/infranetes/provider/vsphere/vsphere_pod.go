@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"strings"
 
 	"github.com/golang/glog"
@@ -12,6 +13,7 @@ import (
 	"github.com/apcera/libretto/ssh"
 	vsvm "github.com/apcera/libretto/virtualmachine/vsphere"
 
+	"github.com/apporbit/infranetes/cmd/infranetes/flags"
 	"github.com/apporbit/infranetes/pkg/infranetes/provider"
 	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
 	"github.com/apporbit/infranetes/pkg/infranetes/types"
@@ -69,6 +71,11 @@ func (p *vspherePodProvider) bootSandbox(vm *vsvm.VM, config *kubeapi.PodSandbox
 	// 1. Parse Annotations from PodSandboxConfig
 	cAnno := common.ParseCommonAnnotations(config.Annotations)
 
+	podPolicy, agentPolicy, err := common.IPPolicies(config.Annotations)
+	if err != nil {
+		return nil, fmt.Errorf("CreatePodSandbox: %v", err)
+	}
+
 	// 2. Boot VM
 	if err := vm.Provision(); err != nil {
 		return nil, fmt.Errorf("failed to provision vm: %v\n", err)
@@ -82,18 +89,30 @@ func (p *vspherePodProvider) bootSandbox(vm *vsvm.VM, config *kubeapi.PodSandbox
 
 	glog.Infof("CreatePodSandbox: ips = %v", ips)
 
-	// FIXME: Need to learn from practic which is the correct index
-	index := 0
-	podIp := ips[index].String()
+	// vsphere doesn't tell public addresses apart, all are the guest's own
+	addrs := common.CloudAddresses(nil, ips...)
 
-	glog.Infof("CreatePodSandbox: podIp = %v", podIp)
+	agentIp, err := agentPolicy.Select(addrs)
+	if err != nil {
+		return nil, fmt.Errorf("CreatePodSandbox: %v", err)
+	}
 
 	// 4. Connect to VMServer in VM
-	client, err := common.CreateRealClient(podIp)
+	client, err := common.CreateRealClient(agentIp)
 	if err != nil {
 		return nil, fmt.Errorf("CreatePodSandbox: error in createClient(): %v", err)
 	}
 
+	addrs = common.AddInterfaceAddresses(client, addrs)
+
+	podIp, err := podPolicy.Select(addrs)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("CreatePodSandbox: %v", err)
+	}
+
+	glog.Infof("CreatePodSandbox: podIp = %v, agentIp = %v", podIp, agentIp)
+
 	// 5. Setup Instance / VM Correctly
 	// Store Config so can be recovered if neccessary
 	err = client.SetSandboxConfig(config)
@@ -101,7 +120,24 @@ func (p *vspherePodProvider) bootSandbox(vm *vsvm.VM, config *kubeapi.PodSandbox
 		glog.Warningf("CreatePodSandbox: Failed to save sandbox config: %v", err)
 	}
 
-	err = client.SetPodIP(podIp)
+	// The VM keeps its own ip for reaching it, the pod gets one from the cluster's pod network
+	podIpv6 := ""
+	if common.CNIEnabled() {
+		podIp, podIpv6, err = common.SetupCNI(client, name)
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("CreatePodSandbox: couldn't join the pod network: %v", err)
+		}
+	} else if *flags.DualStack {
+		// the guest's own ipv6 address, from its network's router advertisements or dhcpv6
+		podIpv6 = common.SelectIPv6(addrs)
+	}
+
+	if *flags.DualStack && podIpv6 == "" {
+		glog.Warningf("CreatePodSandbox: %v has no ipv6 address", name)
+	}
+
+	err = client.SetPodIP(podIp, podIpv6)
 	if err != nil {
 		glog.Warningf("CreatePodSandbox: Failed to configure inteface: %v", err)
 	}
@@ -117,6 +153,13 @@ func (p *vspherePodProvider) bootSandbox(vm *vsvm.VM, config *kubeapi.PodSandbox
 		glog.Infof("CreatePodSandbox: Skipping Proxy")
 	}
 
+	if *flags.NetworkPolicy {
+		err = client.StartNetworkPolicy()
+		if err != nil {
+			glog.Warningf("CreatePodSandbox: Couldn't enforce network policies: %v", err)
+		}
+	}
+
 	// Do we set the hostname to the pod's name
 	if cAnno.SetHostname {
 		err = client.SetHostname(config.GetHostname())
@@ -140,6 +183,8 @@ func (p *vspherePodProvider) bootSandbox(vm *vsvm.VM, config *kubeapi.PodSandbox
 	booted := true
 
 	podData := common.NewPodData(vm, name, config.Metadata, config.Annotations, config.Labels, podIp, config.Linux, client, booted, providerData)
+	podData.Ips = common.AddressIPs(addrs)
+	podData.Ipv6 = podIpv6
 
 	return podData, nil
 }
@@ -181,8 +226,13 @@ func (v *vspherePodProvider) ListInstances() ([]*common.PodData, error) {
 		}
 
 		client, err := common.CreateRealClient(podIp)
+		if err != nil {
+			continue
+		}
+
+		addrs := common.AddInterfaceAddresses(client, common.CloudAddresses(nil, net.ParseIP(podIp)))
 
-		podIp, err = client.GetPodIP()
+		podIp, podIpv6, err := client.GetPodIP()
 		if err != nil {
 			continue
 		}
@@ -209,6 +259,9 @@ func (v *vspherePodProvider) ListInstances() ([]*common.PodData, error) {
 		glog.Infof("ListInstances: creating a podData for %v", name)
 		booted := true
 		podData := common.NewPodData(vm, name, config.Metadata, config.Annotations, config.Labels, podIp, config.Linux, client, booted, providerData)
+		podData.Ips = common.AddressIPs(addrs)
+		podData.Ipv6 = podIpv6
+		podData.PortMappings = common.HostPorts(config)
 
 		podDatas = append(podDatas, podData)
 	}
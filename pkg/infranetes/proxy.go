@@ -0,0 +1,75 @@
+package infranetes
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+
+	icommon "github.com/apporbit/infranetes/pkg/common"
+	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+// watchProxies keeps the health of each VM's kube-proxy up to date for its pod's status
+func (m *Manager) watchProxies(period time.Duration) {
+	for range time.Tick(period) {
+		m.pollProxies()
+	}
+}
+
+// pollProxies asks the VMs of ready pods that run kube-proxy how it is
+func (m *Manager) pollProxies() {
+	for id, podData := range m.copyVMMap() {
+		podData.RLock()
+		client := podData.Client
+		booted := podData.Booted
+		state := podData.PodState
+		startProxy := common.ParseCommonAnnotations(podData.Annotations).StartProxy
+		podData.RUnlock()
+
+		if client == nil || !booted || !startProxy || state != kubeapi.PodSandboxState_SANDBOX_READY {
+			continue
+		}
+
+		var summary string
+		status, err := client.ProxyStatus()
+		if err != nil {
+			glog.Warningf("pollProxies: couldn't get %v's kube-proxy status: %v", id, err)
+			summary = "unknown"
+		} else {
+			summary = proxySummary(status)
+		}
+
+		// a pod stopped meanwhile has stopped its kube-proxy
+		podData.Lock()
+		if podData.PodState == kubeapi.PodSandboxState_SANDBOX_READY {
+			if podData.ProxyState != summary {
+				glog.Infof("pollProxies: %v's kube-proxy is %v", id, summary)
+			}
+			podData.ProxyState = summary
+		}
+		podData.Unlock()
+	}
+}
+
+// proxySummary is status in a word, followed by what's wrong and how often kube-proxy was restarted
+func proxySummary(status *icommon.ProxyStatusResponse) string {
+	summary := "healthy"
+	switch {
+	case !status.Running:
+		summary = "stopped"
+	case !status.Healthy:
+		summary = "unhealthy"
+	}
+
+	if summary != "healthy" && status.Message != "" {
+		summary += ": " + status.Message
+	}
+	if status.Restarts > 0 {
+		summary += fmt.Sprintf(" (%d restarts)", status.Restarts)
+	}
+
+	return summary
+}
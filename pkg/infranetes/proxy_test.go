@@ -0,0 +1,92 @@
+package infranetes
+
+import (
+	"testing"
+
+	icommon "github.com/apporbit/infranetes/pkg/common"
+	"github.com/apporbit/infranetes/pkg/infranetes/provider/common"
+	"github.com/apporbit/infranetes/pkg/infranetes/provider/fake"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+// proxyClient is a vmserver whose kube-proxy reports status until it's stopped
+type proxyClient struct {
+	common.Client
+
+	status  *icommon.ProxyStatusResponse
+	stopped bool
+}
+
+func (c *proxyClient) ProxyStatus() (*icommon.ProxyStatusResponse, error) {
+	return c.status, nil
+}
+
+func (c *proxyClient) StopProxy() error {
+	c.stopped = true
+	return nil
+}
+
+func TestPollProxies(t *testing.T) {
+	fakeClient, _ := common.CreateFakeClient()
+	client := &proxyClient{Client: fakeClient, status: &icommon.ProxyStatusResponse{Running: true, Message: "healthz: connection refused", Restarts: 2}}
+
+	linux := &kubeapi.LinuxPodSandboxConfig{SecurityContext: &kubeapi.LinuxSandboxSecurityContext{}}
+	podData := common.NewPodData(nil, "pod", &kubeapi.PodSandboxMetadata{Name: "pod"}, nil, nil, "10.0.0.1", linux, client, true, nil)
+	podData.Capabilities = &icommon.CapabilitiesResponse{Features: []string{icommon.FeatureProxyControl}}
+
+	m := &Manager{vmMap: map[string]*common.PodData{"pod": podData}}
+
+	m.pollProxies()
+	want := "unhealthy: healthz: connection refused (2 restarts)"
+	if got := podData.PodStatus().Annotations[common.ProxyAnnotation]; got != want {
+		t.Errorf("status annotation = %q, want %q", got, want)
+	}
+
+	// pods without kube-proxy aren't asked
+	podData.Annotations = map[string]string{"infranetes.startproxy": "false"}
+	client.status = nil
+	m.pollProxies()
+	if podData.ProxyState != want {
+		t.Errorf("pod without kube-proxy is %q", podData.ProxyState)
+	}
+}
+
+func TestStopSandboxStopsProxy(t *testing.T) {
+	fakeClient, _ := common.CreateFakeClient()
+	client := &proxyClient{Client: fakeClient}
+
+	linux := &kubeapi.LinuxPodSandboxConfig{SecurityContext: &kubeapi.LinuxSandboxSecurityContext{}}
+	podData := common.NewPodData(nil, "pod", &kubeapi.PodSandboxMetadata{Name: "pod"}, nil, nil, "10.0.0.1", linux, client, true, nil)
+	podData.Capabilities = &icommon.CapabilitiesResponse{Features: []string{icommon.FeatureProxyControl}}
+	podData.ProxyState = "healthy"
+
+	pods, err := fake.NewFakePodProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Manager{podProvider: pods, vmMap: map[string]*common.PodData{"pod": podData}}
+
+	if _, err := m.stopSandbox(&kubeapi.StopPodSandboxRequest{PodSandboxId: "pod"}); err != nil {
+		t.Fatal(err)
+	}
+	if !client.stopped || podData.ProxyState != "" {
+		t.Errorf("stopped sandbox's kube-proxy stopped %v, state %q", client.stopped, podData.ProxyState)
+	}
+}
+
+func TestProxySummary(t *testing.T) {
+	for _, test := range []struct {
+		status *icommon.ProxyStatusResponse
+		want   string
+	}{
+		{&icommon.ProxyStatusResponse{Running: true, Healthy: true}, "healthy"},
+		{&icommon.ProxyStatusResponse{Running: true, Healthy: true, Restarts: 1}, "healthy (1 restarts)"},
+		{&icommon.ProxyStatusResponse{Message: "kube-proxy not started"}, "stopped: kube-proxy not started"},
+		{&icommon.ProxyStatusResponse{Running: true}, "unhealthy"},
+	} {
+		if got := proxySummary(test.status); got != test.want {
+			t.Errorf("proxySummary(%+v) = %q, want %q", test.status, got, test.want)
+		}
+	}
+}
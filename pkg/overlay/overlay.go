@@ -0,0 +1,438 @@
+/* Tunnel devices for the overlay network between VM pods and the infranetes node */
+
+package overlay
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	"golang.org/x/crypto/curve25519"
+)
+
+const (
+	ModeVXLAN     = "vxlan"
+	ModeWireGuard = "wireguard"
+
+	DefaultDevice        = "infranetes0"
+	DefaultVNI           = 4242
+	DefaultVXLANPort     = 4789
+	DefaultWireGuardPort = 51820
+
+	// room for the encapsulation on a 1500 byte underlay
+	vxlanMTU     = 1450
+	wireGuardMTU = 1420
+
+	// vxlan floods to every remote with this mac in its fdb
+	floodMAC = "00:00:00:00:00:00"
+)
+
+// Config is one end of the overlay
+type Config struct {
+	// Mode is ModeVXLAN or ModeWireGuard
+	Mode string
+	// Device is the tunnel's interface, DefaultDevice if empty
+	Device string
+	// Address is this end's address on the overlay in cidr notation
+	Address string
+	// Underlay is the local address tunneled traffic is sent from, any if empty
+	Underlay string
+	// Port is the udp port tunneled traffic is sent to, the mode's default if 0
+	Port int
+	// VNI is the vxlan network identifier, DefaultVNI if 0
+	VNI int
+	// MTU is the tunnel's mtu, the mode's default if 0
+	MTU int
+	// KeyFile holds this end's wireguard private key, it's created if it doesn't exist
+	KeyFile string
+}
+
+// Peer is the other end of a tunnel
+type Peer struct {
+	// Endpoint is the peer's underlay address
+	Endpoint string
+	// PublicKey is the peer's wireguard public key
+	PublicKey string
+	// AllowedIPs are the overlay addresses wireguard accepts from and sends to the peer
+	AllowedIPs []string
+}
+
+// Runner runs a command and returns its combined output
+type Runner func(name string, args ...string) ([]byte, error)
+
+func execRunner(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).CombinedOutput()
+}
+
+// Link is a configured tunnel device
+type Link struct {
+	config    Config
+	run       Runner
+	publicKey string
+}
+
+// Setup creates config's tunnel device and brings it up.  A device of the same name with the same settings is kept
+// with its peers, only its address is changed to config's, and one with other settings is replaced.  Peers and routes
+// are added to it afterwards.  A nil run runs the commands.
+func Setup(config Config, run Runner) (*Link, error) {
+	if run == nil {
+		run = execRunner
+	}
+
+	if config.Device == "" {
+		config.Device = DefaultDevice
+	}
+	if config.VNI == 0 {
+		config.VNI = DefaultVNI
+	}
+
+	switch config.Mode {
+	case ModeVXLAN:
+		if config.Port == 0 {
+			config.Port = DefaultVXLANPort
+		}
+		if config.MTU == 0 {
+			config.MTU = vxlanMTU
+		}
+	case ModeWireGuard:
+		if config.Port == 0 {
+			config.Port = DefaultWireGuardPort
+		}
+		if config.MTU == 0 {
+			config.MTU = wireGuardMTU
+		}
+		if config.KeyFile == "" {
+			return nil, fmt.Errorf("wireguard needs a key file")
+		}
+	default:
+		return nil, fmt.Errorf("unknown overlay mode %q", config.Mode)
+	}
+
+	l := &Link{config: config, run: run}
+
+	if config.Mode == ModeWireGuard {
+		publicKey, err := loadKey(config.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		l.publicKey = publicKey
+	}
+
+	// a device left by a previous run is kept with its peers if it has the same settings, so restarting doesn't cut
+	// off the tunnels
+	existing, err := l.device()
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && !existing.matches(config) {
+		glog.Infof("Setup: recreating %v, which has other settings", config.Device)
+		if err := l.ip("link", "del", "dev", config.Device); err != nil {
+			return nil, err
+		}
+		existing = nil
+	}
+
+	if existing == nil {
+		if config.Mode == ModeVXLAN {
+			args := []string{"link", "add", config.Device, "type", "vxlan", "id", strconv.Itoa(config.VNI), "dstport", strconv.Itoa(config.Port)}
+			if config.Underlay != "" {
+				args = append(args, "local", config.Underlay)
+			}
+			if err := l.ip(args...); err != nil {
+				return nil, err
+			}
+		} else if err := l.ip("link", "add", config.Device, "type", "wireguard"); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Mode == ModeWireGuard {
+		if err := l.wg("set", config.Device, "listen-port", strconv.Itoa(config.Port), "private-key", config.KeyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := l.ip("link", "set", config.Device, "mtu", strconv.Itoa(config.MTU)); err != nil {
+		return nil, err
+	}
+	if err := l.syncAddress(); err != nil {
+		return nil, err
+	}
+	if err := l.ip("link", "set", config.Device, "up"); err != nil {
+		return nil, err
+	}
+
+	glog.Infof("Setup: %v overlay on %v with address %v", config.Mode, config.Device, config.Address)
+
+	return l, nil
+}
+
+// PublicKey is the link's wireguard public key, empty for vxlan
+func (l *Link) PublicKey() string {
+	return l.publicKey
+}
+
+// Port is the udp port the link's tunnels use
+func (l *Link) Port() int {
+	return l.config.Port
+}
+
+// AddPeer tunnels to peer, replacing what was known about it
+func (l *Link) AddPeer(peer Peer) error {
+	if l.config.Mode == ModeVXLAN {
+		// append fails if the entry exists already, which is what we want anyway
+		if err := l.bridge("fdb", "append", floodMAC, "dev", l.config.Device, "dst", peer.Endpoint); err != nil && !strings.Contains(err.Error(), "File exists") {
+			return err
+		}
+		return nil
+	}
+
+	if peer.PublicKey == "" {
+		return fmt.Errorf("wireguard peer %v has no public key", peer.Endpoint)
+	}
+
+	return l.wg("set", l.config.Device, "peer", peer.PublicKey,
+		"endpoint", fmt.Sprintf("%s:%d", peer.Endpoint, l.config.Port),
+		"allowed-ips", strings.Join(peer.AllowedIPs, ","),
+		"persistent-keepalive", "25")
+}
+
+// RemovePeer stops tunneling to peer
+func (l *Link) RemovePeer(peer Peer) error {
+	if l.config.Mode == ModeVXLAN {
+		return l.bridge("fdb", "del", floodMAC, "dev", l.config.Device, "dst", peer.Endpoint)
+	}
+
+	return l.wg("set", l.config.Device, "peer", peer.PublicKey, "remove")
+}
+
+// Peers returns who the link tunnels to: the endpoints of vxlan's, and the public keys and endpoints of wireguard's
+func (l *Link) Peers() ([]Peer, error) {
+	peers := []Peer{}
+
+	if l.config.Mode == ModeVXLAN {
+		output, err := l.output("bridge", "fdb", "show", "dev", l.config.Device)
+		if err != nil {
+			return nil, err
+		}
+
+		// 00:00:00:00:00:00 dst 172.20.0.11 self permanent
+		for _, line := range strings.Split(output, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) >= 3 && fields[0] == floodMAC && fields[1] == "dst" {
+				peers = append(peers, Peer{Endpoint: fields[2]})
+			}
+		}
+
+		return peers, nil
+	}
+
+	output, err := l.output("wg", "show", l.config.Device, "endpoints")
+	if err != nil {
+		return nil, err
+	}
+
+	// <public key>\t<endpoint>:<port>, or (none) for peers that haven't been heard from
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		peer := Peer{PublicKey: fields[0]}
+		if host, _, err := net.SplitHostPort(fields[1]); err == nil {
+			peer.Endpoint = host
+		}
+		peers = append(peers, peer)
+	}
+
+	return peers, nil
+}
+
+// SyncPeers stops tunneling to the link's peers that aren't among peers, which are told apart by their endpoints for
+// vxlan and their public keys for wireguard
+func (l *Link) SyncPeers(peers []Peer) error {
+	existing, err := l.Peers()
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[string]bool)
+	for _, peer := range peers {
+		wanted[l.peerKey(peer)] = true
+	}
+
+	for _, peer := range existing {
+		if wanted[l.peerKey(peer)] {
+			continue
+		}
+		glog.Infof("SyncPeers: removing stale peer %v from %v", peer.Endpoint, l.config.Device)
+		if err := l.RemovePeer(peer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *Link) peerKey(peer Peer) string {
+	if l.config.Mode == ModeVXLAN {
+		return peer.Endpoint
+	}
+	return peer.PublicKey
+}
+
+// AddRoute routes cidr through the tunnel, via the overlay address via if it isn't empty
+func (l *Link) AddRoute(cidr string, via string) error {
+	args := []string{"route", "replace", cidr}
+	if via != "" {
+		args = append(args, "via", via)
+	}
+	args = append(args, "dev", l.config.Device)
+
+	return l.ip(args...)
+}
+
+// device is the settings of the link's device as ip reports them, nil if there's no such device
+type device struct {
+	LinkInfo struct {
+		Kind string `json:"info_kind"`
+		Data struct {
+			ID    int    `json:"id"`
+			Local string `json:"local"`
+			Port  int    `json:"port"`
+		} `json:"info_data"`
+	} `json:"linkinfo"`
+	AddrInfo []struct {
+		Local     string `json:"local"`
+		PrefixLen int    `json:"prefixlen"`
+		Scope     string `json:"scope"`
+	} `json:"addr_info"`
+}
+
+func (l *Link) device() (*device, error) {
+	output, err := l.output("ip", "-d", "-j", "link", "show", "dev", l.config.Device)
+	if err != nil {
+		if strings.Contains(err.Error(), "does not exist") {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var devices []device
+	if err := json.Unmarshal([]byte(output), &devices); err != nil {
+		return nil, fmt.Errorf("couldn't parse %v's settings: %v", l.config.Device, err)
+	}
+	if len(devices) != 1 {
+		return nil, nil
+	}
+
+	return &devices[0], nil
+}
+
+// matches reports if the device tunnels the way config does.  A wireguard device's port and key are set anyway.
+func (d *device) matches(config Config) bool {
+	if config.Mode == ModeWireGuard {
+		return d.LinkInfo.Kind == "wireguard"
+	}
+
+	return d.LinkInfo.Kind == "vxlan" && d.LinkInfo.Data.ID == config.VNI && d.LinkInfo.Data.Port == config.Port && d.LinkInfo.Data.Local == config.Underlay
+}
+
+// syncAddress gives the device config's address and removes its other global ones
+func (l *Link) syncAddress() error {
+	output, err := l.output("ip", "-j", "addr", "show", "dev", l.config.Device)
+	if err != nil {
+		return err
+	}
+
+	var devices []device
+	if err := json.Unmarshal([]byte(output), &devices); err != nil {
+		return fmt.Errorf("couldn't parse %v's addresses: %v", l.config.Device, err)
+	}
+
+	for _, d := range devices {
+		for _, addr := range d.AddrInfo {
+			cidr := fmt.Sprintf("%v/%d", addr.Local, addr.PrefixLen)
+			if addr.Scope != "global" || cidr == l.config.Address {
+				continue
+			}
+			if err := l.ip("addr", "del", cidr, "dev", l.config.Device); err != nil {
+				return err
+			}
+		}
+	}
+
+	return l.ip("addr", "replace", l.config.Address, "dev", l.config.Device)
+}
+
+func (l *Link) ip(args ...string) error {
+	return l.command("ip", args...)
+}
+
+func (l *Link) bridge(args ...string) error {
+	return l.command("bridge", args...)
+}
+
+func (l *Link) wg(args ...string) error {
+	return l.command("wg", args...)
+}
+
+func (l *Link) command(name string, args ...string) error {
+	_, err := l.output(name, args...)
+	return err
+}
+
+func (l *Link) output(name string, args ...string) (string, error) {
+	output, err := l.run(name, args...)
+	if err != nil {
+		return "", fmt.Errorf("%v %v failed: %v: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+
+	return string(output), nil
+}
+
+// loadKey reads the wireguard private key in path, generating it if there's none, and returns its public key base64
+// encoded as wg expects.  wg reads the private key from path itself.
+func loadKey(path string) (string, error) {
+	var private [32]byte
+
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case err == nil:
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != 32 {
+			return "", fmt.Errorf("invalid wireguard key in %v", path)
+		}
+		copy(private[:], key)
+	case os.IsNotExist(err):
+		if _, err := rand.Read(private[:]); err != nil {
+			return "", err
+		}
+		// clamp as curve25519 private keys are
+		private[0] &= 248
+		private[31] = (private[31] & 127) | 64
+
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return "", err
+		}
+		if err := ioutil.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(private[:])+"\n"), 0600); err != nil {
+			return "", err
+		}
+	default:
+		return "", err
+	}
+
+	var public [32]byte
+	curve25519.ScalarBaseMult(&public, &private)
+
+	return base64.StdEncoding.EncodeToString(public[:]), nil
+}
@@ -0,0 +1,267 @@
+package overlay
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// noDevice is what ip says about a device that doesn't exist
+const noDevice = `Device "infranetes0" does not exist.`
+
+type fakeRunner struct {
+	commands []string
+	// commands starting with a key fail with its output
+	failures map[string]string
+	// commands starting with a key output its value
+	outputs map[string]string
+}
+
+func (r *fakeRunner) run(name string, args ...string) ([]byte, error) {
+	command := strings.Join(append([]string{name}, args...), " ")
+	r.commands = append(r.commands, command)
+
+	for prefix, output := range r.failures {
+		if strings.HasPrefix(command, prefix) {
+			return []byte(output), errors.New("exit status 2")
+		}
+	}
+	for prefix, output := range r.outputs {
+		if strings.HasPrefix(command, prefix) {
+			return []byte(output), nil
+		}
+	}
+
+	return nil, nil
+}
+
+// newDevice has ip report no device until it's added, and no addresses on it
+func newDevice(failures map[string]string) *fakeRunner {
+	if failures == nil {
+		failures = make(map[string]string)
+	}
+	failures["ip -d -j link show"] = noDevice
+	return &fakeRunner{failures: failures, outputs: map[string]string{"ip -j addr show": `[{"addr_info": []}]`}}
+}
+
+func TestSetupVXLAN(t *testing.T) {
+	r := newDevice(map[string]string{"bridge fdb append": "RTNETLINK answers: File exists"})
+
+	link, err := Setup(Config{Mode: ModeVXLAN, Address: "10.2.0.1/24", Underlay: "172.20.0.10"}, r.run)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := link.AddPeer(Peer{Endpoint: "172.20.0.11"}); err != nil {
+		t.Errorf("AddPeer of a known peer failed: %v", err)
+	}
+	if err := link.AddRoute("10.2.0.0/16", "10.2.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"ip -d -j link show dev infranetes0",
+		"ip link add infranetes0 type vxlan id 4242 dstport 4789 local 172.20.0.10",
+		"ip link set infranetes0 mtu 1450",
+		"ip -j addr show dev infranetes0",
+		"ip addr replace 10.2.0.1/24 dev infranetes0",
+		"ip link set infranetes0 up",
+		"bridge fdb append 00:00:00:00:00:00 dev infranetes0 dst 172.20.0.11",
+		"ip route replace 10.2.0.0/16 via 10.2.0.1 dev infranetes0",
+	}
+	if !reflect.DeepEqual(r.commands, want) {
+		t.Errorf("commands = %q, want %q", r.commands, want)
+	}
+}
+
+func TestSetupWireGuard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "overlay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "keys", "overlay.key")
+	r := newDevice(nil)
+
+	link, err := Setup(Config{Mode: ModeWireGuard, Address: "10.2.0.5/32", KeyFile: keyFile}, r.run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.PublicKey() == "" || link.Port() != DefaultWireGuardPort {
+		t.Errorf("public key %q and port %v", link.PublicKey(), link.Port())
+	}
+
+	// the generated key is kept
+	again, err := Setup(Config{Mode: ModeWireGuard, Address: "10.2.0.5/32", KeyFile: keyFile}, r.run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.PublicKey() != link.PublicKey() {
+		t.Errorf("public key changed from %v to %v", link.PublicKey(), again.PublicKey())
+	}
+
+	r.commands = nil
+	if err := link.AddPeer(Peer{Endpoint: "172.20.0.10", PublicKey: "cGVlcg==", AllowedIPs: []string{"10.2.0.1/32", "10.3.0.0/16"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := link.AddPeer(Peer{Endpoint: "172.20.0.10"}); err == nil {
+		t.Errorf("AddPeer without a public key succeeded")
+	}
+
+	want := []string{"wg set infranetes0 peer cGVlcg== endpoint 172.20.0.10:51820 allowed-ips 10.2.0.1/32,10.3.0.0/16 persistent-keepalive 25"}
+	if !reflect.DeepEqual(r.commands, want) {
+		t.Errorf("commands = %q, want %q", r.commands, want)
+	}
+}
+
+func TestSetupFailure(t *testing.T) {
+	r := newDevice(map[string]string{"ip link add": "RTNETLINK answers: Operation not supported"})
+
+	_, err := Setup(Config{Mode: ModeVXLAN, Address: "10.2.0.1/24"}, r.run)
+	if err == nil || !strings.Contains(err.Error(), "Operation not supported") {
+		t.Errorf("err = %v", err)
+	}
+
+	if _, err := Setup(Config{Mode: "gre"}, r.run); err == nil {
+		t.Errorf("unknown mode succeeded")
+	}
+}
+
+func TestSetupKeepsDevice(t *testing.T) {
+	r := &fakeRunner{outputs: map[string]string{
+		"ip -d -j link show": `[{"ifname": "infranetes0", "linkinfo": {"info_kind": "vxlan", "info_data": {"id": 4242, "local": "172.20.0.10", "port": 4789}}}]`,
+		"ip -j addr show": `[{"addr_info": [
+			{"family": "inet", "local": "10.2.0.9", "prefixlen": 24, "scope": "global"},
+			{"family": "inet", "local": "10.2.0.1", "prefixlen": 24, "scope": "global"},
+			{"family": "inet6", "local": "fe80::1", "prefixlen": 64, "scope": "link"}
+		]}]`,
+	}}
+
+	if _, err := Setup(Config{Mode: ModeVXLAN, Address: "10.2.0.1/24", Underlay: "172.20.0.10"}, r.run); err != nil {
+		t.Fatal(err)
+	}
+
+	// the device and its peers are kept, only its stale address goes
+	want := []string{
+		"ip -d -j link show dev infranetes0",
+		"ip link set infranetes0 mtu 1450",
+		"ip -j addr show dev infranetes0",
+		"ip addr del 10.2.0.9/24 dev infranetes0",
+		"ip addr replace 10.2.0.1/24 dev infranetes0",
+		"ip link set infranetes0 up",
+	}
+	if !reflect.DeepEqual(r.commands, want) {
+		t.Errorf("commands = %q, want %q", r.commands, want)
+	}
+
+	// one with another vni is replaced
+	r.commands = nil
+	if _, err := Setup(Config{Mode: ModeVXLAN, Address: "10.2.0.1/24", Underlay: "172.20.0.10", VNI: 7}, r.run); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.commands) < 3 || r.commands[1] != "ip link del dev infranetes0" || !strings.HasPrefix(r.commands[2], "ip link add infranetes0 type vxlan id 7 ") {
+		t.Errorf("commands = %q", r.commands)
+	}
+}
+
+func TestSyncPeers(t *testing.T) {
+	r := newDevice(nil)
+	r.outputs["bridge fdb show"] = "00:00:00:00:00:00 dst 172.20.0.11 self permanent\n" +
+		"00:00:00:00:00:00 dst 172.20.0.12 self permanent\n" +
+		"6a:1c:9e:00:00:01 dst 172.20.0.11 self\n"
+
+	link, err := Setup(Config{Mode: ModeVXLAN, Address: "10.2.0.1/24"}, r.run)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.commands = nil
+	if err := link.SyncPeers([]Peer{{Endpoint: "172.20.0.11"}}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"bridge fdb show dev infranetes0",
+		"bridge fdb del 00:00:00:00:00:00 dev infranetes0 dst 172.20.0.12",
+	}
+	if !reflect.DeepEqual(r.commands, want) {
+		t.Errorf("vxlan commands = %q, want %q", r.commands, want)
+	}
+
+	dir, err := ioutil.TempDir("", "overlay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r = newDevice(nil)
+	r.outputs["wg show"] = "a2V5MQ==\t172.20.0.11:51820\nc3RhbGU=\t(none)\n"
+
+	link, err = Setup(Config{Mode: ModeWireGuard, Address: "10.2.0.5/32", KeyFile: filepath.Join(dir, "overlay.key")}, r.run)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peers, err := link.Peers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Peer{{Endpoint: "172.20.0.11", PublicKey: "a2V5MQ=="}, {PublicKey: "c3RhbGU="}}; !reflect.DeepEqual(peers, want) {
+		t.Errorf("peers = %+v, want %+v", peers, want)
+	}
+
+	r.commands = nil
+	if err := link.SyncPeers([]Peer{{Endpoint: "172.20.0.13", PublicKey: "a2V5MQ=="}}); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{
+		"wg show infranetes0 endpoints",
+		"wg set infranetes0 peer c3RhbGU= remove",
+	}
+	if !reflect.DeepEqual(r.commands, want) {
+		t.Errorf("wireguard commands = %q, want %q", r.commands, want)
+	}
+}
+
+func TestPool(t *testing.T) {
+	if _, err := NewPool("10.2.0.0/31"); err == nil {
+		t.Errorf("NewPool of a /31 succeeded")
+	}
+
+	pool, err := NewPool("10.2.0.0/29")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pool.Gateway() != "10.2.0.1" || pool.CIDR("10.2.0.1") != "10.2.0.1/29" || pool.Network() != "10.2.0.0/29" {
+		t.Errorf("gateway %v, cidr %v, network %v", pool.Gateway(), pool.CIDR("10.2.0.1"), pool.Network())
+	}
+
+	if err := pool.Reserve("10.2.0.3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Reserve(pool.Gateway()); err == nil {
+		t.Errorf("Reserve of the gateway succeeded")
+	}
+
+	got := []string{}
+	for {
+		ip, err := pool.Allocate()
+		if err != nil {
+			break
+		}
+		got = append(got, ip)
+	}
+	if want := []string{"10.2.0.2", "10.2.0.4", "10.2.0.5", "10.2.0.6"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("allocated %v, want %v", got, want)
+	}
+
+	pool.Release("10.2.0.4")
+	if ip, err := pool.Allocate(); err != nil || ip != "10.2.0.4" {
+		t.Errorf("Allocate after Release = %v, %v", ip, err)
+	}
+}
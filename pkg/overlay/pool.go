@@ -0,0 +1,124 @@
+package overlay
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// Pool hands out the addresses of an overlay's ipv4 cidr.  Its first host address is the node's, the others are the
+// pods'.
+type Pool struct {
+	network *net.IPNet
+	first   uint32
+	last    uint32
+
+	lock sync.Mutex
+	used map[uint32]bool
+}
+
+func NewPool(cidr string) (*Pool, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	if network.IP.To4() == nil {
+		return nil, fmt.Errorf("overlay cidr %v isn't ipv4", cidr)
+	}
+
+	ones, bits := network.Mask.Size()
+	if bits-ones < 2 {
+		return nil, fmt.Errorf("overlay cidr %v is too small", cidr)
+	}
+
+	base := binary.BigEndian.Uint32(network.IP.To4())
+	size := uint32(1) << uint(bits-ones)
+
+	return &Pool{
+		network: network,
+		// skip the network and broadcast addresses
+		first: base + 1,
+		last:  base + size - 2,
+		used:  make(map[uint32]bool),
+	}, nil
+}
+
+// Gateway is the node's address on the overlay
+func (p *Pool) Gateway() string {
+	return toIP(p.first).String()
+}
+
+// CIDR returns ip with the overlay's prefix length
+func (p *Pool) CIDR(ip string) string {
+	ones, _ := p.network.Mask.Size()
+	return fmt.Sprintf("%s/%d", ip, ones)
+}
+
+// Network is the overlay's cidr
+func (p *Pool) Network() string {
+	return p.network.String()
+}
+
+// Allocate returns an unused pod address
+func (p *Pool) Allocate() (string, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for addr := p.first + 1; addr <= p.last; addr++ {
+		if !p.used[addr] {
+			p.used[addr] = true
+			return toIP(addr).String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("overlay %v has no free addresses", p.network)
+}
+
+// Reserve marks ip as used, for pods that got theirs before infranetes restarted
+func (p *Pool) Reserve(ip string) error {
+	addr, err := p.index(ip)
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.used[addr] = true
+
+	return nil
+}
+
+// Release returns ip to the pool
+func (p *Pool) Release(ip string) {
+	addr, err := p.index(ip)
+	if err != nil {
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.used, addr)
+}
+
+func (p *Pool) index(ip string) (uint32, error) {
+	parsed := net.ParseIP(ip).To4()
+	if parsed == nil || !p.network.Contains(parsed) {
+		return 0, fmt.Errorf("%v isn't in overlay %v", ip, p.network)
+	}
+
+	addr := binary.BigEndian.Uint32(parsed)
+	if addr <= p.first || addr > p.last {
+		return 0, fmt.Errorf("%v isn't a pod address of overlay %v", ip, p.network)
+	}
+
+	return addr, nil
+}
+
+func toIP(addr uint32) net.IP {
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, addr)
+	return ip
+}
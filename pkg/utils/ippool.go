@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"sync"
+)
+
+// IPPool hands out the addresses of a cidr of either family, lowest first.  Unlike a Deque of every address it works
+// for ipv6 ranges too big to list.
+type IPPool struct {
+	sync.Mutex
+	network *net.IPNet
+	first   *big.Int
+	last    *big.Int
+	used    map[string]bool
+}
+
+// NewIPPool returns a pool of cidr's addresses after its first reserved ones.  An ipv4 cidr's broadcast address isn't
+// given out.
+func NewIPPool(cidr string, reserved int) (*IPPool, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	ones, bits := network.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+
+	first := new(big.Int).Add(ipToInt(network.IP), big.NewInt(int64(reserved)))
+	last := new(big.Int).Add(ipToInt(network.IP), size)
+	last.Sub(last, big.NewInt(1))
+	if network.IP.To4() != nil {
+		last.Sub(last, big.NewInt(1))
+	}
+
+	if first.Cmp(last) > 0 {
+		return nil, fmt.Errorf("%v has no addresses after the %v reserved ones", cidr, reserved)
+	}
+
+	return &IPPool{
+		network: network,
+		first:   first,
+		last:    last,
+		used:    make(map[string]bool),
+	}, nil
+}
+
+// Allocate returns an unused address
+func (p *IPPool) Allocate() (string, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	one := big.NewInt(1)
+	for addr := new(big.Int).Set(p.first); addr.Cmp(p.last) <= 0; addr.Add(addr, one) {
+		ip := intToIP(addr, len(p.network.IP)).String()
+		if !p.used[ip] {
+			p.used[ip] = true
+			return ip, nil
+		}
+	}
+
+	return "", fmt.Errorf("%v has no free addresses", p.network)
+}
+
+// Reserve marks ip as used, for addresses handed out before infranetes restarted
+func (p *IPPool) Reserve(ip string) error {
+	parsed := net.ParseIP(ip)
+	if parsed == nil || !p.network.Contains(parsed) {
+		return fmt.Errorf("%v isn't in %v", ip, p.network)
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	p.used[parsed.String()] = true
+
+	return nil
+}
+
+// Release returns ip to the pool
+func (p *IPPool) Release(ip string) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	delete(p.used, parsed.String())
+}
+
+func (p *IPPool) String() string {
+	return p.network.String()
+}
+
+func ipToInt(ip net.IP) *big.Int {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	return new(big.Int).SetBytes(ip)
+}
+
+func intToIP(addr *big.Int, size int) net.IP {
+	ip := make(net.IP, size)
+	b := addr.Bytes()
+	copy(ip[size-len(b):], b)
+	return ip
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestIPPoolIPv6(t *testing.T) {
+	pool, err := NewIPPool("2600:1f14:abc:de00::/64", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"2600:1f14:abc:de00::4", "2600:1f14:abc:de00::5"} {
+		if ip, err := pool.Allocate(); err != nil || ip != want {
+			t.Errorf("Allocate() = %v, %v, want %v", ip, err, want)
+		}
+	}
+
+	if err := pool.Reserve("2600:1f14:abc:de00::6"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Reserve("2600:1f14:abc:de01::6"); err == nil {
+		t.Errorf("Reserve of an address outside the pool succeeded")
+	}
+	pool.Release("2600:1f14:abc:de00:0:0:0:4")
+
+	for _, want := range []string{"2600:1f14:abc:de00::4", "2600:1f14:abc:de00::7"} {
+		if ip, err := pool.Allocate(); err != nil || ip != want {
+			t.Errorf("Allocate() = %v, %v, want %v", ip, err, want)
+		}
+	}
+}
+
+func TestIPPoolIPv4(t *testing.T) {
+	pool, err := NewIPPool("10.0.0.0/30", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"10.0.0.1", "10.0.0.2"} {
+		if ip, err := pool.Allocate(); err != nil || ip != want {
+			t.Errorf("Allocate() = %v, %v, want %v", ip, err, want)
+		}
+	}
+
+	// the broadcast address isn't given out
+	if ip, err := pool.Allocate(); err == nil {
+		t.Errorf("Allocate() of a full pool = %v", ip)
+	}
+}
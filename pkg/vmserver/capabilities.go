@@ -0,0 +1,61 @@
+package vmserver
+
+import (
+	"net"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+
+	"github.com/apporbit/infranetes/pkg/common"
+)
+
+func (m *VMserver) Capabilities(ctx context.Context, req *common.CapabilitiesRequest) (*common.CapabilitiesResponse, error) {
+	glog.V(1).Infof("Capabilities: req = %+v", req)
+
+	features := []string{common.FeatureProxy, common.FeatureProxyControl, common.FeatureProxyKubeconfig, common.FeatureNetworkPolicy, common.FeatureOverlay, common.FeatureCNI, common.FeatureAddresses, common.FeatureHostPorts, common.FeatureMetrics, common.FeatureEvents}
+	features = append(features, m.contProvider.Features()...)
+
+	streamingEndpoint := ""
+	if m.streamingServer != nil && m.podIp != nil {
+		streamingEndpoint = net.JoinHostPort(*m.podIp, streamingPort)
+	}
+
+	subsystems := []*common.SubsystemStatus{}
+
+	runtime := &common.SubsystemStatus{Name: common.SubsystemContainerRuntime, Ready: true}
+	if err := m.contProvider.Ready(); err != nil {
+		runtime.Ready = false
+		runtime.Message = err.Error()
+	}
+	subsystems = append(subsystems, runtime)
+
+	streaming := &common.SubsystemStatus{Name: common.SubsystemStreaming, Ready: m.streamingServer != nil}
+	if m.contProvider.GetStreamingRuntime() == nil {
+		streaming.Message = "container provider doesn't support streaming"
+	} else if m.podIp == nil {
+		streaming.Message = "pod ip not set"
+	}
+	subsystems = append(subsystems, streaming)
+
+	proxyStatus := m.proxyStatus()
+	proxy := &common.SubsystemStatus{Name: common.SubsystemProxy, Ready: proxyStatus.Healthy, Message: proxyStatus.Message}
+	subsystems = append(subsystems, proxy)
+
+	subsystems = append(subsystems, &common.SubsystemStatus{Name: common.SubsystemMetrics, Ready: m.cadvisor != nil})
+
+	policyStatus := m.networkPolicyStatus()
+	policy := &common.SubsystemStatus{Name: common.SubsystemNetworkPolicy, Ready: policyStatus.Running && policyStatus.Message == "", Message: policyStatus.Message}
+	subsystems = append(subsystems, policy)
+
+	resp := &common.CapabilitiesResponse{
+		ProtocolVersion:   common.ProtocolVersion,
+		Features:          features,
+		ContainerProvider: m.providerName,
+		StreamingEndpoint: streamingEndpoint,
+		Subsystems:        subsystems,
+	}
+
+	glog.V(1).Infof("Capabilities: resp = %+v", resp)
+
+	return resp, nil
+}
@@ -31,7 +31,19 @@ func (m *VMserver) SetPodIP(ctx context.Context, req *common.SetIPRequest) (*com
 		return nil, fmt.Errorf("SetPodIP: %v is an invalid ip address", req.Ip)
 	}
 
+	m.podIpv6 = nil
+	if req.Ipv6 != "" {
+		val := net.ParseIP(req.Ipv6)
+		if val == nil || val.To4() != nil {
+			return nil, fmt.Errorf("SetPodIP: %v is an invalid ipv6 address", req.Ipv6)
+		}
+		m.podIpv6 = &req.Ipv6
+	}
+
 	m.podIp = &req.Ip
+	if err := m.saveState(); err != nil {
+		glog.Warningf("SetPodIP: couldn't save state: %v", err)
+	}
 
 	err := m.startStreamingServer()
 	if err != nil {
@@ -46,7 +58,12 @@ func (m *VMserver) GetPodIP(ctx context.Context, req *common.GetIPRequest) (*com
 		return nil, fmt.Errorf("GetPodIP: podIp wasn't set")
 	}
 
-	return &common.GetIPResponse{Ip: *m.podIp}, nil
+	resp := &common.GetIPResponse{Ip: *m.podIp}
+	if m.podIpv6 != nil {
+		resp.Ipv6 = *m.podIpv6
+	}
+
+	return resp, nil
 }
 
 func (m *VMserver) SetSandboxConfig(ctx context.Context, req *common.SetSandboxConfigRequest) (*common.SetSandboxConfigResponse, error) {
@@ -56,6 +73,9 @@ func (m *VMserver) SetSandboxConfig(ctx context.Context, req *common.SetSandboxC
 		return nil, fmt.Errorf("SetSandboxConfig: couldn't unmarshall the sandbox config")
 	}
 	m.config = &sandboxConfig
+	if err := m.saveState(); err != nil {
+		glog.Warningf("SetSandboxConfig: couldn't save state: %v", err)
+	}
 
 	return &common.SetSandboxConfigResponse{}, nil
 }
@@ -166,3 +186,34 @@ func (m *VMserver) AddRoute(ctx context.Context, req *common.AddRouteRequest) (*
 
 	return &common.AddRouteResponse{}, nil
 }
+
+func (m *VMserver) ListAddresses(ctx context.Context, req *common.ListAddressesRequest) (*common.ListAddressesResponse, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("ListAddresses: %v", err)
+	}
+
+	resp := &common.ListAddressesResponse{}
+
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			glog.Warningf("ListAddresses: couldn't get addresses of %v: %v", iface.Name, err)
+			continue
+		}
+
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok || ipnet.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			resp.Addresses = append(resp.Addresses, &common.InterfaceAddress{Interface: iface.Name, Ip: ipnet.IP.String()})
+		}
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,30 @@
+package vmserver
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/apporbit/infranetes/pkg/common"
+)
+
+func TestListAddressesSkipsLoopback(t *testing.T) {
+	m := &VMserver{}
+
+	resp, err := m.ListAddresses(context.Background(), &common.ListAddressesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, addr := range resp.Addresses {
+		ip := net.ParseIP(addr.Ip)
+		if ip == nil {
+			t.Errorf("%v has an invalid ip %q", addr.Interface, addr.Ip)
+			continue
+		}
+		if ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+			t.Errorf("ListAddresses returned %v on %v", addr.Ip, addr.Interface)
+		}
+	}
+}
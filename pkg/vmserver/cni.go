@@ -0,0 +1,182 @@
+package vmserver
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/types/current"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+
+	"github.com/apporbit/infranetes/pkg/common"
+)
+
+// cniIfName is the interface the CNI plugins put the pod's ip on
+const cniIfName = "pod0"
+
+// newCNI runs the CNI plugins in binDir, tests replace it with a fake
+var newCNI = func(binDir string) libcni.CNI {
+	return &libcni.CNIConfig{Path: []string{binDir}}
+}
+
+// cniNetwork is the pod network the CNI plugins joined the VM to
+type cniNetwork struct {
+	cni     libcni.CNI
+	list    *libcni.NetworkConfigList
+	runtime *libcni.RuntimeConf
+	ip      string
+	ipv6    string
+}
+
+// SetupCNI runs the CNI plugins of req's config to give the VM its pod ip.  The VM's containers share its network
+// namespace, so that's the one the plugins configure.
+func (m *VMserver) SetupCNI(ctx context.Context, req *common.SetupCNIRequest) (*common.SetupCNIResponse, error) {
+	glog.Infof("SetupCNI: joining sandbox %v to the pod network", req.SandboxId)
+
+	m.cniLock.Lock()
+	defer m.cniLock.Unlock()
+
+	if m.cni != nil {
+		return &common.SetupCNIResponse{Ip: m.cni.ip, Ipv6: m.cni.ipv6}, nil
+	}
+
+	network, err := m.setupCNI(req)
+	if err != nil {
+		return nil, fmt.Errorf("SetupCNI: %v", err)
+	}
+
+	m.cni = network
+	m.cniRequest = req
+	if err := m.saveState(); err != nil {
+		glog.Warningf("SetupCNI: couldn't save state: %v", err)
+	}
+
+	glog.Infof("SetupCNI: %v gave the pod ip %v %v", network.list.Name, network.ip, network.ipv6)
+
+	return &common.SetupCNIResponse{Ip: network.ip, Ipv6: network.ipv6}, nil
+}
+
+func (m *VMserver) setupCNI(req *common.SetupCNIRequest) (*cniNetwork, error) {
+	network, err := m.cniNetwork(req)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := network.cni.AddNetworkList(network.list, network.runtime)
+	if err != nil {
+		return nil, err
+	}
+
+	ip, ipv6, err := cniResultIPs(result)
+	if err != nil {
+		// don't leak what the plugins did allocate
+		if err := network.cni.DelNetworkList(network.list, network.runtime); err != nil {
+			glog.Warningf("setupCNI: couldn't undo %v: %v", network.list.Name, err)
+		}
+		return nil, err
+	}
+	network.ip = ip
+	network.ipv6 = ipv6
+
+	return network, nil
+}
+
+// cniNetwork builds what the plugins are run with from req, which is also all a restarted vmserver needs to tear
+// the network down again
+func (m *VMserver) cniNetwork(req *common.SetupCNIRequest) (*cniNetwork, error) {
+	list, err := common.ParseCNIConfig(req.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	runtime := &libcni.RuntimeConf{
+		ContainerID: req.SandboxId,
+		NetNS:       fmt.Sprintf("/proc/%d/ns/net", os.Getpid()),
+		IfName:      cniIfName,
+		Args:        [][2]string{{"IgnoreUnknown", "1"}, {"K8S_POD_INFRA_CONTAINER_ID", req.SandboxId}},
+	}
+	if m.config != nil && m.config.Metadata != nil {
+		runtime.Args = append(runtime.Args, [2]string{"K8S_POD_NAMESPACE", m.config.Metadata.Namespace}, [2]string{"K8S_POD_NAME", m.config.Metadata.Name})
+	}
+
+	return &cniNetwork{cni: newCNI(req.BinDir), list: list, runtime: runtime}, nil
+}
+
+// cniResultIPs picks the pod's ipv4 address, and its ipv6 one if a dual-stack config gave it one, out of what the
+// last plugin returned
+func cniResultIPs(result types.Result) (string, string, error) {
+	if result == nil {
+		return "", "", fmt.Errorf("CNI plugins returned no result")
+	}
+
+	res, err := current.GetResult(result)
+	if err != nil {
+		return "", "", fmt.Errorf("couldn't parse CNI result: %v", err)
+	}
+
+	ipv4, ipv6 := "", ""
+	for _, ip := range res.IPs {
+		if ip.Address.IP.To4() != nil {
+			if ipv4 == "" {
+				ipv4 = ip.Address.IP.String()
+			}
+		} else if ipv6 == "" && ip.Address.IP.IsGlobalUnicast() {
+			ipv6 = ip.Address.IP.String()
+		}
+	}
+
+	if ipv4 == "" {
+		return "", "", fmt.Errorf("CNI plugins returned no ipv4 address: %v", res)
+	}
+
+	return ipv4, ipv6, nil
+}
+
+// TeardownCNI runs the CNI plugins' DEL, so the pod network gets its ip back before the VM goes away
+func (m *VMserver) TeardownCNI(ctx context.Context, req *common.TeardownCNIRequest) (*common.TeardownCNIResponse, error) {
+	m.cniLock.Lock()
+	defer m.cniLock.Unlock()
+
+	if m.cni == nil {
+		return &common.TeardownCNIResponse{}, nil
+	}
+
+	glog.Infof("TeardownCNI: releasing %v from %v", m.cni.ip, m.cni.list.Name)
+
+	if err := m.cni.cni.DelNetworkList(m.cni.list, m.cni.runtime); err != nil {
+		return nil, fmt.Errorf("TeardownCNI: %v", err)
+	}
+
+	m.cni = nil
+	m.cniRequest = nil
+	if err := m.saveState(); err != nil {
+		glog.Warningf("TeardownCNI: couldn't save state: %v", err)
+	}
+
+	return &common.TeardownCNIResponse{}, nil
+}
+
+// restoreCNI picks up the network a previous vmserver set up, the plugins' work outlives vmserver so they aren't
+// run again
+func (m *VMserver) restoreCNI(req *common.SetupCNIRequest) error {
+	network, err := m.cniNetwork(req)
+	if err != nil {
+		return err
+	}
+	if m.podIp != nil {
+		network.ip = *m.podIp
+	}
+	if m.podIpv6 != nil {
+		network.ipv6 = *m.podIpv6
+	}
+
+	m.cniLock.Lock()
+	defer m.cniLock.Unlock()
+
+	m.cni = network
+	m.cniRequest = req
+
+	return nil
+}
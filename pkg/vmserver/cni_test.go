@@ -0,0 +1,137 @@
+package vmserver
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/types/current"
+	"golang.org/x/net/context"
+
+	"github.com/apporbit/infranetes/pkg/common"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+type fakeCNI struct {
+	result  types.Result
+	added   []*libcni.RuntimeConf
+	deleted []*libcni.RuntimeConf
+}
+
+func (c *fakeCNI) AddNetworkList(net *libcni.NetworkConfigList, rt *libcni.RuntimeConf) (types.Result, error) {
+	c.added = append(c.added, rt)
+	return c.result, nil
+}
+
+func (c *fakeCNI) DelNetworkList(net *libcni.NetworkConfigList, rt *libcni.RuntimeConf) error {
+	c.deleted = append(c.deleted, rt)
+	return nil
+}
+
+func (c *fakeCNI) AddNetwork(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) (types.Result, error) {
+	return c.AddNetworkList(nil, rt)
+}
+
+func (c *fakeCNI) DelNetwork(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) error {
+	return c.DelNetworkList(nil, rt)
+}
+
+func TestSetupCNI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vmserver-cni")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	result, err := current.NewResult([]byte(`{"cniVersion": "0.3.1", "ips": [{"version": "6", "address": "fd00::5/64"}, {"version": "4", "address": "10.244.3.7/24"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeCNI{result: result}
+
+	oldCNI := newCNI
+	defer func() { newCNI = oldCNI }()
+	binDirs := []string{}
+	newCNI = func(binDir string) libcni.CNI {
+		binDirs = append(binDirs, binDir)
+		return fake
+	}
+
+	m := &VMserver{
+		stateDir: dir,
+		config:   &kubeapi.PodSandboxConfig{Metadata: &kubeapi.PodSandboxMetadata{Name: "web", Namespace: "default"}},
+	}
+
+	// a plugin's config is run as a list of one
+	req := &common.SetupCNIRequest{Config: []byte(`{"cniVersion": "0.3.1", "name": "pods", "type": "bridge"}`), BinDir: "/opt/cni/bin", SandboxId: "10.0.0.5"}
+	resp, err := m.SetupCNI(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Ip != "10.244.3.7" || resp.Ipv6 != "fd00::5" {
+		t.Errorf("ips = %v %v, want 10.244.3.7 fd00::5", resp.Ip, resp.Ipv6)
+	}
+
+	if len(fake.added) != 1 || !reflect.DeepEqual(binDirs, []string{"/opt/cni/bin"}) {
+		t.Fatalf("plugins ran %v times from %v", len(fake.added), binDirs)
+	}
+	rt := fake.added[0]
+	if rt.ContainerID != "10.0.0.5" || rt.IfName != cniIfName {
+		t.Errorf("runtime conf = %+v", rt)
+	}
+	for _, arg := range [][2]string{{"K8S_POD_NAMESPACE", "default"}, {"K8S_POD_NAME", "web"}} {
+		found := false
+		for _, got := range rt.Args {
+			found = found || got == arg
+		}
+		if !found {
+			t.Errorf("args %v lack %v", rt.Args, arg)
+		}
+	}
+
+	// setting up again returns the same ip without running the plugins
+	if resp, err := m.SetupCNI(context.Background(), req); err != nil || resp.Ip != "10.244.3.7" || len(fake.added) != 1 {
+		t.Errorf("second SetupCNI = %v, %v after %v adds", resp, err, len(fake.added))
+	}
+
+	// a restarted vmserver can still tear the network down
+	ip := resp.Ip
+	restarted := &VMserver{stateDir: dir, podIp: &ip}
+	state, err := loadState(dir)
+	if err != nil || state.CNI == nil {
+		t.Fatalf("state = %+v, %v", state, err)
+	}
+	if err := restarted.restoreCNI(state.CNI); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := restarted.TeardownCNI(context.Background(), &common.TeardownCNIRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.deleted) != 1 || fake.deleted[0].ContainerID != "10.0.0.5" {
+		t.Errorf("deleted = %+v", fake.deleted)
+	}
+	if restarted.cni != nil || restarted.cniRequest != nil {
+		t.Errorf("network still set after teardown")
+	}
+}
+
+func TestParseCNIConfig(t *testing.T) {
+	list, err := common.ParseCNIConfig([]byte(`{"cniVersion": "0.3.1", "name": "pods", "plugins": [{"type": "calico"}, {"type": "portmap"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Name != "pods" || len(list.Plugins) != 2 || list.Plugins[1].Network.Type != "portmap" {
+		t.Errorf("list = %+v", list)
+	}
+
+	for _, bad := range []string{`not json`, `{"name": "pods"}`, `{"name": "pods", "plugins": []}`} {
+		if _, err := common.ParseCNIConfig([]byte(bad)); err == nil {
+			t.Errorf("ParseCNIConfig(%v) succeeded", bad)
+		}
+	}
+}
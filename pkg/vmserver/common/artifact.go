@@ -0,0 +1,471 @@
+package common
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	digestAlgorithm = "sha256"
+
+	blobsDir   = "blobs"
+	bundlesDir = "bundles"
+
+	// BundleEntrypoint is what a tarball bundle runs when the container doesn't set Command
+	BundleEntrypoint = "entrypoint"
+)
+
+// ArtifactRef is an image reference of the form [https://]host/path[@sha256:<hex>].  Paths ending in .tar, .tar.gz
+// or .tgz are bundles that are unpacked rather than run directly.
+type ArtifactRef struct {
+	URL    string
+	Host   string
+	Digest string
+	Bundle bool
+}
+
+func ParseArtifactRef(image string, allowHTTP bool) (*ArtifactRef, error) {
+	ref := &ArtifactRef{}
+
+	if i := strings.LastIndex(image, "@"); i != -1 {
+		ref.Digest = image[i+1:]
+		image = image[:i]
+
+		if err := validateDigest(ref.Digest); err != nil {
+			return nil, err
+		}
+	}
+
+	if !strings.Contains(image, "://") {
+		image = "https://" + image
+	}
+
+	u, err := url.Parse(image)
+	if err != nil {
+		return nil, fmt.Errorf("ParseArtifactRef: invalid reference %q: %v", image, err)
+	}
+
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if !allowHTTP {
+			return nil, fmt.Errorf("ParseArtifactRef: refusing to fetch %v over plain http", image)
+		}
+	default:
+		return nil, fmt.Errorf("ParseArtifactRef: unsupported scheme %q in %v", u.Scheme, image)
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("ParseArtifactRef: no host in %v", image)
+	}
+
+	ref.URL = u.String()
+	ref.Host = u.Host
+	ref.Bundle = strings.HasSuffix(u.Path, ".tar") || strings.HasSuffix(u.Path, ".tar.gz") || strings.HasSuffix(u.Path, ".tgz")
+
+	return ref, nil
+}
+
+func validateDigest(digest string) error {
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 || parts[0] != digestAlgorithm {
+		return fmt.Errorf("unsupported digest %q, want %v:<hex>", digest, digestAlgorithm)
+	}
+
+	if b, err := hex.DecodeString(parts[1]); err != nil || len(b) != sha256.Size {
+		return fmt.Errorf("malformed digest %q", digest)
+	}
+
+	return nil
+}
+
+// ArtifactCredential authenticates fetches from a host, either with a bearer token or basic auth
+type ArtifactCredential struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Token    string `json:"token"`
+}
+
+// LoadArtifactCredentials reads a json object mapping hosts to their ArtifactCredential
+func LoadArtifactCredentials(path string) (map[string]ArtifactCredential, error) {
+	ret := make(map[string]ArtifactCredential)
+	if path == "" {
+		return ret, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("LoadArtifactCredentials: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &ret); err != nil {
+		return nil, fmt.Errorf("LoadArtifactCredentials: couldn't parse %v: %v", path, err)
+	}
+
+	return ret, nil
+}
+
+// Artifact is a fetched binary or unpacked bundle
+type Artifact struct {
+	Digest string
+	// Path is the executable for binaries and the unpacked directory for bundles
+	Path   string
+	Bundle bool
+}
+
+// ArtifactStore is a content-addressed cache of fetched artifacts.  Blobs are kept under blobs/sha256/<hex> and
+// bundles are unpacked into bundles/<hex>.
+type ArtifactStore struct {
+	dir         string
+	allowHTTP   bool
+	credentials map[string]ArtifactCredential
+	client      *http.Client
+
+	// held while fetching and collecting so GC can't remove an artifact between Fetch and its use being recorded
+	lock sync.Mutex
+}
+
+func NewArtifactStore(dir string, credentialsPath string, allowHTTP bool) (*ArtifactStore, error) {
+	credentials, err := LoadArtifactCredentials(credentialsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sub := range []string{filepath.Join(blobsDir, digestAlgorithm), bundlesDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, fmt.Errorf("NewArtifactStore: %v", err)
+		}
+	}
+
+	store := &ArtifactStore{
+		dir:         dir,
+		allowHTTP:   allowHTTP,
+		credentials: credentials,
+		client:      &http.Client{Timeout: 10 * time.Minute},
+	}
+
+	return store, nil
+}
+
+// Credential returns the credential configured for host
+func (s *ArtifactStore) Credential(host string) (ArtifactCredential, bool) {
+	cred, ok := s.credentials[host]
+	return cred, ok
+}
+
+func (s *ArtifactStore) blobPath(digest string) string {
+	return filepath.Join(s.dir, blobsDir, digestAlgorithm, strings.TrimPrefix(digest, digestAlgorithm+":"))
+}
+
+func (s *ArtifactStore) bundlePath(digest string) string {
+	return filepath.Join(s.dir, bundlesDir, strings.TrimPrefix(digest, digestAlgorithm+":"))
+}
+
+// Fetch returns the artifact image refers to, downloading it unless a reference with a digest is already cached
+func (s *ArtifactStore) Fetch(image string) (*Artifact, error) {
+	ref, err := ParseArtifactRef(image, s.allowHTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	digest := ref.Digest
+	if digest == "" || !exists(s.blobPath(digest)) {
+		if digest, err = s.download(ref); err != nil {
+			return nil, err
+		}
+	} else {
+		glog.Infof("Fetch: %v is cached", image)
+	}
+
+	artifact := &Artifact{Digest: digest, Path: s.blobPath(digest), Bundle: ref.Bundle}
+
+	if ref.Bundle {
+		if artifact.Path, err = s.unpack(digest); err != nil {
+			return nil, err
+		}
+	}
+
+	s.touch(digest)
+
+	return artifact, nil
+}
+
+func (s *ArtifactStore) download(ref *ArtifactRef) (string, error) {
+	glog.Infof("Fetch: downloading %v", ref.URL)
+
+	req, err := http.NewRequest("GET", ref.URL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	if cred, ok := s.credentials[ref.Host]; ok {
+		if cred.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+cred.Token)
+		} else {
+			req.SetBasicAuth(cred.Username, cred.Password)
+		}
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Fetch: GET %v failed: %v", ref.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Fetch: GET %v returned %v", ref.URL, resp.Status)
+	}
+
+	digest, err := s.store(resp.Body, ref.Digest)
+	if err != nil {
+		return "", fmt.Errorf("Fetch: %v: %v", ref.URL, err)
+	}
+
+	glog.Infof("Fetch: stored %v as %v", ref.URL, digest)
+
+	return digest, nil
+}
+
+// Put caches the blob fetch returns unless digest is already cached, returning the blob's path.  It is for callers,
+// such as registry clients, that have their own way of fetching content whose digest they know.
+func (s *ArtifactStore) Put(digest string, fetch func() (io.ReadCloser, error)) (string, error) {
+	if err := validateDigest(digest); err != nil {
+		return "", err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !exists(s.blobPath(digest)) {
+		body, err := fetch()
+		if err != nil {
+			return "", err
+		}
+		defer body.Close()
+
+		if _, err := s.store(body, digest); err != nil {
+			return "", err
+		}
+	}
+
+	s.touch(digest)
+
+	return s.blobPath(digest), nil
+}
+
+// store writes body to the cache, failing if it doesn't match want when want is set
+func (s *ArtifactStore) store(body io.Reader, want string) (string, error) {
+	tmp, err := ioutil.TempFile(filepath.Join(s.dir, blobsDir), "download-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), body)
+	tmp.Close()
+	if err != nil {
+		return "", fmt.Errorf("read failed: %v", err)
+	}
+
+	digest := digestAlgorithm + ":" + hex.EncodeToString(h.Sum(nil))
+	if want != "" && want != digest {
+		return "", fmt.Errorf("digest mismatch: want %v, got %v", want, digest)
+	}
+
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(tmp.Name(), s.blobPath(digest)); err != nil {
+		return "", err
+	}
+
+	return digest, nil
+}
+
+func (s *ArtifactStore) unpack(digest string) (string, error) {
+	dest := s.bundlePath(digest)
+	if exists(dest) {
+		return dest, nil
+	}
+
+	tmp, err := ioutil.TempDir(filepath.Join(s.dir, bundlesDir), "unpack-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := untar(s.blobPath(digest), tmp); err != nil {
+		return "", fmt.Errorf("Fetch: couldn't unpack %v: %v", digest, err)
+	}
+
+	if err := os.Rename(tmp, dest); err != nil {
+		return "", err
+	}
+
+	return dest, nil
+}
+
+func untar(path string, dest string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var reader io.Reader = f
+	if gz, err := gzip.NewReader(f); err == nil {
+		defer gz.Close()
+		reader = gz
+	} else if _, err := f.Seek(0, os.SEEK_SET); err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(reader)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			// a link can lead outside through links unpacked after it, so they're checked once they're all there
+			return checkLinks(dest)
+		}
+		if err != nil {
+			return err
+		}
+
+		name := filepath.Clean(hdr.Name)
+		if escapes(name) {
+			return fmt.Errorf("entry %q escapes the bundle", hdr.Name)
+		}
+
+		// the entry goes where its path leads through the links unpacked before it, kept inside the bundle
+		dir, err := SecureJoin(dest, filepath.Dir(name))
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, filepath.Base(name))
+		// a link unpacked earlier at the entry's own path is replaced rather than followed
+		if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			if err := os.Remove(target); err != nil {
+				return err
+			}
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode).Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(out, tr)
+			out.Close()
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if filepath.IsAbs(hdr.Linkname) || escapes(filepath.Join(filepath.Dir(name), hdr.Linkname)) {
+				return fmt.Errorf("symlink %q points outside the bundle", hdr.Name)
+			}
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		default:
+			glog.Warningf("untar: skipping %v of unsupported type %c", hdr.Name, hdr.Typeflag)
+		}
+	}
+}
+
+// checkLinks fails if any of the symlinks under dest leads outside of it when followed
+func checkLinks(dest string) error {
+	return filepath.Walk(dest, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			return err
+		}
+
+		name, err := filepath.Rel(dest, path)
+		if err != nil {
+			return err
+		}
+		if _, err := ResolveWithin(dest, name); err != nil {
+			return fmt.Errorf("symlink %q points outside the bundle: %v", name, err)
+		}
+
+		return nil
+	})
+}
+
+// escapes says whether name, relative to a bundle's directory, is outside of it
+func escapes(name string) bool {
+	name = filepath.Clean(name)
+	return filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../")
+}
+
+// touch marks digest as used so GC keeps it for another ttl
+func (s *ArtifactStore) touch(digest string) {
+	now := time.Now()
+	for _, path := range []string{s.blobPath(digest), s.bundlePath(digest)} {
+		if exists(path) {
+			os.Chtimes(path, now, now)
+		}
+	}
+}
+
+// GC removes artifacts that aren't in inUse and haven't been fetched for ttl
+func (s *ArtifactStore) GC(inUse map[string]bool, ttl time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	blobs, err := ioutil.ReadDir(filepath.Join(s.dir, blobsDir, digestAlgorithm))
+	if err != nil {
+		glog.Warningf("GC: couldn't list artifacts: %v", err)
+		return
+	}
+
+	for _, blob := range blobs {
+		digest := digestAlgorithm + ":" + blob.Name()
+		if inUse[digest] || time.Since(blob.ModTime()) < ttl {
+			continue
+		}
+
+		glog.Infof("GC: removing unused artifact %v", digest)
+		if err := os.RemoveAll(s.bundlePath(digest)); err != nil {
+			glog.Warningf("GC: couldn't remove bundle %v: %v", digest, err)
+		}
+		if err := os.Remove(s.blobPath(digest)); err != nil {
+			glog.Warningf("GC: couldn't remove %v: %v", digest, err)
+		}
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,192 @@
+package common
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testEntry is an entry of a tarball built by testTar, a regular file unless link is set
+type testEntry struct {
+	name     string
+	contents string
+	link     string
+	dir      bool
+}
+
+func testTar(t *testing.T, entries []testEntry) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for _, entry := range entries {
+		hdr := &tar.Header{Name: entry.name, Mode: 0755, Size: int64(len(entry.contents)), Typeflag: tar.TypeReg}
+		switch {
+		case entry.dir:
+			hdr.Typeflag = tar.TypeDir
+		case entry.link != "":
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = entry.link
+			hdr.Size = 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(entry.contents)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func testDigest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return digestAlgorithm + ":" + hex.EncodeToString(sum[:])
+}
+
+// testStore returns a store in a new directory, fetching from a server that serves files and counts its requests
+func testStore(t *testing.T, files map[string][]byte) (*ArtifactStore, string, *int, func()) {
+	dir, err := ioutil.TempDir("", "artifacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+
+	store, err := NewArtifactStore(dir, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store, server.URL, &requests, func() {
+		server.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestFetchBundle(t *testing.T) {
+	bundle := testTar(t, []testEntry{
+		{name: "bin/", dir: true},
+		{name: "bin/app", contents: "#!/bin/sh\n"},
+		{name: "entrypoint", link: "bin/app"},
+		{name: "bin/self", link: "../bin/app"},
+	})
+	store, url, requests, cleanup := testStore(t, map[string][]byte{"/app.tar.gz": bundle})
+	defer cleanup()
+
+	image := url + "/app.tar.gz@" + testDigest(bundle)
+	artifact, err := store.Fetch(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !artifact.Bundle || artifact.Digest != testDigest(bundle) {
+		t.Errorf("fetched %+v", artifact)
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(artifact.Path, BundleEntrypoint)); err != nil || string(data) != "#!/bin/sh\n" {
+		t.Errorf("entrypoint is %q: %v", data, err)
+	}
+
+	// a reference with a digest that's cached isn't downloaded again
+	if _, err := store.Fetch(image); err != nil {
+		t.Fatal(err)
+	}
+	if *requests != 1 {
+		t.Errorf("fetched %v times", *requests)
+	}
+}
+
+func TestFetchDigestMismatch(t *testing.T) {
+	store, url, _, cleanup := testStore(t, map[string][]byte{"/app": []byte("tampered")})
+	defer cleanup()
+
+	want := testDigest([]byte("app"))
+	if artifact, err := store.Fetch(url + "/app@" + want); err == nil || !strings.Contains(err.Error(), "digest mismatch") {
+		t.Fatalf("fetched %+v: %v", artifact, err)
+	}
+
+	// nothing is left behind for a later fetch to find
+	if exists(store.blobPath(want)) || exists(store.blobPath(testDigest([]byte("tampered")))) {
+		t.Errorf("mismatched download was cached")
+	}
+	leftovers, err := filepath.Glob(filepath.Join(store.dir, blobsDir, "download-*"))
+	if err != nil || len(leftovers) != 0 {
+		t.Errorf("left %v behind: %v", leftovers, err)
+	}
+}
+
+func TestUntarEscapes(t *testing.T) {
+	for name, entries := range map[string][]testEntry{
+		"parent":            {{name: "../app", contents: "x"}},
+		"nested parent":     {{name: "bin/../../app", contents: "x"}},
+		"absolute":          {{name: "/etc/cron.d/app", contents: "x"}},
+		"symlink parent":    {{name: "bin/app", link: "../../etc/passwd"}},
+		"symlink absolute":  {{name: "app", link: "/etc/passwd"}},
+		"symlink to parent": {{name: "up", link: ".."}},
+		// each link is inside on its own, b only leads out through a
+		"chained symlinks":   {{name: "a", link: "."}, {name: "b", link: "a/.."}, {name: "b/app", contents: "x"}},
+		"symlink made later": {{name: "b", link: "a/.."}, {name: "a", link: "."}},
+	} {
+		bundle := testTar(t, entries)
+		store, url, _, cleanup := testStore(t, map[string][]byte{"/app.tgz": bundle})
+
+		if artifact, err := store.Fetch(url + "/app.tgz"); err == nil {
+			t.Errorf("%v: unpacked %+v", name, artifact)
+		}
+		if exists(store.bundlePath(testDigest(bundle))) {
+			t.Errorf("%v: bundle was kept", name)
+		}
+		if unpacking, _ := filepath.Glob(filepath.Join(store.dir, bundlesDir, "unpack-*")); len(unpacking) != 0 {
+			t.Errorf("%v: left %v behind", name, unpacking)
+		}
+		if exists(filepath.Join(store.dir, bundlesDir, "app")) || exists(filepath.Join(store.dir, "app")) {
+			t.Errorf("%v: wrote outside the bundle", name)
+		}
+
+		cleanup()
+	}
+}
+
+func TestEscapes(t *testing.T) {
+	for name, want := range map[string]bool{
+		"app":          false,
+		"bin/../app":   false,
+		"..app":        false,
+		"./bin/app":    false,
+		"..":           true,
+		"../app":       true,
+		"bin/../../up": true,
+		"/app":         true,
+	} {
+		if got := escapes(name); got != want {
+			t.Errorf("escapes(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
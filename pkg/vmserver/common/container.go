@@ -16,6 +16,8 @@ type Container struct {
 	createdAt   int64
 	startedAt   int64
 	finishedAt  int64
+	exitCode    int32
+	reason      string
 	labels      map[string]string
 	annotations map[string]string
 }
@@ -52,6 +54,43 @@ func (c *Container) Finished() {
 	c.state = kubeapi.ContainerState_CONTAINER_EXITED
 }
 
+// UpdateState is for providers that read the container's state from their runtime rather than tracking it
+func (c *Container) UpdateState(state kubeapi.ContainerState, startedAt int64, finishedAt int64, exitCode int32, reason string) {
+	c.state = state
+	c.startedAt = startedAt
+	c.finishedAt = finishedAt
+	c.exitCode = exitCode
+	c.reason = reason
+}
+
+func (c *Container) SetCreatedAt(createdAt int64) {
+	c.createdAt = createdAt
+}
+
+func (c *Container) GetCreatedAt() int64 {
+	return c.createdAt
+}
+
+func (c *Container) GetStartedAt() int64 {
+	return c.startedAt
+}
+
+func (c *Container) GetMetadata() *kubeapi.ContainerMetadata {
+	return c.metadata
+}
+
+func (c *Container) GetImage() *kubeapi.ImageSpec {
+	return c.image
+}
+
+func (c *Container) GetMounts() []*kubeapi.Mount {
+	return c.mounts
+}
+
+func (c *Container) GetAnnotations() map[string]string {
+	return c.annotations
+}
+
 func (c *Container) GetId() *string {
 	return c.id
 }
@@ -85,13 +124,14 @@ func (c *Container) ToKubeContainer() *kubeapi.Container {
 }
 
 func (c *Container) ToKubeStatus() *kubeapi.ContainerStatus {
-	exitCode := int32(0)
-	var reason string
+	exitCode := c.exitCode
+	reason := c.reason
 	mounts := c.mounts
 
 	if c.state == kubeapi.ContainerState_CONTAINER_EXITED {
-		tmp := "Stopped"
-		reason = tmp
+		if reason == "" {
+			reason = "Stopped"
+		}
 		mounts = nil
 	}
 
@@ -0,0 +1,93 @@
+package common
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	icommon "github.com/apporbit/infranetes/pkg/common"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+// subscribers that fall this far behind are dropped, so they resync when they reconnect
+const eventBufferSize = 64
+
+// EventBus fans container lifecycle events out to every WatchContainerEvents stream
+type EventBus struct {
+	lock        sync.Mutex
+	nextId      int
+	subscribers map[int]chan *icommon.ContainerEvent
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: make(map[int]chan *icommon.ContainerEvent),
+	}
+}
+
+// Subscribe returns a channel of events and a function that must be called to stop receiving them.  The channel is
+// closed if the subscriber falls behind, as it has missed events then.
+func (b *EventBus) Subscribe() (<-chan *icommon.ContainerEvent, func()) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	id := b.nextId
+	b.nextId++
+
+	ch := make(chan *icommon.ContainerEvent, eventBufferSize)
+	b.subscribers[id] = ch
+
+	cancel := func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+
+		if ch, ok := b.subscribers[id]; ok {
+			delete(b.subscribers, id)
+			close(ch)
+		}
+	}
+
+	return ch, cancel
+}
+
+func (b *EventBus) Publish(event *icommon.ContainerEvent) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for id, ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			glog.Warningf("EventBus.Publish: subscriber %v is full, dropping it", id)
+			delete(b.subscribers, id)
+			close(ch)
+		}
+	}
+}
+
+// PublishStatus is a convenience wrapper for providers that track kubeapi.ContainerStatus themselves
+func (b *EventBus) PublishStatus(id string, eventType icommon.ContainerEventType, status *kubeapi.ContainerStatus) {
+	if b == nil {
+		return
+	}
+
+	event := &icommon.ContainerEvent{
+		ContainerID: id,
+		Type:        eventType,
+		Timestamp:   time.Now().UnixNano(),
+	}
+
+	if status != nil {
+		data, err := json.Marshal(status)
+		if err != nil {
+			glog.Warningf("PublishStatus: couldn't marshal status for %v: %v", id, err)
+		} else {
+			event.Status = data
+		}
+	}
+
+	b.Publish(event)
+}
@@ -0,0 +1,40 @@
+package common
+
+import (
+	"testing"
+
+	icommon "github.com/apporbit/infranetes/pkg/common"
+)
+
+func TestEventBusDropsLaggingSubscriber(t *testing.T) {
+	b := NewEventBus()
+
+	lagging, cancelLagging := b.Subscribe()
+	defer cancelLagging()
+	reading, cancelReading := b.Subscribe()
+	defer cancelReading()
+
+	for i := 0; i <= eventBufferSize; i++ {
+		b.Publish(&icommon.ContainerEvent{ContainerID: "c"})
+		if event, ok := <-reading; !ok || event.ContainerID != "c" {
+			t.Fatalf("reading subscriber got %v, %v", event, ok)
+		}
+	}
+
+	// the lagging subscriber gets what fit in its buffer, and then its channel is closed
+	received := 0
+	for range lagging {
+		received++
+	}
+	if received != eventBufferSize {
+		t.Errorf("lagging subscriber received %v events, want %v", received, eventBufferSize)
+	}
+
+	// the closed subscriber's cancel is still safe
+	cancelLagging()
+
+	b.Publish(&icommon.ContainerEvent{ContainerID: "d"})
+	if event := <-reading; event.ContainerID != "d" {
+		t.Errorf("reading subscriber got %v after the lagging one was dropped", event)
+	}
+}
@@ -0,0 +1,609 @@
+package common
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+
+	icommon "github.com/apporbit/infranetes/pkg/common"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+const (
+	// LogSourcesAnnotation selects the guest logs streamed as a VM pod's container log, e.g.
+	// "journald:kubelet.service,file:/var/log/cloud-init-output.log,serial"
+	LogSourcesAnnotation = "infranetes.logsources"
+	// DefaultLogSources is used when neither the container nor its sandbox set LogSourcesAnnotation
+	DefaultLogSources = "journald"
+
+	LogSourceJournald = "journald"
+	LogSourceFile     = "file"
+	LogSourceSerial   = "serial"
+
+	// maxLogPositions is how many of the latest positions of a source are kept
+	maxLogPositions = 1024
+	// followInterval is how often a followed file is checked for more lines
+	followInterval = 250 * time.Millisecond
+)
+
+var (
+	// kmsgPath is the kernel's ring buffer, i.e. what the VM writes to its serial console
+	kmsgPath = "/dev/kmsg"
+	// procStat has when the VM booted, which kernel records are stamped from
+	procStat = "/proc/stat"
+)
+
+// guestLogDir keeps how far file sources were streamed, so streams resumed at an offset carry on where they left off,
+// also after vmserver restarts.  Nothing is kept when it's "".
+var guestLogDir string
+
+// SetGuestLogDir keeps the state of the guest logs in dir
+func SetGuestLogDir(dir string) {
+	guestLogDir = dir
+}
+
+// LogSource is a single guest log.  Offsets for guest logs are unix nanosecond timestamps, journald and the serial
+// console resume after the timestamp, files after the line sent at it when guestLogDir kept where that was and from
+// their current end otherwise.
+type LogSource struct {
+	Kind   string
+	Target string
+}
+
+func (s LogSource) String() string {
+	if s.Target == "" {
+		return s.Kind
+	}
+
+	return s.Kind + ":" + s.Target
+}
+
+// LogSourcesSpec returns the log source annotation for a container, falling back to its sandbox's annotation
+func LogSourcesSpec(req *kubeapi.CreateContainerRequest) string {
+	if spec, ok := req.GetConfig().GetAnnotations()[LogSourcesAnnotation]; ok {
+		return spec
+	}
+
+	if spec, ok := req.GetSandboxConfig().GetAnnotations()[LogSourcesAnnotation]; ok {
+		return spec
+	}
+
+	return DefaultLogSources
+}
+
+func ParseLogSources(spec string) ([]LogSource, error) {
+	ret := []LogSource{}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		source := LogSource{Kind: entry}
+		if i := strings.Index(entry, ":"); i != -1 {
+			source.Kind = entry[:i]
+			source.Target = entry[i+1:]
+		}
+
+		switch source.Kind {
+		case LogSourceJournald:
+		case LogSourceFile:
+			if !strings.HasPrefix(source.Target, "/") {
+				return nil, fmt.Errorf("ParseLogSources: file source needs an absolute path, got %q", source.Target)
+			}
+		case LogSourceSerial:
+			if source.Target != "" {
+				return nil, fmt.Errorf("ParseLogSources: serial source is the kernel's console, got %q", source.Target)
+			}
+		default:
+			return nil, fmt.Errorf("ParseLogSources: unknown log source %q", source.Kind)
+		}
+
+		ret = append(ret, source)
+	}
+
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("ParseLogSources: no log sources in %q", spec)
+	}
+
+	return ret, nil
+}
+
+// StreamGuestLogs merges sources into send until ctx is done or a source fails
+func StreamGuestLogs(ctx context.Context, sources []LogSource, offset int64, send func(*icommon.LogLine) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lines := make(chan *icommon.LogLine)
+	errs := make(chan error, len(sources))
+
+	for _, source := range sources {
+		go func(source LogSource) {
+			err := source.stream(ctx, offset, lines)
+			if err != nil {
+				err = fmt.Errorf("%v: %v", source, err)
+			}
+			errs <- err
+		}(source)
+	}
+
+	for running := len(sources); running > 0; {
+		select {
+		case line := <-lines:
+			if err := send(line); err != nil {
+				return err
+			}
+		case err := <-errs:
+			running--
+			if err != nil {
+				glog.Warningf("StreamGuestLogs: %v", err)
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (s LogSource) stream(ctx context.Context, offset int64, lines chan<- *icommon.LogLine) error {
+	switch s.Kind {
+	case LogSourceJournald:
+		return streamJournald(ctx, s.Target, offset, lines)
+	case LogSourceFile:
+		return streamFile(ctx, s, s.Target, offset, lines)
+	case LogSourceSerial:
+		return streamSerial(ctx, offset, lines)
+	}
+
+	return fmt.Errorf("unknown log source")
+}
+
+func sendLine(ctx context.Context, lines chan<- *icommon.LogLine, line *icommon.LogLine) bool {
+	line.Offset = line.Timestamp
+
+	select {
+	case lines <- line:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// journalEntry is the part of journalctl's json output we use
+type journalEntry struct {
+	Message    json.RawMessage `json:"MESSAGE"`
+	Realtime   string          `json:"__REALTIME_TIMESTAMP"`
+	Priority   string          `json:"PRIORITY"`
+	Identifier string          `json:"SYSLOG_IDENTIFIER"`
+}
+
+func (e *journalEntry) message() string {
+	var text string
+	if err := json.Unmarshal(e.Message, &text); err == nil {
+		return text
+	}
+
+	// journald encodes messages that aren't valid utf8 as an array of bytes
+	var raw []byte
+	if err := json.Unmarshal(e.Message, &raw); err == nil {
+		return string(raw)
+	}
+
+	return string(e.Message)
+}
+
+func streamJournald(ctx context.Context, unit string, offset int64, lines chan<- *icommon.LogLine) error {
+	args := []string{"--follow", "--output=json", "--no-pager"}
+	if offset > 0 {
+		args = append(args, fmt.Sprintf("--since=@%d", offset/int64(time.Second)))
+	} else {
+		args = append(args, "--boot", "--no-tail")
+	}
+	if unit != "" {
+		args = append(args, "--unit="+unit)
+	}
+
+	cmd := exec.Command("journalctl", args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		cmd.Process.Kill()
+	}()
+	defer cmd.Wait()
+
+	decoder := json.NewDecoder(stdout)
+	for {
+		var entry journalEntry
+		if err := decoder.Decode(&entry); err != nil {
+			if ctx.Err() != nil || err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		usec, err := strconv.ParseInt(entry.Realtime, 10, 64)
+		if err != nil {
+			continue
+		}
+		ts := usec * int64(time.Microsecond)
+		if ts <= offset {
+			continue
+		}
+
+		stream := "stdout"
+		if pri, err := strconv.Atoi(entry.Priority); err == nil && pri <= 3 {
+			stream = "stderr"
+		}
+
+		// a single unit's journal is the container's own output, only label lines when merging the whole journal
+		text := entry.message()
+		if unit == "" && entry.Identifier != "" {
+			text = entry.Identifier + ": " + text
+		}
+
+		if !sendLine(ctx, lines, &icommon.LogLine{LogLine: text, Timestamp: ts, Stream: stream}) {
+			return nil
+		}
+	}
+}
+
+// logPosition is where a file was read to after the line sent at Offset
+type logPosition struct {
+	Offset   int64
+	Position int64
+	// Inode tells the file the position is in from one that replaced it since
+	Inode uint64
+}
+
+// logPositions are the latest positions a source was streamed to, oldest first, saved in guestLogDir
+type logPositions struct {
+	path string
+
+	lock      sync.Mutex
+	positions []logPosition
+	saved     time.Time
+}
+
+var (
+	positionsLock sync.Mutex
+	// the positions of each source streamed since vmserver started, by source
+	sourcePositions = make(map[string]*logPositions)
+)
+
+// loadPositions returns the positions kept for source, nil when guestLogDir isn't set
+func loadPositions(source LogSource) *logPositions {
+	if guestLogDir == "" {
+		return nil
+	}
+
+	positionsLock.Lock()
+	defer positionsLock.Unlock()
+
+	if p, ok := sourcePositions[source.String()]; ok {
+		return p
+	}
+
+	p := &logPositions{
+		path: filepath.Join(guestLogDir, "positions", fmt.Sprintf("%x.json", sha1.Sum([]byte(source.String())))),
+	}
+	if data, err := ioutil.ReadFile(p.path); err == nil {
+		if err := json.Unmarshal(data, &p.positions); err != nil {
+			glog.Warningf("loadPositions: ignoring the positions of %v: %v", source, err)
+		}
+	} else if !os.IsNotExist(err) {
+		glog.Warningf("loadPositions: ignoring the positions of %v: %v", source, err)
+	}
+	sourcePositions[source.String()] = p
+
+	return p
+}
+
+// resume returns the position after the last line sent at or before offset, false if none was kept
+func (p *logPositions) resume(offset int64) (logPosition, bool) {
+	if p == nil {
+		return logPosition{}, false
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for i := len(p.positions) - 1; i >= 0; i-- {
+		if p.positions[i].Offset <= offset {
+			return p.positions[i], true
+		}
+	}
+
+	return logPosition{}, false
+}
+
+// record keeps the position after a line that was sent, saving the positions at most once a second.  Those recorded
+// since they were saved are lost with vmserver, which sends their lines again.
+func (p *logPositions) record(pos logPosition) {
+	if p == nil {
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.positions = append(p.positions, pos)
+	if len(p.positions) > maxLogPositions {
+		p.positions = append([]logPosition{}, p.positions[len(p.positions)-maxLogPositions:]...)
+	}
+
+	if time.Since(p.saved) >= time.Second {
+		p.saveLocked()
+	}
+}
+
+func (p *logPositions) save() {
+	if p == nil {
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.saveLocked()
+}
+
+func (p *logPositions) saveLocked() {
+	p.saved = time.Now()
+
+	data, err := json.Marshal(p.positions)
+	if err != nil {
+		glog.Warningf("saveLocked: %v", err)
+		return
+	}
+
+	// written aside first, so a crash doesn't leave half the positions
+	if err := os.MkdirAll(filepath.Dir(p.path), 0700); err != nil {
+		glog.Warningf("saveLocked: %v", err)
+		return
+	}
+	tmp := p.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		glog.Warningf("saveLocked: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, p.path); err != nil {
+		glog.Warningf("saveLocked: %v", err)
+	}
+}
+
+// streamFile streams the lines of path for source, after the one sent at offset
+func streamFile(ctx context.Context, source LogSource, path string, offset int64, lines chan<- *icommon.LogLine) error {
+	positions := loadPositions(source)
+	defer positions.save()
+
+	start, ok := positions.resume(offset)
+	if offset > 0 && !ok {
+		// where the stream was isn't known, so the lines since are lost rather than sent twice
+		start.Position = -1
+	}
+
+	return followFile(ctx, path, start, positions, lines)
+}
+
+// inode returns the inode of the file info describes
+func inode(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino)
+	}
+
+	return 0
+}
+
+// openFollowed opens path, waiting for it to be created
+func openFollowed(ctx context.Context, path string) (*os.File, os.FileInfo, error) {
+	for {
+		f, err := os.Open(path)
+		if err == nil {
+			info, err := f.Stat()
+			if err != nil {
+				f.Close()
+				return nil, nil, err
+			}
+			return f, info, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, nil, err
+		}
+
+		select {
+		case <-time.After(followInterval):
+		case <-ctx.Done():
+			return nil, nil, nil
+		}
+	}
+}
+
+// followFile sends the lines of path from start, or from its end if start.Position is -1, following it as it grows,
+// is truncated or is replaced by a new file, and records the position after each line in positions.  A start in a
+// file path no longer is starts at the beginning of the current one.
+func followFile(ctx context.Context, path string, start logPosition, positions *logPositions, lines chan<- *icommon.LogLine) error {
+	f, info, err := openFollowed(ctx, path)
+	if err != nil || f == nil {
+		return err
+	}
+	defer func() {
+		f.Close()
+	}()
+
+	ino := inode(info)
+	pos := int64(0)
+	switch {
+	case start.Position == -1:
+		pos = info.Size()
+	case start.Inode == ino && start.Position <= info.Size():
+		pos = start.Position
+	}
+	if _, err := f.Seek(pos, os.SEEK_SET); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(f)
+	partial := ""
+	replaced := false
+	for {
+		chunk, err := reader.ReadString('\n')
+		pos += int64(len(chunk))
+		if err == nil {
+			line := &icommon.LogLine{LogLine: strings.TrimSuffix(partial+chunk, "\n"), Timestamp: time.Now().UnixNano(), Stream: "stdout"}
+			partial = ""
+			if !sendLine(ctx, lines, line) {
+				return nil
+			}
+			positions.record(logPosition{Offset: line.Offset, Position: pos, Inode: ino})
+			continue
+		}
+		if err != io.EOF {
+			return err
+		}
+		partial += chunk
+
+		// the rest of a file that was replaced is read before moving on to the new one
+		if replaced {
+			next, nextInfo, err := openFollowed(ctx, path)
+			if err != nil || next == nil {
+				return err
+			}
+			f.Close()
+			f, ino, pos, partial, replaced = next, inode(nextInfo), 0, "", false
+			reader.Reset(f)
+			continue
+		}
+
+		select {
+		case <-time.After(followInterval):
+		case <-ctx.Done():
+			return nil
+		}
+
+		current, err := os.Stat(path)
+		switch {
+		case err != nil && !os.IsNotExist(err):
+			return err
+		case err != nil || inode(current) != ino:
+			replaced = true
+		case current.Size() < pos:
+			glog.Infof("followFile: %v was truncated, following it from the start", path)
+			if _, err := f.Seek(0, os.SEEK_SET); err != nil {
+				return err
+			}
+			pos, partial = 0, ""
+			reader.Reset(f)
+		}
+	}
+}
+
+// streamSerial streams what the kernel writes to the VM's console, the records in its ring buffer.  They are stamped
+// with the time since boot, which makes them resume after offset like journald does.
+func streamSerial(ctx context.Context, offset int64, lines chan<- *icommon.LogLine) error {
+	boot, err := bootTime()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(kmsgPath)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+
+	// every read of /dev/kmsg returns a single "prio,seq,usec,flags;message" record
+	reader := bufio.NewReader(f)
+	for {
+		record, err := reader.ReadString('\n')
+		if pe, ok := err.(*os.PathError); ok && pe.Err == syscall.EPIPE {
+			// we fell behind and the kernel overwrote records, carry on from the oldest remaining one
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil || err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		// continuation lines of a record start with a space and carry key=value metadata
+		if strings.HasPrefix(record, " ") {
+			continue
+		}
+
+		i := strings.Index(record, ";")
+		if i == -1 {
+			continue
+		}
+		fields := strings.Split(record[:i], ",")
+		if len(fields) < 3 {
+			continue
+		}
+		usec, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		ts := boot + usec*int64(time.Microsecond)
+		if ts <= offset {
+			continue
+		}
+
+		line := &icommon.LogLine{
+			LogLine:   "kernel: " + strings.TrimSuffix(record[i+1:], "\n"),
+			Timestamp: ts,
+			Stream:    "stdout",
+		}
+		if !sendLine(ctx, lines, line) {
+			return nil
+		}
+	}
+}
+
+// bootTime returns when the VM booted, in unix nanoseconds
+func bootTime() (int64, error) {
+	data, err := ioutil.ReadFile(procStat)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "btime" {
+			sec, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			return sec * int64(time.Second), nil
+		}
+	}
+
+	return 0, fmt.Errorf("no boot time in %v", procStat)
+}
@@ -0,0 +1,200 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	icommon "github.com/apporbit/infranetes/pkg/common"
+)
+
+func TestParseLogSources(t *testing.T) {
+	for spec, want := range map[string][]LogSource{
+		"journald":                         {{Kind: LogSourceJournald}},
+		"journald:kubelet.service, serial": {{Kind: LogSourceJournald, Target: "kubelet.service"}, {Kind: LogSourceSerial}},
+		"file:/var/log/a.log,,":            {{Kind: LogSourceFile, Target: "/var/log/a.log"}},
+		"file:/var/log/a:b.log":            {{Kind: LogSourceFile, Target: "/var/log/a:b.log"}},
+	} {
+		sources, err := ParseLogSources(spec)
+		if err != nil {
+			t.Errorf("%q: %v", spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(sources, want) {
+			t.Errorf("%q: got %v, want %v", spec, sources, want)
+		}
+	}
+
+	for _, spec := range []string{"", " , ", "file", "file:var/log/a.log", "serial:/dev/ttyS1", "syslog"} {
+		if sources, err := ParseLogSources(spec); err == nil {
+			t.Errorf("%q: got %v", spec, sources)
+		}
+	}
+}
+
+// testGuestLogDir keeps the guest logs' state in a new directory, and forgets it as a restarted vmserver would
+func testGuestLogDir(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "guestlogs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetGuestLogDir(dir)
+
+	return func() {
+		SetGuestLogDir("")
+		forgetPositions()
+		os.RemoveAll(dir)
+	}
+}
+
+func forgetPositions() {
+	positionsLock.Lock()
+	sourcePositions = make(map[string]*logPositions)
+	positionsLock.Unlock()
+}
+
+// streamLines returns the first n lines sources stream after offset
+func streamLines(t *testing.T, sources []LogSource, offset int64, n int) []*icommon.LogLine {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan *icommon.LogLine)
+	go StreamGuestLogs(ctx, sources, offset, func(line *icommon.LogLine) error {
+		select {
+		case received <- line:
+		case <-ctx.Done():
+		}
+		return nil
+	})
+
+	lines := []*icommon.LogLine{}
+	for len(lines) < n {
+		select {
+		case line := <-received:
+			lines = append(lines, line)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %v of %v lines", len(lines), n)
+		}
+	}
+
+	return lines
+}
+
+func texts(lines []*icommon.LogLine) []string {
+	ret := []string{}
+	for _, line := range lines {
+		ret = append(ret, line.LogLine)
+	}
+	return ret
+}
+
+func appendFile(t *testing.T, path string, text string) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(text); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamFileResumes(t *testing.T) {
+	defer testGuestLogDir(t)()
+
+	path := filepath.Join(guestLogDir, "app.log")
+	appendFile(t, path, "a\nb\n")
+	sources := []LogSource{{Kind: LogSourceFile, Target: path}}
+
+	first := streamLines(t, sources, 0, 2)
+	if got := texts(first); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("got %v", got)
+	}
+
+	// a restarted vmserver knows where the lines were from what it saved
+	time.Sleep(100 * time.Millisecond)
+	forgetPositions()
+	appendFile(t, path, "c\n")
+
+	if got := texts(streamLines(t, sources, first[1].Offset, 1)); !reflect.DeepEqual(got, []string{"c"}) {
+		t.Errorf("resumed after b with %v", got)
+	}
+	if got := texts(streamLines(t, sources, first[0].Offset, 2)); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Errorf("resumed after a with %v", got)
+	}
+
+	// a stream at an offset the file's positions don't go back to carries on from its end
+	appendFile(t, path, "d\n")
+	go func() {
+		time.Sleep(2 * followInterval)
+		appendFile(t, path, "e\n")
+	}()
+	if got := texts(streamLines(t, sources, 1, 1)); !reflect.DeepEqual(got, []string{"e"}) {
+		t.Errorf("resumed at an unknown offset with %v", got)
+	}
+}
+
+func TestStreamFileReplaced(t *testing.T) {
+	defer testGuestLogDir(t)()
+
+	path := filepath.Join(guestLogDir, "app.log")
+	appendFile(t, path, "a\n")
+	sources := []LogSource{{Kind: LogSourceFile, Target: path}}
+
+	first := streamLines(t, sources, 0, 1)
+	time.Sleep(100 * time.Millisecond)
+	forgetPositions()
+
+	// rotated, so the position after a is in the old file
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "b\n")
+	if got := texts(streamLines(t, sources, first[0].Offset, 1)); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("resumed in a rotated file with %v", got)
+	}
+
+	// and rotated while it's followed
+	go func() {
+		time.Sleep(2 * followInterval)
+		appendFile(t, path, "c\n")
+		os.Rename(path, path+".1")
+		appendFile(t, path, "d\n")
+	}()
+	if got := texts(streamLines(t, sources, 0, 3)); !reflect.DeepEqual(got, []string{"b", "c", "d"}) {
+		t.Errorf("followed a rotated file with %v", got)
+	}
+}
+
+func TestStreamSerial(t *testing.T) {
+	defer testGuestLogDir(t)()
+
+	savedKmsg, savedStat := kmsgPath, procStat
+	kmsgPath, procStat = filepath.Join(guestLogDir, "kmsg"), filepath.Join(guestLogDir, "stat")
+	defer func() {
+		kmsgPath, procStat = savedKmsg, savedStat
+	}()
+
+	appendFile(t, procStat, "cpu  1 2 3\nbtime 1500000000\nprocesses 10\n")
+	appendFile(t, kmsgPath, "6,0,0,-;Linux version 4.9.0\n SUBSYSTEM=cpu\n6,1,2000000,-;eth0: link up\n")
+	sources := []LogSource{{Kind: LogSourceSerial}}
+
+	first := streamLines(t, sources, 0, 2)
+	if got := texts(first); !reflect.DeepEqual(got, []string{"kernel: Linux version 4.9.0", "kernel: eth0: link up"}) {
+		t.Fatalf("got %v", got)
+	}
+	if want := int64(1500000002) * int64(time.Second); first[1].Timestamp != want {
+		t.Errorf("stamped %v, want %v", first[1].Timestamp, want)
+	}
+
+	// records are stamped from when the VM booted, so a restarted vmserver resumes after the one at the offset
+	if got := texts(streamLines(t, sources, first[0].Offset, 1)); !reflect.DeepEqual(got, []string{"kernel: eth0: link up"}) {
+		t.Errorf("resumed with %v", got)
+	}
+}
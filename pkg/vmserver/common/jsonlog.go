@@ -0,0 +1,146 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/hpcloud/tail"
+	"golang.org/x/net/context"
+
+	icommon "github.com/apporbit/infranetes/pkg/common"
+)
+
+// jsonLogLine is a single line of docker's json-file log driver, which providers that manage their containers'
+// output themselves write as well
+type jsonLogLine struct {
+	Log    string    `json:"log"`
+	Stream string    `json:"stream"`
+	Time   time.Time `json:"time"`
+}
+
+func ParseJsonLogLine(text string) (*icommon.LogLine, error) {
+	var entry jsonLogLine
+	if err := json.Unmarshal([]byte(text), &entry); err != nil {
+		return nil, err
+	}
+
+	ret := &icommon.LogLine{
+		LogLine:   strings.TrimSuffix(entry.Log, "\n"),
+		Timestamp: entry.Time.UnixNano(),
+		Stream:    entry.Stream,
+		Partial:   !strings.HasSuffix(entry.Log, "\n"),
+	}
+
+	return ret, nil
+}
+
+// JsonLogWriter writes a container's stdout and stderr to a json-file log, one entry per line of output
+type JsonLogWriter struct {
+	lock sync.Mutex
+	out  io.Writer
+}
+
+func NewJsonLogWriter(out io.Writer) *JsonLogWriter {
+	return &JsonLogWriter{out: out}
+}
+
+// Stream returns a writer for one of the container's streams, "stdout" or "stderr"
+func (w *JsonLogWriter) Stream(stream string) io.Writer {
+	return &jsonStreamWriter{parent: w, stream: stream}
+}
+
+func (w *JsonLogWriter) write(stream string, log string) error {
+	data, err := json.Marshal(&jsonLogLine{Log: log, Stream: stream, Time: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	_, err = w.out.Write(append(data, '\n'))
+
+	return err
+}
+
+type jsonStreamWriter struct {
+	parent  *JsonLogWriter
+	stream  string
+	partial string
+}
+
+func (s *jsonStreamWriter) Write(p []byte) (int, error) {
+	data := s.partial + string(p)
+
+	for {
+		i := strings.IndexByte(data, '\n')
+		if i == -1 {
+			break
+		}
+		if err := s.parent.write(s.stream, data[:i+1]); err != nil {
+			return 0, err
+		}
+		data = data[i+1:]
+	}
+
+	// flush overly long lines as partial entries rather than buffering them forever
+	if len(data) >= 16*1024 {
+		if err := s.parent.write(s.stream, data); err != nil {
+			return 0, err
+		}
+		data = ""
+	}
+	s.partial = data
+
+	return len(p), nil
+}
+
+// FollowJsonLog streams the json log at path from offset, a byte offset into it, until stop is closed, ctx is done or
+// send fails
+func FollowJsonLog(ctx context.Context, path string, offset int64, stop <-chan struct{}, send func(*icommon.LogLine) error) error {
+	if info, err := os.Stat(path); err != nil || info.Size() < offset {
+		offset = 0
+	}
+
+	t, err := tail.TailFile(path, tail.Config{
+		Follow:   true,
+		Location: &tail.SeekInfo{Offset: offset, Whence: os.SEEK_SET},
+	})
+	if err != nil {
+		return fmt.Errorf("tail of %v failed: %v", path, err)
+	}
+	defer t.Stop()
+
+	for {
+		select {
+		case line, ok := <-t.Lines:
+			if !ok {
+				return t.Err()
+			}
+
+			// tail strips the newline every entry ends with
+			offset += int64(len(line.Text)) + 1
+
+			logLine, err := ParseJsonLogLine(line.Text)
+			if err != nil {
+				glog.Warningf("FollowJsonLog: skipping unparseable line in %v: %v", path, err)
+				continue
+			}
+			logLine.Offset = offset
+
+			if err := send(logLine); err != nil {
+				return err
+			}
+		case <-stop:
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
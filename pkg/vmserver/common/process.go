@@ -0,0 +1,183 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kr/pty"
+
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/kubernetes/pkg/util/term"
+)
+
+// ManagedProcess is a container's main process run as a child of vmserver, for providers whose runtime doesn't
+// keep the container's output itself.  Output goes to a json-file log and to whoever is attached.
+type ManagedProcess struct {
+	*Relay
+
+	name string
+	cmd  *exec.Cmd
+
+	// pty is the terminal of tty containers, nil otherwise
+	pty *os.File
+
+	// done is closed once the process has exited and exitCode is set
+	done     chan struct{}
+	exitCode int32
+}
+
+// StartManagedProcess starts cmd, on a terminal if tty is set, appending its output to the json log at logPath
+func StartManagedProcess(name string, cmd *exec.Cmd, tty bool, stdin bool, logPath string) (*ManagedProcess, error) {
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open log: %v", err)
+	}
+	logWriter := NewJsonLogWriter(logFile)
+
+	p := &ManagedProcess{
+		name: name,
+		cmd:  cmd,
+		done: make(chan struct{}),
+	}
+	var stdinPipe io.Writer
+
+	glog.Infof("StartManagedProcess: running %v", cmd.Args)
+
+	pumps := sync.WaitGroup{}
+	pump := func(r io.Reader, stream string) {
+		defer pumps.Done()
+		p.Pump(r, stream, logWriter.Stream(stream))
+	}
+
+	if tty {
+		if p.pty, err = pty.Start(cmd); err != nil {
+			logFile.Close()
+			return nil, err
+		}
+		stdinPipe = p.pty
+		p.Relay = NewRelay(name, stdinPipe, func(size remotecommand.TerminalSize) {
+			term.SetSize(p.pty.Fd(), size)
+		})
+		pumps.Add(1)
+		go pump(p.pty, "stdout")
+	} else {
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			logFile.Close()
+			return nil, err
+		}
+		stderr, err := cmd.StderrPipe()
+		if err != nil {
+			logFile.Close()
+			return nil, err
+		}
+		if stdin {
+			if stdinPipe, err = cmd.StdinPipe(); err != nil {
+				logFile.Close()
+				return nil, err
+			}
+		}
+		p.Relay = NewRelay(name, stdinPipe, nil)
+		if err := cmd.Start(); err != nil {
+			logFile.Close()
+			return nil, err
+		}
+		pumps.Add(2)
+		go pump(stdout, "stdout")
+		go pump(stderr, "stderr")
+	}
+
+	go func() {
+		err := cmd.Wait()
+		if p.pty != nil {
+			// reads of the master only end once it's closed
+			p.pty.Close()
+		}
+		pumps.Wait()
+		logFile.Close()
+
+		p.exitCode = exitCode(err)
+		glog.Infof("%v exited with %v", name, p.exitCode)
+		p.Relay.Close()
+		close(p.done)
+	}()
+
+	return p, nil
+}
+
+func exitCode(err error) int32 {
+	if err == nil {
+		return 0
+	}
+
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			if ws.Signaled() {
+				return int32(128 + ws.Signal())
+			}
+			return int32(ws.ExitStatus())
+		}
+	}
+
+	return -1
+}
+
+// Done is closed once the process has exited
+func (p *ManagedProcess) Done() <-chan struct{} {
+	return p.done
+}
+
+// ExitCode is only meaningful once Done is closed
+func (p *ManagedProcess) ExitCode() int32 {
+	return p.exitCode
+}
+
+func (p *ManagedProcess) Pid() int {
+	return p.cmd.Process.Pid
+}
+
+func (p *ManagedProcess) Running() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Stop sends the process SIGTERM and SIGKILL if it hasn't exited after timeout.  Providers whose process is a client
+// of the real one pass signal to deliver the signals to that instead.
+func (p *ManagedProcess) Stop(timeout time.Duration, signal func(syscall.Signal) error) {
+	if !p.Running() {
+		return
+	}
+
+	if signal == nil {
+		signal = func(sig syscall.Signal) error {
+			return p.cmd.Process.Signal(sig)
+		}
+	}
+
+	if err := signal(syscall.SIGTERM); err != nil {
+		glog.Warningf("%v: SIGTERM failed: %v", p.name, err)
+	}
+
+	select {
+	case <-p.done:
+		return
+	case <-time.After(timeout):
+	}
+
+	glog.Warningf("%v didn't stop within %v, killing it", p.name, timeout)
+	if err := signal(syscall.SIGKILL); err != nil {
+		glog.Warningf("%v: SIGKILL failed: %v", p.name, err)
+	}
+
+	<-p.done
+}
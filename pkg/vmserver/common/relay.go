@@ -0,0 +1,128 @@
+package common
+
+import (
+	"io"
+	"sync"
+
+	"github.com/golang/glog"
+
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// Relay copies a container's output to its json-file log and to whoever is attached, and attached clients' input to
+// the container.  ManagedProcess relays the processes vmserver runs itself, providers whose runtime hands them the
+// container's stdio use one directly.
+type Relay struct {
+	name  string
+	stdin io.Writer
+	// resize sets the size of the container's terminal, nil if it has none
+	resize func(remotecommand.TerminalSize)
+
+	attachLock sync.Mutex
+	attached   map[*attachment]bool
+
+	// done is closed once the container has exited, ending attachments
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+type attachment struct {
+	stdout io.Writer
+	stderr io.Writer
+	// failed is closed when a write to the client fails, i.e. it went away
+	failed chan struct{}
+	once   sync.Once
+}
+
+// NewRelay returns a relay for the container called name, whose stdin, if it has one, is stdin
+func NewRelay(name string, stdin io.Writer, resize func(remotecommand.TerminalSize)) *Relay {
+	return &Relay{
+		name:     name,
+		stdin:    stdin,
+		resize:   resize,
+		attached: make(map[*attachment]bool),
+		done:     make(chan struct{}),
+	}
+}
+
+// Pump copies rd, the container's stream, to log and attached clients until it ends
+func (r *Relay) Pump(rd io.Reader, stream string, log io.Writer) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := rd.Read(buf)
+		if n > 0 {
+			if _, werr := log.Write(buf[:n]); werr != nil {
+				glog.Warningf("%v: couldn't write log: %v", r.name, werr)
+			}
+			r.broadcast(stream, buf[:n])
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (r *Relay) broadcast(stream string, data []byte) {
+	r.attachLock.Lock()
+	defer r.attachLock.Unlock()
+
+	for a := range r.attached {
+		w := a.stdout
+		if stream == "stderr" && a.stderr != nil {
+			w = a.stderr
+		}
+		if w == nil {
+			continue
+		}
+		if _, err := w.Write(data); err != nil {
+			a.once.Do(func() { close(a.failed) })
+			delete(r.attached, a)
+		}
+	}
+}
+
+// Close ends attachments, once the container has exited
+func (r *Relay) Close() {
+	r.doneOnce.Do(func() { close(r.done) })
+}
+
+// Attach streams the container's output to stdout and stderr and stdin to the container until it exits or the client
+// goes away
+func (r *Relay) Attach(stdin io.Reader, stdout, stderr io.WriteCloser, resize <-chan remotecommand.TerminalSize) error {
+	a := &attachment{failed: make(chan struct{})}
+	if stdout != nil {
+		a.stdout = stdout
+	}
+	if stderr != nil {
+		a.stderr = stderr
+	}
+
+	r.attachLock.Lock()
+	r.attached[a] = true
+	r.attachLock.Unlock()
+
+	defer func() {
+		r.attachLock.Lock()
+		delete(r.attached, a)
+		r.attachLock.Unlock()
+	}()
+
+	if resize != nil && r.resize != nil {
+		go func() {
+			for size := range resize {
+				r.resize(size)
+			}
+		}()
+	}
+
+	if stdin != nil && r.stdin != nil {
+		go io.Copy(r.stdin, stdin)
+	}
+
+	select {
+	case <-r.done:
+	case <-a.failed:
+	}
+
+	return nil
+}
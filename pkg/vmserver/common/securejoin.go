@@ -0,0 +1,80 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxSymlinks is how many symlinks are followed resolving a single path before it's assumed to loop
+const maxSymlinks = 255
+
+// SecureJoin resolves name within root as a chroot into root would, following the symlinks already in root without
+// letting them lead outside of it
+func SecureJoin(root string, name string) (string, error) {
+	return resolveIn(root, name, false)
+}
+
+// ResolveWithin resolves name within root as the OS would, following the symlinks already in root, and fails if that
+// leads outside of root
+func ResolveWithin(root string, name string) (string, error) {
+	return resolveIn(root, name, true)
+}
+
+// resolveIn resolves name within root, keeping to root as a chroot would or, if strict is set, failing when it leads
+// outside of it
+func resolveIn(root string, name string, strict bool) (string, error) {
+	resolved := ""
+	remaining := name
+	links := 0
+
+	for remaining != "" {
+		var part string
+		if i := strings.IndexByte(remaining, '/'); i == -1 {
+			part, remaining = remaining, ""
+		} else {
+			part, remaining = remaining[:i], remaining[i+1:]
+		}
+
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if strict && resolved == "" {
+				return "", fmt.Errorf("%v leads outside of %v", name, root)
+			}
+			resolved = filepath.Dir(resolved)
+			if resolved == "." || resolved == "/" {
+				resolved = ""
+			}
+			continue
+		}
+
+		next := filepath.Join(resolved, part)
+		info, err := os.Lstat(filepath.Join(root, next))
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", fmt.Errorf("too many symlinks resolving %v", name)
+		}
+
+		link, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(link) {
+			if strict {
+				return "", fmt.Errorf("%v leads to %v, outside of %v", name, link, root)
+			}
+			resolved = ""
+		}
+		remaining = link + "/" + remaining
+	}
+
+	return filepath.Join(root, resolved), nil
+}
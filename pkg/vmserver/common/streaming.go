@@ -9,6 +9,7 @@ import (
 	"os/exec"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/golang/glog"
 
@@ -124,7 +125,28 @@ func ExecSync(req *kubeapi.ExecSyncRequest) (*kubeapi.ExecSyncResponse, error) {
 	command.Stdout = stdoutBuffer
 	command.Stderr = stderrBuffer
 
-	err := command.Run()
+	if err := command.Start(); err != nil {
+		return nil, fmt.Errorf("ExecSync: failed to run: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- command.Wait()
+	}()
+
+	var timeout <-chan time.Time
+	if req.Timeout > 0 {
+		timeout = time.After(time.Duration(req.Timeout) * time.Second)
+	}
+
+	var err error
+	select {
+	case err = <-done:
+	case <-timeout:
+		command.Process.Kill()
+		<-done
+		return nil, fmt.Errorf("ExecSync: %v timed out after %vs", req.Cmd, req.Timeout)
+	}
 
 	exit := int32(0)
 
@@ -0,0 +1,553 @@
+package containerd
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// containerd's go client and api packages need a far newer grpc and protobuf than are vendored, so the messages of the
+// few services the provider uses are declared here, with containerd's field numbers, and its rpcs invoked directly.
+// Fields the provider doesn't use are left out, protobuf skips them.
+
+const (
+	// containerd scopes every request to the namespace in this header, and protects what's written under a lease
+	namespaceHeader = "containerd-namespace"
+	leaseHeader     = "containerd-lease"
+
+	// the type urls containerd stores runtime specs under
+	specTypeURL    = "types.containerd.io/opencontainers/runtime-spec/1/Spec"
+	processTypeURL = "types.containerd.io/opencontainers/runtime-spec/1/Process"
+)
+
+// task statuses, containerd.v1.types.Status
+const (
+	taskStatusUnknown int32 = iota
+	taskStatusCreated
+	taskStatusRunning
+	taskStatusStopped
+	taskStatusPaused
+	taskStatusPausing
+)
+
+// content write actions, containerd.services.content.v1.WriteAction
+const (
+	writeActionStat int32 = iota
+	writeActionWrite
+	writeActionCommit
+)
+
+type empty struct{}
+
+func (m *empty) Reset()         { *m = empty{} }
+func (m *empty) String() string { return proto.CompactTextString(m) }
+func (*empty) ProtoMessage()    {}
+
+// any is google.protobuf.Any
+type any struct {
+	TypeUrl string `protobuf:"bytes,1,opt,name=type_url,json=typeUrl" json:"type_url,omitempty"`
+	Value   []byte `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (m *any) Reset()         { *m = any{} }
+func (m *any) String() string { return proto.CompactTextString(m) }
+func (*any) ProtoMessage()    {}
+
+type apiMount struct {
+	Type    string   `protobuf:"bytes,1,opt,name=type" json:"type,omitempty"`
+	Source  string   `protobuf:"bytes,2,opt,name=source" json:"source,omitempty"`
+	Target  string   `protobuf:"bytes,3,opt,name=target" json:"target,omitempty"`
+	Options []string `protobuf:"bytes,4,rep,name=options" json:"options,omitempty"`
+}
+
+func (m *apiMount) Reset()         { *m = apiMount{} }
+func (m *apiMount) String() string { return proto.CompactTextString(m) }
+func (*apiMount) ProtoMessage()    {}
+
+type apiDescriptor struct {
+	MediaType   string            `protobuf:"bytes,1,opt,name=media_type,json=mediaType" json:"media_type,omitempty"`
+	Digest      string            `protobuf:"bytes,2,opt,name=digest" json:"digest,omitempty"`
+	Size        int64             `protobuf:"varint,3,opt,name=size" json:"size,omitempty"`
+	Annotations map[string]string `protobuf:"bytes,5,rep,name=annotations" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *apiDescriptor) Reset()         { *m = apiDescriptor{} }
+func (m *apiDescriptor) String() string { return proto.CompactTextString(m) }
+func (*apiDescriptor) ProtoMessage()    {}
+
+// version
+
+type versionResponse struct {
+	Version  string `protobuf:"bytes,1,opt,name=version" json:"version,omitempty"`
+	Revision string `protobuf:"bytes,2,opt,name=revision" json:"revision,omitempty"`
+}
+
+func (m *versionResponse) Reset()         { *m = versionResponse{} }
+func (m *versionResponse) String() string { return proto.CompactTextString(m) }
+func (*versionResponse) ProtoMessage()    {}
+
+// leases
+
+type lease struct {
+	ID string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+}
+
+func (m *lease) Reset()         { *m = lease{} }
+func (m *lease) String() string { return proto.CompactTextString(m) }
+func (*lease) ProtoMessage()    {}
+
+type createLeaseRequest struct {
+	ID     string            `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Labels map[string]string `protobuf:"bytes,3,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *createLeaseRequest) Reset()         { *m = createLeaseRequest{} }
+func (m *createLeaseRequest) String() string { return proto.CompactTextString(m) }
+func (*createLeaseRequest) ProtoMessage()    {}
+
+type createLeaseResponse struct {
+	Lease *lease `protobuf:"bytes,1,opt,name=lease" json:"lease,omitempty"`
+}
+
+func (m *createLeaseResponse) Reset()         { *m = createLeaseResponse{} }
+func (m *createLeaseResponse) String() string { return proto.CompactTextString(m) }
+func (*createLeaseResponse) ProtoMessage()    {}
+
+type deleteLeaseRequest struct {
+	ID string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+}
+
+func (m *deleteLeaseRequest) Reset()         { *m = deleteLeaseRequest{} }
+func (m *deleteLeaseRequest) String() string { return proto.CompactTextString(m) }
+func (*deleteLeaseRequest) ProtoMessage()    {}
+
+// images
+
+type apiImage struct {
+	Name   string            `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Labels map[string]string `protobuf:"bytes,2,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Target *apiDescriptor    `protobuf:"bytes,3,opt,name=target" json:"target,omitempty"`
+}
+
+func (m *apiImage) Reset()         { *m = apiImage{} }
+func (m *apiImage) String() string { return proto.CompactTextString(m) }
+func (*apiImage) ProtoMessage()    {}
+
+type getImageRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+}
+
+func (m *getImageRequest) Reset()         { *m = getImageRequest{} }
+func (m *getImageRequest) String() string { return proto.CompactTextString(m) }
+func (*getImageRequest) ProtoMessage()    {}
+
+// imageMessage is GetImageResponse, CreateImageRequest and CreateImageResponse, which all just hold an image
+type imageMessage struct {
+	Image *apiImage `protobuf:"bytes,1,opt,name=image" json:"image,omitempty"`
+}
+
+func (m *imageMessage) Reset()         { *m = imageMessage{} }
+func (m *imageMessage) String() string { return proto.CompactTextString(m) }
+func (*imageMessage) ProtoMessage()    {}
+
+// content
+
+type contentInfo struct {
+	Digest string `protobuf:"bytes,1,opt,name=digest" json:"digest,omitempty"`
+	Size   int64  `protobuf:"varint,2,opt,name=size" json:"size,omitempty"`
+}
+
+func (m *contentInfo) Reset()         { *m = contentInfo{} }
+func (m *contentInfo) String() string { return proto.CompactTextString(m) }
+func (*contentInfo) ProtoMessage()    {}
+
+type infoRequest struct {
+	Digest string `protobuf:"bytes,1,opt,name=digest" json:"digest,omitempty"`
+}
+
+func (m *infoRequest) Reset()         { *m = infoRequest{} }
+func (m *infoRequest) String() string { return proto.CompactTextString(m) }
+func (*infoRequest) ProtoMessage()    {}
+
+type infoResponse struct {
+	Info *contentInfo `protobuf:"bytes,1,opt,name=info" json:"info,omitempty"`
+}
+
+func (m *infoResponse) Reset()         { *m = infoResponse{} }
+func (m *infoResponse) String() string { return proto.CompactTextString(m) }
+func (*infoResponse) ProtoMessage()    {}
+
+type readContentRequest struct {
+	Digest string `protobuf:"bytes,1,opt,name=digest" json:"digest,omitempty"`
+	Offset int64  `protobuf:"varint,2,opt,name=offset" json:"offset,omitempty"`
+	Size   int64  `protobuf:"varint,3,opt,name=size" json:"size,omitempty"`
+}
+
+func (m *readContentRequest) Reset()         { *m = readContentRequest{} }
+func (m *readContentRequest) String() string { return proto.CompactTextString(m) }
+func (*readContentRequest) ProtoMessage()    {}
+
+type readContentResponse struct {
+	Offset int64  `protobuf:"varint,1,opt,name=offset" json:"offset,omitempty"`
+	Data   []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (m *readContentResponse) Reset()         { *m = readContentResponse{} }
+func (m *readContentResponse) String() string { return proto.CompactTextString(m) }
+func (*readContentResponse) ProtoMessage()    {}
+
+type writeContentRequest struct {
+	Action   int32             `protobuf:"varint,1,opt,name=action" json:"action,omitempty"`
+	Ref      string            `protobuf:"bytes,2,opt,name=ref" json:"ref,omitempty"`
+	Total    int64             `protobuf:"varint,3,opt,name=total" json:"total,omitempty"`
+	Expected string            `protobuf:"bytes,4,opt,name=expected" json:"expected,omitempty"`
+	Offset   int64             `protobuf:"varint,5,opt,name=offset" json:"offset,omitempty"`
+	Data     []byte            `protobuf:"bytes,6,opt,name=data,proto3" json:"data,omitempty"`
+	Labels   map[string]string `protobuf:"bytes,7,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *writeContentRequest) Reset()         { *m = writeContentRequest{} }
+func (m *writeContentRequest) String() string { return proto.CompactTextString(m) }
+func (*writeContentRequest) ProtoMessage()    {}
+
+type writeContentResponse struct {
+	Action int32  `protobuf:"varint,1,opt,name=action" json:"action,omitempty"`
+	Offset int64  `protobuf:"varint,4,opt,name=offset" json:"offset,omitempty"`
+	Total  int64  `protobuf:"varint,5,opt,name=total" json:"total,omitempty"`
+	Digest string `protobuf:"bytes,6,opt,name=digest" json:"digest,omitempty"`
+}
+
+func (m *writeContentResponse) Reset()         { *m = writeContentResponse{} }
+func (m *writeContentResponse) String() string { return proto.CompactTextString(m) }
+func (*writeContentResponse) ProtoMessage()    {}
+
+type abortRequest struct {
+	Ref string `protobuf:"bytes,1,opt,name=ref" json:"ref,omitempty"`
+}
+
+func (m *abortRequest) Reset()         { *m = abortRequest{} }
+func (m *abortRequest) String() string { return proto.CompactTextString(m) }
+func (*abortRequest) ProtoMessage()    {}
+
+// snapshots
+
+type prepareSnapshotRequest struct {
+	Snapshotter string            `protobuf:"bytes,1,opt,name=snapshotter" json:"snapshotter,omitempty"`
+	Key         string            `protobuf:"bytes,2,opt,name=key" json:"key,omitempty"`
+	Parent      string            `protobuf:"bytes,3,opt,name=parent" json:"parent,omitempty"`
+	Labels      map[string]string `protobuf:"bytes,4,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *prepareSnapshotRequest) Reset()         { *m = prepareSnapshotRequest{} }
+func (m *prepareSnapshotRequest) String() string { return proto.CompactTextString(m) }
+func (*prepareSnapshotRequest) ProtoMessage()    {}
+
+// mountsResponse is PrepareSnapshotResponse and MountsResponse
+type mountsResponse struct {
+	Mounts []*apiMount `protobuf:"bytes,1,rep,name=mounts" json:"mounts,omitempty"`
+}
+
+func (m *mountsResponse) Reset()         { *m = mountsResponse{} }
+func (m *mountsResponse) String() string { return proto.CompactTextString(m) }
+func (*mountsResponse) ProtoMessage()    {}
+
+// snapshotRequest is MountsRequest, RemoveSnapshotRequest and StatSnapshotRequest
+type snapshotRequest struct {
+	Snapshotter string `protobuf:"bytes,1,opt,name=snapshotter" json:"snapshotter,omitempty"`
+	Key         string `protobuf:"bytes,2,opt,name=key" json:"key,omitempty"`
+}
+
+func (m *snapshotRequest) Reset()         { *m = snapshotRequest{} }
+func (m *snapshotRequest) String() string { return proto.CompactTextString(m) }
+func (*snapshotRequest) ProtoMessage()    {}
+
+type commitSnapshotRequest struct {
+	Snapshotter string            `protobuf:"bytes,1,opt,name=snapshotter" json:"snapshotter,omitempty"`
+	Name        string            `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Key         string            `protobuf:"bytes,3,opt,name=key" json:"key,omitempty"`
+	Labels      map[string]string `protobuf:"bytes,4,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *commitSnapshotRequest) Reset()         { *m = commitSnapshotRequest{} }
+func (m *commitSnapshotRequest) String() string { return proto.CompactTextString(m) }
+func (*commitSnapshotRequest) ProtoMessage()    {}
+
+// diff
+
+type applyRequest struct {
+	Diff   *apiDescriptor `protobuf:"bytes,1,opt,name=diff" json:"diff,omitempty"`
+	Mounts []*apiMount    `protobuf:"bytes,2,rep,name=mounts" json:"mounts,omitempty"`
+}
+
+func (m *applyRequest) Reset()         { *m = applyRequest{} }
+func (m *applyRequest) String() string { return proto.CompactTextString(m) }
+func (*applyRequest) ProtoMessage()    {}
+
+type applyResponse struct {
+	Applied *apiDescriptor `protobuf:"bytes,1,opt,name=applied" json:"applied,omitempty"`
+}
+
+func (m *applyResponse) Reset()         { *m = applyResponse{} }
+func (m *applyResponse) String() string { return proto.CompactTextString(m) }
+func (*applyResponse) ProtoMessage()    {}
+
+// containers
+
+type containerRuntime struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+}
+
+func (m *containerRuntime) Reset()         { *m = containerRuntime{} }
+func (m *containerRuntime) String() string { return proto.CompactTextString(m) }
+func (*containerRuntime) ProtoMessage()    {}
+
+type apiContainer struct {
+	ID          string            `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Labels      map[string]string `protobuf:"bytes,2,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Image       string            `protobuf:"bytes,3,opt,name=image" json:"image,omitempty"`
+	Runtime     *containerRuntime `protobuf:"bytes,4,opt,name=runtime" json:"runtime,omitempty"`
+	Spec        *any              `protobuf:"bytes,5,opt,name=spec" json:"spec,omitempty"`
+	Snapshotter string            `protobuf:"bytes,6,opt,name=snapshotter" json:"snapshotter,omitempty"`
+	SnapshotKey string            `protobuf:"bytes,7,opt,name=snapshot_key,json=snapshotKey" json:"snapshot_key,omitempty"`
+}
+
+func (m *apiContainer) Reset()         { *m = apiContainer{} }
+func (m *apiContainer) String() string { return proto.CompactTextString(m) }
+func (*apiContainer) ProtoMessage()    {}
+
+// containerMessage is CreateContainerRequest and CreateContainerResponse
+type containerMessage struct {
+	Container *apiContainer `protobuf:"bytes,1,opt,name=container" json:"container,omitempty"`
+}
+
+func (m *containerMessage) Reset()         { *m = containerMessage{} }
+func (m *containerMessage) String() string { return proto.CompactTextString(m) }
+func (*containerMessage) ProtoMessage()    {}
+
+type listContainersRequest struct {
+	Filters []string `protobuf:"bytes,1,rep,name=filters" json:"filters,omitempty"`
+}
+
+func (m *listContainersRequest) Reset()         { *m = listContainersRequest{} }
+func (m *listContainersRequest) String() string { return proto.CompactTextString(m) }
+func (*listContainersRequest) ProtoMessage()    {}
+
+type listContainersResponse struct {
+	Containers []*apiContainer `protobuf:"bytes,1,rep,name=containers" json:"containers,omitempty"`
+}
+
+func (m *listContainersResponse) Reset()         { *m = listContainersResponse{} }
+func (m *listContainersResponse) String() string { return proto.CompactTextString(m) }
+func (*listContainersResponse) ProtoMessage()    {}
+
+type deleteContainerRequest struct {
+	ID string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+}
+
+func (m *deleteContainerRequest) Reset()         { *m = deleteContainerRequest{} }
+func (m *deleteContainerRequest) String() string { return proto.CompactTextString(m) }
+func (*deleteContainerRequest) ProtoMessage()    {}
+
+// tasks
+
+type createTaskRequest struct {
+	ContainerID string      `protobuf:"bytes,1,opt,name=container_id,json=containerId" json:"container_id,omitempty"`
+	Rootfs      []*apiMount `protobuf:"bytes,3,rep,name=rootfs" json:"rootfs,omitempty"`
+	Stdin       string      `protobuf:"bytes,4,opt,name=stdin" json:"stdin,omitempty"`
+	Stdout      string      `protobuf:"bytes,5,opt,name=stdout" json:"stdout,omitempty"`
+	Stderr      string      `protobuf:"bytes,6,opt,name=stderr" json:"stderr,omitempty"`
+	Terminal    bool        `protobuf:"varint,7,opt,name=terminal" json:"terminal,omitempty"`
+}
+
+func (m *createTaskRequest) Reset()         { *m = createTaskRequest{} }
+func (m *createTaskRequest) String() string { return proto.CompactTextString(m) }
+func (*createTaskRequest) ProtoMessage()    {}
+
+type createTaskResponse struct {
+	ContainerID string `protobuf:"bytes,1,opt,name=container_id,json=containerId" json:"container_id,omitempty"`
+	Pid         uint32 `protobuf:"varint,2,opt,name=pid" json:"pid,omitempty"`
+}
+
+func (m *createTaskResponse) Reset()         { *m = createTaskResponse{} }
+func (m *createTaskResponse) String() string { return proto.CompactTextString(m) }
+func (*createTaskResponse) ProtoMessage()    {}
+
+// processRequest is StartRequest, DeleteProcessRequest, GetRequest and WaitRequest, which name a task or one of its
+// exec processes.  DeleteTaskRequest is the same without the exec id.
+type processRequest struct {
+	ContainerID string `protobuf:"bytes,1,opt,name=container_id,json=containerId" json:"container_id,omitempty"`
+	ExecID      string `protobuf:"bytes,2,opt,name=exec_id,json=execId" json:"exec_id,omitempty"`
+}
+
+func (m *processRequest) Reset()         { *m = processRequest{} }
+func (m *processRequest) String() string { return proto.CompactTextString(m) }
+func (*processRequest) ProtoMessage()    {}
+
+type startResponse struct {
+	Pid uint32 `protobuf:"varint,1,opt,name=pid" json:"pid,omitempty"`
+}
+
+func (m *startResponse) Reset()         { *m = startResponse{} }
+func (m *startResponse) String() string { return proto.CompactTextString(m) }
+func (*startResponse) ProtoMessage()    {}
+
+type deleteResponse struct {
+	ID         string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Pid        uint32 `protobuf:"varint,2,opt,name=pid" json:"pid,omitempty"`
+	ExitStatus uint32 `protobuf:"varint,3,opt,name=exit_status,json=exitStatus" json:"exit_status,omitempty"`
+}
+
+func (m *deleteResponse) Reset()         { *m = deleteResponse{} }
+func (m *deleteResponse) String() string { return proto.CompactTextString(m) }
+func (*deleteResponse) ProtoMessage()    {}
+
+type apiProcess struct {
+	ContainerID string `protobuf:"bytes,1,opt,name=container_id,json=containerId" json:"container_id,omitempty"`
+	ID          string `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+	Pid         uint32 `protobuf:"varint,3,opt,name=pid" json:"pid,omitempty"`
+	Status      int32  `protobuf:"varint,4,opt,name=status" json:"status,omitempty"`
+	Stdin       string `protobuf:"bytes,5,opt,name=stdin" json:"stdin,omitempty"`
+	Stdout      string `protobuf:"bytes,6,opt,name=stdout" json:"stdout,omitempty"`
+	Stderr      string `protobuf:"bytes,7,opt,name=stderr" json:"stderr,omitempty"`
+	Terminal    bool   `protobuf:"varint,8,opt,name=terminal" json:"terminal,omitempty"`
+	ExitStatus  uint32 `protobuf:"varint,9,opt,name=exit_status,json=exitStatus" json:"exit_status,omitempty"`
+}
+
+func (m *apiProcess) Reset()         { *m = apiProcess{} }
+func (m *apiProcess) String() string { return proto.CompactTextString(m) }
+func (*apiProcess) ProtoMessage()    {}
+
+type getResponse struct {
+	Process *apiProcess `protobuf:"bytes,1,opt,name=process" json:"process,omitempty"`
+}
+
+func (m *getResponse) Reset()         { *m = getResponse{} }
+func (m *getResponse) String() string { return proto.CompactTextString(m) }
+func (*getResponse) ProtoMessage()    {}
+
+type killRequest struct {
+	ContainerID string `protobuf:"bytes,1,opt,name=container_id,json=containerId" json:"container_id,omitempty"`
+	ExecID      string `protobuf:"bytes,2,opt,name=exec_id,json=execId" json:"exec_id,omitempty"`
+	Signal      uint32 `protobuf:"varint,3,opt,name=signal" json:"signal,omitempty"`
+	All         bool   `protobuf:"varint,4,opt,name=all" json:"all,omitempty"`
+}
+
+func (m *killRequest) Reset()         { *m = killRequest{} }
+func (m *killRequest) String() string { return proto.CompactTextString(m) }
+func (*killRequest) ProtoMessage()    {}
+
+type execProcessRequest struct {
+	ContainerID string `protobuf:"bytes,1,opt,name=container_id,json=containerId" json:"container_id,omitempty"`
+	Stdin       string `protobuf:"bytes,2,opt,name=stdin" json:"stdin,omitempty"`
+	Stdout      string `protobuf:"bytes,3,opt,name=stdout" json:"stdout,omitempty"`
+	Stderr      string `protobuf:"bytes,4,opt,name=stderr" json:"stderr,omitempty"`
+	Terminal    bool   `protobuf:"varint,5,opt,name=terminal" json:"terminal,omitempty"`
+	Spec        *any   `protobuf:"bytes,6,opt,name=spec" json:"spec,omitempty"`
+	ExecID      string `protobuf:"bytes,7,opt,name=exec_id,json=execId" json:"exec_id,omitempty"`
+}
+
+func (m *execProcessRequest) Reset()         { *m = execProcessRequest{} }
+func (m *execProcessRequest) String() string { return proto.CompactTextString(m) }
+func (*execProcessRequest) ProtoMessage()    {}
+
+type resizePtyRequest struct {
+	ContainerID string `protobuf:"bytes,1,opt,name=container_id,json=containerId" json:"container_id,omitempty"`
+	ExecID      string `protobuf:"bytes,2,opt,name=exec_id,json=execId" json:"exec_id,omitempty"`
+	Width       uint32 `protobuf:"varint,3,opt,name=width" json:"width,omitempty"`
+	Height      uint32 `protobuf:"varint,4,opt,name=height" json:"height,omitempty"`
+}
+
+func (m *resizePtyRequest) Reset()         { *m = resizePtyRequest{} }
+func (m *resizePtyRequest) String() string { return proto.CompactTextString(m) }
+func (*resizePtyRequest) ProtoMessage()    {}
+
+type closeIORequest struct {
+	ContainerID string `protobuf:"bytes,1,opt,name=container_id,json=containerId" json:"container_id,omitempty"`
+	ExecID      string `protobuf:"bytes,2,opt,name=exec_id,json=execId" json:"exec_id,omitempty"`
+	Stdin       bool   `protobuf:"varint,3,opt,name=stdin" json:"stdin,omitempty"`
+}
+
+func (m *closeIORequest) Reset()         { *m = closeIORequest{} }
+func (m *closeIORequest) String() string { return proto.CompactTextString(m) }
+func (*closeIORequest) ProtoMessage()    {}
+
+type waitResponse struct {
+	ExitStatus uint32 `protobuf:"varint,1,opt,name=exit_status,json=exitStatus" json:"exit_status,omitempty"`
+}
+
+func (m *waitResponse) Reset()         { *m = waitResponse{} }
+func (m *waitResponse) String() string { return proto.CompactTextString(m) }
+func (*waitResponse) ProtoMessage()    {}
+
+// client is a connection to containerd's grpc api, scoped to one namespace
+type client struct {
+	conn      *grpc.ClientConn
+	namespace string
+}
+
+func dialContainerd(address string, namespace string) (*client, error) {
+	conn, err := grpc.Dial(address,
+		grpc.WithInsecure(),
+		grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+			return net.DialTimeout("unix", addr, timeout)
+		}))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't connect to containerd at %v: %v", address, err)
+	}
+
+	return &client{conn: conn, namespace: namespace}, nil
+}
+
+func (c *client) context(ctx context.Context, leaseID string) context.Context {
+	md := metadata.Pairs(namespaceHeader, c.namespace)
+	if leaseID != "" {
+		md = metadata.Join(md, metadata.Pairs(leaseHeader, leaseID))
+	}
+	return metadata.NewContext(ctx, md)
+}
+
+// call invokes one of containerd's unary rpcs, e.g. call("tasks.v1.Tasks/Start", ...)
+func (c *client) call(ctx context.Context, method string, req interface{}, resp interface{}) error {
+	return c.callLeased(ctx, "", method, req, resp)
+}
+
+func (c *client) callLeased(ctx context.Context, leaseID string, method string, req interface{}, resp interface{}) error {
+	if err := grpc.Invoke(c.context(ctx, leaseID), "/containerd.services."+method, req, resp, c.conn); err != nil {
+		return &callError{method: method, err: err}
+	}
+	return nil
+}
+
+// stream opens one of containerd's streaming rpcs
+func (c *client) stream(ctx context.Context, leaseID string, method string, clientStreams bool) (grpc.ClientStream, error) {
+	desc := &grpc.StreamDesc{StreamName: method, ServerStreams: true, ClientStreams: clientStreams}
+	return grpc.NewClientStream(c.context(ctx, leaseID), desc, c.conn, "/containerd.services."+method)
+}
+
+// isCode tells whether err, as returned by call, is containerd's grpc error code
+func isCode(err error, code codes.Code) bool {
+	return err != nil && grpc.Code(errorCause(err)) == code
+}
+
+// callError keeps the grpc error call wraps, so isCode can still find its code
+type callError struct {
+	method string
+	err    error
+}
+
+func (e *callError) Error() string {
+	return e.method + ": " + grpc.ErrorDesc(e.err)
+}
+
+func errorCause(err error) error {
+	if e, ok := err.(*callError); ok {
+		return e.err
+	}
+	return err
+}
@@ -0,0 +1,766 @@
+package containerd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+
+	"github.com/apporbit/infranetes/cmd/vmserver/flags"
+	icommon "github.com/apporbit/infranetes/pkg/common"
+	"github.com/apporbit/infranetes/pkg/vmserver"
+	"github.com/apporbit/infranetes/pkg/vmserver/common"
+
+	"k8s.io/client-go/tools/remotecommand"
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+const (
+	// seconds StopContainer gives a container when the request doesn't say
+	defaultStopTimeout = 10
+	// how often waiting for a task retries while containerd is unreachable, e.g. restarting
+	waitRetryInterval = 2 * time.Second
+
+	stateFile = "container.json"
+)
+
+// containerdContainer is the provider's view of a container on top of its common.Container state
+type containerdContainer struct {
+	*common.Container
+
+	// ctrId is the container's id in containerd, which doesn't allow the : of ours.  It also names its snapshot.
+	ctrId   string
+	spec    *spec
+	dir     string
+	logPath string
+	tty     bool
+	stdin   bool
+	// task relays the container's task once it's started
+	task *task
+	// exited is closed once waitTask has recorded the task's exit
+	exited chan struct{}
+	// removed is closed by RemoveContainer so log streams of the container end
+	removed chan struct{}
+}
+
+// task is a container's running task, whose output comes through its fifos
+type task struct {
+	*common.Relay
+
+	fifos   *fifos
+	pio     *processIO
+	logFile *os.File
+	pumps   sync.WaitGroup
+	// done is closed once the task has exited and its output has all been relayed
+	done chan struct{}
+}
+
+func (t *task) Running() bool {
+	select {
+	case <-t.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// finish ends the relaying of the task's output, once containerd has deleted it
+func (t *task) finish() {
+	t.pio.drain()
+	t.pumps.Wait()
+	t.pio.close()
+	t.logFile.Close()
+	t.fifos.remove()
+	t.Relay.Close()
+	close(t.done)
+}
+
+// savedContainer is what the provider keeps of a container in its state file, to re-adopt it after a vmserver restart
+type savedContainer struct {
+	PodId  string                   `json:"podId"`
+	Status *kubeapi.ContainerStatus `json:"status"`
+	Tty    bool                     `json:"tty"`
+	Stdin  bool                     `json:"stdin"`
+	Spec   *spec                    `json:"spec"`
+}
+
+type containerdProvider struct {
+	contMap map[string]*containerdContainer
+	mapLock sync.Mutex
+	events  *common.EventBus
+
+	client      *client
+	root        string
+	runtime     string
+	snapshotter string
+
+	streamingRuntime *streamingRuntime
+}
+
+func init() {
+	vmserver.ContainerProviders.RegisterProvider("containerd", NewContainerdProvider)
+}
+
+func NewContainerdProvider() (vmserver.ContainerProvider, error) {
+	glog.Infof("ContainerdProvider: starting")
+
+	root := *flags.ContainerdRoot
+	if err := os.MkdirAll(filepath.Join(root, "containers"), 0755); err != nil {
+		return nil, fmt.Errorf("NewContainerdProvider: %v", err)
+	}
+
+	client, err := dialContainerd(*flags.ContainerdAddress, *flags.ContainerdNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("NewContainerdProvider: %v", err)
+	}
+
+	p := &containerdProvider{
+		contMap:     make(map[string]*containerdContainer),
+		events:      common.NewEventBus(),
+		client:      client,
+		root:        root,
+		runtime:     *flags.ContainerdRuntime,
+		snapshotter: *flags.ContainerdSnapshotter,
+	}
+	p.streamingRuntime = &streamingRuntime{provider: p}
+
+	p.readopt()
+
+	return p, nil
+}
+
+// readopt picks up the containers a previous vmserver left in our namespace, reattaching to the tasks still running,
+// as the pods they belong to haven't gone anywhere.  Containers without a state file aren't ours and are left alone.
+func (p *containerdProvider) readopt() {
+	var resp listContainersResponse
+	if err := p.client.call(context.Background(), "containers.v1.Containers/List", &listContainersRequest{}, &resp); err != nil {
+		glog.Warningf("readopt: couldn't list containers: %v", err)
+		return
+	}
+
+	p.mapLock.Lock()
+	defer p.mapLock.Unlock()
+
+	for _, c := range resp.Containers {
+		cont, err := p.load(c.ID)
+		if err != nil {
+			glog.Warningf("readopt: leaving %v alone: %v", c.ID, err)
+			continue
+		}
+
+		if err := p.readoptTask(cont); err != nil {
+			glog.Warningf("readopt: couldn't reattach to the task of %v: %v", c.ID, err)
+		}
+
+		glog.Infof("readopt: re-adopted %v as %v, %v", c.ID, *cont.GetId(), cont.GetState())
+		p.contMap[*cont.GetId()] = cont
+	}
+}
+
+func (p *containerdProvider) load(ctrId string) (*containerdContainer, error) {
+	dir := filepath.Join(p.root, "containers", ctrId)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, stateFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var saved savedContainer
+	if err := json.Unmarshal(data, &saved); err != nil || saved.Status == nil || saved.Spec == nil {
+		return nil, fmt.Errorf("invalid state file %v: %v", filepath.Join(dir, stateFile), err)
+	}
+
+	status := saved.Status
+	c := common.NewContainer(&status.Id,
+		&saved.PodId,
+		status.State,
+		status.Metadata,
+		status.Image,
+		status.Mounts,
+		status.Labels,
+		status.Annotations)
+	c.SetCreatedAt(status.CreatedAt)
+	c.UpdateState(status.State, status.StartedAt, status.FinishedAt, status.ExitCode, status.Reason)
+
+	return &containerdContainer{
+		Container: c,
+		ctrId:     ctrId,
+		spec:      saved.Spec,
+		dir:       dir,
+		logPath:   filepath.Join(dir, "output.log"),
+		tty:       saved.Tty,
+		stdin:     saved.Stdin,
+		exited:    make(chan struct{}),
+		removed:   make(chan struct{}),
+	}, nil
+}
+
+// readoptTask reattaches to the container's task if it's still running, and records its exit if it exited while
+// vmserver wasn't running
+func (p *containerdProvider) readoptTask(cont *containerdContainer) error {
+	if cont.GetState() != kubeapi.ContainerState_CONTAINER_RUNNING {
+		return nil
+	}
+
+	ctx := context.Background()
+
+	var resp getResponse
+	err := p.client.call(ctx, "tasks.v1.Tasks/Get", &processRequest{ContainerID: cont.ctrId}, &resp)
+	if err != nil && !isCode(err, codes.NotFound) {
+		return err
+	}
+
+	if err == nil && resp.Process != nil && resp.Process.Status != taskStatusStopped {
+		f, err := newFifos(cont.dir, "task", cont.stdin, cont.tty)
+		if err != nil {
+			return err
+		}
+		t, err := p.attachTask(cont, f)
+		if err != nil {
+			return err
+		}
+		cont.task = t
+		go p.waitTask(cont, t)
+		return nil
+	}
+
+	// the task exited, and its exit status is only still known if containerd hasn't deleted it
+	exitCode, reason := int32(-1), "Unknown"
+	if err == nil {
+		var deleted deleteResponse
+		if err := p.client.call(ctx, "tasks.v1.Tasks/Delete", &processRequest{ContainerID: cont.ctrId}, &deleted); err != nil {
+			glog.Warningf("readoptTask: %v", err)
+		} else {
+			exitCode, reason = int32(deleted.ExitStatus), exitReason(int32(deleted.ExitStatus))
+		}
+	}
+
+	cont.UpdateState(kubeapi.ContainerState_CONTAINER_EXITED, cont.GetStartedAt(), time.Now().Unix(), exitCode, reason)
+	close(cont.exited)
+	p.save(cont)
+
+	return nil
+}
+
+// save writes the container's state file, which the caller holds mapLock for
+func (p *containerdProvider) save(cont *containerdContainer) {
+	saved := &savedContainer{
+		PodId:  *cont.GetPodId(),
+		Status: cont.ToKubeStatus(),
+		Tty:    cont.tty,
+		Stdin:  cont.stdin,
+		Spec:   cont.spec,
+	}
+	// ToKubeStatus leaves out the mounts of exited containers, which don't need them
+	saved.Status.Mounts = cont.GetMounts()
+
+	data, err := json.Marshal(saved)
+	if err == nil {
+		path := filepath.Join(cont.dir, stateFile)
+		if err = ioutil.WriteFile(path+".tmp", data, 0644); err == nil {
+			err = os.Rename(path+".tmp", path)
+		}
+	}
+	if err != nil {
+		glog.Warningf("save: couldn't save the state of %v: %v", cont.ctrId, err)
+	}
+}
+
+func (p *containerdProvider) CreateContainer(req *kubeapi.CreateContainerRequest) (*kubeapi.CreateContainerResponse, error) {
+	p.mapLock.Lock()
+	defer p.mapLock.Unlock()
+
+	id := req.GetPodSandboxId() + ":" + req.Config.Metadata.GetName()
+
+	if _, ok := p.contMap[id]; ok {
+		return nil, fmt.Errorf("CreateContainer: %v already exists", id)
+	}
+
+	ctx := context.Background()
+
+	img, err := p.pullImage(ctx, req.Config.GetImage().GetImage())
+	if err != nil {
+		msg := fmt.Sprintf("CreateContainer: couldn't pull %v: %v", req.Config.GetImage().GetImage(), err)
+		glog.Info(msg)
+		return nil, errors.New(msg)
+	}
+
+	s, err := buildSpec(req, img.config)
+	if err != nil {
+		msg := fmt.Sprintf("CreateContainer: %v", err)
+		glog.Info(msg)
+		return nil, errors.New(msg)
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("CreateContainer: %v", err)
+	}
+
+	ctrId := strings.Replace(id, ":", "_", -1)
+	dir := filepath.Join(p.root, "containers", ctrId)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("CreateContainer: %v", err)
+	}
+
+	container := &apiContainer{
+		ID:          ctrId,
+		Image:       img.name,
+		Runtime:     &containerRuntime{Name: p.runtime},
+		Spec:        &any{TypeUrl: specTypeURL, Value: data},
+		Snapshotter: p.snapshotter,
+		SnapshotKey: ctrId,
+	}
+	if err := p.client.call(ctx, "containers.v1.Containers/Create", &containerMessage{Container: container}, &containerMessage{}); err != nil {
+		os.RemoveAll(dir)
+		msg := fmt.Sprintf("CreateContainer: %v", err)
+		glog.Info(msg)
+		return nil, errors.New(msg)
+	}
+
+	// the container's record refers to its snapshot, so containerd keeps it once it's prepared
+	prepare := &prepareSnapshotRequest{Snapshotter: p.snapshotter, Key: ctrId, Parent: img.chainID}
+	if err := p.client.call(ctx, "snapshots.v1.Snapshots/Prepare", prepare, &mountsResponse{}); err != nil {
+		p.client.call(ctx, "containers.v1.Containers/Delete", &deleteContainerRequest{ID: ctrId}, &empty{})
+		os.RemoveAll(dir)
+		msg := fmt.Sprintf("CreateContainer: %v", err)
+		glog.Info(msg)
+		return nil, errors.New(msg)
+	}
+
+	cont := &containerdContainer{
+		Container: common.NewContainer(&id,
+			&req.PodSandboxId,
+			kubeapi.ContainerState_CONTAINER_CREATED,
+			req.Config.Metadata,
+			req.Config.Image,
+			req.Config.Mounts,
+			req.Config.Labels,
+			req.Config.Annotations),
+		ctrId:   ctrId,
+		spec:    s,
+		dir:     dir,
+		logPath: filepath.Join(dir, "output.log"),
+		tty:     req.Config.Tty,
+		stdin:   req.Config.Stdin,
+		exited:  make(chan struct{}),
+		removed: make(chan struct{}),
+	}
+
+	p.contMap[id] = cont
+	p.save(cont)
+
+	p.events.PublishStatus(id, icommon.ContainerEventType_CREATED, cont.ToKubeStatus())
+
+	return &kubeapi.CreateContainerResponse{ContainerId: id}, nil
+}
+
+// pullImage pulls image unless containerd already has it, with the credentials configured for its registry, which are
+// read on each pull so they can be rotated
+func (p *containerdProvider) pullImage(ctx context.Context, image string) (*image, error) {
+	name, host, err := normalizeImage(image)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := newResolver(*flags.ArtifactCredentials, *flags.ArtifactAllowHTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.client.pullImage(ctx, name, host, r, p.snapshotter)
+}
+
+func (p *containerdProvider) StartContainer(req *kubeapi.StartContainerRequest) (*kubeapi.StartContainerResponse, error) {
+	p.mapLock.Lock()
+	defer p.mapLock.Unlock()
+
+	id := req.GetContainerId()
+
+	cont, ok := p.contMap[id]
+	if !ok {
+		return nil, fmt.Errorf("StartContainer: Invalid ContainerID: %v", id)
+	}
+
+	if cont.GetState() != kubeapi.ContainerState_CONTAINER_CREATED {
+		return nil, fmt.Errorf("StartContainer: %v is %v", id, cont.GetState())
+	}
+
+	t, err := p.startTask(cont)
+	if err != nil {
+		return nil, fmt.Errorf("StartContainer: couldn't start %v: %v", id, err)
+	}
+
+	cont.task = t
+	cont.Start()
+	p.save(cont)
+
+	go p.waitTask(cont, t)
+
+	p.events.PublishStatus(id, icommon.ContainerEventType_STARTED, cont.ToKubeStatus())
+
+	return &kubeapi.StartContainerResponse{}, nil
+}
+
+// startTask creates and starts the container's task on its snapshot, with its stdio connected to fifos
+func (p *containerdProvider) startTask(cont *containerdContainer) (*task, error) {
+	ctx := context.Background()
+
+	var mounts mountsResponse
+	if err := p.client.call(ctx, "snapshots.v1.Snapshots/Mounts", &snapshotRequest{Snapshotter: p.snapshotter, Key: cont.ctrId}, &mounts); err != nil {
+		return nil, err
+	}
+
+	f, err := newFifos(cont.dir, "task", cont.stdin, cont.tty)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := p.attachTask(cont, f)
+	if err != nil {
+		f.remove()
+		return nil, err
+	}
+
+	err = func() error {
+		create := &createTaskRequest{
+			ContainerID: cont.ctrId,
+			Rootfs:      mounts.Mounts,
+			Stdin:       f.stdin,
+			Stdout:      f.stdout,
+			Stderr:      f.stderr,
+			Terminal:    cont.tty,
+		}
+		if err := p.client.call(ctx, "tasks.v1.Tasks/Create", create, &createTaskResponse{}); err != nil {
+			return err
+		}
+
+		if err := p.client.call(ctx, "tasks.v1.Tasks/Start", &processRequest{ContainerID: cont.ctrId}, &startResponse{}); err != nil {
+			p.client.call(ctx, "tasks.v1.Tasks/Delete", &processRequest{ContainerID: cont.ctrId}, &deleteResponse{})
+			return err
+		}
+
+		return nil
+	}()
+	if err != nil {
+		t.finish()
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// attachTask relays the output of the container's task from its fifos to its log and whoever attaches
+func (p *containerdProvider) attachTask(cont *containerdContainer, f *fifos) (*task, error) {
+	logFile, err := os.OpenFile(cont.logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open log: %v", err)
+	}
+
+	pio, err := f.open()
+	if err != nil {
+		logFile.Close()
+		return nil, err
+	}
+
+	var stdin io.Writer
+	if pio.stdin != nil {
+		stdin = pio.stdin
+	}
+	var resize func(remotecommand.TerminalSize)
+	if cont.tty {
+		resize = func(size remotecommand.TerminalSize) {
+			p.resize(cont.ctrId, "", size)
+		}
+	}
+
+	t := &task{
+		Relay:   common.NewRelay("task "+cont.ctrId, stdin, resize),
+		fifos:   f,
+		pio:     pio,
+		logFile: logFile,
+		done:    make(chan struct{}),
+	}
+
+	logWriter := common.NewJsonLogWriter(logFile)
+	for _, output := range []struct {
+		stream string
+		file   *os.File
+	}{{"stdout", pio.stdout}, {"stderr", pio.stderr}} {
+		if output.file == nil {
+			continue
+		}
+		t.pumps.Add(1)
+		go func(stream string, file *os.File) {
+			defer t.pumps.Done()
+			t.Pump(file, stream, logWriter.Stream(stream))
+		}(output.stream, output.file)
+	}
+
+	return t, nil
+}
+
+func (p *containerdProvider) resize(ctrId string, execId string, size remotecommand.TerminalSize) {
+	req := &resizePtyRequest{ContainerID: ctrId, ExecID: execId, Width: uint32(size.Width), Height: uint32(size.Height)}
+	if err := p.client.call(context.Background(), "tasks.v1.Tasks/ResizePty", req, &empty{}); err != nil {
+		glog.Warningf("resize: %v", err)
+	}
+}
+
+// waitExit waits for the container's task, or one of its exec processes, to exit and returns its exit status.  It
+// rides out containerd restarts, which don't affect the process, failing only if the process is gone or ctx is done.
+func (p *containerdProvider) waitExit(ctx context.Context, ctrId string, execId string) (int32, error) {
+	for {
+		var resp waitResponse
+		err := p.client.call(ctx, "tasks.v1.Tasks/Wait", &processRequest{ContainerID: ctrId, ExecID: execId}, &resp)
+		if err == nil {
+			return int32(resp.ExitStatus), nil
+		}
+		if isCode(err, codes.NotFound) || ctx.Err() != nil {
+			return -1, err
+		}
+
+		glog.Warningf("waitExit: %v, retrying", err)
+		select {
+		case <-ctx.Done():
+			return -1, ctx.Err()
+		case <-time.After(waitRetryInterval):
+		}
+	}
+}
+
+func exitReason(exitCode int32) string {
+	if exitCode != 0 {
+		return "Error"
+	}
+	return "Completed"
+}
+
+// waitTask records how the container's task exited
+func (p *containerdProvider) waitTask(cont *containerdContainer, t *task) {
+	exitCode, err := p.waitExit(context.Background(), cont.ctrId, "")
+	if err != nil {
+		glog.Warningf("waitTask: %v", err)
+	}
+
+	// deleting the task waits for the shim to have written all its output to the fifos
+	if err := p.client.call(context.Background(), "tasks.v1.Tasks/Delete", &processRequest{ContainerID: cont.ctrId}, &deleteResponse{}); err != nil && !isCode(err, codes.NotFound) {
+		glog.Warningf("waitTask: %v", err)
+	}
+	t.finish()
+
+	glog.Infof("task %v exited with %v", cont.ctrId, exitCode)
+
+	p.mapLock.Lock()
+	defer p.mapLock.Unlock()
+
+	cont.UpdateState(kubeapi.ContainerState_CONTAINER_EXITED, cont.GetStartedAt(), time.Now().Unix(), exitCode, exitReason(exitCode))
+	p.save(cont)
+
+	p.events.PublishStatus(*cont.GetId(), icommon.ContainerEventType_STOPPED, cont.ToKubeStatus())
+
+	close(cont.exited)
+}
+
+// stopTask sends the task SIGTERM, and SIGKILL if it hasn't exited after timeout
+func (p *containerdProvider) stopTask(cont *containerdContainer, t *task, timeout int64) {
+	if !t.Running() {
+		<-cont.exited
+		return
+	}
+
+	if timeout <= 0 {
+		timeout = defaultStopTimeout
+	}
+
+	kill := func(sig syscall.Signal) {
+		req := &killRequest{ContainerID: cont.ctrId, Signal: uint32(sig), All: sig == syscall.SIGKILL}
+		if err := p.client.call(context.Background(), "tasks.v1.Tasks/Kill", req, &empty{}); err != nil && !isCode(err, codes.NotFound) {
+			glog.Warningf("stopTask: %v", err)
+		}
+	}
+
+	kill(syscall.SIGTERM)
+
+	select {
+	case <-cont.exited:
+		return
+	case <-time.After(time.Duration(timeout) * time.Second):
+	}
+
+	glog.Warningf("task %v didn't stop within %vs, killing it", cont.ctrId, timeout)
+	kill(syscall.SIGKILL)
+
+	<-cont.exited
+}
+
+func (p *containerdProvider) StopContainer(req *kubeapi.StopContainerRequest) (*kubeapi.StopContainerResponse, error) {
+	id := req.GetContainerId()
+	cont, task, err := p.lookup(id)
+	if err != nil {
+		return nil, fmt.Errorf("StopContainer: %v", err)
+	}
+
+	// waitTask takes mapLock to record the exit, so we can't hold it while the task stops
+	if task != nil {
+		p.stopTask(cont, task, req.Timeout)
+	}
+
+	return &kubeapi.StopContainerResponse{}, nil
+}
+
+func (p *containerdProvider) RemoveContainer(req *kubeapi.RemoveContainerRequest) (*kubeapi.RemoveContainerResponse, error) {
+	id := req.GetContainerId()
+	cont, task, err := p.lookup(id)
+	if err != nil {
+		return nil, fmt.Errorf("RemoveContainer: %v", err)
+	}
+
+	if task != nil {
+		p.stopTask(cont, task, 0)
+	}
+
+	p.mapLock.Lock()
+	defer p.mapLock.Unlock()
+
+	if _, ok := p.contMap[id]; !ok {
+		return nil, fmt.Errorf("RemoveContainer: Invalid ContainerID: %v", id)
+	}
+
+	ctx := context.Background()
+	if err := p.client.call(ctx, "containers.v1.Containers/Delete", &deleteContainerRequest{ID: cont.ctrId}, &empty{}); err != nil && !isCode(err, codes.NotFound) {
+		return nil, fmt.Errorf("RemoveContainer: %v", err)
+	}
+	remove := &snapshotRequest{Snapshotter: p.snapshotter, Key: cont.ctrId}
+	if err := p.client.call(ctx, "snapshots.v1.Snapshots/Remove", remove, &empty{}); err != nil && !isCode(err, codes.NotFound) {
+		glog.Warningf("RemoveContainer: %v", err)
+	}
+
+	if err := os.RemoveAll(cont.dir); err != nil {
+		glog.Warningf("RemoveContainer: couldn't remove %v: %v", cont.dir, err)
+	}
+
+	close(cont.removed)
+	delete(p.contMap, id)
+
+	p.events.PublishStatus(id, icommon.ContainerEventType_REMOVED, nil)
+
+	return &kubeapi.RemoveContainerResponse{}, nil
+}
+
+func (p *containerdProvider) ListContainers(req *kubeapi.ListContainersRequest) (*kubeapi.ListContainersResponse, error) {
+	p.mapLock.Lock()
+	defer p.mapLock.Unlock()
+
+	containers := []*kubeapi.Container{}
+
+	for _, cont := range p.contMap {
+		if filter(req.Filter, cont.Container) {
+			continue
+		}
+
+		containers = append(containers, cont.ToKubeContainer())
+	}
+
+	resp := &kubeapi.ListContainersResponse{
+		Containers: containers,
+	}
+
+	return resp, nil
+}
+
+func filter(filter *kubeapi.ContainerFilter, cont *common.Container) bool {
+	if filter != nil {
+		if filter.GetId() != "" && filter.GetId() != *cont.GetId() {
+			glog.Infof("Filtering out %v as want %v", *cont.GetId(), filter.GetId())
+			return true
+		}
+
+		if filter.GetState() != nil && filter.GetState().State != cont.GetState() {
+			glog.Infof("Filtering out %v as want %v and got %v", *cont.GetId(), filter.GetState(), cont.GetState())
+			return true
+		}
+
+		if filter.GetPodSandboxId() != "" && filter.GetPodSandboxId() != *cont.GetPodId() {
+			glog.Infof("Filtering out %v as want %v and got %v", *cont.GetId(), filter.GetPodSandboxId(), *cont.GetPodId())
+			return true
+		}
+
+		for k, v := range filter.GetLabelSelector() {
+			if podVal, ok := cont.GetLabels()[k]; !ok {
+				glog.Infof("didn't find key %v in local labels: %+v", k, cont.GetLabels())
+			} else {
+				if podVal != v {
+					glog.Infof("Filtering out %v as want labels[%v] = %v and got %v", *cont.GetId(), k, v, podVal)
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+func (p *containerdProvider) ContainerStatus(req *kubeapi.ContainerStatusRequest) (*kubeapi.ContainerStatusResponse, error) {
+	p.mapLock.Lock()
+	defer p.mapLock.Unlock()
+
+	id := req.GetContainerId()
+
+	cont, ok := p.contMap[id]
+	if !ok {
+		return nil, fmt.Errorf("ContainerStatus: Invalid ContainerID: %v", id)
+	}
+
+	resp := &kubeapi.ContainerStatusResponse{
+		Status: cont.ToKubeStatus(),
+	}
+
+	return resp, nil
+}
+
+// lookup returns the container with the given id, or whose name is id as infranetes asks for logs by name, and its
+// task if it was started
+func (p *containerdProvider) lookup(id string) (*containerdContainer, *task, error) {
+	p.mapLock.Lock()
+	defer p.mapLock.Unlock()
+
+	if cont, ok := p.contMap[id]; ok {
+		return cont, cont.task, nil
+	}
+
+	for _, cont := range p.contMap {
+		if cont.GetMetadata().GetName() == id {
+			return cont, cont.task, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("Invalid ContainerID: %v", id)
+}
+
+func (p *containerdProvider) Features() []string {
+	return []string{icommon.FeatureExecSync, icommon.FeatureExec, icommon.FeatureAttach, icommon.FeaturePortForward, icommon.FeatureLogs}
+}
+
+func (p *containerdProvider) Events() *common.EventBus {
+	return p.events
+}
+
+func (p *containerdProvider) Ready() error {
+	if err := p.client.call(context.Background(), "version.v1.Version/Version", &empty{}, &versionResponse{}); err != nil {
+		return fmt.Errorf("containerd not available: %v", err)
+	}
+
+	return nil
+}
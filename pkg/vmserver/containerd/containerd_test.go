@@ -0,0 +1,312 @@
+package containerd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/apporbit/infranetes/cmd/vmserver/flags"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+// TestContainerLifecycle runs a container through a local containerd.  It needs root and is skipped unless
+// INFRANETES_CONTAINERD_ADDRESS names containerd's socket.
+func TestContainerLifecycle(t *testing.T) {
+	address := os.Getenv("INFRANETES_CONTAINERD_ADDRESS")
+	if address == "" {
+		t.Skip("INFRANETES_CONTAINERD_ADDRESS not set")
+	}
+
+	root, err := ioutil.TempDir("", "containerd-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	*flags.ContainerdAddress = address
+	*flags.ContainerdNamespace = "infranetes-test"
+	*flags.ContainerdRoot = root
+
+	provider, err := NewContainerdProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := provider.(*containerdProvider)
+
+	if err := p.Ready(); err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := p.CreateContainer(testRequest(&kubeapi.ContainerConfig{
+		Image:   &kubeapi.ImageSpec{Image: "busybox"},
+		Command: []string{"sh", "-c", "echo started; sleep 300"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.ContainerId
+	defer p.RemoveContainer(&kubeapi.RemoveContainerRequest{ContainerId: id})
+
+	if _, err := p.StartContainer(&kubeapi.StartContainerRequest{ContainerId: id}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := p.ExecSync(&kubeapi.ExecSyncRequest{ContainerId: id, Cmd: []string{"cat", "/etc/hostname"}, Timeout: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostname, _ := os.Hostname()
+	if resp.ExitCode != 0 || strings.TrimSpace(string(resp.Stdout)) != hostname {
+		t.Errorf("exec = %v %q, want the VM's hostname %q", resp.ExitCode, resp.Stdout, hostname)
+	}
+
+	if _, err := p.StopContainer(&kubeapi.StopContainerRequest{ContainerId: id, Timeout: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := p.ContainerStatus(&kubeapi.ContainerStatusRequest{ContainerId: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status.State != kubeapi.ContainerState_CONTAINER_EXITED {
+		t.Errorf("state = %v, want exited", status.Status.State)
+	}
+
+	log, err := ioutil.ReadFile(p.contMap[id].logPath)
+	if err != nil || !strings.Contains(string(log), "started") {
+		t.Errorf("log = %q, %v", log, err)
+	}
+
+	if _, err := p.RemoveContainer(&kubeapi.RemoveContainerRequest{ContainerId: id}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestProvider starts a provider on fake, keeping its state in root
+func newTestProvider(t *testing.T, fake *fakeContainerd, root string) *containerdProvider {
+	*flags.ContainerdAddress = fake.address
+	*flags.ContainerdNamespace = "infranetes-test"
+	*flags.ContainerdRoot = root
+	*flags.ArtifactAllowHTTP = true
+
+	provider, err := NewContainerdProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return provider.(*containerdProvider)
+}
+
+func waitExited(t *testing.T, cont *containerdContainer) {
+	select {
+	case <-cont.exited:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("%v didn't exit", cont.ctrId)
+	}
+}
+
+func TestContainerdProvider(t *testing.T) {
+	registry := newTestRegistry("", "")
+	defer registry.Close()
+
+	fake := newFakeContainerd(t)
+	defer fake.stop()
+
+	root, err := ioutil.TempDir("", "containerd-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	// the task says it started, an exec process writes to both outputs and fails
+	fake.started = func(id string, proc *fakeProcess) {
+		if id == processKey("pod_app", "") {
+			proc.write(t, proc.stdout, "started\n")
+			return
+		}
+		proc.write(t, proc.stdout, "out")
+		proc.write(t, proc.stderr, "err")
+		fake.lock.Lock()
+		proc.exit(3)
+		fake.lock.Unlock()
+	}
+
+	p := newTestProvider(t, fake, root)
+
+	if err := p.Ready(); err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := p.CreateContainer(testRequest(&kubeapi.ContainerConfig{
+		Image: &kubeapi.ImageSpec{Image: registry.host() + "/app:v1"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.ContainerId
+
+	fake.lock.Lock()
+	if s := fake.snapshots["pod_app"]; s == nil || s.committed || s.parent != chainIDs(registry.diffIDs)[1] {
+		t.Errorf("container snapshot = %+v, want an active one on the image", s)
+	}
+	fake.lock.Unlock()
+
+	if _, err := p.StartContainer(&kubeapi.StartContainerRequest{ContainerId: id}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := p.ExecSync(&kubeapi.ExecSyncRequest{ContainerId: id, Cmd: []string{"false"}, Timeout: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ExitCode != 3 || string(resp.Stdout) != "out" || string(resp.Stderr) != "err" {
+		t.Errorf("exec = %v %q %q, want 3 \"out\" \"err\"", resp.ExitCode, resp.Stdout, resp.Stderr)
+	}
+
+	fake.lock.Lock()
+	if len(fake.processes) != 1 {
+		t.Errorf("exec process wasn't deleted: %v", fake.processes)
+	}
+	fake.lock.Unlock()
+
+	if _, err := p.StopContainer(&kubeapi.StopContainerRequest{ContainerId: id, Timeout: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := p.ContainerStatus(&kubeapi.ContainerStatusRequest{ContainerId: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status.State != kubeapi.ContainerState_CONTAINER_EXITED || status.Status.ExitCode != 128+int32(syscall.SIGTERM) {
+		t.Errorf("status = %v %v, want exited by SIGTERM", status.Status.State, status.Status.ExitCode)
+	}
+
+	log, err := ioutil.ReadFile(p.contMap[id].logPath)
+	if err != nil || !strings.Contains(string(log), "started") {
+		t.Errorf("log = %q, %v", log, err)
+	}
+
+	if _, err := p.RemoveContainer(&kubeapi.RemoveContainerRequest{ContainerId: id}); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	if len(fake.containers) != 0 || fake.snapshots["pod_app"] != nil {
+		t.Errorf("containers %v and snapshot %v weren't removed", fake.containers, fake.snapshots["pod_app"])
+	}
+	if _, err := os.Stat(filepath.Join(root, "containers", "pod_app")); !os.IsNotExist(err) {
+		t.Errorf("container dir wasn't removed: %v", err)
+	}
+}
+
+// saveState writes the state file a previous vmserver would have left for a running container called name
+func saveState(t *testing.T, fake *fakeContainerd, root string, name string) string {
+	ctrId := "pod_" + name
+	dir := filepath.Join(root, "containers", ctrId)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := json.Marshal(&savedContainer{
+		PodId: "pod",
+		Status: &kubeapi.ContainerStatus{
+			Id:        "pod:" + name,
+			Metadata:  &kubeapi.ContainerMetadata{Name: name},
+			Image:     &kubeapi.ImageSpec{Image: "app"},
+			State:     kubeapi.ContainerState_CONTAINER_RUNNING,
+			StartedAt: 1,
+		},
+		Spec: &spec{},
+	})
+	if err := ioutil.WriteFile(filepath.Join(dir, stateFile), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.lock.Lock()
+	fake.containers[ctrId] = &apiContainer{ID: ctrId}
+	fake.lock.Unlock()
+
+	return dir
+}
+
+func TestReadopt(t *testing.T) {
+	fake := newFakeContainerd(t)
+	defer fake.stop()
+
+	root, err := ioutil.TempDir("", "containerd-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	running := fake.runningTask("pod_running", saveState(t, fake, root, "running"))
+
+	stopped := fake.runningTask("pod_stopped", saveState(t, fake, root, "stopped"))
+	fake.lock.Lock()
+	stopped.exit(2)
+	fake.lock.Unlock()
+
+	// its task was deleted while vmserver was down
+	saveState(t, fake, root, "gone")
+
+	// another client's container, which has no state file
+	fake.runningTask("foreign", root)
+	fake.containers["foreign"] = &apiContainer{ID: "foreign"}
+
+	p := newTestProvider(t, fake, root)
+
+	if len(p.contMap) != 3 {
+		t.Fatalf("re-adopted %v, want 3 containers", len(p.contMap))
+	}
+
+	for id, want := range map[string]struct {
+		exitCode int32
+		reason   string
+	}{"pod:stopped": {2, "Error"}, "pod:gone": {-1, "Unknown"}} {
+		status := p.contMap[id].ToKubeStatus()
+		if status.State != kubeapi.ContainerState_CONTAINER_EXITED || status.ExitCode != want.exitCode || status.Reason != want.reason {
+			t.Errorf("%v = %v %v %v, want exited %v %v", id, status.State, status.ExitCode, status.Reason, want.exitCode, want.reason)
+		}
+	}
+
+	cont := p.contMap["pod:running"]
+	if cont.GetState() != kubeapi.ContainerState_CONTAINER_RUNNING || cont.task == nil {
+		t.Fatalf("pod:running = %v, want running with its task reattached", cont.GetState())
+	}
+
+	running.write(t, running.stdout, "after restart\n")
+	fake.lock.Lock()
+	running.exit(0)
+	fake.lock.Unlock()
+	waitExited(t, cont)
+
+	if status := cont.ToKubeStatus(); status.State != kubeapi.ContainerState_CONTAINER_EXITED || status.ExitCode != 0 {
+		t.Errorf("pod:running = %v %v, want exited 0", status.State, status.ExitCode)
+	}
+	log, err := ioutil.ReadFile(cont.logPath)
+	if err != nil || !strings.Contains(string(log), "after restart") {
+		t.Errorf("log = %q, %v", log, err)
+	}
+
+	// what was recorded survives another restart
+	p = newTestProvider(t, fake, root)
+	if status := p.contMap["pod:running"].ToKubeStatus(); status.State != kubeapi.ContainerState_CONTAINER_EXITED || status.StartedAt != 1 {
+		t.Errorf("pod:running = %v started at %v, want exited and started at 1", status.State, status.StartedAt)
+	}
+
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	if fake.containers["foreign"] == nil || fake.processes[processKey("foreign", "")] == nil || len(fake.kills) != 0 {
+		t.Errorf("foreign container was touched: kills %v", fake.kills)
+	}
+	if fake.processes[processKey("pod_stopped", "")] != nil {
+		t.Errorf("stopped task wasn't deleted")
+	}
+}
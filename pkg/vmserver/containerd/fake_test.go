@@ -0,0 +1,501 @@
+package containerd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// fakeContainerd serves the parts of containerd's grpc api the provider uses, keeping everything in memory.  Tasks
+// don't run anything: a test plays their part through their fifos and exit.
+type fakeContainerd struct {
+	t       *testing.T
+	dir     string
+	address string
+	server  *grpc.Server
+
+	lock       sync.Mutex
+	content    map[string][]byte
+	labels     map[string]map[string]string
+	ingests    map[string]*bytes.Buffer
+	leases     map[string]bool
+	images     map[string]*apiImage
+	snapshots  map[string]*fakeSnapshot
+	containers map[string]*apiContainer
+	// processes are keyed by container id and exec id, "" for the task
+	processes map[string]*fakeProcess
+	kills     []string
+
+	// started is called as a process starts
+	started func(id string, p *fakeProcess)
+}
+
+type fakeSnapshot struct {
+	parent    string
+	committed bool
+}
+
+type fakeProcess struct {
+	stdin    string
+	stdout   string
+	stderr   string
+	terminal bool
+	spec     *any
+
+	status     int32
+	exitStatus uint32
+	exited     chan struct{}
+}
+
+func newFakeProcess(stdin, stdout, stderr string, terminal bool) *fakeProcess {
+	return &fakeProcess{stdin: stdin, stdout: stdout, stderr: stderr, terminal: terminal, status: taskStatusCreated, exited: make(chan struct{})}
+}
+
+// exit ends the process, which the caller holds fake.lock for
+func (p *fakeProcess) exit(status uint32) {
+	if p.status == taskStatusStopped {
+		return
+	}
+	p.status = taskStatusStopped
+	p.exitStatus = status
+	close(p.exited)
+}
+
+// write writes data to one of the process's output fifos, as the shim would
+func (p *fakeProcess) write(t *testing.T, fifo string, data string) {
+	f, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+	if err != nil {
+		t.Errorf("couldn't open %v: %v", fifo, err)
+		return
+	}
+	defer f.Close()
+	f.Write([]byte(data))
+}
+
+func processKey(ctrId string, execId string) string {
+	return ctrId + "/" + execId
+}
+
+type fakeMethod struct {
+	request func() proto.Message
+	handle  func(req proto.Message) (proto.Message, error)
+}
+
+func newFakeContainerd(t *testing.T) *fakeContainerd {
+	dir, err := ioutil.TempDir("", "fake-containerd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeContainerd{
+		t:          t,
+		dir:        dir,
+		address:    filepath.Join(dir, "containerd.sock"),
+		server:     grpc.NewServer(),
+		content:    make(map[string][]byte),
+		labels:     make(map[string]map[string]string),
+		ingests:    make(map[string]*bytes.Buffer),
+		leases:     make(map[string]bool),
+		images:     make(map[string]*apiImage),
+		snapshots:  make(map[string]*fakeSnapshot),
+		containers: make(map[string]*apiContainer),
+		processes:  make(map[string]*fakeProcess),
+	}
+
+	services := make(map[string]*grpc.ServiceDesc)
+	for method, m := range f.methods() {
+		parts := strings.SplitN(method, "/", 2)
+		sd, ok := services[parts[0]]
+		if !ok {
+			sd = &grpc.ServiceDesc{ServiceName: "containerd.services." + parts[0], HandlerType: (*interface{})(nil)}
+			services[parts[0]] = sd
+		}
+		sd.Methods = append(sd.Methods, grpc.MethodDesc{MethodName: parts[1], Handler: f.unary(m)})
+	}
+	content := services["content.v1.Content"]
+	content.Streams = []grpc.StreamDesc{
+		{StreamName: "Read", Handler: f.read, ServerStreams: true},
+		{StreamName: "Write", Handler: f.write, ServerStreams: true, ClientStreams: true},
+	}
+	for _, sd := range services {
+		f.server.RegisterService(sd, f)
+	}
+
+	l, err := net.Listen("unix", f.address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go f.server.Serve(l)
+
+	return f
+}
+
+func (f *fakeContainerd) stop() {
+	f.server.Stop()
+	os.RemoveAll(f.dir)
+}
+
+func (f *fakeContainerd) checkNamespace(ctx context.Context) error {
+	md, _ := metadata.FromContext(ctx)
+	if ns := md[namespaceHeader]; len(ns) != 1 || ns[0] == "" {
+		return grpc.Errorf(codes.InvalidArgument, "no namespace in %v", md)
+	}
+	return nil
+}
+
+func (f *fakeContainerd) unary(m fakeMethod) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(_ interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+		if err := f.checkNamespace(ctx); err != nil {
+			return nil, err
+		}
+		req := m.request()
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		// the tasks' process methods take the lock themselves, as Wait blocks until the process exits
+		if _, ok := req.(*processRequest); !ok {
+			f.lock.Lock()
+			defer f.lock.Unlock()
+		}
+		return m.handle(req)
+	}
+}
+
+func notFound(what string) error {
+	return grpc.Errorf(codes.NotFound, "%v not found", what)
+}
+
+func (f *fakeContainerd) methods() map[string]fakeMethod {
+	newEmpty := func() proto.Message { return &empty{} }
+	newProcess := func() proto.Message { return &processRequest{} }
+
+	// process looks a process up, with f.lock held
+	process := func(req proto.Message) (*fakeProcess, error) {
+		r := req.(*processRequest)
+		p, ok := f.processes[processKey(r.ContainerID, r.ExecID)]
+		if !ok {
+			return nil, notFound("process " + processKey(r.ContainerID, r.ExecID))
+		}
+		return p, nil
+	}
+	locked := func(handle func(req proto.Message) (proto.Message, error)) func(req proto.Message) (proto.Message, error) {
+		return func(req proto.Message) (proto.Message, error) {
+			f.lock.Lock()
+			defer f.lock.Unlock()
+			return handle(req)
+		}
+	}
+
+	return map[string]fakeMethod{
+		"version.v1.Version/Version": {newEmpty, func(proto.Message) (proto.Message, error) {
+			return &versionResponse{Version: "fake"}, nil
+		}},
+
+		"leases.v1.Leases/Create": {func() proto.Message { return &createLeaseRequest{} }, func(req proto.Message) (proto.Message, error) {
+			id := req.(*createLeaseRequest).ID
+			f.leases[id] = true
+			return &createLeaseResponse{Lease: &lease{ID: id}}, nil
+		}},
+		"leases.v1.Leases/Delete": {func() proto.Message { return &deleteLeaseRequest{} }, func(req proto.Message) (proto.Message, error) {
+			delete(f.leases, req.(*deleteLeaseRequest).ID)
+			return &empty{}, nil
+		}},
+
+		"images.v1.Images/Get": {func() proto.Message { return &getImageRequest{} }, func(req proto.Message) (proto.Message, error) {
+			img, ok := f.images[req.(*getImageRequest).Name]
+			if !ok {
+				return nil, notFound("image")
+			}
+			return &imageMessage{Image: img}, nil
+		}},
+		"images.v1.Images/Create": {func() proto.Message { return &imageMessage{} }, func(req proto.Message) (proto.Message, error) {
+			img := req.(*imageMessage).Image
+			if _, ok := f.images[img.Name]; ok {
+				return nil, grpc.Errorf(codes.AlreadyExists, "image %v exists", img.Name)
+			}
+			f.images[img.Name] = img
+			return &imageMessage{Image: img}, nil
+		}},
+
+		"content.v1.Content/Info": {func() proto.Message { return &infoRequest{} }, func(req proto.Message) (proto.Message, error) {
+			digest := req.(*infoRequest).Digest
+			data, ok := f.content[digest]
+			if !ok {
+				return nil, notFound("content " + digest)
+			}
+			return &infoResponse{Info: &contentInfo{Digest: digest, Size: int64(len(data))}}, nil
+		}},
+		"content.v1.Content/Abort": {func() proto.Message { return &abortRequest{} }, func(req proto.Message) (proto.Message, error) {
+			delete(f.ingests, req.(*abortRequest).Ref)
+			return &empty{}, nil
+		}},
+
+		"snapshots.v1.Snapshots/Stat": {func() proto.Message { return &snapshotRequest{} }, func(req proto.Message) (proto.Message, error) {
+			if _, ok := f.snapshots[req.(*snapshotRequest).Key]; !ok {
+				return nil, notFound("snapshot")
+			}
+			return &empty{}, nil
+		}},
+		"snapshots.v1.Snapshots/Prepare": {func() proto.Message { return &prepareSnapshotRequest{} }, func(req proto.Message) (proto.Message, error) {
+			r := req.(*prepareSnapshotRequest)
+			if _, ok := f.snapshots[r.Key]; ok {
+				return nil, grpc.Errorf(codes.AlreadyExists, "snapshot %v exists", r.Key)
+			}
+			if parent, ok := f.snapshots[r.Parent]; r.Parent != "" && (!ok || !parent.committed) {
+				return nil, notFound("parent " + r.Parent)
+			}
+			f.snapshots[r.Key] = &fakeSnapshot{parent: r.Parent}
+			return &mountsResponse{Mounts: []*apiMount{{Type: "bind", Source: "/snapshots/" + r.Key}}}, nil
+		}},
+		"snapshots.v1.Snapshots/Mounts": {func() proto.Message { return &snapshotRequest{} }, func(req proto.Message) (proto.Message, error) {
+			key := req.(*snapshotRequest).Key
+			if _, ok := f.snapshots[key]; !ok {
+				return nil, notFound("snapshot")
+			}
+			return &mountsResponse{Mounts: []*apiMount{{Type: "bind", Source: "/snapshots/" + key}}}, nil
+		}},
+		"snapshots.v1.Snapshots/Commit": {func() proto.Message { return &commitSnapshotRequest{} }, func(req proto.Message) (proto.Message, error) {
+			r := req.(*commitSnapshotRequest)
+			s, ok := f.snapshots[r.Key]
+			if !ok || s.committed {
+				return nil, notFound("active snapshot " + r.Key)
+			}
+			if _, ok := f.snapshots[r.Name]; ok {
+				return nil, grpc.Errorf(codes.AlreadyExists, "snapshot %v exists", r.Name)
+			}
+			delete(f.snapshots, r.Key)
+			f.snapshots[r.Name] = &fakeSnapshot{parent: s.parent, committed: true}
+			return &empty{}, nil
+		}},
+		"snapshots.v1.Snapshots/Remove": {func() proto.Message { return &snapshotRequest{} }, func(req proto.Message) (proto.Message, error) {
+			key := req.(*snapshotRequest).Key
+			if _, ok := f.snapshots[key]; !ok {
+				return nil, notFound("snapshot")
+			}
+			delete(f.snapshots, key)
+			return &empty{}, nil
+		}},
+
+		// applying a layer reports the digest of its uncompressed content, which is all the provider checks
+		"diff.v1.Diff/Apply": {func() proto.Message { return &applyRequest{} }, func(req proto.Message) (proto.Message, error) {
+			r := req.(*applyRequest)
+			data, ok := f.content[r.Diff.Digest]
+			if !ok {
+				return nil, notFound("content " + r.Diff.Digest)
+			}
+			zr, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, grpc.Errorf(codes.InvalidArgument, "%v", err)
+			}
+			uncompressed, err := ioutil.ReadAll(zr)
+			if err != nil {
+				return nil, grpc.Errorf(codes.InvalidArgument, "%v", err)
+			}
+			return &applyResponse{Applied: &apiDescriptor{Digest: digestOf(uncompressed)}}, nil
+		}},
+
+		"containers.v1.Containers/Create": {func() proto.Message { return &containerMessage{} }, func(req proto.Message) (proto.Message, error) {
+			c := req.(*containerMessage).Container
+			if _, ok := f.containers[c.ID]; ok {
+				return nil, grpc.Errorf(codes.AlreadyExists, "container %v exists", c.ID)
+			}
+			f.containers[c.ID] = c
+			return &containerMessage{Container: c}, nil
+		}},
+		"containers.v1.Containers/List": {func() proto.Message { return &listContainersRequest{} }, func(proto.Message) (proto.Message, error) {
+			resp := &listContainersResponse{}
+			for _, c := range f.containers {
+				resp.Containers = append(resp.Containers, c)
+			}
+			return resp, nil
+		}},
+		"containers.v1.Containers/Delete": {func() proto.Message { return &deleteContainerRequest{} }, func(req proto.Message) (proto.Message, error) {
+			id := req.(*deleteContainerRequest).ID
+			if _, ok := f.containers[id]; !ok {
+				return nil, notFound("container")
+			}
+			if _, ok := f.processes[processKey(id, "")]; ok {
+				return nil, grpc.Errorf(codes.FailedPrecondition, "container %v has a task", id)
+			}
+			delete(f.containers, id)
+			return &empty{}, nil
+		}},
+
+		"tasks.v1.Tasks/Create": {func() proto.Message { return &createTaskRequest{} }, func(req proto.Message) (proto.Message, error) {
+			r := req.(*createTaskRequest)
+			if _, ok := f.containers[r.ContainerID]; !ok {
+				return nil, notFound("container")
+			}
+			if len(r.Rootfs) == 0 {
+				return nil, grpc.Errorf(codes.InvalidArgument, "no rootfs")
+			}
+			f.processes[processKey(r.ContainerID, "")] = newFakeProcess(r.Stdin, r.Stdout, r.Stderr, r.Terminal)
+			return &createTaskResponse{ContainerID: r.ContainerID, Pid: 1}, nil
+		}},
+		"tasks.v1.Tasks/Exec": {func() proto.Message { return &execProcessRequest{} }, func(req proto.Message) (proto.Message, error) {
+			r := req.(*execProcessRequest)
+			if _, ok := f.processes[processKey(r.ContainerID, "")]; !ok {
+				return nil, notFound("task")
+			}
+			p := newFakeProcess(r.Stdin, r.Stdout, r.Stderr, r.Terminal)
+			p.spec = r.Spec
+			f.processes[processKey(r.ContainerID, r.ExecID)] = p
+			return &empty{}, nil
+		}},
+		"tasks.v1.Tasks/Start": {newProcess, locked(func(req proto.Message) (proto.Message, error) {
+			p, err := process(req)
+			if err != nil {
+				return nil, err
+			}
+			p.status = taskStatusRunning
+			if f.started != nil {
+				r := req.(*processRequest)
+				go f.started(processKey(r.ContainerID, r.ExecID), p)
+			}
+			return &startResponse{Pid: 2}, nil
+		})},
+		"tasks.v1.Tasks/Get": {newProcess, locked(func(req proto.Message) (proto.Message, error) {
+			p, err := process(req)
+			if err != nil {
+				return nil, err
+			}
+			return &getResponse{Process: &apiProcess{Status: p.status, Stdout: p.stdout, ExitStatus: p.exitStatus}}, nil
+		})},
+		"tasks.v1.Tasks/Wait": {newProcess, func(req proto.Message) (proto.Message, error) {
+			f.lock.Lock()
+			p, err := process(req)
+			f.lock.Unlock()
+			if err != nil {
+				return nil, err
+			}
+			<-p.exited
+			return &waitResponse{ExitStatus: p.exitStatus}, nil
+		}},
+		"tasks.v1.Tasks/Delete": {newProcess, locked(func(req proto.Message) (proto.Message, error) {
+			p, err := process(req)
+			if err != nil {
+				return nil, err
+			}
+			if p.status != taskStatusStopped {
+				return nil, grpc.Errorf(codes.FailedPrecondition, "task is running")
+			}
+			r := req.(*processRequest)
+			delete(f.processes, processKey(r.ContainerID, ""))
+			return &deleteResponse{ExitStatus: p.exitStatus}, nil
+		})},
+		"tasks.v1.Tasks/DeleteProcess": {newProcess, locked(func(req proto.Message) (proto.Message, error) {
+			p, err := process(req)
+			if err != nil {
+				return nil, err
+			}
+			r := req.(*processRequest)
+			delete(f.processes, processKey(r.ContainerID, r.ExecID))
+			return &deleteResponse{ExitStatus: p.exitStatus}, nil
+		})},
+		"tasks.v1.Tasks/Kill": {func() proto.Message { return &killRequest{} }, func(req proto.Message) (proto.Message, error) {
+			r := req.(*killRequest)
+			p, ok := f.processes[processKey(r.ContainerID, r.ExecID)]
+			if !ok {
+				return nil, notFound("process")
+			}
+			f.kills = append(f.kills, processKey(r.ContainerID, r.ExecID))
+			p.exit(128 + r.Signal)
+			return &empty{}, nil
+		}},
+		"tasks.v1.Tasks/ResizePty": {func() proto.Message { return &resizePtyRequest{} }, func(proto.Message) (proto.Message, error) {
+			return &empty{}, nil
+		}},
+	}
+}
+
+func (f *fakeContainerd) read(_ interface{}, stream grpc.ServerStream) error {
+	if err := f.checkNamespace(stream.Context()); err != nil {
+		return err
+	}
+
+	var req readContentRequest
+	if err := stream.RecvMsg(&req); err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	data, ok := f.content[req.Digest]
+	f.lock.Unlock()
+	if !ok {
+		return notFound("content " + req.Digest)
+	}
+
+	return stream.SendMsg(&readContentResponse{Data: data})
+}
+
+func (f *fakeContainerd) write(_ interface{}, stream grpc.ServerStream) error {
+	if err := f.checkNamespace(stream.Context()); err != nil {
+		return err
+	}
+
+	for {
+		var req writeContentRequest
+		if err := stream.RecvMsg(&req); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		f.lock.Lock()
+		ingest, ok := f.ingests[req.Ref]
+		if !ok {
+			ingest = &bytes.Buffer{}
+			f.ingests[req.Ref] = ingest
+		}
+		if req.Offset != int64(ingest.Len()) {
+			f.lock.Unlock()
+			return grpc.Errorf(codes.InvalidArgument, "write at %v of %v bytes", req.Offset, ingest.Len())
+		}
+		ingest.Write(req.Data)
+
+		if req.Action == writeActionCommit {
+			delete(f.ingests, req.Ref)
+			digest := digestOf(ingest.Bytes())
+			if digest != req.Expected {
+				f.lock.Unlock()
+				return grpc.Errorf(codes.FailedPrecondition, "unexpected digest %v, want %v", digest, req.Expected)
+			}
+			if _, ok := f.content[digest]; ok {
+				f.lock.Unlock()
+				return grpc.Errorf(codes.AlreadyExists, "content %v exists", digest)
+			}
+			f.content[digest] = ingest.Bytes()
+			f.labels[digest] = req.Labels
+		}
+		offset := int64(ingest.Len())
+		f.lock.Unlock()
+
+		if err := stream.SendMsg(&writeContentResponse{Action: req.Action, Offset: offset}); err != nil {
+			return err
+		}
+	}
+}
+
+// runningTask adds a running task of container ctrId, with its fifos in dir as the provider names them
+func (f *fakeContainerd) runningTask(ctrId string, dir string) *fakeProcess {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	p := newFakeProcess("", filepath.Join(dir, "task-stdout"), filepath.Join(dir, "task-stderr"), false)
+	p.status = taskStatusRunning
+	f.processes[processKey(ctrId, "")] = p
+	return p
+}
@@ -0,0 +1,107 @@
+package containerd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// how long to keep reading a process's output fifos once containerd has deleted it, by when the shim has written
+// everything the process did
+const drainTimeout = 200 * time.Millisecond
+
+// fifos are the named pipes containerd's shim connects a task's or exec process's stdio to.  "" is an unused one.
+type fifos struct {
+	stdin  string
+	stdout string
+	stderr string
+}
+
+// newFifos makes the fifos of the process called name in dir, leaving any that are already there, as those of a task
+// re-adopted after a vmserver restart are
+func newFifos(dir string, name string, stdin bool, tty bool) (*fifos, error) {
+	f := &fifos{stdout: filepath.Join(dir, name+"-stdout")}
+	if stdin {
+		f.stdin = filepath.Join(dir, name+"-stdin")
+	}
+	// a terminal has only the one output
+	if !tty {
+		f.stderr = filepath.Join(dir, name+"-stderr")
+	}
+
+	for _, path := range []string{f.stdin, f.stdout, f.stderr} {
+		if path == "" {
+			continue
+		}
+		if err := syscall.Mkfifo(path, 0600); err != nil && !os.IsExist(err) {
+			f.remove()
+			return nil, fmt.Errorf("couldn't make fifo %v: %v", path, err)
+		}
+	}
+
+	return f, nil
+}
+
+func (f *fifos) remove() {
+	for _, path := range []string{f.stdin, f.stdout, f.stderr} {
+		if path != "" {
+			os.Remove(path)
+		}
+	}
+}
+
+// processIO is our end of a process's fifos
+type processIO struct {
+	stdin  *os.File
+	stdout *os.File
+	stderr *os.File
+}
+
+// open opens our end of the fifos.  They're opened read-write so opening doesn't wait for the shim, which means
+// reads never see the end of the output; drain ends them once the process is gone.
+func (f *fifos) open() (*processIO, error) {
+	pio := &processIO{}
+
+	var err error
+	for _, fifo := range []struct {
+		path string
+		file **os.File
+	}{{f.stdin, &pio.stdin}, {f.stdout, &pio.stdout}, {f.stderr, &pio.stderr}} {
+		if fifo.path == "" {
+			continue
+		}
+		if *fifo.file, err = os.OpenFile(fifo.path, os.O_RDWR, 0); err != nil {
+			pio.close()
+			return nil, err
+		}
+	}
+
+	return pio, nil
+}
+
+// drain lets reads of the output return what's left in the fifos, then end
+func (pio *processIO) drain() {
+	deadline := time.Now().Add(drainTimeout)
+	for _, file := range []*os.File{pio.stdout, pio.stderr} {
+		if file != nil {
+			file.SetReadDeadline(deadline)
+		}
+	}
+}
+
+// closeStdin tells the process its input has ended
+func (pio *processIO) closeStdin() {
+	if pio.stdin != nil {
+		pio.stdin.Close()
+	}
+}
+
+func (pio *processIO) close() {
+	for _, file := range []*os.File{pio.stdin, pio.stdout, pio.stderr} {
+		if file != nil {
+			file.Close()
+		}
+	}
+}
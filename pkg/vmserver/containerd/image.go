@@ -0,0 +1,525 @@
+package containerd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/reference"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	mediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+
+	// manifests, indexes and configs are read whole, layers are streamed
+	maxMetadataSize = 4 << 20
+	writeChunkSize  = 1 << 20
+)
+
+var manifestMediaTypes = []string{mediaTypeOCIManifest, mediaTypeDockerManifest, mediaTypeOCIIndex, mediaTypeDockerManifestList}
+
+// normalizeImage fully qualifies image the way containerd names images, e.g. busybox is
+// docker.io/library/busybox:latest
+func normalizeImage(image string) (string, string, error) {
+	named, err := reference.ParseNamed(image)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid image %q: %v", image, err)
+	}
+
+	host, repository := reference.SplitHostname(named)
+	// the first component is only a registry if it looks like a host, otherwise it's a docker hub user
+	if host != "" && !strings.ContainsAny(host, ".:") && host != "localhost" {
+		host, repository = "", named.Name()
+	}
+	if host == "" {
+		host = "docker.io"
+	}
+	if host == "docker.io" && !strings.Contains(repository, "/") {
+		repository = "library/" + repository
+	}
+
+	name := host + "/" + repository
+	switch r := named.(type) {
+	case reference.Canonical:
+		name += "@" + r.Digest().String()
+	case reference.NamedTagged:
+		name += ":" + r.Tag()
+	default:
+		name += ":latest"
+	}
+
+	return name, host, nil
+}
+
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+	Platform  *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	} `json:"platform,omitempty"`
+}
+
+// manifest covers both manifests and manifest lists, which are told apart by their media type
+type manifest struct {
+	MediaType string       `json:"mediaType"`
+	Config    descriptor   `json:"config"`
+	Layers    []descriptor `json:"layers"`
+	Manifests []descriptor `json:"manifests"`
+}
+
+func (m *manifest) isIndex() bool {
+	return m.MediaType == mediaTypeDockerManifestList || m.MediaType == mediaTypeOCIIndex || len(m.Manifests) > 0
+}
+
+// platformManifest returns the entry of an index for the VM's platform
+func (m *manifest) platformManifest() (descriptor, error) {
+	for _, desc := range m.Manifests {
+		if desc.Platform != nil && desc.Platform.OS == "linux" && desc.Platform.Architecture == runtime.GOARCH {
+			return desc, nil
+		}
+	}
+	return descriptor{}, fmt.Errorf("no manifest for linux/%v", runtime.GOARCH)
+}
+
+// imageConfig is the part of the image's config blob a container's defaults and its layers' diff ids come from
+type imageConfig struct {
+	Config struct {
+		User       string   `json:"User"`
+		Env        []string `json:"Env"`
+		Entrypoint []string `json:"Entrypoint"`
+		Cmd        []string `json:"Cmd"`
+		WorkingDir string   `json:"WorkingDir"`
+	} `json:"config"`
+	RootFS struct {
+		DiffIDs []string `json:"diff_ids"`
+	} `json:"rootfs"`
+}
+
+// image is a pulled and unpacked image
+type image struct {
+	name   string
+	config *imageConfig
+	// chainID names the snapshot of its unpacked layers, which containers' snapshots are prepared from
+	chainID string
+}
+
+// chainIDs returns the chain ids of the snapshots layers with diffIDs unpack into, each naming its layer and all
+// those below it
+func chainIDs(diffIDs []string) []string {
+	ret := make([]string, len(diffIDs))
+	for i, diffID := range diffIDs {
+		if i == 0 {
+			ret[i] = diffID
+			continue
+		}
+		sum := sha256.Sum256([]byte(ret[i-1] + " " + diffID))
+		ret[i] = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	return ret
+}
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// puller pulls one image into containerd under a lease, so its content and snapshots aren't collected before the
+// image record refers to them
+type puller struct {
+	c           *client
+	resolver    *resolver
+	ref         *registryRef
+	lease       string
+	snapshotter string
+}
+
+// pullImage pulls image into containerd and unpacks it unless it already has it
+func (c *client) pullImage(ctx context.Context, name string, host string, r *resolver, snapshotter string) (*image, error) {
+	p := &puller{c: c, resolver: r, ref: parseRegistryRef(name, host), snapshotter: snapshotter}
+
+	var resp createLeaseResponse
+	leaseID := "infranetes-pull-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	if err := c.call(ctx, "leases.v1.Leases/Create", &createLeaseRequest{ID: leaseID}, &resp); err != nil {
+		return nil, err
+	}
+	p.lease = leaseID
+	defer func() {
+		if err := c.call(ctx, "leases.v1.Leases/Delete", &deleteLeaseRequest{ID: leaseID}, &empty{}); err != nil {
+			glog.Warningf("pullImage: %v", err)
+		}
+	}()
+
+	target, err := p.target(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	config, chainID, err := p.fetch(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	img := &apiImage{
+		Name:   name,
+		Target: &apiDescriptor{MediaType: target.MediaType, Digest: target.Digest, Size: target.Size},
+	}
+	if err := c.callLeased(ctx, p.lease, "images.v1.Images/Create", &imageMessage{Image: img}, &imageMessage{}); err != nil && !isCode(err, codes.AlreadyExists) {
+		return nil, err
+	}
+
+	return &image{name: name, config: config, chainID: chainID}, nil
+}
+
+// target returns the manifest or index the image record name points at, resolving it in the registry if containerd
+// doesn't have the image
+func (p *puller) target(ctx context.Context, name string) (descriptor, error) {
+	var resp imageMessage
+	err := p.c.call(ctx, "images.v1.Images/Get", &getImageRequest{Name: name}, &resp)
+	if err == nil && resp.Image != nil && resp.Image.Target != nil {
+		glog.Infof("pullImage: %v is already present as %v", name, resp.Image.Target.Digest)
+		return descriptor{MediaType: resp.Image.Target.MediaType, Digest: resp.Image.Target.Digest, Size: resp.Image.Target.Size}, nil
+	}
+	if err != nil && !isCode(err, codes.NotFound) {
+		return descriptor{}, err
+	}
+
+	glog.Infof("pullImage: pulling %v", name)
+
+	body, err := p.resolver.get(p.ref, "manifests", p.ref.reference, manifestMediaTypes)
+	if err != nil {
+		return descriptor{}, err
+	}
+	defer body.Body.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(body.Body, maxMetadataSize))
+	if err != nil {
+		return descriptor{}, fmt.Errorf("couldn't read manifest of %v: %v", p.ref, err)
+	}
+
+	desc := descriptor{MediaType: body.Header.Get("Content-Type"), Digest: digestOf(data), Size: int64(len(data))}
+	if strings.HasPrefix(p.ref.reference, "sha256:") && p.ref.reference != desc.Digest {
+		return descriptor{}, fmt.Errorf("manifest of %v has digest %v", p.ref, desc.Digest)
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return descriptor{}, fmt.Errorf("couldn't parse manifest of %v: %v", p.ref, err)
+	}
+	if m.MediaType != "" {
+		desc.MediaType = m.MediaType
+	}
+
+	if err := p.write(ctx, desc, bytes.NewReader(data), manifestLabels(data)); err != nil {
+		return descriptor{}, err
+	}
+
+	return desc, nil
+}
+
+// manifestLabels are the labels that tell containerd's garbage collector what the manifest or index in data refers to
+func manifestLabels(data []byte) map[string]string {
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil
+	}
+
+	labels := make(map[string]string)
+	for i, desc := range m.Manifests {
+		labels["containerd.io/gc.ref.content.m."+strconv.Itoa(i)] = desc.Digest
+	}
+	if m.Config.Digest != "" {
+		labels["containerd.io/gc.ref.content.config"] = m.Config.Digest
+	}
+	for i, desc := range m.Layers {
+		labels["containerd.io/gc.ref.content.l."+strconv.Itoa(i)] = desc.Digest
+	}
+	return labels
+}
+
+// configLabels keeps the snapshots of the image whose config is in data as long as the config is kept
+func (p *puller) configLabels(data []byte) map[string]string {
+	var config imageConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil
+	}
+
+	chain := chainIDs(config.RootFS.DiffIDs)
+	if len(chain) == 0 {
+		return nil
+	}
+	return map[string]string{"containerd.io/gc.ref.snapshot." + p.snapshotter: chain[len(chain)-1]}
+}
+
+// fetch makes sure containerd has the content of the image whose manifest or index is target, and its layers
+// unpacked, returning its config and the chain id of its top layer
+func (p *puller) fetch(ctx context.Context, target descriptor) (*imageConfig, string, error) {
+	data, err := p.metadata(ctx, target, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, "", fmt.Errorf("couldn't parse %v: %v", target.Digest, err)
+	}
+
+	if m.isIndex() {
+		desc, err := m.platformManifest()
+		if err != nil {
+			return nil, "", fmt.Errorf("%v has %v", target.Digest, err)
+		}
+		if data, err = p.metadata(ctx, desc, manifestLabels); err != nil {
+			return nil, "", err
+		}
+		m = manifest{}
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, "", fmt.Errorf("couldn't parse %v: %v", desc.Digest, err)
+		}
+	}
+
+	if data, err = p.metadata(ctx, m.Config, p.configLabels); err != nil {
+		return nil, "", err
+	}
+	var config imageConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, "", fmt.Errorf("couldn't parse config %v: %v", m.Config.Digest, err)
+	}
+
+	chain := chainIDs(config.RootFS.DiffIDs)
+	if len(chain) == 0 || len(chain) != len(m.Layers) {
+		return nil, "", fmt.Errorf("config %v has %v layers, its manifest %v", m.Config.Digest, len(chain), len(m.Layers))
+	}
+
+	for i, layer := range m.Layers {
+		if err := p.unpack(ctx, layer, config.RootFS.DiffIDs[i], chain, i); err != nil {
+			return nil, "", err
+		}
+	}
+
+	return &config, chain[len(chain)-1], nil
+}
+
+// metadata returns a manifest, index or config, fetching it into containerd with the labels labels returns for it if
+// containerd doesn't have it
+func (p *puller) metadata(ctx context.Context, desc descriptor, labels func([]byte) map[string]string) ([]byte, error) {
+	if p.c.hasContent(ctx, desc.Digest) {
+		return p.c.readContent(ctx, desc.Digest)
+	}
+
+	// registries serve manifests from their manifests endpoint, by digest as well as tag
+	kind, accept := "blobs", []string(nil)
+	for _, mediaType := range manifestMediaTypes {
+		if desc.MediaType == mediaType {
+			kind, accept = "manifests", []string{mediaType}
+		}
+	}
+
+	resp, err := p.resolver.get(p.ref, kind, desc.Digest, accept)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxMetadataSize))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read %v: %v", desc.Digest, err)
+	}
+	if digest := digestOf(data); digest != desc.Digest {
+		return nil, fmt.Errorf("digest mismatch: want %v, got %v", desc.Digest, digest)
+	}
+
+	var l map[string]string
+	if labels != nil {
+		l = labels(data)
+	}
+	if err := p.write(ctx, desc, bytes.NewReader(data), l); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// unpack applies layer i of the image onto the snapshot of the layers below it, unless it's already unpacked
+func (p *puller) unpack(ctx context.Context, layer descriptor, diffID string, chain []string, i int) error {
+	stat := &snapshotRequest{Snapshotter: p.snapshotter, Key: chain[i]}
+	if err := p.c.call(ctx, "snapshots.v1.Snapshots/Stat", stat, &empty{}); err == nil {
+		return nil
+	} else if !isCode(err, codes.NotFound) {
+		return err
+	}
+
+	if !p.c.hasContent(ctx, layer.Digest) {
+		glog.Infof("pullImage: pulling layer %v of %v", layer.Digest, p.ref)
+		resp, err := p.resolver.get(p.ref, "blobs", layer.Digest, nil)
+		if err != nil {
+			return err
+		}
+		err = p.write(ctx, layer, resp.Body, nil)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	parent := ""
+	if i > 0 {
+		parent = chain[i-1]
+	}
+	key := "extract-" + strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + chain[i]
+
+	var mounts mountsResponse
+	prepare := &prepareSnapshotRequest{Snapshotter: p.snapshotter, Key: key, Parent: parent}
+	if err := p.c.callLeased(ctx, p.lease, "snapshots.v1.Snapshots/Prepare", prepare, &mounts); err != nil {
+		return err
+	}
+
+	err := func() error {
+		var applied applyResponse
+		apply := &applyRequest{
+			Diff:   &apiDescriptor{MediaType: layer.MediaType, Digest: layer.Digest, Size: layer.Size},
+			Mounts: mounts.Mounts,
+		}
+		if err := p.c.callLeased(ctx, p.lease, "diff.v1.Diff/Apply", apply, &applied); err != nil {
+			return err
+		}
+		if applied.Applied == nil || applied.Applied.Digest != diffID {
+			return fmt.Errorf("layer %v didn't unpack to %v, the diff id its config gives", layer.Digest, diffID)
+		}
+
+		commit := &commitSnapshotRequest{Snapshotter: p.snapshotter, Name: chain[i], Key: key}
+		return p.c.callLeased(ctx, p.lease, "snapshots.v1.Snapshots/Commit", commit, &empty{})
+	}()
+	if err != nil {
+		p.c.call(ctx, "snapshots.v1.Snapshots/Remove", &snapshotRequest{Snapshotter: p.snapshotter, Key: key}, &empty{})
+		if isCode(err, codes.AlreadyExists) {
+			// another pull unpacked it first
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+// write writes a blob to containerd's content store, which checks it against desc's digest before committing it
+func (p *puller) write(ctx context.Context, desc descriptor, r io.Reader, labels map[string]string) error {
+	ref := "infranetes-" + strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + desc.Digest
+
+	err := p.c.writeContent(ctx, p.lease, ref, desc, r, labels)
+	if err != nil {
+		if isCode(err, codes.AlreadyExists) {
+			return nil
+		}
+		if aerr := p.c.call(ctx, "content.v1.Content/Abort", &abortRequest{Ref: ref}, &empty{}); aerr != nil && !isCode(aerr, codes.NotFound) {
+			glog.Warningf("pullImage: %v", aerr)
+		}
+		return fmt.Errorf("couldn't write %v: %v", desc.Digest, err)
+	}
+
+	return nil
+}
+
+func (c *client) hasContent(ctx context.Context, digest string) bool {
+	return c.call(ctx, "content.v1.Content/Info", &infoRequest{Digest: digest}, &infoResponse{}) == nil
+}
+
+// readContent reads a blob from containerd's content store
+func (c *client) readContent(ctx context.Context, digest string) ([]byte, error) {
+	stream, err := c.stream(ctx, "", "content.v1.Content/Read", false)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(&readContentRequest{Digest: digest}); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for {
+		var resp readContentResponse
+		if err := stream.RecvMsg(&resp); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, &callError{method: "content.v1.Content/Read", err: err}
+		}
+		buf.Write(resp.Data)
+		if buf.Len() > maxMetadataSize {
+			return nil, fmt.Errorf("%v is too large", digest)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeContent streams r into containerd's content store as desc under ref, an ingest name unique to this write
+func (c *client) writeContent(ctx context.Context, leaseID string, ref string, desc descriptor, r io.Reader, labels map[string]string) error {
+	stream, err := c.stream(ctx, leaseID, "content.v1.Content/Write", true)
+	if err != nil {
+		return err
+	}
+	defer stream.CloseSend()
+
+	// containerd answers each request
+	send := func(req *writeContentRequest) error {
+		if err := stream.SendMsg(req); err != nil {
+			return &callError{method: "content.v1.Content/Write", err: err}
+		}
+		var resp writeContentResponse
+		if err := stream.RecvMsg(&resp); err != nil {
+			return &callError{method: "content.v1.Content/Write", err: err}
+		}
+		return nil
+	}
+
+	buf := make([]byte, writeChunkSize)
+	offset := int64(0)
+	for {
+		n, rerr := io.ReadFull(r, buf)
+		if n > 0 {
+			req := &writeContentRequest{
+				Action:   writeActionWrite,
+				Ref:      ref,
+				Total:    desc.Size,
+				Expected: desc.Digest,
+				Offset:   offset,
+				Data:     buf[:n],
+			}
+			if err := send(req); err != nil {
+				return err
+			}
+			offset += int64(n)
+		}
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			break
+		} else if rerr != nil {
+			return rerr
+		}
+	}
+
+	return send(&writeContentRequest{
+		Action:   writeActionCommit,
+		Ref:      ref,
+		Total:    offset,
+		Expected: desc.Digest,
+		Offset:   offset,
+		Labels:   labels,
+	})
+}
@@ -0,0 +1,298 @@
+package containerd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/apporbit/infranetes/pkg/vmserver/common"
+)
+
+// testRegistry serves one image, app:v1, as an index of a single manifest with two gzipped layers.  With a username
+// set it wants a bearer token, which its token endpoint hands out for the username and password.
+type testRegistry struct {
+	*httptest.Server
+
+	username string
+	password string
+
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	// diffIDs are the digests of the uncompressed layers
+	diffIDs []string
+	index   string
+
+	lock     sync.Mutex
+	requests int
+}
+
+func gzipped(data string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(data))
+	w.Close()
+	return buf.Bytes()
+}
+
+func newTestRegistry(username string, password string) *testRegistry {
+	r := &testRegistry{
+		username:  username,
+		password:  password,
+		blobs:     make(map[string][]byte),
+		manifests: make(map[string][]byte),
+	}
+
+	var layers []descriptor
+	for _, content := range []string{"layer one", "layer two"} {
+		data := gzipped(content)
+		r.blobs[digestOf(data)] = data
+		r.diffIDs = append(r.diffIDs, digestOf([]byte(content)))
+		layers = append(layers, descriptor{MediaType: "application/vnd.docker.image.rootfs.diff.tar.gzip", Digest: digestOf(data), Size: int64(len(data))})
+	}
+
+	config := &imageConfig{}
+	config.Config.Cmd = []string{"/app"}
+	config.RootFS.DiffIDs = r.diffIDs
+	configData, _ := json.Marshal(config)
+	r.blobs[digestOf(configData)] = configData
+
+	m, _ := json.Marshal(&manifest{
+		MediaType: mediaTypeDockerManifest,
+		Config:    descriptor{MediaType: "application/vnd.docker.container.image.v1+json", Digest: digestOf(configData), Size: int64(len(configData))},
+		Layers:    layers,
+	})
+	r.manifests[digestOf(m)] = m
+
+	platform := descriptor{MediaType: mediaTypeDockerManifest, Digest: digestOf(m), Size: int64(len(m))}
+	platform.Platform = &struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	}{runtime.GOARCH, "linux"}
+	index, _ := json.Marshal(&manifest{MediaType: mediaTypeDockerManifestList, Manifests: []descriptor{platform}})
+	r.index = digestOf(index)
+	r.manifests[r.index] = index
+	r.manifests["v1"] = index
+
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serve))
+
+	return r
+}
+
+func (r *testRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	r.lock.Lock()
+	r.requests++
+	r.lock.Unlock()
+
+	if req.URL.Path == "/token" {
+		if user, pass, ok := req.BasicAuth(); !ok || user != r.username || pass != r.password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if req.URL.Query().Get("scope") != "repository:app:pull" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"token": "registry-token"})
+		return
+	}
+
+	if r.username != "" && req.Header.Get("Authorization") != "Bearer registry-token" {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%v/token",service="test"`, r.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/v2/app/"), "/")
+	if len(parts) != 2 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var data []byte
+	switch parts[0] {
+	case "manifests":
+		data = r.manifests[parts[1]]
+		if data != nil {
+			var m manifest
+			json.Unmarshal(data, &m)
+			w.Header().Set("Content-Type", m.MediaType)
+		}
+	case "blobs":
+		data = r.blobs[parts[1]]
+	}
+	if data == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Write(data)
+}
+
+func (r *testRegistry) host() string {
+	return strings.TrimPrefix(r.URL, "http://")
+}
+
+func testResolver(credentials map[string]common.ArtifactCredential) *resolver {
+	return &resolver{credentials: credentials, allowHTTP: true, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func TestPullImage(t *testing.T) {
+	registry := newTestRegistry("user", "secret")
+	defer registry.Close()
+
+	fake := newFakeContainerd(t)
+	defer fake.stop()
+
+	c, err := dialContainerd(fake.address, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name, host, err := normalizeImage(registry.host() + "/app:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := testResolver(map[string]common.ArtifactCredential{host: {Username: "user", Password: "secret"}})
+
+	img, err := c.pullImage(context.Background(), name, host, r, "overlayfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain := chainIDs(registry.diffIDs)
+	if chain[0] != registry.diffIDs[0] || chain[1] == registry.diffIDs[1] {
+		t.Errorf("chain ids = %v for diff ids %v", chain, registry.diffIDs)
+	}
+	if img.chainID != chain[1] {
+		t.Errorf("chain id = %v, want %v", img.chainID, chain[1])
+	}
+	if len(img.config.Config.Cmd) != 1 || img.config.Config.Cmd[0] != "/app" {
+		t.Errorf("config = %+v", img.config.Config)
+	}
+
+	fake.lock.Lock()
+	if target := fake.images[name]; target == nil || target.Target.Digest != registry.index {
+		t.Errorf("image %v = %v, want the index %v", name, target, registry.index)
+	}
+	for _, id := range chain {
+		if fake.snapshots[id] == nil || !fake.snapshots[id].committed {
+			t.Errorf("snapshot %v wasn't committed", id)
+		}
+	}
+	if fake.snapshots[chain[1]].parent != chain[0] {
+		t.Errorf("snapshot %v has parent %v, want %v", chain[1], fake.snapshots[chain[1]].parent, chain[0])
+	}
+	for digest, data := range fake.content {
+		var config imageConfig
+		if json.Unmarshal(data, &config) == nil && len(config.RootFS.DiffIDs) > 0 {
+			if got := fake.labels[digest]["containerd.io/gc.ref.snapshot.overlayfs"]; got != chain[1] {
+				t.Errorf("config is labelled with snapshot %q, want %v", got, chain[1])
+			}
+		}
+	}
+	if len(fake.leases) != 0 {
+		t.Errorf("leases %v weren't deleted", fake.leases)
+	}
+	fake.lock.Unlock()
+
+	// containerd has it now
+	registry.lock.Lock()
+	registry.requests = 0
+	registry.lock.Unlock()
+
+	if _, err := c.pullImage(context.Background(), name, host, r, "overlayfs"); err != nil {
+		t.Fatal(err)
+	}
+	if registry.requests != 0 {
+		t.Errorf("pulling a present image made %v registry requests", registry.requests)
+	}
+}
+
+func TestPullImageErrors(t *testing.T) {
+	registry := newTestRegistry("user", "secret")
+	defer registry.Close()
+
+	fake := newFakeContainerd(t)
+	defer fake.stop()
+
+	c, err := dialContainerd(fake.address, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name, host, _ := normalizeImage(registry.host() + "/app:v1")
+
+	r := testResolver(map[string]common.ArtifactCredential{host: {Username: "user", Password: "wrong"}})
+	if _, err := c.pullImage(context.Background(), name, host, r, "overlayfs"); err == nil {
+		t.Errorf("pulled with the wrong password")
+	}
+
+	// a layer that isn't what its digest says
+	for digest := range registry.blobs {
+		if strings.Contains(string(registry.blobs[digest]), "rootfs") {
+			continue
+		}
+		registry.blobs[digest] = gzipped("tampered")
+	}
+	r = testResolver(map[string]common.ArtifactCredential{host: {Username: "user", Password: "secret"}})
+	if _, err := c.pullImage(context.Background(), name, host, r, "overlayfs"); err == nil {
+		t.Errorf("pulled tampered blobs")
+	}
+
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	if len(fake.images) != 0 {
+		t.Errorf("failed pulls created images %v", fake.images)
+	}
+}
+
+func TestParseRegistryRef(t *testing.T) {
+	for _, test := range []struct {
+		image    string
+		registry string
+		repo     string
+		ref      string
+	}{
+		{"busybox", dockerHubRegistry, "library/busybox", "latest"},
+		{"localhost:5000/app:dev", "localhost:5000", "app", "dev"},
+		{"quay.io/coreos/etcd@sha256:" + strings.Repeat("a", 64), "quay.io", "coreos/etcd", "sha256:" + strings.Repeat("a", 64)},
+	} {
+		name, host, err := normalizeImage(test.image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref := parseRegistryRef(name, host)
+		if ref.registry != test.registry || ref.repository != test.repo || ref.reference != test.ref || ref.host != host {
+			t.Errorf("parseRegistryRef(%v) = %+v", name, ref)
+		}
+	}
+}
+
+func TestNormalizeImage(t *testing.T) {
+	for image, want := range map[string]string{
+		"busybox":                  "docker.io/library/busybox:latest",
+		"busybox:1.28":             "docker.io/library/busybox:1.28",
+		"example/app":              "docker.io/example/app:latest",
+		"quay.io/coreos/etcd:v3.2": "quay.io/coreos/etcd:v3.2",
+		"localhost:5000/app:dev":   "localhost:5000/app:dev",
+	} {
+		got, _, err := normalizeImage(image)
+		if err != nil {
+			t.Errorf("%v: %v", image, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("normalizeImage(%v) = %v, want %v", image, got, want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package containerd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/apporbit/infranetes/pkg/vmserver/common"
+)
+
+// docker hub's images are named docker.io/..., but served from here
+const dockerHubRegistry = "registry-1.docker.io"
+
+// registryRef is where a normalized image name is pulled from
+type registryRef struct {
+	// host is the image name's host, which its credentials are configured for
+	host       string
+	registry   string
+	repository string
+	// reference is the tag or digest to fetch the manifest by
+	reference string
+}
+
+func parseRegistryRef(name string, host string) *registryRef {
+	ref := &registryRef{host: host, registry: host}
+	if host == "docker.io" {
+		ref.registry = dockerHubRegistry
+	}
+
+	repository := strings.TrimPrefix(name, host+"/")
+	if i := strings.Index(repository, "@"); i != -1 {
+		ref.repository, ref.reference = repository[:i], repository[i+1:]
+	} else if i := strings.LastIndex(repository, ":"); i != -1 {
+		ref.repository, ref.reference = repository[:i], repository[i+1:]
+	} else {
+		ref.repository, ref.reference = repository, "latest"
+	}
+
+	return ref
+}
+
+func (r *registryRef) String() string {
+	return r.registry + "/" + r.repository
+}
+
+// resolver fetches manifests and blobs from registries, authenticating with the configured credentials.  They're
+// only ever sent in request headers, never put on a command line where any process on the VM could read them.
+type resolver struct {
+	credentials map[string]common.ArtifactCredential
+	allowHTTP   bool
+	client      *http.Client
+}
+
+func newResolver(credentialsPath string, allowHTTP bool) (*resolver, error) {
+	credentials, err := common.LoadArtifactCredentials(credentialsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resolver{
+		credentials: credentials,
+		allowHTTP:   allowHTTP,
+		client:      &http.Client{Timeout: 30 * time.Minute},
+	}, nil
+}
+
+func (r *resolver) credential(ref *registryRef) (common.ArtifactCredential, bool) {
+	if cred, ok := r.credentials[ref.host]; ok {
+		return cred, true
+	}
+	cred, ok := r.credentials[ref.registry]
+	return cred, ok
+}
+
+func (r *resolver) url(ref *registryRef, kind string, name string) string {
+	scheme := "https"
+	if r.allowHTTP && (strings.HasPrefix(ref.registry, "localhost") || strings.HasPrefix(ref.registry, "127.")) {
+		scheme = "http"
+	}
+
+	return fmt.Sprintf("%v://%v/v2/%v/%v/%v", scheme, ref.registry, ref.repository, kind, name)
+}
+
+// get fetches a manifest or blob of ref, answering a bearer challenge with a token for its repository
+func (r *resolver) get(ref *registryRef, kind string, name string, accept []string) (*http.Response, error) {
+	target := r.url(ref, kind, name)
+
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, mediaType := range accept {
+		req.Header.Add("Accept", mediaType)
+	}
+
+	cred, haveCred := r.credential(ref)
+	if haveCred && cred.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+cred.Token)
+	} else if haveCred {
+		req.SetBasicAuth(cred.Username, cred.Password)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		token, err := r.token(ref, challenge)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+		if resp, err = r.client.Do(req); err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %v returned %v", target, resp.Status)
+	}
+
+	return resp, nil
+}
+
+func (r *resolver) token(ref *registryRef, challenge string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", fmt.Errorf("unsupported auth challenge %q from %v", challenge, ref.registry)
+	}
+
+	params := make(map[string]string)
+	for _, param := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		if kv := strings.SplitN(strings.TrimSpace(param), "=", 2); len(kv) == 2 {
+			params[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("invalid auth realm in challenge %q", challenge)
+	}
+
+	query := realm.Query()
+	if service, ok := params["service"]; ok {
+		query.Set("service", service)
+	}
+	query.Set("scope", "repository:"+ref.repository+":pull")
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequest("GET", realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if cred, ok := r.credential(ref); ok && cred.Username != "" {
+		req.SetBasicAuth(cred.Username, cred.Password)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request to %v failed: %v", realm.Host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request to %v returned %v", realm.Host, resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("couldn't parse token from %v: %v", realm.Host, err)
+	}
+
+	if body.Token != "" {
+		return body.Token, nil
+	}
+
+	return body.AccessToken, nil
+}
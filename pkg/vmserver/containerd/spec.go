@@ -0,0 +1,372 @@
+package containerd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+// The subset of the OCI runtime spec we generate.  The runtime-spec package isn't vendored, and these are its json
+// names.
+type spec struct {
+	OciVersion string  `json:"ociVersion"`
+	Process    process `json:"process"`
+	Root       root    `json:"root"`
+	Mounts     []mount `json:"mounts"`
+	Linux      linux   `json:"linux"`
+}
+
+type process struct {
+	Terminal        bool          `json:"terminal,omitempty"`
+	User            user          `json:"user"`
+	Args            []string      `json:"args"`
+	Env             []string      `json:"env,omitempty"`
+	Cwd             string        `json:"cwd"`
+	Capabilities    *capabilities `json:"capabilities,omitempty"`
+	Rlimits         []rlimit      `json:"rlimits,omitempty"`
+	NoNewPrivileges bool          `json:"noNewPrivileges,omitempty"`
+	OOMScoreAdj     *int          `json:"oomScoreAdj,omitempty"`
+}
+
+type user struct {
+	UID            uint32   `json:"uid"`
+	GID            uint32   `json:"gid"`
+	AdditionalGids []uint32 `json:"additionalGids,omitempty"`
+}
+
+type capabilities struct {
+	Bounding    []string `json:"bounding"`
+	Effective   []string `json:"effective"`
+	Inheritable []string `json:"inheritable"`
+	Permitted   []string `json:"permitted"`
+}
+
+type rlimit struct {
+	Type string `json:"type"`
+	Hard uint64 `json:"hard"`
+	Soft uint64 `json:"soft"`
+}
+
+type root struct {
+	Path     string `json:"path"`
+	Readonly bool   `json:"readonly,omitempty"`
+}
+
+type mount struct {
+	Destination string   `json:"destination"`
+	Type        string   `json:"type,omitempty"`
+	Source      string   `json:"source,omitempty"`
+	Options     []string `json:"options,omitempty"`
+}
+
+type linux struct {
+	Namespaces    []namespace `json:"namespaces"`
+	Resources     *resources  `json:"resources,omitempty"`
+	CgroupsPath   string      `json:"cgroupsPath,omitempty"`
+	MaskedPaths   []string    `json:"maskedPaths,omitempty"`
+	ReadonlyPaths []string    `json:"readonlyPaths,omitempty"`
+}
+
+type namespace struct {
+	Type string `json:"type"`
+}
+
+type resources struct {
+	Devices []deviceCgroup `json:"devices"`
+	Memory  *memory        `json:"memory,omitempty"`
+	CPU     *cpu           `json:"cpu,omitempty"`
+}
+
+type deviceCgroup struct {
+	Allow  bool   `json:"allow"`
+	Access string `json:"access,omitempty"`
+}
+
+type memory struct {
+	Limit *int64 `json:"limit,omitempty"`
+}
+
+type cpu struct {
+	Shares *uint64 `json:"shares,omitempty"`
+	Quota  *int64  `json:"quota,omitempty"`
+	Period *uint64 `json:"period,omitempty"`
+}
+
+const defaultPath = "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// the capabilities docker gives containers by default
+var defaultCapabilities = []string{
+	"CAP_CHOWN", "CAP_DAC_OVERRIDE", "CAP_FSETID", "CAP_FOWNER", "CAP_MKNOD", "CAP_NET_RAW", "CAP_SETGID",
+	"CAP_SETUID", "CAP_SETFCAP", "CAP_SETPCAP", "CAP_NET_BIND_SERVICE", "CAP_SYS_CHROOT", "CAP_KILL", "CAP_AUDIT_WRITE",
+}
+
+var allCapabilities = []string{
+	"CAP_CHOWN", "CAP_DAC_OVERRIDE", "CAP_DAC_READ_SEARCH", "CAP_FOWNER", "CAP_FSETID", "CAP_KILL", "CAP_SETGID",
+	"CAP_SETUID", "CAP_SETPCAP", "CAP_LINUX_IMMUTABLE", "CAP_NET_BIND_SERVICE", "CAP_NET_BROADCAST", "CAP_NET_ADMIN",
+	"CAP_NET_RAW", "CAP_IPC_LOCK", "CAP_IPC_OWNER", "CAP_SYS_MODULE", "CAP_SYS_RAWIO", "CAP_SYS_CHROOT",
+	"CAP_SYS_PTRACE", "CAP_SYS_PACCT", "CAP_SYS_ADMIN", "CAP_SYS_BOOT", "CAP_SYS_NICE", "CAP_SYS_RESOURCE",
+	"CAP_SYS_TIME", "CAP_SYS_TTY_CONFIG", "CAP_MKNOD", "CAP_LEASE", "CAP_AUDIT_WRITE", "CAP_AUDIT_CONTROL",
+	"CAP_SETFCAP", "CAP_MAC_OVERRIDE", "CAP_MAC_ADMIN", "CAP_SYSLOG", "CAP_WAKE_ALARM", "CAP_BLOCK_SUSPEND",
+	"CAP_AUDIT_READ",
+}
+
+var maskedPaths = []string{
+	"/proc/kcore", "/proc/latency_stats", "/proc/timer_list", "/proc/timer_stats", "/proc/sched_debug",
+	"/sys/firmware", "/proc/scsi",
+}
+
+var readonlyPaths = []string{
+	"/proc/asound", "/proc/bus", "/proc/fs", "/proc/irq", "/proc/sys", "/proc/sysrq-trigger",
+}
+
+// buildSpec generates the runtime spec of a container from its CRI config and its image's defaults.  Containers
+// share the VM's network and UTS namespaces, as the VM is the pod, but get their own pid, ipc and mount namespaces
+// unless the sandbox asks for the host's.
+func buildSpec(req *kubeapi.CreateContainerRequest, image *imageConfig) (*spec, error) {
+	config := req.GetConfig()
+	security := config.GetLinux().GetSecurityContext()
+
+	argv := append([]string{}, config.Command...)
+	args := config.Args
+	if len(argv) == 0 {
+		argv = append(argv, image.Config.Entrypoint...)
+		if len(args) == 0 {
+			args = image.Config.Cmd
+		}
+	}
+	argv = append(argv, args...)
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("no command given and image has no entrypoint or cmd")
+	}
+
+	env := append([]string{}, image.Config.Env...)
+	for _, kv := range config.Envs {
+		env = append(env, kv.Key+"="+kv.Value)
+	}
+	if !hasEnv(env, "PATH") {
+		env = append(env, defaultPath)
+	}
+
+	cwd := config.WorkingDir
+	if cwd == "" {
+		cwd = image.Config.WorkingDir
+	}
+	if cwd == "" {
+		cwd = "/"
+	}
+
+	u, err := buildUser(security, image.Config.User)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &spec{
+		OciVersion: "1.0.0",
+		Process: process{
+			Terminal:     config.Tty,
+			User:         *u,
+			Args:         argv,
+			Env:          env,
+			Cwd:          cwd,
+			Capabilities: buildCapabilities(security),
+			Rlimits:      []rlimit{{Type: "RLIMIT_NOFILE", Hard: 1024, Soft: 1024}},
+		},
+		Root: root{
+			Path:     "rootfs",
+			Readonly: security.GetReadonlyRootfs(),
+		},
+		Mounts: defaultMounts(security.GetPrivileged()),
+		Linux: linux{
+			Namespaces:  []namespace{{Type: "mount"}},
+			Resources:   buildResources(config.GetLinux().GetResources(), security.GetPrivileged()),
+			CgroupsPath: "/infranetes/" + strings.Replace(req.GetPodSandboxId()+"-"+config.GetMetadata().GetName(), ":", "-", -1),
+		},
+	}
+
+	if adj := config.GetLinux().GetResources().GetOomScoreAdj(); adj != 0 {
+		oomScoreAdj := int(adj)
+		ret.Process.OOMScoreAdj = &oomScoreAdj
+	}
+
+	namespaces := req.GetSandboxConfig().GetLinux().GetSecurityContext().GetNamespaceOptions()
+	if !namespaces.GetHostPid() {
+		ret.Linux.Namespaces = append(ret.Linux.Namespaces, namespace{Type: "pid"})
+	}
+	if !namespaces.GetHostIpc() {
+		ret.Linux.Namespaces = append(ret.Linux.Namespaces, namespace{Type: "ipc"})
+	}
+
+	if !security.GetPrivileged() {
+		ret.Linux.MaskedPaths = maskedPaths
+		ret.Linux.ReadonlyPaths = readonlyPaths
+	}
+
+	for _, m := range config.Mounts {
+		options := []string{"rbind", "rw"}
+		if m.Readonly {
+			options[1] = "ro"
+		}
+		ret.Mounts = append(ret.Mounts, mount{Destination: m.ContainerPath, Type: "bind", Source: m.HostPath, Options: options})
+	}
+
+	return ret, nil
+}
+
+func hasEnv(env []string, key string) bool {
+	for _, kv := range env {
+		if strings.HasPrefix(kv, key+"=") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// buildUser works out the numeric user to run as.  The spec can't carry user names, so they are only accepted when
+// they are numeric.
+func buildUser(security *kubeapi.LinuxContainerSecurityContext, imageUser string) (*user, error) {
+	ret := &user{}
+
+	name := imageUser
+	if security.GetRunAsUser() != nil {
+		name = strconv.FormatInt(security.GetRunAsUser().Value, 10)
+	} else if security.GetRunAsUsername() != "" {
+		name = security.GetRunAsUsername()
+	}
+
+	if name != "" {
+		parts := strings.SplitN(name, ":", 2)
+		uid, err := strconv.ParseUint(parts[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("user %q isn't numeric, which the containerd provider requires", name)
+		}
+		ret.UID = uint32(uid)
+
+		if len(parts) == 2 {
+			gid, err := strconv.ParseUint(parts[1], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("group of user %q isn't numeric, which the containerd provider requires", name)
+			}
+			ret.GID = uint32(gid)
+		}
+	}
+
+	for _, gid := range security.GetSupplementalGroups() {
+		ret.AdditionalGids = append(ret.AdditionalGids, uint32(gid))
+	}
+
+	return ret, nil
+}
+
+func buildCapabilities(security *kubeapi.LinuxContainerSecurityContext) *capabilities {
+	caps := defaultCapabilities
+	if security.GetPrivileged() {
+		caps = allCapabilities
+	} else {
+		caps = adjustCapabilities(caps, security.GetCapabilities().GetAddCapabilities(), security.GetCapabilities().GetDropCapabilities())
+	}
+
+	return &capabilities{Bounding: caps, Effective: caps, Inheritable: caps, Permitted: caps}
+}
+
+// adjustCapabilities applies CRI's add and drop lists, which name capabilities without the CAP_ prefix and may use ALL
+func adjustCapabilities(base []string, add []string, drop []string) []string {
+	normalize := func(c string) string {
+		c = strings.ToUpper(c)
+		if c != "ALL" && !strings.HasPrefix(c, "CAP_") {
+			c = "CAP_" + c
+		}
+		return c
+	}
+
+	set := make(map[string]bool)
+	for _, c := range base {
+		set[c] = true
+	}
+
+	for _, c := range drop {
+		if c = normalize(c); c == "ALL" {
+			set = make(map[string]bool)
+		} else {
+			delete(set, c)
+		}
+	}
+
+	for _, c := range add {
+		if c = normalize(c); c == "ALL" {
+			for _, all := range allCapabilities {
+				set[all] = true
+			}
+		} else {
+			set[c] = true
+		}
+	}
+
+	// keep a stable order so the generated spec only changes when the config does
+	ret := []string{}
+	for _, c := range allCapabilities {
+		if set[c] {
+			ret = append(ret, c)
+			delete(set, c)
+		}
+	}
+	for c := range set {
+		ret = append(ret, c)
+	}
+
+	return ret
+}
+
+func buildResources(r *kubeapi.LinuxContainerResources, privileged bool) *resources {
+	ret := &resources{Devices: []deviceCgroup{{Allow: privileged, Access: "rwm"}}}
+
+	if r == nil {
+		return ret
+	}
+
+	if r.MemoryLimitInBytes > 0 {
+		limit := r.MemoryLimitInBytes
+		ret.Memory = &memory{Limit: &limit}
+	}
+
+	if r.CpuShares > 0 || r.CpuQuota > 0 || r.CpuPeriod > 0 {
+		ret.CPU = &cpu{}
+		if r.CpuShares > 0 {
+			shares := uint64(r.CpuShares)
+			ret.CPU.Shares = &shares
+		}
+		if r.CpuQuota > 0 {
+			quota := r.CpuQuota
+			ret.CPU.Quota = &quota
+		}
+		if r.CpuPeriod > 0 {
+			period := uint64(r.CpuPeriod)
+			ret.CPU.Period = &period
+		}
+	}
+
+	return ret
+}
+
+func defaultMounts(privileged bool) []mount {
+	sysOptions := []string{"nosuid", "noexec", "nodev", "ro"}
+	if privileged {
+		sysOptions = []string{"nosuid", "noexec", "nodev", "rw"}
+	}
+
+	return []mount{
+		{Destination: "/proc", Type: "proc", Source: "proc", Options: []string{"nosuid", "noexec", "nodev"}},
+		{Destination: "/dev", Type: "tmpfs", Source: "tmpfs", Options: []string{"nosuid", "strictatime", "mode=755", "size=65536k"}},
+		{Destination: "/dev/pts", Type: "devpts", Source: "devpts", Options: []string{"nosuid", "noexec", "newinstance", "ptmxmode=0666", "mode=0620", "gid=5"}},
+		{Destination: "/dev/shm", Type: "tmpfs", Source: "shm", Options: []string{"nosuid", "noexec", "nodev", "mode=1777", "size=65536k"}},
+		{Destination: "/dev/mqueue", Type: "mqueue", Source: "mqueue", Options: []string{"nosuid", "noexec", "nodev"}},
+		{Destination: "/sys", Type: "sysfs", Source: "sysfs", Options: sysOptions},
+		{Destination: "/sys/fs/cgroup", Type: "cgroup", Source: "cgroup", Options: append([]string{"relatime"}, sysOptions...)},
+		// the VM is the pod, so its name resolution is the pod's
+		{Destination: "/etc/resolv.conf", Type: "bind", Source: "/etc/resolv.conf", Options: []string{"rbind", "ro"}},
+		{Destination: "/etc/hosts", Type: "bind", Source: "/etc/hosts", Options: []string{"rbind", "ro"}},
+		{Destination: "/etc/hostname", Type: "bind", Source: "/etc/hostname", Options: []string{"rbind", "ro"}},
+	}
+}
@@ -0,0 +1,179 @@
+package containerd
+
+import (
+	"reflect"
+	"testing"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+func testImage() *imageConfig {
+	image := &imageConfig{}
+	image.Config.Entrypoint = []string{"/entrypoint.sh"}
+	image.Config.Cmd = []string{"serve"}
+	image.Config.Env = []string{"PATH=/opt/bin", "FOO=image"}
+	image.Config.WorkingDir = "/srv"
+	image.Config.User = "100:101"
+	return image
+}
+
+func testRequest(config *kubeapi.ContainerConfig) *kubeapi.CreateContainerRequest {
+	if config.Metadata == nil {
+		config.Metadata = &kubeapi.ContainerMetadata{Name: "app"}
+	}
+	return &kubeapi.CreateContainerRequest{PodSandboxId: "pod", Config: config, SandboxConfig: &kubeapi.PodSandboxConfig{}}
+}
+
+func hasNamespace(s *spec, t string) bool {
+	for _, ns := range s.Linux.Namespaces {
+		if ns.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBuildSpecImageDefaults(t *testing.T) {
+	s, err := buildSpec(testRequest(&kubeapi.ContainerConfig{}), testImage())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"/entrypoint.sh", "serve"}; !reflect.DeepEqual(s.Process.Args, want) {
+		t.Errorf("args = %v, want %v", s.Process.Args, want)
+	}
+	if want := []string{"PATH=/opt/bin", "FOO=image"}; !reflect.DeepEqual(s.Process.Env, want) {
+		t.Errorf("env = %v, want %v", s.Process.Env, want)
+	}
+	if s.Process.Cwd != "/srv" {
+		t.Errorf("cwd = %v, want /srv", s.Process.Cwd)
+	}
+	if s.Process.User.UID != 100 || s.Process.User.GID != 101 {
+		t.Errorf("user = %+v, want 100:101", s.Process.User)
+	}
+	if s.Linux.CgroupsPath != "/infranetes/pod-app" {
+		t.Errorf("cgroupsPath = %v", s.Linux.CgroupsPath)
+	}
+	for _, ns := range []string{"mount", "pid", "ipc"} {
+		if !hasNamespace(s, ns) {
+			t.Errorf("missing %v namespace", ns)
+		}
+	}
+	if hasNamespace(s, "network") {
+		t.Errorf("containers should share the VM's network")
+	}
+}
+
+func TestBuildSpecOverrides(t *testing.T) {
+	config := &kubeapi.ContainerConfig{
+		Command:    []string{"/bin/sh"},
+		Envs:       []*kubeapi.KeyValue{{Key: "FOO", Value: "bar"}},
+		WorkingDir: "/tmp",
+		Mounts:     []*kubeapi.Mount{{HostPath: "/data", ContainerPath: "/data", Readonly: true}},
+		Linux: &kubeapi.LinuxContainerConfig{
+			Resources: &kubeapi.LinuxContainerResources{MemoryLimitInBytes: 1 << 20, CpuShares: 512, OomScoreAdj: 100},
+			SecurityContext: &kubeapi.LinuxContainerSecurityContext{
+				RunAsUser:      &kubeapi.Int64Value{Value: 0},
+				ReadonlyRootfs: true,
+			},
+		},
+	}
+	req := testRequest(config)
+	req.SandboxConfig.Linux = &kubeapi.LinuxPodSandboxConfig{
+		SecurityContext: &kubeapi.LinuxSandboxSecurityContext{
+			NamespaceOptions: &kubeapi.NamespaceOption{HostPid: true},
+		},
+	}
+
+	s, err := buildSpec(req, testImage())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a command replaces both the entrypoint and the cmd of the image
+	if want := []string{"/bin/sh"}; !reflect.DeepEqual(s.Process.Args, want) {
+		t.Errorf("args = %v, want %v", s.Process.Args, want)
+	}
+	if s.Process.Env[len(s.Process.Env)-1] != "FOO=bar" {
+		t.Errorf("env = %v, want FOO=bar last", s.Process.Env)
+	}
+	if s.Process.Cwd != "/tmp" {
+		t.Errorf("cwd = %v, want /tmp", s.Process.Cwd)
+	}
+	if s.Process.User.UID != 0 || s.Process.User.GID != 0 {
+		t.Errorf("user = %+v, want root", s.Process.User)
+	}
+	if !s.Root.Readonly {
+		t.Errorf("rootfs should be readonly")
+	}
+	if hasNamespace(s, "pid") {
+		t.Errorf("pid namespace should be the host's")
+	}
+	if s.Linux.Resources.Memory == nil || *s.Linux.Resources.Memory.Limit != 1<<20 {
+		t.Errorf("memory = %+v", s.Linux.Resources.Memory)
+	}
+	if s.Linux.Resources.CPU == nil || *s.Linux.Resources.CPU.Shares != 512 {
+		t.Errorf("cpu = %+v", s.Linux.Resources.CPU)
+	}
+	if s.Process.OOMScoreAdj == nil || *s.Process.OOMScoreAdj != 100 {
+		t.Errorf("oomScoreAdj = %v", s.Process.OOMScoreAdj)
+	}
+
+	last := s.Mounts[len(s.Mounts)-1]
+	if last.Destination != "/data" || !reflect.DeepEqual(last.Options, []string{"rbind", "ro"}) {
+		t.Errorf("mount = %+v", last)
+	}
+}
+
+func TestBuildSpecDefaultPath(t *testing.T) {
+	image := testImage()
+	image.Config.Env = nil
+
+	s, err := buildSpec(testRequest(&kubeapi.ContainerConfig{}), image)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(s.Process.Env, []string{defaultPath}) {
+		t.Errorf("env = %v, want %v", s.Process.Env, defaultPath)
+	}
+}
+
+func TestBuildSpecErrors(t *testing.T) {
+	image := testImage()
+	image.Config.Entrypoint = nil
+	image.Config.Cmd = nil
+	if _, err := buildSpec(testRequest(&kubeapi.ContainerConfig{}), image); err == nil {
+		t.Errorf("expected an error without a command")
+	}
+
+	image = testImage()
+	image.Config.User = "nobody"
+	if _, err := buildSpec(testRequest(&kubeapi.ContainerConfig{}), image); err == nil {
+		t.Errorf("expected an error for a user name")
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	security := &kubeapi.LinuxContainerSecurityContext{
+		Capabilities: &kubeapi.Capability{AddCapabilities: []string{"net_admin"}, DropCapabilities: []string{"CAP_MKNOD", "KILL"}},
+	}
+
+	caps := buildCapabilities(security).Bounding
+	set := make(map[string]bool)
+	for _, c := range caps {
+		set[c] = true
+	}
+
+	if !set["CAP_NET_ADMIN"] || set["CAP_MKNOD"] || set["CAP_KILL"] || !set["CAP_CHOWN"] {
+		t.Errorf("capabilities = %v", caps)
+	}
+
+	if caps := buildCapabilities(&kubeapi.LinuxContainerSecurityContext{Privileged: true}).Bounding; len(caps) != len(allCapabilities) {
+		t.Errorf("privileged capabilities = %v", caps)
+	}
+
+	if caps := adjustCapabilities(defaultCapabilities, nil, []string{"ALL"}); len(caps) != 0 {
+		t.Errorf("dropping ALL left %v", caps)
+	}
+}
@@ -0,0 +1,222 @@
+package containerd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+
+	icommon "github.com/apporbit/infranetes/pkg/common"
+	"github.com/apporbit/infranetes/pkg/vmserver/common"
+
+	"k8s.io/client-go/tools/remotecommand"
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+	"k8s.io/kubernetes/pkg/kubelet/server/streaming"
+	utilexec "k8s.io/kubernetes/pkg/util/exec"
+)
+
+type streamingRuntime struct {
+	provider *containerdProvider
+}
+
+var _ streaming.Runtime = &streamingRuntime{}
+
+// execs numbers exec processes, whose ids have to be unique within a task
+var execs uint64
+
+// exec runs cmd as another process of the container's task, with its stdio connected to the given streams, and
+// returns its exit code.  It's killed if ctx is done first.
+func (p *containerdProvider) exec(ctx context.Context, id string, cmd []string, stdin io.Reader, stdout, stderr io.Writer, tty bool, resize <-chan remotecommand.TerminalSize) (int32, error) {
+	if len(cmd) == 0 {
+		return -1, fmt.Errorf("no command given")
+	}
+
+	cont, task, err := p.lookup(id)
+	if err != nil {
+		return -1, err
+	}
+	if task == nil || !task.Running() {
+		return -1, fmt.Errorf("container %v is not running", id)
+	}
+
+	execId := "exec-" + strconv.FormatUint(atomic.AddUint64(&execs, 1), 10)
+
+	f, err := newFifos(cont.dir, execId, stdin != nil, tty)
+	if err != nil {
+		return -1, err
+	}
+	defer f.remove()
+
+	pio, err := f.open()
+	if err != nil {
+		return -1, err
+	}
+	defer pio.close()
+
+	processSpec := cont.spec.Process
+	processSpec.Args = cmd
+	processSpec.Terminal = tty
+	data, err := json.Marshal(&processSpec)
+	if err != nil {
+		return -1, err
+	}
+
+	background := context.Background()
+
+	req := &execProcessRequest{
+		ContainerID: cont.ctrId,
+		Stdin:       f.stdin,
+		Stdout:      f.stdout,
+		Stderr:      f.stderr,
+		Terminal:    tty,
+		Spec:        &any{TypeUrl: processTypeURL, Value: data},
+		ExecID:      execId,
+	}
+	if err := p.client.call(background, "tasks.v1.Tasks/Exec", req, &empty{}); err != nil {
+		return -1, err
+	}
+	process := &processRequest{ContainerID: cont.ctrId, ExecID: execId}
+
+	// the output has to be read even if nobody wants it, or the process blocks writing it
+	copies := sync.WaitGroup{}
+	for _, output := range []struct {
+		w    io.Writer
+		file *os.File
+	}{{stdout, pio.stdout}, {stderr, pio.stderr}} {
+		if output.file == nil {
+			continue
+		}
+		if output.w == nil {
+			output.w = ioutil.Discard
+		}
+		copies.Add(1)
+		go func(w io.Writer, file *os.File) {
+			defer copies.Done()
+			io.Copy(w, file)
+		}(output.w, output.file)
+	}
+
+	if pio.stdin != nil {
+		go func() {
+			io.Copy(pio.stdin, stdin)
+			pio.closeStdin()
+		}()
+	}
+
+	if tty && resize != nil {
+		go func() {
+			for size := range resize {
+				p.resize(cont.ctrId, execId, size)
+			}
+		}()
+	}
+
+	if err := p.client.call(background, "tasks.v1.Tasks/Start", process, &startResponse{}); err != nil {
+		p.client.call(background, "tasks.v1.Tasks/DeleteProcess", process, &deleteResponse{})
+		return -1, err
+	}
+
+	exitCode, werr := p.waitExit(ctx, cont.ctrId, execId)
+	if werr != nil && ctx.Err() != nil {
+		kill := &killRequest{ContainerID: cont.ctrId, ExecID: execId, Signal: uint32(syscall.SIGKILL)}
+		if err := p.client.call(background, "tasks.v1.Tasks/Kill", kill, &empty{}); err != nil {
+			glog.Warningf("exec: %v", err)
+		}
+		p.waitExit(background, cont.ctrId, execId)
+	}
+
+	// deleting the process waits for the shim to have written all its output to the fifos
+	if err := p.client.call(background, "tasks.v1.Tasks/DeleteProcess", process, &deleteResponse{}); err != nil {
+		glog.Warningf("exec: %v", err)
+	}
+	pio.drain()
+	copies.Wait()
+
+	if werr != nil {
+		if ctx.Err() != nil {
+			return -1, fmt.Errorf("%v timed out", cmd)
+		}
+		return -1, werr
+	}
+
+	return exitCode, nil
+}
+
+func (p *containerdProvider) ExecSync(req *kubeapi.ExecSyncRequest) (*kubeapi.ExecSyncResponse, error) {
+	ctx := context.Background()
+	if req.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(req.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	exitCode, err := p.exec(ctx, req.ContainerId, req.Cmd, nil, &stdout, &stderr, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ExecSync: %v", err)
+	}
+
+	return &kubeapi.ExecSyncResponse{Stdout: stdout.Bytes(), Stderr: stderr.Bytes(), ExitCode: exitCode}, nil
+}
+
+func (p *containerdProvider) GetStreamingRuntime() streaming.Runtime {
+	return p.streamingRuntime
+}
+
+func (r *streamingRuntime) Exec(containerID string, cmd []string, in io.Reader, out, errw io.WriteCloser, tty bool, resize <-chan remotecommand.TerminalSize) error {
+	// the streams are nil interfaces when the client didn't ask for them, not nil WriteClosers
+	var stdout, stderr io.Writer
+	if out != nil {
+		stdout = out
+	}
+	if errw != nil {
+		stderr = errw
+	}
+
+	exitCode, err := r.provider.exec(context.Background(), containerID, cmd, in, stdout, stderr, tty, resize)
+	if err != nil {
+		return fmt.Errorf("Exec: %v", err)
+	}
+
+	if exitCode != 0 {
+		return utilexec.CodeExitError{Err: fmt.Errorf("command %v exited with %v", cmd, exitCode), Code: int(exitCode)}
+	}
+
+	return nil
+}
+
+func (r *streamingRuntime) Attach(containerID string, in io.Reader, out, errw io.WriteCloser, tty bool, resize <-chan remotecommand.TerminalSize) error {
+	_, task, err := r.provider.lookup(containerID)
+	if err != nil {
+		return fmt.Errorf("Attach: %v", err)
+	}
+	if task == nil {
+		return fmt.Errorf("Attach: container %v was never started", containerID)
+	}
+
+	return task.Attach(in, out, errw, resize)
+}
+
+func (r *streamingRuntime) PortForward(podSandboxID string, port int32, stream io.ReadWriteCloser) error {
+	return common.PortForward(podSandboxID, port, stream)
+}
+
+// Logs follows the container's json log from req.Offset, a byte offset into it, until the container is removed
+func (p *containerdProvider) Logs(req *icommon.LogsRequest, stream icommon.VMServer_LogsServer) error {
+	cont, _, err := p.lookup(req.ContainerID)
+	if err != nil {
+		return fmt.Errorf("Logs: %v", err)
+	}
+
+	return common.FollowJsonLog(stream.Context(), cont.logPath, req.Offset, cont.removed, stream.Send)
+}
@@ -1,10 +1,8 @@
 package docker
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"strings"
 	"sync"
@@ -23,9 +21,11 @@ import (
 	"k8s.io/kubernetes/pkg/kubelet/dockershim"
 	"k8s.io/kubernetes/pkg/kubelet/dockershim/libdocker"
 
+	"github.com/apporbit/infranetes/cmd/vmserver/flags"
 	"github.com/apporbit/infranetes/pkg/common"
 	icommon "github.com/apporbit/infranetes/pkg/common"
 	"github.com/apporbit/infranetes/pkg/vmserver"
+	vmcommon "github.com/apporbit/infranetes/pkg/vmserver/common"
 
 	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
 )
@@ -35,6 +35,9 @@ type dockerProvider struct {
 	streamingRuntime *streamingRuntime
 	tailMap          map[string]*tail.Tail
 	lock             sync.Mutex
+	events           *vmcommon.EventBus
+	// separates keys and values of the security options our docker understands
+	securityOptSeparator rune
 }
 
 const (
@@ -58,13 +61,25 @@ func NewDockerProvider() (vmserver.ContainerProvider, error) {
 		d := &dockerProvider{
 			client:  client,
 			tailMap: make(map[string]*tail.Tail),
+			events:  vmcommon.NewEventBus(),
 			streamingRuntime: &streamingRuntime{
 				client:      libdocker.KubeWrapDockerclient(client),
 				execHandler: &dockershim.NativeExecHandler{},
 				//execHandler: &dockertools.NsenterExecHandler{},
 			},
+			securityOptSeparator: '=',
 		}
 
+		ctx, cancel := getTimeoutContext()
+		defer cancel()
+		if version, err := client.ServerVersion(ctx); err != nil {
+			glog.Warningf("DockerProvider: couldn't get docker's api version, assuming at least 1.23: %v", err)
+		} else {
+			d.securityOptSeparator = securityOptSeparator(version.APIVersion)
+		}
+
+		go d.watchEvents()
+
 		return d, nil
 	}
 }
@@ -72,14 +87,34 @@ func NewDockerProvider() (vmserver.ContainerProvider, error) {
 func createMountablePaths() {
 }
 
-// FIXME: A lot of things to support (ala full linux/security context)
+func (d *dockerProvider) Features() []string {
+	return []string{icommon.FeatureLogs, icommon.FeatureExec, icommon.FeatureAttach, icommon.FeaturePortForward, icommon.FeatureExecSync, icommon.FeatureRegistryAuth}
+}
+
+func (d *dockerProvider) Ready() error {
+	ctx, cancel := getTimeoutContext()
+	defer cancel()
+
+	if _, err := d.client.Info(ctx); err != nil {
+		return fmt.Errorf("docker isn't ready: %v", err)
+	}
+
+	return nil
+}
+
 func (d *dockerProvider) CreateContainer(req *kubeapi.CreateContainerRequest) (*kubeapi.CreateContainerResponse, error) {
+	resp, _, err := d.CreateContainerWithAuth(req, nil)
+
+	return resp, err
+}
+
+func (d *dockerProvider) CreateContainerWithAuth(req *kubeapi.CreateContainerRequest, auth *kubeapi.AuthConfig) (*kubeapi.CreateContainerResponse, string, error) {
 	config := req.Config
 	podSandboxID := req.GetPodSandboxId()
 
 	sharedPaths, err := processSharedPaths(config.Annotations)
 	if err != nil {
-		return nil, fmt.Errorf("ContainerCreate Failed: %v", err)
+		return nil, "", fmt.Errorf("ContainerCreate Failed: %v", err)
 	}
 
 	labels := common.MakeLabels(config.Labels, config.Annotations)
@@ -97,26 +132,14 @@ func (d *dockerProvider) CreateContainer(req *kubeapi.CreateContainerRequest) (*
 		image = iSpec.GetImage()
 	}
 
+	// kubelet already applied the pod's pull policy through infranetes, so of the images the VM has only untagged
+	// and :latest ones are pulled again
+	imageRef := ""
 	if image != "" {
-		pullresp, err := d.client.ImagePull(context.Background(), image, dockertypes.ImagePullOptions{})
+		imageRef, err = common.PullDockerImage(d.client, image, auth, false)
 		if err != nil {
-			return nil, fmt.Errorf("ImagePull Failed (%v)\n", err)
+			return nil, "", err
 		}
-
-		decoder := json.NewDecoder(pullresp)
-		for {
-			var msg interface{}
-			err := decoder.Decode(&msg)
-
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				return nil, fmt.Errorf("Pull Image failed: %v", err)
-			}
-		}
-
-		pullresp.Close()
 	}
 
 	createConfig := &dockercontainer.Config{
@@ -139,6 +162,7 @@ func (d *dockerProvider) CreateContainer(req *kubeapi.CreateContainerRequest) (*
 		PidMode:     "host",
 		NetworkMode: "host",
 		UTSMode:     "host",
+		LogConfig:   logConfig(*flags.LogMaxSize, *flags.LogMaxFiles),
 	}
 	if req.SandboxConfig.DnsConfig != nil {
 		hostConfig.DNS = req.SandboxConfig.DnsConfig.Servers
@@ -146,8 +170,9 @@ func (d *dockerProvider) CreateContainer(req *kubeapi.CreateContainerRequest) (*
 		hostConfig.DNSSearch = req.SandboxConfig.DnsConfig.Searches
 	}
 
-	if req.SandboxConfig.GetLinux().GetSecurityContext().GetPrivileged() {
-		hostConfig.Privileged = true
+	applyResources(config.GetLinux().GetResources(), hostConfig)
+	if err := applySecurityContext(req, createConfig, hostConfig, d.securityOptSeparator, *flags.SeccompProfileRoot); err != nil {
+		return nil, "", fmt.Errorf("ContainerCreate Failed: %v", err)
 	}
 
 	devices := make([]dockercontainer.DeviceMapping, len(config.Devices))
@@ -162,7 +187,7 @@ func (d *dockerProvider) CreateContainer(req *kubeapi.CreateContainerRequest) (*
 
 	dockResp, err := d.client.ContainerCreate(context.Background(), createConfig, hostConfig, nil, "")
 	if err != nil {
-		return nil, fmt.Errorf("ContainerCreate Failed: %v", err)
+		return nil, "", fmt.Errorf("ContainerCreate Failed: %v", err)
 	}
 
 	id := podSandboxID + ":" + dockResp.ID
@@ -171,7 +196,7 @@ func (d *dockerProvider) CreateContainer(req *kubeapi.CreateContainerRequest) (*
 		ContainerId: id,
 	}
 
-	return resp, nil
+	return resp, imageRef, nil
 }
 
 func processSharedPaths(annotations map[string]string) (map[string]bool, error) {
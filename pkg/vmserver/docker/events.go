@@ -0,0 +1,103 @@
+package docker
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+
+	dockertypes "github.com/docker/engine-api/types"
+	dockerfilters "github.com/docker/engine-api/types/filters"
+
+	icommon "github.com/apporbit/infranetes/pkg/common"
+	"github.com/apporbit/infranetes/pkg/vmserver/common"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+const eventsRetryInterval = 5 * time.Second
+
+// dockerEvent is the subset of the docker events api message we care about
+type dockerEvent struct {
+	Type   string
+	Action string
+	Actor  struct {
+		ID         string
+		Attributes map[string]string
+	}
+	TimeNano int64 `json:"timeNano"`
+}
+
+var dockerEventTypes = map[string]icommon.ContainerEventType{
+	"create":  icommon.ContainerEventType_CREATED,
+	"start":   icommon.ContainerEventType_STARTED,
+	"die":     icommon.ContainerEventType_STOPPED,
+	"destroy": icommon.ContainerEventType_REMOVED,
+}
+
+func (d *dockerProvider) Events() *common.EventBus {
+	return d.events
+}
+
+// watchEvents translates the docker event stream onto the provider's event bus, reconnecting if docker goes away
+func (d *dockerProvider) watchEvents() {
+	for {
+		err := d.readEvents()
+		glog.Warningf("watchEvents: docker event stream ended: %v, retrying in %v", err, eventsRetryInterval)
+		time.Sleep(eventsRetryInterval)
+	}
+}
+
+func (d *dockerProvider) readEvents() error {
+	opts := dockertypes.EventsOptions{Filters: dockerfilters.NewArgs()}
+	opts.Filters.Add("type", "container")
+	opts.Filters.Add("label", podSandboxIDLabel)
+
+	body, err := d.client.Events(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	decoder := json.NewDecoder(body)
+	for {
+		var event dockerEvent
+		if err := decoder.Decode(&event); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		d.handleEvent(&event)
+	}
+}
+
+func (d *dockerProvider) handleEvent(event *dockerEvent) {
+	eventType, ok := dockerEventTypes[event.Action]
+	if !ok {
+		return
+	}
+
+	podId, ok := event.Actor.Attributes[podSandboxIDLabel]
+	if !ok {
+		glog.V(2).Infof("handleEvent: ignoring %v event for non infranetes container %v", event.Action, event.Actor.ID)
+		return
+	}
+
+	id := podId + ":" + event.Actor.ID
+
+	var status *kubeapi.ContainerStatus
+	if eventType != icommon.ContainerEventType_REMOVED {
+		resp, err := d.ContainerStatus(&kubeapi.ContainerStatusRequest{ContainerId: id})
+		if err != nil {
+			glog.Warningf("handleEvent: couldn't get status for %v: %v", id, err)
+			return
+		}
+		status = resp.Status
+	}
+
+	d.events.PublishStatus(id, eventType, status)
+}
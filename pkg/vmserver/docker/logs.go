@@ -2,36 +2,115 @@ package docker
 
 import (
 	"fmt"
+	"os"
+	"strconv"
+	"time"
 
+	dockercontainer "github.com/docker/engine-api/types/container"
 	"github.com/golang/glog"
 	"golang.org/x/net/context"
 
 	"github.com/hpcloud/tail"
 
 	"github.com/apporbit/infranetes/pkg/common"
+	vmcommon "github.com/apporbit/infranetes/pkg/vmserver/common"
 )
 
+// logConfig has docker rotate a container's json log at maxSize bytes, the unit infranetes' -container-log-max-size
+// is in as well, keeping maxFiles of them, or never if maxSize is 0
+func logConfig(maxSize int64, maxFiles int) dockercontainer.LogConfig {
+	config := dockercontainer.LogConfig{Type: "json-file", Config: map[string]string{}}
+	if maxSize > 0 {
+		// docker takes sizes without a unit as bytes
+		config.Config["max-size"] = strconv.FormatInt(maxSize, 10)
+		config.Config["max-file"] = strconv.Itoa(maxFiles)
+	}
+
+	return config
+}
+
 func (d *dockerProvider) Logs(req *common.LogsRequest, stream common.VMServer_LogsServer) error {
 	resp, err := d.client.ContainerInspect(context.Background(), req.ContainerID)
 	if err != nil {
 		return fmt.Errorf("Logs: docker container inspect failed")
 	}
 
-	t, err := tail.TailFile(resp.LogPath, tail.Config{Follow: true})
+	offset := req.Offset
+
+	// docker rotates the json log by renaming it, which ends the tail.  Start again at the top of the new file
+	// until the tail ends without the log having been replaced, i.e. RemoveContainer stopped it.
+	for {
+		info, err := os.Stat(resp.LogPath)
+		if err != nil {
+			return fmt.Errorf("Logs: stat of %v failed: %v", resp.LogPath, err)
+		}
+		if info.Size() < offset {
+			glog.Infof("Logs: %v is shorter than requested offset %v, starting from the beginning", resp.LogPath, offset)
+			offset = 0
+		}
+
+		offset, err = d.tailLog(req.ContainerID, resp.LogPath, offset, stream)
+		if err != nil {
+			return err
+		}
+
+		current, err := statRotatedLog(resp.LogPath)
+		if err != nil || os.SameFile(info, current) {
+			return nil
+		}
+
+		glog.Infof("Logs: %v was rotated, following the new file", resp.LogPath)
+		offset = 0
+	}
+}
+
+// tailLog streams path from offset until the tail is stopped, returning the offset it got to
+func (d *dockerProvider) tailLog(id string, path string, offset int64, stream common.VMServer_LogsServer) (int64, error) {
+	config := tail.Config{
+		Follow:   true,
+		Location: &tail.SeekInfo{Offset: offset, Whence: os.SEEK_SET},
+	}
+
+	t, err := tail.TailFile(path, config)
 	if err != nil {
-		return fmt.Errorf("Logs: tail failed")
+		return offset, fmt.Errorf("Logs: tail failed")
 	}
 
-	d.setTail(req.ContainerID, t)
+	d.setTail(id, t)
 
 	for line := range t.Lines {
-		glog.Infof("Log: line = %v", line.Text)
-		err = stream.Send(&common.LogLine{LogLine: line.Text})
+		// tail strips the newline docker terminates every entry with
+		offset += int64(len(line.Text)) + 1
+
+		logLine, err := vmcommon.ParseJsonLogLine(line.Text)
+		if err != nil {
+			glog.Warningf("Logs: skipping unparseable line in %v: %v", path, err)
+			continue
+		}
+		logLine.Offset = offset
+
+		glog.V(5).Infof("Log: line = %+v", logLine)
+		err = stream.Send(logLine)
 		if err != nil {
 			glog.Warningf("Log: Client side Ended early?: %v", err)
-			break
+			t.Stop()
+			return offset, err
+		}
+	}
+
+	return offset, nil
+}
+
+// statRotatedLog gives docker a moment to create the replacement of a log it just rotated
+func statRotatedLog(path string) (os.FileInfo, error) {
+	var err error
+	for i := 0; i < 10; i++ {
+		var info os.FileInfo
+		if info, err = os.Stat(path); err == nil {
+			return info, nil
 		}
+		time.Sleep(100 * time.Millisecond)
 	}
 
-	return err
+	return nil, err
 }
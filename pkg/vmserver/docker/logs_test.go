@@ -0,0 +1,18 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLogConfig(t *testing.T) {
+	config := logConfig(10*1024*1024, 3)
+	if config.Type != "json-file" || !reflect.DeepEqual(config.Config, map[string]string{"max-size": "10485760", "max-file": "3"}) {
+		t.Errorf("got %+v", config)
+	}
+
+	// as with infranetes' logs, 0 doesn't rotate them
+	if config := logConfig(0, 3); len(config.Config) != 0 {
+		t.Errorf("got %+v", config)
+	}
+}
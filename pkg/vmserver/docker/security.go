@@ -0,0 +1,151 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	dockercontainer "github.com/docker/engine-api/types/container"
+
+	"k8s.io/kubernetes/pkg/api/v1"
+	"k8s.io/kubernetes/pkg/kubelet/dockershim/securitycontext"
+	"k8s.io/kubernetes/pkg/security/apparmor"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+// The CRI version we implement has no field for no_new_privs, so it's asked for with this annotation on the container
+const noNewPrivsAnnotation = "infranetes.no_new_privs"
+
+// securityOptSeparator returns what separates the keys and values of security options for a docker api version, which
+// changed from : to = in 1.23
+func securityOptSeparator(apiVersion string) rune {
+	parts := strings.SplitN(apiVersion, ".", 3)
+	if len(parts) < 2 {
+		return '='
+	}
+
+	major, err1 := strconv.Atoi(parts[0])
+	minor, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return '='
+	}
+
+	if major == 1 && minor < 23 {
+		return ':'
+	}
+
+	return '='
+}
+
+// applyResources maps the container's cgroup settings onto its host config
+func applyResources(r *kubeapi.LinuxContainerResources, hostConfig *dockercontainer.HostConfig) {
+	if r == nil {
+		return
+	}
+
+	hostConfig.Resources.CPUShares = r.CpuShares
+	hostConfig.Resources.CPUQuota = r.CpuQuota
+	hostConfig.Resources.CPUPeriod = r.CpuPeriod
+	hostConfig.Resources.Memory = r.MemoryLimitInBytes
+	hostConfig.OomScoreAdj = int(r.OomScoreAdj)
+}
+
+// applySecurityContext maps the container's security context, and the seccomp profile its pod's annotations ask
+// for, onto its config and host config
+func applySecurityContext(req *kubeapi.CreateContainerRequest, config *dockercontainer.Config, hostConfig *dockercontainer.HostConfig, separator rune, seccompProfileRoot string) error {
+	sc := req.GetConfig().GetLinux().GetSecurityContext()
+
+	hostConfig.Privileged = sc.GetPrivileged() || req.GetSandboxConfig().GetLinux().GetSecurityContext().GetPrivileged()
+
+	if sc.GetRunAsUser() != nil {
+		config.User = strconv.FormatInt(sc.GetRunAsUser().Value, 10)
+	}
+	if sc.GetRunAsUsername() != "" {
+		config.User = sc.GetRunAsUsername()
+	}
+
+	for _, group := range sc.GetSupplementalGroups() {
+		hostConfig.GroupAdd = append(hostConfig.GroupAdd, strconv.FormatInt(group, 10))
+	}
+
+	hostConfig.ReadonlyRootfs = sc.GetReadonlyRootfs()
+
+	// privileged containers get everything, docker ignores the rest
+	if hostConfig.Privileged {
+		return nil
+	}
+
+	hostConfig.CapAdd = sc.GetCapabilities().GetAddCapabilities()
+	hostConfig.CapDrop = sc.GetCapabilities().GetDropCapabilities()
+
+	if opts := sc.GetSelinuxOptions(); opts != nil {
+		hostConfig.SecurityOpt = securitycontext.ModifySecurityOptions(hostConfig.SecurityOpt,
+			&v1.SELinuxOptions{
+				User:  opts.User,
+				Role:  opts.Role,
+				Type:  opts.Type,
+				Level: opts.Level,
+			},
+			separator)
+	}
+
+	if profile := sc.GetApparmorProfile(); profile != "" && profile != apparmor.ProfileRuntimeDefault {
+		if !strings.HasPrefix(profile, apparmor.ProfileNamePrefix) {
+			return fmt.Errorf("unknown apparmor profile %q", profile)
+		}
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, fmt.Sprintf("apparmor%c%s", separator, strings.TrimPrefix(profile, apparmor.ProfileNamePrefix)))
+	}
+
+	seccomp, err := seccompProfile(req.GetSandboxConfig().GetAnnotations(), req.GetConfig().GetMetadata().GetName(), seccompProfileRoot)
+	if err != nil {
+		return err
+	}
+	if seccomp != "" {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, fmt.Sprintf("seccomp%c%s", separator, seccomp))
+	}
+
+	if req.GetConfig().GetAnnotations()[noNewPrivsAnnotation] == "true" {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "no-new-privileges")
+	}
+
+	return nil
+}
+
+// seccompProfile returns the seccomp option for the container, following kubelet: the container's annotation wins over
+// the pod's, no annotation means unconfined and "" means docker's default profile
+func seccompProfile(annotations map[string]string, name string, profileRoot string) (string, error) {
+	profile, ok := annotations[v1.SeccompContainerAnnotationKeyPrefix+name]
+	if !ok {
+		if profile, ok = annotations[v1.SeccompPodAnnotationKey]; !ok {
+			return "unconfined", nil
+		}
+	}
+
+	switch {
+	case profile == "unconfined":
+		return "unconfined", nil
+	case profile == "docker/default":
+		return "", nil
+	case !strings.HasPrefix(profile, "localhost/"):
+		return "", fmt.Errorf("unknown seccomp profile %q", profile)
+	}
+
+	// the profile has to be on the VM, as that's where docker runs
+	path := filepath.Join(profileRoot, filepath.Clean("/"+strings.TrimPrefix(profile, "localhost/")))
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("couldn't load seccomp profile %v: %v", profile, err)
+	}
+
+	compact := bytes.NewBuffer(nil)
+	if err := json.Compact(compact, data); err != nil {
+		return "", fmt.Errorf("couldn't parse seccomp profile %v: %v", profile, err)
+	}
+
+	return compact.String(), nil
+}
@@ -0,0 +1,203 @@
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	dockercontainer "github.com/docker/engine-api/types/container"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+func testRequest(sc *kubeapi.LinuxContainerSecurityContext, annotations map[string]string) *kubeapi.CreateContainerRequest {
+	return &kubeapi.CreateContainerRequest{
+		Config: &kubeapi.ContainerConfig{
+			Metadata:    &kubeapi.ContainerMetadata{Name: "app"},
+			Linux:       &kubeapi.LinuxContainerConfig{SecurityContext: sc},
+			Annotations: annotations,
+		},
+		SandboxConfig: &kubeapi.PodSandboxConfig{
+			Annotations: map[string]string{"seccomp.security.alpha.kubernetes.io/pod": "docker/default"},
+		},
+	}
+}
+
+func TestApplyResources(t *testing.T) {
+	hostConfig := &dockercontainer.HostConfig{}
+	applyResources(&kubeapi.LinuxContainerResources{
+		CpuShares:          512,
+		CpuQuota:           50000,
+		CpuPeriod:          100000,
+		MemoryLimitInBytes: 64 << 20,
+		OomScoreAdj:        -998,
+	}, hostConfig)
+
+	if hostConfig.CPUShares != 512 || hostConfig.CPUQuota != 50000 || hostConfig.CPUPeriod != 100000 {
+		t.Errorf("cpu = %v/%v/%v", hostConfig.CPUShares, hostConfig.CPUQuota, hostConfig.CPUPeriod)
+	}
+	if hostConfig.Memory != 64<<20 {
+		t.Errorf("memory = %v", hostConfig.Memory)
+	}
+	if hostConfig.OomScoreAdj != -998 {
+		t.Errorf("oom score adj = %v", hostConfig.OomScoreAdj)
+	}
+
+	// nil resources leave the host config alone
+	applyResources(nil, hostConfig)
+	if hostConfig.CPUShares != 512 {
+		t.Errorf("nil resources changed the host config")
+	}
+}
+
+func TestApplySecurityContext(t *testing.T) {
+	sc := &kubeapi.LinuxContainerSecurityContext{
+		RunAsUser:          &kubeapi.Int64Value{Value: 1000},
+		SupplementalGroups: []int64{10, 20},
+		ReadonlyRootfs:     true,
+		Capabilities:       &kubeapi.Capability{AddCapabilities: []string{"NET_ADMIN"}, DropCapabilities: []string{"MKNOD"}},
+		SelinuxOptions:     &kubeapi.SELinuxOption{Type: "svirt_lxc_net_t"},
+		ApparmorProfile:    "localhost/restricted",
+	}
+
+	config := &dockercontainer.Config{}
+	hostConfig := &dockercontainer.HostConfig{}
+	if err := applySecurityContext(testRequest(sc, map[string]string{noNewPrivsAnnotation: "true"}), config, hostConfig, '=', ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if config.User != "1000" {
+		t.Errorf("user = %v", config.User)
+	}
+	if !reflect.DeepEqual(hostConfig.GroupAdd, []string{"10", "20"}) {
+		t.Errorf("groups = %v", hostConfig.GroupAdd)
+	}
+	if !hostConfig.ReadonlyRootfs {
+		t.Errorf("rootfs should be readonly")
+	}
+	if hostConfig.Privileged {
+		t.Errorf("shouldn't be privileged")
+	}
+	if !reflect.DeepEqual([]string(hostConfig.CapAdd), []string{"NET_ADMIN"}) || !reflect.DeepEqual([]string(hostConfig.CapDrop), []string{"MKNOD"}) {
+		t.Errorf("caps = +%v -%v", hostConfig.CapAdd, hostConfig.CapDrop)
+	}
+
+	want := []string{"label=type:svirt_lxc_net_t", "apparmor=restricted", "no-new-privileges"}
+	if !reflect.DeepEqual(hostConfig.SecurityOpt, want) {
+		t.Errorf("security opts = %v, want %v", hostConfig.SecurityOpt, want)
+	}
+}
+
+func TestApplySecurityContextUsername(t *testing.T) {
+	config := &dockercontainer.Config{}
+	if err := applySecurityContext(testRequest(&kubeapi.LinuxContainerSecurityContext{RunAsUsername: "www-data"}, nil), config, &dockercontainer.HostConfig{}, '=', ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if config.User != "www-data" {
+		t.Errorf("user = %v", config.User)
+	}
+}
+
+func TestApplySecurityContextPrivileged(t *testing.T) {
+	sc := &kubeapi.LinuxContainerSecurityContext{
+		Capabilities:    &kubeapi.Capability{DropCapabilities: []string{"ALL"}},
+		ApparmorProfile: "localhost/restricted",
+	}
+
+	// a privileged sandbox makes its containers privileged
+	req := testRequest(sc, nil)
+	req.SandboxConfig.Linux = &kubeapi.LinuxPodSandboxConfig{
+		SecurityContext: &kubeapi.LinuxSandboxSecurityContext{Privileged: true},
+	}
+
+	hostConfig := &dockercontainer.HostConfig{}
+	if err := applySecurityContext(req, &dockercontainer.Config{}, hostConfig, '=', ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if !hostConfig.Privileged {
+		t.Errorf("should be privileged")
+	}
+	if len(hostConfig.CapDrop) != 0 || len(hostConfig.SecurityOpt) != 0 {
+		t.Errorf("privileged container was confined: -%v %v", hostConfig.CapDrop, hostConfig.SecurityOpt)
+	}
+}
+
+func TestApplySecurityContextOldDocker(t *testing.T) {
+	sc := &kubeapi.LinuxContainerSecurityContext{ApparmorProfile: "localhost/restricted"}
+
+	hostConfig := &dockercontainer.HostConfig{}
+	if err := applySecurityContext(testRequest(sc, nil), &dockercontainer.Config{}, hostConfig, securityOptSeparator("1.22"), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(hostConfig.SecurityOpt, []string{"apparmor:restricted"}) {
+		t.Errorf("security opts = %v", hostConfig.SecurityOpt)
+	}
+}
+
+func TestApplySecurityContextErrors(t *testing.T) {
+	sc := &kubeapi.LinuxContainerSecurityContext{ApparmorProfile: "restricted"}
+	if err := applySecurityContext(testRequest(sc, nil), &dockercontainer.Config{}, &dockercontainer.HostConfig{}, '=', ""); err == nil {
+		t.Errorf("expected an error for an apparmor profile without localhost/")
+	}
+
+	req := testRequest(nil, nil)
+	req.SandboxConfig.Annotations["seccomp.security.alpha.kubernetes.io/pod"] = "bogus"
+	if err := applySecurityContext(req, &dockercontainer.Config{}, &dockercontainer.HostConfig{}, '=', ""); err == nil {
+		t.Errorf("expected an error for an unknown seccomp profile")
+	}
+}
+
+func TestSeccompProfile(t *testing.T) {
+	root, err := ioutil.TempDir("", "seccomp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	if err := ioutil.WriteFile(filepath.Join(root, "audit.json"), []byte("{\n  \"defaultAction\": \"SCMP_ACT_LOG\"\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		annotations map[string]string
+		want        string
+		err         bool
+	}{
+		{annotations: nil, want: "unconfined"},
+		{annotations: map[string]string{"seccomp.security.alpha.kubernetes.io/pod": "docker/default"}, want: ""},
+		{annotations: map[string]string{"seccomp.security.alpha.kubernetes.io/pod": "localhost/audit.json"}, want: `{"defaultAction":"SCMP_ACT_LOG"}`},
+		// the container's annotation wins over the pod's
+		{annotations: map[string]string{
+			"seccomp.security.alpha.kubernetes.io/pod":           "docker/default",
+			"container.seccomp.security.alpha.kubernetes.io/app": "unconfined",
+		}, want: "unconfined"},
+		{annotations: map[string]string{"seccomp.security.alpha.kubernetes.io/pod": "localhost/missing.json"}, err: true},
+		{annotations: map[string]string{"seccomp.security.alpha.kubernetes.io/pod": "localhost/../../etc/passwd"}, err: true},
+	} {
+		got, err := seccompProfile(test.annotations, "app", root)
+		if test.err {
+			if err == nil {
+				t.Errorf("%v: expected an error", test.annotations)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", test.annotations, err)
+		} else if got != test.want {
+			t.Errorf("%v: got %q, want %q", test.annotations, got, test.want)
+		}
+	}
+}
+
+func TestSecurityOptSeparator(t *testing.T) {
+	for version, want := range map[string]rune{"1.22": ':', "1.23": '=', "1.40": '=', "": '='} {
+		if got := securityOptSeparator(version); got != want {
+			t.Errorf("securityOptSeparator(%q) = %c, want %c", version, got, want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package vmserver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/apporbit/infranetes/pkg/common"
+)
+
+func (m *VMserver) WatchContainerEvents(req *common.WatchContainerEventsRequest, stream common.VMServer_WatchContainerEventsServer) error {
+	glog.Infof("WatchContainerEvents: starting watch")
+
+	events, cancel := m.contProvider.Events().Subscribe()
+	defer cancel()
+
+	// the watcher lists the containers once it knows no later change can be missed
+	if err := stream.Send(&common.ContainerEvent{Type: common.ContainerEventType_SYNCED, Timestamp: time.Now().UnixNano()}); err != nil {
+		glog.Warningf("WatchContainerEvents: send failed: %v", err)
+		return err
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			glog.Infof("WatchContainerEvents: watcher went away: %v", stream.Context().Err())
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				// the watcher has missed events, it resyncs when it watches again
+				glog.Warningf("WatchContainerEvents: watcher fell behind")
+				return fmt.Errorf("WatchContainerEvents: watcher fell behind, events were dropped")
+			}
+			glog.V(2).Infof("WatchContainerEvents: sending %v event for %v", event.Type, event.ContainerID)
+			if err := stream.Send(event); err != nil {
+				glog.Warningf("WatchContainerEvents: send failed: %v", err)
+				return err
+			}
+		}
+	}
+}
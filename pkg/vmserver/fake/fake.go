@@ -16,7 +16,9 @@ func NewFakeProvider() (vmserver.ContainerProvider, error) {
 	glog.Info("NewFakeProvider: starting")
 	fake := &fakeProvider{
 		fakeContainerProvider: &fakeContainerProvider{
-			contMap: make(map[string]*common.Container),
+			contMap:    make(map[string]*common.Container),
+			logSources: make(map[string]string),
+			events:     common.NewEventBus(),
 		},
 		fakeExecProvider: &fakeExecProvider{},
 	}
@@ -33,7 +35,9 @@ func NewPodExecProvider() (vmserver.ContainerProvider, error) {
 	glog.Info("NewPodExecProvider: starting")
 	fake := &execProvider{
 		fakeContainerProvider: &fakeContainerProvider{
-			contMap: make(map[string]*common.Container),
+			contMap:    make(map[string]*common.Container),
+			logSources: make(map[string]string),
+			events:     common.NewEventBus(),
 		},
 		podExecProvider: &podExecProvider{},
 	}
@@ -6,14 +6,22 @@ import (
 
 	"github.com/golang/glog"
 
+	icommon "github.com/apporbit/infranetes/pkg/common"
 	"github.com/apporbit/infranetes/pkg/vmserver/common"
 
 	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
 )
 
 type fakeContainerProvider struct {
-	contMap map[string]*common.Container
-	mapLock sync.Mutex
+	contMap    map[string]*common.Container
+	logSources map[string]string
+	mapLock    sync.Mutex
+	events     *common.EventBus
+	stateDir   string
+}
+
+func (f *fakeContainerProvider) Events() *common.EventBus {
+	return f.events
 }
 
 func (p *fakeContainerProvider) Lock() {
@@ -49,6 +57,14 @@ func (p *fakeContainerProvider) Unlock() {
 	} */
 }
 
+// persist saves the container table, which only costs a restarted vmserver its containers if it fails.  Callers hold
+// mapLock.
+func (f *fakeContainerProvider) persist() {
+	if err := f.save(); err != nil {
+		glog.Warningf("fakeProvider: couldn't save containers: %v", err)
+	}
+}
+
 func (f *fakeContainerProvider) CreateContainer(req *kubeapi.CreateContainerRequest) (*kubeapi.CreateContainerResponse, error) {
 	f.Lock()
 	defer f.Unlock()
@@ -56,6 +72,13 @@ func (f *fakeContainerProvider) CreateContainer(req *kubeapi.CreateContainerRequ
 	glog.Infof("CreateContainer: req.Config.Image.Image = %v", req.Config.Image.Image)
 
 	id := req.GetPodSandboxId() + ":" + req.Config.Metadata.GetName()
+
+	spec := common.LogSourcesSpec(req)
+	if _, err := common.ParseLogSources(spec); err != nil {
+		return nil, fmt.Errorf("CreateContainer: %v", err)
+	}
+	f.logSources[id] = spec
+
 	f.contMap[id] = common.NewContainer(&id,
 		&req.PodSandboxId,
 		kubeapi.ContainerState_CONTAINER_CREATED,
@@ -64,6 +87,8 @@ func (f *fakeContainerProvider) CreateContainer(req *kubeapi.CreateContainerRequ
 		req.Config.Mounts,
 		req.Config.Labels,
 		req.Config.Annotations)
+	f.persist()
+	f.events.PublishStatus(id, icommon.ContainerEventType_CREATED, f.contMap[id].ToKubeStatus())
 
 	return &kubeapi.CreateContainerResponse{ContainerId: id}, nil
 }
@@ -77,6 +102,8 @@ func (f *fakeContainerProvider) StartContainer(req *kubeapi.StartContainerReques
 		return nil, fmt.Errorf("StartContainer: Invalid ContainerID: %v", id)
 	} else {
 		cont.Start()
+		f.persist()
+		f.events.PublishStatus(id, icommon.ContainerEventType_STARTED, cont.ToKubeStatus())
 		return &kubeapi.StartContainerResponse{}, nil
 	}
 }
@@ -90,6 +117,8 @@ func (f *fakeContainerProvider) StopContainer(req *kubeapi.StopContainerRequest)
 		return nil, fmt.Errorf("StopContainer: Invalid ContainerID: %v", id)
 	} else {
 		cont.Finished()
+		f.persist()
+		f.events.PublishStatus(id, icommon.ContainerEventType_STOPPED, cont.ToKubeStatus())
 		return &kubeapi.StopContainerResponse{}, nil
 	}
 }
@@ -103,6 +132,9 @@ func (f *fakeContainerProvider) RemoveContainer(req *kubeapi.RemoveContainerRequ
 		return nil, fmt.Errorf("RemoveContainer: Invalid ContainerID: %v", id)
 	} else {
 		delete(f.contMap, id)
+		delete(f.logSources, id)
+		f.persist()
+		f.events.PublishStatus(id, icommon.ContainerEventType_REMOVED, nil)
 		return &kubeapi.RemoveContainerResponse{}, nil
 	}
 }
@@ -174,3 +206,30 @@ func (f *fakeContainerProvider) ContainerStatus(req *kubeapi.ContainerStatusRequ
 		return resp, nil
 	}
 }
+
+// Logs streams the guest logs selected by the container's common.LogSourcesAnnotation, as VM pods have no
+// container log of their own
+func (f *fakeContainerProvider) Logs(req *icommon.LogsRequest, stream icommon.VMServer_LogsServer) error {
+	f.Lock()
+	spec, ok := "", false
+	for id, s := range f.logSources {
+		if _, name, err := icommon.ParseContainer(id); err == nil && (id == req.ContainerID || name == req.ContainerID) {
+			spec, ok = s, true
+			break
+		}
+	}
+	f.Unlock()
+
+	if !ok {
+		return fmt.Errorf("Logs: Invalid ContainerID: %v", req.ContainerID)
+	}
+
+	sources, err := common.ParseLogSources(spec)
+	if err != nil {
+		return fmt.Errorf("Logs: %v", err)
+	}
+
+	glog.Infof("Logs: streaming %v for %v", spec, req.ContainerID)
+
+	return common.StreamGuestLogs(stream.Context(), sources, req.Offset, stream.Send)
+}
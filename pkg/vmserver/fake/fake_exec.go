@@ -1,8 +1,6 @@
 package fake
 
 import (
-	"fmt"
-
 	"k8s.io/kubernetes/pkg/kubelet/server/streaming"
 
 	icommon "github.com/apporbit/infranetes/pkg/common"
@@ -27,6 +25,10 @@ func (f *fakeExecProvider) GetStreamingRuntime() streaming.Runtime {
 	return nil
 }
 
-func (d *fakeExecProvider) Logs(req *icommon.LogsRequest, stream icommon.VMServer_LogsServer) error {
-	return fmt.Errorf("Logging not supported in fake containers")
+func (f *fakeExecProvider) Features() []string {
+	return []string{icommon.FeatureExecSync, icommon.FeatureLogs}
+}
+
+func (f *fakeExecProvider) Ready() error {
+	return nil
 }
@@ -23,8 +23,12 @@ func (f *podExecProvider) GetStreamingRuntime() streaming.Runtime {
 	return nil
 }
 
-func (d *podExecProvider) Logs(req *icommon.LogsRequest, stream icommon.VMServer_LogsServer) error {
-	return fmt.Errorf("Logging not currently support in vm mode yet")
+func (p *podExecProvider) Features() []string {
+	return []string{icommon.FeatureExecSync, icommon.FeatureLogs}
+}
+
+func (p *podExecProvider) Ready() error {
+	return nil
 }
 
 type streamingRuntime struct{}
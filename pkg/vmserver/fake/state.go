@@ -0,0 +1,134 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/glog"
+
+	"github.com/apporbit/infranetes/pkg/vmserver/common"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+// stateFile holds the container table, as the fake providers have no runtime to recover it from
+const stateFile = "containers.json"
+
+type containerRecord struct {
+	Id          string
+	PodId       string
+	Metadata    *kubeapi.ContainerMetadata
+	Image       *kubeapi.ImageSpec
+	Mounts      []*kubeapi.Mount
+	Labels      map[string]string
+	Annotations map[string]string
+	State       kubeapi.ContainerState
+	CreatedAt   int64
+	StartedAt   int64
+	FinishedAt  int64
+	ExitCode    int32
+	Reason      string
+	LogSources  string
+}
+
+func newRecord(cont *common.Container, logSources string) *containerRecord {
+	status := cont.ToKubeStatus()
+
+	return &containerRecord{
+		Id:          *cont.GetId(),
+		PodId:       *cont.GetPodId(),
+		Metadata:    cont.GetMetadata(),
+		Image:       cont.GetImage(),
+		Mounts:      cont.GetMounts(),
+		Labels:      cont.GetLabels(),
+		Annotations: cont.GetAnnotations(),
+		State:       status.State,
+		CreatedAt:   status.CreatedAt,
+		StartedAt:   status.StartedAt,
+		FinishedAt:  status.FinishedAt,
+		ExitCode:    status.ExitCode,
+		Reason:      status.Reason,
+		LogSources:  logSources,
+	}
+}
+
+func (r *containerRecord) toContainer() *common.Container {
+	cont := common.NewContainer(&r.Id, &r.PodId, r.State, r.Metadata, r.Image, r.Mounts, r.Labels, r.Annotations)
+	cont.SetCreatedAt(r.CreatedAt)
+	cont.UpdateState(r.State, r.StartedAt, r.FinishedAt, r.ExitCode, r.Reason)
+
+	return cont
+}
+
+// save writes the container table to the provider's state dir.  Callers hold mapLock.
+func (f *fakeContainerProvider) save() error {
+	if f.stateDir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(f.stateDir, 0700); err != nil {
+		return err
+	}
+
+	records := []*containerRecord{}
+	for id, cont := range f.contMap {
+		records = append(records, newRecord(cont, f.logSources[id]))
+	}
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(f.stateDir, stateFile)
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// RestoreState restores the containers a previous vmserver saved in dir and keeps the table there from now on.  The
+// fake providers infranetes runs in process never call it, so they keep theirs in memory.
+func (f *fakeContainerProvider) RestoreState(dir string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	f.stateDir = dir
+	if err := f.load(); err != nil {
+		return fmt.Errorf("couldn't restore containers: %v", err)
+	}
+	if len(f.contMap) > 0 {
+		glog.Infof("RestoreState: restored %v containers", len(f.contMap))
+	}
+
+	return nil
+}
+
+// load reads the container table a previous vmserver saved.  Callers hold mapLock.
+func (f *fakeContainerProvider) load() error {
+	path := filepath.Join(f.stateDir, stateFile)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var records []*containerRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return fmt.Errorf("couldn't parse %v: %v", path, err)
+	}
+
+	for _, record := range records {
+		f.contMap[record.Id] = record.toContainer()
+		f.logSources[record.Id] = record.LogSources
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package fake
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+func newTestProvider(t *testing.T, dir string) *fakeContainerProvider {
+	provider, err := NewFakeProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := provider.(*fakeProvider).fakeContainerProvider
+	if err := f.RestoreState(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+func TestContainersSurviveRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fake-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := newTestProvider(t, dir)
+
+	for _, name := range []string{"app", "sidecar"} {
+		_, err := f.CreateContainer(&kubeapi.CreateContainerRequest{
+			PodSandboxId: "pod",
+			Config: &kubeapi.ContainerConfig{
+				Metadata: &kubeapi.ContainerMetadata{Name: name},
+				Image:    &kubeapi.ImageSpec{Image: "ami-1234"},
+				Labels:   map[string]string{"app": name},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := f.StartContainer(&kubeapi.StartContainerRequest{ContainerId: "pod:app"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.StopContainer(&kubeapi.StopContainerRequest{ContainerId: "pod:sidecar"}); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := newTestProvider(t, dir)
+
+	if len(restarted.contMap) != 2 {
+		t.Fatalf("restored %v containers, want 2", len(restarted.contMap))
+	}
+	for id, want := range map[string]kubeapi.ContainerState{
+		"pod:app":     kubeapi.ContainerState_CONTAINER_RUNNING,
+		"pod:sidecar": kubeapi.ContainerState_CONTAINER_EXITED,
+	} {
+		before, after := f.contMap[id].ToKubeStatus(), restarted.contMap[id].ToKubeStatus()
+		if after.State != want {
+			t.Errorf("%v: state = %v, want %v", id, after.State, want)
+		}
+		if after.CreatedAt != before.CreatedAt || after.StartedAt != before.StartedAt || after.FinishedAt != before.FinishedAt {
+			t.Errorf("%v: times = %v/%v/%v, want %v/%v/%v", id, after.CreatedAt, after.StartedAt, after.FinishedAt, before.CreatedAt, before.StartedAt, before.FinishedAt)
+		}
+		if after.Labels["app"] != before.Labels["app"] {
+			t.Errorf("%v: labels = %v", id, after.Labels)
+		}
+	}
+	if _, ok := restarted.logSources["pod:app"]; !ok {
+		t.Errorf("log sources weren't restored")
+	}
+
+	if _, err := restarted.RemoveContainer(&kubeapi.RemoveContainerRequest{ContainerId: "pod:sidecar"}); err != nil {
+		t.Fatal(err)
+	}
+	again := newTestProvider(t, dir)
+	if _, ok := again.contMap["pod:sidecar"]; ok || len(again.contMap) != 1 {
+		t.Errorf("removed container was restored: %v", again.contMap)
+	}
+}
@@ -0,0 +1,73 @@
+package vmserver
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+
+	"github.com/apporbit/infranetes/pkg/common"
+
+	utildbus "k8s.io/kubernetes/pkg/util/dbus"
+	"k8s.io/kubernetes/pkg/util/exec"
+	utiliptables "k8s.io/kubernetes/pkg/util/iptables"
+)
+
+// HostPortChain sends traffic for the pod's host ports to its container ports, which the pod's containers listen on
+// in the VM's own network namespace
+const HostPortChain utiliptables.Chain = "INFRANETES-HOSTPORTS"
+
+// SetPortMappings replaces the VM's host port redirects with req's mappings
+func (m *VMserver) SetPortMappings(ctx context.Context, req *common.SetPortMappingsRequest) (*common.SetPortMappingsResponse, error) {
+	for _, mapping := range req.Mappings {
+		if protocol := strings.ToLower(mapping.Protocol); protocol != "tcp" && protocol != "udp" {
+			return nil, fmt.Errorf("SetPortMappings: invalid protocol %q", mapping.Protocol)
+		}
+		if mapping.HostPort < 0 || mapping.HostPort > 65535 || mapping.ContainerPort < 0 || mapping.ContainerPort > 65535 {
+			return nil, fmt.Errorf("SetPortMappings: invalid mapping of %v to %v", mapping.HostPort, mapping.ContainerPort)
+		}
+	}
+
+	protocols := []utiliptables.Protocol{utiliptables.ProtocolIpv4}
+	if m.podIpv6 != nil {
+		protocols = append(protocols, utiliptables.ProtocolIpv6)
+	}
+
+	data := renderHostPortRules(req.Mappings)
+	for _, protocol := range protocols {
+		iptables := utiliptables.New(exec.New(), utildbus.New(), protocol)
+
+		if err := iptables.Restore(utiliptables.TableNAT, data, utiliptables.NoFlushTables, utiliptables.NoRestoreCounters); err != nil {
+			return nil, fmt.Errorf("SetPortMappings: couldn't restore %v: %v", HostPortChain, err)
+		}
+		if _, err := iptables.EnsureRule(utiliptables.Prepend, utiliptables.TableNAT, utiliptables.ChainPrerouting, "-m", "addrtype", "--dst-type", "LOCAL", "-j", string(HostPortChain)); err != nil {
+			return nil, fmt.Errorf("SetPortMappings: couldn't jump to %v: %v", HostPortChain, err)
+		}
+	}
+
+	glog.Infof("SetPortMappings: host ports are %+v", req.Mappings)
+
+	return &common.SetPortMappingsResponse{}, nil
+}
+
+// renderHostPortRules writes the iptables-restore input for the nat table that replaces HostPortChain with redirects
+// for mappings.  Host ports that are their container ports need nothing.
+func renderHostPortRules(mappings []*common.PortMapping) []byte {
+	data := bytes.NewBuffer(nil)
+	data.WriteString("*nat\n")
+	fmt.Fprintf(data, ":%s - [0:0]\n", HostPortChain)
+
+	for _, mapping := range mappings {
+		if mapping.HostPort == 0 || mapping.ContainerPort == 0 || mapping.HostPort == mapping.ContainerPort {
+			continue
+		}
+		protocol := strings.ToLower(mapping.Protocol)
+		fmt.Fprintf(data, "-A %s -p %s -m %s --dport %d -j REDIRECT --to-ports %d\n", HostPortChain, protocol, protocol, mapping.HostPort, mapping.ContainerPort)
+	}
+
+	data.WriteString("COMMIT\n")
+
+	return data.Bytes()
+}
@@ -0,0 +1,31 @@
+package vmserver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/apporbit/infranetes/pkg/common"
+)
+
+func TestRenderHostPortRules(t *testing.T) {
+	data := string(renderHostPortRules([]*common.PortMapping{
+		{Protocol: "TCP", HostPort: 8080, ContainerPort: 80},
+		{Protocol: "udp", HostPort: 5353, ContainerPort: 53},
+		{Protocol: "tcp", HostPort: 443, ContainerPort: 443},
+	}))
+
+	for _, line := range []string{
+		":INFRANETES-HOSTPORTS - [0:0]",
+		"-A INFRANETES-HOSTPORTS -p tcp -m tcp --dport 8080 -j REDIRECT --to-ports 80",
+		"-A INFRANETES-HOSTPORTS -p udp -m udp --dport 5353 -j REDIRECT --to-ports 53",
+	} {
+		if !strings.Contains(data, line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, data)
+		}
+	}
+
+	// a host port that is its container port is reached without a redirect
+	if strings.Contains(data, "443") {
+		t.Errorf("redirect for an unmapped port in:\n%s", data)
+	}
+}
@@ -25,9 +25,8 @@ const (
 	KubeFirewallChain utiliptables.Chain = "KUBE-FIREWALL"
 )
 
-func createTables() {
-	protocol := utiliptables.ProtocolIpv4
-
+// createTables sets up the chains kube-proxy's rules jump to, in the iptables of protocol
+func createTables(protocol utiliptables.Protocol) {
 	execer := exec.New()
 	dbus := utildbus.New()
 	iptClient := utiliptables.New(execer, dbus, protocol)
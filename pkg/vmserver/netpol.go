@@ -0,0 +1,906 @@
+package vmserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+
+	"github.com/apporbit/infranetes/pkg/common"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/kubernetes/pkg/api/v1"
+	"k8s.io/kubernetes/pkg/client/clientset_generated/clientset"
+	utildbus "k8s.io/kubernetes/pkg/util/dbus"
+	"k8s.io/kubernetes/pkg/util/exec"
+	utiliptables "k8s.io/kubernetes/pkg/util/iptables"
+)
+
+const (
+	// traffic to and from the pod ip jumps to these chains, which accept what the pod's policies allow
+	PolicyIngressChain utiliptables.Chain = "INFRANETES-INGRESS"
+	PolicyEgressChain  utiliptables.Chain = "INFRANETES-EGRESS"
+	// peers with ipBlock excepts get a chain of their own, numbered from 0
+	policyPeerChainPrefix = "INFRANETES-PEER-"
+
+	defaultPolicySyncPeriod = 10 * time.Second
+)
+
+// The vendored networking api predates egress policies and ipBlock peers, so policies are read as json into these
+// instead, which follow networking.k8s.io/v1
+
+type networkPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []networkPolicy `json:"items"`
+}
+
+type networkPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              networkPolicySpec `json:"spec"`
+}
+
+type networkPolicySpec struct {
+	PodSelector metav1.LabelSelector `json:"podSelector"`
+	Ingress     []networkPolicyRule  `json:"ingress"`
+	Egress      []networkPolicyRule  `json:"egress"`
+	PolicyTypes []string             `json:"policyTypes"`
+}
+
+// networkPolicyRule is an ingress rule, which has From, or an egress rule, which has To
+type networkPolicyRule struct {
+	Ports []networkPolicyPort `json:"ports"`
+	From  []networkPolicyPeer `json:"from"`
+	To    []networkPolicyPeer `json:"to"`
+}
+
+type networkPolicyPort struct {
+	Protocol *v1.Protocol        `json:"protocol"`
+	Port     *intstr.IntOrString `json:"port"`
+}
+
+type networkPolicyPeer struct {
+	PodSelector       *metav1.LabelSelector `json:"podSelector"`
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector"`
+	IPBlock           *ipBlock              `json:"ipBlock"`
+}
+
+type ipBlock struct {
+	CIDR   string   `json:"cidr"`
+	Except []string `json:"except"`
+}
+
+// policyTypes returns which directions the policy isolates.  Policies without policyTypes isolate ingress, and
+// egress if they have egress rules.
+func (p *networkPolicy) policyTypes() (bool, bool) {
+	if len(p.Spec.PolicyTypes) == 0 {
+		return true, len(p.Spec.Egress) > 0
+	}
+
+	ingress, egress := false, false
+	for _, t := range p.Spec.PolicyTypes {
+		switch t {
+		case "Ingress":
+			ingress = true
+		case "Egress":
+			egress = true
+		}
+	}
+
+	return ingress, egress
+}
+
+// policySource is the part of the api server the policies are computed from
+type policySource interface {
+	getPod(namespace string, name string) (*v1.Pod, error)
+	listPolicies(namespace string) ([]networkPolicy, error)
+	listNamespaces(selector labels.Selector) ([]v1.Namespace, error)
+	listPods(namespace string, selector labels.Selector) ([]v1.Pod, error)
+}
+
+// informerPolicySource answers from informers' caches: every pod and namespace, and the policies of the pod's
+// namespace.  changed is signalled whenever any of them changes.
+type informerPolicySource struct {
+	pods       cache.SharedIndexInformer
+	namespaces cache.SharedIndexInformer
+	policies   cache.SharedIndexInformer
+
+	changed chan struct{}
+}
+
+func newInformerPolicySource(client *clientset.Clientset, namespace string, resync time.Duration) *informerPolicySource {
+	indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+
+	s := &informerPolicySource{
+		pods:       cache.NewSharedIndexInformer(cache.NewListWatchFromClient(client.CoreV1().RESTClient(), "pods", metav1.NamespaceAll, fields.Everything()), &v1.Pod{}, resync, indexers),
+		namespaces: cache.NewSharedIndexInformer(cache.NewListWatchFromClient(client.CoreV1().RESTClient(), "namespaces", metav1.NamespaceAll, fields.Everything()), &v1.Namespace{}, resync, cache.Indexers{}),
+		policies:   cache.NewSharedIndexInformer(policyListWatch(client.NetworkingV1().RESTClient(), namespace), &networkPolicy{}, resync, indexers),
+		changed:    make(chan struct{}, 1),
+	}
+
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { s.notify() },
+		UpdateFunc: func(oldObj, newObj interface{}) { s.notify() },
+		DeleteFunc: func(obj interface{}) { s.notify() },
+	}
+	for _, informer := range s.informers() {
+		informer.AddEventHandler(handler)
+	}
+
+	return s
+}
+
+func (s *informerPolicySource) informers() []cache.SharedIndexInformer {
+	return []cache.SharedIndexInformer{s.pods, s.namespaces, s.policies}
+}
+
+func (s *informerPolicySource) notify() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// start runs the informers until stopCh is closed and waits for their caches to fill.  It returns false if stopCh
+// was closed first.
+func (s *informerPolicySource) start(stopCh <-chan struct{}) bool {
+	synced := []cache.InformerSynced{}
+	for _, informer := range s.informers() {
+		go informer.Run(stopCh)
+		synced = append(synced, informer.HasSynced)
+	}
+
+	return cache.WaitForCacheSync(stopCh, synced...)
+}
+
+func (s *informerPolicySource) getPod(namespace string, name string) (*v1.Pod, error) {
+	obj, exists, err := s.pods.GetIndexer().GetByKey(namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("pod %v/%v not found", namespace, name)
+	}
+
+	return obj.(*v1.Pod), nil
+}
+
+func (s *informerPolicySource) listPolicies(namespace string) ([]networkPolicy, error) {
+	objs, err := s.policies.GetIndexer().ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := []networkPolicy{}
+	for _, obj := range objs {
+		policies = append(policies, *obj.(*networkPolicy))
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Name < policies[j].Name })
+
+	return policies, nil
+}
+
+func (s *informerPolicySource) listNamespaces(selector labels.Selector) ([]v1.Namespace, error) {
+	namespaces := []v1.Namespace{}
+	for _, obj := range s.namespaces.GetIndexer().List() {
+		ns := obj.(*v1.Namespace)
+		if selector.Matches(labels.Set(ns.Labels)) {
+			namespaces = append(namespaces, *ns)
+		}
+	}
+	sort.Slice(namespaces, func(i, j int) bool { return namespaces[i].Name < namespaces[j].Name })
+
+	return namespaces, nil
+}
+
+func (s *informerPolicySource) listPods(namespace string, selector labels.Selector) ([]v1.Pod, error) {
+	objs, err := s.pods.GetIndexer().ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	pods := []v1.Pod{}
+	for _, obj := range objs {
+		pod := obj.(*v1.Pod)
+		if selector.Matches(labels.Set(pod.Labels)) {
+			pods = append(pods, *pod)
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+
+	return pods, nil
+}
+
+// policyListWatch lists and watches the namespace's policies as json, as the vendored networking types can't hold them
+func policyListWatch(client rest.Interface, namespace string) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			data, err := client.Get().Namespace(namespace).Resource("networkpolicies").VersionedParams(&options, metav1.ParameterCodec).DoRaw()
+			if err != nil {
+				return nil, err
+			}
+
+			list := &networkPolicyList{}
+			if err := json.Unmarshal(data, list); err != nil {
+				return nil, fmt.Errorf("couldn't parse network policies: %v", err)
+			}
+
+			return list, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.Watch = true
+			stream, err := client.Get().Namespace(namespace).Resource("networkpolicies").VersionedParams(&options, metav1.ParameterCodec).Stream()
+			if err != nil {
+				return nil, err
+			}
+
+			return watch.NewStreamWatcher(&policyDecoder{stream: stream, decoder: json.NewDecoder(stream)}), nil
+		},
+	}
+}
+
+// policyDecoder decodes the json watch events of network policies
+type policyDecoder struct {
+	stream  io.ReadCloser
+	decoder *json.Decoder
+}
+
+func (d *policyDecoder) Decode() (watch.EventType, runtime.Object, error) {
+	var event struct {
+		Type   watch.EventType `json:"type"`
+		Object json.RawMessage `json:"object"`
+	}
+	if err := d.decoder.Decode(&event); err != nil {
+		return "", nil, err
+	}
+
+	var obj runtime.Object
+	switch event.Type {
+	case watch.Added, watch.Modified, watch.Deleted:
+		obj = &networkPolicy{}
+	case watch.Error:
+		obj = &metav1.Status{}
+	default:
+		return "", nil, fmt.Errorf("unknown watch event %q", event.Type)
+	}
+	if err := json.Unmarshal(event.Object, obj); err != nil {
+		return "", nil, fmt.Errorf("couldn't parse %v event: %v", event.Type, err)
+	}
+
+	return event.Type, obj, nil
+}
+
+func (d *policyDecoder) Close() {
+	d.stream.Close()
+}
+
+// policyRules is what the policies selecting a pod allow
+type policyRules struct {
+	ingressIsolated bool
+	egressIsolated  bool
+	// namespace/name of the policies selecting the pod
+	policies []string
+	ingress  []policyPeerRule
+	egress   []policyPeerRule
+}
+
+// policyPeerRule allows traffic with the peers in cidr, except those in except.  An empty cidr is every peer and no
+// ports is every port.
+type policyPeerRule struct {
+	cidr   string
+	except []string
+	ports  []policyPort
+}
+
+// policyPort is a port, or every port of the protocol if port is empty
+type policyPort struct {
+	protocol string
+	port     string
+}
+
+// computePolicyRules finds the policies that select the pod and what they allow, following NetworkPolicy's semantics:
+// a pod is isolated in a direction once a policy selects it for it, and then only allows what one of those policies'
+// rules allow
+func computePolicyRules(source policySource, namespace string, name string) (*policyRules, error) {
+	pod, err := source.getPod(namespace, name)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get pod %v/%v: %v", namespace, name, err)
+	}
+
+	policies, err := source.listPolicies(namespace)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list network policies in %v: %v", namespace, err)
+	}
+
+	rules := &policyRules{}
+
+	for i := range policies {
+		policy := &policies[i]
+
+		selector, err := metav1.LabelSelectorAsSelector(&policy.Spec.PodSelector)
+		if err != nil {
+			return nil, fmt.Errorf("policy %v/%v: %v", namespace, policy.Name, err)
+		}
+		if !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+
+		rules.policies = append(rules.policies, namespace+"/"+policy.Name)
+
+		ingress, egress := policy.policyTypes()
+		if ingress {
+			rules.ingressIsolated = true
+			for _, rule := range policy.Spec.Ingress {
+				peers, err := peerRules(source, namespace, rule.From, rule.Ports, pod)
+				if err != nil {
+					return nil, fmt.Errorf("policy %v/%v: %v", namespace, policy.Name, err)
+				}
+				rules.ingress = append(rules.ingress, peers...)
+			}
+		}
+		if egress {
+			rules.egressIsolated = true
+			for _, rule := range policy.Spec.Egress {
+				peers, err := peerRules(source, namespace, rule.To, rule.Ports, nil)
+				if err != nil {
+					return nil, fmt.Errorf("policy %v/%v: %v", namespace, policy.Name, err)
+				}
+				rules.egress = append(rules.egress, peers...)
+			}
+		}
+	}
+
+	return rules, nil
+}
+
+// peerRules turns a rule's peers into the addresses it allows.  Named ports are the ports of the pod the traffic goes
+// to, which is self for ingress rules and the peer for egress rules.
+func peerRules(source policySource, namespace string, peers []networkPolicyPeer, ports []networkPolicyPort, self *v1.Pod) ([]policyPeerRule, error) {
+	if len(peers) == 0 {
+		if resolved, ok := resolvePorts(ports, self); ok {
+			return []policyPeerRule{{ports: resolved}}, nil
+		}
+		return nil, nil
+	}
+
+	ret := []policyPeerRule{}
+
+	for _, peer := range peers {
+		if peer.IPBlock != nil {
+			if resolved, ok := resolvePorts(ports, self); ok {
+				ret = append(ret, policyPeerRule{cidr: peer.IPBlock.CIDR, except: peer.IPBlock.Except, ports: resolved})
+			}
+			continue
+		}
+
+		namespaces := []string{namespace}
+		if peer.NamespaceSelector != nil {
+			selector, err := metav1.LabelSelectorAsSelector(peer.NamespaceSelector)
+			if err != nil {
+				return nil, err
+			}
+			list, err := source.listNamespaces(selector)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't list namespaces: %v", err)
+			}
+			namespaces = []string{}
+			for _, ns := range list {
+				namespaces = append(namespaces, ns.Name)
+			}
+		}
+
+		podSelector := labels.Everything()
+		if peer.PodSelector != nil {
+			selector, err := metav1.LabelSelectorAsSelector(peer.PodSelector)
+			if err != nil {
+				return nil, err
+			}
+			podSelector = selector
+		}
+
+		for _, ns := range namespaces {
+			pods, err := source.listPods(ns, podSelector)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't list pods in %v: %v", ns, err)
+			}
+
+			for i := range pods {
+				pod := &pods[i]
+				if pod.Status.PodIP == "" {
+					continue
+				}
+
+				target := self
+				if target == nil {
+					target = pod
+				}
+				if resolved, ok := resolvePorts(ports, target); ok {
+					ret = append(ret, policyPeerRule{cidr: hostCIDR(pod.Status.PodIP), ports: resolved})
+				}
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+// resolvePorts returns the ports a rule allows on target, or false if it allows none of them, which happens when
+// none of its named ports are target's
+func resolvePorts(ports []networkPolicyPort, target *v1.Pod) ([]policyPort, bool) {
+	if len(ports) == 0 {
+		return nil, true
+	}
+
+	ret := []policyPort{}
+	for _, port := range ports {
+		protocol := v1.ProtocolTCP
+		if port.Protocol != nil {
+			protocol = *port.Protocol
+		}
+
+		switch {
+		case port.Port == nil:
+			ret = append(ret, policyPort{protocol: string(protocol)})
+		case port.Port.Type == intstr.Int:
+			ret = append(ret, policyPort{protocol: string(protocol), port: strconv.Itoa(port.Port.IntValue())})
+		case target != nil:
+			for _, container := range target.Spec.Containers {
+				for _, containerPort := range container.Ports {
+					if containerPort.Name == port.Port.StrVal && containerPort.Protocol == protocol {
+						ret = append(ret, policyPort{protocol: string(protocol), port: strconv.Itoa(int(containerPort.ContainerPort))})
+					}
+				}
+			}
+		}
+	}
+
+	return ret, len(ret) > 0
+}
+
+// policyExemptions is traffic that's allowed whatever the policies say, as infranetes and vmserver need it to manage
+// the pod.  ingressPorts are tcp ports on the pod, egressEndpoints are tcp ip:ports it talks to.
+type policyExemptions struct {
+	ingressPorts    []string
+	egressEndpoints []string
+}
+
+// renderPolicyRules writes the iptables-restore input for the filter table that replaces the policy chains with rules,
+// and removes the peer chains in oldPeerChains it doesn't need anymore.  It returns the peer chains it created.  Only
+// the addresses of one family go in a table, ip6tables' if ipv6 is set.
+func renderPolicyRules(rules *policyRules, exempt *policyExemptions, oldPeerChains []string, ipv6 bool) ([]byte, []string) {
+	chains := bytes.NewBuffer(nil)
+	lines := bytes.NewBuffer(nil)
+	peerChains := []string{}
+
+	newPeerChain := func() string {
+		chain := fmt.Sprintf("%s%d", policyPeerChainPrefix, len(peerChains))
+		peerChains = append(peerChains, chain)
+		return chain
+	}
+
+	writeChain := func(chain utiliptables.Chain, isolated bool, peers []policyPeerRule, addrFlag string, loFlag string, exempted []string) {
+		fmt.Fprintf(chains, ":%s - [0:0]\n", chain)
+		if !isolated {
+			return
+		}
+
+		fmt.Fprintf(lines, "-A %s -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT\n", chain)
+		fmt.Fprintf(lines, "-A %s %s lo -j ACCEPT\n", chain, loFlag)
+		for _, e := range exempted {
+			fmt.Fprintf(lines, "-A %s %s -j ACCEPT\n", chain, e)
+		}
+
+		for _, peer := range peers {
+			if peer.cidr != "" && isIPv6CIDR(peer.cidr) != ipv6 {
+				continue
+			}
+
+			target, match := string(chain), ""
+			if peer.cidr != "" {
+				match = fmt.Sprintf(" %s %s", addrFlag, peer.cidr)
+			}
+
+			if len(peer.except) > 0 {
+				target = newPeerChain()
+				fmt.Fprintf(chains, ":%s - [0:0]\n", target)
+				fmt.Fprintf(lines, "-A %s%s -j %s\n", chain, match, target)
+				for _, except := range peer.except {
+					if isIPv6CIDR(except) != ipv6 {
+						continue
+					}
+					fmt.Fprintf(lines, "-A %s %s %s -j RETURN\n", target, addrFlag, except)
+				}
+				match = ""
+			}
+
+			if len(peer.ports) == 0 {
+				fmt.Fprintf(lines, "-A %s%s -j ACCEPT\n", target, match)
+				continue
+			}
+			for _, port := range peer.ports {
+				protocol := strings.ToLower(port.protocol)
+				if port.port == "" {
+					fmt.Fprintf(lines, "-A %s%s -p %s -j ACCEPT\n", target, match, protocol)
+				} else {
+					fmt.Fprintf(lines, "-A %s%s -p %s -m %s --dport %s -j ACCEPT\n", target, match, protocol, protocol, port.port)
+				}
+			}
+		}
+
+		fmt.Fprintf(lines, "-A %s -j DROP\n", chain)
+	}
+
+	ingressExempt := []string{}
+	for _, port := range exempt.ingressPorts {
+		ingressExempt = append(ingressExempt, "-p tcp -m tcp --dport "+port)
+	}
+	egressExempt := []string{}
+	for _, endpoint := range exempt.egressEndpoints {
+		if host, port, err := net.SplitHostPort(endpoint); err == nil && isIPv6CIDR(hostCIDR(host)) == ipv6 {
+			egressExempt = append(egressExempt, fmt.Sprintf("-d %s -p tcp -m tcp --dport %s", hostCIDR(host), port))
+		}
+	}
+
+	writeChain(PolicyIngressChain, rules.ingressIsolated, rules.ingress, "-s", "-i", ingressExempt)
+	writeChain(PolicyEgressChain, rules.egressIsolated, rules.egress, "-d", "-o", egressExempt)
+
+	// old peer chains are flushed by declaring them, and can be deleted once nothing jumps to them
+	stale := []string{}
+	for _, chain := range oldPeerChains {
+		if !containsString(peerChains, chain) {
+			fmt.Fprintf(chains, ":%s - [0:0]\n", chain)
+			stale = append(stale, chain)
+		}
+	}
+
+	data := bytes.NewBuffer(nil)
+	data.WriteString("*filter\n")
+	data.Write(chains.Bytes())
+	data.Write(lines.Bytes())
+	for _, chain := range stale {
+		fmt.Fprintf(data, "-X %s\n", chain)
+	}
+	data.WriteString("COMMIT\n")
+
+	return data.Bytes(), peerChains
+}
+
+// hostCIDR is the cidr of just ip, in its family
+func hostCIDR(ip string) string {
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		return ip + "/128"
+	}
+	return ip + "/32"
+}
+
+func isIPv6CIDR(cidr string) bool {
+	ip, _, err := net.ParseCIDR(cidr)
+	return err == nil && ip.To4() == nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// policyController keeps the pod's policy chains in sync with the NetworkPolicies that select it.  It watches the api
+// server with the kubeconfig it was started with, so a rotated kubeconfig takes a new controller.
+type policyController struct {
+	namespace  string
+	name       string
+	syncPeriod time.Duration
+	// ingressPorts are the ports vmserver serves infranetes on
+	ingressPorts []string
+	// families are the iptables of each of the pod's ips, ip6tables' only for dual-stack pods
+	families []*policyFamily
+
+	lock   sync.Mutex
+	status common.NetworkPolicyStatusResponse
+	// the api server's addresses, kept from the last time they could be resolved
+	apiserver []string
+
+	stopped chan struct{}
+	// done is closed once run returns, after which the chains aren't touched
+	done chan struct{}
+}
+
+// policyFamily is where the policy chains of one of the pod's ips go
+type policyFamily struct {
+	podIp      string
+	ipv6       bool
+	iptables   utiliptables.Interface
+	peerChains []string
+}
+
+func startPolicyController(namespace string, name string, podIp string, podIpv6 string, syncPeriod time.Duration, ingressPorts []string) *policyController {
+	families := []*policyFamily{{podIp: podIp, iptables: utiliptables.New(exec.New(), utildbus.New(), utiliptables.ProtocolIpv4)}}
+	if podIpv6 != "" {
+		families = append(families, &policyFamily{podIp: podIpv6, ipv6: true, iptables: utiliptables.New(exec.New(), utildbus.New(), utiliptables.ProtocolIpv6)})
+	}
+
+	return runPolicyController(namespace, name, families, syncPeriod, ingressPorts)
+}
+
+// runPolicyController starts a controller that puts the pod's chains in families
+func runPolicyController(namespace string, name string, families []*policyFamily, syncPeriod time.Duration, ingressPorts []string) *policyController {
+	p := &policyController{
+		namespace:    namespace,
+		name:         name,
+		syncPeriod:   syncPeriod,
+		ingressPorts: ingressPorts,
+		families:     families,
+		status:       common.NetworkPolicyStatusResponse{Running: true},
+		stopped:      make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+
+	go p.run()
+
+	return p
+}
+
+// restart stops the controller and starts another in its place, which connects to the api server with the current
+// kubeconfig.  It takes over the chains the stopped one made, so they're replaced as they were and not left behind.
+func (p *policyController) restart() *policyController {
+	p.stop()
+
+	return runPolicyController(p.namespace, p.name, p.families, p.syncPeriod, p.ingressPorts)
+}
+
+// run connects to the api server, retrying every sync period until it can, and then syncs whenever the informers see
+// a change, and every sync period for the exempt ports and api server addresses
+func (p *policyController) run() {
+	defer close(p.done)
+
+	var source *informerPolicySource
+	var host string
+	for source == nil {
+		config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+		var client *clientset.Clientset
+		if err == nil {
+			client, err = clientset.NewForConfig(config)
+		}
+		if err == nil {
+			source = newInformerPolicySource(client, p.namespace, 0)
+			host = config.Host
+			break
+		}
+
+		glog.Warningf("policyController: couldn't connect to the api server: %v", err)
+		p.setMessage(fmt.Sprintf("couldn't connect to the api server: %v", err))
+		select {
+		case <-p.stopped:
+			return
+		case <-time.After(p.syncPeriod):
+		}
+	}
+
+	if !source.start(p.stopped) {
+		return
+	}
+
+	ticker := time.NewTicker(p.syncPeriod)
+	defer ticker.Stop()
+
+	for {
+		if err := p.sync(source, host); err != nil {
+			glog.Warningf("policyController: sync failed, keeping the previous rules: %v", err)
+			p.setMessage(err.Error())
+		}
+
+		select {
+		case <-p.stopped:
+			return
+		case <-source.changed:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *policyController) setMessage(message string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.status.Message = message
+}
+
+// sync recomputes the pod's rules from source and replaces its chains with them
+func (p *policyController) sync(source policySource, host string) error {
+	rules, err := computePolicyRules(source, p.namespace, p.name)
+	if err != nil {
+		return err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if addrs, err := resolveAPIServer(host); err != nil {
+		glog.V(1).Infof("policyController: couldn't resolve api server %v: %v", host, err)
+	} else {
+		p.apiserver = addrs
+	}
+
+	exempt := &policyExemptions{ingressPorts: p.ingressPorts, egressEndpoints: p.apiserver}
+	for _, family := range p.families {
+		data, peerChains := renderPolicyRules(rules, exempt, family.peerChains, family.ipv6)
+		if err := family.iptables.Restore(utiliptables.TableFilter, data, utiliptables.NoFlushTables, utiliptables.NoRestoreCounters); err != nil {
+			return fmt.Errorf("couldn't restore policy chains of %v: %v", family.podIp, err)
+		}
+		family.peerChains = peerChains
+
+		if _, err := family.iptables.EnsureRule(utiliptables.Prepend, utiliptables.TableFilter, utiliptables.ChainInput, "-d", family.podIp, "-j", string(PolicyIngressChain)); err != nil {
+			return fmt.Errorf("couldn't jump to %v: %v", PolicyIngressChain, err)
+		}
+		if _, err := family.iptables.EnsureRule(utiliptables.Prepend, utiliptables.TableFilter, utiliptables.ChainOutput, "-s", family.podIp, "-j", string(PolicyEgressChain)); err != nil {
+			return fmt.Errorf("couldn't jump to %v: %v", PolicyEgressChain, err)
+		}
+	}
+
+	p.status.IngressIsolated = rules.ingressIsolated
+	p.status.EgressIsolated = rules.egressIsolated
+	p.status.Policies = rules.policies
+	p.status.Message = ""
+	p.status.LastSync = time.Now().Unix()
+
+	return nil
+}
+
+// resolveAPIServer returns the ip:ports of the api server at host, which egress policies mustn't cut vmserver and
+// kube-proxy off from
+func resolveAPIServer(host string) ([]string, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, err
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := []string{}
+	for _, ip := range ips {
+		addrs = append(addrs, net.JoinHostPort(ip.String(), port))
+	}
+	sort.Strings(addrs)
+
+	return addrs, nil
+}
+
+// stop stops the controller and waits for it, so that it doesn't touch the chains once it returns
+func (p *policyController) stop() {
+	close(p.stopped)
+	<-p.done
+}
+
+func (p *policyController) getStatus() *common.NetworkPolicyStatusResponse {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	status := p.status
+	return &status
+}
+
+// StartNetworkPolicy enforces the NetworkPolicies that select the sandbox's pod.  If they're enforced already, the
+// sync period is updated.
+func (m *VMserver) StartNetworkPolicy(ctx context.Context, req *common.StartNetworkPolicyRequest) (*common.StartNetworkPolicyResponse, error) {
+	if len(req.Kubeconfig) > 0 {
+		if err := writeKubeconfig(req.Kubeconfig); err != nil {
+			return nil, fmt.Errorf("StartNetworkPolicy: couldn't write kubeconfig: %v", err)
+		}
+	}
+
+	// the kubeconfig is kept with kube-proxy's, so it isn't saved with the request
+	saved := *req
+	saved.Kubeconfig = nil
+
+	if err := m.startNetworkPolicy(&saved); err != nil {
+		return nil, fmt.Errorf("StartNetworkPolicy: %v", err)
+	}
+
+	return &common.StartNetworkPolicyResponse{}, nil
+}
+
+func (m *VMserver) startNetworkPolicy(req *common.StartNetworkPolicyRequest) error {
+	if m.podIp == nil || m.config == nil {
+		return fmt.Errorf("pod ip or sandbox config wasn't set")
+	}
+
+	syncPeriod := defaultPolicySyncPeriod
+	if req.SyncPeriod != "" {
+		d, err := time.ParseDuration(req.SyncPeriod)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid sync period %q", req.SyncPeriod)
+		}
+		syncPeriod = d
+	}
+
+	m.policyLock.Lock()
+	defer m.policyLock.Unlock()
+
+	if m.listenPort == 0 {
+		return fmt.Errorf("vmserver isn't serving yet")
+	}
+
+	if m.policy != nil {
+		m.policy.stop()
+	}
+
+	metadata := m.config.GetMetadata()
+	glog.Infof("startNetworkPolicy: enforcing network policies for %v/%v every %v", metadata.GetNamespace(), metadata.GetName(), syncPeriod)
+	podIpv6 := ""
+	if m.podIpv6 != nil {
+		podIpv6 = *m.podIpv6
+	}
+	m.policy = startPolicyController(metadata.GetNamespace(), metadata.GetName(), *m.podIp, podIpv6, syncPeriod, m.exemptPorts())
+
+	m.policyRequest = req
+	if err := m.saveState(); err != nil {
+		glog.Warningf("startNetworkPolicy: couldn't save state: %v", err)
+	}
+
+	return nil
+}
+
+// restartNetworkPolicy restarts the policy controller, if policies are enforced, so it uses the current kubeconfig
+func (m *VMserver) restartNetworkPolicy() {
+	m.policyLock.Lock()
+	defer m.policyLock.Unlock()
+
+	if m.policy == nil {
+		return
+	}
+
+	glog.Infof("restartNetworkPolicy: restarting the policy controller with the new kubeconfig")
+	m.policy = m.policy.restart()
+}
+
+// exemptPorts are the ports infranetes reaches vmserver on, which policies mustn't block.  policyLock must be held.
+func (m *VMserver) exemptPorts() []string {
+	return []string{streamingPort, strconv.Itoa(m.listenPort)}
+}
+
+func (m *VMserver) NetworkPolicyStatus(ctx context.Context, req *common.NetworkPolicyStatusRequest) (*common.NetworkPolicyStatusResponse, error) {
+	return m.networkPolicyStatus(), nil
+}
+
+func (m *VMserver) networkPolicyStatus() *common.NetworkPolicyStatusResponse {
+	m.policyLock.Lock()
+	defer m.policyLock.Unlock()
+
+	if m.policy == nil {
+		return &common.NetworkPolicyStatusResponse{Message: "network policies aren't enforced"}
+	}
+
+	return m.policy.getStatus()
+}
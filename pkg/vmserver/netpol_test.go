@@ -0,0 +1,356 @@
+package vmserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"k8s.io/kubernetes/pkg/api/v1"
+	"k8s.io/kubernetes/pkg/client/clientset_generated/clientset"
+)
+
+type fakePolicySource struct {
+	policies   []networkPolicy
+	namespaces []v1.Namespace
+	pods       []v1.Pod
+}
+
+func (s *fakePolicySource) getPod(namespace string, name string) (*v1.Pod, error) {
+	for i := range s.pods {
+		if s.pods[i].Namespace == namespace && s.pods[i].Name == name {
+			return &s.pods[i], nil
+		}
+	}
+	return nil, fmt.Errorf("pod %v/%v not found", namespace, name)
+}
+
+func (s *fakePolicySource) listPolicies(namespace string) ([]networkPolicy, error) {
+	return s.policies, nil
+}
+
+func (s *fakePolicySource) listNamespaces(selector labels.Selector) ([]v1.Namespace, error) {
+	ret := []v1.Namespace{}
+	for _, ns := range s.namespaces {
+		if selector.Matches(labels.Set(ns.Labels)) {
+			ret = append(ret, ns)
+		}
+	}
+	return ret, nil
+}
+
+func (s *fakePolicySource) listPods(namespace string, selector labels.Selector) ([]v1.Pod, error) {
+	ret := []v1.Pod{}
+	for _, pod := range s.pods {
+		if pod.Namespace == namespace && selector.Matches(labels.Set(pod.Labels)) {
+			ret = append(ret, pod)
+		}
+	}
+	return ret, nil
+}
+
+func testPod(namespace, name, ip string, podLabels map[string]string) v1.Pod {
+	return v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: podLabels},
+		Spec: v1.PodSpec{Containers: []v1.Container{{
+			Ports: []v1.ContainerPort{{Name: "http", ContainerPort: 8080, Protocol: v1.ProtocolTCP}},
+		}}},
+		Status: v1.PodStatus{PodIP: ip},
+	}
+}
+
+func parsePolicies(t *testing.T, data string) []networkPolicy {
+	var list networkPolicyList
+	if err := json.Unmarshal([]byte(data), &list); err != nil {
+		t.Fatal(err)
+	}
+	return list.Items
+}
+
+func TestComputePolicyRules(t *testing.T) {
+	source := &fakePolicySource{
+		policies: parsePolicies(t, `{"items": [
+			{"metadata": {"name": "web"}, "spec": {
+				"podSelector": {"matchLabels": {"app": "web"}},
+				"ingress": [{
+					"from": [{"podSelector": {"matchLabels": {"app": "client"}}}, {"namespaceSelector": {"matchLabels": {"team": "ops"}}}],
+					"ports": [{"port": "http"}]
+				}]
+			}},
+			{"metadata": {"name": "egress"}, "spec": {
+				"podSelector": {},
+				"policyTypes": ["Egress"],
+				"egress": [{"to": [{"ipBlock": {"cidr": "10.0.0.0/8", "except": ["10.1.0.0/16"]}}], "ports": [{"protocol": "UDP", "port": 53}]}]
+			}},
+			{"metadata": {"name": "db"}, "spec": {"podSelector": {"matchLabels": {"app": "db"}}}}
+		]}`),
+		namespaces: []v1.Namespace{
+			{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "monitoring", Labels: map[string]string{"team": "ops"}}},
+		},
+		pods: []v1.Pod{
+			testPod("default", "web-1", "10.2.0.5", map[string]string{"app": "web"}),
+			testPod("default", "client-1", "10.2.0.6", map[string]string{"app": "client"}),
+			testPod("default", "client-2", "", map[string]string{"app": "client"}),
+			testPod("default", "other", "10.2.0.7", map[string]string{"app": "other"}),
+			testPod("monitoring", "prometheus", "10.2.0.8", nil),
+		},
+	}
+
+	rules, err := computePolicyRules(source, "default", "web-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !rules.ingressIsolated || !rules.egressIsolated {
+		t.Errorf("isolated = %v/%v, want both", rules.ingressIsolated, rules.egressIsolated)
+	}
+	if !reflect.DeepEqual(rules.policies, []string{"default/web", "default/egress"}) {
+		t.Errorf("policies = %v", rules.policies)
+	}
+
+	http := []policyPort{{protocol: "TCP", port: "8080"}}
+	wantIngress := []policyPeerRule{{cidr: "10.2.0.6/32", ports: http}, {cidr: "10.2.0.8/32", ports: http}}
+	if !reflect.DeepEqual(rules.ingress, wantIngress) {
+		t.Errorf("ingress = %+v, want %+v", rules.ingress, wantIngress)
+	}
+
+	wantEgress := []policyPeerRule{{cidr: "10.0.0.0/8", except: []string{"10.1.0.0/16"}, ports: []policyPort{{protocol: "UDP", port: "53"}}}}
+	if !reflect.DeepEqual(rules.egress, wantEgress) {
+		t.Errorf("egress = %+v, want %+v", rules.egress, wantEgress)
+	}
+
+	// a pod no policy selects isn't isolated
+	rules, err = computePolicyRules(source, "default", "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules.ingressIsolated || len(rules.ingress) != 0 {
+		t.Errorf("other pod's ingress = %v/%+v", rules.ingressIsolated, rules.ingress)
+	}
+}
+
+func TestRenderPolicyRules(t *testing.T) {
+	rules := &policyRules{
+		ingressIsolated: true,
+		ingress:         []policyPeerRule{{cidr: "10.2.0.6/32", ports: []policyPort{{protocol: "TCP", port: "8080"}}}},
+		egressIsolated:  true,
+		egress:          []policyPeerRule{{cidr: "10.0.0.0/8", except: []string{"10.1.0.0/16"}}},
+	}
+	exempt := &policyExemptions{ingressPorts: []string{"2375"}, egressEndpoints: []string{"10.3.0.1:443"}}
+
+	data, peerChains := renderPolicyRules(rules, exempt, []string{"INFRANETES-PEER-0", "INFRANETES-PEER-1"}, false)
+
+	if !reflect.DeepEqual(peerChains, []string{"INFRANETES-PEER-0"}) {
+		t.Errorf("peer chains = %v", peerChains)
+	}
+
+	for _, line := range []string{
+		"-A INFRANETES-INGRESS -p tcp -m tcp --dport 2375 -j ACCEPT",
+		"-A INFRANETES-INGRESS -s 10.2.0.6/32 -p tcp -m tcp --dport 8080 -j ACCEPT",
+		"-A INFRANETES-INGRESS -j DROP",
+		"-A INFRANETES-EGRESS -d 10.3.0.1/32 -p tcp -m tcp --dport 443 -j ACCEPT",
+		"-A INFRANETES-EGRESS -d 10.0.0.0/8 -j INFRANETES-PEER-0",
+		"-A INFRANETES-PEER-0 -d 10.1.0.0/16 -j RETURN",
+		"-A INFRANETES-PEER-0 -j ACCEPT",
+		"-X INFRANETES-PEER-1",
+	} {
+		if !strings.Contains(string(data), line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, data)
+		}
+	}
+
+	// chains of pods that aren't isolated are left empty, so everything is allowed
+	data, _ = renderPolicyRules(&policyRules{}, exempt, nil, false)
+	if strings.Contains(string(data), "-A ") {
+		t.Errorf("unisolated pod got rules:\n%s", data)
+	}
+}
+
+func TestRenderPolicyRulesIPv6(t *testing.T) {
+	rules := &policyRules{
+		ingressIsolated: true,
+		ingress: []policyPeerRule{
+			{cidr: "10.2.0.6/32"},
+			{cidr: "fd00::6/128", ports: []policyPort{{protocol: "TCP", port: "8080"}}},
+			{ports: []policyPort{{protocol: "UDP", port: "53"}}},
+		},
+		egressIsolated: true,
+		egress:         []policyPeerRule{{cidr: "::/0", except: []string{"10.1.0.0/16", "fd00:1::/64"}}},
+	}
+	exempt := &policyExemptions{egressEndpoints: []string{"10.3.0.1:443", "[fd00::1]:443"}}
+
+	data, _ := renderPolicyRules(rules, exempt, nil, true)
+
+	for _, line := range []string{
+		"-A INFRANETES-INGRESS -s fd00::6/128 -p tcp -m tcp --dport 8080 -j ACCEPT",
+		"-A INFRANETES-INGRESS -p udp -m udp --dport 53 -j ACCEPT",
+		"-A INFRANETES-EGRESS -d fd00::1/128 -p tcp -m tcp --dport 443 -j ACCEPT",
+		"-A INFRANETES-EGRESS -d ::/0 -j INFRANETES-PEER-0",
+		"-A INFRANETES-PEER-0 -d fd00:1::/64 -j RETURN",
+	} {
+		if !strings.Contains(string(data), line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, data)
+		}
+	}
+	for _, ip := range []string{"10.2.0.6", "10.1.0.0", "10.3.0.1"} {
+		if strings.Contains(string(data), ip) {
+			t.Errorf("ipv4 address %v in ip6tables rules:\n%s", ip, data)
+		}
+	}
+}
+
+// testAPIServer lists pods, namespaces and the default namespace's policies, and streams the policy events sent on
+// events to policy watches.  Other watches see nothing.
+type testAPIServer struct {
+	*httptest.Server
+
+	pods       []v1.Pod
+	namespaces []v1.Namespace
+	policies   string
+
+	events chan string
+	closed chan struct{}
+}
+
+func newTestAPIServer(pods []v1.Pod, namespaces []v1.Namespace, policies string) *testAPIServer {
+	s := &testAPIServer{pods: pods, namespaces: namespaces, policies: policies, events: make(chan string), closed: make(chan struct{})}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *testAPIServer) serve(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if req.URL.Query().Get("watch") == "true" {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		events := s.events
+		if req.URL.Path != "/apis/networking.k8s.io/v1/namespaces/default/networkpolicies" {
+			events = nil
+		}
+		for {
+			select {
+			case event := <-events:
+				w.Write([]byte(event + "\n"))
+				w.(http.Flusher).Flush()
+			case <-req.Context().Done():
+				return
+			case <-s.closed:
+				return
+			}
+		}
+	}
+
+	switch req.URL.Path {
+	case "/api/v1/pods":
+		json.NewEncoder(w).Encode(&v1.PodList{TypeMeta: metav1.TypeMeta{Kind: "PodList", APIVersion: "v1"}, ListMeta: metav1.ListMeta{ResourceVersion: "1"}, Items: s.pods})
+	case "/api/v1/namespaces":
+		json.NewEncoder(w).Encode(&v1.NamespaceList{TypeMeta: metav1.TypeMeta{Kind: "NamespaceList", APIVersion: "v1"}, ListMeta: metav1.ListMeta{ResourceVersion: "1"}, Items: s.namespaces})
+	case "/apis/networking.k8s.io/v1/namespaces/default/networkpolicies":
+		w.Write([]byte(s.policies))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *testAPIServer) stop() {
+	close(s.closed)
+	s.Close()
+}
+
+func TestInformerPolicySource(t *testing.T) {
+	server := newTestAPIServer(
+		[]v1.Pod{
+			testPod("default", "web-1", "10.2.0.5", map[string]string{"app": "web"}),
+			testPod("default", "client-1", "10.2.0.6", map[string]string{"app": "client"}),
+			testPod("monitoring", "client-2", "10.2.0.7", map[string]string{"app": "client"}),
+		},
+		[]v1.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "default"}}, {ObjectMeta: metav1.ObjectMeta{Name: "monitoring"}}},
+		`{"metadata": {"resourceVersion": "1"}, "items": [
+			{"metadata": {"name": "web", "namespace": "default"}, "spec": {
+				"podSelector": {"matchLabels": {"app": "web"}},
+				"ingress": [{"from": [{"podSelector": {"matchLabels": {"app": "client"}}}]}]
+			}}
+		]}`)
+	defer server.stop()
+
+	client, err := clientset.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source := newInformerPolicySource(client, "default", 0)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	if !source.start(stopCh) {
+		t.Fatal("caches didn't sync")
+	}
+
+	rules, err := computePolicyRules(source, "default", "web-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rules.policies, []string{"default/web"}) || !reflect.DeepEqual(rules.ingress, []policyPeerRule{{cidr: "10.2.0.6/32"}}) {
+		t.Errorf("rules = %+v", rules)
+	}
+
+	// drain what the initial lists signalled
+	select {
+	case <-source.changed:
+	default:
+	}
+
+	server.events <- `{"type": "ADDED", "object": {"metadata": {"name": "deny", "namespace": "default", "resourceVersion": "2"}, "spec": {"podSelector": {}}}}`
+
+	select {
+	case <-source.changed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the new policy wasn't signalled")
+	}
+
+	policies, err := source.listPolicies("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policies) != 2 || policies[0].Name != "deny" || policies[1].Name != "web" {
+		t.Errorf("policies = %+v", policies)
+	}
+}
+
+func TestPolicyDecoder(t *testing.T) {
+	stream := `{"type": "MODIFIED", "object": {"metadata": {"name": "web"}, "spec": {"policyTypes": ["Egress"]}}}
+{"type": "ERROR", "object": {"kind": "Status", "code": 410, "message": "too old"}}
+{"type": "BOOKMARK", "object": {}}
+`
+	d := &policyDecoder{stream: ioutil.NopCloser(strings.NewReader(stream)), decoder: json.NewDecoder(strings.NewReader(stream))}
+
+	eventType, obj, err := d.Decode()
+	if err != nil || eventType != watch.Modified {
+		t.Fatalf("first event = %v, %v", eventType, err)
+	}
+	if policy, ok := obj.(*networkPolicy); !ok || policy.Name != "web" || !reflect.DeepEqual(policy.Spec.PolicyTypes, []string{"Egress"}) {
+		t.Errorf("policy = %+v", obj)
+	}
+
+	eventType, obj, err = d.Decode()
+	if err != nil || eventType != watch.Error {
+		t.Fatalf("second event = %v, %v", eventType, err)
+	}
+	if status, ok := obj.(*metav1.Status); !ok || status.Code != 410 {
+		t.Errorf("status = %+v", obj)
+	}
+
+	if _, _, err := d.Decode(); err == nil {
+		t.Errorf("decoded an unknown event")
+	}
+}
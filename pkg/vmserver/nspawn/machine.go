@@ -0,0 +1,111 @@
+package nspawn
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apporbit/infranetes/pkg/vmserver/common"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+// machineSpec is how a container is run, worked out from its config and its image's defaults at creation
+type machineSpec struct {
+	Name       string
+	Rootfs     string
+	Argv       []string
+	Env        []string
+	WorkingDir string
+	User       string
+	Mounts     []*kubeapi.Mount
+	Tty        bool
+	Stdin      bool
+	LogPath    string
+}
+
+func (s *machineSpec) args() []string {
+	console := "pipe"
+	if s.Tty {
+		console = "interactive"
+	}
+
+	// no --private-network, pods on a VM share the VM's network
+	args := []string{
+		"--quiet",
+		"--register=no",
+		"--as-pid2",
+		"--console=" + console,
+		"--directory=" + s.Rootfs,
+		"--machine=" + s.Name,
+	}
+
+	for _, env := range s.Env {
+		args = append(args, "--setenv="+env)
+	}
+	if s.WorkingDir != "" {
+		args = append(args, "--chdir="+s.WorkingDir)
+	}
+	if s.User != "" {
+		args = append(args, "--user="+s.User)
+	}
+	for _, mount := range s.Mounts {
+		bind := "--bind="
+		if mount.Readonly {
+			bind = "--bind-ro="
+		}
+		args = append(args, bind+mount.HostPath+":"+mount.ContainerPath)
+	}
+
+	return append(append(args, "--"), s.Argv...)
+}
+
+// machine is a running systemd-nspawn process
+type machine struct {
+	*common.ManagedProcess
+
+	spec *machineSpec
+}
+
+func startMachine(spec *machineSpec) (*machine, error) {
+	cmd := exec.Command("systemd-nspawn", spec.args()...)
+
+	process, err := common.StartManagedProcess("machine "+spec.Name, cmd, spec.Tty, spec.Stdin, spec.LogPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &machine{ManagedProcess: process, spec: spec}, nil
+}
+
+// stop asks the machine's init to shut the payload down, killing it if it hasn't gone after timeout seconds
+func (m *machine) stop(timeout int64) {
+	if timeout <= 0 {
+		timeout = defaultStopTimeout
+	}
+
+	m.Stop(time.Duration(timeout)*time.Second, nil)
+}
+
+// leader returns the pid of the container's init, whose namespaces exec enters
+func (m *machine) leader() (int, error) {
+	if !m.Running() {
+		return 0, fmt.Errorf("container %v is not running", m.spec.Name)
+	}
+
+	pid := m.Pid()
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/task/%d/children", pid, pid))
+	if err != nil {
+		return 0, fmt.Errorf("couldn't find init of %v: %v", m.spec.Name, err)
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("container %v hasn't started its init yet", m.spec.Name)
+	}
+
+	return strconv.Atoi(fields[0])
+}
@@ -0,0 +1,416 @@
+package nspawn
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/apporbit/infranetes/cmd/vmserver/flags"
+	icommon "github.com/apporbit/infranetes/pkg/common"
+	"github.com/apporbit/infranetes/pkg/vmserver"
+	"github.com/apporbit/infranetes/pkg/vmserver/common"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+// seconds StopContainer gives a container when the request doesn't say
+const defaultStopTimeout = 10
+
+// nspawnContainer is the provider's view of a container on top of its common.Container state
+type nspawnContainer struct {
+	*common.Container
+
+	spec    *machineSpec
+	imageId string
+	dir     string
+	machine *machine
+	// exited is closed once waitMachine has recorded the machine's exit
+	exited chan struct{}
+	// removed is closed by RemoveContainer so log streams of the container end
+	removed chan struct{}
+}
+
+type nspawnProvider struct {
+	contMap map[string]*nspawnContainer
+	mapLock sync.Mutex
+	events  *common.EventBus
+
+	root      string
+	registry  *registryClient
+	artifacts *common.ArtifactStore
+	imageLock sync.Mutex
+
+	streamingRuntime *streamingRuntime
+}
+
+func init() {
+	vmserver.ContainerProviders.RegisterProvider("nspawn", NewNspawnProvider)
+}
+
+func NewNspawnProvider() (vmserver.ContainerProvider, error) {
+	glog.Infof("NspawnProvider: starting")
+
+	root := *flags.NspawnRoot
+	for _, dir := range []string{"images", "containers"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			return nil, fmt.Errorf("NewNspawnProvider: %v", err)
+		}
+	}
+
+	artifacts, err := common.NewArtifactStore(*flags.ArtifactCacheDir, *flags.ArtifactCredentials, *flags.ArtifactAllowHTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &nspawnProvider{
+		contMap:   make(map[string]*nspawnContainer),
+		events:    common.NewEventBus(),
+		root:      root,
+		registry:  newRegistryClient(artifacts, *flags.ArtifactAllowHTTP),
+		artifacts: artifacts,
+	}
+	p.streamingRuntime = &streamingRuntime{provider: p}
+
+	go p.collect()
+
+	return p, nil
+}
+
+var invalidMachineChars = regexp.MustCompile("[^a-zA-Z0-9-]")
+
+// machineName turns a container name into a valid hostname, which nspawn requires of machine names
+func machineName(name string) string {
+	name = strings.Trim(invalidMachineChars.ReplaceAllString(name, "-"), "-")
+	if len(name) > 60 {
+		name = name[:60]
+	}
+	if name == "" {
+		name = "container"
+	}
+
+	return name
+}
+
+// buildMachineSpec combines the container's config with its image's defaults, the way docker does
+func buildMachineSpec(req *kubeapi.CreateContainerRequest, img *image, rootfs string, logPath string) *machineSpec {
+	config := req.GetConfig()
+	defaults := img.Config.Config
+
+	argv := append([]string{}, config.Command...)
+	args := config.Args
+	if len(argv) == 0 {
+		argv = append(argv, defaults.Entrypoint...)
+		if len(args) == 0 {
+			args = defaults.Cmd
+		}
+	}
+	argv = append(argv, args...)
+
+	spec := &machineSpec{
+		Name:       machineName(config.GetMetadata().GetName()),
+		Rootfs:     rootfs,
+		Argv:       argv,
+		Env:        append([]string{}, defaults.Env...),
+		WorkingDir: defaults.WorkingDir,
+		User:       defaults.User,
+		Mounts:     config.Mounts,
+		Tty:        config.Tty,
+		Stdin:      config.Stdin,
+		LogPath:    logPath,
+	}
+
+	for _, env := range config.Envs {
+		spec.Env = append(spec.Env, env.Key+"="+env.Value)
+	}
+
+	if config.WorkingDir != "" {
+		spec.WorkingDir = config.WorkingDir
+	}
+
+	if uid := config.GetLinux().GetSecurityContext().GetRunAsUser(); uid != nil {
+		spec.User = strconv.FormatInt(uid.Value, 10)
+	} else if user := config.GetLinux().GetSecurityContext().GetRunAsUsername(); user != "" {
+		spec.User = user
+	}
+
+	return spec
+}
+
+func (p *nspawnProvider) CreateContainer(req *kubeapi.CreateContainerRequest) (*kubeapi.CreateContainerResponse, error) {
+	p.mapLock.Lock()
+	defer p.mapLock.Unlock()
+
+	id := req.GetPodSandboxId() + ":" + req.Config.Metadata.GetName()
+
+	if _, ok := p.contMap[id]; ok {
+		return nil, fmt.Errorf("CreateContainer: %v already exists", id)
+	}
+
+	img, err := p.pullImage(req.Config.GetImage().GetImage())
+	if err != nil {
+		msg := fmt.Sprintf("CreateContainer: couldn't pull %v: %v", req.Config.GetImage().GetImage(), err)
+		glog.Info(msg)
+		return nil, errors.New(msg)
+	}
+
+	dir := filepath.Join(p.root, "containers", strings.Replace(id, ":", "_", -1))
+	rootfs, err := mountRootfs(img, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		msg := fmt.Sprintf("CreateContainer: couldn't prepare rootfs: %v", err)
+		glog.Info(msg)
+		return nil, errors.New(msg)
+	}
+
+	cont := &nspawnContainer{
+		Container: common.NewContainer(&id,
+			&req.PodSandboxId,
+			kubeapi.ContainerState_CONTAINER_CREATED,
+			req.Config.Metadata,
+			req.Config.Image,
+			req.Config.Mounts,
+			req.Config.Labels,
+			req.Config.Annotations),
+		spec:    buildMachineSpec(req, img, rootfs, filepath.Join(dir, "output.log")),
+		imageId: img.Id,
+		dir:     dir,
+		exited:  make(chan struct{}),
+		removed: make(chan struct{}),
+	}
+
+	p.contMap[id] = cont
+
+	p.events.PublishStatus(id, icommon.ContainerEventType_CREATED, cont.ToKubeStatus())
+
+	return &kubeapi.CreateContainerResponse{ContainerId: id}, nil
+}
+
+func (p *nspawnProvider) StartContainer(req *kubeapi.StartContainerRequest) (*kubeapi.StartContainerResponse, error) {
+	p.mapLock.Lock()
+	defer p.mapLock.Unlock()
+
+	id := req.GetContainerId()
+
+	cont, ok := p.contMap[id]
+	if !ok {
+		return nil, fmt.Errorf("StartContainer: Invalid ContainerID: %v", id)
+	}
+
+	if cont.GetState() != kubeapi.ContainerState_CONTAINER_CREATED {
+		return nil, fmt.Errorf("StartContainer: %v is %v", id, cont.GetState())
+	}
+
+	m, err := startMachine(cont.spec)
+	if err != nil {
+		return nil, fmt.Errorf("StartContainer: couldn't start %v: %v", id, err)
+	}
+
+	cont.machine = m
+	cont.Start()
+
+	go p.waitMachine(cont, m)
+
+	p.events.PublishStatus(id, icommon.ContainerEventType_STARTED, cont.ToKubeStatus())
+
+	return &kubeapi.StartContainerResponse{}, nil
+}
+
+// waitMachine records how the container's machine exited
+func (p *nspawnProvider) waitMachine(cont *nspawnContainer, m *machine) {
+	<-m.Done()
+
+	p.mapLock.Lock()
+	defer p.mapLock.Unlock()
+
+	reason := "Completed"
+	if m.ExitCode() != 0 {
+		reason = "Error"
+	}
+
+	cont.UpdateState(kubeapi.ContainerState_CONTAINER_EXITED, cont.GetStartedAt(), time.Now().Unix(), m.ExitCode(), reason)
+
+	p.events.PublishStatus(*cont.GetId(), icommon.ContainerEventType_STOPPED, cont.ToKubeStatus())
+
+	close(cont.exited)
+}
+
+func (p *nspawnProvider) StopContainer(req *kubeapi.StopContainerRequest) (*kubeapi.StopContainerResponse, error) {
+	id := req.GetContainerId()
+	cont, m, err := p.lookup(id)
+	if err != nil {
+		return nil, fmt.Errorf("StopContainer: %v", err)
+	}
+
+	// waitMachine takes mapLock to record the exit, so we can't hold it while the machine stops
+	if m != nil {
+		m.stop(req.Timeout)
+		<-cont.exited
+	}
+
+	return &kubeapi.StopContainerResponse{}, nil
+}
+
+func (p *nspawnProvider) RemoveContainer(req *kubeapi.RemoveContainerRequest) (*kubeapi.RemoveContainerResponse, error) {
+	id := req.GetContainerId()
+	cont, m, err := p.lookup(id)
+	if err != nil {
+		return nil, fmt.Errorf("RemoveContainer: %v", err)
+	}
+
+	if m != nil {
+		m.stop(0)
+		<-cont.exited
+	}
+
+	p.mapLock.Lock()
+	defer p.mapLock.Unlock()
+
+	if _, ok := p.contMap[id]; !ok {
+		return nil, fmt.Errorf("RemoveContainer: Invalid ContainerID: %v", id)
+	}
+
+	unmountRootfs(cont.dir)
+	if err := os.RemoveAll(cont.dir); err != nil {
+		glog.Warningf("RemoveContainer: couldn't remove %v: %v", cont.dir, err)
+	}
+
+	close(cont.removed)
+	delete(p.contMap, id)
+
+	p.events.PublishStatus(id, icommon.ContainerEventType_REMOVED, nil)
+
+	return &kubeapi.RemoveContainerResponse{}, nil
+}
+
+func (p *nspawnProvider) ListContainers(req *kubeapi.ListContainersRequest) (*kubeapi.ListContainersResponse, error) {
+	p.mapLock.Lock()
+	defer p.mapLock.Unlock()
+
+	containers := []*kubeapi.Container{}
+
+	for _, cont := range p.contMap {
+		if filter(req.Filter, cont.Container) {
+			continue
+		}
+
+		containers = append(containers, cont.ToKubeContainer())
+	}
+
+	resp := &kubeapi.ListContainersResponse{
+		Containers: containers,
+	}
+
+	return resp, nil
+}
+
+func filter(filter *kubeapi.ContainerFilter, cont *common.Container) bool {
+	if filter != nil {
+		if filter.GetId() != "" && filter.GetId() != *cont.GetId() {
+			glog.Infof("Filtering out %v as want %v", *cont.GetId(), filter.GetId())
+			return true
+		}
+
+		if filter.GetState() != nil && filter.GetState().State != cont.GetState() {
+			glog.Infof("Filtering out %v as want %v and got %v", *cont.GetId(), filter.GetState(), cont.GetState())
+			return true
+		}
+
+		if filter.GetPodSandboxId() != "" && filter.GetPodSandboxId() != *cont.GetPodId() {
+			glog.Infof("Filtering out %v as want %v and got %v", *cont.GetId(), filter.GetPodSandboxId(), *cont.GetPodId())
+			return true
+		}
+
+		for k, v := range filter.GetLabelSelector() {
+			if podVal, ok := cont.GetLabels()[k]; !ok {
+				glog.Infof("didn't find key %v in local labels: %+v", k, cont.GetLabels())
+			} else {
+				if podVal != v {
+					glog.Infof("Filtering out %v as want labels[%v] = %v and got %v", *cont.GetId(), k, v, podVal)
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+func (p *nspawnProvider) ContainerStatus(req *kubeapi.ContainerStatusRequest) (*kubeapi.ContainerStatusResponse, error) {
+	p.mapLock.Lock()
+	defer p.mapLock.Unlock()
+
+	id := req.GetContainerId()
+
+	cont, ok := p.contMap[id]
+	if !ok {
+		return nil, fmt.Errorf("ContainerStatus: Invalid ContainerID: %v", id)
+	}
+
+	resp := &kubeapi.ContainerStatusResponse{
+		Status: cont.ToKubeStatus(),
+	}
+
+	return resp, nil
+}
+
+// lookup returns the container with the given id, or whose name is id as infranetes asks for logs by name, and its
+// machine if it was started
+func (p *nspawnProvider) lookup(id string) (*nspawnContainer, *machine, error) {
+	p.mapLock.Lock()
+	defer p.mapLock.Unlock()
+
+	if cont, ok := p.contMap[id]; ok {
+		return cont, cont.machine, nil
+	}
+
+	for _, cont := range p.contMap {
+		if cont.GetMetadata().GetName() == id {
+			return cont, cont.machine, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("Invalid ContainerID: %v", id)
+}
+
+// collect periodically removes cached images and layers no container uses
+func (p *nspawnProvider) collect() {
+	for {
+		time.Sleep(*flags.ArtifactCacheTTL)
+
+		p.mapLock.Lock()
+		inUse := make(map[string]bool)
+		for _, cont := range p.contMap {
+			inUse[cont.imageId] = true
+		}
+		p.mapLock.Unlock()
+
+		// layers are only needed to unpack images, so only the configs of images in use are kept
+		p.collectImages(inUse, *flags.ArtifactCacheTTL)
+		p.artifacts.GC(inUse, *flags.ArtifactCacheTTL)
+	}
+}
+
+func (p *nspawnProvider) Features() []string {
+	return []string{icommon.FeatureExecSync, icommon.FeatureExec, icommon.FeatureAttach, icommon.FeaturePortForward, icommon.FeatureLogs}
+}
+
+func (p *nspawnProvider) Events() *common.EventBus {
+	return p.events
+}
+
+func (p *nspawnProvider) Ready() error {
+	if _, err := exec.LookPath("systemd-nspawn"); err != nil {
+		return fmt.Errorf("systemd-nspawn not available: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,290 @@
+package nspawn
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/reference"
+	"github.com/golang/glog"
+
+	"github.com/apporbit/infranetes/pkg/vmserver/common"
+)
+
+const (
+	defaultRegistry = "registry-1.docker.io"
+
+	mediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+)
+
+var manifestMediaTypes = []string{mediaTypeOCIManifest, mediaTypeDockerManifest, mediaTypeOCIIndex, mediaTypeDockerManifestList}
+
+// imageRef is a parsed image name, defaulted the way docker does
+type imageRef struct {
+	Registry   string
+	Repository string
+	// Reference is the tag or digest to fetch the manifest by
+	Reference string
+}
+
+func parseImageRef(image string) (*imageRef, error) {
+	named, err := reference.ParseNamed(image)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image %q: %v", image, err)
+	}
+
+	ref := &imageRef{Reference: "latest"}
+	ref.Registry, ref.Repository = reference.SplitHostname(named)
+	// the first component is only a registry if it looks like a host, otherwise it's a docker hub user
+	if ref.Registry != "" && !strings.ContainsAny(ref.Registry, ".:") && ref.Registry != "localhost" {
+		ref.Registry, ref.Repository = "", named.Name()
+	}
+	if ref.Registry == "" || ref.Registry == "docker.io" {
+		ref.Registry = defaultRegistry
+		if !strings.Contains(ref.Repository, "/") {
+			ref.Repository = "library/" + ref.Repository
+		}
+	}
+
+	if tagged, ok := named.(reference.NamedTagged); ok {
+		ref.Reference = tagged.Tag()
+	}
+	if digested, ok := named.(reference.Canonical); ok {
+		ref.Reference = digested.Digest().String()
+	}
+
+	return ref, nil
+}
+
+func (r *imageRef) String() string {
+	return r.Registry + "/" + r.Repository + ":" + r.Reference
+}
+
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+	Platform  *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	} `json:"platform,omitempty"`
+}
+
+// manifest covers both image manifests and manifest lists, in their docker and OCI flavours
+type manifest struct {
+	MediaType string       `json:"mediaType"`
+	Config    descriptor   `json:"config"`
+	Layers    []descriptor `json:"layers"`
+	Manifests []descriptor `json:"manifests"`
+}
+
+// imageConfig is the part of the image's config blob a container's defaults come from
+type imageConfig struct {
+	Config struct {
+		User       string   `json:"User"`
+		Env        []string `json:"Env"`
+		Entrypoint []string `json:"Entrypoint"`
+		Cmd        []string `json:"Cmd"`
+		WorkingDir string   `json:"WorkingDir"`
+	} `json:"config"`
+}
+
+// registryClient speaks just enough of the registry v2 api to pull an image, including the token handshake
+// anonymous pulls from docker hub require
+type registryClient struct {
+	store     *common.ArtifactStore
+	allowHTTP bool
+	client    *http.Client
+}
+
+func newRegistryClient(store *common.ArtifactStore, allowHTTP bool) *registryClient {
+	return &registryClient{
+		store:     store,
+		allowHTTP: allowHTTP,
+		client:    &http.Client{Timeout: 10 * time.Minute},
+	}
+}
+
+func (c *registryClient) url(ref *imageRef, kind string, name string) string {
+	scheme := "https"
+	if c.allowHTTP && (strings.HasPrefix(ref.Registry, "localhost") || strings.HasPrefix(ref.Registry, "127.")) {
+		scheme = "http"
+	}
+
+	return fmt.Sprintf("%v://%v/v2/%v/%v/%v", scheme, ref.Registry, ref.Repository, kind, name)
+}
+
+// get fetches target, answering a bearer challenge with a token for ref's repository
+func (c *registryClient) get(ref *imageRef, target string, accept []string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, mediaType := range accept {
+		req.Header.Add("Accept", mediaType)
+	}
+
+	cred, haveCred := c.store.Credential(ref.Registry)
+	if haveCred && cred.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+cred.Token)
+	} else if haveCred {
+		req.SetBasicAuth(cred.Username, cred.Password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		token, err := c.token(ref, challenge)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+		if resp, err = c.client.Do(req); err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %v returned %v", target, resp.Status)
+	}
+
+	return resp, nil
+}
+
+func (c *registryClient) token(ref *imageRef, challenge string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", fmt.Errorf("unsupported auth challenge %q from %v", challenge, ref.Registry)
+	}
+
+	params := make(map[string]string)
+	for _, param := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		if kv := strings.SplitN(strings.TrimSpace(param), "=", 2); len(kv) == 2 {
+			params[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("invalid auth realm in challenge %q", challenge)
+	}
+
+	query := realm.Query()
+	if service, ok := params["service"]; ok {
+		query.Set("service", service)
+	}
+	query.Set("scope", "repository:"+ref.Repository+":pull")
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequest("GET", realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if cred, ok := c.store.Credential(ref.Registry); ok && cred.Username != "" {
+		req.SetBasicAuth(cred.Username, cred.Password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request to %v failed: %v", realm.Host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request to %v returned %v", realm.Host, resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("couldn't parse token from %v: %v", realm.Host, err)
+	}
+
+	if body.Token != "" {
+		return body.Token, nil
+	}
+
+	return body.AccessToken, nil
+}
+
+// manifest resolves ref to the image manifest for this VM's platform, stepping through a manifest list if need be
+func (c *registryClient) manifest(ref *imageRef) (*manifest, error) {
+	resp, err := c.get(ref, c.url(ref, "manifests", ref.Reference), manifestMediaTypes)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var m manifest
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return nil, fmt.Errorf("couldn't parse manifest of %v: %v", ref, err)
+	}
+
+	if m.MediaType == "" {
+		m.MediaType = resp.Header.Get("Content-Type")
+	}
+
+	if m.MediaType != mediaTypeOCIIndex && m.MediaType != mediaTypeDockerManifestList {
+		return &m, nil
+	}
+
+	for _, entry := range m.Manifests {
+		if entry.Platform != nil && entry.Platform.OS == "linux" && entry.Platform.Architecture == runtime.GOARCH {
+			glog.V(2).Infof("manifest: %v resolves to %v for linux/%v", ref, entry.Digest, runtime.GOARCH)
+			platformRef := *ref
+			platformRef.Reference = entry.Digest
+			return c.manifest(&platformRef)
+		}
+	}
+
+	return nil, fmt.Errorf("%v has no image for linux/%v", ref, runtime.GOARCH)
+}
+
+// blob returns the path of digest in the artifact cache, pulling it if it isn't there
+func (c *registryClient) blob(ref *imageRef, digest string) (string, error) {
+	return c.store.Put(digest, func() (io.ReadCloser, error) {
+		glog.Infof("blob: pulling %v from %v", digest, ref)
+		resp, err := c.get(ref, c.url(ref, "blobs", digest), nil)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Body, nil
+	})
+}
+
+func (c *registryClient) config(ref *imageRef, m *manifest) (*imageConfig, error) {
+	path, err := c.blob(ref, m.Config.Digest)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config imageConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("couldn't parse config of %v: %v", ref, err)
+	}
+
+	return &config, nil
+}
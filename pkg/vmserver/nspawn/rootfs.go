@@ -0,0 +1,317 @@
+package nspawn
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/apporbit/infranetes/pkg/vmserver/common"
+)
+
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// image is an unpacked OCI image
+type image struct {
+	// Id is the digest of the image's config, which identifies its content
+	Id     string
+	Rootfs string
+	Config *imageConfig
+}
+
+func (p *nspawnProvider) imageDir(id string) string {
+	return filepath.Join(p.root, "images", strings.TrimPrefix(id, "sha256:"))
+}
+
+// pullImage returns name unpacked, pulling and unpacking whatever layers aren't already on the VM
+func (p *nspawnProvider) pullImage(name string) (*image, error) {
+	ref, err := parseImageRef(name)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := p.registry.manifest(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := p.registry.config(ref, m)
+	if err != nil {
+		return nil, err
+	}
+
+	img := &image{
+		Id:     m.Config.Digest,
+		Rootfs: filepath.Join(p.imageDir(m.Config.Digest), "rootfs"),
+		Config: config,
+	}
+
+	p.imageLock.Lock()
+	defer p.imageLock.Unlock()
+
+	if _, err := os.Stat(img.Rootfs); err == nil {
+		glog.Infof("pullImage: %v is already unpacked as %v", ref, img.Id)
+		now := time.Now()
+		os.Chtimes(p.imageDir(img.Id), now, now)
+		return img, nil
+	}
+
+	tmp, err := ioutil.TempDir(filepath.Join(p.root, "images"), "unpack-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	rootfs := filepath.Join(tmp, "rootfs")
+	if err := os.Mkdir(rootfs, 0755); err != nil {
+		return nil, err
+	}
+
+	for _, layer := range m.Layers {
+		path, err := p.registry.blob(ref, layer.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't pull layer %v of %v: %v", layer.Digest, ref, err)
+		}
+
+		if err := applyLayer(rootfs, path); err != nil {
+			return nil, fmt.Errorf("couldn't apply layer %v of %v: %v", layer.Digest, ref, err)
+		}
+	}
+
+	if err := os.Rename(tmp, p.imageDir(img.Id)); err != nil {
+		return nil, err
+	}
+
+	glog.Infof("pullImage: unpacked %v as %v", ref, img.Id)
+
+	return img, nil
+}
+
+// applyLayer unpacks the layer tarball at path on top of rootfs, honouring whiteouts
+func applyLayer(rootfs string, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var reader io.Reader = bufio.NewReader(f)
+	if gz, err := gzip.NewReader(reader); err == nil {
+		defer gz.Close()
+		reader = gz
+	} else {
+		if _, err := f.Seek(0, os.SEEK_SET); err != nil {
+			return err
+		}
+		reader = f
+	}
+
+	tr := tar.NewReader(reader)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := filepath.Clean("/" + hdr.Name)
+		if name == "/" {
+			continue
+		}
+
+		dir, err := common.SecureJoin(rootfs, filepath.Dir(name))
+		if err != nil {
+			return err
+		}
+		base := filepath.Base(name)
+
+		if base == whiteoutOpaque {
+			if err := emptyDir(dir); err != nil {
+				return err
+			}
+			continue
+		}
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			hidden := strings.TrimPrefix(base, whiteoutPrefix)
+			if hidden == "" || hidden == "." || hidden == ".." {
+				return fmt.Errorf("invalid whiteout %q", hdr.Name)
+			}
+			if err := os.RemoveAll(filepath.Join(dir, hidden)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+
+		if err := applyEntry(rootfs, filepath.Join(dir, base), hdr, tr); err != nil {
+			return fmt.Errorf("%v: %v", hdr.Name, err)
+		}
+	}
+}
+
+func applyEntry(rootfs string, target string, hdr *tar.Header, r io.Reader) error {
+	mode := hdr.FileInfo().Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+
+	// a later layer replaces whatever an earlier one had at this path, except a directory stays a directory
+	if existing, err := os.Lstat(target); err == nil && !(existing.IsDir() && hdr.Typeflag == tar.TypeDir) {
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if err := os.MkdirAll(target, mode); err != nil {
+			return err
+		}
+	case tar.TypeReg, tar.TypeRegA:
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, r)
+		out.Close()
+		if err != nil {
+			return err
+		}
+	case tar.TypeSymlink:
+		// absolute targets are fine, nspawn resolves them inside the container's root
+		if err := os.Symlink(hdr.Linkname, target); err != nil {
+			return err
+		}
+	case tar.TypeLink:
+		source, err := common.SecureJoin(rootfs, filepath.Clean("/"+hdr.Linkname))
+		if err != nil {
+			return err
+		}
+		if err := os.Link(source, target); err != nil {
+			return err
+		}
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		devMode := uint32(mode.Perm())
+		switch hdr.Typeflag {
+		case tar.TypeChar:
+			devMode |= syscall.S_IFCHR
+		case tar.TypeBlock:
+			devMode |= syscall.S_IFBLK
+		case tar.TypeFifo:
+			devMode |= syscall.S_IFIFO
+		}
+		dev := int((hdr.Devmajor << 8) | (hdr.Devminor & 0xff) | ((hdr.Devminor & 0xfff00) << 12))
+		if err := syscall.Mknod(target, devMode, dev); err != nil {
+			glog.Warningf("applyLayer: skipping device %v: %v", hdr.Name, err)
+			return nil
+		}
+	default:
+		glog.Warningf("applyLayer: skipping %v of unsupported type %c", hdr.Name, hdr.Typeflag)
+		return nil
+	}
+
+	if err := os.Lchown(target, hdr.Uid, hdr.Gid); err != nil {
+		return err
+	}
+
+	if hdr.Typeflag != tar.TypeSymlink {
+		// chown clears setuid, so the mode has to be reapplied after it
+		if err := os.Chmod(target, mode); err != nil {
+			return err
+		}
+		os.Chtimes(target, hdr.ModTime, hdr.ModTime)
+	}
+
+	return nil
+}
+
+func emptyDir(dir string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mountRootfs gives a container its own writable copy of img, an overlay where the kernel supports it and a copy
+// where it doesn't
+func mountRootfs(img *image, dir string) (string, error) {
+	rootfs := filepath.Join(dir, "rootfs")
+	upper := filepath.Join(dir, "upper")
+	work := filepath.Join(dir, "work")
+
+	for _, d := range []string{rootfs, upper, work} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return "", err
+		}
+	}
+
+	options := fmt.Sprintf("lowerdir=%v,upperdir=%v,workdir=%v", img.Rootfs, upper, work)
+	err := syscall.Mount("overlay", rootfs, "overlay", 0, options)
+	if err == nil {
+		return rootfs, nil
+	}
+
+	glog.Warningf("mountRootfs: overlay mount failed, copying %v instead: %v", img.Id, err)
+	if output, err := exec.Command("cp", "-a", "--reflink=auto", img.Rootfs+"/.", rootfs).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("couldn't copy %v: %v: %s", img.Rootfs, err, output)
+	}
+
+	return rootfs, nil
+}
+
+func unmountRootfs(dir string) {
+	rootfs := filepath.Join(dir, "rootfs")
+	if err := syscall.Unmount(rootfs, syscall.MNT_DETACH); err != nil && err != syscall.EINVAL {
+		glog.Warningf("unmountRootfs: unmount of %v failed: %v", rootfs, err)
+	}
+}
+
+// collectImages removes unpacked images no container uses that haven't been pulled for ttl
+func (p *nspawnProvider) collectImages(inUse map[string]bool, ttl time.Duration) {
+	p.imageLock.Lock()
+	defer p.imageLock.Unlock()
+
+	entries, err := ioutil.ReadDir(filepath.Join(p.root, "images"))
+	if err != nil {
+		glog.Warningf("collectImages: %v", err)
+		return
+	}
+
+	for _, entry := range entries {
+		id := "sha256:" + entry.Name()
+		if strings.HasPrefix(entry.Name(), "unpack-") || inUse[id] || time.Since(entry.ModTime()) < ttl {
+			continue
+		}
+
+		glog.Infof("collectImages: removing unused image %v", id)
+		if err := os.RemoveAll(filepath.Join(p.root, "images", entry.Name())); err != nil {
+			glog.Warningf("collectImages: couldn't remove %v: %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package nspawn
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	icommon "github.com/apporbit/infranetes/pkg/common"
+	"github.com/apporbit/infranetes/pkg/vmserver/common"
+
+	"k8s.io/client-go/tools/remotecommand"
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+	"k8s.io/kubernetes/pkg/kubelet/server/streaming"
+)
+
+const defaultPath = "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+type streamingRuntime struct {
+	provider *nspawnProvider
+}
+
+var _ streaming.Runtime = &streamingRuntime{}
+
+// nsenterCommand wraps cmd so it runs inside the container's namespaces and root with the container's environment
+func (p *nspawnProvider) nsenterCommand(id string, cmd []string) ([]string, error) {
+	if len(cmd) == 0 {
+		return nil, fmt.Errorf("no command given")
+	}
+
+	cont, m, err := p.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, fmt.Errorf("container %v was never started", id)
+	}
+
+	pid, err := m.leader()
+	if err != nil {
+		return nil, err
+	}
+
+	// env runs on the VM, the image may not have one
+	argv := []string{"env", "-i"}
+	hasPath := false
+	for _, env := range cont.spec.Env {
+		hasPath = hasPath || strings.HasPrefix(env, "PATH=")
+		argv = append(argv, env)
+	}
+	if !hasPath {
+		argv = append(argv, defaultPath)
+	}
+
+	wd := "--wd"
+	if cont.spec.WorkingDir != "" {
+		wd = "--wd=" + cont.spec.WorkingDir
+	}
+	argv = append(argv, "nsenter", "-t", strconv.Itoa(pid), "-m", "-u", "-i", "-p", "--root", wd, "--")
+
+	return append(argv, cmd...), nil
+}
+
+func (p *nspawnProvider) ExecSync(req *kubeapi.ExecSyncRequest) (*kubeapi.ExecSyncResponse, error) {
+	argv, err := p.nsenterCommand(req.ContainerId, req.Cmd)
+	if err != nil {
+		return nil, fmt.Errorf("ExecSync: %v", err)
+	}
+
+	return common.ExecSync(&kubeapi.ExecSyncRequest{ContainerId: req.ContainerId, Cmd: argv, Timeout: req.Timeout})
+}
+
+func (p *nspawnProvider) GetStreamingRuntime() streaming.Runtime {
+	return p.streamingRuntime
+}
+
+func (r *streamingRuntime) Exec(containerID string, cmd []string, in io.Reader, out, err io.WriteCloser, tty bool, resize <-chan remotecommand.TerminalSize) error {
+	argv, nerr := r.provider.nsenterCommand(containerID, cmd)
+	if nerr != nil {
+		return fmt.Errorf("Exec: %v", nerr)
+	}
+
+	return common.Exec(argv, in, out, err, tty, resize)
+}
+
+func (r *streamingRuntime) Attach(containerID string, in io.Reader, out, errw io.WriteCloser, tty bool, resize <-chan remotecommand.TerminalSize) error {
+	_, m, err := r.provider.lookup(containerID)
+	if err != nil {
+		return fmt.Errorf("Attach: %v", err)
+	}
+	if m == nil {
+		return fmt.Errorf("Attach: container %v was never started", containerID)
+	}
+
+	return m.Attach(in, out, errw, resize)
+}
+
+func (r *streamingRuntime) PortForward(podSandboxID string, port int32, stream io.ReadWriteCloser) error {
+	return common.PortForward(podSandboxID, port, stream)
+}
+
+// Logs follows the container's json log from req.Offset, a byte offset into it, until the container is removed
+func (p *nspawnProvider) Logs(req *icommon.LogsRequest, stream icommon.VMServer_LogsServer) error {
+	cont, _, err := p.lookup(req.ContainerID)
+	if err != nil {
+		return fmt.Errorf("Logs: %v", err)
+	}
+
+	return common.FollowJsonLog(stream.Context(), cont.spec.LogPath, req.Offset, cont.removed, stream.Send)
+}
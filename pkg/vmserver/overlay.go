@@ -0,0 +1,68 @@
+package vmserver
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+
+	"github.com/apporbit/infranetes/pkg/common"
+	"github.com/apporbit/infranetes/pkg/overlay"
+)
+
+// overlayKeyFile holds the VM's wireguard key in the state dir, so the VM keeps its key when vmserver restarts
+const overlayKeyFile = "overlay.key"
+
+// SetupOverlay puts the pod's ip on a tunnel to the infranetes node and routes the cluster through it.  The tunnel
+// lives in the kernel, so it outlives vmserver and isn't part of its saved state.
+func (m *VMserver) SetupOverlay(ctx context.Context, req *common.SetupOverlayRequest) (*common.SetupOverlayResponse, error) {
+	glog.Infof("SetupOverlay: %v overlay with pod ip %v through %v", req.Mode, req.PodIp, req.NodeEndpoint)
+
+	if net.ParseIP(req.PodIp) == nil || net.ParseIP(req.Gateway) == nil || net.ParseIP(req.NodeEndpoint) == nil {
+		return nil, fmt.Errorf("SetupOverlay: invalid pod ip %q, gateway %q or node endpoint %q", req.PodIp, req.Gateway, req.NodeEndpoint)
+	}
+
+	m.overlayLock.Lock()
+	defer m.overlayLock.Unlock()
+
+	link, err := overlay.Setup(overlay.Config{
+		Mode:    req.Mode,
+		Address: req.PodIp + "/32",
+		Port:    int(req.Port),
+		VNI:     int(req.Vni),
+		MTU:     int(req.Mtu),
+		KeyFile: filepath.Join(m.stateDir, overlayKeyFile),
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("SetupOverlay: %v", err)
+	}
+
+	node := overlay.Peer{
+		Endpoint:   req.NodeEndpoint,
+		PublicKey:  req.NodePublicKey,
+		AllowedIPs: append([]string{req.Gateway + "/32"}, req.Routes...),
+	}
+	if err := link.AddPeer(node); err != nil {
+		return nil, fmt.Errorf("SetupOverlay: %v", err)
+	}
+	// a kept tunnel may still go to where the node was before
+	if err := link.SyncPeers([]overlay.Peer{node}); err != nil {
+		return nil, fmt.Errorf("SetupOverlay: %v", err)
+	}
+
+	// the pod's address is a /32, so the gateway needs a route of its own before routes can go through it
+	if err := link.AddRoute(req.Gateway+"/32", ""); err != nil {
+		return nil, fmt.Errorf("SetupOverlay: %v", err)
+	}
+	for _, route := range req.Routes {
+		if err := link.AddRoute(route, req.Gateway); err != nil {
+			return nil, fmt.Errorf("SetupOverlay: %v", err)
+		}
+	}
+
+	m.overlay = link
+
+	return &common.SetupOverlayResponse{PublicKey: link.PublicKey()}, nil
+}
@@ -8,6 +8,7 @@ import (
 	"k8s.io/kubernetes/pkg/kubelet/server/streaming"
 
 	"github.com/apporbit/infranetes/pkg/common"
+	vmcommon "github.com/apporbit/infranetes/pkg/vmserver/common"
 	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
 )
 
@@ -21,6 +22,25 @@ type ContainerProvider interface {
 	ExecSync(req *kubeapi.ExecSyncRequest) (*kubeapi.ExecSyncResponse, error)
 	GetStreamingRuntime() streaming.Runtime
 	Logs(req *common.LogsRequest, stream common.VMServer_LogsServer) error
+	// Features lists the optional common.Feature* capabilities this provider implements
+	Features() []string
+	// Ready returns nil once the provider's underlying runtime can service requests
+	Ready() error
+	// Events returns the bus container lifecycle changes are published on
+	Events() *vmcommon.EventBus
+}
+
+// RegistryAuthProvider is implemented by container providers that can pull images from private registries.  Those
+// providers list common.FeatureRegistryAuth in their Features.
+type RegistryAuthProvider interface {
+	// CreateContainerWithAuth is CreateContainer pulling the image with auth, which also returns the image's ref
+	CreateContainerWithAuth(req *kubeapi.CreateContainerRequest, auth *kubeapi.AuthConfig) (*kubeapi.CreateContainerResponse, string, error)
+}
+
+// StatefulProvider is implemented by container providers whose runtime can't tell them their containers again after
+// vmserver restarts, so they keep their container table in vmserver's state dir
+type StatefulProvider interface {
+	RestoreState(dir string) error
 }
 
 var (
@@ -1,20 +1,39 @@
 package vmserver
 
 import (
+	"bytes"
+	"flag"
+	"fmt"
 	"io/ioutil"
+	"net"
+	"net/http"
 	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/golang/glog"
 	"golang.org/x/net/context"
 
 	"github.com/apporbit/infranetes/pkg/common"
+	vmcommon "github.com/apporbit/infranetes/pkg/vmserver/common"
 
-	"k8s.io/apimachinery/pkg/runtime"
 	kubeproxy "k8s.io/kubernetes/cmd/kube-proxy/app"
-	"k8s.io/kubernetes/pkg/apis/componentconfig"
-	"k8s.io/kubernetes/pkg/apis/componentconfig/v1alpha1"
 	"k8s.io/kubernetes/pkg/kubelet/qos"
+	utiliptables "k8s.io/kubernetes/pkg/util/iptables"
+)
+
+// KubeProxyCommand is the first argument vmserver is run with to be kube-proxy rather than vmserver
+const KubeProxyCommand = "kube-proxy"
+
+const (
+	defaultProxyMode          = "iptables"
+	defaultHealthzBindAddress = "0.0.0.0:10256"
+	proxyRestartDelay         = 5 * time.Second
+	proxyStopTimeout          = 10 * time.Second
+	proxyHealthzTimeout       = 2 * time.Second
 )
 
 var (
@@ -23,57 +42,445 @@ var (
 	OOMScoreAdj    = int32(qos.KubeProxyOOMScoreAdj)
 	kubeconfigPath = "/var/lib/kube-proxy/"
 	kubeconfig     = kubeconfigPath + "kubeconfig"
+	kubeProxyLog   = kubeconfigPath + "kube-proxy.log"
 )
 
-func (m *VMserver) StartProxy(ctx context.Context, req *common.StartProxyRequest) (*common.StartProxyResponse, error) {
-	createTables()
+// RunKubeProxy runs the vendored kube-proxy with kube-proxy's command line args, which only returns on failure
+func RunKubeProxy(args []string) error {
+	// vmserver keeps kube-proxy's output in its log
+	flag.Set("logtostderr", "true")
 
-	config := &componentconfig.KubeProxyConfiguration{}
+	cmd := kubeproxy.NewProxyCommand()
+	cmd.SetArgs(args)
 
-	if err := os.MkdirAll(kubeconfigPath, 0700); err != nil {
-		glog.Infof("MkdirAll failed: %v", err)
-		return nil, err
+	return cmd.Execute()
+}
+
+// kubeProxy supervises a kube-proxy running as a child of vmserver.  The vendored kube-proxy can't be stopped once
+// it runs, so it's a process of its own that can be killed, and restarted when it exits by itself.
+type kubeProxy struct {
+	path           string
+	args           []string
+	mode           string
+	healthzAddress string
+
+	lock      sync.Mutex
+	process   *vmcommon.ManagedProcess
+	restarts  int32
+	lastError string
+
+	// stopped is closed to stop kube-proxy and done once the supervisor has
+	stopped chan struct{}
+	done    chan struct{}
+}
+
+// proxyArgs translates req into kube-proxy's command line, which is the same for the vendored kube-proxy and the
+// external one in kubeProxyPath
+func proxyArgs(req *common.StartProxyRequest, podIp string, kubeProxyPath string) ([]string, error) {
+	mode := req.Mode
+	if mode == "" {
+		mode = defaultProxyMode
+	}
+
+	switch mode {
+	case "iptables", "userspace":
+	case "ipvs":
+		if kubeProxyPath == "" {
+			return nil, fmt.Errorf("ipvs mode needs a kube-proxy that supports it in -kube-proxy-path")
+		}
+	default:
+		return nil, fmt.Errorf("unknown proxy mode %q", mode)
+	}
+
+	healthz := req.HealthzBindAddress
+	if healthz == "" {
+		healthz = defaultHealthzBindAddress
+	}
+	healthzHost, healthzPort, err := net.SplitHostPort(healthz)
+	if err != nil {
+		return nil, fmt.Errorf("invalid healthz bind address %q: %v", healthz, err)
 	}
 
-	err := ioutil.WriteFile(kubeconfig, req.Kubeconfig, 0600)
+	args := []string{
+		"--master=https://" + req.Ip,
+		"--kubeconfig=" + kubeconfig,
+		"--cluster-cidr=" + req.ClusterCidr,
+		"--bind-address=" + podIp,
+		"--proxy-mode=" + mode,
+		// kube-proxy still overrides the port of the healthz bind address with the deprecated healthz port
+		"--healthz-bind-address=" + healthzHost,
+		"--healthz-port=" + healthzPort,
+		"--oom-score-adj=" + strconv.Itoa(int(OOMScoreAdj)),
+		"--iptables-masquerade-bit=" + strconv.Itoa(int(MasqueradeBit)),
+		"--masquerade-all=" + strconv.FormatBool(req.MasqueradeAll),
+	}
+
+	// ipvs mode has sync periods of its own
+	syncPrefix := "--iptables-"
+	if mode == "ipvs" {
+		syncPrefix = "--ipvs-"
+	}
+
+	durations := []struct {
+		flag  string
+		value string
+	}{
+		{syncPrefix + "sync-period", req.SyncPeriod},
+		{syncPrefix + "min-sync-period", req.MinSyncPeriod},
+		{"--conntrack-tcp-timeout-established", req.ConntrackTcpEstablishedTimeout},
+		{"--conntrack-tcp-timeout-close-wait", req.ConntrackTcpCloseWaitTimeout},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(d.value); err != nil {
+			return nil, fmt.Errorf("invalid %v %q: %v", d.flag[2:], d.value, err)
+		}
+		args = append(args, d.flag+"="+d.value)
+	}
+
+	if req.ConntrackMaxPerCore != 0 {
+		args = append(args, "--conntrack-max-per-core="+strconv.Itoa(int(req.ConntrackMaxPerCore)))
+	}
+	if req.ConntrackMin != 0 {
+		args = append(args, "--conntrack-min="+strconv.Itoa(int(req.ConntrackMin)))
+	}
 
-	// master details
-	master := "https://" + req.Ip
-	config.ClusterCIDR = req.ClusterCidr
-	config.ClientConnection.KubeConfigFile = kubeconfig
-	config.HealthzBindAddress = "0.0.0.0:10256"
+	return args, nil
+}
+
+// proxyCommand runs the kube-proxy in kubeProxyPath, or this binary as the vendored kube-proxy if it's empty
+func proxyCommand(kubeProxyPath string, args ...string) (*exec.Cmd, error) {
+	var cmd *exec.Cmd
+	if kubeProxyPath != "" {
+		cmd = exec.Command(kubeProxyPath, args...)
+	} else {
+		self, err := os.Executable()
+		if err != nil {
+			return nil, err
+		}
+		cmd = exec.Command(self, append([]string{KubeProxyCommand}, args...)...)
+	}
 
-	syncP, _ := time.ParseDuration("1m")
-	config.IPTables.SyncPeriod.Duration = syncP
-	//minSyncP, _ := time.ParseDuration("10s")
-	//config.IPTables.MinSyncPeriod.Duration = minSyncP
+	// kube-proxy mustn't outlive vmserver, a restarted vmserver starts its own
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGKILL}
+
+	return cmd, nil
+}
 
-	scheme := runtime.NewScheme()
-	if err := componentconfig.AddToScheme(scheme); err != nil {
+func startKubeProxy(path string, args []string, mode string, healthzAddress string) (*kubeProxy, error) {
+	p := &kubeProxy{
+		path:           path,
+		args:           args,
+		mode:           mode,
+		healthzAddress: healthzAddress,
+		stopped:        make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+
+	process, err := p.start()
+	if err != nil {
 		return nil, err
 	}
-	if err := v1alpha1.AddToScheme(scheme); err != nil {
+
+	go p.supervise(process)
+
+	return p, nil
+}
+
+func (p *kubeProxy) start() (*vmcommon.ManagedProcess, error) {
+	cmd, err := proxyCommand(p.path, p.args...)
+	if err != nil {
 		return nil, err
 	}
 
-	config.Mode = componentconfig.ProxyModeIPTables
+	process, err := vmcommon.StartManagedProcess("kube-proxy", cmd, false, false, kubeProxyLog)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't start kube-proxy: %v", err)
+	}
+
+	p.lock.Lock()
+	p.process = process
+	p.lock.Unlock()
+
+	return process, nil
+}
+
+// supervise restarts kube-proxy whenever it exits until it's stopped
+func (p *kubeProxy) supervise(process *vmcommon.ManagedProcess) {
+	defer close(p.done)
+
+	for {
+		if process != nil {
+			select {
+			case <-process.Done():
+				p.setError(fmt.Sprintf("kube-proxy exited with %v", process.ExitCode()))
+			case <-p.stopped:
+				process.Stop(proxyStopTimeout, nil)
+				return
+			}
+		}
+
+		select {
+		case <-time.After(proxyRestartDelay):
+		case <-p.stopped:
+			return
+		}
+
+		p.lock.Lock()
+		p.restarts++
+		p.lock.Unlock()
+
+		var err error
+		if process, err = p.start(); err != nil {
+			p.setError(err.Error())
+		}
+	}
+}
+
+func (p *kubeProxy) setError(msg string) {
+	glog.Warningf("kubeProxy: %v", msg)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.lastError = msg
+}
+
+// stop kills kube-proxy and waits for it to exit
+func (p *kubeProxy) stop() {
+	close(p.stopped)
+	<-p.done
+}
+
+func (p *kubeProxy) status() *common.ProxyStatusResponse {
+	p.lock.Lock()
+	resp := &common.ProxyStatusResponse{
+		Running:  p.process != nil && p.process.Running(),
+		Mode:     p.mode,
+		Restarts: p.restarts,
+	}
+	lastError := p.lastError
+	p.lock.Unlock()
+
+	if !resp.Running {
+		resp.Message = "kube-proxy not running"
+		if lastError != "" {
+			resp.Message += ": " + lastError
+		}
+		return resp
+	}
+
+	if err := p.healthz(); err != nil {
+		resp.Message = err.Error()
+	} else {
+		resp.Healthy = true
+	}
+
+	return resp
+}
+
+// healthz asks kube-proxy if it synced its rules recently
+func (p *kubeProxy) healthz() error {
+	host, port, err := net.SplitHostPort(p.healthzAddress)
+	if err != nil {
+		return err
+	}
+	if host == "" || host == "0.0.0.0" {
+		host = "127.0.0.1"
+	}
+
+	client := &http.Client{Timeout: proxyHealthzTimeout}
+	resp, err := client.Get("http://" + net.JoinHostPort(host, port) + "/healthz")
+	if err != nil {
+		return fmt.Errorf("kube-proxy healthz failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("kube-proxy healthz returned %v", resp.Status)
+	}
+
+	return nil
+}
+
+// cleanupProxyRules removes the rules kube-proxy left behind, so services stop being proxied once it's stopped
+func cleanupProxyRules(kubeProxyPath string, mode string) {
+	args := []string{"--cleanup-iptables"}
+	if mode == "ipvs" {
+		// kube-proxies that support ipvs clean up their ipvs rules as well with --cleanup
+		args = []string{"--cleanup"}
+	}
+
+	cmd, err := proxyCommand(kubeProxyPath, args...)
+	if err != nil {
+		glog.Warningf("cleanupProxyRules: %v", err)
+		return
+	}
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		glog.Warningf("cleanupProxyRules: %v failed: %v: %s", cmd.Args, err, output)
+	}
+}
+
+// writeKubeconfig replaces kube-proxy's kubeconfig atomically, so a restarting kube-proxy never reads half of it
+func writeKubeconfig(data []byte) error {
+	if err := os.MkdirAll(kubeconfigPath, 0700); err != nil {
+		return err
+	}
+
+	tmp := kubeconfig + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, kubeconfig)
+}
+
+// StartProxy runs kube-proxy with req's settings.  If kube-proxy already runs it's restarted with them.
+func (m *VMserver) StartProxy(ctx context.Context, req *common.StartProxyRequest) (*common.StartProxyResponse, error) {
+	if m.podIp == nil {
+		return nil, fmt.Errorf("StartProxy: podIp wasn't set")
+	}
+
+	args, err := proxyArgs(req, *m.podIp, m.kubeProxyPath)
+	if err != nil {
+		return nil, fmt.Errorf("StartProxy: %v", err)
+	}
+
+	createTables(utiliptables.ProtocolIpv4)
+	if m.podIpv6 != nil {
+		createTables(utiliptables.ProtocolIpv6)
+	}
 
-	// defaults
-	config.OOMScoreAdj = &OOMScoreAdj
-	config.IPTables.MasqueradeBit = &MasqueradeBit
-	//config.IPTables.MasqueradeAll = true
-	config.BindAddress = *m.podIp
+	mode := req.Mode
+	if mode == "" {
+		mode = defaultProxyMode
+	}
+	healthz := req.HealthzBindAddress
+	if healthz == "" {
+		healthz = defaultHealthzBindAddress
+	}
 
-	server, err := kubeproxy.NewProxyServer(config, false, scheme, master)
+	m.proxyLock.Lock()
+	defer m.proxyLock.Unlock()
+
+	// under proxyLock, so an UpdateProxyKubeconfig meanwhile doesn't get its kubeconfig replaced with this one
+	if err := writeKubeconfig(req.Kubeconfig); err != nil {
+		return nil, fmt.Errorf("StartProxy: couldn't write kubeconfig: %v", err)
+	}
+
+	if m.proxy != nil {
+		glog.Infof("StartProxy: restarting kube-proxy with new settings")
+		m.proxy.stop()
+		m.proxy = nil
+	}
+
+	proxy, err := startKubeProxy(m.kubeProxyPath, args, mode, healthz)
 	if err != nil {
-		glog.Infof("NewProxyServer failed: %v", err)
+		glog.Infof("StartProxy: %v", err)
 		return nil, err
 	}
 
-	go func() {
-		err := server.Run()
-		glog.Infof("server.Run failed: %v", err)
-	}()
+	m.proxy = proxy
+	m.proxyRequest = req
+	if err := m.writeState(m.proxyRequest); err != nil {
+		glog.Warningf("StartProxy: couldn't save state: %v", err)
+	}
 
 	return &common.StartProxyResponse{}, nil
 }
+
+// StopProxy stops kube-proxy and removes its rules
+func (m *VMserver) StopProxy(ctx context.Context, req *common.StopProxyRequest) (*common.StopProxyResponse, error) {
+	m.proxyLock.Lock()
+	defer m.proxyLock.Unlock()
+
+	if m.proxy == nil {
+		return &common.StopProxyResponse{}, nil
+	}
+
+	glog.Infof("StopProxy: stopping kube-proxy")
+	m.proxy.stop()
+	cleanupProxyRules(m.proxy.path, m.proxy.mode)
+
+	m.proxy = nil
+	m.proxyRequest = nil
+	if err := m.writeState(m.proxyRequest); err != nil {
+		glog.Warningf("StopProxy: couldn't save state: %v", err)
+	}
+
+	return &common.StopProxyResponse{}, nil
+}
+
+// UpdateProxyKubeconfig replaces kube-proxy's credentials, which network policies are enforced with too.  kube-proxy
+// and the policy controller only read the kubeconfig when they start, so they're restarted with the new one.  Their
+// rules stay in place meanwhile, so pods keep running and services keep working.
+func (m *VMserver) UpdateProxyKubeconfig(ctx context.Context, req *common.UpdateProxyKubeconfigRequest) (*common.UpdateProxyKubeconfigResponse, error) {
+	written, err := m.updateProxyKubeconfig(req.Kubeconfig)
+	if written {
+		m.restartNetworkPolicy()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("UpdateProxyKubeconfig: %v", err)
+	}
+
+	return &common.UpdateProxyKubeconfigResponse{}, nil
+}
+
+// updateProxyKubeconfig writes data as the kubeconfig and restarts kube-proxy with it, returning whether it was written
+func (m *VMserver) updateProxyKubeconfig(data []byte) (bool, error) {
+	m.proxyLock.Lock()
+	defer m.proxyLock.Unlock()
+
+	if current, err := ioutil.ReadFile(kubeconfig); err == nil && bytes.Equal(current, data) {
+		return false, nil
+	}
+
+	if err := writeKubeconfig(data); err != nil {
+		return false, fmt.Errorf("couldn't write kubeconfig: %v", err)
+	}
+
+	if m.proxyRequest != nil {
+		updated := *m.proxyRequest
+		updated.Kubeconfig = data
+		m.proxyRequest = &updated
+		if err := m.writeState(m.proxyRequest); err != nil {
+			glog.Warningf("UpdateProxyKubeconfig: couldn't save state: %v", err)
+		}
+	}
+
+	if m.proxy == nil {
+		return true, nil
+	}
+
+	glog.Infof("UpdateProxyKubeconfig: restarting kube-proxy with the new kubeconfig")
+	old := m.proxy
+	old.stop()
+	m.proxy = nil
+
+	proxy, err := startKubeProxy(old.path, old.args, old.mode, old.healthzAddress)
+	if err != nil {
+		return true, err
+	}
+	m.proxy = proxy
+
+	return true, nil
+}
+
+func (m *VMserver) ProxyStatus(ctx context.Context, req *common.ProxyStatusRequest) (*common.ProxyStatusResponse, error) {
+	return m.proxyStatus(), nil
+}
+
+func (m *VMserver) proxyStatus() *common.ProxyStatusResponse {
+	m.proxyLock.Lock()
+	proxy := m.proxy
+	m.proxyLock.Unlock()
+
+	if proxy == nil {
+		return &common.ProxyStatusResponse{Message: "kube-proxy not started"}
+	}
+
+	return proxy.status()
+}
@@ -0,0 +1,188 @@
+package vmserver
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/apporbit/infranetes/pkg/common"
+)
+
+func hasArg(args []string, arg string) bool {
+	for _, a := range args {
+		if a == arg {
+			return true
+		}
+	}
+	return false
+}
+
+func TestProxyArgsDefaults(t *testing.T) {
+	args, err := proxyArgs(&common.StartProxyRequest{Ip: "10.0.0.1", ClusterCidr: "10.1.0.0/16"}, "10.0.0.5", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"--master=https://10.0.0.1",
+		"--cluster-cidr=10.1.0.0/16",
+		"--bind-address=10.0.0.5",
+		"--proxy-mode=iptables",
+		"--healthz-bind-address=0.0.0.0",
+		"--healthz-port=10256",
+		"--masquerade-all=false",
+	} {
+		if !hasArg(args, want) {
+			t.Errorf("args %v are missing %v", args, want)
+		}
+	}
+
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--iptables-sync-period") || strings.HasPrefix(arg, "--conntrack-min") {
+			t.Errorf("unset setting was passed: %v", arg)
+		}
+	}
+}
+
+func TestProxyArgsSettings(t *testing.T) {
+	args, err := proxyArgs(&common.StartProxyRequest{
+		Mode:                           "userspace",
+		SyncPeriod:                     "30s",
+		MinSyncPeriod:                  "5s",
+		MasqueradeAll:                  true,
+		ConntrackMaxPerCore:            65536,
+		ConntrackMin:                   131072,
+		ConntrackTcpEstablishedTimeout: "24h",
+		ConntrackTcpCloseWaitTimeout:   "1h",
+		HealthzBindAddress:             "127.0.0.1:10257",
+	}, "10.0.0.5", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"--proxy-mode=userspace",
+		"--healthz-bind-address=127.0.0.1",
+		"--healthz-port=10257",
+		"--masquerade-all=true",
+		"--iptables-sync-period=30s",
+		"--iptables-min-sync-period=5s",
+		"--conntrack-tcp-timeout-established=24h",
+		"--conntrack-tcp-timeout-close-wait=1h",
+		"--conntrack-max-per-core=65536",
+		"--conntrack-min=131072",
+	}
+	for _, w := range want {
+		if !hasArg(args, w) {
+			t.Errorf("args %v are missing %v", args, w)
+		}
+	}
+}
+
+func TestProxyArgsIPVS(t *testing.T) {
+	req := &common.StartProxyRequest{Mode: "ipvs", SyncPeriod: "10s"}
+
+	if _, err := proxyArgs(req, "10.0.0.5", ""); err == nil {
+		t.Errorf("ipvs should need an external kube-proxy")
+	}
+
+	args, err := proxyArgs(req, "10.0.0.5", "/usr/local/bin/kube-proxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasArg(args, "--ipvs-sync-period=10s") {
+		t.Errorf("args %v are missing the ipvs sync period", args)
+	}
+}
+
+func TestProxyArgsErrors(t *testing.T) {
+	for _, req := range []*common.StartProxyRequest{
+		{Mode: "nftables"},
+		{SyncPeriod: "soon"},
+		{HealthzBindAddress: "10256"},
+	} {
+		if args, err := proxyArgs(req, "10.0.0.5", ""); err == nil {
+			t.Errorf("%+v: expected an error, got %v", req, args)
+		}
+	}
+}
+
+func TestProxyStatusNotStarted(t *testing.T) {
+	status := (&VMserver{}).proxyStatus()
+	if !reflect.DeepEqual(status, &common.ProxyStatusResponse{Message: "kube-proxy not started"}) {
+		t.Errorf("status = %+v", status)
+	}
+}
+
+func TestUpdateProxyKubeconfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kube-proxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(path, file string) { kubeconfigPath, kubeconfig = path, file }(kubeconfigPath, kubeconfig)
+	kubeconfigPath = dir + "/"
+	kubeconfig = kubeconfigPath + "kubeconfig"
+
+	m := &VMserver{proxyRequest: &common.StartProxyRequest{Ip: "10.0.0.1", Kubeconfig: []byte("old")}}
+	if _, err := m.UpdateProxyKubeconfig(context.Background(), &common.UpdateProxyKubeconfigRequest{Kubeconfig: []byte("new")}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(kubeconfig)
+	if err != nil || string(data) != "new" {
+		t.Errorf("kubeconfig = %q, %v", data, err)
+	}
+	// a restarted vmserver starts kube-proxy with the new kubeconfig
+	if string(m.proxyRequest.Kubeconfig) != "new" || m.proxyRequest.Ip != "10.0.0.1" {
+		t.Errorf("proxy request = %+v", m.proxyRequest)
+	}
+}
+
+func TestUpdateProxyKubeconfigRestartsPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kube-proxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(path, file string) { kubeconfigPath, kubeconfig = path, file }(kubeconfigPath, kubeconfig)
+	kubeconfigPath = dir + "/"
+	kubeconfig = kubeconfigPath + "kubeconfig"
+
+	// neither kubeconfig gets the controller to the api server, so it doesn't touch any chains
+	families := []*policyFamily{{podIp: "10.0.0.5", peerChains: []string{"INFRANETES-PEER-1"}}}
+	old := runPolicyController("default", "pod", families, time.Hour, nil)
+	m := &VMserver{policy: old}
+	defer func() {
+		m.policy.stop()
+	}()
+
+	if _, err := m.UpdateProxyKubeconfig(context.Background(), &common.UpdateProxyKubeconfigRequest{Kubeconfig: []byte("new")}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-old.done:
+	default:
+		t.Errorf("the controller with the old kubeconfig is still running")
+	}
+	if m.policy == old || !reflect.DeepEqual(m.policy.families, families) {
+		t.Errorf("policies are enforced by %+v", m.policy)
+	}
+
+	// the same kubeconfig again restarts nothing
+	current := m.policy
+	if _, err := m.UpdateProxyKubeconfig(context.Background(), &common.UpdateProxyKubeconfigRequest{Kubeconfig: []byte("new")}); err != nil {
+		t.Fatal(err)
+	}
+	if m.policy != current {
+		t.Errorf("policy controller was restarted for an unchanged kubeconfig")
+	}
+}
@@ -0,0 +1,130 @@
+package vmserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+
+	"github.com/apporbit/infranetes/pkg/common"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+const stateFile = "state.json"
+
+// serverState is what infranetes told vmserver about its sandbox, which a restarted vmserver needs to serve it again
+type serverState struct {
+	PodIp   *string
+	PodIpv6 *string
+	Config  *kubeapi.PodSandboxConfig
+	// Proxy is the request kube-proxy was last started with.  It holds the proxy's kubeconfig, so the file is 0600.
+	Proxy *common.StartProxyRequest
+	// NetworkPolicy is the request network policies were last enforced with, without its kubeconfig
+	NetworkPolicy *common.StartNetworkPolicyRequest
+	// CNI is the request the VM joined the pod network with, for tearing it down
+	CNI *common.SetupCNIRequest
+}
+
+// saveState writes the sandbox's state to stateDir, replacing what was there atomically.  It takes proxyLock, which
+// callers holding it already save with writeState instead.
+func (m *VMserver) saveState() error {
+	m.proxyLock.Lock()
+	proxy := m.proxyRequest
+	m.proxyLock.Unlock()
+
+	return m.writeState(proxy)
+}
+
+// writeState saves the sandbox's state with proxy as the request kube-proxy was last started with
+func (m *VMserver) writeState(proxy *common.StartProxyRequest) error {
+	if m.stateDir == "" {
+		return nil
+	}
+
+	m.stateLock.Lock()
+	defer m.stateLock.Unlock()
+
+	if err := os.MkdirAll(m.stateDir, 0700); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(&serverState{PodIp: m.podIp, PodIpv6: m.podIpv6, Config: m.config, Proxy: proxy, NetworkPolicy: m.policyRequest, CNI: m.cniRequest})
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(m.stateDir, stateFile)
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// loadState reads the state a previous vmserver saved in dir, returning nil if there is none
+func loadState(dir string) (*serverState, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, stateFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var state serverState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("couldn't parse %v: %v", filepath.Join(dir, stateFile), err)
+	}
+
+	return &state, nil
+}
+
+// restoreState picks the sandbox back up after vmserver restarted, so infranetes still finds its pod ip and config,
+// and restarts the streaming server, kube-proxy and network policies that ran in the previous vmserver
+func (m *VMserver) restoreState() error {
+	if m.stateDir == "" {
+		return nil
+	}
+
+	state, err := loadState(m.stateDir)
+	if err != nil || state == nil {
+		return err
+	}
+
+	m.podIp = state.PodIp
+	m.podIpv6 = state.PodIpv6
+	m.config = state.Config
+
+	if m.podIp == nil {
+		return nil
+	}
+
+	glog.Infof("restoreState: restoring sandbox with pod ip %v", *m.podIp)
+
+	if state.CNI != nil {
+		if err := m.restoreCNI(state.CNI); err != nil {
+			glog.Warningf("restoreState: couldn't restore the pod network: %v", err)
+		}
+	}
+
+	if err := m.startStreamingServer(); err != nil {
+		glog.Warningf("restoreState: couldn't restart streaming server: %v", err)
+	}
+
+	if state.Proxy != nil {
+		if _, err := m.StartProxy(context.Background(), state.Proxy); err != nil {
+			glog.Warningf("restoreState: couldn't restart kube-proxy: %v", err)
+		}
+	}
+
+	// network policies are enforced again once Serve knows the port they must leave open
+	m.policyRequest = state.NetworkPolicy
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package vmserver
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/apporbit/infranetes/pkg/common"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vmserver-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if state, err := loadState(dir); err != nil || state != nil {
+		t.Fatalf("loadState of an empty dir = %v, %v", state, err)
+	}
+
+	ip := "10.0.0.5"
+	ipv6 := "fd00::5"
+	m := &VMserver{
+		stateDir:     dir,
+		podIp:        &ip,
+		podIpv6:      &ipv6,
+		config:       &kubeapi.PodSandboxConfig{Hostname: "pod", Labels: map[string]string{"app": "web"}},
+		proxyRequest: &common.StartProxyRequest{Ip: "10.0.0.1", ClusterCidr: "10.0.0.0/16", Kubeconfig: []byte("kubeconfig")},
+	}
+	if err := m.saveState(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(dir + "/" + stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("state file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	state, err := loadState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.PodIp == nil || *state.PodIp != ip {
+		t.Errorf("pod ip = %v, want %v", state.PodIp, ip)
+	}
+	if state.PodIpv6 == nil || *state.PodIpv6 != ipv6 {
+		t.Errorf("pod ipv6 = %v, want %v", state.PodIpv6, ipv6)
+	}
+	if state.Config.Hostname != "pod" || state.Config.Labels["app"] != "web" {
+		t.Errorf("config = %+v", state.Config)
+	}
+	if state.Proxy == nil || state.Proxy.ClusterCidr != "10.0.0.0/16" || string(state.Proxy.Kubeconfig) != "kubeconfig" {
+		t.Errorf("proxy = %+v", state.Proxy)
+	}
+}
@@ -1,6 +1,8 @@
 package vmserver
 
 import (
+	"net"
+
 	"golang.org/x/net/context"
 
 	"github.com/golang/glog"
@@ -9,6 +11,8 @@ import (
 	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
 )
 
+const streamingPort = "12345"
+
 func (m *VMserver) startStreamingServer() error {
 	runtime := m.contProvider.GetStreamingRuntime()
 
@@ -24,7 +28,7 @@ func (m *VMserver) startStreamingServer() error {
 		return nil
 	}
 
-	addr := *m.podIp + ":12345"
+	addr := net.JoinHostPort(*m.podIp, streamingPort)
 
 	//TODO(sjpotter): Figure out how to work with TLS?
 	config := streaming.Config{
@@ -0,0 +1,90 @@
+package systemd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+
+	"github.com/apporbit/infranetes/pkg/vmserver/common"
+
+	"k8s.io/client-go/tools/remotecommand"
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+	"k8s.io/kubernetes/pkg/kubelet/server/streaming"
+)
+
+type streamingRuntime struct {
+	provider *systemdProvider
+}
+
+var _ streaming.Runtime = &streamingRuntime{}
+
+// nsenterCommand wraps cmd so it runs in the namespaces, working directory and environment of the container's main
+// process, as systemd may have given the unit private mounts or a different user.
+func (p *systemdProvider) nsenterCommand(id string, cmd []string) ([]string, error) {
+	if len(cmd) == 0 {
+		return nil, fmt.Errorf("no command given")
+	}
+
+	status, err := p.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	pid := strconv.Itoa(status.MainPID)
+	argv := []string{"nsenter", "-t", pid, "-m", "-u", "-i", "-n", "-p", "--wd", "--", "env"}
+	argv = append(argv, processEnv(status.MainPID)...)
+
+	return append(argv, cmd...), nil
+}
+
+// processEnv returns the environment pid was started with, so exec sees the container's Envs
+func processEnv(pid int) []string {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/environ", pid))
+	if err != nil {
+		glog.Warningf("processEnv: couldn't read environment of %v: %v", pid, err)
+		return nil
+	}
+
+	ret := []string{}
+	for _, env := range strings.Split(string(data), "\x00") {
+		if strings.Contains(env, "=") {
+			ret = append(ret, env)
+		}
+	}
+
+	return ret
+}
+
+func (p *systemdProvider) ExecSync(req *kubeapi.ExecSyncRequest) (*kubeapi.ExecSyncResponse, error) {
+	argv, err := p.nsenterCommand(req.ContainerId, req.Cmd)
+	if err != nil {
+		return nil, fmt.Errorf("ExecSync: %v", err)
+	}
+
+	return common.ExecSync(&kubeapi.ExecSyncRequest{ContainerId: req.ContainerId, Cmd: argv, Timeout: req.Timeout})
+}
+
+func (p *systemdProvider) GetStreamingRuntime() streaming.Runtime {
+	return p.streamingRuntime
+}
+
+func (r *streamingRuntime) Exec(containerID string, cmd []string, in io.Reader, out, err io.WriteCloser, tty bool, resize <-chan remotecommand.TerminalSize) error {
+	argv, nerr := r.provider.nsenterCommand(containerID, cmd)
+	if nerr != nil {
+		return fmt.Errorf("Exec: %v", nerr)
+	}
+
+	return common.Exec(argv, in, out, err, tty, resize)
+}
+
+func (r *streamingRuntime) Attach(containerID string, in io.Reader, out, errw io.WriteCloser, tty bool, resize <-chan remotecommand.TerminalSize) error {
+	return fmt.Errorf("Attach unsupported for systemd units, their output goes to the journal")
+}
+
+func (r *streamingRuntime) PortForward(podSandboxID string, port int32, stream io.ReadWriteCloser) error {
+	return common.PortForward(podSandboxID, port, stream)
+}
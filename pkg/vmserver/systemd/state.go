@@ -0,0 +1,127 @@
+package systemd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/glog"
+
+	"github.com/apporbit/infranetes/pkg/vmserver/common"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+// stateDir holds what the units don't record about a container.  It lives in /run like the units themselves so
+// neither outlives a reboot of the VM.
+const stateDir = "/run/infranetes/systemd"
+
+type containerRecord struct {
+	Id          string
+	PodId       string
+	Metadata    *kubeapi.ContainerMetadata
+	Image       *kubeapi.ImageSpec
+	Mounts      []*kubeapi.Mount
+	Labels      map[string]string
+	Annotations map[string]string
+	CreatedAt   int64
+	// Digest is the artifact the container runs, which the cache mustn't collect while the container exists
+	Digest string
+}
+
+func recordPath(id string) string {
+	return filepath.Join(stateDir, strings.Replace(id, ":", "_", -1)+".json")
+}
+
+func newRecord(cont *common.Container, digest string) *containerRecord {
+	return &containerRecord{
+		Id:          *cont.GetId(),
+		PodId:       *cont.GetPodId(),
+		Metadata:    cont.GetMetadata(),
+		Image:       cont.GetImage(),
+		Mounts:      cont.GetMounts(),
+		Labels:      cont.GetLabels(),
+		Annotations: cont.GetAnnotations(),
+		CreatedAt:   cont.GetCreatedAt(),
+		Digest:      digest,
+	}
+}
+
+func (r *containerRecord) toContainer() *common.Container {
+	cont := common.NewContainer(&r.Id, &r.PodId, kubeapi.ContainerState_CONTAINER_CREATED, r.Metadata, r.Image, r.Mounts, r.Labels, r.Annotations)
+	cont.SetCreatedAt(r.CreatedAt)
+
+	return cont
+}
+
+func saveRecord(cont *common.Container, digest string) error {
+	if err := os.MkdirAll(stateDir, 0700); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(newRecord(cont, digest))
+	if err != nil {
+		return err
+	}
+
+	path := recordPath(*cont.GetId())
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+func removeRecord(id string) {
+	if err := os.Remove(recordPath(id)); err != nil && !os.IsNotExist(err) {
+		glog.Warningf("removeRecord: couldn't remove record for %v: %v", id, err)
+	}
+}
+
+// loadRecords returns the containers a previous vmserver created whose units still exist, and their artifacts' digests
+func loadRecords() (map[string]*common.Container, map[string]string, error) {
+	ret := make(map[string]*common.Container)
+	digests := make(map[string]string)
+
+	files, err := ioutil.ReadDir(stateDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ret, digests, nil
+		}
+		return nil, nil, fmt.Errorf("loadRecords: couldn't read %v: %v", stateDir, err)
+	}
+
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+
+		path := filepath.Join(stateDir, file.Name())
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			glog.Warningf("loadRecords: couldn't read %v: %v", path, err)
+			continue
+		}
+
+		var record containerRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			glog.Warningf("loadRecords: couldn't parse %v: %v", path, err)
+			continue
+		}
+
+		if _, err := os.Stat(filepath.Join(unitDir, unitName(record.Metadata.GetName()))); err != nil {
+			glog.Infof("loadRecords: unit for %v is gone, dropping it", record.Id)
+			os.Remove(path)
+			continue
+		}
+
+		ret[record.Id] = record.toContainer()
+		digests[record.Id] = record.Digest
+	}
+
+	return ret, digests, nil
+}
@@ -1,33 +1,39 @@
 package systemd
 
 import (
-	"crypto/sha1"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
+	"strconv"
 	"sync"
 	"syscall"
+	"time"
 
-	"github.com/coreos/go-systemd/unit"
 	"github.com/golang/glog"
 
+	"github.com/apporbit/infranetes/cmd/vmserver/flags"
 	icommon "github.com/apporbit/infranetes/pkg/common"
 	"github.com/apporbit/infranetes/pkg/vmserver"
 	"github.com/apporbit/infranetes/pkg/vmserver/common"
 
-	"k8s.io/kubernetes/pkg/kubelet/server/streaming"
-
 	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
 )
 
+// how often we look for units that exited on their own
+const pollInterval = 2 * time.Second
+
 type systemdProvider struct {
 	contMap map[string]*common.Container
 	mapLock sync.Mutex
+	events  *common.EventBus
+
+	artifacts *common.ArtifactStore
+	// digests maps container ids to the artifact they run
+	digests map[string]string
+
+	streamingRuntime *streamingRuntime
 }
 
 func init() {
@@ -36,36 +42,118 @@ func init() {
 
 func NewSystemdProvider() (vmserver.ContainerProvider, error) {
 	glog.Infof("SystemdProvider: starting")
+
+	artifacts, err := common.NewArtifactStore(*flags.ArtifactCacheDir, *flags.ArtifactCredentials, *flags.ArtifactAllowHTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	contMap, digests, err := loadRecords()
+	if err != nil {
+		return nil, err
+	}
+
 	systemdProvider := &systemdProvider{
-		contMap: make(map[string]*common.Container),
+		contMap:   contMap,
+		events:    common.NewEventBus(),
+		artifacts: artifacts,
+		digests:   digests,
 	}
+	systemdProvider.streamingRuntime = &streamingRuntime{provider: systemdProvider}
+
+	for id, cont := range contMap {
+		if err := systemdProvider.refresh(cont); err != nil {
+			glog.Warningf("SystemdProvider: couldn't read state of %v: %v", id, err)
+		}
+		glog.Infof("SystemdProvider: recovered %v in state %v", id, cont.GetState())
+	}
+
+	go systemdProvider.poll()
+	go systemdProvider.collectArtifacts()
 
 	return systemdProvider, nil
 }
 
-func createSystemdUnit(name string, cmd string) error {
-	myUnit := []*unit.UnitOption{
-		{Section: "Unit", Name: "Description", Value: "Infranetes Systemd Unit for " + name},
-		{Section: "Service", Name: "ExecStart", Value: cmd},
-		{Section: "Service", Name: "Restart", Value: "no"},
-		{Section: "Service", Name: "KillMode", Value: "process"},
+func bindMounts(mounts []*kubeapi.Mount) error {
+	for _, mount := range mounts {
+		info, err := os.Stat(mount.HostPath)
+		if err != nil {
+			return fmt.Errorf("couldn't stat %v: %v", mount.HostPath, err)
+		}
+
+		if info.IsDir() {
+			os.MkdirAll(mount.ContainerPath, 0755)
+		} else {
+			os.MkdirAll(filepath.Dir(mount.ContainerPath), 0755)
+			if f, err := os.OpenFile(mount.ContainerPath, os.O_CREATE, 0644); err == nil {
+				f.Close()
+			}
+		}
+
+		flags := uintptr(syscall.MS_BIND)
+		if err := syscall.Mount(mount.HostPath, mount.ContainerPath, "", flags, ""); err != nil {
+			return fmt.Errorf("bind mount of %v to %v failed: %v", mount.HostPath, mount.ContainerPath, err)
+		}
+
+		if mount.Readonly {
+			flags |= syscall.MS_REMOUNT | syscall.MS_RDONLY
+			if err := syscall.Mount(mount.HostPath, mount.ContainerPath, "", flags, ""); err != nil {
+				return fmt.Errorf("read only remount of %v failed: %v", mount.ContainerPath, err)
+			}
+		}
 	}
 
-	reader := unit.Serialize(myUnit)
-	outBytes, err := ioutil.ReadAll(reader)
-	if err != nil {
-		return fmt.Errorf("ioutil.ReadAll failed: %v\n", err)
+	return nil
+}
+
+func unbindMounts(mounts []*kubeapi.Mount) {
+	for _, mount := range mounts {
+		if err := syscall.Unmount(mount.ContainerPath, 0); err != nil && err != syscall.EINVAL && err != syscall.ENOENT {
+			glog.Warningf("unbindMounts: unmount of %v failed: %v", mount.ContainerPath, err)
+		}
 	}
+}
 
-	ioutil.WriteFile("/run/systemd/system/"+name+".service", outBytes, 0644)
+func buildUnitSpec(req *kubeapi.CreateContainerRequest, artifact *common.Artifact) *unitSpec {
+	config := req.GetConfig()
 
-	command := exec.Command("systemctl", "daemon-reload")
-	output, err := command.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("cmd %v failed to run: %v\n", command, output)
+	// like docker, Command replaces the image's entrypoint, which for us is the fetched binary or the bundle's
+	// entrypoint.  Relative commands and the default working directory are the bundle's directory.
+	argv := append([]string{}, config.Command...)
+	workingDir := config.WorkingDir
+	if artifact.Bundle {
+		if len(argv) == 0 {
+			argv = []string{common.BundleEntrypoint}
+		}
+		if !filepath.IsAbs(argv[0]) {
+			argv[0] = filepath.Join(artifact.Path, argv[0])
+		}
+		if workingDir == "" {
+			workingDir = artifact.Path
+		}
+	} else if len(argv) == 0 {
+		argv = []string{artifact.Path}
 	}
+	argv = append(argv, config.Args...)
 
-	return nil
+	spec := &unitSpec{
+		Description: "Infranetes Systemd Unit for " + config.GetMetadata().GetName(),
+		Argv:        argv,
+		WorkingDir:  workingDir,
+		Resources:   config.GetLinux().GetResources(),
+	}
+
+	for _, env := range config.Envs {
+		spec.Env = append(spec.Env, env.Key+"="+env.Value)
+	}
+
+	if uid := config.GetLinux().GetSecurityContext().GetRunAsUser(); uid != nil {
+		spec.User = strconv.FormatInt(uid.Value, 10)
+	} else if user := config.GetLinux().GetSecurityContext().GetRunAsUsername(); user != "" {
+		spec.User = user
+	}
+
+	return spec
 }
 
 func (p *systemdProvider) CreateContainer(req *kubeapi.CreateContainerRequest) (*kubeapi.CreateContainerResponse, error) {
@@ -73,68 +161,39 @@ func (p *systemdProvider) CreateContainer(req *kubeapi.CreateContainerRequest) (
 	defer p.mapLock.Unlock()
 
 	name := req.Config.Metadata.GetName()
+	id := req.GetPodSandboxId() + ":" + name
 
-	//1. fetch binary
-	url := "http://" + req.Config.GetImage().GetImage()
-	glog.Info("CreateContainer: fetching: %v", url)
-	resp, err := http.Get(url)
-	if err != nil {
-		msg := fmt.Sprintf("CreateContainer: http fetch failed: %v", err)
-		glog.Info(msg)
-		return nil, errors.New(msg)
+	if _, ok := p.contMap[id]; ok {
+		return nil, fmt.Errorf("CreateContainer: %v already exists", id)
 	}
-	defer resp.Body.Close()
 
-	//2. store binary in filename based on url
-	h := sha1.New()
-	h.Write([]byte(url))
-	cmdPath := "/usr/local/bin/" + fmt.Sprintf("%x", h.Sum(nil))
-
-	bytes, err := ioutil.ReadAll(resp.Body)
+	//1. fetch binary
+	artifact, err := p.artifacts.Fetch(req.Config.GetImage().GetImage())
 	if err != nil {
-		msg := fmt.Sprintf("CreateContainer: ReadAll failed: %v", err)
+		msg := fmt.Sprintf("CreateContainer: %v", err)
 		glog.Info(msg)
 		return nil, errors.New(msg)
 	}
-	err = ioutil.WriteFile(cmdPath, bytes, 0755)
+
+	//2. build systemd unit
+	err = writeUnit(name, buildUnitSpec(req, artifact))
 	if err != nil {
-		msg := fmt.Sprintf("CreateContainer: WriteFile failed: %v", err)
+		msg := fmt.Sprintf("CreateContainer: writeUnit failed %v", err)
 		glog.Info(msg)
 		return nil, errors.New(msg)
 	}
 
-	//3. build systemd unit
-	cmdSlice := []string{cmdPath}
-	cmdSlice = append(cmdSlice, req.Config.Args...)
-	cmd := strings.Join(cmdSlice, " ")
-	err = createSystemdUnit(name, cmd)
-	if err != nil {
-		msg := fmt.Sprintf("CreateContainer: createSystemdUnit failed %v", err)
+	//3. bind mount things into place
+	if err := bindMounts(req.Config.Mounts); err != nil {
+		unbindMounts(req.Config.Mounts)
+		removeUnit(name)
+		msg := fmt.Sprintf("CreateContainer: %v", err)
 		glog.Info(msg)
 		return nil, errors.New(msg)
 	}
 
-	//4. bind mount things into place
-
-	for _, mount := range req.Config.Mounts {
-		info, _ := os.Stat(mount.HostPath)
-		if info.IsDir() {
-			os.MkdirAll(mount.ContainerPath, 0755)
-		} else {
-			dir := filepath.Dir(mount.HostPath)
-			os.MkdirAll(dir, 0755)
-			os.Create(mount.ContainerPath)
-		}
-
-		err := syscall.Mount(mount.HostPath, mount.ContainerPath, "", syscall.MS_BIND, "")
-		if err != nil {
-			glog.Warningf("CreateContainer: bind mount of %v to %v failed: %v", mount.HostPath, mount.ContainerPath, err)
-		}
-	}
-
-	//5. generate container data
-	id := req.GetPodSandboxId() + ":" + name
-	p.contMap[id] = common.NewContainer(&id,
+	//4. generate container data
+	cont := common.NewContainer(&id,
 		&req.PodSandboxId,
 		kubeapi.ContainerState_CONTAINER_CREATED,
 		req.Config.Metadata,
@@ -143,6 +202,15 @@ func (p *systemdProvider) CreateContainer(req *kubeapi.CreateContainerRequest) (
 		req.Config.Labels,
 		req.Config.Annotations)
 
+	if err := saveRecord(cont, artifact.Digest); err != nil {
+		glog.Warningf("CreateContainer: couldn't save record for %v, it won't survive a vmserver restart: %v", id, err)
+	}
+
+	p.contMap[id] = cont
+	p.digests[id] = artifact.Digest
+
+	p.events.PublishStatus(id, icommon.ContainerEventType_CREATED, cont.ToKubeStatus())
+
 	return &kubeapi.CreateContainerResponse{ContainerId: id}, nil
 }
 
@@ -150,46 +218,50 @@ func (p *systemdProvider) StartContainer(req *kubeapi.StartContainerRequest) (*k
 	p.mapLock.Lock()
 	defer p.mapLock.Unlock()
 
-	_, name, err := icommon.ParseContainer(req.GetContainerId())
-	if err != nil {
-		return nil, fmt.Errorf("StartContainer: err = %v", err)
-	}
-
 	id := req.GetContainerId()
-	if cont, ok := p.contMap[id]; !ok {
+
+	cont, ok := p.contMap[id]
+	if !ok {
 		return nil, fmt.Errorf("StartContainer: Invalid ContainerID: %v", id)
-	} else {
-		command := exec.Command("systemctl", "start", name)
-		output, err := command.CombinedOutput()
-		if err != nil {
-			return nil, fmt.Errorf("cmd %v failed to run: %v\n", command, output)
-		}
+	}
+
+	if _, err := systemctl("start", unitName(cont.GetMetadata().GetName())); err != nil {
+		return nil, fmt.Errorf("StartContainer: %v", err)
+	}
 
+	if err := updateState(cont); err != nil {
+		glog.Warningf("StartContainer: couldn't read state of %v: %v", id, err)
 		cont.Start()
-		return &kubeapi.StartContainerResponse{}, nil
 	}
+
+	p.events.PublishStatus(id, icommon.ContainerEventType_STARTED, cont.ToKubeStatus())
+
+	return &kubeapi.StartContainerResponse{}, nil
 }
 
 func (p *systemdProvider) StopContainer(req *kubeapi.StopContainerRequest) (*kubeapi.StopContainerResponse, error) {
 	p.mapLock.Lock()
 	defer p.mapLock.Unlock()
 
-	splits := strings.Split(req.GetContainerId(), ":")
-	name := splits[1]
-
 	id := req.GetContainerId()
-	if cont, ok := p.contMap[id]; !ok {
+
+	cont, ok := p.contMap[id]
+	if !ok {
 		return nil, fmt.Errorf("StopContainer: Invalid ContainerID: %v", id)
-	} else {
-		command := exec.Command("systemctl", "stop", name)
-		output, err := command.CombinedOutput()
-		if err != nil {
-			return nil, fmt.Errorf("cmd %v failed to run: %v\n", command, output)
-		}
+	}
 
+	if _, err := systemctl("stop", unitName(cont.GetMetadata().GetName())); err != nil {
+		return nil, fmt.Errorf("StopContainer: %v", err)
+	}
+
+	if err := updateState(cont); err != nil {
+		glog.Warningf("StopContainer: couldn't read state of %v: %v", id, err)
 		cont.Finished()
-		return &kubeapi.StopContainerResponse{}, nil
 	}
+
+	p.events.PublishStatus(id, icommon.ContainerEventType_STOPPED, cont.ToKubeStatus())
+
+	return &kubeapi.StopContainerResponse{}, nil
 }
 
 func (p *systemdProvider) RemoveContainer(req *kubeapi.RemoveContainerRequest) (*kubeapi.RemoveContainerResponse, error) {
@@ -197,12 +269,36 @@ func (p *systemdProvider) RemoveContainer(req *kubeapi.RemoveContainerRequest) (
 	defer p.mapLock.Unlock()
 
 	id := req.GetContainerId()
-	if _, ok := p.contMap[id]; !ok {
+
+	cont, ok := p.contMap[id]
+	if !ok {
 		return nil, fmt.Errorf("RemoveContainer: Invalid ContainerID: %v", id)
-	} else {
-		delete(p.contMap, id)
-		return &kubeapi.RemoveContainerResponse{}, nil
 	}
+
+	name := cont.GetMetadata().GetName()
+
+	if cont.GetState() == kubeapi.ContainerState_CONTAINER_RUNNING {
+		if _, err := systemctl("stop", unitName(name)); err != nil {
+			return nil, fmt.Errorf("RemoveContainer: %v", err)
+		}
+	}
+
+	// forget the unit's result so a container of the same name starts from a clean slate
+	systemctl("reset-failed", unitName(name))
+
+	if err := removeUnit(name); err != nil {
+		return nil, fmt.Errorf("RemoveContainer: removeUnit failed: %v", err)
+	}
+
+	unbindMounts(cont.GetMounts())
+	removeRecord(id)
+
+	delete(p.contMap, id)
+	delete(p.digests, id)
+
+	p.events.PublishStatus(id, icommon.ContainerEventType_REMOVED, nil)
+
+	return &kubeapi.RemoveContainerResponse{}, nil
 }
 
 func (p *systemdProvider) ListContainers(req *kubeapi.ListContainersRequest) (*kubeapi.ListContainersResponse, error) {
@@ -211,10 +307,15 @@ func (p *systemdProvider) ListContainers(req *kubeapi.ListContainersRequest) (*k
 
 	containers := []*kubeapi.Container{}
 
-	for _, cont := range p.contMap {
+	for id, cont := range p.contMap {
+		if err := p.refresh(cont); err != nil {
+			glog.Warningf("ListContainers: couldn't read state of %v: %v", id, err)
+		}
+
 		if filter(req.Filter, cont) {
 			continue
 		}
+
 		containers = append(containers, cont.ToKubeContainer())
 	}
 
@@ -227,12 +328,12 @@ func (p *systemdProvider) ListContainers(req *kubeapi.ListContainersRequest) (*k
 
 func filter(filter *kubeapi.ContainerFilter, cont *common.Container) bool {
 	if filter != nil {
-		if filter.GetId() != "" && filter.GetId() == *cont.GetId() {
+		if filter.GetId() != "" && filter.GetId() != *cont.GetId() {
 			glog.Infof("Filtering out %v as want %v", *cont.GetId(), filter.GetId())
 			return true
 		}
 
-		if filter.GetState().State == cont.GetState() {
+		if filter.GetState() != nil && filter.GetState().State != cont.GetState() {
 			glog.Infof("Filtering out %v as want %v and got %v", *cont.GetId(), filter.GetState(), cont.GetState())
 			return true
 		}
@@ -262,31 +363,140 @@ func (p *systemdProvider) ContainerStatus(req *kubeapi.ContainerStatusRequest) (
 	defer p.mapLock.Unlock()
 
 	id := req.GetContainerId()
-	if cont, ok := p.contMap[id]; !ok {
+
+	cont, ok := p.contMap[id]
+	if !ok {
 		return nil, fmt.Errorf("ContainerStatus: Invalid ContainerID: %v", id)
-	} else {
-		resp := &kubeapi.ContainerStatusResponse{
-			Status: cont.ToKubeStatus(),
+	}
+
+	if err := p.refresh(cont); err != nil {
+		return nil, fmt.Errorf("ContainerStatus: couldn't read state of %v: %v", id, err)
+	}
+
+	resp := &kubeapi.ContainerStatusResponse{
+		Status: cont.ToKubeStatus(),
+	}
+
+	return resp, nil
+}
+
+func updateState(cont *common.Container) error {
+	status, err := showUnit(cont.GetMetadata().GetName())
+	if err != nil {
+		return err
+	}
+
+	cont.UpdateState(status.kubeState())
+
+	return nil
+}
+
+// refresh reads cont's state from its unit, publishing an event when a unit stopped on its own.  Callers hold mapLock.
+func (p *systemdProvider) refresh(cont *common.Container) error {
+	previous := cont.GetState()
+	if err := updateState(cont); err != nil {
+		return err
+	}
+
+	if previous == kubeapi.ContainerState_CONTAINER_RUNNING && cont.GetState() == kubeapi.ContainerState_CONTAINER_EXITED {
+		p.events.PublishStatus(*cont.GetId(), icommon.ContainerEventType_STOPPED, cont.ToKubeStatus())
+	}
+
+	return nil
+}
+
+// poll notices units that exit without going through StopContainer, e.g. a crash or a job that completed
+func (p *systemdProvider) poll() {
+	for range time.Tick(pollInterval) {
+		p.mapLock.Lock()
+		for id, cont := range p.contMap {
+			if cont.GetState() != kubeapi.ContainerState_CONTAINER_RUNNING {
+				continue
+			}
+			if err := p.refresh(cont); err != nil {
+				glog.V(2).Infof("poll: couldn't read state of %v: %v", id, err)
+			}
+		}
+		p.mapLock.Unlock()
+	}
+}
+
+// collectArtifacts periodically removes cached artifacts no container has used for the cache's ttl
+func (p *systemdProvider) collectArtifacts() {
+	for {
+		p.mapLock.Lock()
+		inUse := make(map[string]bool)
+		for _, digest := range p.digests {
+			inUse[digest] = true
 		}
+		p.mapLock.Unlock()
 
-		return resp, nil
+		p.artifacts.GC(inUse, *flags.ArtifactCacheTTL)
+
+		time.Sleep(*flags.ArtifactCacheTTL)
 	}
 }
 
-func (f *systemdProvider) ExecSync(req *kubeapi.ExecSyncRequest) (*kubeapi.ExecSyncResponse, error) {
-	var code int32
-	code = 0
-	ret := &kubeapi.ExecSyncResponse{
-		ExitCode: code,
+// lookup returns the unit status of a running container for exec
+func (p *systemdProvider) lookup(id string) (*unitStatus, error) {
+	p.mapLock.Lock()
+	cont, ok := p.contMap[id]
+	p.mapLock.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("Invalid ContainerID: %v", id)
 	}
 
-	return ret, nil
+	status, err := showUnit(cont.GetMetadata().GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	if status.MainPID == 0 {
+		return nil, fmt.Errorf("container %v is not running", id)
+	}
+
+	return status, nil
+}
+
+func (p *systemdProvider) Features() []string {
+	return []string{icommon.FeatureExecSync, icommon.FeatureExec, icommon.FeaturePortForward, icommon.FeatureLogs}
 }
 
-func (f *systemdProvider) GetStreamingRuntime() streaming.Runtime {
+func (p *systemdProvider) Events() *common.EventBus {
+	return p.events
+}
+
+func (p *systemdProvider) Ready() error {
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return fmt.Errorf("systemctl not available: %v", err)
+	}
+
 	return nil
 }
 
-func (d *systemdProvider) Logs(req *icommon.LogsRequest, stream icommon.VMServer_LogsServer) error {
-	return fmt.Errorf("Logging not currently support in systemd mode yet")
+func (p *systemdProvider) Logs(req *icommon.LogsRequest, stream icommon.VMServer_LogsServer) error {
+	// infranetes asks for the part of the id after the sandbox, which for us is the container's name
+	name := req.ContainerID
+	if _, n, err := icommon.ParseContainer(req.ContainerID); err == nil {
+		name = n
+	}
+
+	p.mapLock.Lock()
+	found := false
+	for _, cont := range p.contMap {
+		if cont.GetMetadata().GetName() == name {
+			found = true
+			break
+		}
+	}
+	p.mapLock.Unlock()
+
+	if !found {
+		return fmt.Errorf("Logs: Invalid ContainerID: %v", req.ContainerID)
+	}
+
+	sources := []common.LogSource{{Kind: common.LogSourceJournald, Target: unitName(name)}}
+
+	return common.StreamGuestLogs(stream.Context(), sources, req.Offset, stream.Send)
 }
@@ -0,0 +1,236 @@
+package systemd
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-systemd/unit"
+
+	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
+)
+
+const (
+	unitDir    = "/run/systemd/system"
+	unitPrefix = "infranetes-"
+
+	// format systemctl show prints timestamps in
+	showTimestampFormat = "Mon 2006-01-02 15:04:05 MST"
+)
+
+// unitName keeps our units from colliding with the guest's own, e.g. a container named "ssh"
+func unitName(name string) string {
+	return unitPrefix + name + ".service"
+}
+
+type unitSpec struct {
+	Description string
+	Argv        []string
+	Env         []string
+	WorkingDir  string
+	User        string
+	Resources   *kubeapi.LinuxContainerResources
+}
+
+func (u *unitSpec) options() []*unit.UnitOption {
+	quoted := make([]string, len(u.Argv))
+	for i, arg := range u.Argv {
+		quoted[i] = escapeArg(arg)
+	}
+
+	opts := []*unit.UnitOption{
+		{Section: "Unit", Name: "Description", Value: u.Description},
+		{Section: "Service", Name: "ExecStart", Value: strings.Join(quoted, " ")},
+		{Section: "Service", Name: "Restart", Value: "no"},
+		{Section: "Service", Name: "KillMode", Value: "mixed"},
+	}
+
+	for _, env := range u.Env {
+		opts = append(opts, &unit.UnitOption{Section: "Service", Name: "Environment", Value: escapeEnv(env)})
+	}
+
+	if u.WorkingDir != "" {
+		opts = append(opts, &unit.UnitOption{Section: "Service", Name: "WorkingDirectory", Value: u.WorkingDir})
+	}
+
+	if u.User != "" {
+		opts = append(opts, &unit.UnitOption{Section: "Service", Name: "User", Value: u.User})
+	}
+
+	if r := u.Resources; r != nil {
+		if r.CpuShares > 0 {
+			opts = append(opts,
+				&unit.UnitOption{Section: "Service", Name: "CPUAccounting", Value: "yes"},
+				&unit.UnitOption{Section: "Service", Name: "CPUShares", Value: strconv.FormatInt(r.CpuShares, 10)})
+		}
+		if r.CpuQuota > 0 && r.CpuPeriod > 0 {
+			percent := r.CpuQuota * 100 / r.CpuPeriod
+			if percent < 1 {
+				percent = 1
+			}
+			opts = append(opts, &unit.UnitOption{Section: "Service", Name: "CPUQuota", Value: fmt.Sprintf("%d%%", percent)})
+		}
+		if r.MemoryLimitInBytes > 0 {
+			opts = append(opts,
+				&unit.UnitOption{Section: "Service", Name: "MemoryAccounting", Value: "yes"},
+				&unit.UnitOption{Section: "Service", Name: "MemoryLimit", Value: strconv.FormatInt(r.MemoryLimitInBytes, 10)})
+		}
+		if r.OomScoreAdj != 0 {
+			opts = append(opts, &unit.UnitOption{Section: "Service", Name: "OOMScoreAdjust", Value: strconv.FormatInt(r.OomScoreAdj, 10)})
+		}
+	}
+
+	return opts
+}
+
+// escapeArg quotes s for ExecStart, where systemd would otherwise split on whitespace and expand $VARIABLES
+// and %specifiers
+func escapeArg(s string) string {
+	return escapeEnv(strings.Replace(s, "$", "$$", -1))
+}
+
+// escapeEnv quotes s for Environment, which expands %specifiers but not $VARIABLES
+func escapeEnv(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	s = strings.Replace(s, "%", "%%", -1)
+
+	return `"` + s + `"`
+}
+
+func writeUnit(name string, spec *unitSpec) error {
+	outBytes, err := ioutil.ReadAll(unit.Serialize(spec.options()))
+	if err != nil {
+		return fmt.Errorf("ioutil.ReadAll failed: %v", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(unitDir, unitName(name)), outBytes, 0644); err != nil {
+		return fmt.Errorf("couldn't write unit: %v", err)
+	}
+
+	_, err = systemctl("daemon-reload")
+
+	return err
+}
+
+func removeUnit(name string) error {
+	if err := os.Remove(filepath.Join(unitDir, unitName(name))); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	_, err := systemctl("daemon-reload")
+
+	return err
+}
+
+func systemctl(args ...string) (string, error) {
+	command := exec.Command("systemctl", args...)
+	output, err := command.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("cmd %v failed to run: %v: %s", command.Args, err, output)
+	}
+
+	return string(output), nil
+}
+
+// unitStatus is the part of systemctl show we map onto CRI container state
+type unitStatus struct {
+	ActiveState string
+	Result      string
+	MainPID     int
+	ExitCode    int32
+	StartedAt   time.Time
+	FinishedAt  time.Time
+}
+
+var showProperties = []string{"ActiveState", "Result", "MainPID", "ExecMainCode", "ExecMainStatus", "ExecMainStartTimestamp", "ExecMainExitTimestamp"}
+
+func showUnit(name string) (*unitStatus, error) {
+	args := []string{"show", unitName(name)}
+	for _, prop := range showProperties {
+		args = append(args, "--property="+prop)
+	}
+
+	output, err := systemctl(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	props := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		if kv := strings.SplitN(scanner.Text(), "=", 2); len(kv) == 2 {
+			props[kv[0]] = kv[1]
+		}
+	}
+
+	status := &unitStatus{
+		ActiveState: props["ActiveState"],
+		Result:      props["Result"],
+		StartedAt:   parseShowTimestamp(props["ExecMainStartTimestamp"]),
+		FinishedAt:  parseShowTimestamp(props["ExecMainExitTimestamp"]),
+	}
+	status.MainPID, _ = strconv.Atoi(props["MainPID"])
+
+	code, _ := strconv.Atoi(props["ExecMainCode"])
+	exit, _ := strconv.Atoi(props["ExecMainStatus"])
+	switch code {
+	case 2, 3: // CLD_KILLED, CLD_DUMPED: status is the signal, report it the way a shell would
+		status.ExitCode = int32(128 + exit)
+	default:
+		status.ExitCode = int32(exit)
+	}
+
+	return status, nil
+}
+
+func parseShowTimestamp(value string) time.Time {
+	if value == "" || value == "n/a" {
+		return time.Time{}
+	}
+
+	t, err := time.ParseInLocation(showTimestampFormat, value, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return t
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.Unix()
+}
+
+// kubeState converts the unit's status to the arguments of common.Container.UpdateState
+func (s *unitStatus) kubeState() (kubeapi.ContainerState, int64, int64, int32, string) {
+	switch s.ActiveState {
+	case "active", "activating", "deactivating", "reloading":
+		return kubeapi.ContainerState_CONTAINER_RUNNING, unixOrZero(s.StartedAt), 0, 0, ""
+	}
+
+	if s.StartedAt.IsZero() {
+		return kubeapi.ContainerState_CONTAINER_CREATED, 0, 0, 0, ""
+	}
+
+	reason := "Completed"
+	switch s.Result {
+	case "success":
+	case "oom-kill":
+		reason = "OOMKilled"
+	default:
+		reason = "Error: " + s.Result
+	}
+
+	return kubeapi.ContainerState_CONTAINER_EXITED, unixOrZero(s.StartedAt), unixOrZero(s.FinishedAt), s.ExitCode, reason
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
@@ -23,6 +25,8 @@ import (
 	"k8s.io/kubernetes/pkg/kubelet/types"
 
 	"github.com/apporbit/infranetes/pkg/common"
+	"github.com/apporbit/infranetes/pkg/overlay"
+	vmcommon "github.com/apporbit/infranetes/pkg/vmserver/common"
 	kubeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/v1alpha1/runtime"
 )
 
@@ -52,14 +56,31 @@ func init() {
 
 type VMserver struct {
 	contProvider    ContainerProvider
+	providerName    string
 	server          *grpc.Server
 	podIp           *string
+	podIpv6         *string
 	config          *kubeapi.PodSandboxConfig
 	streamingServer streaming.Server
 	cadvisor        manager.Manager
+	proxy           *kubeProxy
+	proxyLock       sync.Mutex
+	proxyRequest    *common.StartProxyRequest
+	kubeProxyPath   string
+	policy          *policyController
+	policyLock      sync.Mutex
+	policyRequest   *common.StartNetworkPolicyRequest
+	listenPort      int
+	overlay         *overlay.Link
+	overlayLock     sync.Mutex
+	cni             *cniNetwork
+	cniLock         sync.Mutex
+	cniRequest      *common.SetupCNIRequest
+	stateDir        string
+	stateLock       sync.Mutex
 }
 
-func NewVMServer(cert *string, key *string, contProvider ContainerProvider) (*VMserver, error) {
+func NewVMServer(cert *string, key *string, providerName string, contProvider ContainerProvider, stateDir string, kubeProxyPath string) (*VMserver, error) {
 	var opts []grpc.ServerOption
 	creds, err := credentials.NewServerTLSFromFile(*cert, *key)
 	if err != nil {
@@ -81,13 +102,31 @@ func NewVMServer(cert *string, key *string, contProvider ContainerProvider) (*VM
 	}
 
 	manager := &VMserver{
-		contProvider: contProvider,
-		server:       grpc.NewServer(opts...),
-		cadvisor:     m,
+		contProvider:  contProvider,
+		providerName:  providerName,
+		server:        grpc.NewServer(opts...),
+		cadvisor:      m,
+		stateDir:      stateDir,
+		kubeProxyPath: kubeProxyPath,
 	}
 
 	manager.registerServer()
 
+	// guest logs resume where they left off across restarts when there's somewhere to keep where that was
+	if stateDir != "" {
+		vmcommon.SetGuestLogDir(filepath.Join(stateDir, "guestlogs"))
+	}
+
+	if stateful, ok := contProvider.(StatefulProvider); ok && stateDir != "" {
+		if err := stateful.RestoreState(stateDir); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := manager.restoreState(); err != nil {
+		glog.Warningf("NewVMServer: couldn't restore state from %v: %v", stateDir, err)
+	}
+
 	return manager, nil
 }
 
@@ -99,6 +138,17 @@ func (s *VMserver) registerServer() {
 func (s *VMserver) Serve(port int) error {
 	glog.V(1).Infof("Start infranetes on port %d", port)
 
+	s.policyLock.Lock()
+	s.listenPort = port
+	restored := s.policyRequest
+	s.policyLock.Unlock()
+
+	if restored != nil {
+		if err := s.startNetworkPolicy(restored); err != nil {
+			glog.Warningf("Serve: couldn't enforce network policies: %v", err)
+		}
+	}
+
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 
 	if err != nil {
@@ -119,7 +169,7 @@ func (s *VMserver) Version(ctx context.Context, req *kubeapi.VersionRequest) (*k
 	resp := &kubeapi.VersionResponse{
 		RuntimeApiVersion: runtimeAPIVersion,
 		RuntimeName:       runtimeName,
-		RuntimeVersion:    runtimeAPIVersion,
+		RuntimeVersion:    common.ProtocolVersion,
 		Version:           runtimeAPIVersion,
 	}
 
@@ -156,6 +206,39 @@ func (m *VMserver) CreateContainer(ctx context.Context, req *kubeapi.CreateConta
 	return resp, err
 }
 
+func (m *VMserver) CreateContainerWithAuth(ctx context.Context, req *common.CreateContainerWithAuthRequest) (*common.CreateContainerWithAuthResponse, error) {
+	provider, ok := m.contProvider.(RegistryAuthProvider)
+	if !ok {
+		return nil, fmt.Errorf("CreateContainerWithAuth: %v provider doesn't support registry auth", m.providerName)
+	}
+
+	var createReq kubeapi.CreateContainerRequest
+	if err := json.Unmarshal(req.Request, &createReq); err != nil {
+		return nil, fmt.Errorf("CreateContainerWithAuth: couldn't unmarshal request: %v", err)
+	}
+
+	var auth *kubeapi.AuthConfig
+	if len(req.Auth) > 0 {
+		auth = &kubeapi.AuthConfig{}
+		if err := json.Unmarshal(req.Auth, auth); err != nil {
+			return nil, fmt.Errorf("CreateContainerWithAuth: couldn't unmarshal auth: %v", err)
+		}
+	}
+
+	// the credentials are never logged
+	glog.Infof("CreateContainerWithAuth: req = %+v", &createReq)
+
+	resp, ref, err := provider.CreateContainerWithAuth(&createReq, auth)
+
+	glog.Infof("CreateContainerWithAuth: resp = %+v, ref = %v, err = %v", resp, ref, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &common.CreateContainerWithAuthResponse{ContainerId: resp.ContainerId, ImageRef: ref}, nil
+}
+
 func (m *VMserver) StartContainer(ctx context.Context, req *kubeapi.StartContainerRequest) (*kubeapi.StartContainerResponse, error) {
 	glog.Infof("StartContainer: req = %+v", req)
 